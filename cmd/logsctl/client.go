@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// errNotFound 资源不存在，对应 API 的 404
+var errNotFound = errors.New("not found")
+
+// apiError API 返回的错误响应
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// Error 返回错误信息、错误码与状态码
+func (e *apiError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+	}
+	return fmt.Sprintf("%s (%s, HTTP %d)", e.Message, e.Code, e.Status)
+}
+
+// Is 使 404 错误匹配 errNotFound
+func (e *apiError) Is(target error) bool {
+	return target == errNotFound && e.Status == http.StatusNotFound
+}
+
+// client 访问日志服务 HTTP API 的客户端
+type client struct {
+	server string
+	apiKey string
+	http   *http.Client
+}
+
+// newClient 创建客户端，server 为服务器地址，如 http://localhost:8080
+func newClient(server, apiKey string, timeout time.Duration) (*client, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid server address: %q", server)
+	}
+	return &client{
+		server: strings.TrimRight(server, "/"),
+		apiKey: apiKey,
+		http:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// do 发送请求，body 不为 nil 时编码为 JSON；out 不为 nil 时解码响应体。
+// 非 2xx 响应转换为 *apiError
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.doWithHeader(ctx, method, path, nil, body, out)
+}
+
+// doWithHeader 同 do，并附带额外的请求头
+func (c *client) doWithHeader(ctx context.Context, method, path string, header http.Header, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &apiError{Status: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		var errResp struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}
+		if json.Unmarshal(data, &errResp) == nil && errResp.Error != "" {
+			apiErr.Message, apiErr.Code = errResp.Error, errResp.Code
+		}
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// schemaPath 返回 schema 资源路径
+func schemaPath(project, table string) string {
+	return "/api/v1/schemas/" + url.PathEscape(project) + "/" + url.PathEscape(table)
+}
+
+// projectPath 返回项目资源路径
+func projectPath(project string) string {
+	return "/api/v1/projects/" + url.PathEscape(project)
+}
+
+// logsPath 返回日志资源路径
+func logsPath(project, table string) string {
+	return "/api/v1/logs/" + url.PathEscape(project) + "/" + url.PathEscape(table)
+}
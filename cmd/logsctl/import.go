@@ -0,0 +1,305 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"pkg.blksails.net/logs/internal/importer"
+)
+
+const (
+	// defaultImportState 记录导入进度的默认文件
+	defaultImportState = ".logsctl-import.json"
+	// maxReportedLines 每个文件最多输出的被拒绝行数，超出部分只计数
+	maxReportedLines = 20
+)
+
+// importProgress 一个文件的导入进度
+type importProgress struct {
+	Run      string `json:"run"`  // 本次导入的标识，与行号一起组成批次的 Idempotency-Key
+	Size     int64  `json:"size"` // 最近一次读取时的文件大小，文件变小说明已被替换，需要从头导入
+	Line     int    `json:"line"` // 已写入或被拒绝的最后一行
+	Accepted int    `json:"accepted"`
+	Rejected int    `json:"rejected"`
+	Done     bool   `json:"done"`
+}
+
+// importState 导入进度文件，按项目、表与文件的绝对路径记录每个文件的进度
+type importState struct {
+	path  string
+	Files map[string]*importProgress `json:"files"`
+}
+
+// loadImportState 读取进度文件，文件不存在时返回空的进度
+func loadImportState(path string) (*importState, error) {
+	state := &importState{path: path, Files: make(map[string]*importProgress)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid import state %s: %w", path, err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]*importProgress)
+	}
+	return state, nil
+}
+
+// save 先写入临时文件再替换，中断时不会留下不完整的进度文件
+func (s *importState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// newImportCommand 从本地日志文件批量导入历史日志
+func newImportCommand(opts *options) *cobra.Command {
+	var format, mappingFile, statePath string
+	var batchSize int
+	var restart bool
+	cmd := &cobra.Command{
+		Use:   "import PROJECT TABLE FILE...",
+		Short: "从 JSONL、CSV 或 zap console 日志文件批量导入历史日志",
+		Long: `从 JSONL、CSV 或 zap console 日志文件批量导入历史日志。
+
+格式默认按扩展名推断：.csv 为 CSV，.log 与 .txt 为 zap console，其余为 JSONL。--mapping 指定列映射文件：
+  format: csv
+  fields: {ts: timestamp, lvl: level, msg: message, caller: "-"}
+  timestamp_layout: "2006-01-02 15:04:05"   # Go 时间布局，或 unix、unix_ms、unix_us、unix_ns
+  timezone: Asia/Shanghai
+  defaults: {level: info}
+
+每批写入后进度保存到 --state 文件，中断后再次运行相同的命令从上次的位置继续，已完成的文件被跳过；--restart 从头导入。
+无法解析或未通过校验的行被跳过并输出到标准错误。`,
+		Args: cobra.MinimumNArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if batchSize <= 0 {
+				return fmt.Errorf("--batch-size must be positive")
+			}
+			var forced importer.Format
+			if format != "" {
+				var err error
+				if forced, err = importer.ParseFormat(format); err != nil {
+					return err
+				}
+			}
+			var mapping *importer.Mapping
+			if mappingFile != "" {
+				var err error
+				if mapping, err = importer.LoadMapping(mappingFile); err != nil {
+					return err
+				}
+			}
+			state, err := loadImportState(statePath)
+			if err != nil {
+				return err
+			}
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			im := &fileImporter{
+				cmd: cmd, client: c, state: state, project: args[0], table: args[1],
+				format: forced, mapping: mapping, batchSize: batchSize, restart: restart,
+			}
+			for _, file := range args[2:] {
+				if err := im.importFile(file); err != nil {
+					return fmt.Errorf("%s: %w", file, err)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "文件格式 (jsonl, csv, console)，默认按扩展名或映射文件推断")
+	cmd.Flags().StringVarP(&mappingFile, "mapping", "m", "", "列映射文件（YAML 或 JSON）")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 1000, "每批写入的日志条数")
+	cmd.Flags().StringVar(&statePath, "state", defaultImportState, "记录导入进度的文件")
+	cmd.Flags().BoolVar(&restart, "restart", false, "忽略已保存的进度，从头导入")
+	return cmd
+}
+
+// fileImporter 逐个文件读取记录并按批写入
+type fileImporter struct {
+	cmd            *cobra.Command
+	client         *client
+	state          *importState
+	project, table string
+	format         importer.Format
+	mapping        *importer.Mapping
+	batchSize      int
+	restart        bool
+}
+
+// importFile 导入一个文件，- 表示标准输入（不记录进度）
+func (im *fileImporter) importFile(file string) error {
+	var input io.Reader
+	var progress *importProgress
+	format := im.format
+	if file == "-" {
+		input, progress = im.cmd.InOrStdin(), &importProgress{Run: newRunID()}
+	} else {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+
+		key := im.project + ":" + im.table + ":" + abs
+		progress = im.state.Files[key]
+		switch {
+		case progress == nil || im.restart:
+			progress = &importProgress{Run: newRunID()}
+		case info.Size() < progress.Size:
+			fmt.Fprintf(im.cmd.ErrOrStderr(), "%s: file shrank since the last import, starting over\n", file)
+			progress = &importProgress{Run: newRunID()}
+		case progress.Done && info.Size() == progress.Size:
+			fmt.Fprintf(im.cmd.OutOrStdout(), "%s: already imported (%d accepted, %d rejected)\n", file, progress.Accepted, progress.Rejected)
+			return nil
+		case progress.Line > 0:
+			fmt.Fprintf(im.cmd.ErrOrStderr(), "%s: resuming after line %d\n", file, progress.Line)
+		}
+		progress.Size, progress.Done = info.Size(), false
+		im.state.Files[key] = progress
+		input = f
+		if format == "" && (im.mapping == nil || im.mapping.Format == "") {
+			format = importer.DetectFormat(file)
+		}
+	}
+
+	reader, err := importer.NewReader(input, format, im.mapping)
+	if err != nil {
+		return err
+	}
+	reader.SkipThrough(progress.Line)
+
+	reported := 0
+	report := func(line int, reason string) {
+		progress.Rejected++
+		if reported < maxReportedLines {
+			fmt.Fprintf(im.cmd.ErrOrStderr(), "%s:%d: %s\n", file, line, reason)
+		} else if reported == maxReportedLines {
+			fmt.Fprintf(im.cmd.ErrOrStderr(), "%s: more rejected lines are not shown\n", file)
+		}
+		reported++
+	}
+
+	var batch []*importer.Record
+	lastLine := progress.Line
+	flush := func() error {
+		if len(batch) > 0 {
+			if err := im.send(progress, batch, report); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		progress.Line = lastLine
+		if file != "-" {
+			if err := im.state.save(); err != nil {
+				return fmt.Errorf("failed to save import state: %w", err)
+			}
+		}
+		fmt.Fprintf(im.cmd.ErrOrStderr(), "%s: line %d, %d accepted, %d rejected\n", file, progress.Line, progress.Accepted, progress.Rejected)
+		return nil
+	}
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var lineErr *importer.LineError
+		if errors.As(err, &lineErr) {
+			lastLine = lineErr.Line
+			report(lineErr.Line, lineErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		lastLine = record.Line
+		batch = append(batch, record)
+		if len(batch) >= im.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	progress.Done = true
+	if err := flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(im.cmd.OutOrStdout(), "imported %d logs from %s into %s:%s (%d rejected)\n",
+		progress.Accepted, file, im.project, im.table, progress.Rejected)
+	return nil
+}
+
+// send 以 partial 模式在一个事务中写入一批记录，未通过校验的记录按行号报告。
+// Idempotency-Key 由导入标识与行号范围组成，中断后重试已提交的批次不会重复写入
+func (im *fileImporter) send(progress *importProgress, batch []*importer.Record, report func(int, string)) error {
+	entries := make([]map[string]interface{}, len(batch))
+	for i, record := range batch {
+		entries[i] = record.Data
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d-%d", progress.Run, batch[0].Line, batch[len(batch)-1].Line)))
+	header := http.Header{"Idempotency-Key": {"import-" + hex.EncodeToString(sum[:16])}}
+
+	var resp struct {
+		Results []struct {
+			Index  int    `json:"index"`
+			Status int    `json:"status"`
+			Error  string `json:"error"`
+		} `json:"results"`
+	}
+	path := logsPath(im.project, im.table) + "/batch?partial=true&atomic=true"
+	if err := im.client.doWithHeader(im.cmd.Context(), http.MethodPost, path, header, entries, &resp); err != nil {
+		return err
+	}
+	// 重放的请求没有响应体，按全部写入计数
+	if len(resp.Results) == 0 {
+		progress.Accepted += len(batch)
+		return nil
+	}
+	for _, result := range resp.Results {
+		if result.Status >= 200 && result.Status < 300 {
+			progress.Accepted++
+		} else if result.Index >= 0 && result.Index < len(batch) {
+			report(batch[result.Index].Line, result.Error)
+		}
+	}
+	return nil
+}
+
+// newRunID 生成导入标识
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
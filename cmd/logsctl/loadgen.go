@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"pkg.blksails.net/logs/internal/loadgen"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// newLoadgenCommand 按 schema 生成随机日志并以指定速率写入，报告吞吐与延迟
+func newLoadgenCommand(opts *options) *cobra.Command {
+	var schemaFile, storageType, configFile, output string
+	var values []string
+	var rate float64
+	var duration, interval time.Duration
+	var count, batchSize, concurrency int
+	var seed int64
+	cmd := &cobra.Command{
+		Use:   "loadgen PROJECT TABLE",
+		Short: "按 schema 生成随机日志进行压测，报告吞吐与延迟",
+		Long: `按 schema 生成随机日志并以 --rate 条/秒写入，结束时报告写入成功与失败的条数、实际吞吐与批次延迟的分位数。
+
+字段值符合字段类型、取值范围、长度与正则约束；未设置约束的字段按字段名生成常见形式的值，如 method、path、status、
+user_id、latency。--value 限定字段只从给定的值中选取，如 --value method=GET,POST --value status=200,500。
+schema 默认从目标读取，-f 指定本地 schema 文件。
+
+默认通过 API 的批量写入接口写入；--storage 直接写入存储后端，连接配置从 --config 的 storage 节点读取，用于评估存储本身的容量。
+压测在 --duration 或 --count 先到达时结束，中断时报告已有的结果。`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkOutput(output, "table", "json"); err != nil {
+				return err
+			}
+			if batchSize <= 0 || concurrency <= 0 {
+				return fmt.Errorf("--batch-size and --concurrency must be positive")
+			}
+			enums, err := parseLoadgenValues(values)
+			if err != nil {
+				return err
+			}
+			if !cmd.Flags().Changed("seed") {
+				seed = time.Now().UnixNano()
+			}
+			ctx := cmd.Context()
+			project, table := args[0], args[1]
+
+			var sink loadgen.Sink
+			var load func() (*models.Schema, error)
+			if storageType != "" {
+				store, err := openStorage(cmd, storageType, configFile)
+				if err != nil {
+					return err
+				}
+				defer store.Close()
+				sink = storageSink(store, project, table)
+				load = func() (*models.Schema, error) { return store.GetSchema(ctx, project, table) }
+			} else {
+				c, err := opts.client()
+				if err != nil {
+					return err
+				}
+				sink = apiSink(c, project, table)
+				load = func() (*models.Schema, error) {
+					var s models.Schema
+					return &s, c.do(ctx, http.MethodGet, schemaPath(project, table), nil, &s)
+				}
+			}
+			if schemaFile != "" {
+				load = func() (*models.Schema, error) { return findSchema(schemaFile, project, table) }
+			}
+			schema, err := load()
+			if err != nil {
+				return err
+			}
+
+			gen, err := loadgen.New(schema, loadgen.Options{Seed: seed, Values: enums})
+			if err != nil {
+				return err
+			}
+			report, err := loadgen.Run(ctx, gen, sink, loadgen.RunOptions{
+				Rate: rate, Duration: duration, Count: count, BatchSize: batchSize, Concurrency: concurrency,
+				Interval: interval,
+				OnProgress: func(r *loadgen.Report) {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d sent, %d failed, %.1f logs/s, p99 %s\n",
+						r.Elapsed.Round(time.Second), r.Sent, r.Failed, r.Throughput, r.P99)
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			if output == "json" {
+				if err := printJSON(cmd.OutOrStdout(), report); err != nil {
+					return err
+				}
+			} else {
+				if err := printTable(cmd.OutOrStdout(),
+					[]string{"sent", "failed", "batches", "elapsed", "logs/s", "p50", "p90", "p99", "max"},
+					[][]string{{
+						strconv.FormatInt(report.Sent, 10), strconv.FormatInt(report.Failed, 10), strconv.Itoa(report.Batches),
+						report.Elapsed.Round(time.Millisecond).String(), strconv.FormatFloat(report.Throughput, 'f', 1, 64),
+						report.P50.String(), report.P90.String(), report.P99.String(), report.Max.String(),
+					}}); err != nil {
+					return err
+				}
+			}
+			if report.Failed > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "last error: %s\n", report.LastError)
+			}
+			if report.Sent == 0 && report.Failed > 0 {
+				return fmt.Errorf("all %d logs failed to write", report.Failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&schemaFile, "filename", "f", "", "生成日志使用的 schema 文件，默认从目标读取")
+	cmd.Flags().Float64Var(&rate, "rate", 100, "目标速率（条/秒），0 表示不限速")
+	cmd.Flags().DurationVar(&duration, "duration", 30*time.Second, "压测时长，0 表示直到达到 --count")
+	cmd.Flags().IntVar(&count, "count", 0, "生成的日志总数，0 表示不限")
+	cmd.Flags().IntVar(&batchSize, "batch-size", loadgen.DefaultBatchSize, "每批写入的日志条数")
+	cmd.Flags().IntVar(&concurrency, "concurrency", loadgen.DefaultConcurrency, "并发写入数")
+	cmd.Flags().Int64Var(&seed, "seed", 0, "随机数种子，默认每次不同")
+	cmd.Flags().StringArrayVar(&values, "value", nil, "字段的候选值，如 method=GET,POST，可重复")
+	cmd.Flags().StringVar(&storageType, "storage", "", "直接写入的存储后端类型 (postgres, mysql, sqlite, clickhouse, file)，默认通过 API 写入")
+	cmd.Flags().StringVarP(&configFile, "config", "c", "configs/config.yaml", "--storage 使用的服务器配置文件")
+	cmd.Flags().DurationVar(&interval, "interval", 5*time.Second, "输出进度的间隔，0 表示不输出")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "输出格式 (table, json)")
+	return cmd
+}
+
+// apiSink 通过批量写入接口写入
+func apiSink(c *client, project, table string) loadgen.Sink {
+	path := logsPath(project, table) + "/batch"
+	return loadgen.SinkFunc(func(ctx context.Context, entries []*models.LogEntry) error {
+		bodies := make([]map[string]interface{}, len(entries))
+		for i, entry := range entries {
+			bodies[i] = loadgen.Body(entry)
+		}
+		return c.do(ctx, http.MethodPost, path, bodies, nil)
+	})
+}
+
+// storageSink 直接写入存储后端
+func storageSink(store storage.Storage, project, table string) loadgen.Sink {
+	return loadgen.SinkFunc(func(ctx context.Context, entries []*models.LogEntry) error {
+		return store.BatchInsertLogs(ctx, project, table, entries)
+	})
+}
+
+// findSchema 从 schema 文件中找到 project 与 table 对应的 schema
+func findSchema(filename, project, table string) (*models.Schema, error) {
+	schemas, err := readSchemaFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range schemas {
+		if s.Project == project && s.Table == table {
+			return s, s.Validate()
+		}
+	}
+	return nil, fmt.Errorf("%s does not define %s:%s", filename, project, table)
+}
+
+// parseLoadgenValues 解析 --value field=a,b,c，值按 JSON 解析，如 200、true，无法解析时作为字符串
+func parseLoadgenValues(specs []string) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{}, len(specs))
+	for _, spec := range specs {
+		name, list, ok := strings.Cut(spec, "=")
+		if !ok || name == "" || list == "" {
+			return nil, fmt.Errorf("invalid --value %q, expected field=value[,value...]", spec)
+		}
+		for _, item := range strings.Split(list, ",") {
+			value := parseValue(item)
+			if n, ok := value.(json.Number); ok {
+				if i, err := n.Int64(); err == nil {
+					value = i
+				} else {
+					value, _ = n.Float64()
+				}
+			}
+			values[name] = append(values[name], value)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// tailBatchSize tail 每次轮询获取的最新日志条数，两次轮询之间新增的日志超过该数量时会遗漏
+const tailBatchSize = 1000
+
+// hiddenColumns 表格与 tail 输出中省略的基础列
+var hiddenColumns = map[string]bool{"id": true, "project": true, "table_name": true, models.IngestTimeColumn: true}
+
+// newLogsCommand 日志写入与查询命令
+func newLogsCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "写入、查询与跟踪日志",
+	}
+	cmd.AddCommand(
+		newLogsInsertCommand(opts),
+		newLogsQueryCommand(opts),
+		newLogsTailCommand(opts),
+		newLogsDeleteCommand(opts),
+		newLogsRedactCommand(opts),
+		newLogsRollupCommand(opts),
+		newLogsPatternsCommand(opts),
+	)
+	return cmd
+}
+
+// newLogsInsertCommand 写入测试日志
+func newLogsInsertCommand(opts *options) *cobra.Command {
+	var data, file string
+	cmd := &cobra.Command{
+		Use:   "insert PROJECT TABLE (-d JSON | -f FILE)",
+		Short: "写入日志",
+		Long:  "写入日志。输入可以是单个 JSON 对象、JSON 数组或每行一个对象的 NDJSON，多条日志通过批量接口一次写入。",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var input io.Reader
+			switch {
+			case data != "" && file != "":
+				return fmt.Errorf("--data and --filename are mutually exclusive")
+			case data != "":
+				input = strings.NewReader(data)
+			case file == "-":
+				input = cmd.InOrStdin()
+			case file != "":
+				f, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				input = f
+			default:
+				return fmt.Errorf("either --data or --filename is required")
+			}
+
+			entries, err := decodeEntries(input)
+			if err != nil {
+				return err
+			}
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			project, table := args[0], args[1]
+			if len(entries) == 1 {
+				err = c.do(cmd.Context(), http.MethodPost, logsPath(project, table), entries[0], nil)
+			} else {
+				err = c.do(cmd.Context(), http.MethodPost, logsPath(project, table)+"/batch", entries, nil)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "inserted %d logs into %s:%s\n", len(entries), project, table)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&data, "data", "d", "", "日志内容（JSON）")
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "日志文件或 -（标准输入）")
+	return cmd
+}
+
+// queryFlags query 与 tail 共用的过滤参数
+type queryFlags struct {
+	filters []string
+	tags    []string
+	fields  []string
+}
+
+// register 注册过滤参数与返回的列
+func (f *queryFlags) register(cmd *cobra.Command) {
+	f.registerFilters(cmd)
+	cmd.Flags().StringSliceVar(&f.fields, "fields", nil, "返回的列，逗号分隔")
+}
+
+// registerFilters 只注册过滤参数，用于删除与修改日志
+func (f *queryFlags) registerFilters(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&f.filters, "filter", nil, "等值过滤 KEY=VALUE，VALUE 按 JSON 解析，无法解析时作为字符串；可重复")
+	cmd.Flags().StringArrayVar(&f.tags, "tag", nil, "标签过滤 KEY=VALUE，可重复")
+}
+
+// logFilter 构造删除或修改日志的过滤条件，至少需要一个 --filter 或 --tag
+func (f *queryFlags) logFilter() (models.LogFilter, error) {
+	query, err := f.query()
+	if err != nil {
+		return models.LogFilter{}, err
+	}
+	if len(query.Filter) == 0 && len(query.Tags) == 0 {
+		return models.LogFilter{}, fmt.Errorf("at least one --filter or --tag is required")
+	}
+	return models.LogFilter{Filter: query.Filter, Tags: query.Tags}, nil
+}
+
+// query 构造查询
+func (f *queryFlags) query() (*models.Query, error) {
+	query := &models.Query{Fields: f.fields}
+	for _, filter := range f.filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected KEY=VALUE", filter)
+		}
+		if query.Filter == nil {
+			query.Filter = make(map[string]interface{})
+		}
+		query.Filter[key] = parseValue(value)
+	}
+	for _, tag := range f.tags {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag %q, expected KEY=VALUE", tag)
+		}
+		if query.Tags == nil {
+			query.Tags = make(map[string]string)
+		}
+		query.Tags[key] = value
+	}
+	return query, nil
+}
+
+// newLogsQueryCommand 查询日志
+func newLogsQueryCommand(opts *options) *cobra.Command {
+	var flags queryFlags
+	var sortKeys []string
+	var limit, offset int
+	var output string
+	cmd := &cobra.Command{
+		Use:   "query PROJECT TABLE",
+		Short: "查询日志",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkOutput(output, "table", "json"); err != nil {
+				return err
+			}
+			query, err := flags.query()
+			if err != nil {
+				return err
+			}
+			query.Sort, query.Limit, query.Offset = sortKeys, limit, offset
+
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			entries, err := searchLogs(cmd.Context(), c, args[0], args[1], query)
+			if err != nil {
+				return err
+			}
+			if output == "json" {
+				return printJSON(cmd.OutOrStdout(), entries)
+			}
+
+			columns := flags.fields
+			if len(columns) == 0 {
+				columns = entryColumns(entries)
+			}
+			rows := make([][]string, len(entries))
+			for i, entry := range entries {
+				rows[i] = make([]string, len(columns))
+				for j, column := range columns {
+					rows[i][j] = formatValue(entry[column])
+				}
+			}
+			return printTable(cmd.OutOrStdout(), columns, rows)
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringSliceVar(&sortKeys, "sort", []string{"-timestamp"}, "排序列，逗号分隔，以 - 开头表示降序")
+	cmd.Flags().IntVar(&limit, "limit", 100, "最多返回的条数")
+	cmd.Flags().IntVar(&offset, "offset", 0, "跳过的条数")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "输出格式 (table, json)")
+	return cmd
+}
+
+// newLogsDeleteCommand 删除匹配过滤条件的日志
+func newLogsDeleteCommand(opts *options) *cobra.Command {
+	var flags queryFlags
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "delete PROJECT TABLE (--filter KEY=VALUE | --tag KEY=VALUE)...",
+		Short: "删除匹配过滤条件的日志，--dry-run 时只统计条数",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := flags.logFilter()
+			if err != nil {
+				return err
+			}
+			return mutateLogs(cmd, opts, http.MethodDelete, args, &models.LogDelete{LogFilter: filter, DryRun: dryRun}, "deleted")
+		},
+	}
+	flags.registerFilters(cmd)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "只统计匹配的条数，不删除")
+	return cmd
+}
+
+// newLogsRedactCommand 修改匹配过滤条件的日志字段，用于清除敏感值
+func newLogsRedactCommand(opts *options) *cobra.Command {
+	var flags queryFlags
+	var sets []string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "redact PROJECT TABLE --set FIELD[=VALUE]... (--filter KEY=VALUE | --tag KEY=VALUE)...",
+		Short: "清除或替换匹配日志的字段值，--dry-run 时只统计条数",
+		Long:  "清除或替换匹配日志的字段值。--set FIELD 将字段置为 null，--set FIELD=VALUE 替换为新值，VALUE 按 JSON 解析，无法解析时作为字符串。",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := flags.logFilter()
+			if err != nil {
+				return err
+			}
+			if len(sets) == 0 {
+				return fmt.Errorf("at least one --set is required")
+			}
+			update := &models.LogUpdate{LogFilter: filter, Set: make(map[string]interface{}), DryRun: dryRun}
+			for _, set := range sets {
+				key, value, ok := strings.Cut(set, "=")
+				if key == "" {
+					return fmt.Errorf("invalid set %q, expected FIELD or FIELD=VALUE", set)
+				}
+				update.Set[key] = nil
+				if ok {
+					update.Set[key] = parseValue(value)
+				}
+			}
+			return mutateLogs(cmd, opts, http.MethodPatch, args, update, "updated")
+		},
+	}
+	flags.registerFilters(cmd)
+	cmd.Flags().StringArrayVar(&sets, "set", nil, "要清除的字段 FIELD 或替换值 FIELD=VALUE，可重复")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "只统计匹配的条数，不修改")
+	return cmd
+}
+
+// mutateLogs 发送删除或修改日志的请求并输出影响的条数
+func mutateLogs(cmd *cobra.Command, opts *options, method string, args []string, body interface{}, action string) error {
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Count  int64 `json:"count"`
+		DryRun bool  `json:"dry_run"`
+	}
+	if err := c.do(cmd.Context(), method, logsPath(args[0], args[1]), body, &resp); err != nil {
+		return err
+	}
+	if resp.DryRun {
+		fmt.Fprintf(cmd.OutOrStdout(), "%d entries match\n", resp.Count)
+		return nil
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%d entries %s\n", resp.Count, action)
+	return nil
+}
+
+// newLogsRollupCommand 立即汇总并删除超过 rollup_after 的原始日志
+func newLogsRollupCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollup PROJECT TABLE",
+		Short: "立即将超过 rollup_after 的原始日志汇总到 rollup 表并删除",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			var resp struct {
+				Count  int64     `json:"count"`
+				Before time.Time `json:"before"`
+			}
+			if err := c.do(cmd.Context(), http.MethodPost, logsPath(args[0], args[1])+"/rollup", nil, &resp); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%d entries before %s rolled up\n", resp.Count, resp.Before.Format(time.RFC3339))
+			return nil
+		},
+	}
+}
+
+// newLogsPatternsCommand 将时间范围内的日志消息聚类为模板，按出现次数倒序输出
+func newLogsPatternsCommand(opts *options) *cobra.Command {
+	var from, to, level, output string
+	var limit, sample int
+	cmd := &cobra.Command{
+		Use:   "patterns PROJECT TABLE",
+		Short: "将日志消息聚类为模板，按出现次数倒序输出",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkOutput(output, "table", "json"); err != nil {
+				return err
+			}
+			params := url.Values{}
+			for key, value := range map[string]string{"from": from, "to": to, "level": level} {
+				if value != "" {
+					params.Set(key, value)
+				}
+			}
+			params.Set("limit", fmt.Sprint(limit))
+			params.Set("sample", fmt.Sprint(sample))
+
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			var resp struct {
+				Scanned   int  `json:"scanned"`
+				Truncated bool `json:"truncated"`
+				Patterns  []struct {
+					Template string  `json:"template"`
+					Count    int64   `json:"count"`
+					Ratio    float64 `json:"ratio"`
+				} `json:"patterns"`
+			}
+			if err := c.do(cmd.Context(), http.MethodGet, logsPath(args[0], args[1])+"/patterns?"+params.Encode(), nil, &resp); err != nil {
+				return err
+			}
+			if output == "json" {
+				return printJSON(cmd.OutOrStdout(), resp.Patterns)
+			}
+
+			rows := make([][]string, len(resp.Patterns))
+			for i, pattern := range resp.Patterns {
+				rows[i] = []string{fmt.Sprint(pattern.Count), fmt.Sprintf("%.1f%%", pattern.Ratio*100), pattern.Template}
+			}
+			if err := printTable(cmd.OutOrStdout(), []string{"COUNT", "RATIO", "TEMPLATE"}, rows); err != nil {
+				return err
+			}
+			if resp.Truncated {
+				fmt.Fprintf(cmd.ErrOrStderr(), "only the latest %d entries were clustered, use --sample to scan more\n", resp.Scanned)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "起始时间 (RFC3339)")
+	cmd.Flags().StringVar(&to, "to", "", "结束时间 (RFC3339)，不包含")
+	cmd.Flags().StringVar(&level, "level", "", "只统计该级别的日志")
+	cmd.Flags().IntVar(&limit, "limit", 20, "最多输出的模板数")
+	cmd.Flags().IntVar(&sample, "sample", 10000, "最多聚类的最近日志条数")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "输出格式 (table, json)")
+	return cmd
+}
+
+// newLogsTailCommand 输出最新的日志，--follow 时持续轮询新日志
+func newLogsTailCommand(opts *options) *cobra.Command {
+	var flags queryFlags
+	var lines int
+	var follow bool
+	var interval time.Duration
+	var output string
+	cmd := &cobra.Command{
+		Use:   "tail PROJECT TABLE",
+		Short: "输出最新的日志，-f 时持续输出新日志",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkOutput(output, "text", "json"); err != nil {
+				return err
+			}
+			if lines < 0 || interval <= 0 {
+				return fmt.Errorf("--lines must not be negative and --interval must be positive")
+			}
+			query, err := flags.query()
+			if err != nil {
+				return err
+			}
+			// 按 id 去重，同一时间戳的日志顺序保持稳定
+			query.Sort = []string{"-timestamp", "-id"}
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			emit := func(entry map[string]interface{}) error {
+				if output == "json" {
+					data, err := json.Marshal(entry)
+					if err != nil {
+						return err
+					}
+					_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s\n", data)
+					return err
+				}
+				_, err := fmt.Fprintln(cmd.OutOrStdout(), formatLine(entry))
+				return err
+			}
+
+			t := &tailer{client: c, project: args[0], table: args[1], query: query}
+			if err := t.poll(cmd.Context(), lines, emit); err != nil || !follow {
+				return err
+			}
+			ctx := cmd.Context()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+					if err := t.poll(ctx, tailBatchSize, emit); err != nil {
+						if ctx.Err() != nil {
+							return nil
+						}
+						return err
+					}
+				}
+			}
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().IntVarP(&lines, "lines", "n", 10, "首先输出的最新日志条数")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "持续输出新日志")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "--follow 时的轮询间隔")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "输出格式 (text, json)")
+	return cmd
+}
+
+// tailer 轮询最新日志，只输出上次轮询之后出现的日志
+type tailer struct {
+	client  *client
+	project string
+	table   string
+	query   *models.Query
+	seen    map[string]bool // 上次轮询返回的日志 id
+}
+
+// poll 获取最新的 limit 条日志，按时间顺序输出其中未见过的部分。
+// 结果按时间倒序排列，遇到上次见过的日志即说明更早的日志已经输出过
+func (t *tailer) poll(ctx context.Context, limit int, emit func(map[string]interface{}) error) error {
+	query := *t.query
+	// 首次轮询 limit 为 0 时仍需获取一条日志作为起点
+	query.Limit = max(limit, 1)
+	entries, err := searchLogs(ctx, t.client, t.project, t.table, &query)
+	if err != nil {
+		return err
+	}
+
+	first := t.seen == nil
+	var fresh []map[string]interface{}
+	for _, entry := range entries {
+		if t.seen[formatValue(entry["id"])] {
+			break
+		}
+		fresh = append(fresh, entry)
+	}
+	if first && len(fresh) > limit {
+		fresh = fresh[:limit]
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		seen[formatValue(entry["id"])] = true
+	}
+	t.seen = seen
+
+	for i := len(fresh) - 1; i >= 0; i-- {
+		if err := emit(fresh[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchLogs 调用日志检索接口
+func searchLogs(ctx context.Context, c *client, project, table string, query *models.Query) ([]map[string]interface{}, error) {
+	var resp struct {
+		Entries []map[string]interface{} `json:"entries"`
+	}
+	if err := c.do(ctx, http.MethodPost, logsPath(project, table)+"/search", query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+// decodeEntries 解析单个 JSON 对象、JSON 数组或 NDJSON，保留数字的原始表示
+func decodeEntries(r io.Reader) ([]map[string]interface{}, error) {
+	reader := bufio.NewReader(r)
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	// 跳过空白后判断是否为数组
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return nil, fmt.Errorf("no log entries in input")
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			if b[0] == '[' {
+				var entries []map[string]interface{}
+				if err := decoder.Decode(&entries); err != nil {
+					return nil, fmt.Errorf("invalid log entries: %w", err)
+				}
+				if len(entries) == 0 {
+					return nil, fmt.Errorf("no log entries in input")
+				}
+				return entries, nil
+			}
+			break
+		}
+		reader.ReadByte()
+	}
+
+	var entries []map[string]interface{}
+	for {
+		var entry map[string]interface{}
+		err := decoder.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid log entry %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseValue 将过滤值按 JSON 解析，如 500、true；无法解析时作为字符串
+func parseValue(value string) interface{} {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return value
+	}
+	return v
+}
+
+// entryColumns 返回查询结果的列：timestamp、level、message 在前，其余按名称排序
+func entryColumns(entries []map[string]interface{}) []string {
+	leading := []string{"timestamp", "level", "message"}
+	names := make(map[string]bool)
+	for _, entry := range entries {
+		for name := range entry {
+			names[name] = true
+		}
+	}
+
+	var columns, rest []string
+	for _, name := range leading {
+		if names[name] {
+			columns = append(columns, name)
+		}
+		delete(names, name)
+	}
+	for name := range names {
+		if !hiddenColumns[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(columns, rest...)
+}
+
+// formatLine 将一条日志格式化为 tail 输出的一行：时间 级别 消息 key=value...，缺失的列省略
+func formatLine(entry map[string]interface{}) string {
+	var parts []string
+	for _, column := range entryColumns([]map[string]interface{}{entry}) {
+		value := formatValue(entry[column])
+		switch column {
+		case "level":
+			parts = append(parts, strings.ToUpper(value))
+		case "timestamp", "message":
+			parts = append(parts, value)
+		default:
+			parts = append(parts, column+"="+value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,95 @@
+// logsctl 日志服务的命令行管理工具：管理 schema、写入测试日志、导入历史日志、在存储后端之间迁移、压测写入、查询与跟踪日志、检查服务状态
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultServer 未设置 --server 与 LOGSCTL_SERVER 时访问的地址
+const defaultServer = "http://localhost:8080"
+
+// options 全局选项
+type options struct {
+	server  string
+	apiKey  string
+	timeout time.Duration
+}
+
+// client 根据全局选项创建 API 客户端
+func (o *options) client() (*client, error) {
+	return newClient(o.server, o.apiKey, o.timeout)
+}
+
+func main() {
+	// 中断时取消正在执行的请求，logs tail --follow 正常退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := newRootCommand(os.Stdout, os.Stderr).ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// newRootCommand 创建根命令，输出写入 stdout 与 stderr
+func newRootCommand(stdout, stderr io.Writer) *cobra.Command {
+	opts := &options{}
+	cmd := &cobra.Command{
+		Use:          "logsctl",
+		Short:        "日志服务命令行管理工具",
+		SilenceUsage: true,
+	}
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+
+	server := os.Getenv("LOGSCTL_SERVER")
+	if server == "" {
+		server = defaultServer
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVarP(&opts.server, "server", "s", server, "服务器地址，默认读取 LOGSCTL_SERVER")
+	flags.StringVar(&opts.apiKey, "api-key", os.Getenv("LOGSCTL_API_KEY"), "通过 X-API-Key 请求头发送的 API Key，默认读取 LOGSCTL_API_KEY")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "单个请求的超时时间")
+
+	cmd.AddCommand(
+		newSchemaCommand(opts),
+		newLogsCommand(opts),
+		newImportCommand(opts),
+		newMigrateCommand(),
+		newLoadgenCommand(opts),
+		newHealthCommand(opts),
+	)
+	return cmd
+}
+
+// newHealthCommand 检查服务器与存储是否可用
+func newHealthCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "health",
+		Short: "检查服务器与存储后端是否可用",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			var resp struct {
+				Status  string `json:"status"`
+				Storage string `json:"storage"`
+			}
+			if err := c.do(cmd.Context(), http.MethodGet, "/healthz", nil, &resp); err != nil {
+				return fmt.Errorf("server %s is unhealthy: %w", opts.server, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %s (storage: %s)\n", opts.server, resp.Status, resp.Storage)
+			return nil
+		},
+	}
+}
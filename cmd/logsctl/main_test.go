@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/api"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// newTestServer 启动使用 SQLite 存储的 API 服务器
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store, err := storage.New(context.Background(), storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	ts := httptest.NewServer(api.NewServer(store, &api.Config{StorageType: "sqlite"}).Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// execute 执行 logsctl 命令，返回标准输出与错误
+func execute(t *testing.T, server string, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	cmd := newRootCommand(&stdout, &stderr)
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetArgs(append([]string{"--server", server}, args...))
+	err := cmd.Execute()
+	return stdout.String(), err
+}
+
+func TestSchemaCommands(t *testing.T) {
+	ts := newTestServer(t)
+	schemaYAML := `project: app
+table: requests
+fields:
+  - name: path
+    type: string
+    required: true
+  - name: status
+    type: int
+`
+
+	out, err := execute(t, ts.URL, schemaYAML, "schema", "apply", "-f", "-", "--dry-run")
+	require.NoError(t, err)
+	assert.Equal(t, "schema app:requests created (dry run)\n", out)
+	_, err = execute(t, ts.URL, "", "schema", "get", "app", "requests")
+	assert.ErrorContains(t, err, "not_found")
+
+	out, err = execute(t, ts.URL, schemaYAML, "schema", "apply", "-f", "-")
+	require.NoError(t, err)
+	assert.Equal(t, "schema app:requests created\n", out)
+	out, err = execute(t, ts.URL, schemaYAML, "schema", "apply", "-f", "-")
+	require.NoError(t, err)
+	assert.Equal(t, "schema app:requests unchanged\n", out)
+
+	dir := t.TempDir()
+	updated := schemaYAML + "  - name: latency\n    type: float\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "requests.yaml"), []byte(updated), 0644))
+	out, err = execute(t, ts.URL, "", "schema", "apply", "-f", dir)
+	require.NoError(t, err)
+	assert.Equal(t, "schema app:requests configured\n", out)
+
+	// 本地校验失败时不修改服务器
+	_, err = execute(t, ts.URL, "project: app\ntable: bad\nfields: []\n", "schema", "apply", "-f", "-")
+	assert.ErrorContains(t, err, "at least one field is required")
+
+	out, err = execute(t, ts.URL, "", "schema", "get", "app", "requests", "-o", "json")
+	require.NoError(t, err)
+	var schema struct {
+		Fields []struct {
+			Name string `json:"name"`
+		} `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &schema))
+	assert.Len(t, schema.Fields, 3)
+
+	out, err = execute(t, ts.URL, "", "schema", "get", "app", "requests", "-o", "jsonschema")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"x-table": "requests"`)
+
+	out, err = execute(t, ts.URL, "", "schema", "list")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `^PROJECT\s+TABLE\s+FIELDS\s+UPDATED$`, lines[0])
+	assert.Regexp(t, `^app\s+requests\s+3\s+`, lines[1])
+	out, err = execute(t, ts.URL, "", "schema", "list", "-p", "other")
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+
+	out, err = execute(t, ts.URL, "", "schema", "delete", "app", "requests")
+	require.NoError(t, err)
+	assert.Equal(t, "schema app:requests deleted\n", out)
+	_, err = execute(t, ts.URL, "", "schema", "delete", "app", "requests")
+	assert.Error(t, err)
+
+	out, err = execute(t, ts.URL, "", "schema", "restore", "app", "requests")
+	require.NoError(t, err)
+	assert.Equal(t, "schema app:requests restored\n", out)
+	out, err = execute(t, ts.URL, "", "schema", "rename", "app", "requests", "events")
+	require.NoError(t, err)
+	assert.Equal(t, "schema app:requests renamed to app:events\n", out)
+	out, err = execute(t, ts.URL, "", "schema", "rename-project", "app", "web")
+	require.NoError(t, err)
+	assert.Equal(t, "project app renamed to web (1 schemas)\n", out)
+	_, err = execute(t, ts.URL, "", "schema", "rename", "app", "events", "other")
+	assert.ErrorContains(t, err, "not_found")
+	_, err = execute(t, ts.URL, "", "schema", "rename", "web", "events", "requests", "-p", "app")
+	require.NoError(t, err)
+	_, err = execute(t, ts.URL, "", "schema", "delete", "app", "requests", "--hard")
+	require.NoError(t, err)
+	_, err = execute(t, ts.URL, "", "schema", "restore", "app", "requests")
+	assert.ErrorContains(t, err, "not_found")
+
+	_, err = execute(t, ts.URL, "", "schema", "list", "-o", "csv")
+	assert.ErrorContains(t, err, "invalid output format")
+}
+
+func TestSchemaValidateCommand(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("project: app\ntable: logs\nfields:\n  - name: order\n    type: string\n"), 0644))
+
+	out, err := execute(t, "http://localhost:0", "", "schema", "validate", dir, "--storage", "mysql")
+	require.NoError(t, err)
+	assert.Contains(t, out, "1 files, 0 errors, 1 warnings")
+	_, err = execute(t, "http://localhost:0", "", "schema", "validate", dir, "--strict")
+	assert.ErrorContains(t, err, "schema validation failed")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("project: app\ntable: logs\nfields:\n  - name: level\n    type: string\n"), 0644))
+	_, err = execute(t, "http://localhost:0", "", "schema", "validate", dir)
+	assert.ErrorContains(t, err, "1 errors")
+}
+
+func TestLogsCommands(t *testing.T) {
+	ts := newTestServer(t)
+	_, err := execute(t, ts.URL, `project: app
+table: requests
+fields:
+  - name: event
+    type: string
+  - name: path
+    type: string
+  - name: status
+    type: int
+`, "schema", "apply", "-f", "-")
+	require.NoError(t, err)
+
+	out, err := execute(t, ts.URL, "", "logs", "insert", "app", "requests",
+		"-d", `{"level": "info", "message": "hit", "event": "first", "path": "/a", "status": 200, "timestamp": "2024-01-01T00:00:00Z"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "inserted 1 logs into app:requests\n", out)
+
+	ndjson := `{"level": "error", "message": "hit", "event": "second", "path": "/b", "status": 500, "timestamp": "2024-01-01T00:00:01Z"}
+{"level": "error", "message": "hit", "event": "third", "path": "/c", "status": 500, "timestamp": "2024-01-01T00:00:02Z"}
+`
+	out, err = execute(t, ts.URL, ndjson, "logs", "insert", "app", "requests", "-f", "-")
+	require.NoError(t, err)
+	assert.Equal(t, "inserted 2 logs into app:requests\n", out)
+	_, err = execute(t, ts.URL, "", "logs", "insert", "app", "requests")
+	assert.ErrorContains(t, err, "either --data or --filename is required")
+
+	out, err = execute(t, ts.URL, "", "logs", "query", "app", "requests", "--filter", "status=500", "--fields", "event,status", "--sort", "-id")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^EVENT\s+STATUS$`, lines[0])
+	assert.Regexp(t, `^third\s+500$`, lines[1])
+	assert.Regexp(t, `^second\s+500$`, lines[2])
+
+	out, err = execute(t, ts.URL, "", "logs", "query", "app", "requests", "--sort", "id", "--limit", "1", "-o", "json")
+	require.NoError(t, err)
+	var entries []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "first", entries[0]["event"])
+
+	_, err = execute(t, ts.URL, "", "logs", "query", "app", "requests", "--filter", "missing=1")
+	assert.ErrorContains(t, err, "validation_failed")
+
+	out, err = execute(t, ts.URL, "", "logs", "tail", "app", "requests", "-n", "2")
+	require.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	// SQLite 只保存日志时间与 schema 中声明的字段
+	assert.Equal(t, "2024-01-01T00:00:01Z event=second path=/b status=500", lines[0])
+	assert.Equal(t, "2024-01-01T00:00:02Z event=third path=/c status=500", lines[1])
+
+	out, err = execute(t, ts.URL, "", "logs", "redact", "app", "requests", "--filter", "status=500", "--set", "path", "--dry-run")
+	require.NoError(t, err)
+	assert.Equal(t, "2 entries match\n", out)
+	out, err = execute(t, ts.URL, "", "logs", "redact", "app", "requests", "--filter", "status=500", "--set", "path", "--set", "event=hidden")
+	require.NoError(t, err)
+	assert.Equal(t, "2 entries updated\n", out)
+	out, err = execute(t, ts.URL, "", "logs", "delete", "app", "requests", "--filter", "event=hidden")
+	require.NoError(t, err)
+	assert.Equal(t, "2 entries deleted\n", out)
+	_, err = execute(t, ts.URL, "", "logs", "delete", "app", "requests")
+	assert.ErrorContains(t, err, "at least one --filter or --tag is required")
+	_, err = execute(t, ts.URL, "", "logs", "rollup", "app", "requests")
+	assert.ErrorContains(t, err, "has no rollups")
+}
+
+func TestLogsPatternsCommand(t *testing.T) {
+	ts := newTestServer(t)
+	_, err := execute(t, ts.URL, "project: app\ntable: events\nfields:\n  - name: message\n    type: string\n", "schema", "apply", "-f", "-")
+	require.NoError(t, err)
+	_, err = execute(t, ts.URL, "", "logs", "insert", "app", "events", "-d", `[
+		{"level": "info", "message": "job 1 done", "timestamp": "2024-01-01T00:00:00Z"},
+		{"level": "info", "message": "job 2 done", "timestamp": "2024-01-01T00:00:01Z"},
+		{"level": "warn", "message": "queue is full", "timestamp": "2024-01-01T00:00:02Z"}
+	]`)
+	require.NoError(t, err)
+
+	out, err := execute(t, ts.URL, "", "logs", "patterns", "app", "events")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^COUNT\s+RATIO\s+TEMPLATE$`, lines[0])
+	assert.Regexp(t, `^2\s+66.7%\s+job <\*> done$`, lines[1])
+	assert.Regexp(t, `^1\s+33.3%\s+queue is full$`, lines[2])
+
+	_, err = execute(t, ts.URL, "", "logs", "patterns", "app", "events", "--from", "yesterday")
+	assert.ErrorContains(t, err, "invalid from")
+}
+
+func TestTailerPoll(t *testing.T) {
+	ts := newTestServer(t)
+	_, err := execute(t, ts.URL, "project: app\ntable: events\nfields:\n  - name: event\n    type: string\n", "schema", "apply", "-f", "-")
+	require.NoError(t, err)
+	insert := func(event, timestamp string) {
+		_, err := execute(t, ts.URL, "", "logs", "insert", "app", "events",
+			"-d", `{"level": "info", "message": "tail", "event": "`+event+`", "timestamp": "`+timestamp+`"}`)
+		require.NoError(t, err)
+	}
+	insert("a", "2024-01-01T00:00:00Z")
+	insert("b", "2024-01-01T00:00:01Z")
+
+	c, err := newClient(ts.URL, "", 0)
+	require.NoError(t, err)
+	tl := &tailer{client: c, project: "app", table: "events", query: &models.Query{Sort: []string{"-timestamp", "-id"}}}
+	var got []string
+	emit := func(entry map[string]interface{}) error {
+		got = append(got, entry["event"].(string))
+		return nil
+	}
+
+	require.NoError(t, tl.poll(context.Background(), 1, emit))
+	assert.Equal(t, []string{"b"}, got)
+	require.NoError(t, tl.poll(context.Background(), tailBatchSize, emit))
+	assert.Equal(t, []string{"b"}, got)
+
+	insert("c", "2024-01-01T00:00:02Z")
+	insert("d", "2024-01-01T00:00:03Z")
+	require.NoError(t, tl.poll(context.Background(), tailBatchSize, emit))
+	assert.Equal(t, []string{"b", "c", "d"}, got)
+}
+
+func TestParseValue(t *testing.T) {
+	assert.Equal(t, json.Number("500"), parseValue("500"))
+	assert.Equal(t, true, parseValue("true"))
+	assert.Equal(t, "500", parseValue(`"500"`))
+	assert.Equal(t, "/api/v1", parseValue("/api/v1"))
+	assert.Equal(t, "1 2", parseValue("1 2"))
+}
+
+func TestImportCommand(t *testing.T) {
+	ts := newTestServer(t)
+	_, err := execute(t, ts.URL, "project: app\ntable: requests\nfields:\n  - name: status\n    type: int\n", "schema", "apply", "-f", "-")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	mapping := filepath.Join(dir, "mapping.yaml")
+	require.NoError(t, os.WriteFile(mapping, []byte("fields: {time: timestamp, text: message}\ndefaults: {level: info}\n"), 0644))
+	var csv strings.Builder
+	csv.WriteString("time,text,status\n")
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(&csv, "2024-01-02T03:04:0%dZ,m%d,200\n", i, i)
+	}
+	csv.WriteString("2024-01-02T03:04:05Z,bad,abc\n")
+	file := filepath.Join(dir, "old.csv")
+	require.NoError(t, os.WriteFile(file, []byte(csv.String()), 0644))
+	state := filepath.Join(dir, "state.json")
+
+	count := func() int {
+		out, err := execute(t, ts.URL, "", "logs", "query", "app", "requests", "-o", "json")
+		require.NoError(t, err)
+		var entries []map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(out), &entries))
+		return len(entries)
+	}
+
+	// 模拟上次导入在第 3 行之后中断
+	require.NoError(t, os.WriteFile(state, []byte(`{"files":{"app:requests:`+file+`":{"run":"r1","size":1,"line":3,"accepted":2}}}`), 0644))
+	args := []string{"import", "app", "requests", file, "-m", mapping, "--state", state, "--batch-size", "2"}
+	out, err := execute(t, ts.URL, "", args...)
+	require.NoError(t, err)
+	assert.Equal(t, "imported 5 logs from "+file+" into app:requests (1 rejected)\n", out)
+	assert.Equal(t, 3, count())
+
+	out, err = execute(t, ts.URL, "", args...)
+	require.NoError(t, err)
+	assert.Equal(t, file+": already imported (5 accepted, 1 rejected)\n", out)
+	assert.Equal(t, 3, count())
+
+	out, err = execute(t, ts.URL, "", append(args, "--restart")...)
+	require.NoError(t, err)
+	assert.Equal(t, "imported 5 logs from "+file+" into app:requests (1 rejected)\n", out)
+	assert.Equal(t, 8, count())
+
+	_, err = execute(t, ts.URL, "", "import", "app", "requests", file, "--format", "xml")
+	assert.ErrorContains(t, err, "unsupported import format")
+}
+
+func TestMigrateCommand(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeConfig := func(name, path string) string {
+		file := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(file, []byte("storage:\n  sqlite:\n    path: "+path+"\n"), 0644))
+		return file
+	}
+	fromConfig := writeConfig("from.yaml", filepath.Join(dir, "from.db"))
+	toConfig := writeConfig("to.yaml", filepath.Join(dir, "to.db"))
+
+	source, err := storage.New(ctx, storage.Config{Type: "sqlite", SQLite: storage.SQLiteConfig{Path: filepath.Join(dir, "from.db")}})
+	require.NoError(t, err)
+	require.NoError(t, source.CreateSchema(ctx, &models.Schema{
+		Project: "app", Table: "requests",
+		Fields: []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+	}))
+	var entries []*models.LogEntry
+	for i := 0; i < 5; i++ {
+		entries = append(entries, &models.LogEntry{
+			Project: "app", Table: "requests", Level: "info", Message: "ok",
+			Timestamp: time.Date(2024, 1, 2, 3, 4, i, 0, time.UTC),
+			Fields:    map[string]interface{}{"status": 200 + i},
+		})
+	}
+	require.NoError(t, source.BatchInsertLogs(ctx, "app", "requests", entries))
+	require.NoError(t, source.Close())
+
+	state := filepath.Join(dir, "migrate.json")
+	args := []string{"migrate", "--from", "sqlite", "--to", "sqlite", "--from-config", fromConfig, "--to-config", toConfig,
+		"--batch-size", "2", "--state", state}
+	out, err := execute(t, "", "", args...)
+	require.NoError(t, err, out)
+	assert.Regexp(t, `app\s+requests\s+5\s+5\s+5\s+ok`, out)
+
+	data, err := os.ReadFile(state)
+	require.NoError(t, err)
+	var saved struct {
+		Source string `json:"source"`
+		Tables map[string]struct {
+			Copied int  `json:"copied"`
+			Done   bool `json:"done"`
+		} `json:"tables"`
+	}
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.Equal(t, "sqlite", saved.Source)
+	assert.Equal(t, 5, saved.Tables["app:requests"].Copied)
+	assert.True(t, saved.Tables["app:requests"].Done)
+
+	// 已完成的表不再复制，计数仍然校验
+	out, err = execute(t, "", "", args...)
+	require.NoError(t, err, out)
+	assert.Regexp(t, `app\s+requests\s+5\s+5\s+5\s+ok`, out)
+
+	_, err = execute(t, "", "", "migrate", "--from", "sqlite", "--to", "file", "--config", fromConfig, "--state", state)
+	assert.ErrorContains(t, err, "records a migration from sqlite to sqlite")
+	_, err = execute(t, "", "", "migrate", "--from", "sqlite")
+	assert.ErrorContains(t, err, "--from and --to are required")
+}
+
+func TestLoadgenCommand(t *testing.T) {
+	ts := newTestServer(t)
+	schemaYAML := `project: app
+table: requests
+fields:
+  - name: method
+    type: string
+    required: true
+  - name: code
+    type: string
+    pattern: "^[A-Z]{2}\\d{3}$"
+  - name: status
+    type: int
+  - name: latency
+    type: float
+    min_value: 0
+`
+	_, err := execute(t, ts.URL, schemaYAML, "schema", "apply", "-f", "-")
+	require.NoError(t, err)
+
+	out, err := execute(t, ts.URL, "", "loadgen", "app", "requests", "--count", "120", "--rate", "0",
+		"--batch-size", "50", "--value", "method=GET,HEAD", "--seed", "1", "-o", "json")
+	require.NoError(t, err, out)
+	var report struct {
+		Sent    int `json:"sent"`
+		Failed  int `json:"failed"`
+		Batches int `json:"batches"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	assert.Equal(t, 120, report.Sent)
+	assert.Zero(t, report.Failed)
+	assert.Equal(t, 3, report.Batches)
+
+	out, err = execute(t, ts.URL, "", "logs", "query", "app", "requests", "--limit", "500", "-o", "json")
+	require.NoError(t, err)
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rows))
+	require.Len(t, rows, 120)
+	for _, row := range rows {
+		assert.Contains(t, []interface{}{"GET", "HEAD"}, row["method"])
+	}
+
+	// 直接写入存储，schema 来自本地文件
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte("storage:\n  sqlite:\n    path: "+filepath.Join(dir, "logs.db")+"\n"), 0644))
+	schemaFile := filepath.Join(dir, "requests.yaml")
+	require.NoError(t, os.WriteFile(schemaFile, []byte(schemaYAML), 0644))
+	store, err := storage.New(context.Background(), storage.Config{Type: "sqlite", SQLite: storage.SQLiteConfig{Path: filepath.Join(dir, "logs.db")}})
+	require.NoError(t, err)
+	schema, err := findSchema(schemaFile, "app", "requests")
+	require.NoError(t, err)
+	require.NoError(t, store.CreateSchema(context.Background(), schema))
+	require.NoError(t, store.Close())
+
+	out, err = execute(t, "", "", "loadgen", "app", "requests", "--storage", "sqlite", "-c", config, "-f", schemaFile,
+		"--rate", "200", "--duration", "300ms")
+	require.NoError(t, err, out)
+	assert.Regexp(t, `(?m)^SENT\s+FAILED\s+BATCHES`, out)
+
+	_, err = execute(t, ts.URL, "", "loadgen", "app", "missing", "--count", "1")
+	assert.ErrorContains(t, err, "not_found")
+	_, err = execute(t, ts.URL, "", "loadgen", "app", "requests", "--count", "1", "--value", "status=oops")
+	assert.ErrorContains(t, err, "invalid value oops for field status")
+	_, err = execute(t, ts.URL, "", "loadgen", "app", "requests", "--value", "status")
+	assert.ErrorContains(t, err, "expected field=value")
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"pkg.blksails.net/logs/internal/backup"
+	"pkg.blksails.net/logs/internal/config"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// defaultMigrateState 记录迁移进度的默认文件
+const defaultMigrateState = ".logsctl-migrate.json"
+
+// migrateState 迁移进度文件，记录源与目标后端以及每个表的读取位置
+type migrateState struct {
+	path    string
+	Source  string                    `json:"source"`
+	Target  string                    `json:"target"`
+	Cursors map[string]*backup.Cursor `json:"tables"`
+}
+
+// loadMigrateState 读取进度文件，文件不存在或 restart 时返回空的进度。
+// 进度属于另一对后端时返回错误，避免把一次迁移的位置用到另一次
+func loadMigrateState(path, source, target string, restart bool) (*migrateState, error) {
+	state := &migrateState{path: path, Source: source, Target: target, Cursors: make(map[string]*backup.Cursor)}
+	if restart {
+		return state, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	saved := &migrateState{path: path}
+	if err := json.Unmarshal(data, saved); err != nil {
+		return nil, fmt.Errorf("invalid migrate state %s: %w", path, err)
+	}
+	if saved.Source != source || saved.Target != target {
+		return nil, fmt.Errorf("%s records a migration from %s to %s; use --restart or another --state file", path, saved.Source, saved.Target)
+	}
+	if saved.Cursors == nil {
+		saved.Cursors = make(map[string]*backup.Cursor)
+	}
+	return saved, nil
+}
+
+// save 先写入临时文件再替换，中断时不会留下不完整的进度文件
+func (s *migrateState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// newMigrateCommand 在两个存储后端之间复制 schema 与日志
+func newMigrateCommand() *cobra.Command {
+	var from, to, configFile, fromConfig, toConfig, statePath string
+	var projects []string
+	var batchSize int
+	var restart bool
+	cmd := &cobra.Command{
+		Use:   "migrate --from TYPE --to TYPE",
+		Short: "在两个存储后端之间复制 schema 与日志",
+		Long: `在两个存储后端之间复制 schema 与日志，如 logsctl migrate --from postgres --to clickhouse。
+
+直接连接两端的存储，连接配置从服务器的配置文件的 storage 节点读取：--config 同时用于两端，
+--from-config、--to-config 分别指定两端的配置文件。目标存储中不存在的表按 schema 创建，字段类型由目标后端的建表规则转换；
+已存在的表向其追加。日志按 timestamp 顺序分批复制，保留 id、时间与标签。
+
+每批写入后进度保存到 --state 文件，中断后再次运行相同的命令从上次的位置继续；--restart 从头复制。
+每个表复制完成后比较两端的行数，任一表不一致时命令以非零状态退出。迁移期间源存储新写入的日志可能不会被复制。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" || to == "" {
+				return fmt.Errorf("--from and --to are required")
+			}
+			if batchSize <= 0 {
+				return fmt.Errorf("--batch-size must be positive")
+			}
+			if fromConfig == "" {
+				fromConfig = configFile
+			}
+			if toConfig == "" {
+				toConfig = configFile
+			}
+			state, err := loadMigrateState(statePath, from, to, restart)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			src, err := openStorage(cmd, from, fromConfig)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			dst, err := openStorage(cmd, to, toConfig)
+			if err != nil {
+				return err
+			}
+			defer dst.Close()
+
+			results, err := backup.Migrate(ctx, src, dst, backup.MigrateOptions{
+				Projects:  projects,
+				BatchSize: batchSize,
+				Cursors:   state.Cursors,
+				OnBatch: func(schema *models.Schema, cursor *backup.Cursor) error {
+					if err := state.save(); err != nil {
+						return fmt.Errorf("failed to save migrate state: %w", err)
+					}
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s:%s: %d logs copied\n", schema.Project, schema.Table, cursor.Copied)
+					return nil
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if err := state.save(); err != nil {
+				return fmt.Errorf("failed to save migrate state: %w", err)
+			}
+
+			rows := make([][]string, len(results))
+			mismatched := 0
+			for i, result := range results {
+				status := "ok"
+				switch {
+				case result.Source < 0 || result.Target < 0:
+					status = "unverified"
+				case !result.Verified():
+					status = "mismatch"
+					mismatched++
+				}
+				rows[i] = []string{result.Project, result.Table, strconv.Itoa(result.Copied),
+					formatCount(result.Source), formatCount(result.Target), status}
+			}
+			if err := printTable(cmd.OutOrStdout(), []string{"project", "table", "copied", "source", "target", "status"}, rows); err != nil {
+				return err
+			}
+			if mismatched > 0 {
+				return fmt.Errorf("row counts differ for %d tables", mismatched)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "源存储后端类型 (postgres, mysql, sqlite, clickhouse, file)")
+	cmd.Flags().StringVar(&to, "to", "", "目标存储后端类型")
+	cmd.Flags().StringVarP(&configFile, "config", "c", "configs/config.yaml", "服务器配置文件，读取其中的 storage 节点")
+	cmd.Flags().StringVar(&fromConfig, "from-config", "", "源存储使用的配置文件，默认同 --config")
+	cmd.Flags().StringVar(&toConfig, "to-config", "", "目标存储使用的配置文件，默认同 --config")
+	cmd.Flags().StringSliceVarP(&projects, "project", "p", nil, "只迁移这些项目，可重复，默认迁移全部项目")
+	cmd.Flags().IntVar(&batchSize, "batch-size", backup.DefaultBatchSize, "每批复制的日志条数")
+	cmd.Flags().StringVar(&statePath, "state", defaultMigrateState, "记录迁移进度的文件")
+	cmd.Flags().BoolVar(&restart, "restart", false, "忽略已保存的进度，从头复制")
+	return cmd
+}
+
+// openStorage 按配置文件连接并初始化存储后端
+func openStorage(cmd *cobra.Command, storageType, configFile string) (storage.Storage, error) {
+	v, err := config.Load(cmd.Context(), configFile)
+	if err != nil {
+		return nil, err
+	}
+	return storage.New(cmd.Context(), config.Storage(v, storageType))
+}
+
+// formatCount 输出行数，未知时输出 -
+func formatCount(n int64) string {
+	if n < 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
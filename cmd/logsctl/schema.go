@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/schema"
+)
+
+// newSchemaCommand schema 管理命令
+func newSchemaCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "管理 schema",
+	}
+	cmd.AddCommand(
+		newSchemaApplyCommand(opts),
+		newSchemaGetCommand(opts),
+		newSchemaListCommand(opts),
+		newSchemaDeleteCommand(opts),
+		newSchemaRestoreCommand(opts),
+		newSchemaRenameCommand(opts),
+		newSchemaRenameProjectCommand(opts),
+		newSchemaValidateCommand(),
+	)
+	return cmd
+}
+
+// newSchemaApplyCommand 按文件创建或更新 schema
+func newSchemaApplyCommand(opts *options) *cobra.Command {
+	var file string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "apply -f FILE",
+		Short: "按 YAML/JSON 文件创建或更新 schema",
+		Long: "按 YAML/JSON 文件创建或更新 schema。-f 可以是文件、目录（读取其中的 .yaml、.yml 与 .json 文件）或 - 表示标准输入，\n" +
+			"一个 YAML 文件可以用 --- 分隔多个 schema。应用前先在本地校验全部 schema，任一无效时不做修改。",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schemas, err := readSchemas(file, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			for _, s := range schemas {
+				if err := s.Validate(); err != nil {
+					return fmt.Errorf("schema %s:%s: %w", s.Project, s.Table, err)
+				}
+			}
+
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			suffix := ""
+			if dryRun {
+				suffix = " (dry run)"
+			}
+			for _, s := range schemas {
+				action, err := applySchema(cmd.Context(), c, s, dryRun)
+				if err != nil {
+					return fmt.Errorf("schema %s:%s: %w", s.Project, s.Table, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "schema %s:%s %s%s\n", s.Project, s.Table, action, suffix)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "schema 文件、目录或 -（标准输入）")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "只输出将要执行的操作，不修改服务器")
+	cmd.MarkFlagRequired("filename")
+	return cmd
+}
+
+// applySchema 不存在时创建 schema，定义不同时整体替换，返回执行的操作
+func applySchema(ctx context.Context, c *client, s *models.Schema, dryRun bool) (string, error) {
+	var current models.Schema
+	err := c.do(ctx, http.MethodGet, schemaPath(s.Project, s.Table), nil, &current)
+	switch {
+	case errors.Is(err, errNotFound):
+		if dryRun {
+			return "created", nil
+		}
+		return "created", c.do(ctx, http.MethodPost, "/api/v1/schemas", s, nil)
+	case err != nil:
+		return "", err
+	case sameSchema(&current, s):
+		return "unchanged", nil
+	case dryRun:
+		return "configured", nil
+	default:
+		return "configured", c.do(ctx, http.MethodPut, schemaPath(s.Project, s.Table), s, nil)
+	}
+}
+
+// newSchemaGetCommand 输出单个 schema
+func newSchemaGetCommand(opts *options) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "get PROJECT TABLE",
+		Short: "输出 schema 定义",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkOutput(output, "yaml", "json", "jsonschema"); err != nil {
+				return err
+			}
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			path := schemaPath(args[0], args[1])
+			if output == "jsonschema" {
+				var doc json.RawMessage
+				if err := c.do(cmd.Context(), http.MethodGet, path+"?format=jsonschema", nil, &doc); err != nil {
+					return err
+				}
+				var out bytes.Buffer
+				if err := json.Indent(&out, doc, "", "  "); err != nil {
+					return err
+				}
+				out.WriteByte('\n')
+				_, err := out.WriteTo(cmd.OutOrStdout())
+				return err
+			}
+
+			var s models.Schema
+			if err := c.do(cmd.Context(), http.MethodGet, path, nil, &s); err != nil {
+				return err
+			}
+			if output == "json" {
+				return printJSON(cmd.OutOrStdout(), &s)
+			}
+			return printYAML(cmd.OutOrStdout(), &s)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "yaml", "输出格式 (yaml, json, jsonschema)")
+	return cmd
+}
+
+// newSchemaListCommand 列出 schema
+func newSchemaListCommand(opts *options) *cobra.Command {
+	var output, project string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "列出 schema",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkOutput(output, "table", "json", "yaml"); err != nil {
+				return err
+			}
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			var all []*models.Schema
+			if err := c.do(cmd.Context(), http.MethodGet, "/api/v1/schemas", nil, &all); err != nil {
+				return err
+			}
+			schemas := make([]*models.Schema, 0, len(all))
+			for _, s := range all {
+				if project == "" || s.Project == project {
+					schemas = append(schemas, s)
+				}
+			}
+
+			switch output {
+			case "json":
+				return printJSON(cmd.OutOrStdout(), schemas)
+			case "yaml":
+				return printYAML(cmd.OutOrStdout(), schemas)
+			}
+			rows := make([][]string, 0, len(schemas))
+			for _, s := range schemas {
+				updated := "-"
+				if !s.UpdatedAt.IsZero() {
+					updated = s.UpdatedAt.Local().Format(time.RFC3339)
+				}
+				rows = append(rows, []string{s.Project, s.Table, strconv.Itoa(len(s.Fields)), updated})
+			}
+			return printTable(cmd.OutOrStdout(), []string{"project", "table", "fields", "updated"}, rows)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "输出格式 (table, json, yaml)")
+	cmd.Flags().StringVarP(&project, "project", "p", "", "只列出指定项目的 schema")
+	return cmd
+}
+
+// newSchemaDeleteCommand 删除 schema，服务器开启归档时日志表保留到宽限期结束
+func newSchemaDeleteCommand(opts *options) *cobra.Command {
+	var hard bool
+	cmd := &cobra.Command{
+		Use:   "delete PROJECT TABLE",
+		Short: "删除 schema，服务器支持归档时日志表在宽限期内可通过 restore 恢复",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			path := schemaPath(args[0], args[1])
+			if hard {
+				path += "?hard=true"
+			}
+			if err := c.do(cmd.Context(), http.MethodDelete, path, nil, nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "schema %s:%s deleted\n", args[0], args[1])
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&hard, "hard", false, "立即删除日志表，不归档")
+	return cmd
+}
+
+// newSchemaRestoreCommand 恢复最近一次归档的 schema 及其日志
+func newSchemaRestoreCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore PROJECT TABLE",
+		Short: "恢复最近一次删除的 schema 及其日志",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			if err := c.do(cmd.Context(), http.MethodPost, schemaPath(args[0], args[1])+"/restore", nil, nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "schema %s:%s restored\n", args[0], args[1])
+			return nil
+		},
+	}
+}
+
+// newSchemaRenameCommand 重命名 schema 及其日志表
+func newSchemaRenameCommand(opts *options) *cobra.Command {
+	var project string
+	cmd := &cobra.Command{
+		Use:   "rename PROJECT TABLE NEW_TABLE",
+		Short: "重命名 schema 及其日志表，--project 将表移动到其他项目",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			if project == "" {
+				project = args[0]
+			}
+			body := map[string]string{"project": project, "table": args[2]}
+			if err := c.do(cmd.Context(), http.MethodPost, schemaPath(args[0], args[1])+"/rename", body, nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "schema %s:%s renamed to %s:%s\n", args[0], args[1], project, args[2])
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&project, "project", "p", "", "新项目名，默认不变")
+	return cmd
+}
+
+// newSchemaRenameProjectCommand 将项目下的全部 schema 移动到新项目
+func newSchemaRenameProjectCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename-project PROJECT NEW_PROJECT",
+		Short: "重命名项目，项目下的全部 schema 与日志表一起移动",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			var renamed []*models.Schema
+			body := map[string]string{"project": args[1]}
+			if err := c.do(cmd.Context(), http.MethodPost, projectPath(args[0])+"/rename", body, &renamed); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "project %s renamed to %s (%d schemas)\n", args[0], args[1], len(renamed))
+			return nil
+		},
+	}
+}
+
+// newSchemaValidateCommand 校验 schema 目录，不连接服务器与数据库，适合在 CI 中运行
+func newSchemaValidateCommand() *cobra.Command {
+	var storageType, output string
+	var strict bool
+	cmd := &cobra.Command{
+		Use:   "validate DIR",
+		Short: "校验 schema 目录并输出报告，不连接服务器与数据库",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkOutput(output, "text", "json"); err != nil {
+				return err
+			}
+			report, err := schema.ValidateDir(args[0], storageType)
+			if err != nil {
+				return err
+			}
+			if output == "json" {
+				if err := printJSON(cmd.OutOrStdout(), report); err != nil {
+					return err
+				}
+			} else {
+				report.Print(cmd.OutOrStdout())
+			}
+
+			if report.ErrorCount() > 0 || (strict && report.WarningCount() > 0) {
+				return fmt.Errorf("schema validation failed: %d errors, %d warnings", report.ErrorCount(), report.WarningCount())
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&storageType, "storage", "", "检查兼容性的存储后端类型 (postgres, mysql, sqlite, clickhouse)，为空时检查全部")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "报告格式 (text, json)")
+	cmd.Flags().BoolVar(&strict, "strict", false, "存在警告时同样视为失败")
+	return cmd
+}
+
+// readSchemas 读取文件、目录或标准输入中的 schema
+func readSchemas(path string, stdin io.Reader) ([]*models.Schema, error) {
+	if path == "-" {
+		return decodeSchemas("stdin", stdin)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return readSchemaFile(path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var schemas []*models.Schema
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+		found, err := readSchemaFile(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, found...)
+	}
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("no schema files found in %s", path)
+	}
+	return schemas, nil
+}
+
+// readSchemaFile 读取单个文件中的 schema
+func readSchemaFile(filename string) ([]*models.Schema, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return decodeSchemas(filename, f)
+}
+
+// decodeSchemas 解析以 --- 分隔的多个 YAML 文档，JSON 作为 YAML 的子集同样可以解析
+func decodeSchemas(name string, r io.Reader) ([]*models.Schema, error) {
+	decoder := yaml.NewDecoder(r)
+	var schemas []*models.Schema
+	for {
+		s := &models.Schema{}
+		err := decoder.Decode(s)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		if s.Project == "" && s.Table == "" && len(s.Fields) == 0 {
+			// 空文档
+			continue
+		}
+		schemas = append(schemas, s)
+	}
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("no schema found in %s", name)
+	}
+	return schemas, nil
+}
+
+// sameSchema 比较两个 schema 的定义，忽略创建与更新时间
+func sameSchema(a, b *models.Schema) bool {
+	x, y := a.Clone(), b.Clone()
+	x.CreatedAt, x.UpdatedAt = time.Time{}, time.Time{}
+	y.CreatedAt, y.UpdatedAt = time.Time{}, time.Time{}
+	dx, err := json.Marshal(x)
+	if err != nil {
+		return false
+	}
+	dy, err := json.Marshal(y)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(dx, dy)
+}
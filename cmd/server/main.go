@@ -3,80 +3,263 @@ package main
 import (
 	"context"
 	"flag"
-	"fmt"
-	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/anomaly"
 	"pkg.blksails.net/logs/internal/api"
+	"pkg.blksails.net/logs/internal/cluster"
+	"pkg.blksails.net/logs/internal/config"
+	"pkg.blksails.net/logs/internal/issues"
+	"pkg.blksails.net/logs/internal/logging"
+	"pkg.blksails.net/logs/internal/metrics"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/report"
 	"pkg.blksails.net/logs/internal/schema"
 	"pkg.blksails.net/logs/internal/storage"
+	"pkg.blksails.net/logs/pkg/clientip"
 )
 
 var (
 	configFile  string
 	schemasDir  string
 	storageType string
+	dryRun      bool
 )
 
 func init() {
 	flag.StringVar(&configFile, "config", "configs/config.yaml", "配置文件路径")
 	flag.StringVar(&schemasDir, "schemas", "configs/schemas", "Schema 配置目录")
-	flag.StringVar(&storageType, "storage", "clickhouse", "存储后端类型 (postgres, mysql, sqlite, clickhouse)")
+	flag.StringVar(&storageType, "storage", "clickhouse", "存储后端类型 (postgres, mysql, sqlite, clickhouse, file 或通过 storage.Register 注册的后端)")
+	flag.BoolVar(&dryRun, "dry-run", false, "只校验 schema 目录并输出报告，不连接数据库")
 }
 
 func main() {
 	flag.Parse()
 
+	// 读取配置前使用默认的日志配置
+	logger, _ := logging.New(logging.Config{})
+
 	// 加载配置文件
 	viper.SetConfigFile(configFile)
 	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("读取配置文件失败: %v", err)
+		logger.Fatal("读取配置文件失败", zap.Error(err))
+	}
+
+	// 展开配置中的 ${ENV} 环境变量与 ${vault:...}、${awssm:...}、${sops:...} 密钥引用
+	resolveCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	err := config.NewResolver().ResolveViper(resolveCtx, viper.GetViper())
+	cancel()
+	if err != nil {
+		logger.Fatal("解析配置失败", zap.Error(err))
+	}
+
+	configured, err := logging.New(logging.Config{
+		Level:  viper.GetString("log.level"),
+		Format: viper.GetString("log.format"),
+		Output: viper.GetString("log.output"),
+	})
+	if err != nil {
+		logger.Fatal("初始化日志失败", zap.Error(err))
+	}
+	logger = configured
+	defer logger.Sync()
+	zap.ReplaceGlobals(logger)
+
+	// 只校验 schema 文件，不修改目录与数据库
+	if dryRun {
+		report, err := schema.ValidateDir(schemasDir, storageType)
+		if err != nil {
+			logger.Fatal("校验 schema 失败", zap.Error(err))
+		}
+		report.Print(os.Stdout)
+		if report.ErrorCount() > 0 {
+			os.Exit(1)
+		}
+		return
 	}
 
 	// 确保配置目录存在
 	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
-		log.Fatalf("创建配置目录失败: %v", err)
+		logger.Fatal("创建配置目录失败", zap.Error(err))
 	}
 
 	// 确保 schema 目录存在
 	if err := os.MkdirAll(schemasDir, 0755); err != nil {
-		log.Fatalf("创建 schema 目录失败: %v", err)
+		logger.Fatal("创建 schema 目录失败", zap.Error(err))
 	}
 
-	log.Println("storageType", storageType)
 	// 初始化存储后端
-	store, err := initializeStorage(storageType)
+	store, err := initializeStorage(storageType, logger)
 	if err != nil {
-		log.Fatalf("初始化存储后端失败: %v", err)
+		logger.Fatal("初始化存储后端失败", zap.Error(err))
 	}
 	defer store.Close()
 
+	// 多实例部署时通过 PostgreSQL 协调：只有 leader 执行归档、汇总与定时报表，schema 与报表的变更广播到其他实例
+	var coordinator cluster.Coordinator
+	if viper.GetBool("cluster.enabled") {
+		clusterConfig := config.Cluster(viper.GetViper())
+		clusterConfig.Logger = logger
+		postgres, err := cluster.NewPostgres(context.Background(), clusterConfig)
+		if err != nil {
+			logger.Fatal("初始化集群协调失败", zap.Error(err))
+		}
+		defer postgres.Close()
+		coordinator = postgres
+	}
+
+	// schema 注册表由 schema 管理器与 API 服务器共享，未开启缓存时 API 每次从存储读取
+	var schemaRegistry *models.SchemaRegistry
+	if viper.GetBool("schema.cache") {
+		schemaRegistry = models.NewSchemaRegistry()
+	}
+
 	// 初始化 schema 管理器
-	schemaManager, err := schema.NewManager(store, schemasDir)
+	conflictPolicy, err := schema.ParseConflictPolicy(viper.GetString("schema.conflict_policy"))
+	if err != nil {
+		logger.Fatal("解析 schema 冲突策略失败", zap.Error(err))
+	}
+	deletePolicy, err := schema.ParseDeletePolicy(viper.GetString("schema.delete_policy"))
+	if err != nil {
+		logger.Fatal("解析 schema 删除策略失败", zap.Error(err))
+	}
+	schemaManager, err := schema.NewManager(store, schemasDir,
+		schema.WithRegistry(schemaRegistry),
+		schema.WithConflictPolicy(conflictPolicy),
+		schema.WithDeletePolicy(deletePolicy),
+		schema.WithWriteBack(viper.GetBool("schema.write_back")),
+		schema.WithLogger(logger),
+	)
 	if err != nil {
-		log.Fatalf("初始化 schema 管理器失败: %v", err)
+		logger.Fatal("初始化 schema 管理器失败", zap.Error(err))
 	}
 	defer schemaManager.Stop()
 
 	// 启动 schema 管理器
 	if err := schemaManager.Start(); err != nil {
-		log.Fatalf("启动 schema 管理器失败: %v", err)
+		logger.Fatal("启动 schema 管理器失败", zap.Error(err))
+	}
+
+	// 启动定时报表调度器，存储不支持时跳过
+	var reportScheduler *report.Scheduler
+	if viper.GetBool("reports.enabled") {
+		reportScheduler, err = report.NewScheduler(store, report.Config{
+			SMTP: report.SMTPConfig{
+				Host:     viper.GetString("reports.smtp.host"),
+				Port:     viper.GetInt("reports.smtp.port"),
+				Username: viper.GetString("reports.smtp.user"),
+				Password: viper.GetString("reports.smtp.password"),
+				From:     viper.GetString("reports.smtp.from"),
+			},
+			Timeout: viper.GetDuration("reports.timeout"),
+			Leading: leading(coordinator),
+			Logger:  logger,
+		})
+		if err != nil {
+			logger.Warn("定时报表不可用", zap.Error(err))
+		} else {
+			if err := reportScheduler.Start(context.Background()); err != nil {
+				logger.Fatal("启动定时报表调度器失败", zap.Error(err))
+			}
+			defer reportScheduler.Stop()
+		}
+	}
+
+	// 日志转指标规则，未配置时不暴露 /metrics
+	var metricRules []*metrics.Rule
+	if err := viper.UnmarshalKey("metrics.rules", &metricRules); err != nil {
+		logger.Fatal("解析指标规则失败", zap.Error(err))
+	}
+	var metricRegistry *metrics.Registry
+	if len(metricRules) > 0 {
+		if metricRegistry, err = metrics.NewRegistry(metricRules); err != nil {
+			logger.Fatal("指标规则无效", zap.Error(err))
+		}
+	}
+
+	// 写入量异常检测，未开启时不统计
+	var anomalyDetector *anomaly.Detector
+	if viper.GetBool("anomaly.enabled") {
+		anomalyDetector = anomaly.NewDetector(anomaly.Config{
+			Interval:  viper.GetDuration("anomaly.interval"),
+			Alpha:     viper.GetFloat64("anomaly.alpha"),
+			Threshold: viper.GetFloat64("anomaly.threshold"),
+			MinCount:  viper.GetFloat64("anomaly.min_count"),
+			Warmup:    viper.GetInt("anomaly.warmup"),
+			Seasonal:  viper.GetBool("anomaly.seasonal"),
+			Webhook:   viper.GetString("anomaly.webhook"),
+			Logger:    logger,
+		})
+		anomalyDetector.Start()
+		defer anomalyDetector.Stop()
+	}
+
+	// 错误日志归并为问题，存储不支持时跳过
+	var issueProcessor *issues.Processor
+	if viper.GetBool("issues.enabled") {
+		issueProcessor, err = issues.NewProcessor(store, issues.Config{
+			Levels:      viper.GetStringSlice("issues.levels"),
+			StackFields: viper.GetStringSlice("issues.stack_fields"),
+			Logger:      logger,
+		})
+		if err != nil {
+			logger.Warn("错误归并不可用", zap.Error(err))
+		}
+	}
+
+	// 客户端地址只从受信任代理转发的头部读取
+	clientIP, err := clientip.New(clientip.Config{
+		TrustedProxies: viper.GetStringSlice("server.trusted_proxies"),
+		Headers:        viper.GetStringSlice("server.client_ip_headers"),
+	})
+	if err != nil {
+		logger.Fatal("受信任代理配置无效", zap.Error(err))
 	}
 
 	// 初始化 API 服务器
 	server := api.NewServer(store, &api.Config{
-		Host: viper.GetString("server.host"),
-		Port: viper.GetInt("server.port"),
+		Host:                  viper.GetString("server.host"),
+		Port:                  viper.GetInt("server.port"),
+		SchemaManager:         schemaManager,
+		Schemas:               schemaRegistry,
+		ReadOnly:              viper.GetBool("server.read_only"),
+		ReadOnlyProjects:      viper.GetStringSlice("server.read_only_projects"),
+		Telemetry:             viper.GetBool("telemetry.enabled"),
+		ReportScheduler:       reportScheduler,
+		Cluster:               coordinator,
+		Metrics:               metricRegistry,
+		Anomaly:               anomalyDetector,
+		Issues:                issueProcessor,
+		StorageType:           storageType,
+		MaxDecompressedBody:   viper.GetInt64("server.max_decompressed_body"),
+		IdempotencyTTL:        viper.GetDuration("server.idempotency_ttl"),
+		BatchChunkSize:        viper.GetInt("server.batch_chunk_size"),
+		Pprof:                 viper.GetBool("server.pprof"),
+		SchemaWebhook:         viper.GetString("server.schema_webhook"),
+		ValidateRequests:      viper.GetBool("server.validate_requests"),
+		SchemaArchiveGrace:    viper.GetDuration("schema.archive_grace"),
+		RollupInterval:        viper.GetDuration("schema.rollup_interval"),
+		LogMutationLimit:      viper.GetInt64("server.max_mutation_rows"),
+		QueryTimeout:          viper.GetDuration("server.query_timeout"),
+		MaxQueryRows:          viper.GetInt("server.max_query_rows"),
+		RangeRequiredRows:     viper.GetInt64("server.range_required_rows"),
+		SlowQueryThreshold:    viper.GetDuration("server.slow_query_threshold"),
+		SlowQueryRetention:    viper.GetDuration("server.slow_query_retention"),
+		ClientIP:              clientIP.ClientIP,
+		TLSFingerprintHeaders: viper.GetStringMapString("server.tls_fingerprint_headers"),
+		Logger:                logger,
 	})
 
 	// 启动服务器
 	go func() {
 		if err := server.Start(); err != nil {
-			log.Printf("服务器停止: %v", err)
+			logger.Warn("服务器停止", zap.Error(err))
 		}
 	}()
 
@@ -86,63 +269,22 @@ func main() {
 	<-sigChan
 
 	// 优雅关闭
-	fmt.Println("\n正在关闭服务...")
+	logger.Info("正在关闭服务...")
 	if err := server.Stop(context.Background()); err != nil {
-		log.Printf("服务器关闭出错: %v", err)
+		logger.Warn("服务器关闭出错", zap.Error(err))
+	}
+}
+
+// leading 返回定时报表判断本实例是否为 leader 的函数，未开启集群协调时返回 nil
+func leading(coordinator cluster.Coordinator) func(context.Context) bool {
+	if coordinator == nil {
+		return nil
 	}
+	return coordinator.Leading
 }
 
-func initializeStorage(storageType string) (storage.Storage, error) {
-	ctx := context.Background()
-
-	config := storage.Config{
-		Type: storageType,
-		Postgres: storage.PostgresConfig{
-			Host:     viper.GetString("storage.postgres.host"),
-			Port:     viper.GetInt("storage.postgres.port"),
-			Database: viper.GetString("storage.postgres.database"),
-			Username: viper.GetString("storage.postgres.user"),
-			Password: viper.GetString("storage.postgres.password"),
-			Schema:   viper.GetString("storage.postgres.schema"),
-		},
-		MySQL: storage.MySQLConfig{
-			Host:     viper.GetString("storage.mysql.host"),
-			Port:     viper.GetInt("storage.mysql.port"),
-			Database: viper.GetString("storage.mysql.database"),
-			Username: viper.GetString("storage.mysql.user"),
-			Password: viper.GetString("storage.mysql.password"),
-		},
-		SQLite: storage.SQLiteConfig{
-			Path: viper.GetString("storage.sqlite.path"),
-		},
-		ClickHouse: storage.ClickHouseConfig{
-			Host:     viper.GetString("storage.clickhouse.host"),
-			Port:     viper.GetInt("storage.clickhouse.port"),
-			Database: viper.GetString("storage.clickhouse.database"),
-			Username: viper.GetString("storage.clickhouse.user"),
-			Password: viper.GetString("storage.clickhouse.password"),
-		},
-	}
-
-	var store storage.Storage
-	log.Println(storageType)
-	log.Printf("%+v", config)
-	switch storageType {
-	case "postgres":
-		store = storage.NewPostgresStorage(config)
-	case "mysql":
-		store = storage.NewMySQLStorage(config)
-	case "sqlite":
-		store = storage.NewSQLiteStorage(config)
-	case "clickhouse":
-		store = storage.NewClickHouseStorage(config)
-	default:
-		return nil, fmt.Errorf("不支持的存储后端类型: %s", storageType)
-	}
-
-	if err := store.Initialize(ctx); err != nil {
-		return nil, fmt.Errorf("初始化存储后端失败: %w", err)
-	}
-
-	return store, nil
+func initializeStorage(storageType string, logger *zap.Logger) (storage.Storage, error) {
+	storageConfig := config.Storage(viper.GetViper(), storageType)
+	storageConfig.Logger = logger
+	return storage.New(context.Background(), storageConfig)
 }
@@ -15,6 +15,7 @@ import (
 	"gopkg.in/yaml.v3"
 	"pkg.blksails.net/logs/internal/api"
 	"pkg.blksails.net/logs/internal/storage"
+	"pkg.blksails.net/logs/pkg/ginlog"
 	zaphook "pkg.blksails.net/logs/pkg/zap"
 )
 
@@ -84,24 +85,14 @@ func main() {
 	router := gin.Default()
 
 	// 添加中间件记录请求日志
-	router.Use(func(c *gin.Context) {
-		start := time.Now()
-
-		// 处理请求
-		c.Next()
-
-		// 记录日志
-		duration := time.Since(start)
-		logger.Info("HTTP Request",
-			zap.String("method", c.Request.Method),
-			zap.String("path", c.Request.URL.Path),
-			zap.Int("status", c.Writer.Status()),
-			zap.Duration("duration", duration),
-			zap.String("ip", c.ClientIP()),
-			zap.String("user_agent", c.Request.UserAgent()),
-			zap.String("referer", c.Request.Referer()),
-		)
-	})
+	router.Use(ginlog.Middleware(store, "myapp", "access_logs",
+		ginlog.WithHeaders("User-Agent", "Referer"),
+		ginlog.WithExcludePaths("/health"),
+	))
+
+	// 日志钩子的缓冲区与刷新状态，用于观察写入是否落后
+	router.GET("/metrics", gin.WrapH(zaphook.MetricsHandler(hook)))
+	router.GET("/debug/log-hooks", gin.WrapH(zaphook.StatsHandler(hook)))
 
 	// 添加示例路由
 	router.GET("/hello", func(c *gin.Context) {
@@ -175,8 +166,8 @@ func initializeStorage(cfg *Config) (storage.Storage, error) {
 		},
 	}
 
-	store := storage.NewPostgresStorage(config)
-	if err := store.Initialize(context.Background()); err != nil {
+	store, err := storage.New(context.Background(), config)
+	if err != nil {
 		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
@@ -27,8 +27,8 @@ func main() {
 	}
 
 	// 初始化存储
-	store := storage.NewPostgresStorage(config)
-	if err := store.Initialize(context.Background()); err != nil {
+	store, err := storage.New(context.Background(), config)
+	if err != nil {
 		log.Fatalf("初始化存储失败: %v", err)
 	}
 	defer store.Close()
@@ -0,0 +1,368 @@
+// Package anomaly 按项目、表与日志级别统计写入量，以 EWMA 基线检测写入量突增与断流，并发送告警
+package anomaly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/logging"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+const (
+	DefaultInterval  = time.Minute
+	DefaultAlpha     = 0.1
+	DefaultThreshold = 4.0
+	DefaultMinCount  = 10
+	DefaultWarmup    = 30
+)
+
+// MaxSeries 最多跟踪的项目/表/级别组合数，超过后新的组合不再检测
+const MaxSeries = 10000
+
+// maxRecentAlerts Status 返回的最近告警条数
+const maxRecentAlerts = 100
+
+// Event 告警类型
+type Event string
+
+const (
+	EventSpike Event = "volume.spike" // 写入量明显高于基线
+	EventDrop  Event = "volume.drop"  // 基线有稳定写入时写入量降为零
+)
+
+// Config 检测器配置
+type Config struct {
+	// Interval 统计窗口长度，每个窗口结束时与基线比较，默认 DefaultInterval
+	Interval time.Duration
+	// Alpha EWMA 平滑系数，取值 (0, 1]，越大基线跟随越快，默认 DefaultAlpha
+	Alpha float64
+	// Threshold 窗口条数超过基线均值多少个标准差视为突增，默认 DefaultThreshold
+	Threshold float64
+	// MinCount 突增时窗口至少的条数，以及检测断流时基线均值的下限，避免低流量表误报，默认 DefaultMinCount
+	MinCount float64
+	// Warmup 基线至少经过多少个窗口才开始告警，默认 DefaultWarmup
+	Warmup int
+	// Seasonal 按一天中的小时（UTC）分别建立基线，适合有明显日周期的流量
+	Seasonal bool
+	// Webhook 可选，告警以 JSON POST 到该地址
+	Webhook    string
+	HTTPClient *http.Client // 默认带 10 秒超时的客户端
+	Logger     *zap.Logger  // 为空时使用全局 logger
+}
+
+// Alert 一次写入量异常
+type Alert struct {
+	Event       Event     `json:"event"`
+	Project     string    `json:"project"`
+	Table       string    `json:"table"`
+	Level       string    `json:"level"`
+	Count       int64     `json:"count"`    // 窗口内写入的条数
+	Expected    float64   `json:"expected"` // 基线均值
+	StdDev      float64   `json:"stddev"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+}
+
+// Baseline 一个组合当前的基线
+type Baseline struct {
+	Project   string  `json:"project"`
+	Table     string  `json:"table"`
+	Level     string  `json:"level"`
+	Mean      float64 `json:"mean"`
+	StdDev    float64 `json:"stddev"`
+	Windows   int     `json:"windows"`   // 参与基线的窗口数
+	Anomalous bool    `json:"anomalous"` // 最近一个窗口是否异常
+}
+
+// Status 检测器状态
+type Status struct {
+	Enabled   bool        `json:"enabled"`
+	Interval  string      `json:"interval,omitempty"`
+	Seasonal  bool        `json:"seasonal"`
+	Alerts    []*Alert    `json:"alerts"` // 最近的告警，新的在前
+	Baselines []*Baseline `json:"baselines"`
+}
+
+// key 统计维度
+type key struct {
+	project, table, level string
+}
+
+// less 按项目、表、级别排序
+func (k key) less(o key) bool {
+	if k.project != o.project {
+		return k.project < o.project
+	}
+	if k.table != o.table {
+		return k.table < o.table
+	}
+	return k.level < o.level
+}
+
+// ewma 指数加权的均值与方差
+type ewma struct {
+	mean, variance float64
+	windows        int
+}
+
+// update 计入一个窗口的条数
+func (e *ewma) update(x, alpha float64) {
+	if e.windows == 0 {
+		e.mean = x
+	} else {
+		diff := x - e.mean
+		incr := alpha * diff
+		e.mean += incr
+		e.variance = (1 - alpha) * (e.variance + diff*incr)
+	}
+	e.windows++
+}
+
+// series 一个组合的基线，非季节性时只使用 slots[0]
+type series struct {
+	slots     []ewma
+	anomalous bool
+}
+
+// Detector 统计写入量并检测异常，可并发使用
+type Detector struct {
+	config Config
+	logger *zap.Logger
+
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[key]int64
+	series      map[key]*series
+	recent      []*Alert
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDetector 创建检测器，第一个窗口从当前时间开始
+func NewDetector(config Config) *Detector {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Alpha <= 0 || config.Alpha > 1 {
+		config.Alpha = DefaultAlpha
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultThreshold
+	}
+	if config.MinCount <= 0 {
+		config.MinCount = DefaultMinCount
+	}
+	if config.Warmup <= 0 {
+		config.Warmup = DefaultWarmup
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Detector{
+		config:      config,
+		logger:      logging.Component(config.Logger, "anomaly"),
+		windowStart: time.Now(),
+		counts:      make(map[key]int64),
+		series:      make(map[key]*series),
+		done:        make(chan struct{}),
+	}
+}
+
+// Observe 将成功写入的一批日志计入当前窗口，d 为 nil 时不做任何事
+func (d *Detector) Observe(project, table string, logs []*models.LogEntry) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, log := range logs {
+		k := key{project, table, log.Level}
+		if _, ok := d.series[k]; !ok {
+			if len(d.series) >= MaxSeries {
+				continue
+			}
+			d.series[k] = &series{slots: make([]ewma, d.slots())}
+		}
+		d.counts[k]++
+	}
+}
+
+// slots 基线的分段数
+func (d *Detector) slots() int {
+	if d.config.Seasonal {
+		return 24
+	}
+	return 1
+}
+
+// Evaluate 结束当前窗口：将各组合的条数与基线比较并更新基线，返回新出现的异常。
+// 连续异常的组合只在第一个异常窗口告警，恢复正常后才会再次告警
+func (d *Detector) Evaluate(now time.Time) []*Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	start := d.windowStart
+	slot := 0
+	if d.config.Seasonal {
+		slot = start.UTC().Hour()
+	}
+
+	var alerts []*Alert
+	for k, s := range d.series {
+		count := d.counts[k]
+		base := &s.slots[slot]
+		event, anomalous := d.check(base, float64(count))
+		if anomalous && !s.anomalous {
+			alerts = append(alerts, &Alert{
+				Event:       event,
+				Project:     k.project,
+				Table:       k.table,
+				Level:       k.level,
+				Count:       count,
+				Expected:    base.mean,
+				StdDev:      math.Sqrt(base.variance),
+				WindowStart: start,
+				WindowEnd:   now,
+			})
+		}
+		s.anomalous = anomalous
+		base.update(float64(count), d.config.Alpha)
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		return key{a.Project, a.Table, a.Level}.less(key{b.Project, b.Table, b.Level})
+	})
+	for _, alert := range alerts {
+		d.recent = append([]*Alert{alert}, d.recent...)
+	}
+	if len(d.recent) > maxRecentAlerts {
+		d.recent = d.recent[:maxRecentAlerts]
+	}
+
+	d.windowStart = now
+	d.counts = make(map[key]int64)
+	return alerts
+}
+
+// check 判断窗口条数相对基线是否异常。标准差不小于泊松噪声 sqrt(mean)，避免平稳流量的微小波动被判为突增
+func (d *Detector) check(base *ewma, count float64) (Event, bool) {
+	if base.windows < d.config.Warmup {
+		return "", false
+	}
+	if count == 0 && base.mean >= d.config.MinCount {
+		return EventDrop, true
+	}
+	stddev := math.Max(math.Sqrt(base.variance), math.Sqrt(base.mean))
+	if count >= d.config.MinCount && count > base.mean+d.config.Threshold*stddev {
+		return EventSpike, true
+	}
+	return "", false
+}
+
+// Status 返回最近的告警与各组合当前时段的基线，d 为 nil 时表示未启用
+func (d *Detector) Status() *Status {
+	if d == nil {
+		return &Status{Alerts: []*Alert{}, Baselines: []*Baseline{}}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	slot := 0
+	if d.config.Seasonal {
+		slot = d.windowStart.UTC().Hour()
+	}
+	status := &Status{
+		Enabled:   true,
+		Interval:  d.config.Interval.String(),
+		Seasonal:  d.config.Seasonal,
+		Alerts:    append([]*Alert{}, d.recent...),
+		Baselines: make([]*Baseline, 0, len(d.series)),
+	}
+	for k, s := range d.series {
+		base := s.slots[slot]
+		status.Baselines = append(status.Baselines, &Baseline{
+			Project:   k.project,
+			Table:     k.table,
+			Level:     k.level,
+			Mean:      base.mean,
+			StdDev:    math.Sqrt(base.variance),
+			Windows:   base.windows,
+			Anomalous: s.anomalous,
+		})
+	}
+	sort.Slice(status.Baselines, func(i, j int) bool {
+		a, b := status.Baselines[i], status.Baselines[j]
+		return key{a.Project, a.Table, a.Level}.less(key{b.Project, b.Table, b.Level})
+	})
+	return status
+}
+
+// Start 在后台每隔 Interval 结束一个窗口并发送告警，直到 Stop
+func (d *Detector) Start() {
+	go func() {
+		ticker := time.NewTicker(d.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				for _, alert := range d.Evaluate(now) {
+					d.notify(alert)
+				}
+			case <-d.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检测
+func (d *Detector) Stop() {
+	d.stopOnce.Do(func() { close(d.done) })
+}
+
+// notify 记录告警并发送到 Webhook，失败时只记录日志
+func (d *Detector) notify(alert *Alert) {
+	d.logger.Warn("log volume anomaly",
+		zap.String("event", string(alert.Event)),
+		zap.String("project", alert.Project),
+		zap.String("table", alert.Table),
+		zap.String("level", alert.Level),
+		zap.Int64("count", alert.Count),
+		zap.Float64("expected", alert.Expected))
+	if d.config.Webhook == "" {
+		return
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		d.logger.Error("failed to encode anomaly webhook", zap.Error(err))
+		return
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, d.config.Webhook, bytes.NewReader(body))
+	if err != nil {
+		d.logger.Warn("failed to send anomaly webhook", zap.String("url", d.config.Webhook), zap.Error(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.config.HTTPClient.Do(req)
+	if err != nil {
+		d.logger.Warn("failed to send anomaly webhook", zap.String("url", d.config.Webhook), zap.Error(err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		d.logger.Warn("failed to send anomaly webhook", zap.String("url", d.config.Webhook), zap.String("status", resp.Status))
+	}
+}
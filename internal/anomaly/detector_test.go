@@ -0,0 +1,111 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// batch 生成 n 条指定级别的日志
+func batch(n int, level string) []*models.LogEntry {
+	logs := make([]*models.LogEntry, n)
+	for i := range logs {
+		logs[i] = &models.LogEntry{Level: level, Message: "m"}
+	}
+	return logs
+}
+
+func TestDetector(t *testing.T) {
+	d := NewDetector(Config{Interval: time.Minute, Warmup: 5})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := func(counts map[string]int) []*Alert {
+		for level, n := range counts {
+			d.Observe("app", "requests", batch(n, level))
+		}
+		now = now.Add(time.Minute)
+		return d.Evaluate(now)
+	}
+
+	// 预热期内不告警
+	for i := 0; i < 10; i++ {
+		assert.Empty(t, window(map[string]int{"info": 100 + i%3, "error": 1}))
+	}
+	// 正常波动与低流量级别的小幅增长不告警
+	assert.Empty(t, window(map[string]int{"info": 110, "error": 5}))
+
+	alerts := window(map[string]int{"info": 1000, "error": 1})
+	require.Len(t, alerts, 1)
+	assert.Equal(t, EventSpike, alerts[0].Event)
+	assert.Equal(t, "info", alerts[0].Level)
+	assert.EqualValues(t, 1000, alerts[0].Count)
+	assert.InDelta(t, 101, alerts[0].Expected, 2)
+	assert.Equal(t, now, alerts[0].WindowEnd)
+	// 持续异常不重复告警
+	assert.Empty(t, window(map[string]int{"info": 5000, "error": 1}))
+
+	for i := 0; i < 30; i++ {
+		window(map[string]int{"info": 100, "error": 1})
+	}
+	// 断流：info 降为零告警，error 的基线低于 MinCount 不告警
+	alerts = window(nil)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, EventDrop, alerts[0].Event)
+	assert.EqualValues(t, 0, alerts[0].Count)
+
+	status := d.Status()
+	assert.True(t, status.Enabled)
+	require.Len(t, status.Alerts, 2)
+	assert.Equal(t, EventDrop, status.Alerts[0].Event)
+	require.Len(t, status.Baselines, 2)
+	assert.Equal(t, "error", status.Baselines[0].Level)
+	assert.True(t, status.Baselines[1].Anomalous)
+
+	var nilDetector *Detector
+	nilDetector.Observe("app", "requests", batch(1, "info"))
+	assert.False(t, nilDetector.Status().Enabled)
+}
+
+func TestDetectorSeasonal(t *testing.T) {
+	d := NewDetector(Config{Seasonal: true, Warmup: 3})
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 白天流量高、夜间流量低
+	for i := 0; i < 5; i++ {
+		for hour, n := range map[int]int{3: 20, 12: 2000} {
+			d.windowStart = day.Add(time.Duration(i*24+hour) * time.Hour)
+			d.Observe("app", "requests", batch(n, "info"))
+			assert.Empty(t, d.Evaluate(d.windowStart.Add(time.Minute)))
+		}
+	}
+
+	// 白天的流量出现在夜间时段才是突增
+	d.windowStart = day.Add(5*24*time.Hour + 3*time.Hour)
+	d.Observe("app", "requests", batch(2000, "info"))
+	alerts := d.Evaluate(d.windowStart.Add(time.Minute))
+	require.Len(t, alerts, 1)
+	assert.Equal(t, EventSpike, alerts[0].Event)
+	assert.InDelta(t, 20, alerts[0].Expected, 0.01)
+}
+
+func TestDetectorWebhook(t *testing.T) {
+	received := make(chan *Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert Alert
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		received <- &alert
+	}))
+	defer srv.Close()
+
+	d := NewDetector(Config{Webhook: srv.URL})
+	d.notify(&Alert{Event: EventDrop, Project: "app", Table: "requests", Level: "info", Expected: 50})
+	alert := <-received
+	assert.Equal(t, EventDrop, alert.Event)
+	assert.Equal(t, "requests", alert.Table)
+	assert.Equal(t, 50.0, alert.Expected)
+}
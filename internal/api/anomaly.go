@@ -0,0 +1,12 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// anomalyStatus 返回写入量异常检测的最近告警与各项目、表、级别的基线，未启用时 enabled 为 false
+func (s *Server) anomalyStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.anomaly.Status())
+}
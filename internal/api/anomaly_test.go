@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/anomaly"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestAnomalyStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+	}))
+
+	status := func(server *Server) *anomaly.Status {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/anomalies", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp anomaly.Status
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return &resp
+	}
+	assert.False(t, status(NewServer(store, &Config{})).Enabled)
+
+	detector := anomaly.NewDetector(anomaly.Config{})
+	server := NewServer(store, &Config{Anomaly: detector})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/requests/batch",
+		strings.NewReader(`[{"level":"info","message":"a","status":200},{"level":"error","message":"b","status":500}]`))
+	req.Header.Set("Content-Type", "application/json")
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	detector.Evaluate(time.Now())
+
+	resp := status(server)
+	assert.True(t, resp.Enabled)
+	assert.Equal(t, "1m0s", resp.Interval)
+	require.Len(t, resp.Baselines, 2)
+	assert.Equal(t, "error", resp.Baselines[0].Level)
+	assert.Equal(t, 1.0, resp.Baselines[1].Mean)
+	assert.Equal(t, 1, resp.Baselines[1].Windows)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// DefaultSchemaArchiveGrace 删除 schema 后归档日志表的默认保留时间
+const DefaultSchemaArchiveGrace = 7 * 24 * time.Hour
+
+// archivePurgeInterval 检查并永久删除过期归档的间隔
+const archivePurgeInterval = time.Hour
+
+// archiver 返回存储的归档能力，未开启归档或存储不支持时返回 false
+func (s *Server) archiver() (storage.SchemaArchiver, bool) {
+	if s.archiveGrace < 0 {
+		return nil, false
+	}
+	return storage.As[storage.SchemaArchiver](s.storage)
+}
+
+// listArchivedSchemas 列出已归档的 schema 及其永久删除时间
+func (s *Server) listArchivedSchemas(c *gin.Context) {
+	archiver, ok := s.archiver()
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "schema archiving is not enabled")
+		return
+	}
+
+	archives, err := archiver.ListArchivedSchemas(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for _, archived := range archives {
+		purgeAt := archived.ArchivedAt.Add(s.archiveGrace)
+		archived.PurgeAt = &purgeAt
+	}
+	c.JSON(http.StatusOK, archives)
+}
+
+// restoreSchema 恢复最近一次归档的 schema 及其日志
+func (s *Server) restoreSchema(c *gin.Context) {
+	project := c.Param("project")
+	table := c.Param("table")
+	archiver, ok := s.archiver()
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "schema archiving is not enabled")
+		return
+	}
+
+	schema, err := archiver.RestoreSchema(c.Request.Context(), project, table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	s.respondSchema(c, http.StatusOK, project, table, schema)
+}
+
+// purgeArchivedSchemas 永久删除超过宽限期的归档，由后台任务 archive_purge 定期执行
+func (s *Server) purgeArchivedSchemas(ctx context.Context) error {
+	archiver, ok := s.archiver()
+	if !ok {
+		return nil
+	}
+	purged, err := archiver.PurgeArchivedSchemas(ctx, time.Now().Add(-s.archiveGrace))
+	for _, archived := range purged {
+		s.logger.Info("archived schema purged",
+			zap.String("project", archived.Project), zap.String("table", archived.Table),
+			zap.String("archive_table", archived.ArchiveTable))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to purge archived schemas: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestArchiveSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	server := NewServer(store, &Config{})
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString}},
+	}
+	require.NoError(t, store.CreateSchema(ctx, schema))
+	require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+		Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: time.Now(),
+		Fields: map[string]interface{}{"name": "kept"},
+	}))
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/v1/schemas/app/events").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/schemas/app/events").Code)
+
+	w := do(http.MethodGet, "/api/v1/schemas/archived")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var archives []*models.ArchivedSchema
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &archives))
+	require.Len(t, archives, 1)
+	assert.Equal(t, "events", archives[0].Table)
+	require.NotNil(t, archives[0].PurgeAt)
+	assert.Equal(t, archives[0].ArchivedAt.Add(DefaultSchemaArchiveGrace), *archives[0].PurgeAt)
+
+	w = do(http.MethodPost, "/api/v1/schemas/app/events/restore")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	rows, err := store.QueryLogs(ctx, "app", "events", nil, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "kept", rows[0]["name"])
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/v1/schemas/app/events/restore").Code)
+
+	// 归档后重新创建的同名 schema 不会被覆盖
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/v1/schemas/app/events").Code)
+	require.NoError(t, store.CreateSchema(ctx, schema))
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/api/v1/schemas/app/events/restore").Code)
+
+	// hard=true 立即删除日志表
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/v1/schemas/app/events?hard=true").Code)
+	archives, err = store.ListArchivedSchemas(ctx)
+	require.NoError(t, err)
+	assert.Len(t, archives, 1)
+
+	// 宽限期结束后清理归档
+	server.archiveGrace = -time.Hour
+	server.purgeArchivedSchemas(ctx)
+	archives, err = store.ListArchivedSchemas(ctx)
+	require.NoError(t, err)
+	assert.Len(t, archives, 1, "archiving disabled")
+	server.archiveGrace = time.Nanosecond
+	server.purgeArchivedSchemas(ctx)
+	archives, err = store.ListArchivedSchemas(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, archives)
+
+	disabled := NewServer(store, &Config{SchemaArchiveGrace: -1})
+	w = httptest.NewRecorder()
+	disabled.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/schemas/archived", nil))
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/backup"
+)
+
+// exportProject 以 NDJSON 流导出项目的全部 schema 与日志，用于备份或迁移到另一个存储后端。
+// 开始写出后发生的错误无法再改变状态码，只记录日志并中断响应，客户端会收到不完整的流
+func (s *Server) exportProject(c *gin.Context) {
+	project := c.Param("project")
+	switch format := c.DefaultQuery("format", "ndjson"); format {
+	case "ndjson", "jsonl":
+	case "parquet":
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "parquet export is not supported, use ndjson")
+		return
+	default:
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "unsupported export format: "+format)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.ndjson", project, time.Now().UTC().Format("20060102T150405Z"))
+	result, err := backup.Export(c.Request.Context(), s.storage, project, &exportWriter{c: c, filename: filename})
+	switch {
+	case errors.Is(err, backup.ErrUnsupported) && !c.Writer.Written():
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, err.Error())
+	case err != nil && !c.Writer.Written():
+		respondError(c, err)
+	case err != nil:
+		s.logger.Error("project export failed", zap.String("project", project), zap.Error(err))
+		c.Abort()
+	default:
+		s.logger.Info("project exported", zap.String("project", project),
+			zap.Int("schemas", result.Schemas), zap.Int("logs", result.Logs))
+	}
+}
+
+// exportWriter 在写出第一行时才设置下载的响应头，此前的错误仍以 JSON 返回
+type exportWriter struct {
+	c        *gin.Context
+	filename string
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	if !w.c.Writer.Written() {
+		w.c.Header("Content-Type", "application/x-ndjson")
+		w.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", w.filename))
+	}
+	return w.c.Writer.Write(p)
+}
+
+// restoreProject 从 exportProject 导出的流中重建项目的 schema 与日志，写入路径中的项目。
+// 表已存在时返回 409，append=true 时向已有的表追加日志
+func (s *Server) restoreProject(c *gin.Context) {
+	project := c.Param("project")
+	if s.rejectReadOnly(c, project) {
+		return
+	}
+	opts := backup.RestoreOptions{Project: project}
+	if value := c.Query("append"); value != "" {
+		appendLogs, err := strconv.ParseBool(value)
+		if err != nil {
+			respondStatus(c, http.StatusBadRequest, CodeBadRequest, "invalid append: "+value)
+			return
+		}
+		opts.Append = appendLogs
+	}
+
+	result, err := backup.Restore(c.Request.Context(), s.storage, c.Request.Body, opts)
+	if err != nil {
+		if result != nil {
+			s.logger.Error("project restore failed", zap.String("project", project),
+				zap.Int("schemas", result.Schemas), zap.Int("logs", result.Logs), zap.Error(err))
+		}
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/backup"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestExportRestoreProject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+	}))
+	require.NoError(t, store.InsertLog(ctx, "app", "requests", &models.LogEntry{
+		Project: "app", Table: "requests", Level: "info", Message: "ok",
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields:    map[string]interface{}{"status": 200},
+	}))
+	server := NewServer(store, &Config{})
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodGet, "/api/v1/admin/projects/app/export", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="app-`)
+	dump := w.Body.String()
+	assert.Equal(t, 3, strings.Count(dump, "\n"), "header, schema and one log")
+
+	w = serve(http.MethodPost, "/api/v1/admin/projects/copy/restore", dump)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result backup.RestoreResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, backup.RestoreResult{Project: "copy", Schemas: 1, Logs: 1}, result)
+	rows, err := store.SearchLogs(ctx, "copy", "requests", &models.Query{})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.EqualValues(t, 200, rows[0]["status"])
+
+	w = serve(http.MethodPost, "/api/v1/admin/projects/copy/restore", dump)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+	// 追加到已存在的空表
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "merged",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+	}))
+	w = serve(http.MethodPost, "/api/v1/admin/projects/merged/restore?append=true", dump)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, backup.RestoreResult{Project: "merged", Schemas: 0, Logs: 1}, result)
+
+	w = serve(http.MethodPost, "/api/v1/admin/projects/copy/restore", "not json")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	w = serve(http.MethodGet, "/api/v1/admin/projects/missing/export", "")
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+	w = serve(http.MethodGet, "/api/v1/admin/projects/app/export?format=parquet", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
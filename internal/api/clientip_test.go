@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+	"pkg.blksails.net/logs/pkg/clientip"
+)
+
+func TestInsertLogClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "ip", Type: models.FieldTypeString}},
+	}))
+
+	insert := func(server *Server, remote string) string {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/requests", strings.NewReader(`{"level":"info","message":"hi"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		rows, err := store.SearchLogs(ctx, "app", "requests", &models.Query{Fields: []string{"ip"}, Sort: []string{"-timestamp"}, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		return rows[0]["ip"].(string)
+	}
+
+	// 默认不信任代理头部
+	assert.Equal(t, "10.0.0.2", insert(NewServer(store, &Config{}), "10.0.0.2:5000"))
+
+	resolver, err := clientip.New(clientip.Config{TrustedProxies: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+	server := NewServer(store, &Config{ClientIP: resolver.ClientIP})
+	assert.Equal(t, "198.51.100.1", insert(server, "10.0.0.2:5000"))
+	assert.Equal(t, "203.0.113.7", insert(server, "203.0.113.7:5000"))
+}
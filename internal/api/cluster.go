@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/cluster"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// leading 本实例是否执行后台任务，未设置 Cluster 时总是执行
+func (s *Server) leading(ctx context.Context) bool {
+	return s.cluster == nil || s.cluster.Leading(ctx)
+}
+
+// announce 向其他实例广播变更，失败只记录日志：其他实例的缓存可能短暂过期，但本次修改已经生效
+func (s *Server) announce(ctx context.Context, msg *cluster.Message) {
+	if s.cluster == nil {
+		return
+	}
+	if err := s.cluster.Publish(ctx, msg); err != nil {
+		s.logger.Warn("failed to announce change to other instances",
+			zap.String("kind", msg.Kind), zap.Error(err))
+	}
+}
+
+// clusterLoop 将注册表中的 schema 变更广播给其他实例，并应用其他实例的变更，直到 done 关闭
+func (s *Server) clusterLoop(done <-chan struct{}) {
+	if s.cluster == nil {
+		return
+	}
+	var events <-chan models.SchemaEvent
+	if s.schemas != nil {
+		var cancel func()
+		events, cancel = s.schemas.Subscribe(256)
+		defer cancel()
+	}
+	messages := s.cluster.Messages()
+	for {
+		select {
+		case event := <-events:
+			s.announce(context.Background(), &cluster.Message{Kind: cluster.KindSchema, Project: event.Project, Table: event.Table})
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			s.applyClusterMessage(context.Background(), msg)
+		case <-done:
+			return
+		}
+	}
+}
+
+// applyClusterMessage 应用其他实例的变更：schema 从缓存中移除，下次读取时从存储加载；报表重新加载调度。
+// msg 为 nil 时可能错过了消息，清空缓存并重新加载全部报表
+func (s *Server) applyClusterMessage(ctx context.Context, msg *cluster.Message) {
+	switch {
+	case msg == nil:
+		if s.schemas != nil {
+			s.schemas.EvictAll()
+		}
+		if s.reports != nil {
+			if err := s.reports.Reload(ctx); err != nil {
+				s.logger.Warn("failed to reload reports", zap.Error(err))
+			}
+		}
+	case msg.Kind == cluster.KindSchema:
+		if s.schemas != nil {
+			s.schemas.Evict(msg.Project, msg.Table)
+		}
+	case msg.Kind == cluster.KindReport:
+		if s.reports == nil {
+			return
+		}
+		if err := s.reports.Refresh(ctx, msg.Owner, msg.Name); err != nil {
+			s.logger.Warn("failed to refresh report",
+				zap.String("owner", msg.Owner), zap.String("report", msg.Name), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/cluster"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestClusterSchemaInvalidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	// 两个实例共享同一存储，各自缓存 schema
+	group := cluster.NewGroup()
+	newInstance := func(name string) (*Server, *cluster.Member) {
+		member := group.Join(name)
+		server := NewServer(store, &Config{Schemas: models.NewSchemaRegistry(), Cluster: member})
+		go server.clusterLoop(server.done)
+		t.Cleanup(func() { server.Stop(ctx) })
+		return server, member
+	}
+	a, memberA := newInstance("a")
+	b, _ := newInstance("b")
+	do := func(server *Server, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	fieldCount := func(server *Server) int {
+		w := do(server, http.MethodGet, "/api/v1/schemas/app/events", "")
+		if w.Code != http.StatusOK {
+			return -1
+		}
+		var schema models.Schema
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schema))
+		return len(schema.Fields)
+	}
+
+	w := do(a, http.MethodPost, "/api/v1/schemas", `{"project":"app","table":"events",
+		"fields":[{"name":"user","type":"string"}]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, 1, fieldCount(b))
+	_, err := b.schemas.Get("app", "events")
+	require.NoError(t, err, "b caches the schema after reading it")
+
+	w = do(a, http.MethodPut, "/api/v1/schemas/app/events", `{"project":"app","table":"events",
+		"fields":[{"name":"user","type":"string"},{"name":"status","type":"int"}]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Eventually(t, func() bool { return fieldCount(b) == 2 }, time.Second, 10*time.Millisecond,
+		"b reloads the schema updated through a")
+
+	w = do(b, http.MethodDelete, "/api/v1/schemas/app/events", "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	require.Eventually(t, func() bool { return fieldCount(a) == -1 }, time.Second, 10*time.Millisecond,
+		"a forgets the schema deleted through b")
+
+	// 错过消息后清空缓存
+	require.NoError(t, b.schemas.Put(&models.Schema{Project: "app", Table: "stale"}))
+	b.applyClusterMessage(ctx, nil)
+	assert.Empty(t, b.schemas.List())
+
+	// 只有 leader 执行后台任务，leader 退出后由其他实例接替
+	assert.True(t, a.leading(ctx))
+	assert.False(t, b.leading(ctx))
+	require.NoError(t, memberA.Close())
+	assert.True(t, b.leading(ctx))
+	assert.True(t, NewServer(store, &Config{}).leading(ctx), "a single instance always leads")
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// CompareResponse 指定 compare 参数时持续聚合或 rollup 的查询结果
+type CompareResponse struct {
+	Offset   string                   `json:"offset"`   // 对比窗口相对当前窗口向前的偏移，即 compare 参数
+	Current  []map[string]interface{} `json:"current"`  // 当前窗口 [from, to) 的结果
+	Previous []map[string]interface{} `json:"previous"` // 前移 offset 的窗口的结果，时间桶已后移 offset 与 current 对齐
+}
+
+// seriesQuery 查询 [from, to) 内的持续聚合或 rollup 结果
+type seriesQuery func(ctx context.Context, from, to time.Time) ([]map[string]interface{}, error)
+
+// respondSeries 查询并按 tz、interval 重新划分时间桶后返回结果；
+// 指定 compare 时同时查询前移该偏移的窗口，返回对齐的两组结果
+func (s *Server) respondSeries(c *gin.Context, query seriesQuery,
+	find func(*models.Schema, string) (*models.Aggregate, bool)) {
+	from, to, ok := timeRange(c)
+	if !ok {
+		return
+	}
+	compare := c.Query("compare")
+	if compare == "" {
+		result, err := query(c.Request.Context(), from, to)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if result, ok = s.rebucket(c, result, find); !ok {
+			return
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	shift, ok := compareShift(c, compare)
+	if !ok {
+		return
+	}
+	if from.IsZero() {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "compare requires from")
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	resp := &CompareResponse{Offset: compare}
+	for _, window := range []struct {
+		from, to time.Time
+		rows     *[]map[string]interface{}
+	}{
+		{from, to, &resp.Current},
+		{shift(from, -1), shift(to, -1), &resp.Previous},
+	} {
+		result, err := query(c.Request.Context(), window.from, window.to)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if result, ok = s.rebucket(c, result, find); !ok {
+			return
+		}
+		*window.rows = nonNilRows(result)
+	}
+	if err := storage.ShiftBuckets(resp.Previous, func(t time.Time) time.Time { return shift(t, 1).In(t.Location()) }); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// compareShift 解析 compare 参数，返回按偏移前移（sign 为 -1）或后移（sign 为 1）时间的函数。
+// 整天的偏移按 tz 的日历日平移，跨夏令时切换时当地时间保持不变
+func compareShift(c *gin.Context, compare string) (func(t time.Time, sign int) time.Time, bool) {
+	offset, err := models.ParseRetention(compare)
+	if err != nil {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("invalid compare: %s", compare))
+		return nil, false
+	}
+	loc, ok := timeZone(c)
+	if !ok {
+		return nil, false
+	}
+	if offset%(24*time.Hour) == 0 {
+		days := int(offset / (24 * time.Hour))
+		return func(t time.Time, sign int) time.Time {
+			return t.In(loc).AddDate(0, 0, sign*days)
+		}, true
+	}
+	return func(t time.Time, sign int) time.Time {
+		return t.Add(time.Duration(sign) * offset)
+	}, true
+}
+
+// nonNilRows 将空结果转换为空列表，使响应中为 [] 而不是 null
+func nonNilRows(rows []map[string]interface{}) []map[string]interface{} {
+	if rows == nil {
+		return []map[string]interface{}{}
+	}
+	return rows
+}
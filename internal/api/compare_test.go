@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestCompareAggregate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	server := NewServer(store, &Config{})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "path", Type: models.FieldTypeString}},
+		SchemaOptions: models.SchemaOptions{
+			Aggregates: []*models.Aggregate{{Name: "hourly", Interval: "1h", Metrics: []*models.AggregateMetric{{Func: models.AggregateCount}}}},
+		},
+	}))
+	// 2024-03-10 美东进入夏令时，两周各取周二 10 点（当地时间）
+	current := time.Date(2024, 3, 12, 14, 0, 0, 0, time.UTC)
+	previous := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	for _, ts := range []time.Time{current, current.Add(time.Minute), current.Add(2 * time.Minute), previous} {
+		require.NoError(t, store.InsertLog(ctx, "app", "requests", &models.LogEntry{
+			Project: "app", Table: "requests", Level: "info", Message: "request", Timestamp: ts,
+			Fields: map[string]interface{}{"path": "/"},
+		}))
+	}
+
+	w := get("/api/v1/logs/app/requests/aggregates/hourly?from=2024-03-12T00:00:00Z&to=2024-03-13T00:00:00Z&compare=7d")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp CompareResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "7d", resp.Offset)
+	require.Len(t, resp.Current, 1)
+	assert.Equal(t, "2024-03-12T14:00:00Z", resp.Current[0]["bucket"])
+	assert.EqualValues(t, 3, resp.Current[0]["count"])
+	require.Len(t, resp.Previous, 1)
+	assert.Equal(t, "2024-03-12T15:00:00Z", resp.Previous[0]["bucket"], "shifted forward by seven days")
+	assert.EqualValues(t, 1, resp.Previous[0]["count"])
+
+	// 整天的偏移按 tz 的日历日平移，跨夏令时切换后按天的时间桶仍然对齐
+	w = get("/api/v1/logs/app/requests/aggregates/hourly?from=2024-03-12T00:00:00&to=2024-03-13T00:00:00&tz=America/New_York&interval=1d&compare=7d")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp = CompareResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Current, 1)
+	require.Len(t, resp.Previous, 1)
+	assert.Equal(t, "2024-03-12T00:00:00-04:00", resp.Current[0]["bucket"])
+	assert.Equal(t, resp.Current[0]["bucket"], resp.Previous[0]["bucket"])
+	assert.EqualValues(t, 1, resp.Previous[0]["count"])
+
+	w = get("/api/v1/logs/app/requests/aggregates/hourly?from=2024-03-13T00:00:00Z&compare=1h")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"offset": "1h", "current": [], "previous": []}`, w.Body.String())
+
+	w = get("/api/v1/logs/app/requests/aggregates/hourly?compare=7d")
+	assert.Equal(t, http.StatusBadRequest, w.Code, "compare requires from")
+	w = get("/api/v1/logs/app/requests/aggregates/hourly?from=2024-03-12T00:00:00Z&compare=week")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = get("/api/v1/logs/app/requests/aggregates/hourly?from=2024-03-12T00:00:00Z&to=2024-03-13T00:00:00Z")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rows))
+	assert.Len(t, rows, 1, "without compare the rows are returned as before")
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxDecompressedBody 解压后请求体的默认上限
+const DefaultMaxDecompressedBody = 64 << 20
+
+// zstdMaxWindow 解压 zstd 时允许的最大窗口，与 RFC 8878 建议的 8MB 一致，限制单个请求的内存占用
+const zstdMaxWindow = 8 << 20
+
+// 支持的内容编码
+const (
+	encodingGzip = "gzip"
+	encodingZstd = "zstd"
+)
+
+var (
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	zstdWriters = sync.Pool{New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedFastest))
+		return w
+	}}
+)
+
+// decompressBody 按 Content-Encoding 解压请求体，解压后超过 limit 字节时读取失败，limit 为 0 时不限制
+func decompressBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		var body io.ReadCloser
+		switch encoding {
+		case "", "identity":
+			c.Next()
+			return
+		case encodingGzip, "x-gzip":
+			r, err := gzip.NewReader(c.Request.Body)
+			if err != nil {
+				badRequest(c, fmt.Errorf("invalid gzip body: %w", err))
+				c.Abort()
+				return
+			}
+			body = r
+		case encodingZstd:
+			r, err := zstd.NewReader(c.Request.Body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+			if err != nil {
+				badRequest(c, fmt.Errorf("invalid zstd body: %w", err))
+				c.Abort()
+				return
+			}
+			body = r.IOReadCloser()
+		default:
+			c.Header("Accept-Encoding", "gzip, zstd")
+			respondStatus(c, http.StatusUnsupportedMediaType, CodeBadRequest, fmt.Sprintf("unsupported content encoding: %s", encoding))
+			c.Abort()
+			return
+		}
+		defer body.Close()
+
+		c.Request.Body = body
+		if limit > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, body, limit)
+		}
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Request.ContentLength = -1
+		c.Next()
+	}
+}
+
+// compressResponse 按 Accept-Encoding 压缩响应体，zstd 优先于 gzip
+func compressResponse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = w
+		c.Next()
+		w.close()
+		c.Writer = w.ResponseWriter
+	}
+}
+
+// negotiateEncoding 从 Accept-Encoding 中选出权重最高的可用编码，都不可用时返回空字符串
+func negotiateEncoding(header string) string {
+	var best string
+	var bestQ float64
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == "*" {
+			name = encodingZstd
+		}
+		if (name != encodingGzip && name != encodingZstd) || q <= 0 {
+			continue
+		}
+		// 权重相同时优先 zstd
+		if q > bestQ || (q == bestQ && name == encodingZstd) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter 在第一次写入响应体时创建压缩器，没有响应体时不设置 Content-Encoding
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	encoder  io.WriteCloser
+}
+
+// start 设置响应头并创建压缩器
+func (w *compressWriter) start() {
+	if w.encoder != nil {
+		return
+	}
+	w.Header().Set("Content-Encoding", w.encoding)
+	w.Header().Del("Content-Length")
+	switch w.encoding {
+	case encodingGzip:
+		gz := gzipWriters.Get().(*gzip.Writer)
+		gz.Reset(w.ResponseWriter)
+		w.encoder = gz
+	case encodingZstd:
+		zw := zstdWriters.Get().(*zstd.Encoder)
+		zw.Reset(w.ResponseWriter)
+		w.encoder = zw
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	w.start()
+	return w.encoder.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 刷出已压缩的数据，用于流式响应
+func (w *compressWriter) Flush() {
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close 写出压缩尾部并归还压缩器
+func (w *compressWriter) close() {
+	if w.encoder == nil {
+		return
+	}
+	w.encoder.Close()
+	switch encoder := w.encoder.(type) {
+	case *gzip.Writer:
+		encoder.Reset(io.Discard)
+		gzipWriters.Put(encoder)
+	case *zstd.Encoder:
+		encoder.Reset(nil)
+		zstdWriters.Put(encoder)
+	}
+	w.encoder = nil
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "zstd", negotiateEncoding("gzip, deflate, br, zstd"))
+	assert.Equal(t, "gzip", negotiateEncoding("gzip, zstd;q=0.5"))
+	assert.Equal(t, "gzip", negotiateEncoding("gzip;q=1.0, zstd;q=0"))
+	assert.Equal(t, "zstd", negotiateEncoding("*"))
+	assert.Empty(t, negotiateEncoding("br, deflate"))
+	assert.Empty(t, negotiateEncoding(""))
+}
+
+func TestDecompressBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/echo", decompressBody(64), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			badRequest(c, err)
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+	send := func(encoding string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", encoding)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(`[{"message":"a"}]`))
+	gw.Close()
+	w := send("gzip", gz.Bytes())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[{"message":"a"}]`, w.Body.String())
+
+	zw, _ := zstd.NewWriter(nil)
+	w = send("zstd", zw.EncodeAll([]byte(`[{"message":"b"}]`), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[{"message":"b"}]`, w.Body.String())
+
+	w = send("", []byte("plain"))
+	assert.Equal(t, "plain", w.Body.String())
+
+	w = send("br", []byte("x"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	w = send("gzip", []byte("not gzip"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 解压后超过上限
+	w = send("zstd", zw.EncodeAll([]byte(strings.Repeat("a", 65)), nil))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), string(CodePayloadTooLarge))
+}
+
+func TestCompressResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	payload := strings.Repeat(`{"message":"hello"},`, 100)
+	router.GET("/logs", compressResponse(), func(c *gin.Context) { c.String(http.StatusOK, payload) })
+	router.GET("/empty", compressResponse(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/logs", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	gr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(body))
+
+	// 连续请求复用压缩器
+	for i := 0; i < 2; i++ {
+		w = get("/logs", "gzip, zstd")
+		assert.Equal(t, "zstd", w.Header().Get("Content-Encoding"))
+		zr, err := zstd.NewReader(w.Body)
+		require.NoError(t, err)
+		body, err = io.ReadAll(zr)
+		zr.Close()
+		require.NoError(t, err)
+		assert.Equal(t, payload, string(body))
+	}
+
+	w = get("/logs", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, payload, w.Body.String())
+
+	w = get("/empty", "gzip")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Zero(t, w.Body.Len())
+}
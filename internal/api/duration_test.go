@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestDurationRoundTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store, err := storage.New(ctx, storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "name", Type: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeDuration},
+		},
+	}))
+	server := NewServer(store, &Config{StorageType: "sqlite"})
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	// 数字与整数字符串为纳秒，其余字符串按 Go 持续时间解析
+	for _, body := range []string{
+		`{"level": "info", "message": "request", "name": "number", "latency": 1500}`,
+		`{"level": "info", "message": "request", "name": "integer string", "latency": "1500"}`,
+		`{"level": "info", "message": "request", "name": "go duration", "latency": "1.5s"}`,
+	} {
+		w := post("/api/v1/logs/app/requests", body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+	w := post("/api/v1/logs/app/requests", `{"level": "info", "message": "request", "name": "fraction", "latency": 1.5}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	search := func(filter string) map[string]float64 {
+		w := post("/api/v1/logs/app/requests/search", `{"filter": `+filter+`, "fields": ["name", "latency"]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Entries []map[string]interface{} `json:"entries"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		latencies := map[string]float64{}
+		for _, entry := range resp.Entries {
+			latencies[entry["name"].(string)] = entry["latency"].(float64)
+		}
+		return latencies
+	}
+
+	assert.Equal(t, map[string]float64{"number": 1500, "integer string": 1500}, search(`{"latency": 1500}`))
+	assert.Equal(t, map[string]float64{"go duration": 1.5e9}, search(`{"latency": "1.5s"}`))
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// ErrorCode 机器可读的错误码
+type ErrorCode string
+
+const (
+	CodeBadRequest         ErrorCode = "bad_request"         // 请求体或参数无法解析
+	CodeValidation         ErrorCode = "validation_failed"   // schema、查询或日志未通过校验
+	CodeNotFound           ErrorCode = "not_found"           // schema、归档、保存查询、报表或问题不存在
+	CodeConflict           ErrorCode = "conflict"            // If-Match 与当前版本不一致，或恢复的 schema 已存在
+	CodeReadOnly           ErrorCode = "read_only"           // 服务器或项目处于只读模式
+	CodeNotImplemented     ErrorCode = "not_implemented"     // 存储或配置不支持该功能
+	CodeBackendUnavailable ErrorCode = "backend_unavailable" // 存储后端无法连接
+	CodeDeliveryFailed     ErrorCode = "delivery_failed"     // 报表投递失败
+	CodePayloadTooLarge    ErrorCode = "payload_too_large"   // 解压后的请求体超过上限
+	CodeMutationLimit      ErrorCode = "mutation_limit"      // 删除或修改日志匹配的条数超过上限
+	CodeQueryTimeout       ErrorCode = "query_timeout"       // 查询超过 QueryTimeout
+	CodeTimeRangeRequired  ErrorCode = "time_range_required" // 大表的查询未指定 from 或 to
+	CodeInternal           ErrorCode = "internal"            // 其他服务端错误
+)
+
+// ErrorResponse 统一的错误响应体，error 为可读信息，code 供客户端判断错误类型
+type ErrorResponse struct {
+	Error  string               `json:"error"`
+	Code   ErrorCode            `json:"code"`
+	Fields []*models.FieldError `json:"fields,omitempty"` // 日志校验失败时的全部字段错误
+
+	// Accepted 分块写入的批量日志中途失败时，之前已提交的日志条数
+	Accepted int `json:"accepted,omitempty"`
+}
+
+// classifyError 根据错误链确定状态码与错误码
+func classifyError(err error) (int, ErrorCode) {
+	switch {
+	case errors.Is(err, models.ErrValidation):
+		return http.StatusUnprocessableEntity, CodeValidation
+	case errors.Is(err, models.ErrSchemaNotFound),
+		errors.Is(err, models.ErrSavedQueryNotFound),
+		errors.Is(err, models.ErrReportNotFound),
+		errors.Is(err, models.ErrIssueNotFound),
+		errors.Is(err, models.ErrArchivedSchemaNotFound):
+		return http.StatusNotFound, CodeNotFound
+	case errors.Is(err, models.ErrSchemaExists):
+		return http.StatusConflict, CodeConflict
+	case errors.Is(err, models.ErrMutationLimit):
+		return http.StatusUnprocessableEntity, CodeMutationLimit
+	case errors.Is(err, storage.ErrBackendUnavailable):
+		return http.StatusServiceUnavailable, CodeBackendUnavailable
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
+
+// respondError 按错误类型返回 404、409、422、503 或 500
+func respondError(c *gin.Context, err error) {
+	status, code := classifyError(err)
+	c.JSON(status, ErrorResponse{Error: err.Error(), Code: code, Fields: models.FieldErrors(err)})
+}
+
+// respondStoreError 批量写入失败时返回错误，accepted 条日志已在之前的分块中提交
+func respondStoreError(c *gin.Context, err error, accepted int) {
+	status, code := classifyError(err)
+	c.JSON(status, ErrorResponse{Error: err.Error(), Code: code, Fields: models.FieldErrors(err), Accepted: accepted})
+}
+
+// respondStatus 返回指定状态码与错误码
+func respondStatus(c *gin.Context, status int, code ErrorCode, message string) {
+	c.JSON(status, ErrorResponse{Error: message, Code: code})
+}
+
+// badRequest 请求体或参数无法解析时返回 400，请求体超过上限时返回 413
+func badRequest(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondStatus(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, err.Error())
+		return
+	}
+	respondStatus(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+}
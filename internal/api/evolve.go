@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// schemaWebhookTimeout schema 变更通知的超时时间
+const schemaWebhookTimeout = 10 * time.Second
+
+// SchemaEvolvedEvent auto_evolve 自动添加字段后发送到 SchemaWebhook 的通知
+type SchemaEvolvedEvent struct {
+	Event     string          `json:"event"` // 固定为 schema.evolved
+	Project   string          `json:"project"`
+	Table     string          `json:"table"`
+	Fields    []*models.Field `json:"fields"` // 新添加的字段
+	ETag      string          `json:"etag"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// evolveSchema 为开启 auto_evolve 且没有 Rest 字段的 schema 添加日志中出现的未知字段，
+// 返回添加字段后的 schema；无需变更时原样返回
+func (s *Server) evolveSchema(ctx context.Context, schema *models.Schema, rawData map[string]interface{}) (*models.Schema, error) {
+	if !schema.AutoEvolve || schema.RestField() != nil || len(schema.NewFields(rawData)) == 0 {
+		return schema, nil
+	}
+
+	s.schemaMu.Lock()
+	defer s.schemaMu.Unlock()
+
+	// 基于最新版本添加，其他请求可能已经添加了部分字段
+	current, err := s.storage.GetSchema(ctx, schema.Project, schema.Table)
+	if err != nil {
+		return nil, err
+	}
+	if !current.AutoEvolve || current.RestField() != nil {
+		return current, nil
+	}
+	fields := current.NewFields(rawData)
+	if len(fields) == 0 {
+		return current, nil
+	}
+
+	patch := &models.SchemaPatch{Operations: make([]models.PatchOp, len(fields))}
+	for i, field := range fields {
+		patch.Operations[i] = models.PatchOp{Op: models.PatchAddField, Field: field}
+	}
+	if err := current.ApplyPatch(patch); err != nil {
+		return nil, fmt.Errorf("auto evolve schema: %w", err)
+	}
+	current.UpdatedAt = time.Now()
+	if err := s.storage.UpdateSchema(ctx, current); err != nil {
+		return nil, fmt.Errorf("auto evolve schema: %w", err)
+	}
+	s.syncSchemaFile(current)
+
+	s.notifySchemaEvolved(&SchemaEvolvedEvent{
+		Event:     "schema.evolved",
+		Project:   current.Project,
+		Table:     current.Table,
+		Fields:    fields,
+		ETag:      current.ETag(),
+		UpdatedAt: current.UpdatedAt,
+	})
+	return current, nil
+}
+
+// notifySchemaEvolved 异步将 schema 变更通知发送到 SchemaWebhook，失败时只记录日志
+func (s *Server) notifySchemaEvolved(event *SchemaEvolvedEvent) {
+	if s.schemaWebhook == "" {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		s.logger.Error("failed to encode schema webhook", zap.Error(err))
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), schemaWebhookTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.schemaWebhook, bytes.NewReader(body))
+		if err != nil {
+			s.logger.Warn("failed to send schema webhook", zap.String("url", s.schemaWebhook), zap.Error(err))
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			s.logger.Warn("failed to send schema webhook", zap.String("url", s.schemaWebhook), zap.Error(err))
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			s.logger.Warn("failed to send schema webhook", zap.String("url", s.schemaWebhook), zap.String("status", resp.Status))
+		}
+	}()
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestAutoEvolveSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	events := make(chan SchemaEvolvedEvent, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event SchemaEvolvedEvent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events <- event
+	}))
+	defer webhook.Close()
+	server := NewServer(store, &Config{SchemaWebhook: webhook.URL})
+
+	for _, table := range []string{"evolving", "fixed"} {
+		require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+			Project: "app",
+			Table:   table,
+			Fields: []*models.Field{
+				{Name: "level", Type: models.FieldTypeString, Required: true},
+				{Name: "message", Type: models.FieldTypeString, Required: true},
+			},
+			SchemaOptions: models.SchemaOptions{AutoEvolve: table == "evolving"},
+		}))
+	}
+	insert := func(table, body string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/"+table, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	body := `{"level": "info", "message": "m", "latency": 1.5, "user": "bob", "bad-name": 1, "empty": null}`
+	insert("evolving", body)
+	insert("fixed", body)
+
+	schema, err := store.GetSchema(ctx, "app", "evolving")
+	require.NoError(t, err)
+	require.Len(t, schema.Fields, 4)
+	assert.Equal(t, &models.Field{Name: "latency", Type: models.FieldTypeFloat}, schema.GetField("latency"))
+	assert.Equal(t, &models.Field{Name: "user", Type: models.FieldTypeString}, schema.GetField("user"))
+
+	select {
+	case event := <-events:
+		assert.Equal(t, "schema.evolved", event.Event)
+		assert.Equal(t, "evolving", event.Table)
+		assert.Equal(t, schema.ETag(), event.ETag)
+		require.Len(t, event.Fields, 2)
+		assert.Equal(t, "latency", event.Fields[0].Name)
+		assert.Equal(t, "user", event.Fields[1].Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("schema webhook was not called")
+	}
+
+	logs, err := store.QueryLogs(ctx, "app", "evolving", nil, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "bob", logs[0]["user"])
+
+	// 已添加的字段不再触发变更
+	insert("evolving", `{"level": "info", "message": "m", "user": "alice"}`)
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected schema webhook: %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 未开启 auto_evolve 时未知字段仍被丢弃
+	fixed, err := store.GetSchema(ctx, "app", "fixed")
+	require.NoError(t, err)
+	assert.Len(t, fixed.Fields, 2)
+}
+
+func TestStrictSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	server := NewServer(store, &Config{})
+
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields: []*models.Field{
+			{Name: "level", Type: models.FieldTypeString, Required: true},
+			{Name: "message", Type: models.FieldTypeString, Required: true},
+			{Name: "user", Type: models.FieldTypeString},
+		},
+		SchemaOptions: models.SchemaOptions{Strict: true},
+	}))
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/events"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("", `{"level": "info", "message": "m", "user": "bob"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = post("", `{"level": "info", "message": "m", "usr": "bob", "latency": 1.5}`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Fields, 2)
+	assert.Equal(t, "latency", resp.Fields[0].Field)
+	assert.Equal(t, models.FieldErrorUnknown, resp.Fields[0].Reason)
+	assert.Equal(t, "usr", resp.Fields[1].Field)
+
+	w = post("/batch", `[{"level": "info", "message": "a"}, {"level": "info", "message": "b", "usr": "bob"}]`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "usr")
+
+	logs, err := store.QueryLogs(ctx, "app", "events", nil, 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// explainQuery 返回请求体中的查询在存储后端的 SQL 与执行计划，请求体与 search 相同，
+// limit 按 search 的方式应用默认值与 MaxQueryRows，计划与 search 实际执行的查询一致。
+// analyze=true 时实际执行查询并给出耗时，受 QueryTimeout 限制；不支持的存储返回 501
+func (s *Server) explainQuery(c *gin.Context) {
+	explainer, ok := storage.As[storage.QueryExplainer](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "storage does not support query plans")
+		return
+	}
+
+	var query models.Query
+	if err := c.ShouldBindJSON(&query); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultSearchLimit
+	}
+	if query.Limit < 0 || query.Offset < 0 {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "limit and offset must not be negative")
+		return
+	}
+	analyze := false
+	if value := c.Query("analyze"); value != "" {
+		var err error
+		if analyze, err = strconv.ParseBool(value); err != nil {
+			respondStatus(c, http.StatusBadRequest, CodeBadRequest, "analyze must be a boolean")
+			return
+		}
+	}
+	bound, err := query.Bind(nil)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	project, table := c.Param("project"), c.Param("table")
+	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := bound.Validate(schema, storage.BaseColumns(s.storage, schema)); err != nil {
+		respondError(c, err)
+		return
+	}
+	s.guard.limit(bound)
+
+	ctx, cancel := s.guard.context(c.Request.Context())
+	defer cancel()
+	plan, err := explainer.ExplainQuery(ctx, project, table, bound, analyze)
+	if err != nil {
+		s.respondQueryError(c, ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
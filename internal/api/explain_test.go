@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestExplainQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "trace_id", Type: models.FieldTypeString, Indexed: true}},
+	}))
+
+	server := NewServer(store, &Config{MaxQueryRows: 50})
+	explain := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := explain("/api/v1/admin/logs/app/requests/explain", `{"filter":{"trace_id":"t1"},"limit":1000}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var plan models.QueryPlan
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plan))
+	assert.Equal(t, "sqlite", plan.Dialect)
+	// limit 与 search 一样受 MaxQueryRows 限制
+	assert.Contains(t, plan.SQL, "LIMIT 51 OFFSET 0")
+	assert.Equal(t, []interface{}{"t1"}, plan.Args)
+	assert.Contains(t, strings.Join(plan.Plan, "\n"), "USING INDEX")
+
+	// 查询按 schema 校验
+	w = explain("/api/v1/admin/logs/app/requests/explain", `{"fields":["missing"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	w = explain("/api/v1/admin/logs/app/missing/explain", `{}`)
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	w = explain("/api/v1/admin/logs/app/requests/explain?analyze=maybe", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	// SQLite 不支持 analyze
+	w = explain("/api/v1/admin/logs/app/requests/explain?analyze=true", `{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	// 不支持的存储返回 501
+	server = NewServer(&storageWithoutSizes{store}, &Config{})
+	w = explain("/api/v1/admin/logs/app/requests/explain", `{}`)
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
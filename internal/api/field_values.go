@@ -0,0 +1,82 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// maxFieldValuesLimit 单次最多返回的取值个数
+const maxFieldValuesLimit = 1000
+
+// fieldValues 返回时间范围内字段出现最多的取值及次数，用于构建过滤下拉框
+func (s *Server) fieldValues(c *gin.Context) {
+	querier, ok := storage.As[storage.FieldValuesQuerier](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "field values are not supported by this storage")
+		return
+	}
+	from, to, ok := timeRange(c)
+	if !ok {
+		return
+	}
+	limit := storage.DefaultFieldValuesLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			respondStatus(c, http.StatusBadRequest, CodeBadRequest, "invalid limit: "+value)
+			return
+		}
+		limit = n
+	}
+	if limit > maxFieldValuesLimit {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("limit must not exceed %d", maxFieldValuesLimit))
+		return
+	}
+
+	query := &models.Query{Limit: limit}
+	if !from.IsZero() {
+		query.From = &from
+	}
+	if !to.IsZero() {
+		query.To = &to
+	}
+
+	project, table, field := c.Param("project"), c.Param("table"), c.Param("field")
+	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if _, err := schema.FacetField(field); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := query.Validate(schema, storage.BaseColumns(s.storage, schema)); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := s.guard.checkRange(c, project, table, query); err != nil {
+		s.respondQueryError(c, c.Request.Context(), err)
+		return
+	}
+	ctx, cancel := s.guard.context(c.Request.Context())
+	defer cancel()
+	start := time.Now()
+	values, err := querier.FieldValues(ctx, project, table, field, query)
+	var rows int
+	if values != nil {
+		rows = len(values.Values)
+	}
+	s.recordSlowQuery(c, project, table, query, time.Since(start), rows, err)
+	if err != nil {
+		s.respondQueryError(c, ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, values)
+}
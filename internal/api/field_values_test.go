@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestFieldValues(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "path", Type: models.FieldTypeString},
+			{Name: "request", Type: models.FieldTypeObject, Fields: []*models.Field{{Name: "host", Type: models.FieldTypeString}}},
+		},
+	}))
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, path := range []string{"/a", "/b", "/a", "/c", "/a", "/b"} {
+		require.NoError(t, store.InsertLog(ctx, "app", "requests", &models.LogEntry{
+			Project:   "app",
+			Table:     "requests",
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Level:     "info",
+			Message:   "request",
+			Fields:    map[string]interface{}{"path": path},
+		}))
+	}
+
+	server := NewServer(store, &Config{})
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/v1/logs/app/requests/fields/path/values?limit=2")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var values models.FieldValues
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &values))
+	assert.Equal(t, int64(6), values.Total)
+	assert.Equal(t, int64(3), values.Distinct)
+	assert.False(t, values.Approximate)
+	assert.Equal(t, []models.FieldValue{{Value: "/a", Count: 3}, {Value: "/b", Count: 2}}, values.Values)
+
+	w = get("/api/v1/logs/app/requests/fields/path/values?from=2024-05-01T00:03:00Z&to=2024-05-01T00:05:00Z")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	values = models.FieldValues{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &values))
+	assert.Equal(t, []models.FieldValue{{Value: "/a", Count: 1}, {Value: "/c", Count: 1}}, values.Values)
+
+	w = get("/api/v1/logs/app/requests/fields/missing/values")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	w = get("/api/v1/logs/app/requests/fields/request/values")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	w = get("/api/v1/logs/app/requests/fields/path/values?limit=0")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = get("/api/v1/logs/app/requests/fields/path/values?limit=1001")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = get("/api/v1/logs/app/requests/fields/path/values?from=2024-05-02T00:00:00Z&to=2024-05-01T00:00:00Z")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = get("/api/v1/logs/app/missing/fields/path/values")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 不支持的存储返回 501
+	server = NewServer(&storageWithoutSizes{store}, &Config{})
+	w = get("/api/v1/logs/app/requests/fields/path/values")
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
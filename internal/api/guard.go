@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+const (
+	// DefaultQueryTimeout 单次查询默认的最长执行时间
+	DefaultQueryTimeout = 30 * time.Second
+	// DefaultMaxQueryRows 单次查询默认最多返回的行数
+	DefaultMaxQueryRows = 10000
+	// tableRowsTTL 判断大表使用的行数估算的缓存时间
+	tableRowsTTL = 5 * time.Minute
+)
+
+// 查询结果不完整的原因，见响应的 partial_reason
+const (
+	PartialMaxRows = "max_rows" // 匹配的日志超过 MaxQueryRows，只返回了前 MaxQueryRows 条
+	PartialTimeout = "timeout"  // 跨表查询超过 QueryTimeout，只返回了已查询完成的表
+)
+
+// errTimeRangeRequired 大表的查询未指定时间范围
+var errTimeRangeRequired = errors.New("time range required")
+
+// queryGuard 限制查询的执行时间与返回行数，并要求大表的查询指定时间范围，避免失控的查询拖垮存储
+type queryGuard struct {
+	timeout     time.Duration // 0 表示不限制
+	maxRows     int
+	rangeRows   int64 // 0 表示不检查
+	storage     storage.Storage
+	logger      *zap.Logger
+	mu          sync.Mutex
+	rows        map[string]int64 // project/table -> 估算行数
+	rowsExpires time.Time
+}
+
+// context 返回带查询超时的 context
+func (g *queryGuard) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, g.timeout)
+}
+
+// timedOut 判断查询是否因超过 QueryTimeout 而失败，请求本身被取消时返回 false
+func (g *queryGuard) timedOut(ctx, parent context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil
+}
+
+// limit 将请求的行数限制在 maxRows 内，超过时多取一条用于判断结果是否被截断，返回 true
+func (g *queryGuard) limit(query *models.Query) bool {
+	if query.Limit <= g.maxRows {
+		return false
+	}
+	query.Limit = g.maxRows + 1
+	return true
+}
+
+// truncate 截断多取的行，返回结果是否不完整
+func (g *queryGuard) truncate(rows []map[string]interface{}) ([]map[string]interface{}, bool) {
+	if len(rows) > g.maxRows {
+		return rows[:g.maxRows], true
+	}
+	return rows, false
+}
+
+// checkRange 估算行数不少于 rangeRows 的表的查询必须指定 from 或 to，请求带 allow_full_scan=true 时跳过
+func (g *queryGuard) checkRange(c *gin.Context, project, table string, query *models.Query) error {
+	if g.rangeRows <= 0 || query.From != nil || query.To != nil {
+		return nil
+	}
+	if override, _ := strconv.ParseBool(c.Query("allow_full_scan")); override {
+		return nil
+	}
+	rows := g.tableRows(c.Request.Context(), project, table)
+	if rows < g.rangeRows {
+		return nil
+	}
+	return fmt.Errorf("%w: %s_%s has about %d rows, set from or to, or allow_full_scan=true", errTimeRangeRequired, project, table, rows)
+}
+
+// tableRows 返回表的估算行数，过期时从存储重新读取全部表的大小；存储不支持或读取失败时返回 0，不阻止查询
+func (g *queryGuard) tableRows(ctx context.Context, project, table string) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now := time.Now(); now.After(g.rowsExpires) {
+		g.rowsExpires = now.Add(tableRowsTTL)
+		g.rows = nil
+		reporter, ok := storage.As[storage.SizeReporter](g.storage)
+		if !ok {
+			return 0
+		}
+		sizes, err := reporter.TableSizes(ctx)
+		if err != nil {
+			g.logger.Warn("failed to estimate table sizes for query guard", zap.Error(err))
+			return 0
+		}
+		g.rows = make(map[string]int64, len(sizes))
+		for _, size := range sizes {
+			g.rows[size.Project+"/"+size.Table] = size.Rows
+		}
+	}
+	return g.rows[project+"/"+table]
+}
+
+// respondQueryError 查询超过 QueryTimeout 时返回 504，未指定时间范围时返回 400，其余按 respondError
+func (s *Server) respondQueryError(c *gin.Context, ctx context.Context, err error) {
+	switch {
+	case s.guard.timedOut(ctx, c.Request.Context()):
+		respondStatus(c, http.StatusGatewayTimeout, CodeQueryTimeout,
+			fmt.Sprintf("query exceeded %s, narrow the time range or filters", s.guard.timeout))
+	case errors.Is(err, errTimeRangeRequired):
+		respondStatus(c, http.StatusBadRequest, CodeTimeRangeRequired, err.Error())
+	default:
+		respondError(c, err)
+	}
+}
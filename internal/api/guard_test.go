@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestQueryGuard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "trace_id", Type: models.FieldTypeString, Indexed: true},
+			{Name: "message", Type: models.FieldTypeString},
+		},
+	}))
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.InsertLog(ctx, "app", "requests", &models.LogEntry{
+			Project: "app", Table: "requests", Level: "info", Message: "request",
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Fields:    map[string]interface{}{"trace_id": "t1"},
+		}))
+	}
+	server := NewServer(store, &Config{MaxQueryRows: 2, RangeRequiredRows: 5})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	var resp searchResponse
+
+	// 大表的查询需要时间范围
+	w := do(http.MethodPost, "/api/v1/logs/app/requests/search", `{}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), CodeTimeRangeRequired)
+	w = do(http.MethodGet, "/api/v1/logs/app/requests/patterns", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	// 超过 MaxQueryRows 时截断并标记 partial
+	w = do(http.MethodPost, "/api/v1/logs/app/requests/search?allow_full_scan=true", `{"limit": 10}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Count)
+	assert.True(t, resp.Partial)
+	assert.Equal(t, PartialMaxRows, resp.PartialReason)
+
+	w = do(http.MethodPost, "/api/v1/logs/app/requests/search", `{"from": "2024-01-02T03:07:00Z", "limit": 10}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp = searchResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Count)
+	assert.False(t, resp.Partial, "exactly MaxQueryRows entries match")
+	assert.Empty(t, resp.PartialReason)
+
+	w = do(http.MethodGet, "/api/v1/trace/t1?limit=10", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var trace correlatedResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trace))
+	assert.Equal(t, 2, trace.Count)
+	assert.Equal(t, PartialMaxRows, trace.PartialReason)
+}
+
+// slowQuerier 查询一直阻塞到 context 结束
+type slowQuerier struct {
+	storage.Storage
+}
+
+func (s *slowQuerier) QueryLogs(ctx context.Context, _, _ string, _ map[string]interface{}, _, _ int) ([]map[string]interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowQuerier) SearchLogs(ctx context.Context, _, _ string, _ *models.Query) ([]map[string]interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestQueryGuardTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "trace_id", Type: models.FieldTypeString, Indexed: true}},
+	}))
+	server := NewServer(&slowQuerier{store}, &Config{QueryTimeout: 10 * time.Millisecond})
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/requests/search", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), CodeQueryTimeout)
+
+	// 跨表查询超时时返回已完成的部分
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/trace/t1", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var trace correlatedResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trace))
+	assert.True(t, trace.Partial)
+	assert.Equal(t, PartialTimeout, trace.PartialReason)
+	assert.Empty(t, trace.Tables)
+}
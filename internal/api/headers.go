@@ -0,0 +1,52 @@
+package api
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// TLS 指纹字段，schema 声明后写入日志时由请求头自动填充
+const (
+	FieldJA4        = "ja4"
+	FieldJA4String  = "ja4_string"
+	FieldTLSVersion = "tls_version"
+)
+
+// DefaultTLSFingerprintHeaders 默认的字段与请求头对应关系，请求头通常由计算 JA4 的反向代理设置
+var DefaultTLSFingerprintHeaders = map[string]string{
+	FieldJA4:        "X-JA4",
+	FieldJA4String:  "X-JA4-String",
+	FieldTLSVersion: "X-TLS-Version",
+}
+
+// applyFingerprintHeaders 为 schema 中声明的指纹字段填入对应请求头的值，请求头优先于请求体中的同名字段。
+// 请求头缺失时 tls_version 取服务端直接终止 TLS 时协商的版本
+func (s *Server) applyFingerprintHeaders(r *http.Request, schema *models.Schema, rawData map[string]interface{}) {
+	for field, header := range s.fingerprintHeaders {
+		if schema.GetField(field) == nil {
+			continue
+		}
+		value := r.Header.Get(header)
+		if value == "" && field == FieldTLSVersion && r.TLS != nil {
+			value = tls.VersionName(r.TLS.Version)
+		}
+		if value != "" {
+			rawData[field] = value
+		}
+	}
+}
+
+// applyCapturedHeaders 按 schema 的 capture_headers 将请求头复制到字段，请求体中已有该字段时保留请求体的值
+func applyCapturedHeaders(r *http.Request, schema *models.Schema, rawData map[string]interface{}) {
+	for _, capture := range schema.CaptureHeaders {
+		field := capture.FieldName()
+		if _, ok := rawData[field]; ok {
+			continue
+		}
+		if value := r.Header.Get(capture.Header); value != "" {
+			rawData[field] = value
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestInsertLogTLSFingerprint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project:       "app",
+		Table:         "access",
+		SchemaOptions: models.SchemaOptions{Strict: true},
+		Fields: []*models.Field{
+			{Name: "ip", Type: models.FieldTypeString},
+			{Name: FieldJA4, Type: models.FieldTypeString},
+			{Name: FieldTLSVersion, Type: models.FieldTypeString},
+		},
+	}))
+
+	server := NewServer(store, &Config{})
+	insert := func(body string, headers map[string]string) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/access", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		rows, err := store.SearchLogs(ctx, "app", "access", &models.Query{Fields: []string{FieldJA4, FieldTLSVersion}, Sort: []string{"-timestamp"}, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		return rows[0]
+	}
+
+	// 未声明的 ja4_string 不写入，strict schema 也不因此拒绝
+	row := insert(`{"level":"info","message":"hi","ja4":"spoofed"}`, map[string]string{
+		"X-JA4":         "t13d1516h2_8daaf6152771_b186095e22b6",
+		"X-JA4-String":  "t13d1516h2_002f,0035_0403,0804",
+		"X-TLS-Version": "TLS 1.3",
+	})
+	assert.Equal(t, "t13d1516h2_8daaf6152771_b186095e22b6", row[FieldJA4])
+	assert.Equal(t, "TLS 1.3", row[FieldTLSVersion])
+
+	// 请求头缺失时保留请求体中的值
+	row = insert(`{"level":"info","message":"hi","ja4":"from-body"}`, nil)
+	assert.Equal(t, "from-body", row[FieldJA4])
+	assert.Nil(t, row[FieldTLSVersion])
+}
+
+func TestInsertLogCaptureHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "access",
+		Fields: []*models.Field{
+			{Name: "user_agent", Type: models.FieldTypeString},
+			{Name: "correlation_id", Type: models.FieldTypeString},
+		},
+		SchemaOptions: models.SchemaOptions{CaptureHeaders: []*models.HeaderCapture{
+			{Header: "User-Agent"},
+			{Header: "X-Request-ID", Field: "correlation_id"},
+		}},
+	}))
+
+	server := NewServer(store, &Config{})
+	insert := func(body string) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/access", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "curl/8.5.0")
+		req.Header.Set("X-Request-ID", "req-1")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		rows, err := store.SearchLogs(ctx, "app", "access", &models.Query{Fields: []string{"user_agent", "correlation_id"}, Sort: []string{"-timestamp"}, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		return rows[0]
+	}
+
+	row := insert(`{"level":"info","message":"hi"}`)
+	assert.Equal(t, "curl/8.5.0", row["user_agent"])
+	assert.Equal(t, "req-1", row["correlation_id"])
+
+	// 请求体中的值优先
+	row = insert(`{"level":"info","message":"hi","user_agent":"Mozilla/5.0"}`)
+	assert.Equal(t, "Mozilla/5.0", row["user_agent"])
+}
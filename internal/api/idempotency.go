@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+// DefaultIdempotencyTTL 幂等键的默认保留时间
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKey 幂等键的最大长度
+const maxIdempotencyKey = 255
+
+// idempotencyEntry 幂等键的处理状态，status 为 0 表示请求仍在处理中
+type idempotencyEntry struct {
+	status  int
+	expires time.Time
+}
+
+// idempotencyStore 记录已处理的幂等键，只保存在当前进程内
+type idempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	clock     clock.Clock
+	entries   map[string]*idempotencyEntry
+	nextSweep time.Time
+}
+
+// newIdempotencyStore 创建幂等键存储，ttl 不大于 0 时使用 DefaultIdempotencyTTL
+func newIdempotencyStore(ttl time.Duration, c clock.Clock) *idempotencyStore {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	return &idempotencyStore{ttl: ttl, clock: clock.OrReal(c), entries: make(map[string]*idempotencyEntry)}
+}
+
+// begin 登记幂等键。键已完成时返回记录的状态码；键正在处理时返回 inFlight；否则登记为处理中
+func (s *idempotencyStore) begin(key string) (status int, inFlight bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.sweep(now)
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expires) {
+		return entry.status, entry.status == 0
+	}
+	s.entries[key] = &idempotencyEntry{expires: now.Add(s.ttl)}
+	return 0, false
+}
+
+// finish 请求成功时记录状态码，失败时释放幂等键以便客户端重试
+func (s *idempotencyStore) finish(key string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status < 200 || status >= 300 {
+		delete(s.entries, key)
+		return
+	}
+	s.entries[key] = &idempotencyEntry{status: status, expires: s.clock.Now().Add(s.ttl)}
+}
+
+// sweep 每分钟最多清理一次过期的键
+func (s *idempotencyStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(time.Minute)
+	for key, entry := range s.entries {
+		if entry.status != 0 && !now.Before(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// idempotent 处理 Idempotency-Key 请求头：已成功处理的键直接返回原状态码并设置 Idempotent-Replayed，
+// 同一个键的请求仍在处理时返回 409。键按项目与表隔离
+func (s *Server) idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			respondStatus(c, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("idempotency key exceeds %d characters", maxIdempotencyKey))
+			c.Abort()
+			return
+		}
+
+		scoped := c.Param("project") + "/" + c.Param("table") + "/" + key
+		status, inFlight := s.idempotency.begin(scoped)
+		switch {
+		case inFlight:
+			respondStatus(c, http.StatusConflict, CodeConflict, "a request with this idempotency key is still being processed")
+			c.Abort()
+			return
+		case status != 0:
+			c.Header("Idempotent-Replayed", "true")
+			c.Status(status)
+			c.Abort()
+			return
+		}
+
+		defer func() {
+			// handler panic 时按失败处理，释放幂等键
+			if r := recover(); r != nil {
+				s.idempotency.finish(scoped, http.StatusInternalServerError)
+				panic(r)
+			}
+		}()
+		c.Next()
+		s.idempotency.finish(scoped, c.Writer.Status())
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	mock := clock.NewMock(time.Now())
+	store := newIdempotencyStore(time.Hour, mock)
+
+	status, inFlight := store.begin("k")
+	assert.Zero(t, status)
+	assert.False(t, inFlight)
+	_, inFlight = store.begin("k")
+	assert.True(t, inFlight)
+
+	// 失败后释放
+	store.finish("k", http.StatusServiceUnavailable)
+	_, inFlight = store.begin("k")
+	assert.False(t, inFlight)
+
+	store.finish("k", http.StatusCreated)
+	status, _ = store.begin("k")
+	assert.Equal(t, http.StatusCreated, status)
+
+	// 过期后清理并重新登记
+	mock.Add(time.Hour)
+	status, inFlight = store.begin("k")
+	assert.Zero(t, status)
+	assert.False(t, inFlight)
+	assert.Len(t, store.entries, 1)
+}
+
+func TestIdempotentBatchInsert(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+	}))
+	server := NewServer(store, &Config{})
+
+	post := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	count := func() int64 {
+		n, err := store.CountLogs(ctx, "app", "requests", nil)
+		require.NoError(t, err)
+		return n
+	}
+
+	batch := `[{"level":"info","message":"a","status":200},{"level":"info","message":"b","status":500}]`
+	w := post("/api/v1/logs/app/requests/batch", "batch-1", batch)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+
+	w = post("/api/v1/logs/app/requests/batch", "batch-1", batch)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.EqualValues(t, 2, count())
+
+	// 失败的请求不记录，修正后可以用同一个键重试
+	w = post("/api/v1/logs/app/requests", "single-1", `{"level":"info","message":"c","status":"x"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = post("/api/v1/logs/app/requests", "single-1", `{"level":"info","message":"c","status":1}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+
+	// 键按表隔离，没有键的请求不受影响
+	w = post("/api/v1/logs/app/other/batch", "batch-1", batch)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = post("/api/v1/logs/app/requests/batch", "", batch)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.EqualValues(t, 5, count())
+
+	w = post("/api/v1/logs/app/requests/batch", strings.Repeat("k", maxIdempotencyKey+1), batch)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
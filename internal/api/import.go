@@ -0,0 +1,58 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/importer"
+)
+
+// importLogs 导入已有的日志文件，请求体为文件内容。format 参数指定格式（jsonl、csv、console），
+// 未指定时按 Content-Type 推断：text/csv 为 CSV，text/plain 为 zap console，其余为 JSONL；
+// mapping 参数为 YAML 或 JSON 格式的列映射；skip 跳过行号不大于该值的记录，用于从上次响应的 last_line 继续导入
+func (s *Server) importLogs(c *gin.Context) {
+	var mapping *importer.Mapping
+	if value := c.Query("mapping"); value != "" {
+		var err error
+		if mapping, err = importer.ParseMapping([]byte(value)); err != nil {
+			badRequest(c, err)
+			return
+		}
+	}
+
+	var format importer.Format
+	if value := c.Query("format"); value != "" {
+		var err error
+		if format, err = importer.ParseFormat(value); err != nil {
+			badRequest(c, err)
+			return
+		}
+	} else if mapping == nil || mapping.Format == "" {
+		switch mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type")); mediaType {
+		case "text/csv":
+			format = importer.FormatCSV
+		case "text/plain":
+			format = importer.FormatConsole
+		}
+	}
+
+	skip := 0
+	if value := c.Query("skip"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			respondStatus(c, http.StatusBadRequest, CodeBadRequest, "invalid skip: "+value)
+			return
+		}
+		skip = n
+	}
+
+	reader, err := importer.NewReader(c.Request.Body, format, mapping)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	reader.SkipThrough(skip)
+	s.ingest(c, reader)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestImportLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+	}))
+	server := NewServer(store, &Config{})
+
+	post := func(query url.Values, contentType, body string) (*httptest.ResponseRecorder, StreamResult) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/requests/import?"+query.Encode(), strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		var result StreamResult
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		}
+		return w, result
+	}
+
+	csv := "time,severity,text,status\n" +
+		"2024-01-02 03:04:05,info,first,200\n" +
+		"2024-01-02 03:04:06,error,second,abc\n" +
+		"2024-01-02 03:04:07,warn,third,\n"
+	mapping := `{"fields":{"time":"timestamp","severity":"level","text":"message"},"timestamp_layout":"2006-01-02 15:04:05","timezone":"+08:00"}`
+
+	w, result := post(url.Values{"mapping": {mapping}}, "text/csv", csv)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 2, result.Accepted)
+	assert.Equal(t, 1, result.Rejected)
+	assert.Equal(t, 4, result.LastLine)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 3, result.Errors[0].Line)
+	assert.Equal(t, "status", result.Errors[0].Fields[0].Field)
+
+	rows, err := store.SearchLogs(ctx, "app", "requests", &models.Query{Sort: []string{"timestamp"}})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.EqualValues(t, 200, rows[0]["status"])
+	assert.Equal(t, time.Date(2024, 1, 1, 19, 4, 5, 0, time.UTC), rows[0]["timestamp"].(time.Time).UTC())
+
+	// 从上次的位置继续时不重复写入
+	w, result = post(url.Values{"mapping": {mapping}, "format": {"csv"}, "skip": {"2"}}, "application/octet-stream", csv)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, result.Accepted)
+	assert.Equal(t, 1, result.Rejected)
+
+	console := "2024-01-02T03:04:05.000Z\tINFO\tmain.go:10\tstarted\t{\"status\":0}\n"
+	w, result = post(nil, "text/plain", console)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, result.Accepted)
+
+	w, _ = post(url.Values{"format": {"xml"}}, "text/csv", csv)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = post(url.Values{"mapping": {"fields: ["}}, "text/csv", csv)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = post(url.Values{"skip": {"-1"}}, "text/csv", csv)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// maxInferSamples schema 推断接口一次最多接受的样本数
+const maxInferSamples = 1000
+
+// InferSchemaRequest schema 推断请求，samples 与日志写入接口的请求体格式相同
+type InferSchemaRequest struct {
+	Project string                   `json:"project"`
+	Table   string                   `json:"table"`
+	Samples []map[string]interface{} `json:"samples"`
+	Create  bool                     `json:"create"` // 为 true 时直接创建推断出的 schema
+}
+
+// InferSchemaResponse schema 推断结果
+type InferSchemaResponse struct {
+	Schema           *models.Schema    `json:"schema"`
+	SuggestedIndexes map[string]string `json:"suggested_indexes"` // 建议索引的字段及原因
+	Created          bool              `json:"created"`
+}
+
+// inferSchema 根据样本日志推断 schema 并建议索引，create 为 true 时创建该 schema
+func (s *Server) inferSchema(c *gin.Context) {
+	var req InferSchemaRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	// 保留整数与浮点数的区别，避免整数字段被推断为 float
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if len(req.Samples) == 0 {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "at least one sample is required")
+		return
+	}
+	if len(req.Samples) > maxInferSamples {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("at most %d samples are allowed", maxInferSamples))
+		return
+	}
+
+	logs := make([]*models.LogEntry, len(req.Samples))
+	for i, sample := range req.Samples {
+		logs[i] = &models.LogEntry{Fields: numbersToNative(sample).(map[string]interface{})}
+	}
+	schema := models.InferSchema(req.Project, req.Table, logs)
+	indexes := models.SuggestIndexes(schema, logs)
+	if err := schema.Validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if !req.Create {
+		c.JSON(http.StatusOK, &InferSchemaResponse{Schema: schema, SuggestedIndexes: indexes})
+		return
+	}
+	if s.rejectReadOnly(c, schema.Project) {
+		return
+	}
+	now := time.Now()
+	schema.CreatedAt = now
+	schema.UpdatedAt = now
+	if err := s.storage.CreateSchema(c.Request.Context(), schema); err != nil {
+		respondError(c, err)
+		return
+	}
+	s.syncSchemaFile(schema)
+	c.Header("ETag", schema.ETag())
+	c.JSON(http.StatusCreated, &InferSchemaResponse{Schema: schema, SuggestedIndexes: indexes, Created: true})
+}
+
+// numbersToNative 将 json.Number 转换为 int64，无法表示为整数时转换为 float64
+func numbersToNative(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = numbersToNative(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = numbersToNative(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
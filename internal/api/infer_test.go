@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestInferSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := storage.New(context.Background(), storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	server := NewServer(store, &Config{})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/schemas/infer", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	samples := `[
+		{"level": "info", "message": "a", "status": 200, "latency": 1.5, "user_id": "u1", "client": "10.0.0.1"},
+		{"level": "warn", "message": "b", "status": 404, "latency": 2, "user_id": "u2", "client": "10.0.0.2"}
+	]`
+	w := post(`{"project": "app", "table": "requests", "samples": ` + samples + `}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp InferSchemaResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.Created)
+	types := make(map[string]models.FieldType)
+	for _, field := range resp.Schema.Fields {
+		types[field.Name] = field.Type
+	}
+	assert.Equal(t, models.FieldTypeInt, types["status"])
+	assert.Equal(t, models.FieldTypeFloat, types["latency"])
+	assert.Equal(t, models.FieldTypeIP, types["client"])
+	assert.Equal(t, "identifier field", resp.SuggestedIndexes["user_id"])
+	assert.Equal(t, "commonly filtered field", resp.SuggestedIndexes["status"])
+	_, err = store.GetSchema(context.Background(), "app", "requests")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+
+	w = post(`{"project": "app", "table": "requests", "create": true, "samples": ` + samples + `}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	schema, err := store.GetSchema(context.Background(), "app", "requests")
+	require.NoError(t, err)
+	assert.Len(t, schema.Fields, len(resp.Schema.Fields))
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"project": "app", "table": "requests", "samples": []}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, post(`{"project": "App", "table": "requests", "samples": `+samples+`}`).Code)
+}
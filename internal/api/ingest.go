@@ -0,0 +1,261 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// 日志写入接口支持的请求体格式，其他 Content-Type 按 JSON 解析
+const (
+	formatJSON     = "json"
+	formatMsgpack  = "msgpack"
+	formatProtobuf = "protobuf"
+)
+
+// msgpackHandle MessagePack 解码配置，对象解码为 map[string]interface{}，字符串不再是 []byte
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	h.WriteExt = true
+	return h
+}()
+
+// bodyFormat 根据 Content-Type 返回请求体格式
+func bodyFormat(c *gin.Context) string {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+		return formatMsgpack
+	case "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf":
+		return formatProtobuf
+	default:
+		return formatJSON
+	}
+}
+
+// bindLog 解析单条日志请求体，protobuf 请求体为 LogEntry
+func bindLog(c *gin.Context) (map[string]interface{}, error) {
+	switch bodyFormat(c) {
+	case formatMsgpack:
+		var raw map[string]interface{}
+		if err := codec.NewDecoder(c.Request.Body, msgpackHandle).Decode(&raw); err != nil {
+			return nil, fmt.Errorf("invalid msgpack body: %w", err)
+		}
+		return normalizeMap(raw), nil
+	case formatProtobuf:
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := decodeProtoEntry(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid protobuf body: %w", err)
+		}
+		return raw, nil
+	default:
+		var raw map[string]interface{}
+		if err := c.ShouldBindJSON(&raw); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	}
+}
+
+// bindLogBatch 解析批量日志请求体，protobuf 请求体为 LogBatch
+func bindLogBatch(c *gin.Context) ([]map[string]interface{}, error) {
+	switch bodyFormat(c) {
+	case formatMsgpack:
+		var raw []map[string]interface{}
+		if err := codec.NewDecoder(c.Request.Body, msgpackHandle).Decode(&raw); err != nil {
+			return nil, fmt.Errorf("invalid msgpack body: %w", err)
+		}
+		for i := range raw {
+			raw[i] = normalizeMap(raw[i])
+		}
+		return raw, nil
+	case formatProtobuf:
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		return decodeProtoBatch(data)
+	default:
+		var raw []map[string]interface{}
+		if err := c.ShouldBindJSON(&raw); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	}
+}
+
+// normalizeMap 将 MessagePack 解码出的值转换为与 JSON 解码结果一致的类型
+func normalizeMap(m map[string]interface{}) map[string]interface{} {
+	for key, value := range m {
+		m[key] = normalizeValue(value)
+	}
+	return m
+}
+
+// normalizeValue 整数统一为 int64，时间转换为 RFC3339 字符串，二进制转换为字符串
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return normalizeMap(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeValue(v[i])
+		}
+		return v
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint:
+		return normalizeUint(uint64(v))
+	case uint64:
+		return normalizeUint(v)
+	case float32:
+		return float64(v)
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return value
+	}
+}
+
+// normalizeUint 超出 int64 范围的无符号整数转换为 float64
+func normalizeUint(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return float64(v)
+	}
+	return int64(v)
+}
+
+// decodeProtoBatch 解码 LogBatch 消息
+func decodeProtoBatch(data []byte) ([]map[string]interface{}, error) {
+	var logs []map[string]interface{}
+	err := walkProto(data, func(num protowire.Number, value []byte) error {
+		if num != 1 {
+			return nil
+		}
+		entry, err := decodeProtoEntry(value)
+		if err != nil {
+			return fmt.Errorf("logs[%d]: %w", len(logs), err)
+		}
+		logs = append(logs, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid protobuf body: %w", err)
+	}
+	return logs, nil
+}
+
+// decodeProtoEntry 解码 LogEntry 消息，fields 中的同名键不会覆盖 level、message 与 timestamp
+func decodeProtoEntry(data []byte) (map[string]interface{}, error) {
+	raw := make(map[string]interface{})
+	builtin := make(map[string]interface{})
+	err := walkProto(data, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			builtin["level"] = string(value)
+		case 2:
+			builtin["message"] = string(value)
+		case 3:
+			var ts timestamppb.Timestamp
+			if err := proto.Unmarshal(value, &ts); err != nil {
+				return fmt.Errorf("timestamp: %w", err)
+			}
+			builtin["timestamp"] = ts.AsTime().Format(time.RFC3339Nano)
+		case 4:
+			var fields structpb.Struct
+			if err := proto.Unmarshal(value, &fields); err != nil {
+				return fmt.Errorf("fields: %w", err)
+			}
+			for key, v := range fields.AsMap() {
+				raw[key] = v
+			}
+		case 5:
+			tags, _ := builtin[models.TagsColumn].(map[string]interface{})
+			if tags == nil {
+				tags = make(map[string]interface{})
+				builtin[models.TagsColumn] = tags
+			}
+			var key, tag string
+			err := walkProto(value, func(num protowire.Number, value []byte) error {
+				switch num {
+				case 1:
+					key = string(value)
+				case 2:
+					tag = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("tags: %w", err)
+			}
+			tags[key] = tag
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range builtin {
+		raw[key] = value
+	}
+	return raw, nil
+}
+
+// walkProto 依次回调消息中长度前缀类型的字段，其他类型的字段被跳过
+func walkProto(data []byte, fn func(num protowire.Number, value []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := fn(num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
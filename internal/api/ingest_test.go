@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// requestContext 构造带请求体的 gin.Context
+func requestContext(contentType string, body []byte) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", contentType)
+	return c
+}
+
+// protoEntry 按 logs.proto 编码 LogEntry
+func protoEntry(t *testing.T, level, message string, ts time.Time, fields map[string]interface{}, tags map[string]string) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, level)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, message)
+	tsBytes, err := proto.Marshal(timestamppb.New(ts))
+	require.NoError(t, err)
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendBytes(b, tsBytes)
+	st, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	fieldBytes, err := proto.Marshal(st)
+	require.NoError(t, err)
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	b = protowire.AppendBytes(b, fieldBytes)
+	for key, value := range tags {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, key)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, value)
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	// 未知字段被跳过
+	b = protowire.AppendTag(b, 99, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+func TestBindLogMsgpack(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var body []byte
+	require.NoError(t, codec.NewEncoderBytes(&body, msgpackHandle).Encode([]map[string]interface{}{{
+		"level":     "info",
+		"message":   "hello",
+		"timestamp": ts,
+		"status":    uint16(200),
+		"payload":   []byte("raw"),
+		"user":      map[string]interface{}{"id": int8(7), "roles": []interface{}{"admin"}},
+	}}))
+
+	logs, err := bindLogBatch(requestContext("application/msgpack", body))
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, map[string]interface{}{
+		"level":     "info",
+		"message":   "hello",
+		"timestamp": "2024-01-02T03:04:05Z",
+		"status":    int64(200),
+		"payload":   "raw",
+		"user":      map[string]interface{}{"id": int64(7), "roles": []interface{}{"admin"}},
+	}, logs[0])
+
+	_, err = bindLog(requestContext("application/x-msgpack", []byte{0xc1}))
+	assert.Error(t, err)
+}
+
+func TestBindLogProtobuf(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	entry := protoEntry(t, "warn", "slow", ts,
+		map[string]interface{}{"latency": 1.5, "message": "ignored"},
+		map[string]string{"env": "prod"})
+
+	raw, err := bindLog(requestContext("application/x-protobuf", entry))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"level":     "warn",
+		"message":   "slow",
+		"timestamp": "2024-01-02T03:04:05.0000006Z",
+		"latency":   1.5,
+		"tags":      map[string]interface{}{"env": "prod"},
+	}, raw)
+
+	var batch []byte
+	for i := 0; i < 2; i++ {
+		batch = protowire.AppendTag(batch, 1, protowire.BytesType)
+		batch = protowire.AppendBytes(batch, entry)
+	}
+	logs, err := bindLogBatch(requestContext("application/x-protobuf; charset=binary", batch))
+	require.NoError(t, err)
+	assert.Len(t, logs, 2)
+
+	_, err = bindLogBatch(requestContext("application/x-protobuf", []byte{0x0a, 0x05, 0x01}))
+	assert.Error(t, err)
+
+	// 其他 Content-Type 仍按 JSON 解析
+	raw, err = bindLog(requestContext("text/plain", []byte(`{"message":"json"}`)))
+	require.NoError(t, err)
+	assert.Equal(t, "json", raw["message"])
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// IssueStatusRequest 修改问题状态的请求
+type IssueStatusRequest struct {
+	Status models.IssueStatus `json:"status" binding:"required"`
+}
+
+// issueStore 获取问题存储，存储不支持时返回 501
+func (s *Server) issueStore(c *gin.Context) (storage.IssueStore, bool) {
+	store, ok := storage.As[storage.IssueStore](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "issues are not supported by this storage")
+	}
+	return store, ok
+}
+
+// listIssues 按最近出现时间倒序列出问题，可按 project、table、status 过滤
+func (s *Server) listIssues(c *gin.Context) {
+	store, ok := s.issueStore(c)
+	if !ok {
+		return
+	}
+
+	filter := &models.IssueFilter{
+		Project: c.Query("project"),
+		Table:   c.Query("table"),
+		Status:  models.IssueStatus(c.Query("status")),
+	}
+	if filter.Status != "" {
+		if err := filter.Status.Validate(); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+	for param, target := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := c.Query(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				respondStatus(c, http.StatusBadRequest, CodeBadRequest, "invalid "+param+": "+value)
+				return
+			}
+			*target = n
+		}
+	}
+
+	issues, err := store.ListIssues(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, issues)
+}
+
+// getIssue 获取问题
+func (s *Server) getIssue(c *gin.Context) {
+	store, ok := s.issueStore(c)
+	if !ok {
+		return
+	}
+
+	issue, err := store.GetIssue(c.Request.Context(), c.Param("project"), c.Param("table"), c.Param("fingerprint"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, issue)
+}
+
+// updateIssue 将问题标记为已解决、已忽略或重新打开
+func (s *Server) updateIssue(c *gin.Context) {
+	store, ok := s.issueStore(c)
+	if !ok {
+		return
+	}
+
+	var req IssueStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if err := req.Status.Validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	project, table, fingerprint := c.Param("project"), c.Param("table"), c.Param("fingerprint")
+	if err := store.SetIssueStatus(ctx, project, table, fingerprint, req.Status); err != nil {
+		respondError(c, err)
+		return
+	}
+	issue, err := store.GetIssue(ctx, project, table, fingerprint)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, issue)
+}
+
+// deleteIssue 删除问题，之后再次出现的同类错误会作为新问题记录
+func (s *Server) deleteIssue(c *gin.Context) {
+	store, ok := s.issueStore(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteIssue(c.Request.Context(), c.Param("project"), c.Param("table"), c.Param("fingerprint")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
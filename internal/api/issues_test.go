@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/issues"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestIssues(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "stack", Type: models.FieldTypeString}},
+	}))
+	processor, err := issues.NewProcessor(store, issues.Config{})
+	require.NoError(t, err)
+	server := NewServer(store, &Config{Issues: processor})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	w := do(http.MethodPost, "/api/v1/logs/app/events/batch", `[
+		{"level":"error","message":"order 17 not found","stack":"main.load()\n\t/app/load.go:10"},
+		{"level":"error","message":"order 42 not found","stack":"main.load()\n\t/app/load.go:12"},
+		{"level":"info","message":"order 42 loaded"}
+	]`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = do(http.MethodPost, "/api/v1/logs/app/events", `{"level":"error","message":"disk full"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = do(http.MethodGet, "/api/v1/issues?project=app&status=unresolved", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list []*models.Issue
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 2)
+	var orders *models.Issue
+	for _, issue := range list {
+		if issue.Title == "order <num> not found" {
+			orders = issue
+		}
+	}
+	require.NotNil(t, orders)
+	assert.EqualValues(t, 2, orders.Count)
+	assert.Equal(t, "main.load()", orders.Culprit)
+
+	path := "/api/v1/issues/app/events/" + orders.Fingerprint
+	w = do(http.MethodPatch, path, `{"status":"resolved"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var issue models.Issue
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issue))
+	assert.Equal(t, models.IssueResolved, issue.Status)
+
+	// 再次出现时重新打开
+	w = do(http.MethodPost, "/api/v1/logs/app/events", `{"level":"error","message":"order 7 not found","stack":"main.load()\n\t/app/load.go:99"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = do(http.MethodGet, path, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issue))
+	assert.Equal(t, models.IssueUnresolved, issue.Status)
+	assert.EqualValues(t, 3, issue.Count)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPatch, path, `{"status":"closed"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodGet, "/api/v1/issues?status=closed", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/v1/issues?limit=x", "").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, path, "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, path, "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPatch, path, `{"status":"ignored"}`).Code)
+}
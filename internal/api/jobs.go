@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/jobs"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// JobsResponse 后台任务的执行状态
+type JobsResponse struct {
+	Jobs []jobs.Status `json:"jobs"`
+}
+
+// newJobs 创建后台任务调度器，注册存储支持的维护任务：archive_purge 每小时永久删除超过宽限期的 schema 归档，
+// rollup 每隔 rollupInterval 汇总过期原始日志，slow_query_purge 每小时删除超过保留时间的慢查询记录。
+// 设置了 Cluster 时只有 leader 执行
+func (s *Server) newJobs(logger *zap.Logger) *jobs.Scheduler {
+	config := jobs.Config{Logger: logger}
+	if s.cluster != nil {
+		config.Leading = s.leading
+	}
+	scheduler := jobs.New(config)
+	if _, ok := s.archiver(); ok {
+		scheduler.Add(jobs.Job{Name: "archive_purge", Interval: archivePurgeInterval, Run: s.purgeArchivedSchemas})
+	}
+	if _, ok := storage.As[storage.Roller](s.storage); ok && s.rollupInterval > 0 {
+		scheduler.Add(jobs.Job{Name: "rollup", Interval: s.rollupInterval, Run: s.rollupAll})
+	}
+	if _, ok := storage.As[storage.SlowQueryStore](s.storage); ok {
+		scheduler.Add(jobs.Job{Name: "slow_query_purge", Interval: slowQueryPurgeInterval, Run: s.purgeSlowQueries})
+	}
+	return scheduler
+}
+
+// listJobs 返回本实例各后台任务的执行次数、最近结果与耗时
+func (s *Server) listJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, JobsResponse{Jobs: s.jobs.Status()})
+}
+
+// runJob 在本实例上立即执行一次后台任务，不论本实例是否为 leader；任务失败时返回错误
+func (s *Server) runJob(c *gin.Context) {
+	status, err := s.jobs.Run(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		respondStatus(c, http.StatusNotFound, CodeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// serveMetrics 输出日志转换的指标与后台任务的指标
+func (s *Server) serveMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.metrics.WriteTo(c.Writer)
+	s.jobs.WriteTo(c.Writer)
+}
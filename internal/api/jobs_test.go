@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/jobs"
+	"pkg.blksails.net/logs/internal/metrics"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{Project: "app", Table: "events"}))
+
+	registry, err := metrics.NewRegistry(nil)
+	require.NoError(t, err)
+	server := NewServer(store, &Config{Metrics: registry, SchemaArchiveGrace: time.Millisecond})
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := do(http.MethodGet, "/api/v1/admin/jobs")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp JobsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Jobs, 3)
+	assert.Equal(t, "archive_purge", resp.Jobs[0].Name)
+	assert.Equal(t, "1h0m0s", resp.Jobs[0].Interval)
+	assert.Equal(t, "rollup", resp.Jobs[1].Name)
+	assert.Equal(t, "slow_query_purge", resp.Jobs[2].Name)
+	assert.Zero(t, resp.Jobs[0].Succeeded)
+
+	// 删除的 schema 超过宽限期后由 archive_purge 永久删除
+	w = do(http.MethodDelete, "/api/v1/schemas/app/events")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	time.Sleep(5 * time.Millisecond)
+	w = do(http.MethodPost, "/api/v1/admin/jobs/archive_purge/run")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var status jobs.Status
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, int64(1), status.Succeeded)
+	assert.NotNil(t, status.LastSuccess)
+	archived, err := store.ListArchivedSchemas(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, archived)
+
+	w = do(http.MethodPost, "/api/v1/admin/jobs/vacuum/run")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), CodeNotFound)
+
+	w = do(http.MethodGet, "/metrics")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `logs_job_runs_total{job="archive_purge",result="success"} 1`)
+	assert.Contains(t, w.Body.String(), "logs_job_leader 1")
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// schemaFormatJSONSchema 以 JSON Schema 文档导入导出 schema
+const schemaFormatJSONSchema = "jsonschema"
+
+// checkSchemaFormat 校验 format 查询参数，为空表示内部格式，不支持的格式返回 400
+func checkSchemaFormat(c *gin.Context) (string, bool) {
+	format := c.Query("format")
+	if format != "" && format != schemaFormatJSONSchema {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "unsupported schema format: "+format)
+		return "", false
+	}
+	return format, true
+}
+
+// respondJSONSchema 以 JSON Schema 文档返回 schema
+func respondJSONSchema(c *gin.Context, schema *models.Schema) {
+	c.Header("ETag", schema.ETag())
+	c.Header("Content-Type", "application/schema+json")
+	c.JSON(http.StatusOK, schema.ToJSONSchema())
+}
+
+// importSchema 从 JSON Schema 文档创建 schema，project 与 table 查询参数优先于文档中的 x-project 与 x-table；
+// format=protobuf 时从 FileDescriptorSet 创建
+func (s *Server) importSchema(c *gin.Context) {
+	if c.Query("format") == schemaFormatProtobuf {
+		s.importProtobuf(c)
+		return
+	}
+	if _, ok := checkSchemaFormat(c); !ok {
+		return
+	}
+
+	var doc models.JSONSchema
+	if err := c.ShouldBindJSON(&doc); err != nil {
+		badRequest(c, err)
+		return
+	}
+	schema, err := doc.ToSchema(c.Query("project"), c.Query("table"))
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	if s.rejectReadOnly(c, schema.Project) {
+		return
+	}
+
+	now := time.Now()
+	schema.CreatedAt = now
+	schema.UpdatedAt = now
+	if err := schema.Validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := s.storage.CreateSchema(c.Request.Context(), schema); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	s.respondSchema(c, http.StatusCreated, schema.Project, schema.Table, schema)
+}
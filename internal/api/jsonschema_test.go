@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestJSONSchemaImportExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store, err := storage.New(ctx, storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	server := NewServer(store, &Config{})
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	doc := `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"required": ["level", "message"],
+		"properties": {
+			"level": {"type": "string", "x-indexed": true},
+			"message": {"type": "string"},
+			"status": {"type": "integer"},
+			"client": {"type": "string", "format": "ipv4"}
+		},
+		"additionalProperties": true
+	}`
+	w := do(http.MethodPost, "/api/v1/schemas/import?format=jsonschema&project=app&table=requests", doc)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	schema, err := store.GetSchema(ctx, "app", "requests")
+	require.NoError(t, err)
+	assert.Equal(t, models.FieldTypeIP, schema.GetField("client").Type)
+	assert.True(t, schema.GetField("level").Indexed)
+	require.NotNil(t, schema.RestField())
+	assert.Equal(t, models.InferredRestField, schema.RestField().Name)
+
+	w = do(http.MethodGet, "/api/v1/schemas/app/requests?format=jsonschema", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/schema+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, schema.ETag(), w.Header().Get("ETag"))
+	var exported models.JSONSchema
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &exported))
+	assert.Equal(t, "app", exported.Project)
+	assert.Equal(t, models.JSONSchemaType{"integer"}, exported.Properties["status"].Type)
+	assert.ElementsMatch(t, []string{"level", "message"}, exported.Required)
+	assert.NotContains(t, exported.Properties, models.InferredRestField)
+
+	// 导出的文档可以原样导入为另一个表
+	w = do(http.MethodPost, "/api/v1/schemas/import?format=jsonschema&table=copy", w.Body.String())
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	copied, err := store.GetSchema(ctx, "app", "copy")
+	require.NoError(t, err)
+	assert.Equal(t, schema.Fields, copied.Fields)
+
+	w = do(http.MethodGet, "/api/v1/schemas/app/requests?format=avro", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPost, "/api/v1/schemas/import", `{"type": "object", "properties": {}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPost, "/api/v1/schemas/import?project=app&table=bad", `{"type": "object", "properties": {"Bad": {"type": "string"}}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+}
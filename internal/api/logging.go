@@ -0,0 +1,51 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// accessLog 记录每个请求的方法、路径、状态码与耗时，5xx 记为 error，4xx 记为 warn，其余记为 info
+func (s *Server) accessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", s.clientIP(c.Request)),
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			s.logger.Error("request", fields...)
+		case status >= http.StatusBadRequest:
+			s.logger.Warn("request", fields...)
+		default:
+			s.logger.Info("request", fields...)
+		}
+	}
+}
+
+// recovery 捕获处理器中的 panic，记录日志并返回 500
+func (s *Server) recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		s.logger.Error("panic recovered",
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Any("panic", err),
+			zap.Stack("stack"))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
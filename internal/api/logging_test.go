@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.DebugLevel)
+	server := NewServer(nil, &Config{Pprof: true, Logger: zap.New(core)})
+	server.router.GET("/panic", func(c *gin.Context) { panic("boom") })
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/"))
+	assert.Equal(t, http.StatusNotFound, get("/missing"))
+	assert.Equal(t, http.StatusInternalServerError, get("/panic"))
+
+	requests := logs.FilterMessage("request").All()
+	require.Len(t, requests, 3)
+	assert.Equal(t, zapcore.InfoLevel, requests[0].Level)
+	assert.Equal(t, "api", requests[0].ContextMap()["component"])
+	assert.Equal(t, "/debug/pprof/", requests[0].ContextMap()["path"])
+	assert.Equal(t, zapcore.WarnLevel, requests[1].Level)
+	assert.Equal(t, int64(http.StatusNotFound), requests[1].ContextMap()["status"])
+	assert.Equal(t, zapcore.ErrorLevel, requests[2].Level)
+	assert.Equal(t, 1, logs.FilterMessage("panic recovered").Len())
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/metrics"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "status", Type: models.FieldTypeInt},
+			{Name: "duration", Type: models.FieldTypeFloat},
+		},
+	}))
+
+	registry, err := metrics.NewRegistry([]*metrics.Rule{
+		{Name: "http_requests_total", Type: metrics.Counter, Project: "app", Labels: []string{"status"}},
+		{Name: "http_request_duration_seconds", Type: metrics.Histogram, Project: "app", Field: "duration", Buckets: []float64{1}},
+	})
+	require.NoError(t, err)
+	server := NewServer(store, &Config{Metrics: registry})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	w := do(http.MethodPost, "/api/v1/logs/app/requests", `{"level":"info","message":"ok","status":200,"duration":0.2}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = do(http.MethodPost, "/api/v1/logs/app/requests/batch",
+		`[{"level":"info","message":"ok","status":200,"duration":0.4},{"level":"error","message":"fail","status":500,"duration":3}]`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	// 写入失败的日志不计入
+	w = do(http.MethodPost, "/api/v1/logs/app/requests", `{"level":"info",`)
+	require.NotEqual(t, http.StatusCreated, w.Code)
+
+	w = do(http.MethodGet, "/metrics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `http_requests_total{project="app",table="requests",status="200"} 2`)
+	assert.Contains(t, body, `http_requests_total{project="app",table="requests",status="500"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{project="app",table="requests",le="1"} 2`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{project="app",table="requests"} 3`)
+
+	// 未配置规则时不注册 /metrics
+	w = httptest.NewRecorder()
+	NewServer(store, &Config{}).router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
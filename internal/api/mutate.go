@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// DefaultLogMutationLimit 单次删除或修改日志默认允许匹配的最大条数
+const DefaultLogMutationLimit = 10000
+
+// LogMutationResponse 删除或修改日志的结果，dry_run 时 count 为匹配的条数
+type LogMutationResponse struct {
+	Project string `json:"project"`
+	Table   string `json:"table"`
+	Count   int64  `json:"count"`
+	DryRun  bool   `json:"dry_run,omitempty"`
+	Limit   int64  `json:"limit,omitempty"` // 单次允许匹配的最大条数，0 表示不限制
+}
+
+// mutator 返回存储的日志修改能力，不支持时返回 501
+func (s *Server) mutator(c *gin.Context) (storage.LogMutator, bool) {
+	mutator, ok := storage.As[storage.LogMutator](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "log deletes and updates are not supported by this storage")
+	}
+	return mutator, ok
+}
+
+// deleteLogs 删除匹配过滤条件的日志，过滤条件必填；dry_run 时只统计匹配条数
+func (s *Server) deleteLogs(c *gin.Context) {
+	mutator, ok := s.mutator(c)
+	if !ok {
+		return
+	}
+	var req models.LogDelete
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	project, table := c.Param("project"), c.Param("table")
+	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := req.LogFilter.Validate(schema, storage.BaseColumns(s.storage, schema)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	s.mutateLogs(c, mutator, project, table, req.DryRun, &req.LogFilter, func() (int64, error) {
+		return mutator.DeleteLogs(c.Request.Context(), project, table, &req.LogFilter, s.mutationLimit)
+	})
+}
+
+// updateLogs 修改匹配过滤条件的日志字段，用于清除或替换敏感值；dry_run 时只统计匹配条数
+func (s *Server) updateLogs(c *gin.Context) {
+	mutator, ok := s.mutator(c)
+	if !ok {
+		return
+	}
+	var req models.LogUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	project, table := c.Param("project"), c.Param("table")
+	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := req.Validate(schema, storage.BaseColumns(s.storage, schema)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	s.mutateLogs(c, mutator, project, table, req.DryRun, &req.LogFilter, func() (int64, error) {
+		return mutator.UpdateLogs(c.Request.Context(), project, table, &req.LogFilter, req.Set, s.mutationLimit)
+	})
+}
+
+// mutateLogs dry_run 时统计匹配条数，否则执行 mutate 并记录操作日志
+func (s *Server) mutateLogs(c *gin.Context, mutator storage.LogMutator, project, table string, dryRun bool,
+	filter *models.LogFilter, mutate func() (int64, error)) {
+	resp := LogMutationResponse{Project: project, Table: table, DryRun: dryRun, Limit: s.mutationLimit}
+	var err error
+	if dryRun {
+		resp.Count, err = mutator.CountMatching(c.Request.Context(), project, table, filter)
+	} else {
+		resp.Count, err = mutate()
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if !dryRun {
+		s.logger.Info("logs modified", zap.String("method", c.Request.Method),
+			zap.String("project", project), zap.String("table", table), zap.Int64("count", resp.Count))
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestMutateLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	server := NewServer(store, &Config{LogMutationLimit: 2})
+
+	do := func(method, body string) (*httptest.ResponseRecorder, LogMutationResponse) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/api/v1/logs/app/events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(w, req)
+		var resp LogMutationResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w, resp
+	}
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields: []*models.Field{
+			{Name: "user", Type: models.FieldTypeString},
+			{Name: "token", Type: models.FieldTypeString},
+		},
+	}))
+	for _, user := range []string{"alice", "alice", "bob", "bob", "bob"} {
+		require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+			Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: time.Now(),
+			Fields: map[string]interface{}{"user": user, "token": "secret"},
+		}))
+	}
+
+	w, _ := do(http.MethodDelete, `{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w, resp := do(http.MethodDelete, `{"filter": {"user": "bob"}, "dry_run": true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.EqualValues(t, 3, resp.Count)
+	assert.EqualValues(t, 2, resp.Limit)
+	assert.True(t, resp.DryRun)
+
+	// 匹配条数超过上限时拒绝
+	w, _ = do(http.MethodDelete, `{"filter": {"user": "bob"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), string(CodeMutationLimit))
+
+	w, resp = do(http.MethodPatch, `{"filter": {"user": "alice"}, "set": {"token": "[redacted]"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.EqualValues(t, 2, resp.Count)
+	rows, err := store.QueryLogs(ctx, "app", "events", map[string]interface{}{"user": "alice"}, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "[redacted]", rows[0]["token"])
+	w, _ = do(http.MethodPatch, `{"filter": {"user": "alice"}, "set": {"id": "x"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w, resp = do(http.MethodDelete, `{"filter": {"user": "alice"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.EqualValues(t, 2, resp.Count)
+	count, err := store.CountLogs(ctx, "app", "events", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, count)
+
+	server.readOnly.setProject("app", true, "audit")
+	w, _ = do(http.MethodDelete, `{"filter": {"user": "bob"}}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
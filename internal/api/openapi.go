@@ -0,0 +1,549 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/anomaly"
+	"pkg.blksails.net/logs/internal/backup"
+	"pkg.blksails.net/logs/internal/jobs"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/openapi"
+	"pkg.blksails.net/logs/internal/report"
+	"pkg.blksails.net/logs/internal/schema"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// operation 接口描述，路由注册时据此生成 OpenAPI 文档并校验请求
+type operation struct {
+	id      string
+	tag     string
+	summary string
+	query   []param
+	headers []param
+	// body JSON 请求体类型的零值，nil 表示没有 JSON 请求体
+	body interface{}
+	// mediaTypes 除 JSON 外接受的请求体格式，这些格式的请求体不做校验
+	mediaTypes []string
+	// responses 成功响应的状态码与响应体类型的零值，值为 nil 表示没有响应体
+	responses map[int]interface{}
+}
+
+// param 查询参数或请求头
+type param struct {
+	name        string
+	description string
+	schema      *openapi.Schema
+	required    bool
+}
+
+// logInput 单条日志请求体，除内置字段外的属性按 schema 中的字段校验
+type logInput struct {
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"` // 缺省时使用服务器时间
+}
+
+// healthResponse 健康检查结果
+type healthResponse struct {
+	Status  string    `json:"status"`
+	Storage string    `json:"storage"`
+	Error   string    `json:"error,omitempty"`
+	Code    ErrorCode `json:"code,omitempty"`
+}
+
+// searchResponse 日志查询结果
+type searchResponse struct {
+	Project       string                   `json:"project"`
+	Table         string                   `json:"table"`
+	Count         int                      `json:"count"`
+	Entries       []map[string]interface{} `json:"entries"`
+	Partial       bool                     `json:"partial"`        // 结果被查询保护截断，仍有未返回的匹配日志
+	PartialReason string                   `json:"partial_reason"` // max_rows，未截断时为空
+}
+
+// savedQueryResults 保存查询的执行结果
+type savedQueryResults struct {
+	Name string `json:"name"`
+	searchResponse
+}
+
+// correlatedResponse 按 trace_id 或 request_id 关联查询的结果
+type correlatedResponse struct {
+	TraceID   string                   `json:"trace_id,omitempty"`
+	RequestID string                   `json:"request_id,omitempty"`
+	Count     int                      `json:"count"`
+	Entries   []map[string]interface{} `json:"entries"`
+	Tables    []string                 `json:"tables"`  // 已查询的 project/table
+	Skipped   []string                 `json:"skipped"` // 字段未建索引而跳过的 project/table
+	Partial   bool                     `json:"partial"` // 某张表的结果被截断，或超时后未查询其余的表
+	// PartialReason max_rows 或 timeout，未截断时为空
+	PartialReason string `json:"partial_reason"`
+}
+
+// messageResponse 只包含提示信息的响应
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// 常用参数
+var (
+	limitParam  = param{name: "limit", description: "最多返回的条数", schema: &openapi.Schema{Type: "integer", Minimum: float(1)}}
+	fullScan    = param{name: "allow_full_scan", description: "为 true 时大表的查询不要求指定时间范围", schema: &openapi.Schema{Type: "boolean"}}
+	fieldsQuery = param{name: "fields", description: "只返回的列，逗号分隔，为空时返回全部列", schema: &openapi.Schema{Type: "string"}}
+	ownerParam  = param{name: "X-User", description: "未提供 X-API-Key 时用于区分所有者"}
+	ifMatch     = param{name: "If-Match", description: "schema 的 ETag，与当前版本不一致时返回 409"}
+	idemKey     = param{name: "Idempotency-Key", description: "相同的键只写入一次，重复请求返回原状态码"}
+	prefer      = param{name: "Prefer", description: "为 return=minimal 时不返回写入日志的 ID 与时间"}
+)
+
+// operations 全部接口的描述，键为方法与 gin 路由路径
+var operations = map[string]*operation{
+	"GET /healthz": {id: "health", tag: "health", summary: "检查服务与存储是否可用",
+		responses: map[int]interface{}{http.StatusOK: healthResponse{}, http.StatusServiceUnavailable: healthResponse{}}},
+
+	"POST /api/v1/schemas": {id: "createSchema", tag: "schemas", summary: "创建 schema",
+		body: models.Schema{}, responses: map[int]interface{}{http.StatusCreated: models.Schema{}}},
+	"POST /api/v1/schemas/infer": {id: "inferSchema", tag: "schemas", summary: "根据样例日志推断 schema，create 为 true 时直接创建",
+		body: InferSchemaRequest{}, responses: map[int]interface{}{http.StatusOK: InferSchemaResponse{}, http.StatusCreated: InferSchemaResponse{}}},
+	"POST /api/v1/schemas/import": {id: "importSchema", tag: "schemas", summary: "从 JSON Schema 文档或 protobuf FileDescriptorSet 创建 schema",
+		query: []param{
+			{name: "format", schema: &openapi.Schema{Type: "string", Enum: []string{schemaFormatJSONSchema, schemaFormatProtobuf}}},
+			{name: "project", description: "优先于文档中的 x-project"},
+			{name: "table", description: "优先于文档中的 x-table"},
+			{name: "message", description: "format=protobuf 时要转换的消息全名，可重复"},
+		},
+		body: map[string]interface{}{}, mediaTypes: []string{"application/x-protobuf"},
+		responses: map[int]interface{}{http.StatusCreated: models.Schema{}}},
+	"PUT /api/v1/schemas/:project/:table": {id: "updateSchema", tag: "schemas", summary: "替换 schema",
+		headers: []param{ifMatch}, body: models.Schema{}, responses: map[int]interface{}{http.StatusOK: models.Schema{}}},
+	"PATCH /api/v1/schemas/:project/:table": {id: "patchSchema", tag: "schemas", summary: "局部更新 schema",
+		headers: []param{ifMatch}, body: models.SchemaPatch{}, responses: map[int]interface{}{http.StatusOK: models.Schema{}}},
+	"DELETE /api/v1/schemas/:project/:table": {id: "deleteSchema", tag: "schemas", summary: "删除 schema，存储支持归档时默认软删除",
+		query:     []param{{name: "hard", description: "为 true 时立即删除日志表，不归档", schema: &openapi.Schema{Type: "boolean"}}},
+		responses: map[int]interface{}{http.StatusNoContent: nil}},
+	"GET /api/v1/schemas/archived": {id: "listArchivedSchemas", tag: "schemas", summary: "列出已归档的 schema 及其永久删除时间",
+		responses: map[int]interface{}{http.StatusOK: []*models.ArchivedSchema{}}},
+	"POST /api/v1/schemas/:project/:table/restore": {id: "restoreSchema", tag: "schemas", summary: "恢复最近一次归档的 schema 及其日志",
+		responses: map[int]interface{}{http.StatusOK: models.Schema{}}},
+	"POST /api/v1/schemas/:project/:table/rename": {id: "renameSchema", tag: "schemas", summary: "重命名 schema 及其日志表，留空的字段沿用原名称",
+		body: RenameSchemaRequest{}, responses: map[int]interface{}{http.StatusOK: models.Schema{}}},
+	"POST /api/v1/projects/:project/rename": {id: "renameProject", tag: "schemas", summary: "将项目下的全部 schema 移动到新项目",
+		body: RenameProjectRequest{}, responses: map[int]interface{}{http.StatusOK: []*models.Schema{}}},
+	"GET /api/v1/schemas/:project/:table": {id: "getSchema", tag: "schemas", summary: "获取 schema，format=jsonschema 时返回 JSON Schema 文档",
+		query:     []param{{name: "format", schema: &openapi.Schema{Type: "string", Enum: []string{schemaFormatJSONSchema}}}},
+		responses: map[int]interface{}{http.StatusOK: models.Schema{}}},
+	"GET /api/v1/schemas": {id: "listSchemas", tag: "schemas", summary: "列出全部 schema",
+		responses: map[int]interface{}{http.StatusOK: []*models.Schema{}}},
+
+	"GET /api/v1/stats": {id: "ingestStats", tag: "stats", summary: "各表在窗口内写入的条数、字节数、错误率与最近写入时间",
+		query: []param{
+			{name: "window", description: "统计窗口，1m 至 24h 的时长（如 5m、1h、1d）或 all，默认 1h", schema: &openapi.Schema{Type: "string"}},
+			{name: "project", description: "只返回该项目的表", schema: &openapi.Schema{Type: "string"}},
+			{name: "table", description: "只返回该名称的表", schema: &openapi.Schema{Type: "string"}},
+		},
+		responses: map[int]interface{}{http.StatusOK: StatsResponse{}}},
+
+	"GET /api/v1/admin/schemas/status": {id: "schemaManagerStatus", tag: "admin", summary: "schema 文件加载状态",
+		responses: map[int]interface{}{http.StatusOK: schema.Status{}}},
+	"GET /api/v1/admin/read-only": {id: "getReadOnly", tag: "admin", summary: "只读状态",
+		responses: map[int]interface{}{http.StatusOK: ReadOnlyStatus{}}},
+	"PUT /api/v1/admin/read-only": {id: "setReadOnly", tag: "admin", summary: "设置全局只读开关",
+		body: readOnlyRequest{}, responses: map[int]interface{}{http.StatusOK: ReadOnlyStatus{}}},
+	"PUT /api/v1/admin/read-only/:project": {id: "setProjectReadOnly", tag: "admin", summary: "设置项目只读开关",
+		body: readOnlyRequest{}, responses: map[int]interface{}{http.StatusOK: ReadOnlyStatus{}}},
+	"GET /api/v1/admin/telemetry": {id: "telemetryStatus", tag: "admin", summary: "遥测状态与发送的内容",
+		responses: map[int]interface{}{http.StatusOK: TelemetryStatus{}}},
+	"GET /api/v1/admin/anomalies": {id: "anomalyStatus", tag: "admin", summary: "写入量异常检测的最近告警与基线",
+		responses: map[int]interface{}{http.StatusOK: anomaly.Status{}}},
+	"GET /api/v1/admin/jobs": {id: "listJobs", tag: "admin", summary: "本实例各后台维护任务的执行状态",
+		responses: map[int]interface{}{http.StatusOK: JobsResponse{}}},
+	"POST /api/v1/admin/jobs/:name/run": {id: "runJob", tag: "admin", summary: "在本实例上立即执行一次后台维护任务",
+		responses: map[int]interface{}{http.StatusOK: jobs.Status{}}},
+	"GET /api/v1/admin/storage": {id: "storageUsage", tag: "admin", summary: "各项目与日志表占用的存储空间，按大小降序",
+		query:     []param{{name: "project", description: "只返回该项目的表", schema: &openapi.Schema{Type: "string"}}},
+		responses: map[int]interface{}{http.StatusOK: StorageUsageResponse{}}},
+	"POST /api/v1/admin/logs/:project/:table/explain": {id: "explainQuery", tag: "admin", summary: "返回日志查询在存储后端的 SQL 与执行计划，用于排查慢查询",
+		query: []param{{name: "analyze", description: "为 true 时实际执行查询，计划包含耗时与行数；只支持 PostgreSQL 与 MySQL", schema: &openapi.Schema{Type: "boolean"}}},
+		body:  models.Query{}, responses: map[int]interface{}{http.StatusOK: models.QueryPlan{}}},
+	"GET /api/v1/admin/slow-queries": {id: "listSlowQueries", tag: "admin", summary: "列出耗时超过阈值的查询，用于决定为哪些字段建索引",
+		query: []param{
+			{name: "project", description: "只返回该项目的查询", schema: &openapi.Schema{Type: "string"}},
+			{name: "table", description: "只返回该名称的表的查询", schema: &openapi.Schema{Type: "string"}},
+			{name: "from", description: "记录时间不早于该时间（RFC 3339）", schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{name: "to", description: "记录时间早于该时间（RFC 3339）", schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{name: "min_duration", description: "只返回耗时不少于该值的查询，如 5s", schema: &openapi.Schema{Type: "string"}},
+			{name: "sort", description: "time 最近的在前（默认），duration 最慢的在前",
+				schema: &openapi.Schema{Type: "string", Enum: []string{models.SlowQueryByTime, models.SlowQueryByDuration}}},
+			limitParam,
+			{name: "offset", schema: &openapi.Schema{Type: "integer", Minimum: float(0)}},
+		},
+		responses: map[int]interface{}{http.StatusOK: []*models.SlowQuery{}}},
+	"GET /api/v1/admin/projects/:project/export": {id: "exportProject", tag: "admin", summary: "以 NDJSON 流导出项目的全部 schema 与日志",
+		query:     []param{{name: "format", description: "只支持 ndjson", schema: &openapi.Schema{Type: "string", Enum: []string{"ndjson"}}}},
+		responses: map[int]interface{}{http.StatusOK: backup.Record{}}},
+	"POST /api/v1/admin/projects/:project/restore": {id: "restoreProject", tag: "admin", summary: "从导出的 NDJSON 流重建项目的 schema 与日志",
+		query:      []param{{name: "append", description: "为 true 时向已存在的表追加日志，否则已存在的表返回 409", schema: &openapi.Schema{Type: "boolean"}}},
+		mediaTypes: []string{"application/x-ndjson"}, responses: map[int]interface{}{http.StatusOK: backup.RestoreResult{}}},
+
+	"POST /api/v1/logs/:project/:table": {id: "insertLog", tag: "logs", summary: "写入单条日志",
+		headers: []param{idemKey}, body: logInput{}, mediaTypes: []string{"application/msgpack", "application/x-protobuf"},
+		responses: map[int]interface{}{http.StatusCreated: nil}},
+	"POST /api/v1/logs/:project/:table/batch": {id: "batchInsertLogs", tag: "logs", summary: "批量写入日志",
+		headers: []param{idemKey, prefer}, body: []logInput{}, mediaTypes: []string{"application/msgpack", "application/x-protobuf"},
+		query: []param{
+			{name: "partial", description: "为 true 时只写入通过校验的日志，以 207 返回每条日志的结果", schema: &openapi.Schema{Type: "boolean"}},
+			{name: "atomic", description: "为 true 时整批在一个事务中写入，不按 batch_chunk_size 分块", schema: &openapi.Schema{Type: "boolean"}},
+		},
+		responses: map[int]interface{}{http.StatusCreated: BatchResponse{}, http.StatusMultiStatus: BatchResult{}}},
+	"POST /api/v1/logs/:project/:table/stream": {id: "streamLogs", tag: "logs", summary: "以 NDJSON 流式写入日志",
+		mediaTypes: []string{"application/x-ndjson"}, responses: map[int]interface{}{http.StatusOK: StreamResult{}}},
+	"POST /api/v1/logs/:project/:table/import": {id: "importLogs", tag: "logs", summary: "导入 JSONL、CSV 或 zap console 格式的日志文件",
+		query: []param{
+			{name: "format", description: "jsonl（ndjson）、csv 或 console（zap-console），默认按 Content-Type 推断", schema: &openapi.Schema{Type: "string"}},
+			{name: "mapping", description: "YAML 或 JSON 格式的列映射：fields、timestamp_layout、timezone 与 defaults", schema: &openapi.Schema{Type: "string"}},
+			{name: "skip", description: "跳过起始行号不大于该值的记录，传入上次响应的 last_line 以继续导入", schema: &openapi.Schema{Type: "integer", Minimum: float(0)}},
+		},
+		mediaTypes: []string{"application/x-ndjson", "text/csv", "text/plain"}, responses: map[int]interface{}{http.StatusOK: StreamResult{}}},
+	"GET /api/v1/logs/:project/:table/aggregates/:name": {id: "queryAggregate", tag: "logs", summary: "查询持续聚合结果",
+		query: []param{
+			{name: "from", schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{name: "to", schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{name: "tz", description: "时区，IANA 名称或 ±hh:mm，默认 UTC；不带偏移的 from、to 按该时区解释", schema: &openapi.Schema{Type: "string"}},
+			{name: "interval", description: "按 tz 的当地时间合并到的时间桶大小，如 1h、1d，需为定义的时间桶的整数倍", schema: &openapi.Schema{Type: "string"}},
+			{name: "compare", description: "对比窗口的偏移，如 1d、7d；指定时需要 from，返回 {offset, current, previous}，previous 为前移该偏移的窗口的结果，时间桶已后移与 current 对齐", schema: &openapi.Schema{Type: "string"}},
+		},
+		responses: map[int]interface{}{http.StatusOK: []map[string]interface{}{}}},
+	"GET /api/v1/logs/:project/:table/rollups/:name": {id: "queryRollup", tag: "logs", summary: "查询 rollup 汇总结果",
+		query: []param{
+			{name: "from", schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{name: "to", schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{name: "tz", description: "时区，IANA 名称或 ±hh:mm，默认 UTC；不带偏移的 from、to 按该时区解释", schema: &openapi.Schema{Type: "string"}},
+			{name: "interval", description: "按 tz 的当地时间合并到的时间桶大小，如 1h、1d，需为定义的时间桶的整数倍", schema: &openapi.Schema{Type: "string"}},
+			{name: "compare", description: "对比窗口的偏移，如 1d、7d；指定时需要 from，返回 {offset, current, previous}，previous 为前移该偏移的窗口的结果，时间桶已后移与 current 对齐", schema: &openapi.Schema{Type: "string"}},
+		},
+		responses: map[int]interface{}{http.StatusOK: []map[string]interface{}{}}},
+	"POST /api/v1/logs/:project/:table/rollup": {id: "runRollup", tag: "logs", summary: "立即汇总并删除超过 rollup_after 的原始日志",
+		responses: map[int]interface{}{http.StatusOK: RollupResponse{}}},
+	"POST /api/v1/logs/:project/:table/search": {id: "searchLogs", tag: "logs", summary: "按过滤条件、字段与排序查询日志",
+		query: []param{fullScan}, body: models.Query{}, responses: map[int]interface{}{http.StatusOK: searchResponse{}}},
+	"GET /api/v1/logs/:project/:table/patterns": {id: "logPatterns", tag: "logs", summary: "将时间范围内的日志消息聚类为模板并统计出现次数",
+		query: []param{
+			{name: "from", schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{name: "to", schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{name: "tz", description: "时区，IANA 名称或 ±hh:mm，默认 UTC；不带偏移的 from、to 按该时区解释", schema: &openapi.Schema{Type: "string"}},
+			{name: "level", schema: &openapi.Schema{Type: "string"}},
+			{name: "limit", description: "返回的模板数，默认 50", schema: &openapi.Schema{Type: "integer", Minimum: float(1)}},
+			{name: "sample", description: "最多聚类的最近日志条数，默认 10000", schema: &openapi.Schema{Type: "integer", Minimum: float(1), Maximum: float(maxPatternSample)}},
+			fullScan,
+		},
+		responses: map[int]interface{}{http.StatusOK: PatternsResponse{}}},
+	"GET /api/v1/logs/:project/:table/fields/:field/values": {id: "fieldValues", tag: "logs", summary: "统计时间范围内字段出现最多的取值及次数，用于构建过滤下拉框",
+		query: []param{
+			{name: "from", schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{name: "to", schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{name: "tz", description: "时区，IANA 名称或 ±hh:mm，默认 UTC；不带偏移的 from、to 按该时区解释", schema: &openapi.Schema{Type: "string"}},
+			{name: "limit", description: fmt.Sprintf("返回的取值个数，默认 %d", storage.DefaultFieldValuesLimit), schema: &openapi.Schema{Type: "integer", Minimum: float(1), Maximum: float(maxFieldValuesLimit)}},
+			fullScan,
+		},
+		responses: map[int]interface{}{http.StatusOK: models.FieldValues{}}},
+	"DELETE /api/v1/logs/:project/:table": {id: "deleteLogs", tag: "logs", summary: "删除匹配过滤条件的日志，过滤条件必填，dry_run 时只统计匹配条数",
+		body: models.LogDelete{}, responses: map[int]interface{}{http.StatusOK: LogMutationResponse{}}},
+	"PATCH /api/v1/logs/:project/:table": {id: "updateLogs", tag: "logs", summary: "修改匹配过滤条件的日志字段，用于清除或替换敏感值",
+		body: models.LogUpdate{}, responses: map[int]interface{}{http.StatusOK: LogMutationResponse{}}},
+	"POST /api/v1/test": {id: "insertTestLog", tag: "logs", summary: "写入一条固定的测试日志",
+		responses: map[int]interface{}{http.StatusOK: messageResponse{}}},
+
+	"POST /api/v1/saved-queries": {id: "saveQuery", tag: "queries", summary: "创建或替换保存的查询",
+		headers: []param{ownerParam}, body: models.SavedQuery{}, responses: map[int]interface{}{http.StatusOK: models.SavedQuery{}}},
+	"GET /api/v1/saved-queries": {id: "listSavedQueries", tag: "queries", summary: "列出保存的查询",
+		headers: []param{ownerParam}, responses: map[int]interface{}{http.StatusOK: []*models.SavedQuery{}}},
+	"GET /api/v1/saved-queries/:name": {id: "getSavedQuery", tag: "queries", summary: "获取保存的查询",
+		headers: []param{ownerParam}, responses: map[int]interface{}{http.StatusOK: models.SavedQuery{}}},
+	"DELETE /api/v1/saved-queries/:name": {id: "deleteSavedQuery", tag: "queries", summary: "删除保存的查询",
+		headers: []param{ownerParam}, responses: map[int]interface{}{http.StatusNoContent: nil}},
+	"GET /api/v1/saved-queries/:name/results": {id: "executeSavedQuery", tag: "queries", summary: "执行保存的查询，其余查询参数填充 ${param} 模板",
+		headers: []param{ownerParam},
+		query: []param{
+			{name: "limit", schema: &openapi.Schema{Type: "integer", Minimum: float(0)}},
+			{name: "offset", schema: &openapi.Schema{Type: "integer", Minimum: float(0)}},
+			fieldsQuery,
+			{name: "sort", description: "替换保存的排序，逗号分隔或重复给出，如 status desc,-timestamp", schema: &openapi.Schema{Type: "string"}},
+			fullScan,
+		},
+		responses: map[int]interface{}{http.StatusOK: savedQueryResults{}}},
+
+	"POST /api/v1/reports": {id: "saveReport", tag: "reports", summary: "创建或替换定时报表",
+		headers: []param{ownerParam}, body: models.Report{}, responses: map[int]interface{}{http.StatusOK: reportResponse{}}},
+	"GET /api/v1/reports": {id: "listReports", tag: "reports", summary: "列出定时报表",
+		headers: []param{ownerParam}, responses: map[int]interface{}{http.StatusOK: []reportResponse{}}},
+	"GET /api/v1/reports/:name": {id: "getReport", tag: "reports", summary: "获取定时报表",
+		headers: []param{ownerParam}, responses: map[int]interface{}{http.StatusOK: reportResponse{}}},
+	"DELETE /api/v1/reports/:name": {id: "deleteReport", tag: "reports", summary: "删除定时报表",
+		headers: []param{ownerParam}, responses: map[int]interface{}{http.StatusNoContent: nil}},
+	"POST /api/v1/reports/:name/run": {id: "runReport", tag: "reports", summary: "立即执行并投递定时报表",
+		headers: []param{ownerParam}, responses: map[int]interface{}{http.StatusOK: report.Result{}}},
+
+	"GET /api/v1/issues": {id: "listIssues", tag: "issues", summary: "按最近出现时间倒序列出错误归并问题",
+		query: []param{
+			{name: "project", schema: &openapi.Schema{Type: "string"}},
+			{name: "table", schema: &openapi.Schema{Type: "string"}},
+			{name: "status", schema: &openapi.Schema{Type: "string", Enum: []string{string(models.IssueUnresolved), string(models.IssueResolved), string(models.IssueIgnored)}}},
+			{name: "limit", schema: &openapi.Schema{Type: "integer", Minimum: float(0)}},
+			{name: "offset", schema: &openapi.Schema{Type: "integer", Minimum: float(0)}},
+		},
+		responses: map[int]interface{}{http.StatusOK: []*models.Issue{}}},
+	"GET /api/v1/issues/:project/:table/:fingerprint": {id: "getIssue", tag: "issues", summary: "获取问题",
+		responses: map[int]interface{}{http.StatusOK: models.Issue{}}},
+	"PATCH /api/v1/issues/:project/:table/:fingerprint": {id: "updateIssue", tag: "issues", summary: "修改问题状态",
+		body: IssueStatusRequest{}, responses: map[int]interface{}{http.StatusOK: models.Issue{}}},
+	"DELETE /api/v1/issues/:project/:table/:fingerprint": {id: "deleteIssue", tag: "issues", summary: "删除问题",
+		responses: map[int]interface{}{http.StatusNoContent: nil}},
+
+	"GET /api/v1/trace/:trace_id": {id: "queryTrace", tag: "logs", summary: "查询 trace_id 已建索引的所有表中的关联日志",
+		query: []param{limitParam, fieldsQuery}, responses: map[int]interface{}{http.StatusOK: correlatedResponse{}}},
+	"GET /api/v1/request/:request_id": {id: "queryRequest", tag: "logs", summary: "查询 request_id 已建索引的所有表中的关联日志",
+		query: []param{limitParam, fieldsQuery}, responses: map[int]interface{}{http.StatusOK: correlatedResponse{}}},
+}
+
+// specTags 接口分组
+var specTags = []openapi.Tag{
+	{Name: "health", Description: "健康检查"},
+	{Name: "schemas", Description: "schema 管理"},
+	{Name: "logs", Description: "日志写入与查询"},
+	{Name: "queries", Description: "保存的查询"},
+	{Name: "reports", Description: "定时报表"},
+	{Name: "issues", Description: "错误归并问题"},
+	{Name: "admin", Description: "运行时管理"},
+}
+
+// float 返回 f 的指针
+func float(f float64) *float64 {
+	return &f
+}
+
+// newGenerator 创建声明了枚举与必填属性的 schema 生成器
+func newGenerator() *openapi.Generator {
+	g := openapi.NewGenerator()
+	g.Enum(models.FieldType(""), string(models.FieldTypeString), string(models.FieldTypeInt), string(models.FieldTypeFloat),
+		string(models.FieldTypeBool), string(models.FieldTypeDateTime), string(models.FieldTypeTime), string(models.FieldTypeDuration),
+		string(models.FieldTypeJSON), string(models.FieldTypeRest), string(models.FieldTypeIP), string(models.FieldTypeObject),
+		string(models.FieldTypeArray))
+	g.Enum(models.PatchOpType(""), string(models.PatchAddField), string(models.PatchDeprecateField),
+		string(models.PatchSetRetention), string(models.PatchSetIndex))
+	g.Enum(models.AggregateFunc(""), string(models.AggregateCount), string(models.AggregateSum), string(models.AggregateMin),
+		string(models.AggregateMax), string(models.AggregateAvg))
+	g.Enum(models.ReportFormat(""), string(models.ReportFormatCSV), string(models.ReportFormatJSON), string(models.ReportFormatSummary))
+	g.Enum(models.ReportChannel(""), string(models.ReportChannelWebhook), string(models.ReportChannelSlack), string(models.ReportChannelEmail))
+	g.Enum(models.IssueStatus(""), string(models.IssueUnresolved), string(models.IssueResolved), string(models.IssueIgnored))
+	g.Enum(ErrorCode(""), string(CodeBadRequest), string(CodeValidation), string(CodeNotFound), string(CodeConflict),
+		string(CodeReadOnly), string(CodeNotImplemented), string(CodeBackendUnavailable), string(CodeDeliveryFailed),
+		string(CodePayloadTooLarge), string(CodeMutationLimit), string(CodeQueryTimeout), string(CodeTimeRangeRequired),
+		string(CodeInternal))
+
+	g.Name(report.Result{}, "ReportResult")
+	g.Name(schema.Status{}, "SchemaManagerStatus")
+
+	g.Require(models.Schema{}, "project", "table", "fields")
+	g.Require(models.Field{}, "name", "type")
+	g.Require(models.Aggregate{}, "name", "interval")
+	g.Require(models.AggregateMetric{}, "func")
+	g.Require(models.SchemaPatch{}, "operations")
+	g.Require(models.PatchOp{}, "op")
+	g.Require(models.SavedQuery{}, "name", "project", "table")
+	g.Require(models.Report{}, "name", "saved_query", "schedule")
+	g.Require(models.ReportTarget{}, "type")
+	g.Require(InferSchemaRequest{}, "samples")
+	return g
+}
+
+// buildSpec 根据已注册的路由生成 OpenAPI 文档，只包含 operations 中描述的路由
+func buildSpec(routes gin.RoutesInfo) *openapi.Document {
+	g := newGenerator()
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "BlackSail Logs API",
+			Description: "按 schema 校验并存储结构化日志。除特别说明外，错误响应均为 ErrorResponse",
+			Version:     "v1",
+		},
+		Tags:  specTags,
+		Paths: make(map[string]*openapi.PathItem),
+	}
+	errorResponse := &openapi.Response{
+		Description: "错误",
+		Content:     map[string]*openapi.MediaType{"application/json": {Schema: g.Schema(ErrorResponse{})}},
+	}
+
+	for _, route := range routes {
+		op, ok := operations[route.Method+" "+route.Path]
+		if !ok {
+			continue
+		}
+		path, params := openAPIPath(route.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &openapi.PathItem{}
+			doc.Paths[path] = item
+		}
+
+		spec := &openapi.Operation{
+			OperationID: op.id,
+			Summary:     op.summary,
+			Tags:        []string{op.tag},
+			Responses:   map[string]*openapi.Response{"default": errorResponse},
+		}
+		for _, name := range params {
+			spec.Parameters = append(spec.Parameters, &openapi.Parameter{Name: name, In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}})
+		}
+		for _, p := range op.query {
+			spec.Parameters = append(spec.Parameters, p.parameter("query"))
+		}
+		for _, p := range op.headers {
+			spec.Parameters = append(spec.Parameters, p.parameter("header"))
+		}
+		if op.body != nil || len(op.mediaTypes) > 0 {
+			spec.RequestBody = &openapi.RequestBody{Required: true, Content: make(map[string]*openapi.MediaType)}
+			if op.body != nil {
+				spec.RequestBody.Content["application/json"] = &openapi.MediaType{Schema: g.Schema(op.body)}
+			}
+			for _, mediaType := range op.mediaTypes {
+				spec.RequestBody.Content[mediaType] = &openapi.MediaType{}
+			}
+		}
+		for status, body := range op.responses {
+			resp := &openapi.Response{Description: http.StatusText(status)}
+			if body != nil {
+				resp.Content = map[string]*openapi.MediaType{"application/json": {Schema: g.Schema(body)}}
+			}
+			spec.Responses[fmt.Sprint(status)] = resp
+		}
+		item.SetOperation(route.Method, spec)
+	}
+
+	doc.Components.Schemas = g.Components()
+	return doc
+}
+
+// parameter 转换为 OpenAPI 参数，未指定 schema 时为字符串
+func (p param) parameter(in string) *openapi.Parameter {
+	schema := p.schema
+	if schema == nil {
+		schema = &openapi.Schema{Type: "string"}
+	}
+	return &openapi.Parameter{Name: p.name, In: in, Description: p.description, Required: p.required, Schema: schema}
+}
+
+// openAPIPath 将 gin 路由中的 :name 转换为 {name}，同时返回路径参数名
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// handle 注册路由，开启请求校验且路由有接口描述时，在最终处理函数之前按 OpenAPI 文档校验参数与请求体，
+// 此时压缩的请求体已被前面的中间件解压
+func (s *Server) handle(method, path string, handlers ...gin.HandlerFunc) {
+	if _, ok := operations[method+" "+path]; ok && s.validateRequests {
+		last := len(handlers) - 1
+		handlers = append(handlers[:last:last], s.validateRequest(method, path), handlers[last])
+	}
+	s.router.Handle(method, path, handlers...)
+}
+
+// spec 返回当前服务器的 OpenAPI 文档，首次调用时生成
+func (s *Server) spec() *openapi.Document {
+	s.specOnce.Do(func() {
+		s.specDoc = buildSpec(s.router.Routes())
+	})
+	return s.specDoc
+}
+
+// validateRequest 校验查询参数与 JSON 请求体，不符合文档时返回 400
+func (s *Server) validateRequest(method, path string) gin.HandlerFunc {
+	openPath, _ := openAPIPath(path)
+	return func(c *gin.Context) {
+		doc := s.spec()
+		op := doc.Paths[openPath].Operation(method)
+
+		var problems []string
+		for _, p := range op.Parameters {
+			if p.In != "query" {
+				continue
+			}
+			values := c.QueryArray(p.Name)
+			if len(values) == 0 && p.Required {
+				problems = append(problems, "query parameter "+p.Name+" is required")
+			}
+			for _, value := range values {
+				if err := doc.ValidateParameter(p, value); err != nil {
+					problems = append(problems, err.Error())
+				}
+			}
+		}
+
+		if op.RequestBody != nil && bodyFormat(c) == formatJSON {
+			if media, ok := op.RequestBody.Content["application/json"]; ok && c.Request.Body != nil {
+				data, err := io.ReadAll(c.Request.Body)
+				if err != nil {
+					badRequest(c, err)
+					c.Abort()
+					return
+				}
+				c.Request.Body = io.NopCloser(bytes.NewReader(data))
+				// 无法解析的请求体交给处理函数返回原有的错误信息
+				if value, err := openapi.DecodeJSON(data); err == nil {
+					if err := doc.Validate(media.Schema, value, "body"); err != nil {
+						problems = append(problems, err.Error())
+					}
+				}
+			}
+		}
+
+		if len(problems) > 0 {
+			respondStatus(c, http.StatusBadRequest, CodeBadRequest, "request does not match the API spec: "+strings.Join(problems, "; "))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// serveSpec 返回 OpenAPI 文档
+func (s *Server) serveSpec(c *gin.Context) {
+	c.JSON(http.StatusOK, s.spec())
+}
+
+// swaggerUI 加载 /openapi.json 的 Swagger UI 页面，静态资源来自 CDN
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>BlackSail Logs API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// serveDocs 返回 Swagger UI 页面
+func (s *Server) serveDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUI))
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/openapi"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// newSpecServer 创建开启请求校验、使用 SQLite 存储的服务器
+func newSpecServer(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store, err := storage.New(context.Background(), storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return NewServer(store, &Config{StorageType: "sqlite", ValidateRequests: true, Pprof: true})
+}
+
+// collectRefs 收集文档中全部 $ref
+func collectRefs(value interface{}, refs map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if ref, ok := item.(string); ok && key == "$ref" {
+				refs[ref] = true
+			}
+			collectRefs(item, refs)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectRefs(item, refs)
+		}
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	server := newSpecServer(t)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc openapi.Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+	// 除文档与 pprof 外的路由都有描述，描述也都对应实际路由
+	documented := 0
+	ids := make(map[string]bool)
+	for _, route := range server.router.Routes() {
+		if route.Path == "/openapi.json" || route.Path == "/docs" || strings.HasPrefix(route.Path, "/debug/pprof") {
+			continue
+		}
+		path, _ := openAPIPath(route.Path)
+		item, ok := doc.Paths[path]
+		require.True(t, ok, "%s %s is not documented", route.Method, route.Path)
+		op := item.Operation(route.Method)
+		require.NotNil(t, op, "%s %s is not documented", route.Method, route.Path)
+		assert.False(t, ids[op.OperationID], "duplicate operationId %s", op.OperationID)
+		ids[op.OperationID] = true
+		documented++
+	}
+	assert.Equal(t, len(operations), documented, "operations without a route")
+
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+	refs := make(map[string]bool)
+	collectRefs(raw, refs)
+	assert.NotEmpty(t, refs)
+	for ref := range refs {
+		assert.Contains(t, doc.Components.Schemas, strings.TrimPrefix(ref, "#/components/schemas/"), ref)
+	}
+
+	insert := doc.Paths["/api/v1/logs/{project}/{table}"].Post
+	require.NotNil(t, insert)
+	assert.Equal(t, "insertLog", insert.OperationID)
+	assert.Equal(t, "project", insert.Parameters[0].Name)
+	assert.Contains(t, insert.RequestBody.Content, "application/x-protobuf")
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/openapi.json")
+}
+
+func TestRequestValidation(t *testing.T) {
+	server := newSpecServer(t)
+	doc := server.spec()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	// checkResponse 按文档校验成功响应
+	checkResponse := func(method, route string, w *httptest.ResponseRecorder) {
+		t.Helper()
+		path, _ := openAPIPath(route)
+		resp := doc.Paths[path].Operation(method).Responses[fmt.Sprint(w.Code)]
+		require.NotNil(t, resp, "%s %s: undocumented status %d", method, route, w.Code)
+		if resp.Content == nil {
+			assert.Empty(t, w.Body.String())
+			return
+		}
+		value, err := openapi.DecodeJSON(w.Body.Bytes())
+		require.NoError(t, err)
+		assert.NoError(t, doc.Validate(resp.Content["application/json"].Schema, value, "response"), "%s %s", method, route)
+	}
+
+	w := do(http.MethodPost, "/api/v1/schemas", `{"project": "app", "table": 1, "fields": [{"name": "path", "type": "text"}]}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "body.table: expected string, got number")
+	assert.Contains(t, w.Body.String(), `body.fields[0].type: \"text\" is not one of`)
+
+	w = do(http.MethodPost, "/api/v1/schemas", `{"project": "app", "table": "requests", "fields": [
+		{"name": "path", "type": "string", "required": true},
+		{"name": "trace_id", "type": "string", "indexed": true}
+	]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	checkResponse(http.MethodPost, "/api/v1/schemas", w)
+
+	w = do(http.MethodPost, "/api/v1/logs/app/requests", `{"level": 5, "message": "hit", "path": "/"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "body.level: expected string")
+	w = do(http.MethodPost, "/api/v1/logs/app/requests", `{"level": "info", "message": "hit", "path": "/", "trace_id": "t1", "timestamp": "2024-01-01T00:00:00Z"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	checkResponse(http.MethodPost, "/api/v1/logs/:project/:table", w)
+	// 未通过文档校验之外的错误仍由处理函数返回
+	w = do(http.MethodPost, "/api/v1/logs/app/requests", `{"level": "info", "message": "hit"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = do(http.MethodPost, "/api/v1/logs/app/requests", `{"level":`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NotContains(t, w.Body.String(), "API spec")
+
+	w = do(http.MethodPost, "/api/v1/logs/app/requests/search", `{"sort": ["-id"], "limit": 10}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	checkResponse(http.MethodPost, "/api/v1/logs/:project/:table/search", w)
+
+	w = do(http.MethodGet, "/api/v1/trace/t1?limit=0", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "query parameter limit: must be at least 1")
+	w = do(http.MethodGet, "/api/v1/trace/t1", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	checkResponse(http.MethodGet, "/api/v1/trace/:trace_id", w)
+
+	w = do(http.MethodGet, "/api/v1/schemas/app/requests?format=xml", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	for path, route := range map[string]string{
+		"/api/v1/schemas/app/requests": "/api/v1/schemas/:project/:table",
+		"/api/v1/schemas":              "/api/v1/schemas",
+		"/healthz":                     "/healthz",
+		"/api/v1/admin/read-only":      "/api/v1/admin/read-only",
+		"/api/v1/admin/telemetry":      "/api/v1/admin/telemetry",
+	} {
+		w = do(http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		checkResponse(http.MethodGet, route, w)
+	}
+
+	w = do(http.MethodPatch, "/api/v1/schemas/app/requests", `{"operations": [{"op": "rename_field"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPut, "/api/v1/admin/read-only", `{"enabled": "yes"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "body.enabled: expected boolean")
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/patterns"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+const (
+	// defaultPatternLimit 未指定 limit 时返回的模板数
+	defaultPatternLimit = 50
+	// defaultPatternSample 未指定 sample 时最多聚类的日志条数
+	defaultPatternSample = 10000
+	// maxPatternSample 单次最多聚类的日志条数
+	maxPatternSample = 100000
+)
+
+// LogPattern 一个消息模板及其在时间范围内的出现次数
+type LogPattern struct {
+	Template string  `json:"template"`
+	Count    int64   `json:"count"`
+	Ratio    float64 `json:"ratio"`  // 占聚类日志条数的比例
+	Sample   string  `json:"sample"` // 归入该模板的一条原始消息
+}
+
+// PatternsResponse 日志模板统计结果
+type PatternsResponse struct {
+	Project   string       `json:"project"`
+	Table     string       `json:"table"`
+	Scanned   int          `json:"scanned"`   // 参与聚类的日志条数
+	Truncated bool         `json:"truncated"` // 时间范围内的日志超过 sample，只聚类了最近的部分
+	Clusters  int          `json:"clusters"`  // 模板总数
+	Patterns  []LogPattern `json:"patterns"`
+}
+
+// logPatterns 将时间范围内最近的日志消息聚类为模板，按出现次数倒序返回，
+// 用于找出产生日志最多的语句
+func (s *Server) logPatterns(c *gin.Context) {
+	querier, ok := storage.As[storage.LogQuerier](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "log queries are not supported by this storage")
+		return
+	}
+	from, to, ok := timeRange(c)
+	if !ok {
+		return
+	}
+	limit, sample := defaultPatternLimit, defaultPatternSample
+	for param, target := range map[string]*int{"limit": &limit, "sample": &sample} {
+		if value := c.Query(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				respondStatus(c, http.StatusBadRequest, CodeBadRequest, "invalid "+param+": "+value)
+				return
+			}
+			*target = n
+		}
+	}
+	if sample > maxPatternSample {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("sample must not exceed %d", maxPatternSample))
+		return
+	}
+
+	// 多取一条用于判断是否截断
+	query := &models.Query{Fields: []string{"message"}, Sort: []string{"-timestamp"}, Limit: sample + 1}
+	if !from.IsZero() {
+		query.From = &from
+	}
+	if !to.IsZero() {
+		query.To = &to
+	}
+	if level := c.Query("level"); level != "" {
+		query.Filter = map[string]interface{}{"level": level}
+	}
+
+	project, table := c.Param("project"), c.Param("table")
+	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := query.Validate(schema, storage.BaseColumns(s.storage, schema)); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := s.guard.checkRange(c, project, table, query); err != nil {
+		s.respondQueryError(c, c.Request.Context(), err)
+		return
+	}
+	ctx, cancel := s.guard.context(c.Request.Context())
+	defer cancel()
+	start := time.Now()
+	rows, err := querier.SearchLogs(ctx, project, table, query)
+	s.recordSlowQuery(c, project, table, query, time.Since(start), len(rows), err)
+	if err != nil {
+		s.respondQueryError(c, ctx, err)
+		return
+	}
+
+	resp := PatternsResponse{Project: project, Table: table, Patterns: make([]LogPattern, 0)}
+	if len(rows) > sample {
+		rows, resp.Truncated = rows[:sample], true
+	}
+	miner := patterns.NewMiner(patterns.Config{})
+	for _, row := range rows {
+		message, _ := row["message"].(string)
+		miner.Add(message)
+	}
+	resp.Scanned = len(rows)
+
+	clusters := miner.Clusters()
+	resp.Clusters = len(clusters)
+	if len(clusters) > limit {
+		clusters = clusters[:limit]
+	}
+	for _, cluster := range clusters {
+		resp.Patterns = append(resp.Patterns, LogPattern{
+			Template: cluster.Template,
+			Count:    cluster.Count,
+			Ratio:    float64(cluster.Count) / float64(resp.Scanned),
+			Sample:   cluster.Sample,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestLogPatterns(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields: []*models.Field{
+			{Name: "level", Type: models.FieldTypeString},
+			{Name: "message", Type: models.FieldTypeString},
+		},
+	}))
+	server := NewServer(store, &Config{})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	w := do(http.MethodPost, "/api/v1/logs/app/events/batch", `[
+		{"level":"info","message":"user 1 logged in","timestamp":"2024-01-02T10:00:00Z"},
+		{"level":"info","message":"user 2 logged in","timestamp":"2024-01-02T10:01:00Z"},
+		{"level":"info","message":"user 3 logged in","timestamp":"2024-01-02T10:02:00Z"},
+		{"level":"error","message":"cache miss for key 'a'","timestamp":"2024-01-02T10:03:00Z"},
+		{"level":"info","message":"user 4 logged in","timestamp":"2024-01-03T10:00:00Z"}
+	]`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	get := func(query string) PatternsResponse {
+		w := do(http.MethodGet, "/api/v1/logs/app/events/patterns?"+query, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp PatternsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := get("from=2024-01-02T00:00:00Z&to=2024-01-03T00:00:00Z")
+	assert.Equal(t, 4, resp.Scanned)
+	assert.False(t, resp.Truncated)
+	require.Len(t, resp.Patterns, 2)
+	assert.Equal(t, "user <*> logged in", resp.Patterns[0].Template)
+	assert.EqualValues(t, 3, resp.Patterns[0].Count)
+	assert.InDelta(t, 0.75, resp.Patterns[0].Ratio, 1e-9)
+	assert.Equal(t, "cache miss for key <*>", resp.Patterns[1].Template)
+
+	resp = get("level=error")
+	require.Len(t, resp.Patterns, 1)
+	assert.Equal(t, "cache miss for key 'a'", resp.Patterns[0].Sample)
+
+	resp = get("sample=2&limit=1")
+	assert.Equal(t, 2, resp.Scanned)
+	assert.True(t, resp.Truncated)
+	assert.Len(t, resp.Patterns, 1)
+	assert.Equal(t, 2, resp.Clusters)
+
+	w = do(http.MethodGet, "/api/v1/logs/app/events/patterns?sample=0", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodGet, "/api/v1/logs/app/events/patterns?from=2024-01-03T00:00:00Z&to=2024-01-02T00:00:00Z", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	w = do(http.MethodGet, "/api/v1/logs/app/missing/patterns", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPprof(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	get := func(server *Server, path string) int {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	server := NewServer(nil, &Config{Pprof: true})
+	assert.Equal(t, http.StatusOK, get(server, "/debug/pprof/"))
+	assert.Equal(t, http.StatusOK, get(server, "/debug/pprof/goroutine?debug=1"))
+	assert.Equal(t, http.StatusNotFound, get(server, "/debug/pprof/unknown"))
+
+	assert.Equal(t, http.StatusNotFound, get(NewServer(nil, &Config{}), "/debug/pprof/"))
+}
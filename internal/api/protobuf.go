@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/schema"
+)
+
+// schemaFormatProtobuf 从 protobuf FileDescriptorSet 导入 schema
+const schemaFormatProtobuf = "protobuf"
+
+// importProtobuf 从请求体中的 FileDescriptorSet 为 message 参数列出的消息创建 schema。
+// 所有 schema 通过校验后才会写入；只转换一个消息时可以用 table 参数指定表名
+func (s *Server) importProtobuf(c *gin.Context) {
+	project := c.Query("project")
+	messages := c.QueryArray("message")
+	if project == "" || len(messages) == 0 {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "project and message are required")
+		return
+	}
+	table := c.Query("table")
+	if table != "" && len(messages) > 1 {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "table can only be set when importing a single message")
+		return
+	}
+	if s.rejectReadOnly(c, project) {
+		return
+	}
+
+	data, err := c.GetRawData()
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	schemas, err := schema.FromDescriptorSet(data, project, messages)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	if table != "" {
+		schemas[0].Table = table
+	}
+
+	now := time.Now()
+	for _, sc := range schemas {
+		sc.CreatedAt = now
+		sc.UpdatedAt = now
+		if err := sc.Validate(); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+	for _, sc := range schemas {
+		if err := s.storage.CreateSchema(c.Request.Context(), sc); err != nil {
+			respondError(c, err)
+			return
+		}
+		s.syncSchemaFile(sc)
+	}
+
+	c.JSON(http.StatusCreated, schemas)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestImportProtobuf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store, err := storage.New(ctx, storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	server := NewServer(store, &Config{})
+
+	message := func(name string, fields ...string) *descriptorpb.DescriptorProto {
+		msg := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+		for i, field := range fields {
+			msg.Field = append(msg.Field, &descriptorpb.FieldDescriptorProto{
+				Name:   proto.String(field),
+				Number: proto.Int32(int32(i + 1)),
+				Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			})
+		}
+		return msg
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:        proto.String("events.proto"),
+		Package:     proto.String("acme"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{message("Login", "level", "message", "user"), message("Logout", "user"), message("Bad", "Name")},
+	}}})
+	require.NoError(t, err)
+
+	post := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/schemas/import?format=protobuf&"+query, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("project=app&message=acme.Login&message=acme.Logout")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created []*models.Schema
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.Len(t, created, 2)
+	login, err := store.GetSchema(ctx, "app", "login")
+	require.NoError(t, err)
+	assert.Equal(t, models.FieldTypeString, login.GetField("user").Type)
+	_, err = store.GetSchema(ctx, "app", "logout")
+	assert.NoError(t, err)
+
+	w = post("project=app&message=acme.Login&table=sessions")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	_, err = store.GetSchema(ctx, "app", "sessions")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, post("message=acme.Login").Code)
+	assert.Equal(t, http.StatusBadRequest, post("project=app&message=acme.Login&message=acme.Logout&table=x").Code)
+	assert.Equal(t, http.StatusBadRequest, post("project=app&message=acme.Missing").Code)
+
+	// 任一 schema 校验失败时不写入任何 schema
+	w = post("project=other&message=acme.Logout&message=acme.Bad")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	_, err = store.GetSchema(ctx, "other", "logout")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyState 全局与项目级只读开关
+type readOnlyState struct {
+	mu       sync.RWMutex
+	global   bool
+	reason   string
+	projects map[string]string // project -> reason
+}
+
+// ReadOnlyStatus 只读状态
+type ReadOnlyStatus struct {
+	Enabled  bool              `json:"enabled"`
+	Reason   string            `json:"reason,omitempty"`
+	Projects map[string]string `json:"projects"`
+}
+
+// readOnlyRequest 修改只读开关的请求体
+type readOnlyRequest struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+// newReadOnlyState 根据配置创建只读状态
+func newReadOnlyState(global bool, projects []string) *readOnlyState {
+	state := &readOnlyState{
+		global:   global,
+		projects: make(map[string]string, len(projects)),
+	}
+	if global {
+		state.reason = "configured"
+	}
+	for _, project := range projects {
+		state.projects[project] = "configured"
+	}
+	return state
+}
+
+// check 返回只读提示及原因，可写时返回空字符串，project 为空时只检查全局开关
+func (r *readOnlyState) check(project string) (string, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.global {
+		return "server is in read-only mode", r.reason
+	}
+	if reason, ok := r.projects[project]; ok && project != "" {
+		return fmt.Sprintf("project %s is in read-only mode", project), reason
+	}
+	return "", ""
+}
+
+// setGlobal 设置全局只读开关
+func (r *readOnlyState) setGlobal(enabled bool, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.global = enabled
+	r.reason = ""
+	if enabled {
+		r.reason = reason
+	}
+}
+
+// setProject 设置项目只读开关
+func (r *readOnlyState) setProject(project string, enabled bool, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if enabled {
+		r.projects[project] = reason
+	} else {
+		delete(r.projects, project)
+	}
+}
+
+// status 返回当前只读状态
+func (r *readOnlyState) status() ReadOnlyStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	projects := make(map[string]string, len(r.projects))
+	for project, reason := range r.projects {
+		projects[project] = reason
+	}
+	return ReadOnlyStatus{Enabled: r.global, Reason: r.reason, Projects: projects}
+}
+
+// readOnlyMiddleware 在只读模式下拒绝写请求，查询（包括 POST 的日志检索）与管理接口不受影响
+func (s *Server) readOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead ||
+			c.Request.Method == http.MethodOptions || strings.HasPrefix(c.FullPath(), "/api/v1/admin/") ||
+			c.FullPath() == "/api/v1/logs/:project/:table/search" {
+			c.Next()
+			return
+		}
+		if s.rejectReadOnly(c, c.Param("project")) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// rejectReadOnly 项目处于只读模式时返回 503 并中止请求
+func (s *Server) rejectReadOnly(c *gin.Context, project string) bool {
+	msg, reason := s.readOnly.check(project)
+	if msg == "" {
+		return false
+	}
+
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+		"error":     msg,
+		"code":      CodeReadOnly,
+		"read_only": true,
+		"reason":    reason,
+	})
+	return true
+}
+
+// getReadOnly 返回只读状态
+func (s *Server) getReadOnly(c *gin.Context) {
+	c.JSON(http.StatusOK, s.readOnly.status())
+}
+
+// setReadOnly 设置全局只读开关
+func (s *Server) setReadOnly(c *gin.Context) {
+	var req readOnlyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	s.readOnly.setGlobal(req.Enabled, req.Reason)
+	c.JSON(http.StatusOK, s.readOnly.status())
+}
+
+// setProjectReadOnly 设置项目只读开关
+func (s *Server) setProjectReadOnly(c *gin.Context) {
+	var req readOnlyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	s.readOnly.setProject(c.Param("project"), req.Enabled, req.Reason)
+	c.JSON(http.StatusOK, s.readOnly.status())
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{readOnly: newReadOnlyState(false, []string{"audit"})}
+	router := gin.New()
+	router.Use(s.readOnlyMiddleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		router.Handle(method, "/api/v1/logs/:project/:table", ok)
+	}
+	router.POST("/api/v1/logs/:project/:table/search", ok)
+	router.PUT("/api/v1/admin/read-only", ok)
+	router.POST("/api/v1/admin/projects/:project/rollup", ok)
+	router.POST("/api/v1/schemas", ok)
+
+	status := func(method, path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		global      int // 全局只读时的状态码
+		projectOnly int // 只有 audit 项目只读时的状态码
+	}{
+		{"get", http.MethodGet, "/api/v1/logs/audit/events", http.StatusOK, http.StatusOK},
+		{"head", http.MethodHead, "/api/v1/logs/audit/events", http.StatusOK, http.StatusOK},
+		{"options", http.MethodOptions, "/api/v1/logs/audit/events", http.StatusOK, http.StatusOK},
+		{"search", http.MethodPost, "/api/v1/logs/audit/events/search", http.StatusOK, http.StatusOK},
+		{"admin", http.MethodPut, "/api/v1/admin/read-only", http.StatusOK, http.StatusOK},
+		{"admin with project", http.MethodPost, "/api/v1/admin/projects/audit/rollup", http.StatusOK, http.StatusOK},
+		{"insert", http.MethodPost, "/api/v1/logs/audit/events", http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"put", http.MethodPut, "/api/v1/logs/audit/events", http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"patch", http.MethodPatch, "/api/v1/logs/audit/events", http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"delete", http.MethodDelete, "/api/v1/logs/audit/events", http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"other project", http.MethodPost, "/api/v1/logs/app/events", http.StatusServiceUnavailable, http.StatusOK},
+		{"without project", http.MethodPost, "/api/v1/schemas", http.StatusServiceUnavailable, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.projectOnly, status(tt.method, tt.path), "project read-only")
+			s.readOnly.setGlobal(true, "maintenance")
+			defer s.readOnly.setGlobal(false, "")
+			assert.Equal(t, tt.global, status(tt.method, tt.path), "server read-only")
+		})
+	}
+}
+
+func TestReadOnlyAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app", Table: "events", Fields: []*models.Field{{Name: "user", Type: models.FieldTypeString}},
+	}))
+	server := NewServer(store, &Config{})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	insert := func() *httptest.ResponseRecorder {
+		return do(http.MethodPost, "/api/v1/logs/app/events", `{"level":"info","message":"m","fields":{"user":"alice"}}`)
+	}
+
+	require.Equal(t, http.StatusCreated, insert().Code)
+
+	w := do(http.MethodPut, "/api/v1/admin/read-only/app", `{"enabled":true,"reason":"migration"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = insert()
+	require.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, string(CodeReadOnly), body["code"])
+	assert.Equal(t, true, body["read_only"])
+	assert.Equal(t, "migration", body["reason"])
+	assert.Equal(t, "project app is in read-only mode", body["error"])
+
+	// 只读期间仍可查询，包括 POST 的检索
+	w = do(http.MethodPost, "/api/v1/logs/app/events/search", `{}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do(http.MethodGet, "/api/v1/admin/read-only", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enabled":false,"projects":{"app":"migration"}}`, w.Body.String())
+
+	w = do(http.MethodPut, "/api/v1/admin/read-only/app", `{"enabled":false}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do(http.MethodPut, "/api/v1/admin/read-only", `{"enabled":true,"reason":"backup"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = insert()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "server is in read-only mode")
+
+	// 管理接口不受只读模式影响，可以关闭只读
+	w = do(http.MethodPut, "/api/v1/admin/read-only", `{"enabled":false}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusCreated, insert().Code)
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// RenameSchemaRequest 重命名 schema 的请求，留空的字段沿用原名称
+type RenameSchemaRequest struct {
+	Project string `json:"project,omitempty"`
+	Table   string `json:"table,omitempty"`
+}
+
+// RenameProjectRequest 重命名项目的请求
+type RenameProjectRequest struct {
+	Project string `json:"project"`
+}
+
+// renamer 返回存储的重命名能力，不支持时返回 501
+func (s *Server) renamer(c *gin.Context) (storage.SchemaRenamer, bool) {
+	renamer, ok := storage.As[storage.SchemaRenamer](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "storage does not support renaming schemas")
+	}
+	return renamer, ok
+}
+
+// renameSchema 重命名 schema 及其日志表，已写入的日志保留
+func (s *Server) renameSchema(c *gin.Context) {
+	project := c.Param("project")
+	table := c.Param("table")
+
+	var req RenameSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if req.Project == "" {
+		req.Project = project
+	}
+	if req.Table == "" {
+		req.Table = table
+	}
+	if s.rejectReadOnly(c, req.Project) {
+		return
+	}
+	renamer, ok := s.renamer(c)
+	if !ok {
+		return
+	}
+
+	schema, err := s.rename(c, renamer, project, table, req.Project, req.Table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Header("ETag", schema.ETag())
+	c.JSON(http.StatusOK, schema)
+}
+
+// renameProject 将项目下的全部 schema 移动到新项目，任一目标已存在时不做任何修改
+func (s *Server) renameProject(c *gin.Context) {
+	project := c.Param("project")
+
+	var req RenameProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if req.Project == "" {
+		respondError(c, fmt.Errorf("%w: project is required", models.ErrValidation))
+		return
+	}
+	if s.rejectReadOnly(c, req.Project) {
+		return
+	}
+	renamer, ok := s.renamer(c)
+	if !ok {
+		return
+	}
+
+	schemas, err := s.storage.ListSchemas(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	existing := make(map[string]bool, len(schemas))
+	tables := make([]string, 0)
+	for _, schema := range schemas {
+		existing[schema.Project+":"+schema.Table] = true
+		if schema.Project == project {
+			tables = append(tables, schema.Table)
+		}
+	}
+	if len(tables) == 0 {
+		respondError(c, fmt.Errorf("%w: project %s has no schemas", models.ErrSchemaNotFound, project))
+		return
+	}
+	for _, table := range tables {
+		if existing[req.Project+":"+table] {
+			respondError(c, fmt.Errorf("%w: %s_%s", models.ErrSchemaExists, req.Project, table))
+			return
+		}
+	}
+
+	renamed := make([]*models.Schema, 0, len(tables))
+	for _, table := range tables {
+		schema, err := s.rename(c, renamer, project, table, req.Project, table)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		renamed = append(renamed, schema)
+	}
+	c.JSON(http.StatusOK, renamed)
+}
+
+// rename 重命名单个 schema 并同步 YAML 文件
+func (s *Server) rename(c *gin.Context, renamer storage.SchemaRenamer, project, table, newProject, newTable string) (*models.Schema, error) {
+	schema, err := renamer.RenameSchema(c.Request.Context(), project, table, newProject, newTable)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("schema renamed", zap.String("project", project), zap.String("table", table),
+		zap.String("new_project", newProject), zap.String("new_table", newTable))
+	if s.manager != nil {
+		if err := s.manager.RenameFile(project, table, schema); err != nil {
+			s.logger.Error("failed to rename schema file",
+				zap.String("project", newProject), zap.String("table", newTable), zap.Error(err))
+		}
+	}
+	return schema, nil
+}
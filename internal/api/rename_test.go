@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestRenameSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	server := NewServer(store, &Config{})
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	for _, table := range []string{"events", "audit"} {
+		require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+			Project: "app",
+			Table:   table,
+			Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString}},
+		}))
+	}
+	require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+		Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: time.Now(),
+		Fields: map[string]interface{}{"name": "kept"},
+	}))
+
+	w := do("/api/v1/schemas/app/events/rename", `{"table": "clicks"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var renamed models.Schema
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &renamed))
+	assert.Equal(t, "app", renamed.Project)
+	assert.Equal(t, "clicks", renamed.Table)
+	assert.Equal(t, http.StatusNotFound, do("/api/v1/schemas/app/events/rename", `{"table": "other"}`).Code)
+	assert.Equal(t, http.StatusConflict, do("/api/v1/schemas/app/clicks/rename", `{"table": "audit"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do("/api/v1/schemas/app/clicks/rename", `{"table": "Bad-Name"}`).Code)
+
+	// 目标项目中已有同名表时不修改任何 schema
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "web",
+		Table:   "audit",
+		Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString}},
+	}))
+	assert.Equal(t, http.StatusConflict, do("/api/v1/projects/app/rename", `{"project": "web"}`).Code)
+	_, err := store.GetSchema(ctx, "app", "clicks")
+	require.NoError(t, err)
+
+	w = do("/api/v1/projects/app/rename", `{"project": "shop"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var moved []*models.Schema
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &moved))
+	assert.Len(t, moved, 2)
+	rows, err := store.QueryLogs(ctx, "shop", "clicks", nil, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "kept", rows[0]["name"])
+	assert.Equal(t, http.StatusNotFound, do("/api/v1/projects/app/rename", `{"project": "shop"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do("/api/v1/projects/shop/rename", `{}`).Code)
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/cluster"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/report"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// reportStore 获取报表存储，未启用调度器或存储不支持时返回 501
+func (s *Server) reportStore(c *gin.Context) (storage.ReportStore, bool) {
+	store, ok := storage.As[storage.ReportStore](s.storage)
+	if !ok || s.reports == nil {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "reports are not enabled")
+		return nil, false
+	}
+	return store, true
+}
+
+// reportResponse 报表定义及下次执行时间
+type reportResponse struct {
+	*models.Report
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
+
+// withNextRun 附加调度器中的下次执行时间
+func (s *Server) withNextRun(r *models.Report) reportResponse {
+	resp := reportResponse{Report: r}
+	if next := s.reports.Next(r.Owner, r.Name); !next.IsZero() {
+		resp.NextRunAt = &next
+	}
+	return resp
+}
+
+// saveReport 创建或替换定时报表，保留原有的创建时间与执行状态
+func (s *Server) saveReport(c *gin.Context) {
+	store, ok := s.reportStore(c)
+	if !ok {
+		return
+	}
+
+	var r models.Report
+	if err := c.ShouldBindJSON(&r); err != nil {
+		badRequest(c, err)
+		return
+	}
+	r.Owner = requestOwner(c)
+	if err := r.Validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := report.ParseSchedule(r.Schedule); err != nil {
+		respondError(c, &models.ValidationError{Err: err})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if queries, ok := storage.As[storage.SavedQueryStore](s.storage); ok {
+		if _, err := queries.GetSavedQuery(ctx, r.Owner, r.SavedQuery); err != nil {
+			// 引用的保存查询不存在属于报表定义错误
+			if errors.Is(err, models.ErrSavedQueryNotFound) {
+				err = &models.ValidationError{Err: err}
+			}
+			respondError(c, err)
+			return
+		}
+	}
+
+	now := time.Now()
+	r.CreatedAt, r.UpdatedAt = now, now
+	r.LastRunAt, r.LastError = nil, ""
+	if existing, err := store.GetReport(ctx, r.Owner, r.Name); err == nil {
+		r.CreatedAt = existing.CreatedAt
+		r.LastRunAt, r.LastError = existing.LastRunAt, existing.LastError
+	}
+
+	if err := store.SaveReport(ctx, &r); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := s.reports.Sync(&r); err != nil {
+		respondError(c, err)
+		return
+	}
+	s.announce(ctx, &cluster.Message{Kind: cluster.KindReport, Owner: r.Owner, Name: r.Name})
+	c.JSON(http.StatusOK, s.withNextRun(&r))
+}
+
+// listReports 列出当前请求者的定时报表
+func (s *Server) listReports(c *gin.Context) {
+	store, ok := s.reportStore(c)
+	if !ok {
+		return
+	}
+
+	reports, err := store.ListReports(c.Request.Context(), requestOwner(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	resp := make([]reportResponse, 0, len(reports))
+	for _, r := range reports {
+		resp = append(resp, s.withNextRun(r))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// getReport 获取定时报表
+func (s *Server) getReport(c *gin.Context) {
+	store, ok := s.reportStore(c)
+	if !ok {
+		return
+	}
+
+	r, err := store.GetReport(c.Request.Context(), requestOwner(c), c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, s.withNextRun(r))
+}
+
+// deleteReport 删除定时报表并取消调度
+func (s *Server) deleteReport(c *gin.Context) {
+	store, ok := s.reportStore(c)
+	if !ok {
+		return
+	}
+
+	owner, name := requestOwner(c), c.Param("name")
+	if err := store.DeleteReport(c.Request.Context(), owner, name); err != nil {
+		respondError(c, err)
+		return
+	}
+	s.reports.Remove(owner, name)
+	s.announce(c.Request.Context(), &cluster.Message{Kind: cluster.KindReport, Owner: owner, Name: name})
+	c.Status(http.StatusNoContent)
+}
+
+// runReport 立即执行报表并投递，返回查询结果
+func (s *Server) runReport(c *gin.Context) {
+	if _, ok := s.reportStore(c); !ok {
+		return
+	}
+
+	result, err := s.reports.Run(c.Request.Context(), requestOwner(c), c.Param("name"))
+	if err != nil {
+		if errors.Is(err, models.ErrReportNotFound) || result == nil {
+			respondError(c, err)
+			return
+		}
+		// 查询成功但投递失败
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "code": CodeDeliveryFailed, "result": result})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
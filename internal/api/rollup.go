@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// DefaultRollupInterval 后台汇总过期原始日志的默认间隔
+const DefaultRollupInterval = time.Hour
+
+// RollupResponse 立即执行 rollup 的结果
+type RollupResponse struct {
+	Project string    `json:"project"`
+	Table   string    `json:"table"`
+	Count   int64     `json:"count"`  // 汇总并删除的原始日志条数
+	Before  time.Time `json:"before"` // 汇总的是早于该时间的日志
+}
+
+// roller 返回存储的 rollup 能力，不支持时返回 501
+func (s *Server) roller(c *gin.Context) (storage.Roller, bool) {
+	roller, ok := storage.As[storage.Roller](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "rollups are not supported by this storage")
+	}
+	return roller, ok
+}
+
+// queryRollup 查询 rollup 汇总结果
+func (s *Server) queryRollup(c *gin.Context) {
+	roller, ok := s.roller(c)
+	if !ok {
+		return
+	}
+	s.respondSeries(c, func(ctx context.Context, from, to time.Time) ([]map[string]interface{}, error) {
+		return roller.QueryRollup(ctx, c.Param("project"), c.Param("table"), c.Param("name"), from, to)
+	}, (*models.Schema).GetRollup)
+}
+
+// runRollup 立即汇总并删除超过 rollup_after 的原始日志，不等待后台任务
+func (s *Server) runRollup(c *gin.Context) {
+	roller, ok := s.roller(c)
+	if !ok {
+		return
+	}
+	project, table := c.Param("project"), c.Param("table")
+	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	before, ok := schema.RollupCutoff(time.Now())
+	if !ok {
+		respondError(c, fmt.Errorf("%w: schema %s/%s has no rollups", models.ErrValidation, project, table))
+		return
+	}
+
+	count, err := roller.RollupLogs(c.Request.Context(), project, table, before)
+	if count > 0 {
+		s.logger.Info("logs rolled up", zap.String("project", project), zap.String("table", table), zap.Int64("count", count))
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, RollupResponse{Project: project, Table: table, Count: count, Before: before})
+}
+
+// rollupAll 汇总所有定义了 rollup 的表，跳过只读项目，由后台任务 rollup 定期执行。
+// 一张表失败时继续汇总其余的表，返回全部失败
+func (s *Server) rollupAll(ctx context.Context) error {
+	roller, ok := storage.As[storage.Roller](s.storage)
+	if !ok {
+		return nil
+	}
+	schemas, err := s.storage.ListSchemas(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list schemas for rollup: %w", err)
+	}
+
+	now := time.Now()
+	var errs []error
+	for _, schema := range schemas {
+		before, ok := schema.RollupCutoff(now)
+		if !ok {
+			continue
+		}
+		if msg, _ := s.readOnly.check(schema.Project); msg != "" {
+			continue
+		}
+		count, err := roller.RollupLogs(ctx, schema.Project, schema.Table, before)
+		if count > 0 {
+			s.logger.Info("logs rolled up", zap.String("project", schema.Project),
+				zap.String("table", schema.Table), zap.Int64("count", count))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll up %s:%s: %w", schema.Project, schema.Table, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestRollup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	server := NewServer(store, &Config{})
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "user", Type: models.FieldTypeString}},
+		SchemaOptions: models.SchemaOptions{
+			RollupAfter: "7d",
+			Rollups:     []*models.Aggregate{{Name: "daily", Interval: "24h", GroupBy: []string{"user"}}},
+		},
+	}))
+	old := time.Now().AddDate(0, 0, -10)
+	for i, user := range []string{"alice", "alice", "bob"} {
+		require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+			Project: "app", Table: "events", Level: "info", Message: "m",
+			Timestamp: old.Add(time.Duration(i) * time.Second), Fields: map[string]interface{}{"user": user},
+		}))
+	}
+	require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+		Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: time.Now(),
+		Fields: map[string]interface{}{"user": "carol"},
+	}))
+
+	w := do(http.MethodPost, "/api/v1/logs/app/events/rollup")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp RollupResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.EqualValues(t, 3, resp.Count)
+	count, err := store.CountLogs(ctx, "app", "events", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	w = do(http.MethodGet, "/api/v1/logs/app/events/rollups/daily?to="+time.Now().Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rows))
+	assert.Len(t, rows, 2)
+	w = do(http.MethodGet, "/api/v1/logs/app/events/rollups/daily?from=yesterday")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 按调用方时区合并时间桶
+	w = do(http.MethodGet, "/api/v1/logs/app/events/rollups/daily?tz=UTC&interval=28d")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rows))
+	require.Len(t, rows, 2)
+	counts := map[interface{}]interface{}{}
+	for _, row := range rows {
+		counts[row["user"]] = row["count"]
+	}
+	assert.Equal(t, map[interface{}]interface{}{"alice": float64(2), "bob": float64(1)}, counts)
+	w = do(http.MethodGet, "/api/v1/logs/app/events/rollups/daily?tz=%2B05:30")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "UTC days cannot be split into local days")
+	w = do(http.MethodGet, "/api/v1/logs/app/events/rollups/daily?interval=36h")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = do(http.MethodGet, "/api/v1/logs/app/events/rollups/daily?interval=daily")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodGet, "/api/v1/logs/app/events/rollups/daily?tz=Mars/Olympus")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodGet, "/api/v1/logs/app/events/rollups/daily?tz=Asia/Shanghai&to=2000-01-01T08:00:00")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	// 后台任务跳过只读项目
+	require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+		Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: old,
+		Fields: map[string]interface{}{"user": "bob"},
+	}))
+	server.readOnly.setProject("app", true, "audit")
+	server.rollupAll(ctx)
+	count, err = store.CountLogs(ctx, "app", "events", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+	server.readOnly.setProject("app", false, "")
+	server.rollupAll(ctx)
+	count, err = store.CountLogs(ctx, "app", "events", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app", Table: "plain", Fields: []*models.Field{{Name: "user", Type: models.FieldTypeString}},
+	}))
+	w = do(http.MethodPost, "/api/v1/logs/app/plain/rollup")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// requestOwner 确定请求者身份：优先使用 API Key（仅保存摘要），其次 X-User 请求头
+func requestOwner(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:6])
+	}
+	if user := c.GetHeader("X-User"); user != "" {
+		return "user:" + user
+	}
+	return "anonymous"
+}
+
+// savedQueryStore 获取保存查询存储，不支持时返回 501
+func (s *Server) savedQueryStore(c *gin.Context) (storage.SavedQueryStore, bool) {
+	store, ok := storage.As[storage.SavedQueryStore](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "saved queries are not supported by this storage")
+	}
+	return store, ok
+}
+
+// saveQuery 创建或替换保存的查询
+func (s *Server) saveQuery(c *gin.Context) {
+	store, ok := s.savedQueryStore(c)
+	if !ok {
+		return
+	}
+
+	var query models.SavedQuery
+	if err := c.ShouldBindJSON(&query); err != nil {
+		badRequest(c, err)
+		return
+	}
+	query.Owner = requestOwner(c)
+
+	schema, err := s.storage.GetSchema(c.Request.Context(), query.Project, query.Table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := query.Validate(schema, storage.BaseColumns(s.storage, schema)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	now := time.Now()
+	query.CreatedAt = now
+	query.UpdatedAt = now
+	if err := store.SaveQuery(c.Request.Context(), &query); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	saved, err := store.GetSavedQuery(c.Request.Context(), query.Owner, query.Name)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+// listSavedQueries 列出当前请求者的保存查询
+func (s *Server) listSavedQueries(c *gin.Context) {
+	store, ok := s.savedQueryStore(c)
+	if !ok {
+		return
+	}
+
+	queries, err := store.ListSavedQueries(c.Request.Context(), requestOwner(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, queries)
+}
+
+// getSavedQuery 获取保存的查询
+func (s *Server) getSavedQuery(c *gin.Context) {
+	store, ok := s.savedQueryStore(c)
+	if !ok {
+		return
+	}
+
+	query, err := store.GetSavedQuery(c.Request.Context(), requestOwner(c), c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, query)
+}
+
+// deleteSavedQuery 删除保存的查询
+func (s *Server) deleteSavedQuery(c *gin.Context) {
+	store, ok := s.savedQueryStore(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteSavedQuery(c.Request.Context(), requestOwner(c), c.Param("name")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// executeSavedQuery 执行保存的查询，URL 参数用于填充 ${param} 模板参数，limit/offset 可覆盖保存的值
+func (s *Server) executeSavedQuery(c *gin.Context) {
+	store, ok := s.savedQueryStore(c)
+	if !ok {
+		return
+	}
+	querier, ok := storage.As[storage.LogQuerier](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "log queries are not supported by this storage")
+		return
+	}
+
+	saved, err := store.GetSavedQuery(c.Request.Context(), requestOwner(c), c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	params := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		if len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+	query, err := saved.Query.Bind(params)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for param, target := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if value := c.Query(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				respondStatus(c, http.StatusBadRequest, CodeBadRequest, "invalid "+param+": "+value)
+				return
+			}
+			*target = n
+		}
+	}
+	// fields 参数替换保存的返回列
+	if fields := fieldsParam(c); fields != nil {
+		query.Fields = fields
+	}
+	// sort 参数替换保存的排序
+	if sort := sortParam(c); sort != nil {
+		query.Sort = sort
+	}
+
+	// schema 可能在保存后发生变化，执行前重新校验
+	schema, err := s.storage.GetSchema(c.Request.Context(), saved.Project, saved.Table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := query.Validate(schema, storage.BaseColumns(s.storage, schema)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	rows, partial, err := s.guardedSearch(c, querier, schema, query)
+	if err != nil {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"name":           saved.Name,
+		"project":        saved.Project,
+		"table":          saved.Table,
+		"count":          len(rows),
+		"entries":        rows,
+		"partial":        partial != "",
+		"partial_reason": partial,
+	})
+}
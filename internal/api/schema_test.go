@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/schema"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestSchemaRegistryCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	registry := models.NewSchemaRegistry()
+	events, cancel := registry.Subscribe(10)
+	defer cancel()
+	server := NewServer(store, &Config{Schemas: registry})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/v1/schemas", `{"project":"app","table":"events","auto_evolve":true,
+		"fields":[{"name":"level","type":"string"},{"name":"message","type":"string"}]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	event := <-events
+	assert.Equal(t, models.SchemaCreated, event.Type)
+	assert.Len(t, event.Schema.Fields, 2)
+
+	// auto_evolve 添加的字段经由 UpdateSchema 同步到注册表
+	w = do(http.MethodPost, "/api/v1/logs/app/events", `{"level":"info","message":"m","user":"bob"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	event = <-events
+	assert.Equal(t, models.SchemaUpdated, event.Type)
+	assert.NotNil(t, event.Schema.GetField("user"))
+	cached, err := registry.Get("app", "events")
+	require.NoError(t, err)
+	assert.NotNil(t, cached.GetField("user"))
+
+	w = do(http.MethodDelete, "/api/v1/schemas/app/events", "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assert.Equal(t, models.SchemaDeleted, (<-events).Type)
+	w = do(http.MethodGet, "/api/v1/schemas/app/events", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSchemaIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	server := NewServer(store, &Config{})
+	do := func(method, path, body, ifMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	put := func(description, ifMatch string) *httptest.ResponseRecorder {
+		return do(http.MethodPut, "/api/v1/schemas/app/events", `{"project":"app","table":"events","description":"`+description+`",
+			"fields":[{"name":"user","type":"string"}]}`, ifMatch)
+	}
+
+	w := do(http.MethodPost, "/api/v1/schemas", `{"project":"app","table":"events","fields":[{"name":"user","type":"string"}]}`, "")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	created := w.Header().Get("ETag")
+	require.NotEmpty(t, created)
+
+	w = do(http.MethodGet, "/api/v1/schemas/app/events", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, created, w.Header().Get("ETag"), "GET returns the current version")
+
+	// 与当前版本一致时更新成功，并返回新的 ETag
+	w = put("v2", created)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	updated := w.Header().Get("ETag")
+	assert.NotEqual(t, created, updated)
+	w = do(http.MethodGet, "/api/v1/schemas/app/events", "", "")
+	assert.Equal(t, updated, w.Header().Get("ETag"))
+
+	// 持有旧版本的写入返回 409 与当前 ETag，schema 不变
+	w = put("stale", created)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Equal(t, updated, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), CodeConflict)
+	w = do(http.MethodPatch, "/api/v1/schemas/app/events", `{"operations":[{"op":"set_retention","retention":"7d"}]}`, created)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	current, err := store.GetSchema(ctx, "app", "events")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", current.Description)
+	assert.Empty(t, current.Retention)
+
+	// 弱 ETag 与列表中的任一版本匹配即可
+	w = put("v3", `"other", W/`+updated)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do(http.MethodPatch, "/api/v1/schemas/app/events", `{"operations":[{"op":"set_retention","retention":"7d"}]}`, w.Header().Get("ETag"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 未带 If-Match 或为 * 时不做校验，后写入的覆盖先写入的
+	w = put("v4", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = put("v5", "*")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	current, err = store.GetSchema(ctx, "app", "events")
+	require.NoError(t, err)
+	assert.Equal(t, "v5", current.Description)
+}
+
+func TestSchemaWriteLockSharedWithManager(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	dir := t.TempDir()
+	require.NoError(t, (&models.Schema{
+		Project: "app", Table: "events", Fields: []*models.Field{{Name: "user", Type: models.FieldTypeString}},
+	}).SaveToFile(filepath.Join(dir, "app_events.yaml")))
+	manager, err := schema.NewManager(store, dir)
+	require.NoError(t, err)
+	defer manager.Stop()
+	server := NewServer(store, &Config{SchemaManager: manager})
+	require.Same(t, manager.WriteLock(), server.schemaMu)
+
+	// API 写入期间文件的加载等待同一把锁
+	server.schemaMu.Lock()
+	started := make(chan error, 1)
+	go func() { started <- manager.Start() }()
+	select {
+	case err := <-started:
+		t.Fatalf("schema file loaded while an API write holds the lock: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	_, err = store.GetSchema(ctx, "app", "events")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+	server.schemaMu.Unlock()
+
+	require.NoError(t, <-started)
+	_, err = store.GetSchema(ctx, "app", "events")
+	assert.NoError(t, err)
+}
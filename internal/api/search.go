@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+const (
+	// defaultSearchLimit 查询未指定 limit 时返回的条数
+	defaultSearchLimit = 100
+	// healthTimeout 健康检查访问存储的超时时间
+	healthTimeout = 5 * time.Second
+)
+
+// searchLogs 按请求体中的查询检索日志。查询不修改数据，只读模式下同样可用。
+// limit 超过 MaxQueryRows 时只返回 MaxQueryRows 条，仍有更多匹配时标记 partial
+func (s *Server) searchLogs(c *gin.Context) {
+	querier, ok := storage.As[storage.LogQuerier](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "log queries are not supported by this storage")
+		return
+	}
+
+	var query models.Query
+	if err := c.ShouldBindJSON(&query); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultSearchLimit
+	}
+	if query.Limit < 0 || query.Offset < 0 {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "limit and offset must not be negative")
+		return
+	}
+	// 模板参数只用于保存的查询
+	bound, err := query.Bind(nil)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	project, table := c.Param("project"), c.Param("table")
+	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := bound.Validate(schema, storage.BaseColumns(s.storage, schema)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	rows, partial, err := s.guardedSearch(c, querier, schema, bound)
+	if err != nil {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"project":        project,
+		"table":          table,
+		"count":          len(rows),
+		"entries":        rows,
+		"partial":        partial != "",
+		"partial_reason": partial,
+	})
+}
+
+// fieldsParam 解析 fields 查询参数，逗号分隔或重复给出，去除空白与重复的列名；未指定时返回 nil
+func fieldsParam(c *gin.Context) []string {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, value := range c.QueryArray("fields") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" && !seen[name] {
+				seen[name] = true
+				fields = append(fields, name)
+			}
+		}
+	}
+	return fields
+}
+
+// sortParam 解析 sort 查询参数，逗号分隔或重复给出，去除空白；未指定时返回 nil
+func sortParam(c *gin.Context) []string {
+	var sort []string
+	for _, value := range c.QueryArray("sort") {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				sort = append(sort, item)
+			}
+		}
+	}
+	return sort
+}
+
+// guardedSearch 检查时间范围后在 QueryTimeout 内执行查询，返回的结果被 MaxQueryRows 截断时
+// partial 为 PartialMaxRows；按未建索引的列排序时写入 Warning 头；出错时已写入错误响应
+func (s *Server) guardedSearch(c *gin.Context, querier storage.LogQuerier, schema *models.Schema,
+	query *models.Query) (rows []map[string]interface{}, partial string, err error) {
+	project, table := schema.Project, schema.Table
+	if err := s.guard.checkRange(c, project, table, query); err != nil {
+		s.respondQueryError(c, c.Request.Context(), err)
+		return nil, "", err
+	}
+	limited := s.guard.limit(query)
+	// 按未建索引的列排序仍会执行，只以 Warning 头提示
+	for _, warning := range query.SortWarnings(schema) {
+		c.Writer.Header().Add("Warning", "199 - "+strconv.Quote(warning))
+	}
+
+	ctx, cancel := s.guard.context(c.Request.Context())
+	defer cancel()
+	start := time.Now()
+	rows, err = querier.SearchLogs(ctx, project, table, query)
+	s.recordSlowQuery(c, project, table, query, time.Since(start), len(rows), err)
+	if err != nil {
+		s.respondQueryError(c, ctx, err)
+		return nil, "", err
+	}
+	if rows == nil {
+		rows = make([]map[string]interface{}, 0)
+	}
+	if limited {
+		var truncated bool
+		if rows, truncated = s.guard.truncate(rows); truncated {
+			partial = PartialMaxRows
+		}
+	}
+	return rows, partial, nil
+}
+
+// health 检查存储是否可用，不可用时返回 503
+func (s *Server) health(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthTimeout)
+	defer cancel()
+
+	if err := s.storage.Ping(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "unavailable",
+			"storage": s.storageType,
+			"error":   err.Error(),
+			"code":    CodeBackendUnavailable,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "storage": s.storageType})
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestSearchLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := storage.New(context.Background(), storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "path", Type: models.FieldTypeString},
+			{Name: "status", Type: models.FieldTypeInt},
+		},
+	}))
+	now := time.Now()
+	for i, status := range []int{200, 500, 500} {
+		require.NoError(t, store.InsertLog(ctx, "app", "requests", &models.LogEntry{
+			Project:   "app",
+			Table:     "requests",
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			Level:     "info",
+			Message:   "request",
+			Fields:    map[string]interface{}{"path": "/", "status": status},
+		}))
+	}
+	server := NewServer(store, &Config{StorageType: "sqlite", ReadOnly: true})
+
+	search := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 只读模式下仍可查询
+	w := search("/api/v1/logs/app/requests/search", `{"filter": {"status": 500}, "fields": ["status"], "sort": ["-timestamp"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Count   int                      `json:"count"`
+		Entries []map[string]interface{} `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Count)
+	require.Len(t, resp.Entries, 2)
+	assert.EqualValues(t, 500, resp.Entries[0]["status"])
+
+	assert.Empty(t, w.Header().Values("Warning"))
+
+	w = search("/api/v1/logs/app/requests/search", `{"limit": 1}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Count)
+
+	// 未建索引的排序列仍执行，以 Warning 头提示
+	w = search("/api/v1/logs/app/requests/search", `{"fields": ["status"], "sort": ["status asc", "timestamp desc"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp.Entries = nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Entries, 3)
+	assert.EqualValues(t, 200, resp.Entries[0]["status"])
+	assert.Equal(t, []string{`199 - "sort field status is not indexed, matching rows are sorted without an index"`}, w.Header().Values("Warning"))
+	w = search("/api/v1/logs/app/requests/search", `{"sort": ["status sideways"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	// SQLite 日志表没有内置的 message 列，不能按它排序
+	w = search("/api/v1/logs/app/requests/search", `{"sort": ["message desc"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Empty(t, w.Header().Values("Warning"))
+
+	w = search("/api/v1/logs/app/requests/search", `{"filter": {"missing": 1}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = search("/api/v1/logs/app/requests/search", `{"filter": {"path": "${path}"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	// 超过 MaxQueryRows 的 limit 被截断，匹配的日志不足时不标记 partial
+	w = search("/api/v1/logs/app/requests/search", `{"limit": 100000}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"partial":false`)
+	w = search("/api/v1/logs/app/requests/search", `{"limit": -1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = search("/api/v1/logs/app/missing/search", `{}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	// 写入接口仍被拒绝
+	w = search("/api/v1/logs/app/requests", `{"message": "x"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHealth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := storage.New(context.Background(), storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	server := NewServer(store, &Config{StorageType: "sqlite"})
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		return w
+	}
+
+	w := get()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "ok", "storage": "sqlite"}`, w.Body.String())
+
+	require.NoError(t, store.Close())
+	w = get()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"unavailable"`)
+}
+
+func TestSearchLevelColumn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store, err := storage.New(ctx, storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	for _, schema := range []*models.Schema{
+		{Project: "app", Table: "plain", Fields: []*models.Field{{Name: "path", Type: models.FieldTypeString}}},
+		{Project: "app", Table: "leveled", Fields: []*models.Field{
+			{Name: "level", Type: models.FieldTypeString},
+			{Name: "path", Type: models.FieldTypeString},
+		}},
+	} {
+		require.NoError(t, store.CreateSchema(ctx, schema))
+		for _, level := range []string{"info", "error"} {
+			require.NoError(t, store.InsertLog(ctx, schema.Project, schema.Table, &models.LogEntry{
+				Project: schema.Project, Table: schema.Table, Timestamp: time.Now(),
+				Level: level, Message: "request", Fields: map[string]interface{}{"path": "/"},
+			}))
+		}
+	}
+	server := NewServer(store, &Config{StorageType: "sqlite"})
+	search := func(table, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/"+table+"/search", strings.NewReader(body)))
+		return w
+	}
+
+	// SQLite 日志表没有内置的 level 列，未声明时拒绝而不是与字符串常量比较
+	w := search("plain", `{"filter": {"level": "error"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "unknown filter field: level")
+
+	var resp struct {
+		Entries []map[string]interface{} `json:"entries"`
+	}
+	for filter, want := range map[string][]interface{}{
+		`{"level": "error"}`: {"error"},
+		`{"level": "level"}`: nil,
+	} {
+		w = search("leveled", `{"filter": `+filter+`, "fields": ["level"]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		resp.Entries = nil
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		var levels []interface{}
+		for _, entry := range resp.Entries {
+			levels = append(levels, entry["level"])
+		}
+		assert.Equal(t, want, levels, filter)
+	}
+}
+
+func TestSearchFieldsProjection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store, err := storage.New(ctx, storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	for _, schema := range []*models.Schema{
+		{Project: "app", Table: "plain", Fields: []*models.Field{{Name: "latency", Type: models.FieldTypeDuration}}},
+		{Project: "app", Table: "described", Fields: []*models.Field{
+			{Name: "message", Type: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeDuration},
+		}},
+	} {
+		require.NoError(t, store.CreateSchema(ctx, schema))
+		require.NoError(t, store.InsertLog(ctx, schema.Project, schema.Table, &models.LogEntry{
+			Project: schema.Project, Table: schema.Table, Timestamp: time.Now(),
+			Level: "info", Message: "hello", Fields: map[string]interface{}{"latency": 1500},
+		}))
+	}
+	server := NewServer(store, &Config{StorageType: "sqlite"})
+	search := func(table string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/"+table+"/search",
+			strings.NewReader(`{"fields": ["message", "latency"]}`)))
+		return w
+	}
+
+	// 表中没有 message 列时拒绝，而不是返回字符串常量
+	w := search("plain")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "unknown field: message")
+
+	w = search("described")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Entries []map[string]interface{} `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Entries, 1)
+	assert.Equal(t, map[string]interface{}{"message": "hello", "latency": float64(1500)}, resp.Entries[0])
+}
@@ -5,52 +5,315 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/anomaly"
+	"pkg.blksails.net/logs/internal/cluster"
+	"pkg.blksails.net/logs/internal/issues"
+	"pkg.blksails.net/logs/internal/jobs"
+	"pkg.blksails.net/logs/internal/logging"
+	"pkg.blksails.net/logs/internal/metrics"
 	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/openapi"
+	"pkg.blksails.net/logs/internal/report"
+	"pkg.blksails.net/logs/internal/schema"
+	"pkg.blksails.net/logs/internal/stats"
 	"pkg.blksails.net/logs/internal/storage"
+	"pkg.blksails.net/logs/pkg/clientip"
 )
 
 // Server 表示 API 服务器
 type Server struct {
 	storage storage.Storage
-	router  *gin.Engine
-	srv     *http.Server
+	manager *schema.Manager
+	reports *report.Scheduler
+	metrics *metrics.Registry
+	anomaly *anomaly.Detector
+	// stats 各表的写入统计
+	stats  *stats.Tracker
+	issues *issues.Processor
+	router *gin.Engine
+	srv    *http.Server
+	logger *zap.Logger
+
+	readOnly    *readOnlyState
+	telemetry   bool
+	storageType string
+	maxBody     int64
+	batchChunk  int
+	idempotency *idempotencyStore
+	pprof       bool
+
+	schemaWebhook string
+
+	// clientIP 取得写入日志与访问日志中的客户端地址
+	clientIP func(r *http.Request) string
+
+	// fingerprintHeaders TLS 指纹字段与填充该字段的请求头
+	fingerprintHeaders map[string]string
+
+	// mutationLimit 单次删除或修改日志允许匹配的最大条数，0 表示不限制
+	mutationLimit int64
+
+	// rollupInterval 后台汇总过期原始日志的间隔，0 表示不运行
+	rollupInterval time.Duration
+
+	// slowThreshold 记录慢查询的阈值，0 表示不记录；slowRetention 慢查询记录的保留时间
+	slowThreshold time.Duration
+	slowRetention time.Duration
+
+	// guard 查询的超时、行数上限与大表时间范围检查
+	guard *queryGuard
+	// jobs 归档清理、rollup 等后台维护任务
+	jobs *jobs.Scheduler
+
+	// archiveGrace 归档日志表的保留时间，小于 0 时不归档；done 在 Stop 时关闭以停止清理过期归档
+	archiveGrace time.Duration
+	done         chan struct{}
+	stopOnce     sync.Once
+
+	validateRequests bool
+	specOnce         sync.Once
+	specDoc          *openapi.Document
+
+	// schemas 与 storage 缓存共用的注册表，未开启缓存时为 nil
+	schemas *models.SchemaRegistry
+
+	// cluster 多实例部署的协调层，为 nil 时按单实例运行
+	cluster cluster.Coordinator
+
+	// schemaMu 串行化 schema 写操作，保证 If-Match 校验与写入之间不被其他请求插入。
+	// 设置了 SchemaManager 时与文件变更的写入共用同一把锁
+	schemaMu *sync.Mutex
 }
 
 // Config API 服务器配置
 type Config struct {
 	Host string
 	Port int
+
+	// SchemaManager 可选，用于暴露 schema 文件加载状态
+	SchemaManager *schema.Manager
+	// Schemas 可选，设置后 schema 的读取经由该注册表缓存，经由 API 的 schema 变更同步到注册表并通知订阅者。
+	// 与 SchemaManager 使用同一注册表时文件的变更立即可见。多个实例共享同一数据库时需同时设置 Cluster，
+	// 否则其他实例的修改不会使本实例的缓存失效
+	Schemas *models.SchemaRegistry
+
+	// Cluster 可选，多个实例共享同一数据库时的协调层：注册表的变更广播给其他实例使其缓存失效，
+	// 归档清理、rollup 与定时报表只在 leader 上执行
+	Cluster cluster.Coordinator
+
+	// ReadOnly 启动时即进入全局只读模式，拒绝写入但保留查询
+	ReadOnly bool
+	// ReadOnlyProjects 启动时处于只读模式的项目
+	ReadOnlyProjects []string
+
+	// Telemetry 是否允许发送使用情况遥测，默认关闭，DO_NOT_TRACK 环境变量可强制关闭
+	Telemetry bool
+
+	// ReportScheduler 可选，启用基于保存查询的定时报表
+	ReportScheduler *report.Scheduler
+
+	// Metrics 可选，写入成功的日志按规则计入 Prometheus 指标，并在 GET /metrics 暴露
+	Metrics *metrics.Registry
+
+	// Anomaly 可选，写入成功的日志计入按项目、表与级别的写入量异常检测
+	Anomaly *anomaly.Detector
+
+	// Issues 可选，写入成功的错误日志按指纹归并为问题
+	Issues *issues.Processor
+
+	// StorageType 存储类型，schema 写入时据此返回名称兼容性警告，为空时检查所有存储
+	StorageType string
+
+	// MaxDecompressedBody 压缩请求体解压后的上限，默认 DefaultMaxDecompressedBody
+	MaxDecompressedBody int64
+
+	// IdempotencyTTL 写入请求 Idempotency-Key 的保留时间，默认 DefaultIdempotencyTTL
+	IdempotencyTTL time.Duration
+
+	// BatchChunkSize 批量写入时每个事务提交的最大条数，默认 DefaultBatchChunkSize，小于 0 时整批在一个事务中写入。
+	// 请求带 atomic=true 时不分块
+	BatchChunkSize int
+
+	// Pprof 是否开启 /debug/pprof 性能分析接口
+	Pprof bool
+
+	// SchemaWebhook 可选，auto_evolve 自动添加字段后向该地址 POST 变更通知
+	SchemaWebhook string
+
+	// ValidateRequests 按 OpenAPI 文档校验查询参数与 JSON 请求体，不符合时返回 400
+	ValidateRequests bool
+
+	// SchemaArchiveGrace 删除 schema 后归档日志表的保留时间，超过后永久删除，默认 DefaultSchemaArchiveGrace。
+	// 小于 0 或存储不支持归档时，删除 schema 立即删除日志表
+	SchemaArchiveGrace time.Duration
+
+	// LogMutationLimit 单次按过滤条件删除或修改日志允许匹配的最大条数，超过时拒绝且不做修改，
+	// 默认 DefaultLogMutationLimit，小于 0 时不限制
+	LogMutationLimit int64
+
+	// RollupInterval 后台按 schema 的 rollups 汇总并删除过期原始日志的间隔，默认 DefaultRollupInterval，小于 0 时不运行
+	RollupInterval time.Duration
+
+	// QueryTimeout 查询日志的最长执行时间，超过时返回 504，默认 DefaultQueryTimeout，小于 0 时只受存储的查询超时约束
+	QueryTimeout time.Duration
+
+	// MaxQueryRows 单次查询最多返回的行数，请求的 limit 更大时只返回这么多行并标记 partial，默认 DefaultMaxQueryRows
+	MaxQueryRows int
+
+	// RangeRequiredRows 估算行数不少于该值的表，查询必须指定 from 或 to，请求带 allow_full_scan=true 时跳过；
+	// 行数来自存储的 SizeReporter，默认 0 不检查
+	RangeRequiredRows int64
+
+	// SlowQueryThreshold 查询访问存储的耗时不少于该值时记录到慢查询表，可通过 GET /api/v1/admin/slow-queries 查看，
+	// 默认 DefaultSlowQueryThreshold，小于 0 时不记录
+	SlowQueryThreshold time.Duration
+
+	// SlowQueryRetention 慢查询记录的保留时间，超过后由后台任务删除，默认 DefaultSlowQueryRetention
+	SlowQueryRetention time.Duration
+
+	// ClientIP 可选，取得写入日志的 ip 字段与访问日志中的客户端地址，可替换为自定义的解析方式。
+	// 为空时只使用连接地址，不读取任何代理头部；部署在负载均衡之后时使用 clientip.New 按受信任代理创建
+	ClientIP func(r *http.Request) string
+
+	// TLSFingerprintHeaders 字段名到请求头的对应关系，schema 声明了对应字段时写入日志以请求头的值填充，
+	// 默认 DefaultTLSFingerprintHeaders（ja4、ja4_string、tls_version）。请求头应由反向代理覆盖设置，不应透传客户端的值
+	TLSFingerprintHeaders map[string]string
+
+	// Logger 可选，记录访问日志与后台错误，为空时使用全局 logger
+	Logger *zap.Logger
 }
 
 // NewServer 创建新的 API 服务器
-func NewServer(storage storage.Storage, cfg *Config) *Server {
-	router := gin.Default()
+func NewServer(store storage.Storage, cfg *Config) *Server {
+	router := gin.New()
 	server := &Server{
-		storage: storage,
-		router:  router,
+		storage:     storage.WithSchemaCache(store, cfg.Schemas),
+		schemas:     cfg.Schemas,
+		cluster:     cfg.Cluster,
+		logger:      logging.Component(cfg.Logger, "api"),
+		manager:     cfg.SchemaManager,
+		reports:     cfg.ReportScheduler,
+		metrics:     cfg.Metrics,
+		anomaly:     cfg.Anomaly,
+		stats:       stats.New(nil),
+		issues:      cfg.Issues,
+		router:      router,
+		readOnly:    newReadOnlyState(cfg.ReadOnly, cfg.ReadOnlyProjects),
+		telemetry:   cfg.Telemetry,
+		storageType: cfg.StorageType,
+		maxBody:     cfg.MaxDecompressedBody,
+		batchChunk:  cfg.BatchChunkSize,
+		idempotency: newIdempotencyStore(cfg.IdempotencyTTL, nil),
+		pprof:       cfg.Pprof,
+
+		schemaWebhook:      cfg.SchemaWebhook,
+		clientIP:           cfg.ClientIP,
+		fingerprintHeaders: cfg.TLSFingerprintHeaders,
+		validateRequests:   cfg.ValidateRequests,
+		archiveGrace:       cfg.SchemaArchiveGrace,
+		mutationLimit:      cfg.LogMutationLimit,
+		rollupInterval:     cfg.RollupInterval,
+		slowThreshold:      cfg.SlowQueryThreshold,
+		slowRetention:      cfg.SlowQueryRetention,
+		done:               make(chan struct{}),
 		srv: &http.Server{
 			Addr:    fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 			Handler: router,
 		},
 	}
 
+	server.schemaMu = &sync.Mutex{}
+	if server.manager != nil {
+		server.schemaMu = server.manager.WriteLock()
+	}
+	if server.maxBody <= 0 {
+		server.maxBody = DefaultMaxDecompressedBody
+	}
+	switch {
+	case server.batchChunk == 0:
+		server.batchChunk = DefaultBatchChunkSize
+	case server.batchChunk < 0:
+		server.batchChunk = 0
+	}
+	if server.archiveGrace == 0 {
+		server.archiveGrace = DefaultSchemaArchiveGrace
+	}
+	switch {
+	case server.mutationLimit == 0:
+		server.mutationLimit = DefaultLogMutationLimit
+	case server.mutationLimit < 0:
+		server.mutationLimit = 0
+	}
+	if server.rollupInterval == 0 {
+		server.rollupInterval = DefaultRollupInterval
+	}
+	switch {
+	case server.slowThreshold == 0:
+		server.slowThreshold = DefaultSlowQueryThreshold
+	case server.slowThreshold < 0:
+		server.slowThreshold = 0
+	}
+	if server.slowRetention <= 0 {
+		server.slowRetention = DefaultSlowQueryRetention
+	}
+	if server.clientIP == nil {
+		direct, _ := clientip.New(clientip.Config{})
+		server.clientIP = direct.ClientIP
+	}
+	if len(server.fingerprintHeaders) == 0 {
+		server.fingerprintHeaders = DefaultTLSFingerprintHeaders
+	}
+
+	server.guard = &queryGuard{
+		timeout:   cfg.QueryTimeout,
+		maxRows:   cfg.MaxQueryRows,
+		rangeRows: cfg.RangeRequiredRows,
+		storage:   server.storage,
+		logger:    server.logger,
+	}
+	switch {
+	case server.guard.timeout == 0:
+		server.guard.timeout = DefaultQueryTimeout
+	case server.guard.timeout < 0:
+		server.guard.timeout = 0
+	}
+	if server.guard.maxRows <= 0 {
+		server.guard.maxRows = DefaultMaxQueryRows
+	}
+
+	server.jobs = server.newJobs(cfg.Logger)
+
+	router.Use(server.accessLog(), server.recovery())
 	server.setupRoutes()
 	return server
 }
 
-// Start 启动服务器
+// Start 启动服务器，并在后台定期永久删除超过宽限期的 schema 归档、汇总过期原始日志，
+// 设置了 Cluster 时与其他实例同步 schema 与报表的变更
 func (s *Server) Start() error {
+	s.jobs.Start()
+	go s.clusterLoop(s.done)
 	return s.srv.ListenAndServe()
 }
 
+// Handler 返回处理全部 API 请求的 http.Handler，用于嵌入其他 HTTP 服务或测试
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
 // Stop 停止服务器
 func (s *Server) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.done) })
+	s.jobs.Stop()
 	return s.srv.Shutdown(ctx)
 }
 
@@ -59,31 +322,108 @@ func (s *Server) setupRoutes() {
 	// 配置 CORS
 	s.router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Content-Encoding", "Authorization", "If-Match", "Idempotency-Key", "X-API-Key", "X-User"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", "Warning", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
+	s.router.Use(s.readOnlyMiddleware())
+
+	s.handle(http.MethodGet, "/healthz", s.health)
+
+	// OpenAPI 文档与 Swagger UI
+	s.router.GET("/openapi.json", s.serveSpec)
+	s.router.GET("/docs", s.serveDocs)
+
+	// 日志转换与后台任务的 Prometheus 指标
+	if s.metrics != nil {
+		s.router.GET("/metrics", s.serveMetrics)
+	}
 
 	// Schema 相关路由
-	s.router.POST("/api/v1/schemas", s.createSchema)
-	s.router.PUT("/api/v1/schemas/:project/:table", s.updateSchema)
-	s.router.DELETE("/api/v1/schemas/:project/:table", s.deleteSchema)
-	s.router.GET("/api/v1/schemas/:project/:table", s.getSchema)
-	s.router.GET("/api/v1/schemas", s.listSchemas)
+	s.handle(http.MethodPost, "/api/v1/schemas", s.createSchema)
+	s.handle(http.MethodPost, "/api/v1/schemas/infer", s.inferSchema)
+	s.handle(http.MethodPost, "/api/v1/schemas/import", s.importSchema)
+	s.handle(http.MethodPut, "/api/v1/schemas/:project/:table", s.updateSchema)
+	s.handle(http.MethodPatch, "/api/v1/schemas/:project/:table", s.patchSchema)
+	s.handle(http.MethodDelete, "/api/v1/schemas/:project/:table", s.deleteSchema)
+	s.handle(http.MethodGet, "/api/v1/schemas/:project/:table", s.getSchema)
+	s.handle(http.MethodGet, "/api/v1/schemas", s.listSchemas)
+	s.handle(http.MethodGet, "/api/v1/schemas/archived", s.listArchivedSchemas)
+	s.handle(http.MethodPost, "/api/v1/schemas/:project/:table/restore", s.restoreSchema)
+	s.handle(http.MethodPost, "/api/v1/schemas/:project/:table/rename", s.renameSchema)
+	s.handle(http.MethodPost, "/api/v1/projects/:project/rename", s.renameProject)
+
+	// 管理相关路由
+	s.handle(http.MethodGet, "/api/v1/admin/schemas/status", s.schemaManagerStatus)
+	s.handle(http.MethodGet, "/api/v1/admin/read-only", s.getReadOnly)
+	s.handle(http.MethodPut, "/api/v1/admin/read-only", s.setReadOnly)
+	s.handle(http.MethodPut, "/api/v1/admin/read-only/:project", s.setProjectReadOnly)
+	s.handle(http.MethodGet, "/api/v1/admin/telemetry", s.telemetryStatus)
+	s.handle(http.MethodGet, "/api/v1/admin/anomalies", s.anomalyStatus)
+	s.handle(http.MethodGet, "/api/v1/stats", s.ingestStats)
+	s.handle(http.MethodGet, "/api/v1/admin/storage", s.storageUsage)
+	s.handle(http.MethodPost, "/api/v1/admin/logs/:project/:table/explain", s.explainQuery)
+	s.handle(http.MethodGet, "/api/v1/admin/slow-queries", s.listSlowQueries)
+	s.handle(http.MethodGet, "/api/v1/admin/jobs", s.listJobs)
+	s.handle(http.MethodPost, "/api/v1/admin/jobs/:name/run", s.runJob)
+	s.handle(http.MethodGet, "/api/v1/admin/projects/:project/export", compressResponse(), s.exportProject)
+	s.handle(http.MethodPost, "/api/v1/admin/projects/:project/restore", decompressBody(0), s.restoreProject)
+
+	// 日志相关路由，写入接口接受 gzip/zstd 请求体，查询接口按 Accept-Encoding 压缩响应
+	s.handle(http.MethodPost, "/api/v1/logs/:project/:table", s.idempotent(), decompressBody(s.maxBody), s.insertLog)
+	s.handle(http.MethodPost, "/api/v1/logs/:project/:table/batch", s.idempotent(), decompressBody(s.maxBody), s.batchInsertLogs)
+	// 流式写入的请求体不限总大小，只限制单行长度
+	s.handle(http.MethodPost, "/api/v1/logs/:project/:table/stream", decompressBody(0), s.streamLogs)
+	s.handle(http.MethodPost, "/api/v1/logs/:project/:table/import", decompressBody(0), s.importLogs)
+	s.handle(http.MethodGet, "/api/v1/logs/:project/:table/aggregates/:name", compressResponse(), s.queryAggregate)
+	s.handle(http.MethodGet, "/api/v1/logs/:project/:table/rollups/:name", compressResponse(), s.queryRollup)
+	s.handle(http.MethodPost, "/api/v1/logs/:project/:table/rollup", s.runRollup)
+	s.handle(http.MethodPost, "/api/v1/logs/:project/:table/search", compressResponse(), s.searchLogs)
+	s.handle(http.MethodGet, "/api/v1/logs/:project/:table/patterns", compressResponse(), s.logPatterns)
+	s.handle(http.MethodGet, "/api/v1/logs/:project/:table/fields/:field/values", compressResponse(), s.fieldValues)
+	s.handle(http.MethodDelete, "/api/v1/logs/:project/:table", s.deleteLogs)
+	s.handle(http.MethodPatch, "/api/v1/logs/:project/:table", s.updateLogs)
+	s.handle(http.MethodPost, "/api/v1/test", s.test)
+
+	// 保存查询路由
+	s.handle(http.MethodPost, "/api/v1/saved-queries", s.saveQuery)
+	s.handle(http.MethodGet, "/api/v1/saved-queries", s.listSavedQueries)
+	s.handle(http.MethodGet, "/api/v1/saved-queries/:name", s.getSavedQuery)
+	s.handle(http.MethodDelete, "/api/v1/saved-queries/:name", s.deleteSavedQuery)
+	s.handle(http.MethodGet, "/api/v1/saved-queries/:name/results", compressResponse(), s.executeSavedQuery)
+
+	// 定时报表路由
+	s.handle(http.MethodPost, "/api/v1/reports", s.saveReport)
+	s.handle(http.MethodGet, "/api/v1/reports", s.listReports)
+	s.handle(http.MethodGet, "/api/v1/reports/:name", s.getReport)
+	s.handle(http.MethodDelete, "/api/v1/reports/:name", s.deleteReport)
+	s.handle(http.MethodPost, "/api/v1/reports/:name/run", s.runReport)
 
-	// 日志相关路由
-	s.router.POST("/api/v1/logs/:project/:table", s.insertLog)
-	s.router.POST("/api/v1/logs/:project/:table/batch", s.batchInsertLogs)
-	s.router.POST("/api/v1/test", s.test)
+	// 错误归并问题路由
+	s.handle(http.MethodGet, "/api/v1/issues", s.listIssues)
+	s.handle(http.MethodGet, "/api/v1/issues/:project/:table/:fingerprint", s.getIssue)
+	s.handle(http.MethodPatch, "/api/v1/issues/:project/:table/:fingerprint", s.updateIssue)
+	s.handle(http.MethodDelete, "/api/v1/issues/:project/:table/:fingerprint", s.deleteIssue)
+
+	// 关联查询路由
+	s.handle(http.MethodGet, "/api/v1/trace/:trace_id", compressResponse(), s.queryCorrelated("trace_id"))
+	s.handle(http.MethodGet, "/api/v1/request/:request_id", compressResponse(), s.queryCorrelated("request_id"))
+
+	if s.pprof {
+		s.registerPprof()
+	}
 }
 
 // createSchema 创建 schema
 func (s *Server) createSchema(c *gin.Context) {
 	var schema models.Schema
 	if err := c.ShouldBindJSON(&schema); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
+		return
+	}
+	if s.rejectReadOnly(c, schema.Project) {
 		return
 	}
 
@@ -94,17 +434,17 @@ func (s *Server) createSchema(c *gin.Context) {
 
 	// 验证 schema
 	if err := schema.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	// 创建 schema
 	if err := s.storage.CreateSchema(c.Request.Context(), &schema); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, schema)
+	s.respondSchema(c, http.StatusCreated, schema.Project, schema.Table, &schema)
 }
 
 // updateSchema 更新 schema
@@ -114,58 +454,184 @@ func (s *Server) updateSchema(c *gin.Context) {
 
 	var schema models.Schema
 	if err := c.ShouldBindJSON(&schema); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
 	// 确保路径参数匹配
 	if schema.Project != project || schema.Table != table {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "project and table in path must match body"})
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "project and table in path must match body")
 		return
 	}
 
+	s.schemaMu.Lock()
+	defer s.schemaMu.Unlock()
+
+	// 校验客户端持有的版本
+	if c.GetHeader("If-Match") != "" {
+		current, err := s.storage.GetSchema(c.Request.Context(), project, table)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if !checkIfMatch(c, current) {
+			return
+		}
+	}
+
 	// 更新时间戳
 	schema.UpdatedAt = time.Now()
 
 	// 验证 schema
 	if err := schema.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	// 更新 schema
 	if err := s.storage.UpdateSchema(c.Request.Context(), &schema); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, schema)
+	s.respondSchema(c, http.StatusOK, project, table, &schema)
+}
+
+// patchSchema 对 schema 执行局部更新操作
+func (s *Server) patchSchema(c *gin.Context) {
+	project := c.Param("project")
+	table := c.Param("table")
+
+	var patch models.SchemaPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	s.schemaMu.Lock()
+	defer s.schemaMu.Unlock()
+
+	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if !checkIfMatch(c, schema) {
+		return
+	}
+
+	// 基于最新版本应用操作
+	if err := schema.ApplyPatch(&patch); err != nil {
+		respondError(c, err)
+		return
+	}
+	schema.UpdatedAt = time.Now()
+
+	if err := s.storage.UpdateSchema(c.Request.Context(), schema); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	s.respondSchema(c, http.StatusOK, project, table, schema)
 }
 
-// deleteSchema 删除 schema
+// checkIfMatch 校验 If-Match 请求头与当前 schema 的 ETag，不匹配时返回 409
+func checkIfMatch(c *gin.Context, current *models.Schema) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return true
+	}
+
+	etag := current.ETag()
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+
+	c.Header("ETag", etag)
+	c.JSON(http.StatusConflict, gin.H{
+		"error": "schema has been modified since it was read",
+		"code":  CodeConflict,
+		"etag":  etag,
+	})
+	return false
+}
+
+// respondSchema 返回存储中的最新 schema 及其 ETag，读取失败时退回到写入的内容，并同步到 YAML 文件。
+// 字段名在当前存储上的兼容性问题以 Warning 头返回
+func (s *Server) respondSchema(c *gin.Context, status int, project, table string, written *models.Schema) {
+	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
+	if err != nil {
+		schema = written
+	}
+	s.syncSchemaFile(schema)
+	for _, warning := range schema.CompatibilityWarnings(s.storageType) {
+		c.Writer.Header().Add("Warning", "199 - "+strconv.Quote(warning))
+	}
+	c.Header("ETag", schema.ETag())
+	c.JSON(status, schema)
+}
+
+// syncSchemaFile 开启反向同步时将写入存储的 schema 写回 YAML 文件，失败不影响已完成的写入
+func (s *Server) syncSchemaFile(schema *models.Schema) {
+	if s.manager == nil {
+		return
+	}
+	if err := s.manager.WriteBack(schema); err != nil {
+		s.logger.Error("failed to write back schema",
+			zap.String("project", schema.Project), zap.String("table", schema.Table), zap.Error(err))
+	}
+}
+
+// deleteSchema 删除 schema。存储支持归档时默认软删除，日志表在宽限期后才永久删除；hard=true 时立即删除日志表
 func (s *Server) deleteSchema(c *gin.Context) {
 	project := c.Param("project")
 	table := c.Param("table")
 
-	if err := s.storage.DeleteSchema(c.Request.Context(), project, table); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	hard, _ := strconv.ParseBool(c.Query("hard"))
+	if archiver, ok := s.archiver(); ok && !hard {
+		archived, err := archiver.ArchiveSchema(c.Request.Context(), project, table)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		s.logger.Info("schema archived", zap.String("project", project), zap.String("table", table),
+			zap.String("archive_table", archived.ArchiveTable))
+	} else if err := s.storage.DeleteSchema(c.Request.Context(), project, table); err != nil {
+		respondError(c, err)
 		return
 	}
+	if s.manager != nil {
+		if err := s.manager.DeleteFile(project, table); err != nil {
+			s.logger.Error("failed to remove schema file",
+				zap.String("project", project), zap.String("table", table), zap.Error(err))
+		}
+	}
 
 	c.Status(http.StatusNoContent)
 }
 
-// getSchema 获取 schema
+// getSchema 获取 schema，format=jsonschema 时返回 JSON Schema 文档
 func (s *Server) getSchema(c *gin.Context) {
 	project := c.Param("project")
 	table := c.Param("table")
+	format, ok := checkSchemaFormat(c)
+	if !ok {
+		return
+	}
 
 	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
+	if format == schemaFormatJSONSchema {
+		respondJSONSchema(c, schema)
+		return
+	}
+	c.Header("ETag", schema.ETag())
 	c.JSON(http.StatusOK, schema)
 }
 
@@ -173,19 +639,49 @@ func (s *Server) getSchema(c *gin.Context) {
 func (s *Server) listSchemas(c *gin.Context) {
 	schemas, err := s.storage.ListSchemas(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, schemas)
 }
 
+// schemaManagerStatus 返回 schema 管理器状态及文件冲突
+func (s *Server) schemaManagerStatus(c *gin.Context) {
+	if s.manager == nil {
+		respondStatus(c, http.StatusNotFound, CodeNotFound, "schema manager not enabled")
+		return
+	}
+
+	c.JSON(http.StatusOK, s.manager.Status())
+}
+
+// parseTags 解析请求中的 tags 对象，值需为字符串、数字或布尔值
+func parseTags(raw interface{}) (map[string]string, error) {
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("tags must be an object, got %T", raw)
+	}
+	tags := make(map[string]string, len(obj))
+	for key, value := range obj {
+		switch v := value.(type) {
+		case string:
+			tags[key] = v
+		case float64, int64, bool:
+			tags[key] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("invalid value for tag %s: %T", key, value)
+		}
+	}
+	return tags, nil
+}
+
 // deserializeLogEntry 反序列化日志条目
 func (s *Server) deserializeLogEntry(c *gin.Context, project, table string, rawData map[string]interface{}) (*models.LogEntry, error) {
 	// 获取 schema
 	schema, err := s.storage.GetSchema(c.Request.Context(), project, table)
 	if err != nil {
-		return nil, fmt.Errorf("schema not found: %v", err)
+		return nil, err
 	}
 
 	// 创建日志条目
@@ -193,7 +689,7 @@ func (s *Server) deserializeLogEntry(c *gin.Context, project, table string, rawD
 		Project:   project,
 		Table:     table,
 		Timestamp: time.Now(),
-		IP:        c.ClientIP(),
+		IP:        s.clientIP(c.Request),
 		Fields:    make(map[string]interface{}),
 	}
 
@@ -212,16 +708,33 @@ func (s *Server) deserializeLogEntry(c *gin.Context, project, table string, rawD
 		}
 		delete(rawData, "timestamp")
 	}
-
-	// 找到 Rest 字段（如果存在）
-	var restField *models.Field
-	for _, field := range schema.Fields {
-		if field.Type == models.FieldTypeRest {
-			restField = field
-			break
+	// 类型转换失败的字段与 schema 校验结果一并返回
+	var fieldErrs []*models.FieldError
+	if raw, ok := rawData[models.TagsColumn]; ok && schema.StoresTags() {
+		tags, err := parseTags(raw)
+		if err != nil {
+			fieldErrs = append(fieldErrs, models.InvalidField(models.TagsColumn, models.FieldTypeObject, raw, err))
 		}
+		log.Tags = tags
+		delete(rawData, models.TagsColumn)
 	}
 
+	// 开启 auto_evolve 时先为未知字段添加列
+	schema, err = s.evolveSchema(c.Request.Context(), schema, rawData)
+	if err != nil {
+		return nil, err
+	}
+
+	// 由请求头填充 TLS 指纹字段与 schema 要求记录的请求头
+	s.applyFingerprintHeaders(c.Request, schema, rawData)
+	applyCapturedHeaders(c.Request, schema, rawData)
+
+	// strict schema 拒绝未定义的字段
+	fieldErrs = append(fieldErrs, schema.UnknownFields(rawData)...)
+
+	// 找到 Rest 字段（如果存在）
+	restField := schema.RestField()
+
 	// 处理其他字段
 	for name, value := range rawData {
 		// 查找字段定义
@@ -238,7 +751,8 @@ func (s *Server) deserializeLogEntry(c *gin.Context, project, table string, rawD
 			// 根据字段类型转换值
 			convertedValue, err := convertFieldValue(value, fieldDef.Type)
 			if err != nil {
-				return nil, fmt.Errorf("invalid field value for %s: %v", name, err)
+				fieldErrs = append(fieldErrs, models.InvalidField(name, fieldDef.Type, value, err))
+				continue
 			}
 			log.Fields[name] = convertedValue
 		} else if restField != nil {
@@ -251,9 +765,24 @@ func (s *Server) deserializeLogEntry(c *gin.Context, project, table string, rawD
 		}
 	}
 
-	// 验证日志数据
+	// 验证日志数据，转换失败的字段不再重复报告为缺失
 	if err := schema.ValidateLogEntry(log); err != nil {
-		return nil, fmt.Errorf("invalid log data: %v", err)
+		if len(fieldErrs) == 0 && models.FieldErrors(err) == nil {
+			return nil, fmt.Errorf("invalid log data: %w", err)
+		}
+		reported := make(map[string]bool, len(fieldErrs))
+		for _, fe := range fieldErrs {
+			reported[fe.Field] = true
+		}
+		for _, fe := range models.FieldErrors(err) {
+			if !reported[fe.Field] {
+				fieldErrs = append(fieldErrs, fe)
+			}
+		}
+	}
+	sort.Slice(fieldErrs, func(i, j int) bool { return fieldErrs[i].Field < fieldErrs[j].Field })
+	if err := models.NewFieldErrors(fieldErrs); err != nil {
+		return nil, fmt.Errorf("invalid log data: %w", err)
 	}
 
 	return log, nil
@@ -263,38 +792,33 @@ func (s *Server) deserializeLogEntry(c *gin.Context, project, table string, rawD
 func (s *Server) insertLog(c *gin.Context) {
 	project := c.Param("project")
 	table := c.Param("table")
-	XJA4 := c.GetHeader("X-JA4")              // 获取 X-JA4 头
-	XJA4String := c.GetHeader("X-JA4-String") // 获取 X-JA4-String 头
-	fmt.Println("XJA4", XJA4)
-	fmt.Println("XJA4String", XJA4String)
-
-	// 解析请求数据
-	var rawData map[string]interface{}
-	if err := c.ShouldBindJSON(&rawData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+	// 解析请求数据，支持 JSON、MessagePack 与 Protobuf
+	rawData, err := bindLog(c)
+	if err != nil {
+		badRequest(c, err)
 		return
 	}
 
-	fmt.Println("rawData", rawData)
-
 	// 反序列化日志条目
 	log, err := s.deserializeLogEntry(c, project, table, rawData)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if models.FieldErrors(err) != nil {
+			s.stats.Reject(project, table, 1)
+		}
+		respondError(c, err)
 		return
 	}
 
-	// 新增：插入 XJA4 和 XJA4String 字段
-	log.Fields["XJA4"] = XJA4
-	log.Fields["XJA4String"] = XJA4String
-	log.Fields["ip"] = c.ClientIP()
-	fmt.Println("log数据", log)
+	log.Fields["ip"] = s.clientIP(c.Request)
 
 	// 插入日志
 	if err := s.storage.InsertLog(c.Request.Context(), project, table, log); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		s.stats.Reject(project, table, 1)
+		respondError(c, err)
 		return
 	}
+	s.observe(c.Request.Context(), project, table, []*models.LogEntry{log})
 
 	c.Status(http.StatusCreated)
 }
@@ -312,7 +836,7 @@ func (s *Server) test(c *gin.Context) {
 	}
 	err := s.storage.InsertLog(c.Request.Context(), "myapp", "applogs", log)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -321,45 +845,286 @@ func (s *Server) test(c *gin.Context) {
 	})
 }
 
+// DefaultBatchChunkSize 批量写入时每个事务默认提交的最大条数
+const DefaultBatchChunkSize = 1000
+
+// BatchResponse 批量写入成功时的响应
+type BatchResponse struct {
+	Accepted int            `json:"accepted"`
+	Entries  []*InsertedLog `json:"entries"` // 与请求中的日志一一对应
+}
+
+// InsertedLog 已写入日志的 ID 与时间，客户端可据此引用具体的日志
+type InsertedLog struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"` // 以 UTC 表示
+}
+
+// BatchResult partial=true 时批量写入的结果
+type BatchResult struct {
+	Accepted int                 `json:"accepted"`
+	Rejected int                 `json:"rejected"`
+	Results  []*BatchEntryResult `json:"results"` // 与请求中的日志一一对应
+}
+
+// BatchEntryResult 单条日志的写入结果
+type BatchEntryResult struct {
+	Index     int                  `json:"index"`  // 日志在请求中的位置，从 0 开始
+	Status    int                  `json:"status"` // 201 已写入，422 未通过校验
+	ID        string               `json:"id,omitempty"`
+	Timestamp *time.Time           `json:"timestamp,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	Fields    []*models.FieldError `json:"fields,omitempty"`
+}
+
+// returnMinimal 请求带 Prefer: return=minimal 时不返回写入日志的 ID 与时间，减少大批量写入的响应开销
+func returnMinimal(c *gin.Context) bool {
+	for _, prefer := range c.Request.Header.Values("Prefer") {
+		for _, token := range strings.Split(prefer, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "return=minimal") {
+				c.Header("Preference-Applied", "return=minimal")
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// insertedLog 返回已写入日志的 ID 与 UTC 时间
+func insertedLog(log *models.LogEntry) *InsertedLog {
+	return &InsertedLog{ID: log.ID, Timestamp: log.Timestamp.UTC()}
+}
+
 // batchInsertLogs 批量插入日志
 func (s *Server) batchInsertLogs(c *gin.Context) {
 	project := c.Param("project")
 	table := c.Param("table")
 
-	// 解析请求数据
-	var rawLogs []map[string]interface{}
-	if err := c.ShouldBindJSON(&rawLogs); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// 解析请求数据，支持 JSON、MessagePack 与 Protobuf
+	rawLogs, err := bindLogBatch(c)
+	if err != nil {
+		badRequest(c, err)
 		return
 	}
 
-	// 处理每条日志
+	atomic, _ := strconv.ParseBool(c.Query("atomic"))
+	if partial, _ := strconv.ParseBool(c.Query("partial")); partial {
+		s.batchInsertPartial(c, project, table, rawLogs, atomic)
+		return
+	}
+
+	// 处理每条日志，校验错误按日志位置汇总后一次返回
 	logs := make([]*models.LogEntry, 0, len(rawLogs))
-	for _, rawData := range rawLogs {
+	var fieldErrs []*models.FieldError
+	for i, rawData := range rawLogs {
 		// 反序列化日志条目
 		log, err := s.deserializeLogEntry(c, project, table, rawData)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+			errs := models.FieldErrors(err)
+			if errs == nil {
+				respondError(c, err)
+				return
+			}
+			for _, fe := range errs {
+				fe.Index = &i
+			}
+			fieldErrs = append(fieldErrs, errs...)
+			continue
 		}
-		// 新增：插入 XJA4 和 XJA4String 字段
-		log.Fields["XJA4"] = c.GetHeader("X-JA4")
-		log.Fields["XJA4String"] = c.GetHeader("X-JA4-String")
-		log.Fields["ip"] = c.ClientIP()
+		log.Fields["ip"] = s.clientIP(c.Request)
 		logs = append(logs, log)
 	}
+	if err := models.NewFieldErrors(fieldErrs); err != nil {
+		s.stats.Reject(project, table, len(rawLogs))
+		respondError(c, fmt.Errorf("invalid log data: %w", err))
+		return
+	}
 
 	// 批量插入日志
-	if err := s.storage.BatchInsertLogs(c.Request.Context(), project, table, logs); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if accepted, err := s.storeBatch(c.Request.Context(), project, table, logs, atomic); err != nil {
+		respondStoreError(c, err, accepted)
 		return
 	}
 
-	c.Status(http.StatusCreated)
+	if returnMinimal(c) {
+		c.Status(http.StatusCreated)
+		return
+	}
+	resp := &BatchResponse{Accepted: len(logs), Entries: make([]*InsertedLog, len(logs))}
+	for i, log := range logs {
+		resp.Entries[i] = insertedLog(log)
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// storeBatch 写入已通过校验的日志。atomic 为 false 时按 batchChunk 分块，每块在独立的事务中提交，
+// 避免超大事务长时间持有锁；出错时返回之前已提交的条数，已提交的分块不回滚
+func (s *Server) storeBatch(ctx context.Context, project, table string, logs []*models.LogEntry, atomic bool) (int, error) {
+	size := len(logs)
+	if !atomic && s.batchChunk > 0 && s.batchChunk < size {
+		size = s.batchChunk
+	}
+	stored := 0
+	for stored < len(logs) {
+		chunk := logs[stored:min(stored+size, len(logs))]
+		if err := s.storage.BatchInsertLogs(ctx, project, table, chunk); err != nil {
+			s.stats.Reject(project, table, len(logs)-stored)
+			return stored, err
+		}
+		s.observe(ctx, project, table, chunk)
+		stored += len(chunk)
+	}
+	return stored, nil
+}
+
+// batchInsertPartial 校验全部日志后只写入通过校验的日志，以 207 返回每条日志的结果，
+// 个别无效的日志不再阻止整批写入。存储写入失败时返回错误与已提交的条数
+func (s *Server) batchInsertPartial(c *gin.Context, project, table string, rawLogs []map[string]interface{}, atomic bool) {
+	result := &BatchResult{Results: make([]*BatchEntryResult, len(rawLogs))}
+	logs := make([]*models.LogEntry, 0, len(rawLogs))
+	for i, rawData := range rawLogs {
+		log, err := s.deserializeLogEntry(c, project, table, rawData)
+		if err != nil {
+			fields := models.FieldErrors(err)
+			if fields == nil {
+				respondError(c, err)
+				return
+			}
+			result.Rejected++
+			result.Results[i] = &BatchEntryResult{Index: i, Status: http.StatusUnprocessableEntity, Error: err.Error(), Fields: fields}
+			continue
+		}
+		log.Fields["ip"] = s.clientIP(c.Request)
+		logs = append(logs, log)
+		result.Accepted++
+		result.Results[i] = &BatchEntryResult{Index: i, Status: http.StatusCreated}
+	}
+
+	s.stats.Reject(project, table, result.Rejected)
+	if accepted, err := s.storeBatch(c.Request.Context(), project, table, logs, atomic); err != nil {
+		respondStoreError(c, err, accepted)
+		return
+	}
+
+	// 已写入的日志按顺序对应状态为 201 的结果
+	if !returnMinimal(c) {
+		next := 0
+		for _, entry := range result.Results {
+			if entry.Status == http.StatusCreated {
+				inserted := insertedLog(logs[next])
+				entry.ID, entry.Timestamp = inserted.ID, &inserted.Timestamp
+				next++
+			}
+		}
+	}
+	c.JSON(http.StatusMultiStatus, result)
+}
+
+// observe 将成功写入的日志计入写入统计、指标与写入量异常检测，并将错误日志归并为问题
+func (s *Server) observe(ctx context.Context, project, table string, logs []*models.LogEntry) {
+	s.stats.Observe(project, table, logs)
+	s.metrics.Observe(project, table, logs)
+	s.anomaly.Observe(project, table, logs)
+	s.issues.Observe(ctx, project, table, logs)
+}
+
+// queryAggregate 查询持续聚合结果
+func (s *Server) queryAggregate(c *gin.Context) {
+	querier, ok := storage.As[storage.ContinuousQuerier](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "continuous aggregates are not supported by this storage")
+		return
+	}
+
+	s.respondSeries(c, func(ctx context.Context, from, to time.Time) ([]map[string]interface{}, error) {
+		return querier.QueryAggregate(ctx, c.Param("project"), c.Param("table"), c.Param("name"), from, to)
+	}, (*models.Schema).GetAggregate)
+}
+
+// timeRange 解析 RFC3339 格式的 from、to 查询参数，未指定时为零值，格式错误时返回 400。
+// 不带时区偏移的时间（如 2024-01-02T00:00:00）按 tz 参数的时区解释
+func timeRange(c *gin.Context) (from, to time.Time, ok bool) {
+	loc, ok := timeZone(c)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	for param, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			var localErr error
+			if t, localErr = time.ParseInLocation("2006-01-02T15:04:05", value, loc); localErr != nil {
+				respondStatus(c, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("invalid %s: %v", param, err))
+				return time.Time{}, time.Time{}, false
+			}
+		}
+		*target = t
+	}
+	return from, to, true
+}
+
+// timeZone 解析 tz 查询参数，未指定时为 UTC，无效时返回 400
+func timeZone(c *gin.Context) (*time.Location, bool) {
+	loc, err := models.ParseTimeZone(c.Query("tz"))
+	if err != nil {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return nil, false
+	}
+	return loc, true
+}
+
+// rebucket 指定 tz 或 interval 参数时将持续聚合或 rollup 的结果按调用方时区重新划分时间桶，
+// interval 默认为定义的时间桶大小；未指定时原样返回以 UTC 对齐的时间桶
+func (s *Server) rebucket(c *gin.Context, rows []map[string]interface{},
+	find func(*models.Schema, string) (*models.Aggregate, bool)) ([]map[string]interface{}, bool) {
+	if c.Query("tz") == "" && c.Query("interval") == "" {
+		return rows, true
+	}
+	loc, ok := timeZone(c)
+	if !ok {
+		return nil, false
+	}
+
+	schema, err := s.storage.GetSchema(c.Request.Context(), c.Param("project"), c.Param("table"))
+	if err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+	agg, ok := find(schema, c.Param("name"))
+	if !ok {
+		respondError(c, fmt.Errorf("%w: %s not found", models.ErrValidation, c.Param("name")))
+		return nil, false
+	}
+	size, err := agg.BucketSize()
+	if err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+	if interval := c.Query("interval"); interval != "" {
+		if size, err = models.ParseRetention(interval); err != nil {
+			respondStatus(c, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("invalid interval: %s", interval))
+			return nil, false
+		}
+	}
+
+	rows, err = storage.Rebucket(rows, agg, size, loc)
+	if err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+	return rows, true
 }
 
 // convertFieldValue 根据字段类型转换值
 func convertFieldValue(value interface{}, fieldType models.FieldType) (interface{}, error) {
+	// null 原样保留，由 schema 的 nullable 设置决定是否接受
+	if value == nil {
+		return nil, nil
+	}
+
 	switch fieldType {
 	case models.FieldTypeString:
 		switch v := value.(type) {
@@ -420,50 +1185,12 @@ func convertFieldValue(value interface{}, fieldType models.FieldType) (interface
 			return nil, fmt.Errorf("cannot convert %T to time", value)
 		}
 	case models.FieldTypeDuration:
-		switch v := value.(type) {
-		case string:
-			// 尝试解析常见的持续时间格式
-			if strings.HasSuffix(v, "ms") {
-				ms, err := strconv.ParseInt(strings.TrimSuffix(v, "ms"), 10, 64)
-				if err != nil {
-					return nil, fmt.Errorf("invalid duration format: %v", err)
-				}
-				return time.Duration(ms) * time.Millisecond, nil
-			}
-			if strings.HasSuffix(v, "s") {
-				s, err := strconv.ParseInt(strings.TrimSuffix(v, "s"), 10, 64)
-				if err != nil {
-					return nil, fmt.Errorf("invalid duration format: %v", err)
-				}
-				return time.Duration(s) * time.Second, nil
-			}
-			if strings.HasSuffix(v, "m") {
-				m, err := strconv.ParseInt(strings.TrimSuffix(v, "m"), 10, 64)
-				if err != nil {
-					return nil, fmt.Errorf("invalid duration format: %v", err)
-				}
-				return time.Duration(m) * time.Minute, nil
-			}
-			if strings.HasSuffix(v, "h") {
-				h, err := strconv.ParseInt(strings.TrimSuffix(v, "h"), 10, 64)
-				if err != nil {
-					return nil, fmt.Errorf("invalid duration format: %v", err)
-				}
-				return time.Duration(h) * time.Hour, nil
-			}
-			// 尝试使用标准库解析
-			return time.ParseDuration(v)
-		case int:
-			return time.Duration(v) * time.Second, nil
-		case int64:
-			return time.Duration(v) * time.Second, nil
-		case float64:
-			return time.Duration(v * float64(time.Second)), nil
-		case time.Duration:
-			return v, nil
-		default:
-			return nil, fmt.Errorf("cannot convert %T to duration", value)
+		// 与存储层一致：数字为纳秒，字符串为整数纳秒或 Go 持续时间（如 1.5s）
+		n, err := models.DurationNanos(value)
+		if err != nil {
+			return nil, err
 		}
+		return time.Duration(n), nil
 	case models.FieldTypeJSON:
 		// 将值转换为 JSON 字符串
 		jsonBytes, err := json.Marshal(value)
@@ -478,6 +1205,15 @@ func convertFieldValue(value interface{}, fieldType models.FieldType) (interface
 			return nil, fmt.Errorf("failed to marshal JSON: %v", err)
 		}
 		return string(jsonBytes), nil
+	case models.FieldTypeIP:
+		addr, err := models.ParseIP(value)
+		if err != nil {
+			return nil, err
+		}
+		return addr.String(), nil
+	case models.FieldTypeObject, models.FieldTypeArray:
+		// 保留原始结构，由 schema 递归校验子字段与元素
+		return value, nil
 	default:
 		return nil, fmt.Errorf("unsupported field type: %s", fieldType)
 	}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+const (
+	// DefaultSlowQueryThreshold 默认的慢查询阈值
+	DefaultSlowQueryThreshold = time.Second
+	// DefaultSlowQueryRetention 慢查询记录默认的保留时间
+	DefaultSlowQueryRetention = 7 * 24 * time.Hour
+	// slowQueryPurgeInterval 清理过期慢查询记录的间隔
+	slowQueryPurgeInterval = time.Hour
+	// slowQueryRecordTimeout 写入一条慢查询记录的超时时间，与请求是否结束无关
+	slowQueryRecordTimeout = 5 * time.Second
+)
+
+// recordSlowQuery 查询访问存储的耗时不少于 SlowQueryThreshold 时写入慢查询表并记录警告日志。
+// 存储不支持时只记录日志，写入失败不影响查询的响应
+func (s *Server) recordSlowQuery(c *gin.Context, project, table string, query interface{}, elapsed time.Duration, rows int, err error) {
+	if s.slowThreshold <= 0 || elapsed < s.slowThreshold {
+		return
+	}
+	text, _ := json.Marshal(query)
+	record := &models.SlowQuery{
+		Time:       time.Now().UTC(),
+		Project:    project,
+		Table:      table,
+		Endpoint:   c.Request.Method + " " + c.FullPath(),
+		Query:      string(text),
+		DurationMS: float64(elapsed) / float64(time.Millisecond),
+		Rows:       rows,
+		Caller:     requestOwner(c),
+		ClientIP:   s.clientIP(c.Request),
+		Backend:    s.storageType,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	s.logger.Warn("slow query",
+		zap.String("project", project),
+		zap.String("table", table),
+		zap.String("endpoint", record.Endpoint),
+		zap.Duration("elapsed", elapsed),
+		zap.Int("rows", rows),
+		zap.String("query", record.Query))
+
+	store, ok := storage.As[storage.SlowQueryStore](s.storage)
+	if !ok {
+		return
+	}
+	// 查询超时或客户端断开时请求的 context 已取消，记录仍需写入
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), slowQueryRecordTimeout)
+	defer cancel()
+	if err := store.RecordSlowQuery(ctx, record); err != nil {
+		s.logger.Warn("failed to record slow query", zap.Error(err))
+	}
+}
+
+// listSlowQueries 列出超过阈值的查询，默认最近的在前，sort=duration 时最慢的在前，
+// 可按 project、table、from、to 与 min_duration 过滤；不支持的存储返回 501
+func (s *Server) listSlowQueries(c *gin.Context) {
+	store, ok := storage.As[storage.SlowQueryStore](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "slow queries are not supported by this storage")
+		return
+	}
+
+	from, to, ok := timeRange(c)
+	if !ok {
+		return
+	}
+	filter := &models.SlowQueryFilter{
+		Project: c.Query("project"),
+		Table:   c.Query("table"),
+		From:    from,
+		To:      to,
+		Sort:    c.DefaultQuery("sort", models.SlowQueryByTime),
+	}
+	if filter.Sort != models.SlowQueryByTime && filter.Sort != models.SlowQueryByDuration {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, "sort must be time or duration")
+		return
+	}
+	if value := c.Query("min_duration"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			respondStatus(c, http.StatusBadRequest, CodeBadRequest, "invalid min_duration: "+value)
+			return
+		}
+		filter.MinDuration = d
+	}
+	for param, target := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := c.Query(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				respondStatus(c, http.StatusBadRequest, CodeBadRequest, "invalid "+param+": "+value)
+				return
+			}
+			*target = n
+		}
+	}
+
+	queries, err := store.ListSlowQueries(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, queries)
+}
+
+// purgeSlowQueries 删除超过 SlowQueryRetention 的慢查询记录
+func (s *Server) purgeSlowQueries(ctx context.Context) error {
+	store, ok := storage.As[storage.SlowQueryStore](s.storage)
+	if !ok {
+		return nil
+	}
+	n, err := store.PurgeSlowQueries(ctx, time.Now().Add(-s.slowRetention))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		s.logger.Info("purged slow queries", zap.Int64("count", n))
+	}
+	return nil
+}
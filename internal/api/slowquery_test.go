@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// delayedStorage 查询前等待 delay 的 SQLite 存储
+type delayedStorage struct {
+	*storage.SQLiteStorage
+	delay time.Duration
+}
+
+func (s *delayedStorage) SearchLogs(ctx context.Context, project, table string, query *models.Query) ([]map[string]interface{}, error) {
+	time.Sleep(s.delay)
+	return s.SQLiteStorage.SearchLogs(ctx, project, table, query)
+}
+
+func TestSlowQueries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "path", Type: models.FieldTypeString},
+			{Name: "message", Type: models.FieldTypeString},
+		},
+	}))
+
+	server := NewServer(&delayedStorage{store, 20 * time.Millisecond}, &Config{
+		StorageType:        "sqlite",
+		SlowQueryThreshold: 10 * time.Millisecond,
+	})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", "alice")
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/v1/logs/app/requests/search", `{"filter":{"path":"/api"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do(http.MethodGet, "/api/v1/logs/app/requests/patterns", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = do(http.MethodGet, "/api/v1/admin/slow-queries", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var queries []*models.SlowQuery
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &queries))
+	require.Len(t, queries, 2)
+	search := queries[1]
+	assert.Equal(t, "POST /api/v1/logs/:project/:table/search", search.Endpoint)
+	assert.Equal(t, "app", search.Project)
+	assert.Equal(t, "requests", search.Table)
+	assert.Contains(t, search.Query, `"filter":{"path":"/api"}`)
+	assert.GreaterOrEqual(t, search.DurationMS, float64(20))
+	assert.Equal(t, "user:alice", search.Caller)
+	assert.Equal(t, "sqlite", search.Backend)
+	assert.Equal(t, "GET /api/v1/logs/:project/:table/patterns", queries[0].Endpoint)
+
+	w = do(http.MethodGet, "/api/v1/admin/slow-queries?sort=duration&min_duration=1h", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `[]`, w.Body.String())
+	for _, query := range []string{"sort=slowest", "min_duration=fast", "limit=-1", "from=yesterday"} {
+		w = do(http.MethodGet, "/api/v1/admin/slow-queries?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// 阈值小于 0 时不记录
+	server = NewServer(&delayedStorage{store, 20 * time.Millisecond}, &Config{SlowQueryThreshold: -1})
+	w = do(http.MethodPost, "/api/v1/logs/app/requests/search", `{}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do(http.MethodGet, "/api/v1/admin/slow-queries", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &queries))
+	assert.Len(t, queries, 2)
+
+	// 不支持的存储返回 501
+	server = NewServer(&storageWithoutSizes{store}, &Config{})
+	w = do(http.MethodGet, "/api/v1/admin/slow-queries", "")
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
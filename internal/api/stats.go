@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/stats"
+)
+
+// StatsResponse 各表在窗口内的写入统计
+type StatsResponse struct {
+	Window string              `json:"window"` // 窗口长度，all 表示自开始统计以来
+	From   time.Time           `json:"from"`   // 窗口起点，不早于本实例开始统计的时间
+	To     time.Time           `json:"to"`
+	Tables []*stats.TableStats `json:"tables"`
+}
+
+// ingestStats 返回各表在 window 内写入的条数、字节数、错误率、平均大小与最近写入时间。
+// 统计由写入时增量累计，只包含本实例启动以来经由 API 写入的日志
+func (s *Server) ingestStats(c *gin.Context) {
+	window, err := stats.ParseWindow(c.Query("window"))
+	if err != nil {
+		respondStatus(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+
+	resp := &StatsResponse{Window: "all", From: s.stats.Started(), To: time.Now()}
+	if window > 0 {
+		resp.Window = window.String()
+		if from := resp.To.Add(-window); from.After(resp.From) {
+			resp.From = from
+		}
+	}
+	resp.Tables = s.stats.Stats(window, c.Query("project"), c.Query("table"))
+	c.JSON(http.StatusOK, resp)
+}
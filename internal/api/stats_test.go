@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestIngestStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	for _, table := range []string{"requests", "jobs"} {
+		require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+			Project: "app",
+			Table:   table,
+			Fields:  []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+		}))
+	}
+	server := NewServer(store, &Config{})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	w := do(http.MethodPost, "/api/v1/logs/app/requests/batch?partial=true",
+		`[{"level":"info","message":"a","status":200},{"level":"info","message":"b","status":"abc"},{"level":"info","message":"c","status":201}]`)
+	require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+	w = do(http.MethodPost, "/api/v1/logs/app/jobs", `{"level":"warn","message":"slow"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = do(http.MethodPost, "/api/v1/logs/app/jobs", `{"level":"warn","message":"slow","status":"abc"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	w = do(http.MethodGet, "/api/v1/stats", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp StatsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "1h0m0s", resp.Window)
+	assert.False(t, resp.From.After(resp.To))
+	require.Len(t, resp.Tables, 2)
+	assert.Equal(t, "jobs", resp.Tables[0].Table)
+	assert.Equal(t, int64(1), resp.Tables[0].Rows)
+	assert.Equal(t, int64(1), resp.Tables[0].Rejected)
+	assert.Equal(t, 0.5, resp.Tables[0].ErrorRate)
+	requests := resp.Tables[1]
+	assert.Equal(t, "app", requests.Project)
+	assert.Equal(t, int64(2), requests.Rows)
+	assert.Equal(t, int64(1), requests.Rejected)
+	assert.Positive(t, requests.AvgEntrySize)
+	assert.NotNil(t, requests.LastWrite)
+
+	w = do(http.MethodGet, "/api/v1/stats?window=all&project=app&table=requests", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "all", resp.Window)
+	require.Len(t, resp.Tables, 1)
+	assert.Equal(t, "requests", resp.Tables[0].Table)
+
+	w = do(http.MethodGet, "/api/v1/stats?project=other", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Tables)
+
+	w = do(http.MethodGet, "/api/v1/stats?window=7d", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid window")
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/importer"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+const (
+	// streamBatchSize 流式写入每批提交的日志条数
+	streamBatchSize = 1000
+	// maxStreamLine 单行 NDJSON 的最大长度
+	maxStreamLine = importer.MaxLine
+	// maxStreamErrors 响应中最多返回的行错误数，超出部分只计数
+	maxStreamErrors = 100
+)
+
+// StreamResult 流式写入结果。LastLine 之前（含）的行均已写入或被拒绝，中断后可从其后继续
+type StreamResult struct {
+	Accepted int                `json:"accepted"`
+	Rejected int                `json:"rejected"`
+	LastLine int                `json:"last_line"`
+	Errors   []*StreamLineError `json:"errors,omitempty"`
+}
+
+// StreamLineError 被跳过的行及原因，line 从 1 开始
+type StreamLineError struct {
+	Line   int                  `json:"line"`
+	Error  string               `json:"error"`
+	Fields []*models.FieldError `json:"fields,omitempty"`
+}
+
+// streamLogs 逐行读取 NDJSON 请求体，校验后按批写入。
+// 无法解析或未通过校验的行被跳过并在结果中报告；存储写入失败时中止，已提交的批次不回滚
+func (s *Server) streamLogs(c *gin.Context) {
+	reader, err := importer.NewReader(c.Request.Body, importer.FormatJSONL, nil)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	s.ingest(c, reader)
+}
+
+// ingest 校验 reader 中的记录并按批写入，响应写入结果。无法解析或未通过校验的记录被跳过并报告；
+// 读取或写入失败时中止，响应中附带已提交的条数，已提交的批次不回滚
+func (s *Server) ingest(c *gin.Context, reader *importer.Reader) {
+	project := c.Param("project")
+	table := c.Param("table")
+	ctx := c.Request.Context()
+
+	var result StreamResult
+	reject := func(line int, err error) {
+		s.stats.Reject(project, table, 1)
+		result.Rejected++
+		if len(result.Errors) < maxStreamErrors {
+			result.Errors = append(result.Errors, &StreamLineError{Line: line, Error: err.Error(), Fields: models.FieldErrors(err)})
+		}
+	}
+	// fail 中止写入，响应中附带已提交的条数与可以继续的行号
+	fail := func(err error) {
+		status, code := classifyError(err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status, code = http.StatusRequestEntityTooLarge, CodePayloadTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "code": code, "accepted": result.Accepted, "rejected": result.Rejected, "last_line": result.LastLine})
+	}
+
+	batch := make([]*models.LogEntry, 0, streamBatchSize)
+	line := 0
+	flush := func() error {
+		if len(batch) > 0 {
+			if err := s.storage.BatchInsertLogs(ctx, project, table, batch); err != nil {
+				s.stats.Reject(project, table, len(batch))
+				return err
+			}
+			s.observe(ctx, project, table, batch)
+			result.Accepted += len(batch)
+			batch = batch[:0]
+		}
+		result.LastLine = line
+		return nil
+	}
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var lineErr *importer.LineError
+		switch {
+		case errors.As(err, &lineErr):
+			line = lineErr.Line
+			reject(lineErr.Line, lineErr.Err)
+			continue
+		case err != nil:
+			fail(fmt.Errorf("read line %d: %w", line+1, err))
+			return
+		}
+
+		line = record.Line
+		log, err := s.deserializeLogEntry(c, project, table, record.Data)
+		if err != nil {
+			if models.FieldErrors(err) == nil {
+				fail(err)
+				return
+			}
+			reject(record.Line, err)
+			continue
+		}
+		log.Fields["ip"] = s.clientIP(c.Request)
+		batch = append(batch, log)
+		if len(batch) >= streamBatchSize {
+			if err := flush(); err != nil {
+				fail(err)
+				return
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		fail(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestStreamLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+	}))
+	server := NewServer(store, &Config{})
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	var body strings.Builder
+	for i := 0; i < 2*streamBatchSize+5; i++ {
+		fmt.Fprintf(&body, `{"level":"info","message":"m%d","status":200}`+"\n", i)
+	}
+	body.WriteString("\n{not json}\n")
+	body.WriteString(`{"level":"info","message":"bad","status":"abc"}` + "\n")
+	body.WriteString(`{"message":"long","pad":"` + strings.Repeat("x", maxStreamLine) + `"}` + "\n")
+	body.WriteString(`{"level":"info","message":"last","status":201}`) // 最后一行没有换行符
+
+	w := post("/api/v1/logs/app/requests/stream", body.String())
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result StreamResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 2*streamBatchSize+6, result.Accepted)
+	assert.Equal(t, 3, result.Rejected)
+	require.Len(t, result.Errors, 3)
+	assert.Equal(t, 2*streamBatchSize+7, result.Errors[0].Line)
+	assert.Equal(t, 2*streamBatchSize+8, result.Errors[1].Line)
+	require.Len(t, result.Errors[1].Fields, 1)
+	assert.Equal(t, "status", result.Errors[1].Fields[0].Field)
+	assert.Contains(t, result.Errors[2].Error, "exceeds")
+
+	count, err := store.CountLogs(ctx, "app", "requests", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2*streamBatchSize+6, count)
+
+	w = post("/api/v1/logs/app/missing/stream", `{"message":"m"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBatchInsertPartial(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+	}))
+	server := NewServer(store, &Config{})
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	body := `[{"level":"info","message":"a","status":200},{"level":"info","message":"b","status":"abc"},
+		{"message":"c"},{"level":"info","message":"d","status":201}]`
+
+	// 默认整批失败
+	w := post("/api/v1/logs/app/requests/batch", body)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	w = post("/api/v1/logs/app/requests/batch?partial=true", body)
+	require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+	var result BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 2, result.Accepted)
+	assert.Equal(t, 2, result.Rejected)
+	require.Len(t, result.Results, 4)
+	for i, status := range []int{http.StatusCreated, http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, http.StatusCreated} {
+		assert.Equal(t, i, result.Results[i].Index)
+		assert.Equal(t, status, result.Results[i].Status, i)
+	}
+	require.Len(t, result.Results[1].Fields, 1)
+	assert.Equal(t, "status", result.Results[1].Fields[0].Field)
+	assert.Equal(t, "level", result.Results[2].Fields[0].Field)
+	assert.Empty(t, result.Results[0].Error)
+
+	count, err := store.CountLogs(ctx, "app", "requests", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+
+	w = post("/api/v1/logs/app/missing/batch?partial=true", `[{"message":"m"}]`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// chunkRecorder 记录每次批量写入的条数，写入第 failAt 块时失败
+type chunkRecorder struct {
+	storage.Storage
+	chunks []int
+	failAt int
+}
+
+func (r *chunkRecorder) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	r.chunks = append(r.chunks, len(logs))
+	if len(r.chunks) == r.failAt {
+		return fmt.Errorf("insert chunk: %w", storage.ErrBackendUnavailable)
+	}
+	return r.Storage.BatchInsertLogs(ctx, project, table, logs)
+}
+
+func TestBatchInsertChunks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+	}))
+	recorder := &chunkRecorder{Storage: store}
+	server := NewServer(recorder, &Config{BatchChunkSize: 2})
+
+	post := func(path string) *httptest.ResponseRecorder {
+		var body strings.Builder
+		body.WriteString("[")
+		for i := 0; i < 5; i++ {
+			if i > 0 {
+				body.WriteString(",")
+			}
+			fmt.Fprintf(&body, `{"level":"info","message":"m%d","status":200}`, i)
+		}
+		body.WriteString("]")
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body.String()))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/v1/logs/app/requests/batch")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, []int{2, 2, 1}, recorder.chunks)
+
+	recorder.chunks = nil
+	w = post("/api/v1/logs/app/requests/batch?atomic=true")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, []int{5}, recorder.chunks)
+
+	// 失败前已提交的分块保留，响应中返回已提交的条数
+	recorder.chunks, recorder.failAt = nil, 2
+	w = post("/api/v1/logs/app/requests/batch")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, CodeBackendUnavailable, resp.Code)
+	assert.Equal(t, 2, resp.Accepted)
+
+	count, err := store.CountLogs(ctx, "app", "requests", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 12, count)
+}
+
+func TestBatchInsertIDs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "status", Type: models.FieldTypeInt}},
+	}))
+	server := NewServer(store, &Config{})
+
+	post := func(path, prefer, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/v1/logs/app/requests/batch", "",
+		`[{"level":"info","message":"a","timestamp":"2024-01-02T11:04:05+08:00"},{"level":"info","message":"b"}]`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var resp BatchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Accepted)
+	require.Len(t, resp.Entries, 2)
+	assert.NotEmpty(t, resp.Entries[0].ID)
+	assert.NotEqual(t, resp.Entries[0].ID, resp.Entries[1].ID)
+	assert.Equal(t, "2024-01-02T03:04:05Z", resp.Entries[0].Timestamp.Format(time.RFC3339))
+
+	logs, err := store.SearchLogs(ctx, "app", "requests", &models.Query{Filter: map[string]interface{}{"id": resp.Entries[1].ID}})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+
+	w = post("/api/v1/logs/app/requests/batch", "respond-async, return=minimal", `[{"level":"info","message":"c"}]`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, "return=minimal", w.Header().Get("Preference-Applied"))
+
+	w = post("/api/v1/logs/app/requests/batch?partial=true", "", `[{"message":"bad"},{"level":"info","message":"d"}]`)
+	require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+	var result BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Empty(t, result.Results[0].ID)
+	assert.NotEmpty(t, result.Results[1].ID)
+	require.NotNil(t, result.Results[1].Timestamp)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TelemetryItem 描述一项会被发送的遥测数据
+type TelemetryItem struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// telemetryItems 服务器会发送的全部遥测数据。目前服务器不收集也不发送任何数据，
+// 今后新增遥测时必须在此登记，才能通过 /api/v1/admin/telemetry 对外公开
+var telemetryItems = []TelemetryItem{}
+
+// TelemetryStatus 遥测配置与发送内容
+type TelemetryStatus struct {
+	Enabled     bool            `json:"enabled"`
+	OptOutBy    string          `json:"opt_out_by,omitempty"`
+	Destination string          `json:"destination"`
+	Items       []TelemetryItem `json:"items"`
+}
+
+// telemetryEnabled 遥测需显式开启，DO_NOT_TRACK 环境变量始终优先
+func telemetryEnabled(configured bool) (bool, string) {
+	if v := os.Getenv("DO_NOT_TRACK"); v != "" && v != "0" {
+		return false, "DO_NOT_TRACK"
+	}
+	if !configured {
+		return false, "config"
+	}
+	return true, ""
+}
+
+// telemetryStatus 返回遥测开关状态及会发送的数据清单
+func (s *Server) telemetryStatus(c *gin.Context) {
+	enabled, optOutBy := telemetryEnabled(s.telemetry)
+	c.JSON(http.StatusOK, TelemetryStatus{
+		Enabled:     enabled && len(telemetryItems) > 0,
+		OptOutBy:    optOutBy,
+		Destination: "none",
+		Items:       telemetryItems,
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestTelemetryStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	get := func(cfg *Config) (TelemetryStatus, string) {
+		w := httptest.NewRecorder()
+		NewServer(store, cfg).router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/telemetry", nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var status TelemetryStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		return status, w.Body.String()
+	}
+
+	tests := []struct {
+		name       string
+		configured bool
+		doNotTrack string
+		items      []TelemetryItem
+		enabled    bool
+		optOutBy   string
+	}{
+		{name: "absent config", optOutBy: "config"},
+		{name: "configured without items", configured: true},
+		{name: "configured", configured: true, items: []TelemetryItem{{Name: "version", Description: "server version"}}, enabled: true},
+		{name: "do not track", configured: true, doNotTrack: "1", items: []TelemetryItem{{Name: "version"}}, optOutBy: "DO_NOT_TRACK"},
+		{name: "do not track without config", doNotTrack: "true", optOutBy: "DO_NOT_TRACK"},
+		{name: "do not track disabled", configured: true, doNotTrack: "0", items: []TelemetryItem{{Name: "version"}}, enabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DO_NOT_TRACK", tt.doNotTrack)
+			saved := telemetryItems
+			defer func() { telemetryItems = saved }()
+			if tt.items != nil {
+				telemetryItems = tt.items
+			}
+
+			status, body := get(&Config{Telemetry: tt.configured})
+			assert.Equal(t, tt.enabled, status.Enabled)
+			assert.Equal(t, tt.optOutBy, status.OptOutBy)
+			assert.Equal(t, "none", status.Destination)
+			if tt.items == nil {
+				assert.NotNil(t, status.Items, "items is always a list")
+				assert.Empty(t, status.Items)
+			} else {
+				assert.Equal(t, tt.items, status.Items, "the disclosed items do not depend on the switch")
+			}
+			if tt.optOutBy == "" {
+				assert.NotContains(t, body, "opt_out_by")
+			}
+		})
+	}
+}
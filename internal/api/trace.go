@@ -0,0 +1,188 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// defaultCorrelatedLimit 每张表默认返回的最大条数
+const defaultCorrelatedLimit = 1000
+
+// queryCorrelated 返回按 field（trace_id 或 request_id）跨项目/表查询的处理函数，
+// 只查询该字段已建立索引的表，结果按时间合并排序。每张表最多返回 MaxQueryRows 条；
+// 全部表的查询超过 QueryTimeout 时返回已查询完成的表的结果并标记 partial。
+// fields 参数只返回指定的列（及用于排序的 timestamp），表中没有的列跳过，全部表都没有的列返回 422
+func (s *Server) queryCorrelated(field string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		querier, ok := storage.As[storage.LogQuerier](s.storage)
+		if !ok {
+			respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "log queries are not supported by this storage")
+			return
+		}
+
+		id := c.Param(field)
+		limit := defaultCorrelatedLimit
+		if value := c.Query("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				respondStatus(c, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf("invalid limit: %s", value))
+				return
+			}
+			limit = n
+		}
+		limited := limit > s.guard.maxRows
+		if limited {
+			limit = s.guard.maxRows
+		}
+
+		schemas, err := s.storage.ListSchemas(c.Request.Context())
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		fields := fieldsParam(c)
+		if err := s.checkCorrelatedFields(schemas, field, fields); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		ctx, cancel := s.guard.context(c.Request.Context())
+		defer cancel()
+		entries := make([]map[string]interface{}, 0)
+		searched := make([]string, 0)
+		skipped := make([]string, 0)
+		partial := ""
+		for _, schema := range schemas {
+			f := schema.GetField(field)
+			if f == nil {
+				continue
+			}
+			key := schema.Project + "/" + schema.Table
+			if !f.IsIndexed() {
+				skipped = append(skipped, key)
+				continue
+			}
+
+			queryLimit := limit
+			if limited {
+				// 多取一条用于判断是否截断
+				queryLimit++
+			}
+			query := &models.Query{
+				Filter: map[string]interface{}{field: id},
+				Fields: s.projectFields(schema, fields),
+				Sort:   []string{"timestamp"},
+				Limit:  queryLimit,
+			}
+			start := time.Now()
+			rows, err := querier.SearchLogs(ctx, schema.Project, schema.Table, query)
+			s.recordSlowQuery(c, schema.Project, schema.Table, query, time.Since(start), len(rows), err)
+			if err != nil {
+				if s.guard.timedOut(ctx, c.Request.Context()) {
+					partial = PartialTimeout
+					break
+				}
+				respondError(c, fmt.Errorf("query %s: %w", key, err))
+				return
+			}
+			if len(rows) > limit {
+				rows = rows[:limit]
+				if partial == "" {
+					partial = PartialMaxRows
+				}
+			}
+			for _, row := range rows {
+				row["project"] = schema.Project
+				row["table"] = schema.Table
+			}
+			entries = append(entries, rows...)
+			searched = append(searched, key)
+		}
+
+		sort.SliceStable(entries, func(i, j int) bool {
+			return rowTime(entries[i]).Before(rowTime(entries[j]))
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			field:            id,
+			"count":          len(entries),
+			"entries":        entries,
+			"tables":         searched,
+			"skipped":        skipped,
+			"partial":        partial != "",
+			"partial_reason": partial,
+		})
+	}
+}
+
+// rowTimeLayouts 各存储返回的时间字符串格式
+var rowTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// rowTime 解析查询结果中的 timestamp 列，无法解析时返回零值
+func rowTime(row map[string]interface{}) time.Time {
+	switch v := row["timestamp"].(type) {
+	case time.Time:
+		return v
+	case string:
+		for _, layout := range rowTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+	case []byte:
+		return rowTime(map[string]interface{}{"timestamp": string(v)})
+	}
+	return time.Time{}
+}
+
+// hasColumn 判断日志表是否有 name 列：schema 字段或存储报告的基础列
+func (s *Server) hasColumn(schema *models.Schema, name string) bool {
+	return schema.GetField(name) != nil || slices.Contains(storage.BaseColumns(s.storage, schema), name)
+}
+
+// checkCorrelatedFields 检查 fields 中的每一列至少存在于一张有 field 字段的表中
+func (s *Server) checkCorrelatedFields(schemas []*models.Schema, field string, fields []string) error {
+	for _, name := range fields {
+		found := false
+		for _, schema := range schemas {
+			if schema.GetField(field) != nil && s.hasColumn(schema, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: unknown field: %s", models.ErrValidation, name)
+		}
+	}
+	return nil
+}
+
+// projectFields 返回 fields 中 schema 有的列，并加入合并排序使用的 timestamp；fields 为空时返回 nil，即全部列
+func (s *Server) projectFields(schema *models.Schema, fields []string) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+	projected := make([]string, 0, len(fields)+1)
+	for _, name := range fields {
+		if s.hasColumn(schema, name) {
+			projected = append(projected, name)
+		}
+	}
+	if !slices.Contains(projected, "timestamp") {
+		projected = append(projected, "timestamp")
+	}
+	return projected
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestQueryCorrelated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	traced := []*models.Field{
+		{Name: "trace_id", Type: models.FieldTypeString, Indexed: true},
+		{Name: "request_id", Type: models.FieldTypeString, Indexed: true},
+		{Name: "step", Type: models.FieldTypeString},
+	}
+	for _, schema := range []*models.Schema{
+		{Project: "app", Table: "requests", Fields: traced},
+		{Project: "app", Table: "jobs", Fields: traced},
+		{Project: "billing", Table: "charges", Fields: traced},
+		{Project: "audit", Table: "events", Fields: []*models.Field{{Name: "trace_id", Type: models.FieldTypeString}}},
+		{Project: "app", Table: "metrics", Fields: []*models.Field{{Name: "name", Type: models.FieldTypeString}}},
+	} {
+		require.NoError(t, store.CreateSchema(ctx, schema))
+	}
+
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	insert := func(project, table, step, traceID string, offset time.Duration) {
+		fields := map[string]interface{}{"trace_id": traceID}
+		if table != "events" {
+			fields["request_id"], fields["step"] = "req-"+traceID, step
+		}
+		require.NoError(t, store.InsertLog(ctx, project, table, &models.LogEntry{
+			Project: project, Table: table, Level: "info", Message: step,
+			Timestamp: base.Add(offset), Fields: fields,
+		}))
+	}
+	// 各表的写入顺序与时间顺序不同，结果需要跨表按时间合并
+	insert("billing", "charges", "charge", "t1", 3*time.Second)
+	insert("app", "requests", "request end", "t1", 4*time.Second)
+	insert("app", "requests", "request start", "t1", 0)
+	insert("app", "jobs", "job", "t1", 2*time.Second)
+	insert("app", "jobs", "other job", "t2", time.Second)
+	insert("audit", "events", "audited", "t1", time.Second)
+
+	server := NewServer(store, &Config{})
+	get := func(path string) correlatedResponse {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp correlatedResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	origins := func(resp correlatedResponse) []string {
+		var names []string
+		for _, entry := range resp.Entries {
+			names = append(names, entry["project"].(string)+"/"+entry["table"].(string)+": "+entry["step"].(string))
+		}
+		return names
+	}
+
+	resp := get("/api/v1/trace/t1")
+	assert.Equal(t, "t1", resp.TraceID)
+	assert.Equal(t, 4, resp.Count)
+	assert.Equal(t, []string{
+		"app/requests: request start",
+		"app/jobs: job",
+		"billing/charges: charge",
+		"app/requests: request end",
+	}, origins(resp), "entries from all tables ordered by time")
+	assert.ElementsMatch(t, []string{"app/requests", "app/jobs", "billing/charges"}, resp.Tables)
+	assert.Equal(t, []string{"audit/events"}, resp.Skipped, "tables without an index on trace_id are skipped")
+	assert.False(t, resp.Partial)
+
+	resp = get("/api/v1/request/req-t2")
+	assert.Equal(t, "req-t2", resp.RequestID)
+	assert.Equal(t, []string{"app/jobs: other job"}, origins(resp))
+
+	// 未知的 ID 返回空结果而不是 404
+	resp = get("/api/v1/trace/unknown")
+	assert.Equal(t, 0, resp.Count)
+	assert.NotNil(t, resp.Entries)
+	assert.Empty(t, resp.Entries)
+	assert.Len(t, resp.Tables, 3)
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/trace/t1?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestQueryFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, table := range []string{"requests", "jobs"} {
+		extra := &models.Field{Name: "path", Type: models.FieldTypeString}
+		if table == "jobs" {
+			extra = &models.Field{Name: "queue", Type: models.FieldTypeString}
+		}
+		require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+			Project: "app",
+			Table:   table,
+			Fields:  []*models.Field{{Name: "trace_id", Type: models.FieldTypeString, Indexed: true}, extra},
+		}))
+		require.NoError(t, store.InsertLog(ctx, "app", table, &models.LogEntry{
+			Project: "app", Table: table, Level: "info", Message: table,
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Fields:    map[string]interface{}{"trace_id": "t1", extra.Name: "/" + table},
+		}))
+	}
+
+	server := NewServer(store, &Config{})
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	keys := func(row map[string]interface{}) []string {
+		names := make([]string, 0, len(row))
+		for name := range row {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// 只返回请求的列与排序使用的 timestamp，表中没有的列跳过
+	w := get("/api/v1/trace/t1?fields=path,id")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp correlatedResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Entries, 2)
+	assert.Equal(t, []string{"id", "path", "project", "table", "timestamp"}, keys(resp.Entries[0]))
+	assert.Equal(t, "/requests", resp.Entries[0]["path"])
+	assert.Equal(t, []string{"id", "project", "table", "timestamp"}, keys(resp.Entries[1]))
+
+	// 未指定时返回全部列
+	w = get("/api/v1/trace/t1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp = correlatedResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Contains(t, resp.Entries[1], "queue")
+	assert.Contains(t, resp.Entries[1], "trace_id")
+
+	// 所有表都没有的列返回 422
+	w = get("/api/v1/trace/t1?fields=queue&fields=missing")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "missing")
+	// SQLite 日志表没有内置的 message 列，未声明时同样返回 422
+	w = get("/api/v1/trace/t1?fields=message")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	// 保存的查询的 fields 可以被参数替换
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/saved-queries",
+		strings.NewReader(`{"name":"recent","project":"app","table":"requests","query":{"fields":["path"]}}`))
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Less(t, w.Code, 300, w.Body.String())
+	w = get("/api/v1/saved-queries/recent/results")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var results savedQueryResults
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results.Entries, 1)
+	assert.Equal(t, []string{"path"}, keys(results.Entries[0]))
+	w = get("/api/v1/saved-queries/recent/results?fields=trace_id,%20id")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	results = savedQueryResults{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Equal(t, []string{"id", "trace_id"}, keys(results.Entries[0]))
+	w = get("/api/v1/saved-queries/recent/results?fields=queue")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	// sort 参数替换保存的排序，同 Query.Sort 校验
+	w = get("/api/v1/saved-queries/recent/results?sort=path%20desc,-timestamp")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = get("/api/v1/saved-queries/recent/results?sort=path%20sideways")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// StorageUsageResponse 各项目与日志表占用的存储空间，均按 total_bytes 降序
+type StorageUsageResponse struct {
+	TotalBytes int64               `json:"total_bytes"`
+	Projects   []*ProjectUsage     `json:"projects"`
+	Tables     []*models.TableSize `json:"tables"`
+}
+
+// ProjectUsage 一个项目全部日志表的合计
+type ProjectUsage struct {
+	Project    string `json:"project"`
+	Tables     int    `json:"tables"`
+	Rows       int64  `json:"rows"`
+	DataBytes  int64  `json:"data_bytes"`
+	IndexBytes int64  `json:"index_bytes"`
+	TotalBytes int64  `json:"total_bytes"`
+}
+
+// storageUsage 返回各日志表的大小与按项目的合计，project 非空时只返回该项目。
+// 大小来自存储的统计信息，不扫描日志；不支持的存储返回 501
+func (s *Server) storageUsage(c *gin.Context) {
+	reporter, ok := storage.As[storage.SizeReporter](s.storage)
+	if !ok {
+		respondStatus(c, http.StatusNotImplemented, CodeNotImplemented, "storage does not support reporting table sizes")
+		return
+	}
+	sizes, err := reporter.TableSizes(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	project := c.Query("project")
+	resp := &StorageUsageResponse{Projects: []*ProjectUsage{}, Tables: make([]*models.TableSize, 0, len(sizes))}
+	projects := make(map[string]*ProjectUsage)
+	for _, size := range sizes {
+		if project != "" && size.Project != project {
+			continue
+		}
+		usage, ok := projects[size.Project]
+		if !ok {
+			usage = &ProjectUsage{Project: size.Project}
+			projects[size.Project] = usage
+			resp.Projects = append(resp.Projects, usage)
+		}
+		usage.Tables++
+		usage.Rows += size.Rows
+		usage.DataBytes += size.DataBytes
+		usage.IndexBytes += size.IndexBytes
+		usage.TotalBytes += size.TotalBytes
+		resp.TotalBytes += size.TotalBytes
+		resp.Tables = append(resp.Tables, size)
+	}
+	sort.SliceStable(resp.Projects, func(i, j int) bool { return resp.Projects[i].TotalBytes > resp.Projects[j].TotalBytes })
+	sort.SliceStable(resp.Tables, func(i, j int) bool { return resp.Tables[i].TotalBytes > resp.Tables[j].TotalBytes })
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestStorageUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := storage.NewFileStorage(storage.Config{Type: "file", File: storage.FileConfig{Dir: t.TempDir()}})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	insert := func(project, table string, n int) {
+		require.NoError(t, store.CreateSchema(ctx, &models.Schema{Project: project, Table: table}))
+		logs := make([]*models.LogEntry, n)
+		for i := range logs {
+			logs[i] = &models.LogEntry{Project: project, Table: table, Level: "info", Message: "request",
+				Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Fields: map[string]interface{}{}}
+		}
+		require.NoError(t, store.BatchInsertLogs(ctx, project, table, logs))
+	}
+	insert("web", "requests", 5)
+	insert("billing", "events", 20)
+	insert("billing", "audit", 1)
+
+	server := NewServer(store, &Config{})
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/v1/admin/storage")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp StorageUsageResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Tables, 3)
+	assert.Equal(t, "events", resp.Tables[0].Table)
+	assert.Equal(t, "requests", resp.Tables[1].Table)
+	assert.Equal(t, "audit", resp.Tables[2].Table)
+	require.Len(t, resp.Projects, 2)
+	assert.Equal(t, "billing", resp.Projects[0].Project)
+	assert.Equal(t, 2, resp.Projects[0].Tables)
+	assert.Equal(t, int64(21), resp.Projects[0].Rows)
+	assert.Equal(t, resp.Tables[0].TotalBytes+resp.Tables[2].TotalBytes, resp.Projects[0].TotalBytes)
+	assert.Equal(t, resp.Projects[0].TotalBytes+resp.Projects[1].TotalBytes, resp.TotalBytes)
+
+	w = get("/api/v1/admin/storage?project=web")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Tables, 1)
+	assert.Equal(t, int64(5), resp.Tables[0].Rows)
+	assert.Equal(t, resp.Tables[0].TotalBytes, resp.TotalBytes)
+
+	// 不支持的存储返回 501
+	server = NewServer(&storageWithoutSizes{store}, &Config{})
+	w = get("/api/v1/admin/storage")
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Contains(t, w.Body.String(), CodeNotImplemented)
+}
+
+// storageWithoutSizes 隐藏 SizeReporter 能力的存储
+type storageWithoutSizes struct {
+	storage.Storage
+}
@@ -0,0 +1,503 @@
+// Package backup 将一个项目的全部 schema 与日志导出为 NDJSON 流，并从导出的流中重建表与数据，
+// 用于备份以及在不同存储后端之间迁移（如 PostgreSQL 迁移到 ClickHouse）
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// FormatVersion 导出格式的版本，恢复时拒绝更高版本的文件
+const FormatVersion = 1
+
+// 记录类型
+const (
+	TypeHeader = "header" // 首行，记录格式版本、项目与导出时间
+	TypeSchema = "schema" // 一个表的 schema，全部出现在日志之前
+	TypeLog    = "log"    // 一行日志，列名与存储后端返回的一致
+)
+
+// DefaultPageSize 导出时每次查询的行数
+const DefaultPageSize = 1000
+
+// DefaultBatchSize 恢复时每批写入的日志条数
+const DefaultBatchSize = 1000
+
+// ErrUnsupported 存储不支持导出所需的查询能力
+var ErrUnsupported = errors.New("storage does not support exporting logs")
+
+// Record 导出流中的一行
+type Record struct {
+	Type      string                 `json:"type"`
+	Version   int                    `json:"version,omitempty"`
+	Project   string                 `json:"project,omitempty"`
+	CreatedAt *time.Time             `json:"created_at,omitempty"`
+	Schema    *models.Schema         `json:"schema,omitempty"`
+	Table     string                 `json:"table,omitempty"`
+	Row       map[string]interface{} `json:"row,omitempty"`
+}
+
+// ExportResult 导出的表与日志数
+type ExportResult struct {
+	Project string `json:"project"`
+	Schemas int    `json:"schemas"`
+	Logs    int    `json:"logs"`
+}
+
+// Export 将项目的全部 schema 与日志按行写入 w：首行为 header，随后是各表的 schema，最后按表依次写入日志。
+// 日志按 timestamp、id 排序分页读取，导出期间新写入的日志可能不包含在内
+func Export(ctx context.Context, store storage.Storage, project string, w io.Writer) (*ExportResult, error) {
+	querier, ok := storage.As[storage.LogQuerier](store)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	schemas, err := projectSchemas(ctx, store, project)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := json.NewEncoder(w)
+	now := time.Now().UTC()
+	if err := enc.Encode(&Record{Type: TypeHeader, Version: FormatVersion, Project: project, CreatedAt: &now}); err != nil {
+		return nil, err
+	}
+	for _, schema := range schemas {
+		if err := enc.Encode(&Record{Type: TypeSchema, Schema: schema}); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &ExportResult{Project: project, Schemas: len(schemas)}
+	for _, schema := range schemas {
+		n, err := exportTable(ctx, querier, schema, enc)
+		result.Logs += n
+		if err != nil {
+			return result, fmt.Errorf("failed to export %s:%s: %w", project, schema.Table, err)
+		}
+	}
+	return result, nil
+}
+
+// projectSchemas 返回项目的全部 schema，项目没有 schema 时返回 ErrSchemaNotFound
+func projectSchemas(ctx context.Context, store storage.Storage, project string) ([]*models.Schema, error) {
+	all, err := store.ListSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var schemas []*models.Schema
+	for _, schema := range all {
+		if schema.Project == project {
+			schemas = append(schemas, schema)
+		}
+	}
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("%w: project %s has no schemas", models.ErrSchemaNotFound, project)
+	}
+	return schemas, nil
+}
+
+// exportTable 分页写入一个表的日志
+func exportTable(ctx context.Context, querier storage.LogQuerier, schema *models.Schema, enc *json.Encoder) (int, error) {
+	cursor := &Cursor{}
+	for !cursor.Done {
+		rows, err := cursor.next(ctx, querier, schema, DefaultPageSize)
+		if err != nil {
+			return cursor.Copied, err
+		}
+		for _, row := range rows {
+			if err := enc.Encode(&Record{Type: TypeLog, Table: schema.Table, Row: exportRow(row)}); err != nil {
+				return cursor.Copied, err
+			}
+		}
+		if err := cursor.advance(rows, DefaultPageSize); err != nil {
+			return cursor.Copied, err
+		}
+	}
+	return cursor.Copied, nil
+}
+
+// Cursor 按 timestamp、id 顺序分页读取一个表的位置。下一页从已读取的最后一个 timestamp 开始，
+// 并跳过其中已读取的行，避免大偏移量的分页查询；可以保存为 JSON 用于中断后继续
+type Cursor struct {
+	From   *time.Time `json:"from,omitempty"`   // 已读取的最后一行的 timestamp
+	Offset int        `json:"offset,omitempty"` // timestamp 等于 From 的已读取行数
+	Copied int        `json:"copied"`           // 已读取的总行数
+	Done   bool       `json:"done,omitempty"`
+}
+
+// next 读取下一页
+func (c *Cursor) next(ctx context.Context, querier storage.LogQuerier, schema *models.Schema, limit int) ([]map[string]interface{}, error) {
+	return querier.SearchLogs(ctx, schema.Project, schema.Table, &models.Query{
+		From: c.From, Offset: c.Offset, Sort: []string{"timestamp", "id"}, Limit: limit,
+	})
+}
+
+// advance 在一页处理完成后前移，不满 limit 行的页为最后一页
+func (c *Cursor) advance(rows []map[string]interface{}, limit int) error {
+	for _, row := range rows {
+		ts, ok := row["timestamp"].(time.Time)
+		if !ok {
+			return fmt.Errorf("unexpected timestamp value %T", row["timestamp"])
+		}
+		if c.From != nil && ts.Equal(*c.From) {
+			c.Offset++
+		} else {
+			c.From, c.Offset = &ts, 1
+		}
+	}
+	c.Copied += len(rows)
+	c.Done = len(rows) < limit
+	return nil
+}
+
+// exportRow 去掉 project 与表名列，字节值转换为字符串
+func exportRow(row map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(row))
+	for key, value := range row {
+		switch key {
+		case "project", "table_name":
+			continue
+		}
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// RestoreOptions 恢复选项
+type RestoreOptions struct {
+	// Project 写入的项目，为空时使用导出时的项目，可用于恢复到另一个项目
+	Project string
+	// Append 表已存在时向其追加日志，否则已存在的表返回 ErrSchemaExists
+	Append bool
+	// BatchSize 每批写入的日志条数，默认 DefaultBatchSize
+	BatchSize int
+}
+
+// RestoreResult 恢复的表与日志数
+type RestoreResult struct {
+	Project string `json:"project"`
+	Schemas int    `json:"schemas"`
+	Logs    int    `json:"logs"`
+}
+
+// Restore 读取 Export 写出的流，创建其中的 schema 并按批写入日志。日志保留原有的 id、timestamp、ingest_time 与标签；
+// 失败时已写入的数据不会回滚；Append 模式下写入与已有日志 id 相同的日志时由存储后端报错
+func Restore(ctx context.Context, store storage.Storage, r io.Reader, opts RestoreOptions) (*RestoreResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var header Record
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("%w: invalid backup header: %v", models.ErrValidation, err)
+	}
+	if header.Type != TypeHeader {
+		return nil, fmt.Errorf("%w: backup must start with a header record", models.ErrValidation)
+	}
+	if header.Version > FormatVersion {
+		return nil, fmt.Errorf("%w: unsupported backup version %d", models.ErrValidation, header.Version)
+	}
+
+	rs := &restorer{
+		ctx: ctx, store: store, opts: opts,
+		schemas: make(map[string]*models.Schema),
+		batches: make(map[string][]*models.LogEntry),
+		result:  &RestoreResult{Project: opts.Project},
+	}
+	if rs.result.Project == "" {
+		rs.result.Project = header.Project
+	}
+	for line := 2; ; line++ {
+		var record Record
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return rs.result, fmt.Errorf("%w: invalid backup record %d: %v", models.ErrValidation, line, err)
+		}
+		if err := rs.add(&record); err != nil {
+			return rs.result, fmt.Errorf("record %d: %w", line, err)
+		}
+	}
+	if err := rs.createSchemas(); err != nil {
+		return rs.result, err
+	}
+	for table := range rs.batches {
+		if err := rs.flush(table); err != nil {
+			return rs.result, err
+		}
+	}
+	return rs.result, nil
+}
+
+// restorer 恢复过程的状态。schema 在读到第一条日志时统一创建，创建前校验全部 schema
+type restorer struct {
+	ctx     context.Context
+	store   storage.Storage
+	opts    RestoreOptions
+	schemas map[string]*models.Schema
+	order   []string
+	created bool
+	batches map[string][]*models.LogEntry
+	result  *RestoreResult
+}
+
+// add 处理一条记录
+func (rs *restorer) add(record *Record) error {
+	switch record.Type {
+	case TypeSchema:
+		if rs.created {
+			return fmt.Errorf("%w: schema records must precede log records", models.ErrValidation)
+		}
+		if record.Schema == nil {
+			return fmt.Errorf("%w: schema record without a schema", models.ErrValidation)
+		}
+		schema := record.Schema
+		schema.Project = rs.result.Project
+		if err := schema.Validate(); err != nil {
+			return err
+		}
+		if _, ok := rs.schemas[schema.Table]; !ok {
+			rs.order = append(rs.order, schema.Table)
+		}
+		rs.schemas[schema.Table] = schema
+		return nil
+	case TypeLog:
+		if err := rs.createSchemas(); err != nil {
+			return err
+		}
+		schema, ok := rs.schemas[record.Table]
+		if !ok {
+			return fmt.Errorf("%w: log for table %s without a schema", models.ErrValidation, record.Table)
+		}
+		entry, err := restoreEntry(schema, record.Row)
+		if err != nil {
+			return fmt.Errorf("%w: %v", models.ErrValidation, err)
+		}
+		rs.batches[record.Table] = append(rs.batches[record.Table], entry)
+		if len(rs.batches[record.Table]) >= rs.opts.BatchSize {
+			return rs.flush(record.Table)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown record type %q", models.ErrValidation, record.Type)
+}
+
+// createSchemas 创建尚未创建的 schema。表已存在时，Append 模式下使用已有的 schema 写入
+func (rs *restorer) createSchemas() error {
+	if rs.created {
+		return nil
+	}
+	rs.created = true
+	for _, table := range rs.order {
+		schema := rs.schemas[table]
+		existing, err := rs.store.GetSchema(rs.ctx, schema.Project, table)
+		switch {
+		case err == nil && !rs.opts.Append:
+			return fmt.Errorf("%w: %s:%s", models.ErrSchemaExists, schema.Project, table)
+		case err == nil:
+			rs.schemas[table] = existing
+			continue
+		case !errors.Is(err, models.ErrSchemaNotFound):
+			return err
+		}
+		if err := rs.store.CreateSchema(rs.ctx, schema); err != nil {
+			return fmt.Errorf("failed to create schema %s:%s: %w", schema.Project, table, err)
+		}
+		rs.result.Schemas++
+	}
+	return nil
+}
+
+// flush 写入一个表积累的日志
+func (rs *restorer) flush(table string) error {
+	batch := rs.batches[table]
+	if len(batch) == 0 {
+		return nil
+	}
+	schema := rs.schemas[table]
+	if err := rs.store.BatchInsertLogs(rs.ctx, schema.Project, table, batch); err != nil {
+		return fmt.Errorf("failed to restore logs into %s:%s: %w", schema.Project, table, err)
+	}
+	rs.result.Logs += len(batch)
+	rs.batches[table] = batch[:0]
+	return nil
+}
+
+// restoreEntry 将导出的一行转换为日志。level 与 message 只在 schema 声明时保存，
+// 未声明时填入占位值以通过校验
+func restoreEntry(schema *models.Schema, row map[string]interface{}) (*models.LogEntry, error) {
+	entry := &models.LogEntry{
+		Project: schema.Project,
+		Table:   schema.Table,
+		Level:   "info",
+		Message: "-",
+		Fields:  make(map[string]interface{}),
+	}
+	if id, ok := row["id"].(string); ok {
+		entry.ID = id
+	}
+	var err error
+	if entry.Timestamp, err = parseTime(row["timestamp"]); err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	if value, ok := row[models.IngestTimeColumn]; ok && value != nil && schema.StoresIngestTime() {
+		if entry.IngestTime, err = parseTime(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", models.IngestTimeColumn, err)
+		}
+	}
+	if value, ok := row[models.TagsColumn]; ok && value != nil && schema.StoresTags() {
+		if entry.Tags, err = restoreTags(value); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range schema.Fields {
+		value, ok := row[field.Name]
+		if !ok || value == nil {
+			continue
+		}
+		converted, err := restoreValue(field, value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		switch strings.ToLower(field.Name) {
+		case "level":
+			entry.Level, _ = converted.(string)
+		case "message":
+			entry.Message, _ = converted.(string)
+		default:
+			entry.Fields[field.Name] = converted
+		}
+	}
+	return entry, nil
+}
+
+// restoreValue 按字段类型还原 JSON 解码后的值：整数与时长恢复为 int64，JSON 类字段的文本重新解析
+func restoreValue(field *models.Field, value interface{}) (interface{}, error) {
+	switch field.Type {
+	case models.FieldTypeInt, models.FieldTypeDuration:
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i, nil
+			}
+			return n.Float64()
+		}
+	case models.FieldTypeFloat:
+		if n, ok := value.(json.Number); ok {
+			return n.Float64()
+		}
+	case models.FieldTypeBool:
+		// 没有布尔类型的后端以 0 与 1 保存
+		if n, ok := value.(json.Number); ok {
+			return n.String() != "0", nil
+		}
+	case models.FieldTypeDateTime:
+		if s, ok := value.(string); ok {
+			if t, err := parseTime(s); err == nil {
+				return t, nil
+			}
+		}
+	case models.FieldTypeJSON, models.FieldTypeRest, models.FieldTypeObject, models.FieldTypeArray:
+		if s, ok := value.(string); ok {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(s), &decoded); err == nil && !isScalar(decoded, field.Type) {
+				return decoded, nil
+			}
+		}
+	}
+	return plainNumbers(value), nil
+}
+
+// isScalar 对象与数组字段的文本解析出的标量说明原值本来就是字符串
+func isScalar(value interface{}, fieldType models.FieldType) bool {
+	if fieldType == models.FieldTypeJSON {
+		return false
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// plainNumbers 将嵌套值中的 json.Number 转换为 float64，与请求体的解码结果一致
+func plainNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = plainNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = plainNumbers(item)
+		}
+	}
+	return value
+}
+
+// restoreTags 还原标签，接受对象或 JSON 文本
+func restoreTags(value interface{}) (map[string]string, error) {
+	if s, ok := value.(string); ok {
+		if s == "" {
+			return nil, nil
+		}
+		var tags map[string]string
+		if err := json.Unmarshal([]byte(s), &tags); err != nil {
+			return nil, fmt.Errorf("invalid tags: %w", err)
+		}
+		return tags, nil
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid tags: %T", value)
+	}
+	tags := make(map[string]string, len(obj))
+	for key, v := range obj {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid tag %s: %T", key, v)
+		}
+		tags[key] = s
+	}
+	return tags, nil
+}
+
+// timeLayouts 解析导出时间的格式，RFC 3339 之外的格式来自以文本保存时间的后端
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// parseTime 解析导出的时间值
+func parseTime(value interface{}) (time.Time, error) {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("expected a time string, got %T", value)
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
@@ -0,0 +1,144 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func newStore(t *testing.T) *storage.SQLiteStorage {
+	t.Helper()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(context.Background()))
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestExportRestore(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	require.NoError(t, source.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "message", Type: models.FieldTypeString},
+			{Name: "status", Type: models.FieldTypeInt},
+			{Name: "latency", Type: models.FieldTypeDuration},
+			{Name: "ratio", Type: models.FieldTypeFloat},
+			{Name: "cached", Type: models.FieldTypeBool},
+			{Name: "seen_at", Type: models.FieldTypeDateTime},
+			{Name: "client", Type: models.FieldTypeIP},
+			{Name: "meta", Type: models.FieldTypeJSON},
+			{Name: "extra", Type: models.FieldTypeRest},
+		},
+	}))
+	require.NoError(t, source.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString}},
+	}))
+	require.NoError(t, source.CreateSchema(ctx, &models.Schema{
+		Project: "other",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString}},
+	}))
+
+	// 同一时间的日志跨越多页，校验分页不遗漏也不重复
+	base := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	var events []*models.LogEntry
+	for i := 0; i < 2*DefaultPageSize+100; i++ {
+		events = append(events, &models.LogEntry{
+			Project: "app", Table: "events", Level: "info", Message: "event",
+			Timestamp: base.Add(time.Duration(i/700) * time.Second),
+			Fields:    map[string]interface{}{"name": fmt.Sprintf("e%d", i)},
+		})
+	}
+	require.NoError(t, source.BatchInsertLogs(ctx, "app", "events", events))
+	require.NoError(t, source.InsertLog(ctx, "app", "requests", &models.LogEntry{
+		ID: "req-1", Project: "app", Table: "requests", Level: "warn", Message: "slow request",
+		Timestamp: base,
+		Tags:      map[string]string{"region": "eu"},
+		Fields: map[string]interface{}{
+			"status": 503, "latency": 1500 * time.Millisecond, "ratio": 0.25, "cached": true,
+			"seen_at": "2024-01-02T03:04:00Z", "client": "10.0.0.1",
+			"meta":  map[string]interface{}{"retries": 2, "path": []interface{}{"a", "b"}},
+			"trace": "abc",
+		},
+	}))
+
+	var buf bytes.Buffer
+	exported, err := Export(ctx, source, "app", &buf)
+	require.NoError(t, err)
+	assert.Equal(t, &ExportResult{Project: "app", Schemas: 2, Logs: len(events) + 1}, exported)
+	assert.True(t, strings.HasPrefix(buf.String(), `{"type":"header","version":1,"project":"app"`))
+
+	target := newStore(t)
+	restored, err := Restore(ctx, target, bytes.NewReader(buf.Bytes()), RestoreOptions{BatchSize: 500})
+	require.NoError(t, err)
+	assert.Equal(t, &RestoreResult{Project: "app", Schemas: 2, Logs: len(events) + 1}, restored)
+
+	rows, err := target.SearchLogs(ctx, "app", "events", &models.Query{Limit: 10 * DefaultPageSize})
+	require.NoError(t, err)
+	require.Equal(t, len(events), len(rows))
+	names := make(map[interface{}]bool)
+	for _, row := range rows {
+		names[row["name"]] = true
+	}
+	assert.Equal(t, len(events), len(names))
+
+	want, err := source.SearchLogs(ctx, "app", "requests", &models.Query{})
+	require.NoError(t, err)
+	got, err := target.SearchLogs(ctx, "app", "requests", &models.Query{})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "req-1", got[0]["id"])
+	assert.Equal(t, "slow request", got[0]["message"])
+	for _, column := range []string{"status", "latency", "ratio", "cached", "client", "meta", "extra", "tags"} {
+		assert.Equal(t, want[0][column], got[0][column], column)
+	}
+	for _, column := range []string{"timestamp", "ingest_time", "seen_at"} {
+		assert.True(t, want[0][column].(time.Time).Equal(got[0][column].(time.Time)), column)
+	}
+
+	// 表已存在时需要 Append，追加到另一个项目时使用新的项目名
+	_, err = Restore(ctx, target, bytes.NewReader(buf.Bytes()), RestoreOptions{})
+	assert.ErrorIs(t, err, models.ErrSchemaExists)
+	restored, err = Restore(ctx, target, bytes.NewReader(buf.Bytes()), RestoreOptions{Project: "copy"})
+	require.NoError(t, err)
+	assert.Equal(t, "copy", restored.Project)
+	schema, err := target.GetSchema(ctx, "copy", "requests")
+	require.NoError(t, err)
+	assert.Equal(t, "copy", schema.Project)
+
+	_, err = Export(ctx, source, "missing", &buf)
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	for name, input := range map[string]string{
+		"empty":       ``,
+		"no header":   `{"type":"log","table":"events","row":{}}`,
+		"newer":       `{"type":"header","version":99,"project":"app"}`,
+		"no schema":   `{"type":"header","version":1,"project":"app"}` + "\n" + `{"type":"log","table":"events","row":{"timestamp":"2024-01-02T03:04:05Z"}}`,
+		"bad time":    `{"type":"header","version":1,"project":"app"}` + "\n" + `{"type":"schema","schema":{"table":"events","fields":[{"name":"name","type":"string"}]}}` + "\n" + `{"type":"log","table":"events","row":{"timestamp":"yesterday"}}`,
+		"unknown":     `{"type":"header","version":1,"project":"app"}` + "\n" + `{"type":"index"}`,
+		"late schema": `{"type":"header","version":1,"project":"app"}` + "\n" + `{"type":"schema","schema":{"table":"events","fields":[{"name":"name","type":"string"}]}}` + "\n" + `{"type":"log","table":"events","row":{"timestamp":"2024-01-02T03:04:05Z"}}` + "\n" + `{"type":"schema","schema":{"table":"late","fields":[{"name":"name","type":"string"}]}}`,
+	} {
+		_, err := Restore(ctx, store, strings.NewReader(input), RestoreOptions{Project: strings.ReplaceAll(name, " ", "_")})
+		assert.ErrorIs(t, err, models.ErrValidation, name)
+	}
+}
@@ -0,0 +1,182 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// MigrateOptions 迁移选项
+type MigrateOptions struct {
+	// Projects 只迁移这些项目，为空时迁移全部项目
+	Projects []string
+	// BatchSize 每批读取与写入的日志条数，默认 DefaultBatchSize
+	BatchSize int
+	// Cursors 按 project:table 记录的各表进度，迁移过程中原地更新；传入上次保存的进度以从中断处继续
+	Cursors map[string]*Cursor
+	// OnBatch 每批写入后调用，用于保存进度与输出进度；返回错误时中止迁移
+	OnBatch func(schema *models.Schema, cursor *Cursor) error
+}
+
+// TableResult 一个表的迁移结果与校验计数
+type TableResult struct {
+	Project string `json:"project"`
+	Table   string `json:"table"`
+	Created bool   `json:"created"` // 目标存储中的表由本次迁移创建
+	Copied  int    `json:"copied"`  // 累计复制的行数，包含之前中断的迁移
+	Source  int64  `json:"source"`  // 源表的行数，存储不支持计数时为 -1
+	Target  int64  `json:"target"`  // 目标表的行数，存储不支持计数时为 -1
+}
+
+// Verified 源表与目标表的行数均已知且相等
+func (r *TableResult) Verified() bool {
+	return r.Source >= 0 && r.Source == r.Target
+}
+
+// CursorKey 返回表在 MigrateOptions.Cursors 中的键
+func CursorKey(project, table string) string {
+	return project + ":" + table
+}
+
+// Migrate 将 src 中的 schema 与日志复制到 dst。目标存储中不存在的表按 schema 创建，字段类型由目标后端的建表规则转换，
+// 已存在的表向其追加；日志按 timestamp、id 顺序分批读取并转换为目标 schema 的字段值后写入，保留 id、timestamp、
+// ingest_time 与标签。每个表复制完成后统计两端的行数用于校验。
+// 从中断处继续时，若上次最后一批已写入但进度未保存，按该批最后一行的 id 检测并跳过，避免重复写入
+func Migrate(ctx context.Context, src, dst storage.Storage, opts MigrateOptions) ([]*TableResult, error) {
+	querier, ok := storage.As[storage.LogQuerier](src)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Cursors == nil {
+		opts.Cursors = make(map[string]*Cursor)
+	}
+	schemas, err := src.ListSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Projects) > 0 {
+		var selected []*models.Schema
+		for _, project := range opts.Projects {
+			matched, err := projectSchemas(ctx, src, project)
+			if err != nil {
+				return nil, err
+			}
+			selected = append(selected, matched...)
+		}
+		schemas = selected
+	}
+
+	results := make([]*TableResult, 0, len(schemas))
+	for _, schema := range schemas {
+		result, err := migrateTable(ctx, src, querier, dst, schema, &opts)
+		results = append(results, result)
+		if err != nil {
+			return results, fmt.Errorf("failed to migrate %s:%s: %w", schema.Project, schema.Table, err)
+		}
+	}
+	return results, nil
+}
+
+// migrateTable 创建目标表并复制一个表的日志
+func migrateTable(ctx context.Context, src storage.Storage, querier storage.LogQuerier, dst storage.Storage, schema *models.Schema, opts *MigrateOptions) (*TableResult, error) {
+	result := &TableResult{Project: schema.Project, Table: schema.Table, Source: -1, Target: -1}
+	target, err := dst.GetSchema(ctx, schema.Project, schema.Table)
+	if errors.Is(err, models.ErrSchemaNotFound) {
+		target = schema
+		if err = dst.CreateSchema(ctx, target); err == nil {
+			result.Created = true
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+
+	key := CursorKey(schema.Project, schema.Table)
+	cursor := opts.Cursors[key]
+	if cursor == nil {
+		cursor = &Cursor{}
+		opts.Cursors[key] = cursor
+	}
+	resumed := cursor.Copied > 0
+	for !cursor.Done {
+		rows, err := cursor.next(ctx, querier, schema, opts.BatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(rows) > 0 && !(resumed && written(ctx, dst, target, rows[len(rows)-1])) {
+			entries := make([]*models.LogEntry, len(rows))
+			for i, row := range rows {
+				if entries[i], err = convertRow(target, row); err != nil {
+					return result, fmt.Errorf("log %v: %w", row["id"], err)
+				}
+			}
+			if err := dst.BatchInsertLogs(ctx, target.Project, target.Table, entries); err != nil {
+				return result, err
+			}
+		}
+		resumed = false
+		if err := cursor.advance(rows, opts.BatchSize); err != nil {
+			return result, err
+		}
+		result.Copied = cursor.Copied
+		if opts.OnBatch != nil {
+			if err := opts.OnBatch(schema, cursor); err != nil {
+				return result, err
+			}
+		}
+	}
+	result.Copied = cursor.Copied
+
+	if result.Source, err = countLogs(ctx, src, schema); err != nil {
+		return result, err
+	}
+	if result.Target, err = countLogs(ctx, dst, target); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// written 目标表中是否已有与 row 相同 id 的日志，目标存储不支持查询时返回 false
+func written(ctx context.Context, dst storage.Storage, schema *models.Schema, row map[string]interface{}) bool {
+	querier, ok := storage.As[storage.LogQuerier](dst)
+	id, _ := row["id"].(string)
+	if !ok || id == "" {
+		return false
+	}
+	rows, err := querier.SearchLogs(ctx, schema.Project, schema.Table, &models.Query{
+		Filter: map[string]interface{}{"id": id}, Fields: []string{"id"}, Limit: 1,
+	})
+	return err == nil && len(rows) > 0
+}
+
+// convertRow 将源存储返回的一行按 JSON 编码再解码，转换为目标 schema 的日志，与导出后恢复的结果一致
+func convertRow(schema *models.Schema, row map[string]interface{}) (*models.LogEntry, error) {
+	data, err := json.Marshal(exportRow(row))
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var decoded map[string]interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return restoreEntry(schema, decoded)
+}
+
+// countLogs 统计表的行数，存储不支持计数时返回 -1
+func countLogs(ctx context.Context, store storage.Storage, schema *models.Schema) (int64, error) {
+	counter, ok := storage.As[storage.LogMutator](store)
+	if !ok {
+		return -1, nil
+	}
+	return counter.CountMatching(ctx, schema.Project, schema.Table, &models.LogFilter{})
+}
@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	require.NoError(t, source.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "status", Type: models.FieldTypeInt},
+			{Name: "latency", Type: models.FieldTypeDuration},
+			{Name: "meta", Type: models.FieldTypeJSON},
+		},
+	}))
+	require.NoError(t, source.CreateSchema(ctx, &models.Schema{
+		Project: "other",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString}},
+	}))
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var entries []*models.LogEntry
+	for i := 0; i < 25; i++ {
+		entries = append(entries, &models.LogEntry{
+			Project: "app", Table: "requests", Level: "info", Message: "request",
+			Timestamp: base.Add(time.Duration(i/10) * time.Second),
+			Tags:      map[string]string{"n": fmt.Sprint(i)},
+			Fields: map[string]interface{}{
+				"status": 200 + i, "latency": time.Duration(i) * time.Millisecond, "meta": map[string]interface{}{"i": i},
+			},
+		})
+	}
+	require.NoError(t, source.BatchInsertLogs(ctx, "app", "requests", entries))
+
+	// 第二批写入后中断，进度停留在已写入但未保存的位置
+	target := newStore(t)
+	cursors := make(map[string]*Cursor)
+	batches := 0
+	errStop := errors.New("stop")
+	_, err := Migrate(ctx, source, target, MigrateOptions{
+		Projects: []string{"app"}, BatchSize: 10, Cursors: cursors,
+		OnBatch: func(schema *models.Schema, cursor *Cursor) error {
+			if batches++; batches == 2 {
+				return errStop
+			}
+			return nil
+		},
+	})
+	require.ErrorIs(t, err, errStop)
+	saved := *cursors[CursorKey("app", "requests")]
+	assert.Equal(t, 20, saved.Copied)
+	// 模拟只保存了第一批的进度
+	saved.From, saved.Offset, saved.Copied = &base, 10, 10
+
+	results, err := Migrate(ctx, source, target, MigrateOptions{
+		Projects: []string{"app"}, BatchSize: 10,
+		Cursors: map[string]*Cursor{CursorKey("app", "requests"): &saved},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, &TableResult{Project: "app", Table: "requests", Copied: 25, Source: 25, Target: 25}, results[0])
+	assert.True(t, results[0].Verified())
+
+	want, err := source.SearchLogs(ctx, "app", "requests", &models.Query{Sort: []string{"timestamp", "id"}})
+	require.NoError(t, err)
+	got, err := target.SearchLogs(ctx, "app", "requests", &models.Query{Sort: []string{"timestamp", "id"}})
+	require.NoError(t, err)
+	require.Len(t, got, len(want))
+	for i := range want {
+		for _, column := range []string{"id", "status", "latency", "meta", "tags"} {
+			assert.Equal(t, want[i][column], got[i][column], column)
+		}
+	}
+
+	// 迁移到另一种后端时按目标后端建表，不支持计数的存储无法校验
+	files := storage.NewFileStorage(storage.Config{Type: "file", File: storage.FileConfig{Dir: t.TempDir()}})
+	require.NoError(t, files.Initialize(ctx))
+	defer files.Close()
+	results, err = Migrate(ctx, source, files, MigrateOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Created)
+	assert.Equal(t, int64(-1), results[0].Target)
+	assert.False(t, results[0].Verified())
+	rows, err := files.SearchLogs(ctx, "app", "requests", &models.Query{Filter: map[string]interface{}{"status": 224}})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, want[24]["id"], rows[0]["id"])
+
+	_, err = Migrate(ctx, source, target, MigrateOptions{Projects: []string{"missing"}})
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+}
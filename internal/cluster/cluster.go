@@ -0,0 +1,137 @@
+// Package cluster 协调共享同一数据库的多个服务器实例：选举执行后台任务（归档清理、rollup、定时报表）的 leader，
+// 并在实例之间广播 schema 与报表的变更，使其他实例的缓存失效
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"sync"
+)
+
+// 消息类型
+const (
+	KindSchema = "schema" // schema 被创建、修改或删除，Project 与 Table 为其名称
+	KindReport = "report" // 定时报表被保存或删除，Owner 与 Name 为其名称
+)
+
+// Message 实例之间广播的变更通知，只携带名称，接收方从存储重新读取
+type Message struct {
+	Instance string `json:"instance"` // 发送方实例，实例不会收到自己发送的消息
+	Kind     string `json:"kind"`
+	Project  string `json:"project,omitempty"`
+	Table    string `json:"table,omitempty"`
+	Owner    string `json:"owner,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// Coordinator 多实例部署的协调层
+type Coordinator interface {
+	// Leading 返回本实例当前是否为 leader，未持有领导权时尝试获取；出错时返回 false。
+	// 后台任务每次执行前调用，只有 leader 执行
+	Leading(ctx context.Context) bool
+
+	// Publish 向其他实例广播消息，Instance 由 Coordinator 填写
+	Publish(ctx context.Context, msg *Message) error
+
+	// Messages 返回其他实例发布的消息。收到 nil 表示与协调层的连接曾经中断，期间的消息可能丢失，
+	// 接收方应丢弃全部缓存
+	Messages() <-chan *Message
+
+	// Close 放弃领导权并断开连接，之后 Messages 返回的通道被关闭
+	Close() error
+}
+
+// NewInstanceID 生成实例标识：主机名加随机后缀，同一主机上的多个实例也不会重复
+func NewInstanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	if host == "" {
+		return hex.EncodeToString(b)
+	}
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// Group 同一进程内的一组实例，用于测试与在一个进程中运行多个服务器。
+// 最先调用 Leading 的成员成为 leader，直到其 Close
+type Group struct {
+	mu      sync.Mutex
+	leader  *Member
+	members map[*Member]bool
+}
+
+// NewGroup 创建进程内的实例组
+func NewGroup() *Group {
+	return &Group{members: make(map[*Member]bool)}
+}
+
+// Join 加入一个实例
+func (g *Group) Join(instance string) *Member {
+	m := &Member{group: g, instance: instance, messages: make(chan *Message, 1024)}
+	g.mu.Lock()
+	g.members[m] = true
+	g.mu.Unlock()
+	return m
+}
+
+// Member 进程内实例组的成员
+type Member struct {
+	group    *Group
+	instance string
+	messages chan *Message
+}
+
+// Leading 没有 leader 时成为 leader
+func (m *Member) Leading(ctx context.Context) bool {
+	m.group.mu.Lock()
+	defer m.group.mu.Unlock()
+	if !m.group.members[m] {
+		return false
+	}
+	if m.group.leader == nil {
+		m.group.leader = m
+	}
+	return m.group.leader == m
+}
+
+// Publish 将消息投递给其他成员，成员的缓冲区已满时丢弃
+func (m *Member) Publish(ctx context.Context, msg *Message) error {
+	sent := *msg
+	sent.Instance = m.instance
+	m.group.mu.Lock()
+	defer m.group.mu.Unlock()
+	for other := range m.group.members {
+		if other == m {
+			continue
+		}
+		select {
+		case other.messages <- &sent:
+		default:
+		}
+	}
+	return nil
+}
+
+// Messages 返回其他成员发布的消息
+func (m *Member) Messages() <-chan *Message {
+	return m.messages
+}
+
+// Close 离开实例组，是 leader 时放弃领导权
+func (m *Member) Close() error {
+	m.group.mu.Lock()
+	defer m.group.mu.Unlock()
+	if !m.group.members[m] {
+		return nil
+	}
+	delete(m.group.members, m)
+	if m.group.leader == m {
+		m.group.leader = nil
+	}
+	close(m.messages)
+	return nil
+}
+
+var _ Coordinator = (*Member)(nil)
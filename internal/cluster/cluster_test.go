@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGroup(t *testing.T) {
+	ctx := context.Background()
+	group := NewGroup()
+	a, b, c := group.Join("a"), group.Join("b"), group.Join("c")
+
+	assert.True(t, b.Leading(ctx))
+	assert.True(t, b.Leading(ctx), "the leader keeps leading")
+	assert.False(t, a.Leading(ctx))
+
+	require.NoError(t, a.Publish(ctx, &Message{Kind: KindSchema, Project: "app", Table: "events"}))
+	for _, m := range []*Member{b, c} {
+		msg := <-m.Messages()
+		assert.Equal(t, &Message{Instance: "a", Kind: KindSchema, Project: "app", Table: "events"}, msg)
+	}
+	assert.Empty(t, a.Messages(), "members do not receive their own messages")
+
+	require.NoError(t, b.Close())
+	require.NoError(t, b.Close())
+	_, ok := <-b.Messages()
+	assert.False(t, ok, "closing a member closes its channel")
+	assert.False(t, b.Leading(ctx))
+	assert.True(t, c.Leading(ctx), "another member takes over after the leader leaves")
+	assert.False(t, a.Leading(ctx))
+}
+
+func TestNewInstanceID(t *testing.T) {
+	assert.NotEqual(t, NewInstanceID(), NewInstanceID())
+}
+
+// postgresDSN 测试使用的 PostgreSQL，可通过环境变量覆盖
+func postgresDSN() string {
+	env := func(key, value string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return value
+	}
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		env("POSTGRES_HOST", "localhost"), env("POSTGRES_PORT", "5432"), env("POSTGRES_USERNAME", "postgres"),
+		env("POSTGRES_PASSWORD", "postgres"), env("POSTGRES_DATABASE", "logs_test"))
+}
+
+func TestPostgres(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	channel := fmt.Sprintf("logs_cluster_test_%d", time.Now().UnixNano())
+	open := func(instance string) *Postgres {
+		p, err := NewPostgres(ctx, PostgresConfig{DSN: postgresDSN(), Channel: channel, Instance: instance, Logger: zap.NewNop()})
+		if err != nil {
+			t.Skipf("Skipping test: cannot connect to PostgreSQL: %v", err)
+		}
+		return p
+	}
+	a := open("a")
+	defer a.Close()
+	b := open("b")
+	defer b.Close()
+
+	assert.True(t, a.Leading(ctx))
+	assert.True(t, a.Leading(ctx))
+	assert.False(t, b.Leading(ctx))
+
+	require.NoError(t, a.Publish(ctx, &Message{Kind: KindReport, Owner: "user:alice", Name: "daily"}))
+	select {
+	case msg := <-b.Messages():
+		assert.Equal(t, &Message{Instance: "a", Kind: KindReport, Owner: "user:alice", Name: "daily"}, msg)
+	case <-ctx.Done():
+		t.Fatal("b did not receive the message")
+	}
+	select {
+	case msg := <-a.Messages():
+		t.Fatalf("a received its own message: %+v", msg)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	require.NoError(t, a.Close())
+	assert.True(t, b.Leading(ctx), "the lock is released when the leader closes")
+}
@@ -0,0 +1,209 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/logging"
+)
+
+// DefaultChannel 默认的 LISTEN/NOTIFY 通道
+const DefaultChannel = "logs_cluster"
+
+// PostgresConfig 基于 PostgreSQL 的协调配置
+type PostgresConfig struct {
+	// DSN 连接串，如 host=db port=5432 user=logs password=... dbname=logs sslmode=disable
+	DSN string
+
+	// Channel LISTEN/NOTIFY 通道名，默认 DefaultChannel；共用数据库的不同部署应使用不同的通道
+	Channel string
+
+	// LockName 领导权 advisory lock 的名称，按 FNV-64 转换为锁编号，默认与 Channel 相同
+	LockName string
+
+	// Instance 本实例的标识，默认 NewInstanceID
+	Instance string
+
+	// Logger 可选，为空时使用全局 logger
+	Logger *zap.Logger
+}
+
+// Postgres 以会话级 advisory lock 选举 leader、以 LISTEN/NOTIFY 广播消息。
+// leader 锁由一个专用连接持有，连接断开（如实例崩溃）时数据库自动释放，其他实例在下次 Leading 时接替
+type Postgres struct {
+	db       *sql.DB
+	listener *pq.Listener
+	channel  string
+	lockKey  int64
+	instance string
+	logger   *zap.Logger
+	messages chan *Message
+	done     chan struct{}
+	closed   sync.Once
+
+	mu   sync.Mutex
+	conn *sql.Conn // 持有 leader 锁的连接，未持有时为 nil
+}
+
+// NewPostgres 连接数据库并开始监听通道
+func NewPostgres(ctx context.Context, cfg PostgresConfig) (*Postgres, error) {
+	if cfg.Channel == "" {
+		cfg.Channel = DefaultChannel
+	}
+	if cfg.LockName == "" {
+		cfg.LockName = cfg.Channel
+	}
+	if cfg.Instance == "" {
+		cfg.Instance = NewInstanceID()
+	}
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cluster database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to cluster database: %w", err)
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(cfg.LockName))
+	p := &Postgres{
+		db:       db,
+		channel:  cfg.Channel,
+		lockKey:  int64(hash.Sum64()),
+		instance: cfg.Instance,
+		logger:   logging.Component(cfg.Logger, "cluster"),
+		messages: make(chan *Message, 256),
+		done:     make(chan struct{}),
+	}
+	p.listener = pq.NewListener(cfg.DSN, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			p.logger.Warn("cluster listener disconnected", zap.Error(err))
+		case pq.ListenerEventReconnected:
+			p.logger.Info("cluster listener reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			p.logger.Warn("cluster listener reconnect failed", zap.Error(err))
+		}
+	})
+	if err := p.listener.Listen(cfg.Channel); err != nil {
+		p.listener.Close()
+		db.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", cfg.Channel, err)
+	}
+	go p.receive()
+	return p, nil
+}
+
+// receive 转发其他实例的通知，重连后发送 nil
+func (p *Postgres) receive() {
+	defer close(p.messages)
+	for {
+		select {
+		case n, ok := <-p.listener.Notify:
+			if !ok {
+				return
+			}
+			var msg *Message
+			if n != nil {
+				msg = &Message{}
+				if err := json.Unmarshal([]byte(n.Extra), msg); err != nil {
+					p.logger.Warn("invalid cluster message", zap.String("payload", n.Extra), zap.Error(err))
+					continue
+				}
+				if msg.Instance == p.instance {
+					continue
+				}
+			}
+			select {
+			case p.messages <- msg:
+			case <-p.done:
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Leading 持有锁的连接仍然可用时保持领导权，否则在新连接上以 pg_try_advisory_lock 尝试获取
+func (p *Postgres) Leading(ctx context.Context) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.done:
+		return false
+	default:
+	}
+
+	if p.conn != nil {
+		if _, err := p.conn.ExecContext(ctx, "SELECT 1"); err == nil {
+			return true
+		}
+		// 连接已断开，数据库随之释放了锁
+		p.logger.Warn("lost cluster leadership")
+		p.conn.Close()
+		p.conn = nil
+	}
+
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		p.logger.Warn("failed to acquire cluster leadership", zap.Error(err))
+		return false
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", p.lockKey).Scan(&acquired); err != nil {
+		p.logger.Warn("failed to acquire cluster leadership", zap.Error(err))
+		conn.Close()
+		return false
+	}
+	if !acquired {
+		conn.Close()
+		return false
+	}
+	p.logger.Info("acquired cluster leadership", zap.String("instance", p.instance))
+	p.conn = conn
+	return true
+}
+
+// Publish 以 pg_notify 广播消息
+func (p *Postgres) Publish(ctx context.Context, msg *Message) error {
+	sent := *msg
+	sent.Instance = p.instance
+	payload, err := json.Marshal(&sent)
+	if err != nil {
+		return err
+	}
+	if _, err := p.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", p.channel, string(payload)); err != nil {
+		return fmt.Errorf("failed to publish cluster message: %w", err)
+	}
+	return nil
+}
+
+// Messages 返回其他实例发布的消息
+func (p *Postgres) Messages() <-chan *Message {
+	return p.messages
+}
+
+// Close 释放 leader 锁并关闭连接
+func (p *Postgres) Close() error {
+	p.closed.Do(func() { close(p.done) })
+	p.mu.Lock()
+	if p.conn != nil {
+		p.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", p.lockKey)
+		p.conn.Close()
+		p.conn = nil
+	}
+	p.mu.Unlock()
+	p.listener.Close()
+	return p.db.Close()
+}
+
+var _ Coordinator = (*Postgres)(nil)
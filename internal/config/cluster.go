@@ -0,0 +1,27 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"pkg.blksails.net/logs/internal/cluster"
+)
+
+// Cluster 从配置的 cluster 节点读取多实例协调配置，cluster.dsn 为空时使用 storage.postgres 的连接配置；Logger 由调用方设置
+func Cluster(v *viper.Viper) cluster.PostgresConfig {
+	dsn := v.GetString("cluster.dsn")
+	if dsn == "" {
+		dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+			v.GetString("storage.postgres.host"),
+			v.GetInt("storage.postgres.port"),
+			v.GetString("storage.postgres.user"),
+			v.GetString("storage.postgres.password"),
+			v.GetString("storage.postgres.database"),
+		)
+	}
+	return cluster.PostgresConfig{
+		DSN:      dsn,
+		Channel:  v.GetString("cluster.channel"),
+		Instance: v.GetString("cluster.instance"),
+	}
+}
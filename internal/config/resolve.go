@@ -0,0 +1,174 @@
+// Package config 解析配置文件中的环境变量与密钥引用
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// namePattern 环境变量名
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SecretProvider 按引用读取密钥，引用格式由各实现定义
+type SecretProvider interface {
+	Secret(ctx context.Context, ref string) (string, error)
+}
+
+// Resolver 展开配置值中的 ${...} 引用：
+//
+//	${NAME}            环境变量，未设置时报错
+//	${NAME:-default}   环境变量，未设置或为空时使用 default
+//	${scheme:ref}      由 scheme 对应的 SecretProvider 读取，如 ${vault:secret/data/logs#password}
+//	$${                输出字面量 ${
+//
+// 同一引用只解析一次
+type Resolver struct {
+	providers map[string]SecretProvider
+	lookupEnv func(string) (string, bool)
+	cache     map[string]string
+}
+
+// Option Resolver 配置项
+type Option func(*Resolver)
+
+// WithProvider 注册或替换 scheme 对应的密钥来源
+func WithProvider(scheme string, provider SecretProvider) Option {
+	return func(r *Resolver) {
+		r.providers[scheme] = provider
+	}
+}
+
+// WithLookupEnv 替换环境变量的读取方式，用于测试
+func WithLookupEnv(lookup func(string) (string, bool)) Option {
+	return func(r *Resolver) {
+		r.lookupEnv = lookup
+	}
+}
+
+// NewResolver 创建 Resolver，默认注册 vault、awssm 与 sops 三种密钥来源
+func NewResolver(opts ...Option) *Resolver {
+	r := &Resolver{
+		providers: map[string]SecretProvider{
+			"vault": NewVaultProvider(),
+			"awssm": &AWSSecretsManagerProvider{},
+			"sops":  &SOPSProvider{},
+		},
+		lookupEnv: os.LookupEnv,
+		cache:     make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Expand 展开字符串中的全部引用
+func (r *Resolver) Expand(ctx context.Context, value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var b strings.Builder
+	rest := value
+	for {
+		i := strings.Index(rest, "${")
+		if i < 0 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		// $${ 转义为字面量 ${
+		if i > 0 && rest[i-1] == '$' {
+			b.WriteString(rest[:i-1])
+			b.WriteString("${")
+			rest = rest[i+2:]
+			continue
+		}
+		b.WriteString(rest[:i])
+
+		end := strings.IndexByte(rest[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", value)
+		}
+		resolved, err := r.resolve(ctx, rest[i+2:i+end])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(resolved)
+		rest = rest[i+end+1:]
+	}
+}
+
+// resolve 解析单个引用的内容（不含 ${ 与 }）
+func (r *Resolver) resolve(ctx context.Context, ref string) (string, error) {
+	if scheme, path, ok := strings.Cut(ref, ":"); ok && !strings.HasPrefix(path, "-") {
+		provider, ok := r.providers[scheme]
+		if !ok {
+			return "", fmt.Errorf("unknown secret provider %q in ${%s}", scheme, ref)
+		}
+		if value, ok := r.cache[ref]; ok {
+			return value, nil
+		}
+		value, err := provider.Secret(ctx, path)
+		if err != nil {
+			return "", fmt.Errorf("resolve ${%s}: %w", ref, err)
+		}
+		r.cache[ref] = value
+		return value, nil
+	}
+
+	name, fallback, hasDefault := strings.Cut(ref, ":-")
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid reference ${%s}", ref)
+	}
+	value, ok := r.lookupEnv(name)
+	if hasDefault && value == "" {
+		return fallback, nil
+	}
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// ResolveViper 展开 viper 中全部字符串与字符串列表配置项，
+// 展开在解析 YAML 之后进行，注释中的引用不会被解析
+func (r *Resolver) ResolveViper(ctx context.Context, v *viper.Viper) error {
+	for _, key := range v.AllKeys() {
+		switch value := v.Get(key).(type) {
+		case string:
+			expanded, err := r.Expand(ctx, value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			if expanded != value {
+				v.Set(key, expanded)
+			}
+		case []interface{}:
+			changed := false
+			items := make([]interface{}, len(value))
+			for i, item := range value {
+				items[i] = item
+				s, ok := item.(string)
+				if !ok {
+					continue
+				}
+				expanded, err := r.Expand(ctx, s)
+				if err != nil {
+					return fmt.Errorf("%s[%d]: %w", key, i, err)
+				}
+				if expanded != s {
+					items[i], changed = expanded, true
+				}
+			}
+			if changed {
+				v.Set(key, items)
+			}
+		}
+	}
+	return nil
+}
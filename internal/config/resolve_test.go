@@ -0,0 +1,179 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticProvider 返回固定值并记录调用次数
+type staticProvider struct {
+	values map[string]string
+	calls  int
+}
+
+func (p *staticProvider) Secret(ctx context.Context, ref string) (string, error) {
+	p.calls++
+	value, ok := p.values[ref]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func TestResolverExpand(t *testing.T) {
+	env := map[string]string{"DB_HOST": "db.internal", "EMPTY": ""}
+	secrets := &staticProvider{values: map[string]string{"db#password": "s3cret"}}
+	r := NewResolver(
+		WithLookupEnv(func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		}),
+		WithProvider("test", secrets),
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		in, out string
+	}{
+		{"plain", "plain"},
+		{"$HOME stays", "$HOME stays"},
+		{"${DB_HOST}", "db.internal"},
+		{"host=${DB_HOST} port=${DB_PORT:-5432}", "host=db.internal port=5432"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${EMPTY}", ""},
+		{"${test:db#password}", "s3cret"},
+		{"p$${literal}", "p${literal}"},
+	}
+	for _, tt := range tests {
+		got, err := r.Expand(ctx, tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.out, got, tt.in)
+	}
+
+	_, err := r.Expand(ctx, "${test:db#password}")
+	require.NoError(t, err)
+	assert.Equal(t, 1, secrets.calls, "secret references are cached")
+
+	for _, in := range []string{"${MISSING}", "${unknown:ref}", "${test:missing}", "${bad name}", "${DB_HOST"} {
+		_, err := r.Expand(ctx, in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestResolveViper(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(`
+# ${NOT_SET} in a comment is ignored
+storage:
+  postgres:
+    host: ${DB_HOST}
+    port: 5432
+    password: ${test:db#password}
+    replicas:
+      - "host=${DB_HOST} port=5433"
+`)))
+
+	r := NewResolver(
+		WithLookupEnv(func(name string) (string, bool) {
+			if name == "DB_HOST" {
+				return "db.internal", true
+			}
+			return "", false
+		}),
+		WithProvider("test", &staticProvider{values: map[string]string{"db#password": "s3cret"}}),
+	)
+	require.NoError(t, r.ResolveViper(context.Background(), v))
+	assert.Equal(t, "db.internal", v.GetString("storage.postgres.host"))
+	assert.Equal(t, 5432, v.GetInt("storage.postgres.port"))
+	assert.Equal(t, "s3cret", v.GetString("storage.postgres.password"))
+	assert.Equal(t, []string{"host=db.internal port=5433"}, v.GetStringSlice("storage.postgres.replicas"))
+
+	v.Set("server.host", "${NOT_SET}")
+	err := r.ResolveViper(context.Background(), v)
+	assert.ErrorContains(t, err, "server.host")
+}
+
+func TestVaultProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "team", req.Header.Get("X-Vault-Namespace"))
+		switch req.URL.Path {
+		case "/v1/secret/data/logs":
+			w.Write([]byte(`{"data": {"data": {"password": "v2-secret", "port": 5432}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/logs":
+			w.Write([]byte(`{"data": {"password": "v1-secret"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := &VaultProvider{Address: ts.URL, Token: "token", Namespace: "team"}
+	ctx := context.Background()
+	value, err := p.Secret(ctx, "secret/data/logs#password")
+	require.NoError(t, err)
+	assert.Equal(t, "v2-secret", value)
+	value, err = p.Secret(ctx, "secret/data/logs#port")
+	require.NoError(t, err)
+	assert.Equal(t, "5432", value)
+	value, err = p.Secret(ctx, "kv/logs#password")
+	require.NoError(t, err)
+	assert.Equal(t, "v1-secret", value)
+
+	_, err = p.Secret(ctx, "secret/data/logs#missing")
+	assert.ErrorContains(t, err, "key missing not found")
+	_, err = p.Secret(ctx, "secret/data/other#password")
+	assert.ErrorContains(t, err, "404")
+	_, err = p.Secret(ctx, "secret/data/logs")
+	assert.ErrorContains(t, err, "path#key")
+	_, err = (&VaultProvider{}).Secret(ctx, "secret/data/logs#password")
+	assert.ErrorContains(t, err, "VAULT_ADDR")
+}
+
+func TestAWSSecretsManagerProvider(t *testing.T) {
+	var args []string
+	p := &AWSSecretsManagerProvider{Run: func(ctx context.Context, name string, a ...string) ([]byte, error) {
+		args = append([]string{name}, a...)
+		return []byte(`{"username": "logs", "password": "aws-secret"}` + "\n"), nil
+	}}
+
+	value, err := p.Secret(context.Background(), "prod/logs/db#password")
+	require.NoError(t, err)
+	assert.Equal(t, "aws-secret", value)
+	assert.Equal(t, []string{"aws", "secretsmanager", "get-secret-value", "--secret-id", "prod/logs/db",
+		"--query", "SecretString", "--output", "text"}, args)
+
+	value, err = p.Secret(context.Background(), "prod/logs/db")
+	require.NoError(t, err)
+	assert.Equal(t, `{"username": "logs", "password": "aws-secret"}`, value)
+}
+
+func TestSOPSProvider(t *testing.T) {
+	calls := 0
+	p := &SOPSProvider{Run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		calls++
+		assert.Equal(t, []string{"--decrypt", "--output-type", "json", "secrets.enc.yaml"}, args)
+		return []byte(`{"storage": {"postgres": {"password": "sops-secret"}}}`), nil
+	}}
+
+	value, err := p.Secret(context.Background(), "secrets.enc.yaml#storage.postgres.password")
+	require.NoError(t, err)
+	assert.Equal(t, "sops-secret", value)
+	_, err = p.Secret(context.Background(), "secrets.enc.yaml#storage.mysql.password")
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "decrypted files are cached")
+	_, err = p.Secret(context.Background(), "secrets.enc.yaml#storage.postgres")
+	assert.ErrorContains(t, err, "not a scalar")
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vaultTimeout 访问 Vault 的超时时间
+const vaultTimeout = 10 * time.Second
+
+// CommandRunner 执行外部命令并返回标准输出
+type CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// runCommand 默认的命令执行方式，失败时错误中带上标准错误输出
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
+
+// VaultProvider 通过 HTTP API 读取 Vault KV 密钥，引用格式为 path#key，
+// 如 secret/data/logs#password（KV v2）或 secret/logs#password（KV v1）
+type VaultProvider struct {
+	Address   string // 默认读取 VAULT_ADDR
+	Token     string // 默认读取 VAULT_TOKEN
+	Namespace string // 默认读取 VAULT_NAMESPACE
+	Client    *http.Client
+}
+
+// NewVaultProvider 创建从环境变量读取地址与令牌的 VaultProvider
+func NewVaultProvider() *VaultProvider {
+	return &VaultProvider{
+		Address:   os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+	}
+}
+
+// Secret 读取密钥中的一个键
+func (p *VaultProvider) Secret(ctx context.Context, ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("vault reference must be path#key, got %q", ref)
+	}
+	if p.Address == "" || p.Token == "" {
+		return "", fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are required")
+	}
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: vaultTimeout}
+	}
+
+	url := strings.TrimRight(p.Address, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	if p.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+	data := secret.Data
+	// KV v2 的键位于 data.data 中
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, isMeta := data["metadata"]; isMeta {
+			data = nested
+		}
+	}
+	return secretValue(data, key)
+}
+
+// AWSSecretsManagerProvider 通过 aws 命令行读取 AWS Secrets Manager 密钥，
+// 使用 aws 命令行自身的凭证与区域配置。引用格式为 secret-id 或 secret-id#key，
+// 带 key 时密钥内容按 JSON 解析并取出对应的键
+type AWSSecretsManagerProvider struct {
+	Run CommandRunner // 为空时执行 aws 命令
+}
+
+// Secret 读取密钥
+func (p *AWSSecretsManagerProvider) Secret(ctx context.Context, ref string) (string, error) {
+	id, key, hasKey := strings.Cut(ref, "#")
+	if id == "" {
+		return "", fmt.Errorf("awssm reference must be secret-id or secret-id#key, got %q", ref)
+	}
+	run := p.Run
+	if run == nil {
+		run = runCommand
+	}
+	out, err := run(ctx, "aws", "secretsmanager", "get-secret-value",
+		"--secret-id", id, "--query", "SecretString", "--output", "text")
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSuffix(string(out), "\n")
+	if !hasKey {
+		return value, nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", id, err)
+	}
+	return secretValue(data, key)
+}
+
+// SOPSProvider 通过 sops 命令行解密文件并读取其中的值，使用 sops 自身的密钥配置。
+// 引用格式为 file#path，path 以 . 分隔嵌套的键，如 configs/secrets.enc.yaml#storage.postgres.password。
+// 同一文件只解密一次
+type SOPSProvider struct {
+	Run CommandRunner // 为空时执行 sops 命令
+
+	mu    sync.Mutex
+	files map[string]map[string]interface{}
+}
+
+// Secret 读取解密后文件中的值
+func (p *SOPSProvider) Secret(ctx context.Context, ref string) (string, error) {
+	file, path, ok := strings.Cut(ref, "#")
+	if !ok || file == "" || path == "" {
+		return "", fmt.Errorf("sops reference must be file#path, got %q", ref)
+	}
+
+	data, err := p.decrypt(ctx, file)
+	if err != nil {
+		return "", err
+	}
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		nested, ok := data[key].(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("key %s not found in %s", path, file)
+		}
+		data = nested
+	}
+	return secretValue(data, keys[len(keys)-1])
+}
+
+// decrypt 解密文件并缓存结果
+func (p *SOPSProvider) decrypt(ctx context.Context, file string) (map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if data, ok := p.files[file]; ok {
+		return data, nil
+	}
+
+	run := p.Run
+	if run == nil {
+		run = runCommand
+	}
+	out, err := run(ctx, "sops", "--decrypt", "--output-type", "json", file)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(out, &data); err != nil {
+		return nil, fmt.Errorf("invalid sops output for %s: %w", file, err)
+	}
+	if p.files == nil {
+		p.files = make(map[string]map[string]interface{})
+	}
+	p.files[file] = data
+	return data, nil
+}
+
+// secretValue 取出键对应的值，非字符串的标量格式化为文本
+func secretValue(data map[string]interface{}, key string) (string, error) {
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found", key)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("key %s is not a scalar value", key)
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// Load 读取配置文件并展开其中的环境变量与密钥引用，供服务器以外的工具使用与服务器相同的配置
+func Load(ctx context.Context, path string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	if err := NewResolver().ResolveViper(ctx, v); err != nil {
+		return nil, fmt.Errorf("failed to resolve config %s: %w", path, err)
+	}
+	return v, nil
+}
+
+// Storage 从配置的 storage 节点读取 storageType 后端的连接、超时与重试配置，Logger 与 Clock 由调用方设置
+func Storage(v *viper.Viper, storageType string) storage.Config {
+	config := storage.Config{
+		Type:       storageType,
+		IDStrategy: v.GetString("storage.id_strategy"),
+		NodeID:     v.GetInt64("storage.node_id"),
+		Options:    v.GetStringMap("storage." + storageType),
+		Timeouts: storage.TimeoutConfig{
+			Query:  v.GetDuration("storage.timeouts.query"),
+			Write:  v.GetDuration("storage.timeouts.write"),
+			Schema: v.GetDuration("storage.timeouts.schema"),
+		},
+		Postgres: storage.PostgresConfig{
+			Host:     v.GetString("storage.postgres.host"),
+			Port:     v.GetInt("storage.postgres.port"),
+			Database: v.GetString("storage.postgres.database"),
+			Username: v.GetString("storage.postgres.user"),
+			Password: v.GetString("storage.postgres.password"),
+			Schema:   v.GetString("storage.postgres.schema"),
+			Timescale: storage.TimescaleConfig{
+				Enabled:       v.GetBool("storage.postgres.timescale.enabled"),
+				ChunkInterval: v.GetDuration("storage.postgres.timescale.chunk_interval"),
+				CompressAfter: v.GetDuration("storage.postgres.timescale.compress_after"),
+			},
+			Replicas:             v.GetStringSlice("storage.postgres.replicas"),
+			ReplicaCheckInterval: v.GetDuration("storage.postgres.replica_check_interval"),
+			Retry:                retryConfig(v, "storage.postgres.retry"),
+		},
+		MySQL: storage.MySQLConfig{
+			Host:     v.GetString("storage.mysql.host"),
+			Port:     v.GetInt("storage.mysql.port"),
+			Database: v.GetString("storage.mysql.database"),
+			Username: v.GetString("storage.mysql.user"),
+			Password: v.GetString("storage.mysql.password"),
+
+			Replicas:             v.GetStringSlice("storage.mysql.replicas"),
+			ReplicaCheckInterval: v.GetDuration("storage.mysql.replica_check_interval"),
+			Retry:                retryConfig(v, "storage.mysql.retry"),
+		},
+		SQLite: storage.SQLiteConfig{
+			Path:                v.GetString("storage.sqlite.path"),
+			PerProject:          v.GetBool("storage.sqlite.per_project"),
+			Dir:                 v.GetString("storage.sqlite.dir"),
+			MaintenanceInterval: v.GetDuration("storage.sqlite.maintenance_interval"),
+			MaxSize:             v.GetInt64("storage.sqlite.max_size"),
+			Retry:               retryConfig(v, "storage.sqlite.retry"),
+		},
+		ClickHouse: storage.ClickHouseConfig{
+			Host:     v.GetString("storage.clickhouse.host"),
+			Port:     v.GetInt("storage.clickhouse.port"),
+			Database: v.GetString("storage.clickhouse.database"),
+			Username: v.GetString("storage.clickhouse.user"),
+			Password: v.GetString("storage.clickhouse.password"),
+
+			AsyncInsert:  v.GetBool("storage.clickhouse.async_insert"),
+			InsertQuorum: v.GetInt("storage.clickhouse.insert_quorum"),
+			Cluster:      v.GetString("storage.clickhouse.cluster"),
+			Retry:        retryConfig(v, "storage.clickhouse.retry"),
+		},
+		File: storage.FileConfig{
+			Dir:         v.GetString("storage.file.dir"),
+			SegmentSize: v.GetInt64("storage.file.segment_size"),
+			Sync:        v.GetBool("storage.file.sync"),
+			Retry:       retryConfig(v, "storage.file.retry"),
+		},
+	}
+	if v.IsSet("storage.clickhouse.wait_for_async_insert") {
+		wait := v.GetBool("storage.clickhouse.wait_for_async_insert")
+		config.ClickHouse.WaitForAsyncInsert = &wait
+	}
+	return config
+}
+
+// retryConfig 读取存储后端的重试与熔断配置
+func retryConfig(v *viper.Viper, prefix string) storage.RetryConfig {
+	return storage.RetryConfig{
+		Enabled:          v.GetBool(prefix + ".enabled"),
+		MaxAttempts:      v.GetInt(prefix + ".max_attempts"),
+		InitialBackoff:   v.GetDuration(prefix + ".initial_backoff"),
+		MaxBackoff:       v.GetDuration(prefix + ".max_backoff"),
+		FailureThreshold: v.GetInt(prefix + ".failure_threshold"),
+		OpenTimeout:      v.GetDuration(prefix + ".open_timeout"),
+	}
+}
@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// jsonlSource 每行一个 JSON 对象，空行被忽略
+type jsonlSource struct {
+	lines *lineReader
+}
+
+func (s *jsonlSource) next() (*Record, error) {
+	for {
+		text, line, err := s.lines.next()
+		if err != nil {
+			return nil, err
+		}
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &data); err != nil {
+			return nil, &LineError{Line: line, Err: err}
+		}
+		return &Record{Line: line, Data: data}, nil
+	}
+}
+
+// csvSource 首行为列名的 CSV，空值视为缺少该列
+type csvSource struct {
+	r      *csv.Reader
+	header []string
+}
+
+func newCSVSource(r io.Reader) *csvSource {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	return &csvSource{r: reader}
+}
+
+func (s *csvSource) next() (*Record, error) {
+	for {
+		row, err := s.r.Read()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &LineError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := s.r.FieldPos(0)
+
+		if s.header == nil {
+			s.header = make([]string, len(row))
+			for i, name := range row {
+				s.header[i] = strings.TrimSpace(name)
+			}
+			s.header[0] = strings.TrimPrefix(s.header[0], "\ufeff")
+			continue
+		}
+		data := make(map[string]interface{}, len(row))
+		for i, value := range row {
+			if value != "" {
+				data[s.header[i]] = value
+			}
+		}
+		return &Record{Line: line, Data: data}, nil
+	}
+}
+
+var (
+	// ansiPattern 彩色级别编码中的终端控制序列
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// callerPattern 调用位置，如 server/handler.go:87
+	callerPattern = regexp.MustCompile(`^\S+\.\w+:\d+$`)
+)
+
+// consoleSource zap console 编码的日志。一条日志的各部分以制表符分隔：时间、级别、可选的 logger 名与调用位置、
+// 消息与可选的 JSON 字段；之后不以时间开头的行作为该日志的 stacktrace
+type consoleSource struct {
+	lines   *lineReader
+	isTime  func(string) bool
+	pending *Record
+	err     error
+}
+
+func (s *consoleSource) next() (*Record, error) {
+	for s.err == nil {
+		text, line, err := s.lines.next()
+		if err != nil {
+			var lineErr *LineError
+			if errors.As(err, &lineErr) {
+				return nil, err
+			}
+			s.err = err
+			break
+		}
+
+		data, ok := s.parse(text)
+		if !ok {
+			switch {
+			case s.pending != nil:
+				stack, _ := s.pending.Data["stacktrace"].(string)
+				if stack != "" {
+					stack += "\n"
+				}
+				s.pending.Data["stacktrace"] = stack + text
+			case strings.TrimSpace(text) != "":
+				return nil, &LineError{Line: line, Err: fmt.Errorf("not a console log line")}
+			}
+			continue
+		}
+		record := s.pending
+		s.pending = &Record{Line: line, Data: data}
+		if record != nil {
+			return record, nil
+		}
+	}
+
+	if record := s.pending; record != nil {
+		s.pending = nil
+		return record, nil
+	}
+	return nil, s.err
+}
+
+// parse 解析日志的首行，不以时间与级别开头时返回 false
+func (s *consoleSource) parse(text string) (map[string]interface{}, bool) {
+	parts := strings.Split(ansiPattern.ReplaceAllString(text, ""), "\t")
+	if len(parts) < 3 || !s.isTime(parts[0]) {
+		return nil, false
+	}
+	data := map[string]interface{}{
+		"timestamp": parts[0],
+		"level":     strings.ToLower(strings.TrimSpace(parts[1])),
+	}
+	rest := parts[2:]
+
+	last := rest[len(rest)-1]
+	if len(rest) > 1 && strings.HasPrefix(last, "{") {
+		var fields map[string]interface{}
+		if json.Unmarshal([]byte(last), &fields) == nil {
+			for key, value := range fields {
+				data[key] = value
+			}
+			rest = rest[:len(rest)-1]
+		}
+	}
+
+	data["message"] = rest[len(rest)-1]
+	for _, part := range rest[:len(rest)-1] {
+		switch {
+		case callerPattern.MatchString(part):
+			data["caller"] = part
+		case data["logger"] == nil:
+			data["logger"] = part
+		default:
+			data["function"] = part
+		}
+	}
+	return data, true
+}
@@ -0,0 +1,263 @@
+// Package importer 读取已有的日志文件（JSONL、CSV 与 zap console 格式），按映射配置将各列转换为日志写入请求的字段，
+// 用于将历史日志迁移到日志服务
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// Format 日志文件格式
+type Format string
+
+const (
+	FormatJSONL   Format = "jsonl"   // 每行一个 JSON 对象，如 zap 的 JSON 输出
+	FormatCSV     Format = "csv"     // 首行为列名
+	FormatConsole Format = "console" // zap console 编码：时间、级别、logger、调用位置、消息与 JSON 字段以制表符分隔
+)
+
+// MaxLine 单行的最大长度，超长的行被跳过并报告
+const MaxLine = 1 << 20
+
+// ErrLineTooLong 单行超过 MaxLine
+var ErrLineTooLong = fmt.Errorf("line exceeds %d bytes", MaxLine)
+
+// ParseFormat 解析格式名，接受 jsonl、ndjson、json、csv、console 与 zap-console
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "jsonl", "ndjson", "json":
+		return FormatJSONL, nil
+	case "csv":
+		return FormatCSV, nil
+	case "console", "zap-console":
+		return FormatConsole, nil
+	}
+	return "", fmt.Errorf("unsupported import format: %q (use jsonl, csv or console)", name)
+}
+
+// DetectFormat 按文件扩展名推断格式：.csv 为 CSV，.log 与 .txt 为 console，其余为 JSONL
+func DetectFormat(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".log", ".txt":
+		return FormatConsole
+	}
+	return FormatJSONL
+}
+
+// Mapping 源文件的列到日志字段的映射
+type Mapping struct {
+	// Format 文件格式，命令行与请求参数未指定格式时使用
+	Format Format `yaml:"format,omitempty" json:"format,omitempty"`
+
+	// Fields 源列名到字段名的映射，如 ts: timestamp、msg: message；映射为 - 的列被丢弃，未列出的列保持原名
+	Fields map[string]string `yaml:"fields,omitempty" json:"fields,omitempty"`
+
+	// TimestampLayout timestamp 的格式：Go 时间布局，或 unix、unix_ms、unix_us、unix_ns 表示纪元时间。
+	// 为空时接受 RFC 3339、zap 的 ISO 8601 与常见的 2006-01-02 15:04:05 形式，数字按秒解析
+	TimestampLayout string `yaml:"timestamp_layout,omitempty" json:"timestamp_layout,omitempty"`
+
+	// TimeZone 不带时区的时间所在的时区，IANA 名称或 ±hh:mm 偏移，默认 UTC
+	TimeZone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// Defaults 记录中缺少的字段使用的值，如 level: info
+	Defaults map[string]interface{} `yaml:"defaults,omitempty" json:"defaults,omitempty"`
+}
+
+// ParseMapping 解析 YAML 或 JSON 格式的映射配置
+func ParseMapping(data []byte) (*Mapping, error) {
+	var mapping Mapping
+	if err := yaml.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("invalid import mapping: %w", err)
+	}
+	if mapping.Format != "" {
+		format, err := ParseFormat(string(mapping.Format))
+		if err != nil {
+			return nil, err
+		}
+		mapping.Format = format
+	}
+	if _, err := models.ParseTimeZone(mapping.TimeZone); err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// LoadMapping 读取映射配置文件
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMapping(data)
+}
+
+// Record 一条待写入的日志及其在文件中的起始行号（从 1 开始）
+type Record struct {
+	Line int
+	Data map[string]interface{}
+}
+
+// LineError 无法解析或转换的行，Reader 跳过该行后可以继续读取
+type LineError struct {
+	Line int
+	Err  error
+}
+
+// Error 返回行号与原因
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap 返回原因
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// source 按格式逐条解析记录，读完时返回 io.EOF
+type source interface {
+	next() (*Record, error)
+}
+
+// Reader 从日志文件中逐条读取记录并按映射转换
+type Reader struct {
+	src     source
+	mapping *Mapping
+	loc     *time.Location
+	skip    int
+}
+
+// NewReader 创建读取 r 的 Reader，format 为空时使用映射中的格式，仍为空时按 JSONL 读取；mapping 可以为 nil
+func NewReader(r io.Reader, format Format, mapping *Mapping) (*Reader, error) {
+	if mapping == nil {
+		mapping = &Mapping{}
+	}
+	if format == "" {
+		format = mapping.Format
+	}
+	loc, err := models.ParseTimeZone(mapping.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &Reader{mapping: mapping, loc: loc}
+	switch format {
+	case "", FormatJSONL:
+		reader.src = &jsonlSource{lines: newLineReader(r)}
+	case FormatCSV:
+		reader.src = newCSVSource(r)
+	case FormatConsole:
+		reader.src = &consoleSource{lines: newLineReader(r), isTime: func(s string) bool {
+			_, err := reader.parseTimestamp(s)
+			return err == nil
+		}}
+	default:
+		return nil, fmt.Errorf("unsupported import format: %q (use jsonl, csv or console)", format)
+	}
+	return reader, nil
+}
+
+// SkipThrough 跳过起始行号不大于 line 的记录，用于从上次中断处继续导入；被跳过的行不报告错误
+func (r *Reader) SkipThrough(line int) {
+	r.skip = line
+}
+
+// Next 返回下一条记录。无法解析的行返回 *LineError，调用方可以继续读取；读完时返回 io.EOF
+func (r *Reader) Next() (*Record, error) {
+	for {
+		record, err := r.src.next()
+		var lineErr *LineError
+		if errors.As(err, &lineErr) && lineErr.Line <= r.skip {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record.Line <= r.skip {
+			continue
+		}
+		if err := r.apply(record); err != nil {
+			return nil, &LineError{Line: record.Line, Err: err}
+		}
+		return record, nil
+	}
+}
+
+// apply 重命名列、填入默认值，并将 timestamp 转换为 RFC 3339 字符串
+func (r *Reader) apply(record *Record) error {
+	data := make(map[string]interface{}, len(record.Data)+len(r.mapping.Defaults))
+	for key, value := range record.Data {
+		target, ok := r.mapping.Fields[key]
+		switch {
+		case !ok:
+			data[key] = value
+		case target != "-":
+			data[target] = value
+		}
+	}
+	for key, value := range r.mapping.Defaults {
+		if _, ok := data[key]; !ok {
+			data[key] = value
+		}
+	}
+	if value, ok := data["timestamp"]; ok && value != nil {
+		t, err := r.parseTimestamp(value)
+		if err != nil {
+			return err
+		}
+		data["timestamp"] = t.Format(time.RFC3339Nano)
+	}
+	record.Data = data
+	return nil
+}
+
+// lineReader 逐行读取并计数，超长的行被丢弃到行尾并返回 ErrLineTooLong
+type lineReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// newLineReader 创建带 64KB 缓冲的 lineReader
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(r, 64<<10)}
+}
+
+// next 返回下一行（不含行尾）与行号，读完时返回 io.EOF
+func (l *lineReader) next() (string, int, error) {
+	var buf []byte
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		if len(buf)+len(chunk) > MaxLine {
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = l.r.ReadSlice('\n')
+			}
+			if err != nil && !errors.Is(err, io.EOF) {
+				return "", 0, err
+			}
+			l.line++
+			return "", l.line, &LineError{Line: l.line, Err: ErrLineTooLong}
+		}
+		buf = append(buf, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", 0, err
+		}
+		if len(buf) == 0 && errors.Is(err, io.EOF) {
+			return "", 0, io.EOF
+		}
+		l.line++
+		return strings.TrimRight(string(buf), "\r\n"), l.line, nil
+	}
+}
@@ -0,0 +1,180 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readAll 读取全部记录，返回记录与被跳过的行错误
+func readAll(t *testing.T, reader *Reader) ([]*Record, []*LineError) {
+	t.Helper()
+	var records []*Record
+	var lineErrs []*LineError
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return records, lineErrs
+		}
+		var lineErr *LineError
+		if errors.As(err, &lineErr) {
+			lineErrs = append(lineErrs, lineErr)
+			continue
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	mapping, err := ParseMapping([]byte(`
+fields:
+  ts: timestamp
+  msg: message
+  caller: "-"
+defaults:
+  service: api
+`))
+	require.NoError(t, err)
+	input := `{"level":"info","ts":1704164645.5,"msg":"started","caller":"main.go:10"}
+
+not json
+{"level":"error","ts":"2024-01-02T03:04:05.000+0800","msg":"failed","service":"worker"}
+{"level":"info","ts":"yesterday","msg":"bad time"}
+` + `{"pad":"` + strings.Repeat("x", MaxLine) + `"}
+{"level":"info","msg":"no time"}`
+
+	reader, err := NewReader(strings.NewReader(input), "", mapping)
+	require.NoError(t, err)
+	records, lineErrs := readAll(t, reader)
+
+	require.Len(t, records, 3)
+	assert.Equal(t, 1, records[0].Line)
+	assert.Equal(t, map[string]interface{}{
+		"level": "info", "timestamp": "2024-01-02T03:04:05.5Z", "message": "started", "service": "api",
+	}, records[0].Data)
+	assert.Equal(t, 4, records[1].Line)
+	assert.Equal(t, "2024-01-02T03:04:05+08:00", records[1].Data["timestamp"])
+	assert.Equal(t, "worker", records[1].Data["service"], "values in the file win over defaults")
+	assert.Equal(t, 7, records[2].Line)
+	assert.NotContains(t, records[2].Data, "timestamp")
+
+	require.Len(t, lineErrs, 3)
+	assert.Equal(t, []int{3, 5, 6}, []int{lineErrs[0].Line, lineErrs[1].Line, lineErrs[2].Line})
+	assert.ErrorContains(t, lineErrs[1], "unrecognized timestamp")
+	assert.ErrorIs(t, lineErrs[2], ErrLineTooLong)
+
+	// 从中断处继续时跳过已处理的行，其中的错误也不再报告
+	reader, err = NewReader(strings.NewReader(input), FormatJSONL, mapping)
+	require.NoError(t, err)
+	reader.SkipThrough(5)
+	records, lineErrs = readAll(t, reader)
+	require.Len(t, records, 1)
+	assert.Equal(t, 7, records[0].Line)
+	require.Len(t, lineErrs, 1)
+	assert.Equal(t, 6, lineErrs[0].Line)
+}
+
+func TestCSV(t *testing.T) {
+	mapping := &Mapping{
+		Fields:          map[string]string{"time": "timestamp", "lvl": "level", "text": "message"},
+		TimestampLayout: "2006-01-02 15:04:05",
+		TimeZone:        "+08:00",
+	}
+	input := "\ufefftime,lvl,text,status\n" +
+		"2024-01-02 03:04:05,info,\"multi\nline\",200\n" +
+		"2024-01-02 03:04:06,warn,no status,\n" +
+		"2024-01-02 03:04:07,warn\n" +
+		"02/01/2024,info,bad time,200\n"
+	reader, err := NewReader(strings.NewReader(input), FormatCSV, mapping)
+	require.NoError(t, err)
+	records, lineErrs := readAll(t, reader)
+
+	require.Len(t, records, 2)
+	assert.Equal(t, 2, records[0].Line)
+	assert.Equal(t, map[string]interface{}{
+		"timestamp": "2024-01-02T03:04:05+08:00", "level": "info", "message": "multi\nline", "status": "200",
+	}, records[0].Data)
+	assert.Equal(t, 4, records[1].Line)
+	assert.NotContains(t, records[1].Data, "status", "empty cells are treated as missing")
+
+	require.Len(t, lineErrs, 2)
+	assert.Equal(t, 5, lineErrs[0].Line)
+	assert.Equal(t, 6, lineErrs[1].Line)
+	assert.ErrorContains(t, lineErrs[1], `does not match layout`)
+}
+
+func TestConsole(t *testing.T) {
+	input := "2024-01-02T03:04:05.000Z\tINFO\tserver/main.go:42\tlistening\t{\"port\":8080}\n" +
+		"2024-01-02T03:04:06.000+0800\t\x1b[31mERROR\x1b[0m\thttp\tapi/handler.go:87\trequest failed\n" +
+		"main.(*Handler).Serve\n" +
+		"\t/app/handler.go:87\n" +
+		"garbage before nothing\n" +
+		"2024-01-02T03:04:07.000Z\tWARN\tslow {query}\n"
+
+	reader, err := NewReader(strings.NewReader(input), FormatConsole, nil)
+	require.NoError(t, err)
+	records, lineErrs := readAll(t, reader)
+
+	require.Len(t, records, 3)
+	assert.Equal(t, map[string]interface{}{
+		"timestamp": "2024-01-02T03:04:05Z", "level": "info", "caller": "server/main.go:42",
+		"message": "listening", "port": float64(8080),
+	}, records[0].Data)
+	assert.Equal(t, 2, records[1].Line)
+	assert.Equal(t, map[string]interface{}{
+		"timestamp": "2024-01-02T03:04:06+08:00", "level": "error", "logger": "http", "caller": "api/handler.go:87",
+		"message": "request failed", "stacktrace": "main.(*Handler).Serve\n\t/app/handler.go:87\ngarbage before nothing",
+	}, records[1].Data)
+	assert.Equal(t, 6, records[2].Line)
+	assert.Equal(t, "slow {query}", records[2].Data["message"])
+	assert.Empty(t, lineErrs)
+
+	_, lineErrs = readAll(t, mustReader(t, "orphan line\n2024-01-02T03:04:05Z\tINFO\tok\n", FormatConsole))
+	require.Len(t, lineErrs, 1)
+	assert.Equal(t, 1, lineErrs[0].Line)
+}
+
+func TestFormats(t *testing.T) {
+	for name, want := range map[string]Format{"ndjson": FormatJSONL, "CSV": FormatCSV, "zap-console": FormatConsole} {
+		got, err := ParseFormat(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseFormat("xml")
+	assert.Error(t, err)
+
+	assert.Equal(t, FormatCSV, DetectFormat("export.CSV"))
+	assert.Equal(t, FormatConsole, DetectFormat("/var/log/app.log"))
+	assert.Equal(t, FormatJSONL, DetectFormat("app.ndjson"))
+
+	_, err = ParseMapping([]byte("format: xml"))
+	assert.Error(t, err)
+	_, err = ParseMapping([]byte("timezone: Mars/Olympus"))
+	assert.Error(t, err)
+	mapping, err := ParseMapping([]byte(`{"format":"ndjson","timestamp_layout":"unix_ms"}`))
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSONL, mapping.Format)
+
+	records, _ := readAll(t, mustReaderWith(t, `{"timestamp":1704164645123}`, mapping))
+	require.Len(t, records, 1)
+	assert.Equal(t, "2024-01-02T03:04:05.123Z", records[0].Data["timestamp"])
+}
+
+func mustReader(t *testing.T, input string, format Format) *Reader {
+	t.Helper()
+	reader, err := NewReader(strings.NewReader(input), format, nil)
+	require.NoError(t, err)
+	return reader
+}
+
+func mustReaderWith(t *testing.T, input string, mapping *Mapping) *Reader {
+	t.Helper()
+	reader, err := NewReader(strings.NewReader(input), "", mapping)
+	require.NoError(t, err)
+	return reader
+}
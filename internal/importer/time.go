@@ -0,0 +1,82 @@
+package importer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultLayouts 未指定 timestamp_layout 时依次尝试的时间格式
+var defaultLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.000Z0700", // zap ISO8601TimeEncoder
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006/01/02 15:04:05.999999999", // 标准库 log 包
+}
+
+// epochUnits 纪元时间格式对应的单位
+var epochUnits = map[string]time.Duration{
+	"unix":    time.Second,
+	"unix_ms": time.Millisecond,
+	"unix_us": time.Microsecond,
+	"unix_ns": time.Nanosecond,
+}
+
+// parseTimestamp 按映射的 timestamp_layout 解析时间，值可以是字符串或数字
+func (r *Reader) parseTimestamp(value interface{}) (time.Time, error) {
+	layout := r.mapping.TimestampLayout
+	unit, epoch := epochUnits[layout]
+
+	var text string
+	switch v := value.(type) {
+	case float64:
+		if layout != "" && !epoch {
+			return time.Time{}, fmt.Errorf("timestamp %v does not match layout %q", v, layout)
+		}
+		if !epoch {
+			unit = time.Second
+		}
+		return epochTime(v, unit), nil
+	case string:
+		text = strings.TrimSpace(v)
+	default:
+		return time.Time{}, fmt.Errorf("invalid timestamp: %v", value)
+	}
+
+	if epoch || layout == "" {
+		if n, err := strconv.ParseFloat(text, 64); err == nil {
+			if !epoch {
+				unit = time.Second
+			}
+			return epochTime(n, unit), nil
+		}
+		if epoch {
+			return time.Time{}, fmt.Errorf("invalid %s timestamp: %q", layout, text)
+		}
+	}
+
+	layouts := defaultLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+	for _, l := range layouts {
+		if t, err := time.ParseInLocation(l, text, r.loc); err == nil {
+			return t, nil
+		}
+	}
+	if layout != "" {
+		return time.Time{}, fmt.Errorf("timestamp %q does not match layout %q", text, layout)
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp: %q", text)
+}
+
+// epochTime 将以 unit 为单位的纪元时间转换为 UTC 时间，保留小数部分
+func epochTime(n float64, unit time.Duration) time.Time {
+	whole, frac := math.Modf(n)
+	return time.Unix(0, 0).Add(time.Duration(whole) * unit).Add(time.Duration(frac * float64(unit))).UTC()
+}
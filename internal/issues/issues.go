@@ -0,0 +1,223 @@
+// Package issues 按消息模板与堆栈为错误级别的日志生成指纹，将同一类错误归并为问题并累计出现次数
+package issues
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/logging"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+var (
+	// DefaultLevels 默认归并的日志级别
+	DefaultLevels = []string{"error", "fatal", "panic", "critical"}
+	// DefaultStackFields 默认读取堆栈的字段，取第一个非空的字段
+	DefaultStackFields = []string{"stack", "stacktrace", "stack_trace"}
+)
+
+// FingerprintField 日志中该字段非空时直接作为分组依据，不再使用消息模板与堆栈
+const FingerprintField = "fingerprint"
+
+// ErrorField 日志中该字段的模板附加在标题之后并参与指纹计算，如 zap 的 error 字段
+const ErrorField = "error"
+
+// maxStackFrames 参与指纹计算的堆栈行数
+const maxStackFrames = 20
+
+// 消息中的变量部分
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	quotedPattern = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+	ipPattern     = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`)
+	hexPattern    = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-fA-F]{8,}\b`)
+	numberPattern = regexp.MustCompile(`\b\d+(\.\d+)?`)
+)
+
+// frameRules 堆栈中随构建与运行变化的部分：地址、偏移与行号
+var frameRules = []struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`\+0x[0-9a-fA-F]+`), ""},
+	{regexp.MustCompile(`0x[0-9a-fA-F]+`), "<hex>"},
+	{regexp.MustCompile(`:\d+(:\d+)?`), ""},
+	{regexp.MustCompile(`\bline \d+`), "line"},
+}
+
+// Template 将消息中的 UUID、引号内的字符串、IP、十六进制数与数字替换为占位符，
+// 使只有变量不同的消息得到相同的模板
+func Template(message string) string {
+	message = uuidPattern.ReplaceAllString(message, "<uuid>")
+	message = quotedPattern.ReplaceAllString(message, "<str>")
+	message = ipPattern.ReplaceAllString(message, "<ip>")
+	message = hexPattern.ReplaceAllStringFunc(message, func(s string) string {
+		// 纯字母的单词与纯数字不视为十六进制标识
+		if strings.HasPrefix(s, "0x") || (strings.ContainsAny(s, "0123456789") && strings.ContainsAny(s, "abcdefABCDEF")) {
+			return "<hex>"
+		}
+		return s
+	})
+	message = numberPattern.ReplaceAllString(message, "<num>")
+	return strings.Join(strings.Fields(message), " ")
+}
+
+// Frames 返回去除地址、偏移与行号后的堆栈行，跳过空行与 goroutine、Traceback 等标题行
+func Frames(stack string) []string {
+	var frames []string
+	for _, line := range strings.Split(stack, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "goroutine ") || strings.HasPrefix(line, "Traceback ") {
+			continue
+		}
+		for _, rule := range frameRules {
+			line = rule.pattern.ReplaceAllString(line, rule.placeholder)
+		}
+		frames = append(frames, strings.TrimSpace(line))
+		if len(frames) == maxStackFrames {
+			break
+		}
+	}
+	return frames
+}
+
+// Fingerprint 根据标题与堆栈计算指纹
+func Fingerprint(title string, frames []string) string {
+	sum := sha1.Sum([]byte(title + "\n" + strings.Join(frames, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Config 处理器配置
+type Config struct {
+	Levels      []string    // 归并的日志级别，大小写不敏感，默认 DefaultLevels
+	StackFields []string    // 读取堆栈的字段，默认 DefaultStackFields
+	Logger      *zap.Logger // 为空时使用全局 logger
+}
+
+// Processor 将写入的错误日志归并为问题并保存到存储
+type Processor struct {
+	store       storage.IssueStore
+	levels      map[string]bool
+	stackFields []string
+	logger      *zap.Logger
+}
+
+// NewProcessor 创建处理器，存储需支持 storage.IssueStore
+func NewProcessor(store storage.Storage, config Config) (*Processor, error) {
+	issues, ok := storage.As[storage.IssueStore](store)
+	if !ok {
+		return nil, fmt.Errorf("storage does not support issues")
+	}
+	if len(config.Levels) == 0 {
+		config.Levels = DefaultLevels
+	}
+	if len(config.StackFields) == 0 {
+		config.StackFields = DefaultStackFields
+	}
+
+	levels := make(map[string]bool, len(config.Levels))
+	for _, level := range config.Levels {
+		levels[strings.ToLower(level)] = true
+	}
+	return &Processor{
+		store:       issues,
+		levels:      levels,
+		stackFields: config.StackFields,
+		logger:      logging.Component(config.Logger, "issues"),
+	}, nil
+}
+
+// Observe 将成功写入的一批日志中错误级别的条目按指纹合并后记录，p 为 nil 时不做任何事。
+// 记录失败只写日志，不影响已写入的日志
+func (p *Processor) Observe(ctx context.Context, project, table string, logs []*models.LogEntry) {
+	if p == nil {
+		return
+	}
+
+	var issues []*models.Issue
+	byFingerprint := make(map[string]*models.Issue)
+	now := time.Now()
+	for _, log := range logs {
+		if !p.levels[strings.ToLower(log.Level)] {
+			continue
+		}
+		issue := p.Group(log)
+		seen := log.Timestamp
+		if seen.IsZero() {
+			seen = now
+		}
+
+		existing, ok := byFingerprint[issue.Fingerprint]
+		if !ok {
+			issue.Project, issue.Table = project, table
+			issue.Count = 1
+			issue.FirstSeen, issue.LastSeen = seen, seen
+			byFingerprint[issue.Fingerprint] = issue
+			issues = append(issues, issue)
+			continue
+		}
+		existing.Count++
+		if seen.Before(existing.FirstSeen) {
+			existing.FirstSeen = seen
+		}
+		if !seen.Before(existing.LastSeen) {
+			existing.LastSeen = seen
+			existing.Message = issue.Message
+		}
+	}
+	if len(issues) == 0 {
+		return
+	}
+
+	if err := p.store.RecordIssues(ctx, issues); err != nil {
+		p.logger.Error("failed to record issues", zap.String("project", project), zap.String("table", table), zap.Error(err))
+	}
+}
+
+// Group 计算日志所属问题的指纹、标题与首个调用帧
+func (p *Processor) Group(log *models.LogEntry) *models.Issue {
+	title := Template(log.Message)
+	if msg, ok := log.Fields[ErrorField].(string); ok && msg != "" {
+		title += ": " + Template(msg)
+	}
+	issue := &models.Issue{Title: title, Level: log.Level, Message: log.Message}
+
+	var frames []string
+	for _, field := range p.stackFields {
+		if frames = Frames(stackText(log.Fields[field])); len(frames) > 0 {
+			issue.Culprit = frames[0]
+			break
+		}
+	}
+
+	if custom, ok := log.Fields[FingerprintField].(string); ok && custom != "" {
+		issue.Fingerprint = Fingerprint(FingerprintField+":"+custom, nil)
+	} else {
+		issue.Fingerprint = Fingerprint(title, frames)
+	}
+	return issue
+}
+
+// stackText 返回堆栈字段的文本，数组形式的堆栈每帧一行
+func stackText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, "\n")
+	case []interface{}:
+		lines := make([]string, len(v))
+		for i, frame := range v {
+			lines[i] = fmt.Sprint(frame)
+		}
+		return strings.Join(lines, "\n")
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package issues
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func TestTemplate(t *testing.T) {
+	for message, want := range map[string]string{
+		"timeout after 30ms":                                  "timeout after <num>ms",
+		"user 'alice' not found":                              "user <str> not found",
+		`dial tcp 10.0.0.1:5432: connection refused`:          "dial tcp <ip>: connection refused",
+		"request 0b8f4a6e-1c2d-4e5f-8a9b-0c1d2e3f4a5b failed": "request <uuid> failed",
+		"bad pointer 0xc000123456 in object 5f3a9c2e1b7d":     "bad pointer <hex> in object <hex>",
+		"deadline exceeded on user123  retry 2.5s":            "deadline exceeded on user123 retry <num>s",
+		"feedbacked 12345678 bytes":                           "feedbacked <num> bytes",
+	} {
+		assert.Equal(t, want, Template(message), message)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	stack := `goroutine 42 [running]:
+main.(*Handler).Serve(0xc000010000, {0x1, 0x2})
+	/app/handler.go:87 +0x1d
+main.main()
+	/app/main.go:12 +0x25
+`
+	assert.Equal(t, []string{
+		"main.(*Handler).Serve(<hex>, {<hex>, <hex>})",
+		"/app/handler.go",
+		"main.main()",
+		"/app/main.go",
+	}, Frames(stack))
+	assert.Equal(t, []string{`File "app.py", line, in handle`}, Frames("Traceback (most recent call last):\n  File \"app.py\", line 10, in handle"))
+}
+
+func TestProcessor(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	p, err := NewProcessor(store, Config{})
+	require.NoError(t, err)
+
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := func(level, message string, at time.Duration, fields map[string]interface{}) *models.LogEntry {
+		return &models.LogEntry{Level: level, Message: message, Timestamp: base.Add(at), Fields: fields}
+	}
+	goStack := func(line int) string {
+		return fmt.Sprintf("main.save()\n\t/app/store.go:%d +0x1d", line)
+	}
+	p.Observe(ctx, "app", "events", []*models.LogEntry{
+		entry("ERROR", "save order 1 failed", 0, map[string]interface{}{"stack": goStack(1), "error": "timeout after 30ms"}),
+		entry("error", "save order 2 failed", 2*time.Second, map[string]interface{}{"stack": goStack(2), "error": "timeout after 45ms"}),
+		entry("error", "save order 3 failed", time.Second, map[string]interface{}{"stack": "main.load()\n\t/app/load.go:3"}),
+		entry("info", "save order 4 failed", 0, nil),
+		entry("fatal", "shutdown", 0, map[string]interface{}{"fingerprint": "shutdown"}),
+		entry("fatal", "shutdown by signal 15", 0, map[string]interface{}{"fingerprint": "shutdown"}),
+	})
+
+	issues, err := store.ListIssues(ctx, &models.IssueFilter{Project: "app"})
+	require.NoError(t, err)
+	require.Len(t, issues, 3)
+	first := issues[0]
+	assert.Equal(t, "save order <num> failed: timeout after <num>ms", first.Title)
+	assert.Equal(t, "main.save()", first.Culprit)
+	assert.EqualValues(t, 2, first.Count)
+	assert.Equal(t, "save order 2 failed", first.Message)
+	assert.True(t, first.FirstSeen.Equal(base))
+	assert.True(t, first.LastSeen.Equal(base.Add(2*time.Second)))
+	assert.Equal(t, models.IssueUnresolved, first.Status)
+	assert.Equal(t, "main.load()", issues[1].Culprit)
+	assert.EqualValues(t, 2, issues[2].Count, "custom fingerprint groups different messages")
+
+	// 再次出现累计到同一问题
+	p.Observe(ctx, "app", "events", []*models.LogEntry{
+		entry("error", "save order 9 failed", time.Minute, map[string]interface{}{"stack": goStack(7), "error": "timeout after 1ms"}),
+	})
+	got, err := store.GetIssue(ctx, "app", "events", first.Fingerprint)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, got.Count)
+
+	var nilProcessor *Processor
+	nilProcessor.Observe(ctx, "app", "events", []*models.LogEntry{entry("error", "x", 0, nil)})
+
+	_, err = NewProcessor(storage.NewFileStorage(storage.Config{}), Config{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,287 @@
+// Package jobs 按固定间隔运行后台维护任务，如清理过期归档与汇总过期原始日志。
+// 多实例部署时每次执行前询问 Leading，只有 leader 执行，避免同一维护任务在多个实例上重复运行；
+// 每个任务的执行次数、耗时与最近结果以 JSON 或 Prometheus 文本格式输出
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/logging"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+// ErrJobNotFound 任务不存在
+var ErrJobNotFound = errors.New("job not found")
+
+// Job 一个周期性任务
+type Job struct {
+	// Name 任务名，在调度器中唯一，作为指标的 job 标签
+	Name string
+
+	// Interval 执行间隔，调度器启动时立即执行一次，之后每隔 Interval 执行
+	Interval time.Duration
+
+	// Timeout 单次执行的超时时间，默认与 Interval 相同
+	Timeout time.Duration
+
+	// Run 执行任务，返回的错误计为一次失败并记录日志
+	Run func(ctx context.Context) error
+}
+
+// Config 调度器配置
+type Config struct {
+	// Leading 可选，到达执行时间时返回 false 的实例跳过本次执行，为空时总是执行
+	Leading func(ctx context.Context) bool
+
+	// Clock 可选，为空时使用系统时间
+	Clock clock.Clock
+
+	// Logger 可选，为空时使用全局 logger
+	Logger *zap.Logger
+}
+
+// Status 任务的执行状态
+type Status struct {
+	Name         string     `json:"name"`
+	Interval     string     `json:"interval"`
+	Running      bool       `json:"running"`
+	Succeeded    int64      `json:"succeeded"`
+	Failed       int64      `json:"failed"`
+	Skipped      int64      `json:"skipped"` // 本实例不是 leader 而跳过的次数
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastSuccess  *time.Time `json:"last_success,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+
+	// totalSeconds 全部执行的累计耗时，用于 Prometheus 的 _sum
+	totalSeconds float64
+}
+
+// entry 已注册的任务及其状态
+type entry struct {
+	job    Job
+	status Status
+}
+
+// Scheduler 为每个任务启动一个 goroutine 按间隔执行，同一任务的两次执行不会重叠
+type Scheduler struct {
+	config Config
+	clock  clock.Clock
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	jobs    map[string]*entry
+	leader  bool
+	started bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New 创建调度器
+func New(cfg Config) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		config: cfg,
+		clock:  clock.OrReal(cfg.Clock),
+		logger: logging.Component(cfg.Logger, "jobs"),
+		jobs:   make(map[string]*entry),
+		leader: cfg.Leading == nil,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Add 注册任务，需在 Start 之前调用
+func (s *Scheduler) Add(job Job) error {
+	switch {
+	case job.Name == "":
+		return fmt.Errorf("job name is required")
+	case job.Interval <= 0:
+		return fmt.Errorf("job %s: interval must be positive", job.Name)
+	case job.Run == nil:
+		return fmt.Errorf("job %s: run function is required", job.Name)
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = job.Interval
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return fmt.Errorf("job %s: scheduler already started", job.Name)
+	}
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("job %s already exists", job.Name)
+	}
+	s.jobs[job.Name] = &entry{job: job, status: Status{Name: job.Name, Interval: job.Interval.String()}}
+	return nil
+}
+
+// Start 开始按间隔执行全部任务，重复调用无效
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.ctx.Err() != nil {
+		return
+	}
+	s.started = true
+	for _, e := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(e)
+	}
+}
+
+// Stop 取消正在执行的任务并等待其返回，之后不再执行任务
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// loop 立即执行一次任务，之后每隔 Interval 执行，直到 Stop
+func (s *Scheduler) loop(e *entry) {
+	defer s.wg.Done()
+	ticker := s.clock.NewTicker(e.job.Interval)
+	defer ticker.Stop()
+	for {
+		if s.isLeading(e) {
+			s.run(s.ctx, e)
+		}
+		select {
+		case <-ticker.C():
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// isLeading 询问本实例是否为 leader，不是时计入任务的跳过次数
+func (s *Scheduler) isLeading(e *entry) bool {
+	leading := s.config.Leading == nil || s.config.Leading(s.ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leader = leading
+	if !leading {
+		e.status.Skipped++
+	}
+	return leading
+}
+
+// Run 立即执行一次 name 任务，不询问 Leading，返回任务的错误；
+// 与按间隔的执行同时发生时两者可能重叠，任务需能容忍并发执行
+func (s *Scheduler) Run(ctx context.Context, name string) (*Status, error) {
+	s.mu.Lock()
+	e, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	err := s.run(ctx, e)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := e.status
+	return &status, err
+}
+
+// run 执行任务并记录结果
+func (s *Scheduler) run(ctx context.Context, e *entry) error {
+	ctx, cancel := context.WithTimeout(ctx, e.job.Timeout)
+	defer cancel()
+
+	start := s.clock.Now()
+	s.mu.Lock()
+	e.status.Running = true
+	s.mu.Unlock()
+
+	err := e.job.Run(ctx)
+
+	end := s.clock.Now()
+	elapsed := end.Sub(start)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.status.Running = false
+	e.status.LastRun = &start
+	e.status.LastDuration = elapsed.String()
+	e.status.totalSeconds += elapsed.Seconds()
+	if err != nil {
+		e.status.Failed++
+		e.status.LastError = err.Error()
+		s.logger.Error("background job failed", zap.String("job", e.job.Name), zap.Duration("elapsed", elapsed), zap.Error(err))
+		return err
+	}
+	e.status.Succeeded++
+	e.status.LastSuccess = &end
+	e.status.LastError = ""
+	return nil
+}
+
+// Status 返回全部任务的状态，按任务名排序
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		statuses = append(statuses, e.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// WriteTo 以 Prometheus 文本格式（0.0.4）输出各任务的执行次数、耗时、最近成功时间与本实例是否为 leader
+func (s *Scheduler) WriteTo(w io.Writer) (int64, error) {
+	statuses := s.Status()
+	s.mu.Lock()
+	leader := s.leader
+	s.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("# HELP logs_job_runs_total Background job runs by result; skipped runs happened on instances that were not the leader.\n")
+	b.WriteString("# TYPE logs_job_runs_total counter\n")
+	for _, st := range statuses {
+		fmt.Fprintf(&b, "logs_job_runs_total{job=%q,result=\"success\"} %d\n", st.Name, st.Succeeded)
+		fmt.Fprintf(&b, "logs_job_runs_total{job=%q,result=\"failure\"} %d\n", st.Name, st.Failed)
+		fmt.Fprintf(&b, "logs_job_runs_total{job=%q,result=\"skipped\"} %d\n", st.Name, st.Skipped)
+	}
+	b.WriteString("# HELP logs_job_duration_seconds Time spent running background jobs.\n")
+	b.WriteString("# TYPE logs_job_duration_seconds summary\n")
+	for _, st := range statuses {
+		fmt.Fprintf(&b, "logs_job_duration_seconds_sum{job=%q} %g\n", st.Name, st.totalSeconds)
+		fmt.Fprintf(&b, "logs_job_duration_seconds_count{job=%q} %d\n", st.Name, st.Succeeded+st.Failed)
+	}
+	b.WriteString("# HELP logs_job_last_success_timestamp_seconds Unix time of the last successful run of each background job.\n")
+	b.WriteString("# TYPE logs_job_last_success_timestamp_seconds gauge\n")
+	for _, st := range statuses {
+		var last float64
+		if st.LastSuccess != nil {
+			last = float64(st.LastSuccess.UnixNano()) / 1e9
+		}
+		fmt.Fprintf(&b, "logs_job_last_success_timestamp_seconds{job=%q} %g\n", st.Name, last)
+	}
+	b.WriteString("# HELP logs_job_running Whether each background job is running on this instance.\n")
+	b.WriteString("# TYPE logs_job_running gauge\n")
+	for _, st := range statuses {
+		fmt.Fprintf(&b, "logs_job_running{job=%q} %d\n", st.Name, boolValue(st.Running))
+	}
+	b.WriteString("# HELP logs_job_leader Whether this instance was the leader when background jobs last checked.\n")
+	b.WriteString("# TYPE logs_job_leader gauge\n")
+	fmt.Fprintf(&b, "logs_job_leader %d\n", boolValue(leader))
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// boolValue 将布尔值转换为 0 或 1
+func boolValue(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+func TestScheduler(t *testing.T) {
+	mock := clock.NewMock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	var leading atomic.Bool
+	leading.Store(true)
+	scheduler := New(Config{Leading: func(context.Context) bool { return leading.Load() }, Clock: mock})
+
+	var purges atomic.Int64
+	require.NoError(t, scheduler.Add(Job{Name: "purge", Interval: time.Hour, Run: func(ctx context.Context) error {
+		purges.Add(1)
+		return nil
+	}}))
+	require.NoError(t, scheduler.Add(Job{Name: "rollup", Interval: time.Minute, Run: func(ctx context.Context) error {
+		return errors.New("backend unavailable")
+	}}))
+	assert.ErrorContains(t, scheduler.Add(Job{Name: "purge", Interval: time.Hour, Run: func(context.Context) error { return nil }}), "already exists")
+	assert.Error(t, scheduler.Add(Job{Name: "zero", Run: func(context.Context) error { return nil }}))
+	assert.Error(t, scheduler.Add(Job{Name: "empty", Interval: time.Hour}))
+
+	// 启动时立即执行一次
+	scheduler.Start()
+	defer scheduler.Stop()
+	mock.BlockUntil(2)
+	require.Eventually(t, func() bool {
+		statuses := scheduler.Status()
+		return statuses[0].Succeeded == 1 && statuses[1].Failed == 1
+	}, time.Second, time.Millisecond)
+	assert.ErrorContains(t, scheduler.Add(Job{Name: "late", Interval: time.Hour, Run: func(context.Context) error { return nil }}), "already started")
+
+	statuses := scheduler.Status()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "purge", statuses[0].Name)
+	assert.Equal(t, "1h0m0s", statuses[0].Interval)
+	assert.NotNil(t, statuses[0].LastSuccess)
+	assert.Equal(t, "backend unavailable", statuses[1].LastError)
+	assert.Nil(t, statuses[1].LastSuccess)
+
+	// 不是 leader 时跳过执行
+	leading.Store(false)
+	mock.Add(time.Minute)
+	require.Eventually(t, func() bool { return scheduler.Status()[1].Skipped == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, int64(1), purges.Load())
+
+	// 手动执行不询问 leader
+	status, err := scheduler.Run(context.Background(), "purge")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), status.Succeeded)
+	_, err = scheduler.Run(context.Background(), "rollup")
+	assert.ErrorContains(t, err, "backend unavailable")
+	_, err = scheduler.Run(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	var b strings.Builder
+	_, err = scheduler.WriteTo(&b)
+	require.NoError(t, err)
+	metrics := b.String()
+	assert.Contains(t, metrics, `logs_job_runs_total{job="purge",result="success"} 2`)
+	assert.Contains(t, metrics, `logs_job_runs_total{job="rollup",result="failure"} 2`)
+	assert.Contains(t, metrics, `logs_job_runs_total{job="rollup",result="skipped"} 1`)
+	assert.Contains(t, metrics, `logs_job_duration_seconds_count{job="rollup"} 2`)
+	assert.Contains(t, metrics, `logs_job_last_success_timestamp_seconds{job="rollup"} 0`)
+	assert.Contains(t, metrics, `logs_job_last_success_timestamp_seconds{job="purge"} 1.704164705e+09`)
+	assert.Contains(t, metrics, "logs_job_leader 0")
+}
+
+func TestSchedulerStop(t *testing.T) {
+	scheduler := New(Config{})
+	started := make(chan struct{})
+	require.NoError(t, scheduler.Add(Job{Name: "slow", Interval: time.Hour, Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}))
+	scheduler.Start()
+	<-started
+
+	// Stop 取消正在执行的任务并等待其返回
+	scheduler.Stop()
+	status := scheduler.Status()[0]
+	assert.False(t, status.Running)
+	assert.Equal(t, int64(1), status.Failed)
+
+	// 停止后不再启动
+	scheduler.Start()
+	assert.Equal(t, int64(1), scheduler.Status()[0].Failed)
+}
@@ -0,0 +1,338 @@
+// Package loadgen 按 schema 生成随机但贴近真实的日志，并以指定速率写入 API 或存储后端，
+// 统计吞吐与延迟，用于压测与部署容量评估
+package loadgen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/netip"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// omitRate 非必填字段被省略的比例
+const omitRate = 0.1
+
+// levels 日志级别及其权重，多数日志为 info
+var levels = []struct {
+	name   string
+	weight int
+}{
+	{"debug", 15}, {"info", 70}, {"warn", 10}, {"error", 5},
+}
+
+// messages 各级别的日志消息模板
+var messages = map[string][]string{
+	"debug": {"cache lookup", "query plan selected", "retrying connection", "payload decoded"},
+	"info":  {"request completed", "user signed in", "job finished", "order created", "session refreshed"},
+	"warn":  {"slow request", "retry scheduled", "rate limit approaching", "deprecated API called"},
+	"error": {"request failed", "database timeout", "upstream unavailable", "payment declined"},
+}
+
+// httpStatuses 名为 status 的整数字段的取值及其权重
+var httpStatuses = []struct {
+	code   int
+	weight int
+}{
+	{200, 80}, {201, 5}, {204, 3}, {301, 1}, {304, 2}, {400, 3}, {401, 1}, {404, 3}, {429, 1}, {500, 1},
+}
+
+var (
+	httpMethods = []string{"GET", "GET", "GET", "POST", "POST", "PUT", "DELETE", "PATCH"}
+	pathRoots   = []string{"users", "orders", "products", "sessions", "payments", "search"}
+	hostNames   = []string{"api", "web", "worker", "auth", "billing"}
+	words       = []string{"alpha", "bravo", "cedar", "delta", "ember", "falcon", "granite", "harbor", "iris", "juniper"}
+)
+
+// Options 生成选项
+type Options struct {
+	// Seed 随机数种子，相同的种子与 schema 生成相同的字段值（时间除外）
+	Seed int64
+
+	// Values 顶层字段的候选值，指定后该字段只从中取值，用于模拟枚举；值需符合字段类型
+	Values map[string][]interface{}
+}
+
+// Generator 按 schema 生成日志，不能并发使用
+type Generator struct {
+	schema   *models.Schema
+	rand     *rand.Rand
+	values   map[string][]interface{}
+	patterns map[*models.Field]*pattern
+	pools    map[string]*rand.Zipf
+}
+
+// New 创建 schema 的日志生成器，字段正则无法解析或候选值不符合字段类型时返回错误
+func New(schema *models.Schema, opts Options) (*Generator, error) {
+	g := &Generator{
+		schema:   schema,
+		rand:     rand.New(rand.NewSource(opts.Seed)),
+		values:   opts.Values,
+		patterns: make(map[*models.Field]*pattern),
+		pools:    make(map[string]*rand.Zipf),
+	}
+	if err := g.compile(schema.Fields); err != nil {
+		return nil, err
+	}
+	for name, values := range opts.Values {
+		field := schema.GetField(name)
+		if field == nil {
+			return nil, fmt.Errorf("field %s is not defined in %s:%s", name, schema.Project, schema.Table)
+		}
+		probe := &models.Schema{Project: schema.Project, Table: schema.Table, Fields: []*models.Field{field}}
+		for _, value := range values {
+			entry := &models.LogEntry{
+				Project: schema.Project, Table: schema.Table, Level: "info", Message: "probe",
+				Timestamp: time.Now(), Fields: map[string]interface{}{name: value},
+			}
+			if err := probe.ValidateLogEntry(entry); err != nil {
+				return nil, fmt.Errorf("invalid value %v for field %s: %w", value, name, err)
+			}
+		}
+	}
+	return g, nil
+}
+
+// compile 编译字段及其子字段的正则
+func (g *Generator) compile(fields []*models.Field) error {
+	for _, field := range fields {
+		if field.Pattern != "" {
+			p, err := compilePattern(field.Pattern)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			g.patterns[field] = p
+		}
+		if err := g.compile(field.Fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Entry 生成一条时间为 now 的日志。已弃用与 rest 字段不生成，非必填字段有一定比例被省略
+func (g *Generator) Entry(now time.Time) *models.LogEntry {
+	level := g.level()
+	candidates := messages[level]
+	entry := &models.LogEntry{
+		Project:   g.schema.Project,
+		Table:     g.schema.Table,
+		Level:     level,
+		Message:   candidates[g.rand.Intn(len(candidates))],
+		Timestamp: now,
+		Fields:    make(map[string]interface{}, len(g.schema.Fields)),
+	}
+	for _, field := range g.schema.Fields {
+		if field.Type == models.FieldTypeRest || field.Deprecated {
+			continue
+		}
+		if !field.Required && g.rand.Float64() < omitRate {
+			continue
+		}
+		if values := g.values[field.Name]; len(values) > 0 {
+			entry.Fields[field.Name] = values[g.rand.Intn(len(values))]
+			continue
+		}
+		entry.Fields[field.Name] = g.value(field, now)
+	}
+	return entry
+}
+
+// Body 将日志转换为写入接口的请求体：level、message、timestamp 与各字段位于同一层
+func Body(entry *models.LogEntry) map[string]interface{} {
+	body := make(map[string]interface{}, len(entry.Fields)+3)
+	for name, value := range entry.Fields {
+		body[name] = value
+	}
+	body["level"] = entry.Level
+	body["message"] = entry.Message
+	body["timestamp"] = entry.Timestamp.Format(time.RFC3339Nano)
+	return body
+}
+
+// level 按权重选择日志级别
+func (g *Generator) level() string {
+	n := g.rand.Intn(100)
+	for _, l := range levels {
+		if n < l.weight {
+			return l.name
+		}
+		n -= l.weight
+	}
+	return "info"
+}
+
+// value 按字段类型与约束生成值。时间与时长以字符串表示，写入 API 与直接写入存储的结果相同
+func (g *Generator) value(field *models.Field, now time.Time) interface{} {
+	switch field.Type {
+	case models.FieldTypeString:
+		return g.str(field)
+	case models.FieldTypeInt:
+		return g.integer(field)
+	case models.FieldTypeFloat:
+		return g.float(field)
+	case models.FieldTypeBool:
+		return g.rand.Intn(10) < 8
+	case models.FieldTypeDateTime:
+		return now.Add(-time.Duration(g.rand.Int63n(int64(time.Hour)))).UTC().Format(time.RFC3339Nano)
+	case models.FieldTypeTime:
+		return fmt.Sprintf("%02d:%02d:%02d", g.rand.Intn(24), g.rand.Intn(60), g.rand.Intn(60))
+	case models.FieldTypeDuration:
+		return g.latency().Nanoseconds()
+	case models.FieldTypeIP:
+		return g.ip()
+	case models.FieldTypeJSON:
+		return map[string]interface{}{"id": g.rand.Intn(1000), "tag": words[g.rand.Intn(len(words))]}
+	case models.FieldTypeObject:
+		obj := make(map[string]interface{}, len(field.Fields))
+		for _, sub := range field.Fields {
+			if sub.Type == models.FieldTypeRest || sub.Deprecated || (!sub.Required && g.rand.Float64() < omitRate) {
+				continue
+			}
+			obj[sub.Name] = g.value(sub, now)
+		}
+		return obj
+	case models.FieldTypeArray:
+		item := field.ItemField()
+		items := make([]interface{}, 1+g.rand.Intn(3))
+		for i := range items {
+			items[i] = g.value(item, now)
+		}
+		return items
+	}
+	return nil
+}
+
+// str 生成字符串：有正则时按正则生成，否则按字段名选择常见的取值形式，再调整到长度约束内
+func (g *Generator) str(field *models.Field) string {
+	if p := g.patterns[field]; p != nil {
+		var s string
+		for i := 0; i < 10; i++ {
+			s = p.generate(g.rand)
+			if fitsLength(field, s) {
+				break
+			}
+		}
+		return s
+	}
+
+	name := strings.ToLower(field.Name)
+	var s string
+	switch {
+	case strings.Contains(name, "method"):
+		s = httpMethods[g.rand.Intn(len(httpMethods))]
+	case strings.Contains(name, "path") || strings.Contains(name, "url") || strings.Contains(name, "route"):
+		s = fmt.Sprintf("/api/v1/%s/%d", pathRoots[g.rand.Intn(len(pathRoots))], g.pick(name, 5000))
+	case strings.Contains(name, "email"):
+		s = fmt.Sprintf("user%d@example.com", g.pick(name, 1000))
+	case strings.Contains(name, "host") || strings.Contains(name, "service"):
+		s = fmt.Sprintf("%s-%d", hostNames[g.rand.Intn(len(hostNames))], g.rand.Intn(4))
+	case strings.HasSuffix(name, "_id"):
+		s = fmt.Sprintf("%s-%d", strings.TrimSuffix(name, "_id"), g.pick(name, 1000))
+	default:
+		s = words[g.rand.Intn(len(words))]
+	}
+
+	if field.MaxLength != nil && utf8.RuneCountInString(s) > *field.MaxLength {
+		s = string([]rune(s)[:*field.MaxLength])
+	}
+	if field.MinLength != nil {
+		for n := utf8.RuneCountInString(s); n < *field.MinLength; n++ {
+			s += string(rune('a' + g.rand.Intn(26)))
+		}
+	}
+	return s
+}
+
+// fitsLength 字符串是否满足长度约束
+func fitsLength(field *models.Field, s string) bool {
+	n := utf8.RuneCountInString(s)
+	return (field.MinLength == nil || n >= *field.MinLength) && (field.MaxLength == nil || n <= *field.MaxLength)
+}
+
+// pick 从名为 name 的取值池中按 Zipf 分布选择编号，少数值出现得最频繁，接近真实的用户与资源分布
+func (g *Generator) pick(name string, size uint64) uint64 {
+	pool := g.pools[name]
+	if pool == nil {
+		pool = rand.NewZipf(g.rand, 1.2, 1, size-1)
+		g.pools[name] = pool
+	}
+	return pool.Uint64()
+}
+
+// integer 生成整数：有取值范围时在范围内均匀分布，名为 status 的字段使用 HTTP 状态码
+func (g *Generator) integer(field *models.Field) int64 {
+	if field.MinValue != nil || field.MaxValue != nil {
+		lo, hi := bounds(field, 0, 1000)
+		lo, hi = math.Ceil(lo), math.Floor(hi)
+		if hi < lo {
+			return int64(lo)
+		}
+		return int64(lo) + g.rand.Int63n(int64(hi-lo)+1)
+	}
+	name := strings.ToLower(field.Name)
+	switch {
+	case strings.Contains(name, "status"):
+		n := g.rand.Intn(100)
+		for _, s := range httpStatuses {
+			if n < s.weight {
+				return int64(s.code)
+			}
+			n -= s.weight
+		}
+		return 200
+	case strings.Contains(name, "bytes") || strings.Contains(name, "size"):
+		return int64(g.rand.ExpFloat64() * 4096)
+	case strings.Contains(name, "ms") || strings.Contains(name, "latency"):
+		return g.latency().Milliseconds()
+	}
+	return g.rand.Int63n(1000)
+}
+
+// float 生成浮点数：有取值范围时在范围内均匀分布，延迟类字段使用对数正态分布的毫秒数
+func (g *Generator) float(field *models.Field) float64 {
+	if field.MinValue != nil || field.MaxValue != nil {
+		lo, hi := bounds(field, 0, 100)
+		return lo + g.rand.Float64()*(hi-lo)
+	}
+	name := strings.ToLower(field.Name)
+	if strings.Contains(name, "latency") || strings.Contains(name, "duration") || strings.Contains(name, "elapsed") {
+		return float64(g.latency().Microseconds()) / 1000
+	}
+	return math.Round(g.rand.Float64()*10000) / 100
+}
+
+// bounds 返回字段的取值范围。只设置下限时上限为下限加 span；只设置上限时下限为 lo，上限小于 lo 时为上限减 span
+func bounds(field *models.Field, lo, span float64) (float64, float64) {
+	switch {
+	case field.MinValue != nil && field.MaxValue != nil:
+		return *field.MinValue, *field.MaxValue
+	case field.MinValue != nil:
+		return *field.MinValue, *field.MinValue + span
+	case *field.MaxValue >= lo:
+		return lo, *field.MaxValue
+	default:
+		return *field.MaxValue - span, *field.MaxValue
+	}
+}
+
+// latency 对数正态分布的请求耗时，中位数约 50ms，少量请求耗时数秒
+func (g *Generator) latency() time.Duration {
+	ms := math.Exp(math.Log(50) + g.rand.NormFloat64())
+	return time.Duration(ms * float64(time.Millisecond)).Round(time.Microsecond)
+}
+
+// ip 生成私有网段的 IPv4 地址，少量为 IPv6
+func (g *Generator) ip() string {
+	if g.rand.Intn(10) == 0 {
+		var b [16]byte
+		b[0], b[1] = 0xfd, 0x00
+		g.rand.Read(b[8:])
+		return netip.AddrFrom16(b).String()
+	}
+	return netip.AddrFrom4([4]byte{10, byte(g.rand.Intn(4)), byte(g.rand.Intn(256)), byte(1 + g.rand.Intn(254))}).String()
+}
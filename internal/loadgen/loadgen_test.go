@@ -0,0 +1,163 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+// testSchema 覆盖各字段类型与约束的 schema
+func testSchema(t *testing.T) *models.Schema {
+	t.Helper()
+	schema := &models.Schema{Project: "app", Table: "requests", Fields: []*models.Field{
+		{Name: "method", Type: models.FieldTypeString, Required: true},
+		{Name: "path", Type: models.FieldTypeString},
+		{Name: "user_id", Type: models.FieldTypeString, MinLength: ptr(6), MaxLength: ptr(12)},
+		{Name: "code", Type: models.FieldTypeString, Required: true, Pattern: `^[A-Z]{3}-\d{4}(-(ok|retry))?$`},
+		{Name: "region", Type: models.FieldTypeString, Pattern: `(?i)^(eu|us)-west$`},
+		{Name: "status", Type: models.FieldTypeInt, Required: true},
+		{Name: "attempt", Type: models.FieldTypeInt, MinValue: ptr(1.0), MaxValue: ptr(3.0)},
+		{Name: "latency", Type: models.FieldTypeFloat},
+		{Name: "ratio", Type: models.FieldTypeFloat, MaxValue: ptr(-1.0)},
+		{Name: "cached", Type: models.FieldTypeBool},
+		{Name: "seen_at", Type: models.FieldTypeDateTime},
+		{Name: "local", Type: models.FieldTypeTime},
+		{Name: "elapsed", Type: models.FieldTypeDuration},
+		{Name: "client_ip", Type: models.FieldTypeIP},
+		{Name: "payload", Type: models.FieldTypeJSON},
+		{Name: "request", Type: models.FieldTypeObject, Fields: []*models.Field{
+			{Name: "host", Type: models.FieldTypeString, Required: true},
+			{Name: "size", Type: models.FieldTypeInt},
+		}},
+		{Name: "tags", Type: models.FieldTypeArray, ItemType: models.FieldTypeString},
+		{Name: "old", Type: models.FieldTypeString, Deprecated: true},
+		{Name: "extra", Type: models.FieldTypeRest},
+	}}
+	require.NoError(t, schema.Validate())
+	return schema
+}
+
+func TestGeneratorEntries(t *testing.T) {
+	schema := testSchema(t)
+	gen, err := New(schema, Options{Seed: 1})
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	levels := make(map[string]int)
+	omitted := 0
+	for i := 0; i < 500; i++ {
+		entry := gen.Entry(now)
+		require.NoError(t, schema.ValidateLogEntry(entry), "entry %d: %v", i, entry.Fields)
+		assert.Equal(t, now, entry.Timestamp)
+		assert.NotEmpty(t, entry.Message)
+		assert.NotContains(t, entry.Fields, "old", "deprecated fields are not generated")
+		assert.NotContains(t, entry.Fields, "extra", "rest fields are not generated")
+		assert.Contains(t, entry.Fields, "method")
+		levels[entry.Level]++
+		if _, ok := entry.Fields["path"]; !ok {
+			omitted++
+		}
+		if v, ok := entry.Fields["ratio"]; ok {
+			assert.LessOrEqual(t, v.(float64), -1.0)
+		}
+		if v, ok := entry.Fields["elapsed"]; ok {
+			assert.IsType(t, int64(0), v)
+		}
+	}
+	assert.Greater(t, levels["info"], levels["warn"], "info is the most common level")
+	assert.Positive(t, levels["error"])
+	assert.Positive(t, omitted, "optional fields are sometimes omitted")
+	assert.Less(t, omitted, 150)
+
+	a, _ := New(schema, Options{Seed: 7})
+	b, _ := New(schema, Options{Seed: 7})
+	assert.Equal(t, a.Entry(now), b.Entry(now), "the same seed generates the same entries")
+}
+
+func TestGeneratorValues(t *testing.T) {
+	schema := testSchema(t)
+	gen, err := New(schema, Options{Values: map[string][]interface{}{"method": {"GET", "HEAD"}, "status": {200, 503}}})
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		entry := gen.Entry(time.Now())
+		assert.Contains(t, []interface{}{"GET", "HEAD"}, entry.Fields["method"])
+		assert.Contains(t, []interface{}{200, 503}, entry.Fields["status"])
+	}
+
+	_, err = New(schema, Options{Values: map[string][]interface{}{"missing": {"x"}}})
+	assert.ErrorContains(t, err, "field missing is not defined")
+	_, err = New(schema, Options{Values: map[string][]interface{}{"attempt": {7}}})
+	assert.ErrorContains(t, err, "invalid value 7 for field attempt")
+}
+
+func TestPattern(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, expr := range []string{
+		`^[a-f0-9]{8}-[a-f0-9]{4}$`,
+		`(GET|POST|DELETE)`,
+		`^\w+@example\.(com|org)$`,
+		`[^,\s]+`,
+		`^v\d+\.\d+(\.\d+)?(-rc\d)?$`,
+		`(?i)abc`,
+		`a.b*c+d?`,
+	} {
+		p, err := compilePattern(expr)
+		require.NoError(t, err)
+		re := regexp.MustCompile(`^(?:` + expr + `)$`)
+		for i := 0; i < 50; i++ {
+			s := p.generate(r)
+			assert.True(t, re.MatchString(s), "%q does not match %s", s, expr)
+		}
+	}
+	_, err := compilePattern(`[`)
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	gen, err := New(testSchema(t), Options{})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var sizes []int
+	failing := errors.New("backend overloaded")
+	sink := SinkFunc(func(ctx context.Context, entries []*models.LogEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sizes = append(sizes, len(entries))
+		if len(entries) < 100 {
+			return failing
+		}
+		return nil
+	})
+
+	report, err := Run(context.Background(), gen, sink, RunOptions{Count: 250, BatchSize: 100, Concurrency: 2})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{100, 100, 50}, sizes)
+	assert.Equal(t, int64(200), report.Sent)
+	assert.Equal(t, int64(50), report.Failed, "failed batches are counted without stopping the run")
+	assert.Equal(t, 3, report.Batches)
+	assert.Equal(t, failing.Error(), report.LastError)
+	assert.LessOrEqual(t, report.P50, report.Max)
+
+	// 限速时批次被拆小，速率接近目标
+	sizes = nil
+	report, err = Run(context.Background(), gen, SinkFunc(func(context.Context, []*models.LogEntry) error { return nil }), RunOptions{
+		Rate: 200, Duration: 500 * time.Millisecond, BatchSize: 100,
+	})
+	require.NoError(t, err)
+	assert.InDelta(t, 100, report.Sent, 30)
+	assert.InDelta(t, 200, report.Throughput, 60)
+
+	_, err = Run(context.Background(), gen, sink, RunOptions{Rate: 10})
+	assert.Error(t, err, "an unbounded run is rejected")
+}
@@ -0,0 +1,111 @@
+package loadgen
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// maxRepeat 无上限的重复（*、+、{n,}）最多额外重复的次数
+const maxRepeat = 8
+
+// pattern 按正则表达式生成匹配的字符串
+type pattern struct {
+	re *syntax.Regexp
+}
+
+// compilePattern 解析字段正则，语法与 regexp 包相同
+func compilePattern(expr string) (*pattern, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", expr, err)
+	}
+	return &pattern{re: re.Simplify()}, nil
+}
+
+// generate 生成一个完整匹配正则的字符串。锚点与单词边界不产生字符，生成的字符优先取可打印 ASCII
+func (p *pattern) generate(r *rand.Rand) string {
+	var b strings.Builder
+	writePattern(&b, r, p.re)
+	return b.String()
+}
+
+func writePattern(b *strings.Builder, r *rand.Rand, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, c := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && r.Intn(2) == 0 {
+				c = unicode.SimpleFold(c)
+			}
+			b.WriteRune(c)
+		}
+	case syntax.OpCharClass:
+		b.WriteRune(pickRune(r, re.Rune))
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		b.WriteByte(byte('a' + r.Intn(26)))
+	case syntax.OpCapture:
+		writePattern(b, r, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writePattern(b, r, sub)
+		}
+	case syntax.OpAlternate:
+		writePattern(b, r, re.Sub[r.Intn(len(re.Sub))])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		min, max := repeatRange(re)
+		for n := min + r.Intn(max-min+1); n > 0; n-- {
+			writePattern(b, r, re.Sub[0])
+		}
+	}
+}
+
+// repeatRange 返回重复次数的范围
+func repeatRange(re *syntax.Regexp) (int, int) {
+	switch re.Op {
+	case syntax.OpStar:
+		return 0, maxRepeat
+	case syntax.OpPlus:
+		return 1, 1 + maxRepeat
+	case syntax.OpQuest:
+		return 0, 1
+	}
+	if re.Max < 0 {
+		return re.Min, re.Min + maxRepeat
+	}
+	return re.Min, re.Max
+}
+
+// pickRune 从字符类的区间中随机取一个字符，区间与可打印 ASCII 有交集时只在交集中取
+func pickRune(r *rand.Rand, ranges []rune) rune {
+	type span struct{ lo, hi rune }
+	var printable, all []span
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		all = append(all, span{lo, hi})
+		if lo, hi := max(lo, ' '), min(hi, '~'); lo <= hi {
+			printable = append(printable, span{lo, hi})
+		}
+	}
+	spans := printable
+	if len(spans) == 0 {
+		spans = all
+	}
+	if len(spans) == 0 {
+		return 'x'
+	}
+	total := 0
+	for _, s := range spans {
+		total += int(s.hi-s.lo) + 1
+	}
+	n := r.Intn(total)
+	for _, s := range spans {
+		size := int(s.hi-s.lo) + 1
+		if n < size {
+			return s.lo + rune(n)
+		}
+		n -= size
+	}
+	return spans[0].lo
+}
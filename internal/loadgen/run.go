@@ -0,0 +1,211 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+const (
+	// DefaultBatchSize 每批写入的默认日志条数
+	DefaultBatchSize = 100
+	// DefaultConcurrency 默认的并发写入数
+	DefaultConcurrency = 4
+)
+
+// Sink 写入一批生成的日志，需要支持并发调用
+type Sink interface {
+	Write(ctx context.Context, entries []*models.LogEntry) error
+}
+
+// SinkFunc 将函数适配为 Sink
+type SinkFunc func(ctx context.Context, entries []*models.LogEntry) error
+
+// Write 调用 f
+func (f SinkFunc) Write(ctx context.Context, entries []*models.LogEntry) error {
+	return f(ctx, entries)
+}
+
+// RunOptions 压测选项，Duration 与 Count 至少设置一个，先到者结束压测
+type RunOptions struct {
+	Rate        float64       // 目标速率（条/秒），不大于 0 时不限速
+	Duration    time.Duration // 压测时长
+	Count       int           // 生成的日志总数
+	BatchSize   int           // 每批条数，默认 DefaultBatchSize；限速时不超过每 100ms 的目标条数，避免突发
+	Concurrency int           // 并发写入数，默认 DefaultConcurrency
+
+	// Interval 调用 OnProgress 的间隔，为 0 时不报告进度
+	Interval   time.Duration
+	OnProgress func(*Report)
+}
+
+// Report 压测结果，延迟按批次统计
+type Report struct {
+	Sent       int64         `json:"sent"`       // 写入成功的日志数
+	Failed     int64         `json:"failed"`     // 写入失败的日志数
+	Batches    int           `json:"batches"`    // 写入的批次数，包括失败的批次
+	Elapsed    time.Duration `json:"elapsed"`    // 已运行的时间
+	Throughput float64       `json:"throughput"` // 写入成功的日志数每秒
+	P50        time.Duration `json:"p50"`
+	P90        time.Duration `json:"p90"`
+	P99        time.Duration `json:"p99"`
+	Max        time.Duration `json:"max"`
+	LastError  string        `json:"last_error,omitempty"`
+}
+
+// stats 并发写入时累计的结果
+type stats struct {
+	mu        sync.Mutex
+	start     time.Time
+	sent      int64
+	failed    int64
+	latencies []time.Duration
+	lastError error
+}
+
+func (s *stats) record(n int, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies = append(s.latencies, latency)
+	if err != nil {
+		s.failed += int64(n)
+		s.lastError = err
+		return
+	}
+	s.sent += int64(n)
+}
+
+// report 返回当前的统计结果
+func (s *stats) report() *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &Report{Sent: s.sent, Failed: s.failed, Batches: len(s.latencies), Elapsed: time.Since(s.start)}
+	if r.Elapsed > 0 {
+		r.Throughput = float64(r.Sent) / r.Elapsed.Seconds()
+	}
+	if s.lastError != nil {
+		r.LastError = s.lastError.Error()
+	}
+	if len(s.latencies) > 0 {
+		sorted := append([]time.Duration(nil), s.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		r.P50, r.P90, r.P99 = percentile(sorted, 0.5), percentile(sorted, 0.9), percentile(sorted, 0.99)
+		r.Max = sorted[len(sorted)-1]
+	}
+	return r
+}
+
+// percentile 返回已排序延迟的 q 分位数
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// Run 以目标速率生成日志并写入 sink，直到达到 Duration 或 Count，或 ctx 被取消。
+// 写入失败只计入结果，不中断压测；sink 跟不上目标速率时实际速率随之下降
+func Run(ctx context.Context, gen *Generator, sink Sink, opts RunOptions) (*Report, error) {
+	if opts.Duration <= 0 && opts.Count <= 0 {
+		return nil, fmt.Errorf("either a duration or a count is required")
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if opts.Rate > 0 {
+		batchSize = max(1, min(batchSize, int(math.Ceil(opts.Rate/10))))
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	s := &stats{start: time.Now()}
+	var deadline time.Time
+	if opts.Duration > 0 {
+		deadline = s.start.Add(opts.Duration)
+	}
+
+	batches := make(chan []*models.LogEntry, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if ctx.Err() != nil {
+					continue // 压测已取消，排队的批次不再写入
+				}
+				started := time.Now()
+				err := sink.Write(ctx, batch)
+				s.record(len(batch), time.Since(started), err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	if opts.Interval > 0 && opts.OnProgress != nil {
+		go func() {
+			ticker := time.NewTicker(opts.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					opts.OnProgress(s.report())
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	generate(ctx, gen, batches, s.start, deadline, batchSize, opts)
+	close(batches)
+	wg.Wait()
+	close(done)
+	return s.report(), nil
+}
+
+// generate 按计划的时间生成批次：第 n 条日志不早于 start + n/Rate 发出
+func generate(ctx context.Context, gen *Generator, batches chan<- []*models.LogEntry, start, deadline time.Time, batchSize int, opts RunOptions) {
+	for generated := 0; opts.Count <= 0 || generated < opts.Count; {
+		if opts.Rate > 0 {
+			due := start.Add(time.Duration(float64(generated) / opts.Rate * float64(time.Second)))
+			if !deadline.IsZero() && !due.Before(deadline) {
+				return
+			}
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+		}
+		now := time.Now()
+		if ctx.Err() != nil || (!deadline.IsZero() && !now.Before(deadline)) {
+			return
+		}
+
+		n := batchSize
+		if opts.Count > 0 {
+			n = min(n, opts.Count-generated)
+		}
+		batch := make([]*models.LogEntry, n)
+		for i := range batch {
+			batch[i] = gen.Entry(now)
+		}
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			return
+		}
+		generated += n
+	}
+}
@@ -0,0 +1,54 @@
+// Package logging 创建服务端使用的 zap logger，并为各组件的日志附加 component 字段
+package logging
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config 日志配置
+type Config struct {
+	// Level 最低输出级别：debug、info（默认）、warn、error
+	Level string `yaml:"level"`
+	// Format 输出格式：json（默认）或 console
+	Format string `yaml:"format"`
+	// Output 输出位置：stderr（默认）、stdout 或文件路径
+	Output string `yaml:"output"`
+}
+
+// New 根据配置创建 logger
+func New(cfg Config) (*zap.Logger, error) {
+	level := zapcore.InfoLevel
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", cfg.Level)
+		}
+	}
+
+	var zc zap.Config
+	switch cfg.Format {
+	case "", "json":
+		zc = zap.NewProductionConfig()
+		zc.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	case "console":
+		zc = zap.NewDevelopmentConfig()
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected json or console", cfg.Format)
+	}
+	zc.Level = zap.NewAtomicLevelAt(level)
+	zc.Development = false
+	if cfg.Output != "" {
+		zc.OutputPaths = []string{cfg.Output}
+	}
+	return zc.Build()
+}
+
+// Component 返回附加了 component 字段的 logger，logger 为 nil 时使用全局 logger
+func Component(logger *zap.Logger, name string) *zap.Logger {
+	if logger == nil {
+		logger = zap.L()
+	}
+	return logger.With(zap.String("component", name))
+}
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNew(t *testing.T) {
+	logger, err := New(Config{})
+	require.NoError(t, err)
+	assert.True(t, logger.Core().Enabled(zapcore.InfoLevel))
+	assert.False(t, logger.Core().Enabled(zapcore.DebugLevel))
+
+	logger, err = New(Config{Level: "debug", Format: "console"})
+	require.NoError(t, err)
+	assert.True(t, logger.Core().Enabled(zapcore.DebugLevel))
+
+	path := filepath.Join(t.TempDir(), "server.log")
+	logger, err = New(Config{Output: path})
+	require.NoError(t, err)
+	logger.Info("written")
+	require.NoError(t, logger.Sync())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"written"`)
+
+	_, err = New(Config{Level: "verbose"})
+	assert.ErrorContains(t, err, "invalid log level")
+	_, err = New(Config{Format: "xml"})
+	assert.ErrorContains(t, err, "invalid log format")
+}
+
+func TestComponent(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	Component(zap.New(core), "schema").Warn("reload failed")
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "schema", logs.All()[0].ContextMap()["component"])
+
+	restore := zap.ReplaceGlobals(zap.New(core))
+	defer restore()
+	Component(nil, "api").Info("started")
+	assert.Equal(t, "api", logs.All()[1].ContextMap()["component"])
+}
@@ -0,0 +1,360 @@
+// Package metrics 按规则从写入的日志生成 Prometheus 计数器与直方图，以文本格式在 /metrics 暴露
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// RuleType 指标类型
+type RuleType string
+
+const (
+	Counter   RuleType = "counter"
+	Histogram RuleType = "histogram"
+)
+
+// DefaultBuckets 直方图默认分桶上界，与 Prometheus 客户端库相同，时长以秒为单位
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// MaxSeriesPerRule 单条规则最多的标签组合数，超过后新的组合被丢弃并计入 logs_metric_series_dropped_total
+const MaxSeriesPerRule = 10000
+
+var (
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNamePattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Rule 日志转指标规则：匹配 project、table 与 filter 的日志使计数器加一（指定 field 时加字段值），
+// 或将 field 的值记入直方图。指标总是带有 project 与 table 标签
+type Rule struct {
+	Name    string   `yaml:"name" mapstructure:"name"` // 指标名，如 http_requests_total
+	Help    string   `yaml:"help,omitempty" mapstructure:"help"`
+	Type    RuleType `yaml:"type" mapstructure:"type"`
+	Project string   `yaml:"project" mapstructure:"project"`
+	Table   string   `yaml:"table,omitempty" mapstructure:"table"` // 为空时匹配项目下的所有表
+
+	// Filter 字段等值条件，level、message 匹配日志级别与内容，值按文本比较
+	Filter map[string]interface{} `yaml:"filter,omitempty" mapstructure:"filter"`
+	// Labels 作为标签的字段，level 取日志级别，字段缺失时标签值为空
+	Labels []string `yaml:"labels,omitempty" mapstructure:"labels"`
+	// Field 直方图的观测值；时长字段与时长文本按秒计算，非数值的日志不计入
+	Field string `yaml:"field,omitempty" mapstructure:"field"`
+	// Buckets 直方图分桶上界，默认 DefaultBuckets
+	Buckets []float64 `yaml:"buckets,omitempty" mapstructure:"buckets"`
+}
+
+// Validate 检查规则的名称、类型、标签与分桶
+func (r *Rule) Validate() error {
+	if !metricNamePattern.MatchString(r.Name) {
+		return fmt.Errorf("invalid metric name: %q", r.Name)
+	}
+	if r.Project == "" {
+		return fmt.Errorf("metric %s: project is required", r.Name)
+	}
+	switch r.Type {
+	case Counter:
+	case Histogram:
+		if r.Field == "" {
+			return fmt.Errorf("metric %s: histogram requires a field", r.Name)
+		}
+		for i := 1; i < len(r.Buckets); i++ {
+			if r.Buckets[i] <= r.Buckets[i-1] {
+				return fmt.Errorf("metric %s: buckets must be in increasing order", r.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("metric %s: unsupported type %q", r.Name, r.Type)
+	}
+
+	seen := map[string]bool{"project": true, "table": true}
+	for _, label := range r.Labels {
+		if !labelNamePattern.MatchString(label) || strings.HasPrefix(label, "__") || label == "le" {
+			return fmt.Errorf("metric %s: invalid label name %q", r.Name, label)
+		}
+		if seen[label] {
+			return fmt.Errorf("metric %s: duplicate label %q", r.Name, label)
+		}
+		seen[label] = true
+	}
+	return nil
+}
+
+// matches 判断日志是否匹配规则
+func (r *Rule) matches(project, table string, log *models.LogEntry) bool {
+	if r.Project != project || (r.Table != "" && r.Table != table) {
+		return false
+	}
+	for key, want := range r.Filter {
+		got, ok := value(log, key)
+		if !ok || fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+// series 一个标签组合的累计值
+type series struct {
+	labels  []string // 与 project、table 及 Rule.Labels 一一对应
+	value   float64  // counter 的值
+	count   uint64   // histogram 的观测次数
+	sum     float64
+	buckets []uint64 // 各分桶的观测次数（非累计）
+}
+
+// metric 一条规则及其所有标签组合
+type metric struct {
+	rule    *Rule
+	buckets []float64
+	series  map[string]*series
+	dropped uint64
+}
+
+// Registry 保存规则与各指标的累计值，可并发使用
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// NewRegistry 校验规则并创建 Registry，规则名不能重复
+func NewRegistry(rules []*Rule) (*Registry, error) {
+	r := &Registry{}
+	names := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate metric name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		m := &metric{rule: rule, series: make(map[string]*series)}
+		if rule.Type == Histogram {
+			m.buckets = rule.Buckets
+			if len(m.buckets) == 0 {
+				m.buckets = DefaultBuckets
+			}
+		}
+		r.metrics = append(r.metrics, m)
+	}
+	return r, nil
+}
+
+// Observe 将成功写入的一批日志计入匹配的规则，r 为 nil 时不做任何事
+func (r *Registry) Observe(project, table string, logs []*models.LogEntry) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range r.metrics {
+		for _, log := range logs {
+			if !m.rule.matches(project, table, log) {
+				continue
+			}
+			var observed float64
+			if m.rule.Field != "" {
+				v, ok := value(log, m.rule.Field)
+				if !ok {
+					continue
+				}
+				if observed, ok = numeric(v); !ok {
+					continue
+				}
+			}
+
+			s := m.get(project, table, log)
+			if s == nil {
+				continue
+			}
+			switch m.rule.Type {
+			case Counter:
+				if m.rule.Field == "" {
+					observed = 1
+				}
+				s.value += observed
+			case Histogram:
+				s.count++
+				s.sum += observed
+				for i, bound := range m.buckets {
+					if observed <= bound {
+						s.buckets[i]++
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// get 返回日志对应标签组合的累计值，组合数达到上限时返回 nil
+func (m *metric) get(project, table string, log *models.LogEntry) *series {
+	labels := make([]string, 0, len(m.rule.Labels)+2)
+	labels = append(labels, project, table)
+	for _, name := range m.rule.Labels {
+		v, _ := value(log, name)
+		if v == nil {
+			labels = append(labels, "")
+		} else {
+			labels = append(labels, fmt.Sprint(v))
+		}
+	}
+
+	key := strings.Join(labels, "\x00")
+	if s, ok := m.series[key]; ok {
+		return s
+	}
+	if len(m.series) >= MaxSeriesPerRule {
+		m.dropped++
+		return nil
+	}
+	s := &series{labels: labels}
+	if m.rule.Type == Histogram {
+		s.buckets = make([]uint64, len(m.buckets))
+	}
+	m.series[key] = s
+	return s
+}
+
+// WriteTo 以 Prometheus 文本格式（0.0.4）输出所有指标
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+	var dropped []*metric
+	for _, m := range r.metrics {
+		rule := m.rule
+		if rule.Help != "" {
+			fmt.Fprintf(&b, "# HELP %s %s\n", rule.Name, escapeHelp(rule.Help))
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", rule.Name, rule.Type)
+
+		names := append([]string{"project", "table"}, rule.Labels...)
+		keys := make([]string, 0, len(m.series))
+		for key := range m.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := m.series[key]
+			labels := formatLabels(names, s.labels)
+			if rule.Type == Counter {
+				fmt.Fprintf(&b, "%s{%s} %s\n", rule.Name, labels, formatFloat(s.value))
+				continue
+			}
+			var cumulative uint64
+			for i, bound := range m.buckets {
+				cumulative += s.buckets[i]
+				fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n", rule.Name, labels, formatFloat(bound), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", rule.Name, labels, s.count)
+			fmt.Fprintf(&b, "%s_sum{%s} %s\n", rule.Name, labels, formatFloat(s.sum))
+			fmt.Fprintf(&b, "%s_count{%s} %d\n", rule.Name, labels, s.count)
+		}
+		if m.dropped > 0 {
+			dropped = append(dropped, m)
+		}
+	}
+	if len(dropped) > 0 {
+		b.WriteString("# HELP logs_metric_series_dropped_total Label combinations dropped after reaching the per-rule series limit.\n")
+		b.WriteString("# TYPE logs_metric_series_dropped_total counter\n")
+		for _, m := range dropped {
+			fmt.Fprintf(&b, "logs_metric_series_dropped_total{rule=\"%s\"} %d\n", escapeLabel(m.rule.Name), m.dropped)
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP 输出 /metrics
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// value 读取日志的字段值，level、message 在字段中不存在时取日志级别与内容
+func value(log *models.LogEntry, name string) (interface{}, bool) {
+	if v, ok := log.Fields[name]; ok {
+		return v, true
+	}
+	switch name {
+	case "level":
+		return log.Level, log.Level != ""
+	case "message":
+		return log.Message, log.Message != ""
+	}
+	return nil, false
+}
+
+// numeric 将观测值转换为 float64，时长按秒计算
+func numeric(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case time.Duration:
+		return n.Seconds(), true
+	case string:
+		if d, err := time.ParseDuration(n); err == nil {
+			return d.Seconds(), true
+		}
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// formatLabels 生成 name="value" 形式的标签列表
+func formatLabels(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", name, escapeLabel(values[i]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// formatFloat 按 Prometheus 文本格式输出浮点数
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
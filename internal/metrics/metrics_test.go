@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestRegistry(t *testing.T) {
+	registry, err := NewRegistry([]*Rule{
+		{
+			Name:    "http_requests_total",
+			Help:    "HTTP requests by status.",
+			Type:    Counter,
+			Project: "app",
+			Table:   "requests",
+			Labels:  []string{"status", "level"},
+		},
+		{
+			Name:    "http_request_duration_seconds",
+			Type:    Histogram,
+			Project: "app",
+			Filter:  map[string]interface{}{"method": "GET"},
+			Field:   "duration",
+			Buckets: []float64{0.1, 1},
+		},
+	})
+	require.NoError(t, err)
+
+	entry := func(status int, method string, duration interface{}) *models.LogEntry {
+		return &models.LogEntry{Level: "info", Message: "request", Fields: map[string]interface{}{
+			"status": status, "method": method, "duration": duration,
+		}}
+	}
+	registry.Observe("app", "requests", []*models.LogEntry{
+		entry(200, "GET", 50*time.Millisecond),
+		entry(200, "GET", "2s"),
+		entry(500, "POST", 0.5),
+		entry(200, "GET", "n/a"), // 非数值不计入直方图
+	})
+	registry.Observe("app", "jobs", []*models.LogEntry{entry(200, "GET", 0.5)})
+	registry.Observe("other", "requests", []*models.LogEntry{entry(200, "GET", 0.5)})
+
+	var b strings.Builder
+	_, err = registry.WriteTo(&b)
+	require.NoError(t, err)
+	assert.Equal(t, `# HELP http_requests_total HTTP requests by status.
+# TYPE http_requests_total counter
+http_requests_total{project="app",table="requests",status="200",level="info"} 3
+http_requests_total{project="app",table="requests",status="500",level="info"} 1
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{project="app",table="jobs",le="0.1"} 0
+http_request_duration_seconds_bucket{project="app",table="jobs",le="1"} 1
+http_request_duration_seconds_bucket{project="app",table="jobs",le="+Inf"} 1
+http_request_duration_seconds_sum{project="app",table="jobs"} 0.5
+http_request_duration_seconds_count{project="app",table="jobs"} 1
+http_request_duration_seconds_bucket{project="app",table="requests",le="0.1"} 1
+http_request_duration_seconds_bucket{project="app",table="requests",le="1"} 1
+http_request_duration_seconds_bucket{project="app",table="requests",le="+Inf"} 2
+http_request_duration_seconds_sum{project="app",table="requests"} 2.05
+http_request_duration_seconds_count{project="app",table="requests"} 2
+`, b.String())
+
+	w := httptest.NewRecorder()
+	registry.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var nilRegistry *Registry
+	nilRegistry.Observe("app", "requests", []*models.LogEntry{entry(200, "GET", 1)})
+}
+
+func TestRegistryLabelEscapingAndLimit(t *testing.T) {
+	registry, err := NewRegistry([]*Rule{{Name: "errors_total", Type: Counter, Project: "app", Labels: []string{"message"}}})
+	require.NoError(t, err)
+	registry.Observe("app", "t", []*models.LogEntry{{Message: "bad \"quote\"\nline"}})
+
+	logs := make([]*models.LogEntry, MaxSeriesPerRule)
+	for i := range logs {
+		logs[i] = &models.LogEntry{Message: strings.Repeat("x", i+1)}
+	}
+	registry.Observe("app", "t", logs)
+
+	var b strings.Builder
+	_, err = registry.WriteTo(&b)
+	require.NoError(t, err)
+	assert.Contains(t, b.String(), `errors_total{project="app",table="t",message="bad \"quote\"\nline"} 1`)
+	assert.Contains(t, b.String(), `logs_metric_series_dropped_total{rule="errors_total"} 1`)
+}
+
+func TestRuleValidate(t *testing.T) {
+	for name, rule := range map[string]*Rule{
+		"name":      {Name: "bad-name", Type: Counter, Project: "app"},
+		"project":   {Name: "m", Type: Counter},
+		"type":      {Name: "m", Type: "gauge", Project: "app"},
+		"field":     {Name: "m", Type: Histogram, Project: "app"},
+		"buckets":   {Name: "m", Type: Histogram, Project: "app", Field: "d", Buckets: []float64{1, 1}},
+		"label":     {Name: "m", Type: Counter, Project: "app", Labels: []string{"__name"}},
+		"reserved":  {Name: "m", Type: Counter, Project: "app", Labels: []string{"table"}},
+		"duplicate": {Name: "m", Type: Counter, Project: "app", Labels: []string{"a", "a"}},
+	} {
+		assert.Error(t, rule.Validate(), name)
+	}
+
+	_, err := NewRegistry([]*Rule{
+		{Name: "m", Type: Counter, Project: "app"},
+		{Name: "m", Type: Counter, Project: "web"},
+	})
+	assert.ErrorContains(t, err, "duplicate metric name")
+}
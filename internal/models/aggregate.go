@@ -0,0 +1,259 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// AggregateFunc 聚合函数
+type AggregateFunc string
+
+const (
+	AggregateCount AggregateFunc = "count"
+	AggregateSum   AggregateFunc = "sum"
+	AggregateMin   AggregateFunc = "min"
+	AggregateMax   AggregateFunc = "max"
+	AggregateAvg   AggregateFunc = "avg"
+
+	// AggregateStddev 样本标准差
+	AggregateStddev AggregateFunc = "stddev"
+	// 百分位数，在 SQLite、MySQL 上为相对误差 1% 以内的估算值
+	AggregateP50 AggregateFunc = "p50"
+	AggregateP90 AggregateFunc = "p90"
+	AggregateP95 AggregateFunc = "p95"
+	AggregateP99 AggregateFunc = "p99"
+)
+
+// quantiles 百分位函数对应的分位数
+var quantiles = map[AggregateFunc]float64{
+	AggregateP50: 0.5,
+	AggregateP90: 0.9,
+	AggregateP95: 0.95,
+	AggregateP99: 0.99,
+}
+
+// Quantile 返回百分位函数对应的分位数，其他函数返回 false
+func (f AggregateFunc) Quantile() (float64, bool) {
+	q, ok := quantiles[f]
+	return q, ok
+}
+
+// Mergeable 函数在各时间桶的结果能否合并为更大时间桶的结果，标准差与百分位数不能
+func (f AggregateFunc) Mergeable() bool {
+	_, quantile := f.Quantile()
+	return !quantile && f != AggregateStddev
+}
+
+// AggregateMetric 聚合指标定义
+type AggregateMetric struct {
+	Func  AggregateFunc `yaml:"func" json:"func"`
+	Field string        `yaml:"field,omitempty" json:"field,omitempty"` // count 可以不指定字段
+}
+
+// Column 返回指标在聚合表中的列名
+func (m *AggregateMetric) Column() string {
+	if m.Field == "" {
+		return string(m.Func)
+	}
+	return fmt.Sprintf("%s_%s", m.Func, m.Field)
+}
+
+// Aggregate 按时间分组的持续聚合定义
+type Aggregate struct {
+	Name     string             `yaml:"name" json:"name"`
+	Interval string             `yaml:"interval" json:"interval"` // 时间桶大小，如 1m、1h
+	GroupBy  []string           `yaml:"group_by,omitempty" json:"group_by,omitempty"`
+	Metrics  []*AggregateMetric `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+}
+
+// BucketSize 返回时间桶大小
+func (a *Aggregate) BucketSize() (time.Duration, error) {
+	d, err := time.ParseDuration(a.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval for aggregate %s: %w", a.Name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("interval for aggregate %s must be positive", a.Name)
+	}
+	return d, nil
+}
+
+// ParseTimeZone 解析 IANA 时区名（如 Asia/Shanghai）或 ±hh:mm 形式的固定偏移，空字符串表示 UTC
+func ParseTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if name[0] == '+' || name[0] == '-' {
+		t, err := time.Parse("-07:00", name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone offset: %s", name)
+		}
+		_, offset := t.Zone()
+		return time.FixedZone(name, offset), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone: %s", name)
+	}
+	return loc, nil
+}
+
+// BucketStart 返回 t 所在时间桶的起点，时间桶按 loc 的当地时间对齐。
+// 整天的时间桶从当地零点开始，夏令时切换当天的桶不是 24 小时；多天的桶与 UTC 下 time.Truncate 的对齐方式相同
+func BucketStart(t time.Time, size time.Duration, loc *time.Location) time.Time {
+	const day = 24 * time.Hour
+	local := t.In(loc)
+	if size%day != 0 {
+		_, offset := local.Zone()
+		shift := time.Duration(offset) * time.Second
+		return t.Add(shift).Truncate(size).Add(-shift).In(loc)
+	}
+
+	// 以公元 1 年 1 月 1 日起的天数对齐，与 time.Truncate 的零点相同
+	const unixToZero = 719162
+	year, month, dayOfMonth := local.Date()
+	n := time.Date(year, month, dayOfMonth, 0, 0, 0, 0, time.UTC).Unix()/86400 + unixToZero
+	n -= n % int64(size/day)
+	start := time.Unix((n-unixToZero)*86400, 0).UTC()
+	return time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+}
+
+// SchemaOptions 表级别的可选配置，作为整体持久化到存储中
+type SchemaOptions struct {
+	Aggregates []*Aggregate `yaml:"aggregates,omitempty" json:"aggregates,omitempty"`
+	Retention  string       `yaml:"retention,omitempty" json:"retention,omitempty"` // 数据保留期限，如 30d、720h
+
+	// Rollups 原始日志超过 RollupAfter（如 30d）后按这些定义汇总到 rollup_<project>_<table>_<name> 表，
+	// 随后删除原始日志，长期趋势仍可低成本查询
+	Rollups     []*Aggregate `yaml:"rollups,omitempty" json:"rollups,omitempty"`
+	RollupAfter string       `yaml:"rollup_after,omitempty" json:"rollup_after,omitempty"`
+
+	// AutoEvolve 没有 Rest 字段时，写入日志中的未知字段会按推断的类型自动添加为新列，而不是被丢弃
+	AutoEvolve bool `yaml:"auto_evolve,omitempty" json:"auto_evolve,omitempty"`
+
+	// Strict 没有 Rest 字段时拒绝包含未定义字段的日志并列出这些字段，而不是静默丢弃，不能与 AutoEvolve 同时开启
+	Strict bool `yaml:"strict,omitempty" json:"strict,omitempty"`
+
+	// Timestamps 日志时间的处理规则，为空时信任客户端时间
+	Timestamps *TimestampPolicy `yaml:"timestamps,omitempty" json:"timestamps,omitempty"`
+
+	// CaptureHeaders 写入日志时复制到字段的请求头，如 User-Agent、Referer 或自定义的关联 ID，请求体中已有该字段时不覆盖
+	CaptureHeaders []*HeaderCapture `yaml:"capture_headers,omitempty" json:"capture_headers,omitempty"`
+
+	// ClickHouse 排序键、TTL 与列编码等建表参数
+	ClickHouse *ClickHouseOptions `yaml:"clickhouse,omitempty" json:"clickhouse,omitempty"`
+}
+
+// GetAggregate 按名称获取聚合定义
+func (s *Schema) GetAggregate(name string) (*Aggregate, bool) {
+	for _, agg := range s.Aggregates {
+		if agg.Name == name {
+			return agg, true
+		}
+	}
+	return nil, false
+}
+
+// GetRollup 按名称获取 rollup 定义
+func (s *Schema) GetRollup(name string) (*Aggregate, bool) {
+	for _, rollup := range s.Rollups {
+		if rollup.Name == name {
+			return rollup, true
+		}
+	}
+	return nil, false
+}
+
+// RollupCutoff 返回 now 时需要汇总的原始日志时间上限，未配置 rollup 时返回 false
+func (s *Schema) RollupCutoff(now time.Time) (time.Time, bool) {
+	if len(s.Rollups) == 0 {
+		return time.Time{}, false
+	}
+	after, err := ParseRetention(s.RollupAfter)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return now.Add(-after), true
+}
+
+// validateAggregates 验证聚合与 rollup 定义引用的字段是否有效
+func (s *Schema) validateAggregates() error {
+	fields := make(map[string]*Field, len(s.Fields))
+	for _, field := range s.Fields {
+		fields[field.Name] = field
+	}
+
+	names := make(map[string]bool)
+	for _, agg := range s.Aggregates {
+		if err := s.validateAggregate("aggregate", "cq_", agg, fields, names); err != nil {
+			return err
+		}
+	}
+
+	if len(s.Rollups) > 0 && s.RollupAfter == "" {
+		return fmt.Errorf("rollup_after is required when rollups are defined")
+	}
+	if s.RollupAfter != "" {
+		if len(s.Rollups) == 0 {
+			return fmt.Errorf("rollup_after requires at least one rollup")
+		}
+		if after, err := ParseRetention(s.RollupAfter); err != nil || after <= 0 {
+			return fmt.Errorf("invalid rollup_after: %s", s.RollupAfter)
+		}
+	}
+	names = make(map[string]bool)
+	for _, rollup := range s.Rollups {
+		if err := s.validateAggregate("rollup", "rollup_", rollup, fields, names); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateAggregate 验证单个聚合定义，kind 用于错误信息，prefix 为聚合表名前缀
+func (s *Schema) validateAggregate(kind, prefix string, agg *Aggregate, fields map[string]*Field, names map[string]bool) error {
+	if err := validateIdentifier(kind, agg.Name); err != nil {
+		return err
+	}
+	if name := prefix + s.Project + "_" + s.Table + "_" + agg.Name; len(name) > MaxIdentifierLength {
+		return fmt.Errorf("%s table name %s exceeds %d characters", kind, name, MaxIdentifierLength)
+	}
+	if names[agg.Name] {
+		return fmt.Errorf("duplicate %s name: %s", kind, agg.Name)
+	}
+	names[agg.Name] = true
+
+	if _, err := agg.BucketSize(); err != nil {
+		return err
+	}
+
+	for _, name := range agg.GroupBy {
+		if _, ok := fields[name]; !ok && name != "level" {
+			return fmt.Errorf("%s %s groups by unknown field: %s", kind, agg.Name, name)
+		}
+	}
+
+	for _, metric := range agg.Metrics {
+		switch metric.Func {
+		case AggregateCount:
+			continue
+		case AggregateSum, AggregateMin, AggregateMax, AggregateAvg, AggregateStddev,
+			AggregateP50, AggregateP90, AggregateP95, AggregateP99:
+		default:
+			return fmt.Errorf("%s %s uses unsupported function: %s", kind, agg.Name, metric.Func)
+		}
+
+		field, ok := fields[metric.Field]
+		if !ok {
+			return fmt.Errorf("%s %s references unknown field: %s", kind, agg.Name, metric.Field)
+		}
+		switch field.Type {
+		case FieldTypeInt, FieldTypeFloat, FieldTypeDuration:
+		default:
+			return fmt.Errorf("%s %s: field %s is not numeric", kind, agg.Name, metric.Field)
+		}
+	}
+
+	return nil
+}
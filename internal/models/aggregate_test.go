@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeZone(t *testing.T) {
+	loc, err := ParseTimeZone("")
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, loc)
+
+	loc, err = ParseTimeZone("+05:30")
+	require.NoError(t, err)
+	_, offset := time.Date(2024, 1, 1, 0, 0, 0, 0, loc).Zone()
+	assert.Equal(t, 5*3600+1800, offset)
+
+	loc, err = ParseTimeZone("America/New_York")
+	require.NoError(t, err)
+	assert.Equal(t, "America/New_York", loc.String())
+
+	for _, name := range []string{"Mars/Olympus", "+25:00", "-8"} {
+		_, err := ParseTimeZone(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	at := time.Date(2024, 3, 14, 17, 30, 0, 0, time.UTC) // 上海时间 3 月 15 日 01:30
+
+	assert.True(t, BucketStart(at, time.Hour, time.UTC).Equal(time.Date(2024, 3, 14, 17, 0, 0, 0, time.UTC)))
+	assert.True(t, BucketStart(at, 24*time.Hour, time.UTC).Equal(time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2024, 3, 15, 0, 0, 0, 0, shanghai), BucketStart(at, 24*time.Hour, shanghai))
+
+	// 偏移不是整小时的时区按当地时间对齐
+	kolkata := time.FixedZone("IST", 5*3600+1800)
+	assert.Equal(t, time.Date(2024, 3, 14, 23, 0, 0, 0, kolkata), BucketStart(at, time.Hour, kolkata))
+
+	// 多天的时间桶在 UTC 下与 time.Truncate 一致
+	assert.True(t, BucketStart(at, 7*24*time.Hour, time.UTC).Equal(at.Truncate(7*24*time.Hour)))
+
+	// 夏令时开始当天从当地零点开始，只有 23 小时
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	start := BucketStart(time.Date(2024, 3, 10, 20, 0, 0, 0, time.UTC), 24*time.Hour, newYork)
+	assert.Equal(t, time.Date(2024, 3, 10, 0, 0, 0, 0, newYork), start)
+	assert.True(t, start.Equal(time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrArchivedSchemaNotFound is returned when a schema has no archive to restore
+var ErrArchivedSchemaNotFound = fmt.Errorf("archived schema not found")
+
+// ErrSchemaExists is returned when restoring a schema whose name is in use again
+var ErrSchemaExists = fmt.Errorf("schema already exists")
+
+// ArchiveSuffix 归档日志表名中原表名与归档时间之间的分隔
+const ArchiveSuffix = "_archived_"
+
+// archiveTimeFormat 归档日志表名中的时间格式（UTC）
+const archiveTimeFormat = "20060102150405"
+
+// ArchivedSchema 软删除后保留的 schema，日志表被重命名为 ArchiveTable，宽限期内可恢复
+type ArchivedSchema struct {
+	Project      string     `json:"project"`
+	Table        string     `json:"table"`
+	ArchiveTable string     `json:"archive_table"` // 归档日志表名，<原表名>_archived_<UTC 时间>
+	Schema       *Schema    `json:"schema"`
+	ArchivedAt   time.Time  `json:"archived_at"`
+	PurgeAt      *time.Time `json:"purge_at,omitempty"` // 宽限期结束后永久删除的时间
+}
+
+// ArchiveTableName 返回日志表 table 在 at 时刻归档后的表名
+func ArchiveTableName(table string, at time.Time) string {
+	return table + ArchiveSuffix + at.UTC().Format(archiveTimeFormat)
+}
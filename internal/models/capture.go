@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HeaderCapture 写入日志时从请求头复制到字段的规则，可以只写请求头名，如 capture_headers: [User-Agent]
+type HeaderCapture struct {
+	Header string `yaml:"header" json:"header"`
+	Field  string `yaml:"field,omitempty" json:"field,omitempty"` // 为空时使用请求头名的小写下划线形式，如 User-Agent 对应 user_agent
+}
+
+// FieldName 返回保存请求头的字段名
+func (h *HeaderCapture) FieldName() string {
+	if h.Field != "" {
+		return h.Field
+	}
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(h.Header)), "-", "_")
+}
+
+// UnmarshalYAML 接受请求头名或包含 header、field 的对象
+func (h *HeaderCapture) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&h.Header)
+	}
+	type plain HeaderCapture
+	return value.Decode((*plain)(h))
+}
+
+// UnmarshalJSON 接受请求头名或包含 header、field 的对象
+func (h *HeaderCapture) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &h.Header); err == nil {
+		return nil
+	}
+	type plain HeaderCapture
+	if err := json.Unmarshal(data, (*plain)(h)); err != nil {
+		return fmt.Errorf("capture_headers entries must be a header name or an object with header and field")
+	}
+	return nil
+}
+
+// validateCaptureHeaders 检查请求头名不为空，目标字段已在 schema 中声明且不重复
+func (s *Schema) validateCaptureHeaders() error {
+	seen := make(map[string]bool, len(s.CaptureHeaders))
+	for _, capture := range s.CaptureHeaders {
+		if strings.TrimSpace(capture.Header) == "" {
+			return fmt.Errorf("capture_headers: header name is required")
+		}
+		field := capture.FieldName()
+		if s.GetField(field) == nil {
+			return fmt.Errorf("capture_headers: field %s for header %s is not defined", field, capture.Header)
+		}
+		if seen[field] {
+			return fmt.Errorf("capture_headers: field %s is captured more than once", field)
+		}
+		seen[field] = true
+	}
+	return nil
+}
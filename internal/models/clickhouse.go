@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ClickHouseOptions ClickHouse 建表参数，其他存储忽略
+type ClickHouseOptions struct {
+	// OrderBy MergeTree 排序键，默认 (timestamp, id)，只在建表时生效
+	OrderBy []string `yaml:"order_by,omitempty" json:"order_by,omitempty"`
+	// TTL 数据过期表达式，格式为 <column> + INTERVAL <n> <unit>，如 timestamp + INTERVAL 30 DAY
+	TTL string `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+	// Codecs 列名到压缩编码的映射，如 timestamp: "Delta, ZSTD(1)"
+	Codecs map[string]string `yaml:"codecs,omitempty" json:"codecs,omitempty"`
+}
+
+// ClickHouseTTL 解析后的 TTL 表达式
+type ClickHouseTTL struct {
+	Column   string
+	Interval int
+	Unit     string // SECOND、MINUTE、HOUR、DAY、WEEK、MONTH、QUARTER 或 YEAR
+}
+
+// ttlPattern TTL 表达式只允许列加固定时间间隔，避免任意 SQL 进入 DDL
+var ttlPattern = regexp.MustCompile(`(?i)^\s*([a-z_][a-z0-9_]*)\s*\+\s*INTERVAL\s+(\d+)\s+(SECOND|MINUTE|HOUR|DAY|WEEK|MONTH|QUARTER|YEAR)\s*$`)
+
+// codecPattern 单个压缩编码，参数只能是整数
+var codecPattern = regexp.MustCompile(`(?i)^(NONE|LZ4|LZ4HC|ZSTD|DELTA|DOUBLEDELTA|GORILLA|T64|FPC)(\(\s*\d+(\s*,\s*\d+)*\s*\))?$`)
+
+// ParseClickHouseTTL 解析 TTL 表达式
+func ParseClickHouseTTL(expr string) (*ClickHouseTTL, error) {
+	m := ttlPattern.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("invalid ttl %q: expected <column> + INTERVAL <n> <unit>", expr)
+	}
+	n, err := strconv.Atoi(m[2])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid ttl %q: interval must be positive", expr)
+	}
+	return &ClickHouseTTL{Column: m[1], Interval: n, Unit: strings.ToUpper(m[3])}, nil
+}
+
+// SplitCodecs 将编码链拆分为单个编码，如 "Delta, ZSTD(1)" 拆为 Delta 与 ZSTD(1)
+func SplitCodecs(value string) ([]string, error) {
+	var codecs []string
+	depth, start := 0, 0
+	for i, r := range value + "," {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth != 0 {
+				continue
+			}
+			codec := strings.TrimSpace(value[start:i])
+			if !codecPattern.MatchString(codec) {
+				return nil, fmt.Errorf("invalid codec %q", codec)
+			}
+			codecs = append(codecs, codec)
+			start = i + 1
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("invalid codec %q: unbalanced parentheses", value)
+	}
+	return codecs, nil
+}
+
+// validateClickHouse 校验排序键、TTL 与编码引用的列
+func (s *Schema) validateClickHouse() error {
+	opts := s.ClickHouse
+	if opts == nil {
+		return nil
+	}
+
+	// column 返回列的字段类型，内置列中只有 timestamp 为时间类型
+	column := func(name string) (FieldType, bool) {
+		switch name {
+		case "id", "project", "table_name":
+			return FieldTypeString, true
+		case "timestamp":
+			return FieldTypeDateTime, true
+		}
+		if field := s.GetField(name); field != nil {
+			return field.Type, true
+		}
+		return "", false
+	}
+
+	seen := make(map[string]bool, len(opts.OrderBy))
+	for _, name := range opts.OrderBy {
+		typ, ok := column(name)
+		if !ok {
+			return fmt.Errorf("clickhouse order_by references unknown column: %s", name)
+		}
+		switch typ {
+		case FieldTypeJSON, FieldTypeRest, FieldTypeObject, FieldTypeArray:
+			return fmt.Errorf("clickhouse order_by column %s has unsortable type %s", name, typ)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate clickhouse order_by column: %s", name)
+		}
+		seen[name] = true
+	}
+
+	if opts.TTL != "" {
+		ttl, err := ParseClickHouseTTL(opts.TTL)
+		if err != nil {
+			return err
+		}
+		typ, ok := column(ttl.Column)
+		if !ok {
+			return fmt.Errorf("clickhouse ttl references unknown column: %s", ttl.Column)
+		}
+		if typ != FieldTypeDateTime && typ != FieldTypeTime {
+			return fmt.Errorf("clickhouse ttl column %s must be a datetime", ttl.Column)
+		}
+	}
+
+	names := make([]string, 0, len(opts.Codecs))
+	for name := range opts.Codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := column(name); !ok {
+			return fmt.Errorf("clickhouse codec references unknown column: %s", name)
+		}
+		if field := s.GetField(name); field != nil && field.Type == FieldTypeArray && field.ItemType == FieldTypeObject {
+			// 对象数组存储为 Nested，由多个子列组成，不能整体指定编码
+			return fmt.Errorf("clickhouse codec cannot be set on nested column: %s", name)
+		}
+		if _, err := SplitCodecs(opts.Codecs[name]); err != nil {
+			return fmt.Errorf("clickhouse codec for column %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClickHouseOptions(t *testing.T) {
+	schema := func(opts *ClickHouseOptions) *Schema {
+		return &Schema{
+			Project: "app",
+			Table:   "requests",
+			Fields: []*Field{
+				{Name: "service", Type: FieldTypeString},
+				{Name: "finished_at", Type: FieldTypeDateTime},
+				{Name: "payload", Type: FieldTypeJSON},
+				{Name: "items", Type: FieldTypeArray, ItemType: FieldTypeObject, Fields: []*Field{{Name: "sku", Type: FieldTypeString}}},
+			},
+			SchemaOptions: SchemaOptions{ClickHouse: opts},
+		}
+	}
+
+	assert.NoError(t, schema(&ClickHouseOptions{
+		OrderBy: []string{"service", "timestamp", "id"},
+		TTL:     "finished_at + INTERVAL 90 DAY",
+		Codecs:  map[string]string{"timestamp": "DoubleDelta, LZ4", "payload": "ZSTD(3)"},
+	}).Validate())
+
+	for name, opts := range map[string]*ClickHouseOptions{
+		"unknown order_by":  {OrderBy: []string{"missing"}},
+		"json order_by":     {OrderBy: []string{"payload"}},
+		"duplicate":         {OrderBy: []string{"service", "service"}},
+		"ttl expression":    {TTL: "timestamp + INTERVAL 1 DAY; DROP TABLE x"},
+		"ttl column type":   {TTL: "service + INTERVAL 1 DAY"},
+		"ttl zero interval": {TTL: "timestamp + INTERVAL 0 DAY"},
+		"unknown codec":     {Codecs: map[string]string{"service": "Brotli"}},
+		"codec injection":   {Codecs: map[string]string{"service": "ZSTD(1)) SETTINGS x=1 --"}},
+		"codec column":      {Codecs: map[string]string{"missing": "ZSTD"}},
+		"nested codec":      {Codecs: map[string]string{"items": "ZSTD"}},
+	} {
+		assert.ErrorIs(t, schema(opts).Validate(), ErrValidation, name)
+	}
+
+	codecs, err := SplitCodecs("Delta(4), FPC(12, 4),ZSTD")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Delta(4)", "FPC(12, 4)", "ZSTD"}, codecs)
+
+	ttl, err := ParseClickHouseTTL("timestamp + interval 2 week")
+	require.NoError(t, err)
+	assert.Equal(t, &ClickHouseTTL{Column: "timestamp", Interval: 2, Unit: "WEEK"}, ttl)
+
+	clone := schema(&ClickHouseOptions{Codecs: map[string]string{"service": "ZSTD"}}).Clone()
+	original := clone.Clone()
+	clone.ClickHouse.Codecs["service"] = "LZ4"
+	assert.Equal(t, "ZSTD", original.ClickHouse.Codecs["service"])
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// patterns 已编译的字段正则，按表达式缓存，schema 每次从存储读取时无需重新编译
+var patterns sync.Map // string -> *regexp.Regexp
+
+// compilePattern 编译并缓存字段正则
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := patterns.LoadOrStore(pattern, re)
+	return actual.(*regexp.Regexp), nil
+}
+
+// HasConstraints 字段是否声明了长度、取值范围或正则约束
+func (f *Field) HasConstraints() bool {
+	return f.MinLength != nil || f.MaxLength != nil || f.MinValue != nil || f.MaxValue != nil || f.Pattern != ""
+}
+
+// validateConstraints 校验约束定义：长度与正则只用于 string，取值范围只用于 int 与 float
+func (f *Field) validateConstraints() error {
+	if f.MinLength != nil || f.MaxLength != nil || f.Pattern != "" {
+		if f.Type != FieldTypeString {
+			return fmt.Errorf("length and pattern constraints require a string field: %s", f.Name)
+		}
+	}
+	if f.MinValue != nil || f.MaxValue != nil {
+		if f.Type != FieldTypeInt && f.Type != FieldTypeFloat {
+			return fmt.Errorf("value constraints require an int or float field: %s", f.Name)
+		}
+	}
+	if (f.MinLength != nil && *f.MinLength < 0) || (f.MaxLength != nil && *f.MaxLength < 0) {
+		return fmt.Errorf("length constraints must not be negative: %s", f.Name)
+	}
+	if f.MinLength != nil && f.MaxLength != nil && *f.MinLength > *f.MaxLength {
+		return fmt.Errorf("min_length is greater than max_length: %s", f.Name)
+	}
+	if f.MinValue != nil && f.MaxValue != nil && *f.MinValue > *f.MaxValue {
+		return fmt.Errorf("min_value is greater than max_value: %s", f.Name)
+	}
+	if f.Pattern != "" {
+		if _, err := compilePattern(f.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern for field %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkConstraints 检查已通过类型校验的值是否满足字段约束，null 不受约束
+func (f *Field) checkConstraints(value interface{}) *FieldError {
+	if value == nil {
+		return nil
+	}
+
+	violation := func(constraint, message string) *FieldError {
+		return &FieldError{
+			Field:        f.Name,
+			Reason:       FieldErrorConstraint,
+			Constraint:   constraint,
+			Expected:     string(f.Type),
+			Received:     value,
+			ReceivedType: fmt.Sprintf("%T", value),
+			Message:      fmt.Sprintf("字段 %s %s", f.Name, message),
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		length := utf8.RuneCountInString(s)
+		if f.MinLength != nil && length < *f.MinLength {
+			return violation("min_length", fmt.Sprintf("长度 %d 小于最小长度 %d", length, *f.MinLength))
+		}
+		if f.MaxLength != nil && length > *f.MaxLength {
+			return violation("max_length", fmt.Sprintf("长度 %d 超过最大长度 %d", length, *f.MaxLength))
+		}
+		if f.Pattern != "" {
+			re, err := compilePattern(f.Pattern)
+			if err != nil || !re.MatchString(s) {
+				return violation("pattern", fmt.Sprintf("不匹配正则 %s", f.Pattern))
+			}
+		}
+		return nil
+	}
+
+	n, ok := toFloat(value)
+	if !ok {
+		return nil
+	}
+	if f.MinValue != nil && n < *f.MinValue {
+		return violation("min_value", fmt.Sprintf("取值 %v 小于最小值 %v", value, formatBound(*f.MinValue)))
+	}
+	if f.MaxValue != nil && n > *f.MaxValue {
+		return violation("max_value", fmt.Sprintf("取值 %v 超过最大值 %v", value, formatBound(*f.MaxValue)))
+	}
+	return nil
+}
+
+// toFloat 将数值转换为 float64 以便与范围比较
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// formatBound 以最短形式输出边界值
+func formatBound(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldConstraints(t *testing.T) {
+	minLen, maxLen := 2, 5
+	minValue, maxValue := 0.0, 100.0
+	schema := &Schema{
+		Project: "test",
+		Table:   "logs",
+		Fields: []*Field{
+			{Name: "code", Type: FieldTypeString, MinLength: &minLen, MaxLength: &maxLen, Pattern: `^\p{Lu}+$`},
+			{Name: "score", Type: FieldTypeFloat, MinValue: &minValue, MaxValue: &maxValue},
+			{Name: "retries", Type: FieldTypeInt, MaxValue: &maxValue},
+		},
+	}
+	require.NoError(t, schema.Validate())
+
+	entry := func(fields map[string]interface{}) *LogEntry {
+		return &LogEntry{Project: "test", Table: "logs", Level: "info", Message: "m", Timestamp: time.Now(), Fields: fields}
+	}
+
+	require.NoError(t, schema.ValidateLogEntry(entry(map[string]interface{}{"code": "ÄB", "score": 99.5})))
+	require.NoError(t, schema.ValidateLogEntry(entry(map[string]interface{}{"code": nil})))
+
+	err := schema.ValidateLogEntry(entry(map[string]interface{}{"code": "TOOLONG", "score": -1.0, "retries": 101}))
+	require.ErrorIs(t, err, ErrValidation)
+	fields := FieldErrors(err)
+	require.Len(t, fields, 3)
+	assert.Equal(t, "max_length", fields[0].Constraint)
+	assert.Equal(t, FieldErrorConstraint, fields[0].Reason)
+	assert.Equal(t, "字段 code 长度 7 超过最大长度 5", fields[0].Message)
+	assert.Equal(t, "min_value", fields[1].Constraint)
+	assert.Equal(t, "字段 retries 取值 101 超过最大值 100", fields[2].Message)
+
+	fields = FieldErrors(schema.ValidateLogEntry(entry(map[string]interface{}{"code": "ab1"})))
+	require.Len(t, fields, 1)
+	assert.Equal(t, "pattern", fields[0].Constraint)
+
+	fields = FieldErrors(schema.ValidateLogEntry(entry(map[string]interface{}{"code": "A"})))
+	require.Len(t, fields, 1)
+	assert.Equal(t, "min_length", fields[0].Constraint)
+}
+
+func TestFieldConstraintDefinitions(t *testing.T) {
+	one, two := 1, 2
+	low, high := 1.0, 0.0
+	for name, field := range map[string]*Field{
+		"bad pattern":      {Name: "f", Type: FieldTypeString, Pattern: "("},
+		"length on int":    {Name: "f", Type: FieldTypeInt, MaxLength: &one},
+		"range on string":  {Name: "f", Type: FieldTypeString, MinValue: &low},
+		"inverted length":  {Name: "f", Type: FieldTypeString, MinLength: &two, MaxLength: &one},
+		"inverted range":   {Name: "f", Type: FieldTypeFloat, MinValue: &low, MaxValue: &high},
+		"default too long": {Name: "f", Type: FieldTypeString, MaxLength: &one, Default: "long"},
+	} {
+		schema := &Schema{Project: "test", Table: "logs", Fields: []*Field{field}}
+		assert.ErrorIs(t, schema.Validate(), ErrValidation, name)
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// DurationNanos 将 duration 字段的值转换为纳秒数，各存储后端统一以 64 位整数纳秒保存时长。
+// 接受 time.Duration、Go 时长字符串（如 1.5s、250ms）以及表示纳秒的整数和整数字符串
+func DurationNanos(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("duration out of range: %d", v)
+		}
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) || v > math.MaxInt64 || v < math.MinInt64 {
+			return 0, fmt.Errorf("duration must be a whole number of nanoseconds: %v", v)
+		}
+		return int64(v), nil
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n, nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %q", v)
+		}
+		return int64(d), nil
+	case []byte:
+		return DurationNanos(string(v))
+	default:
+		return 0, fmt.Errorf("expected duration, got %T", value)
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationNanos(t *testing.T) {
+	for value, want := range map[interface{}]int64{
+		1500 * time.Millisecond: int64(1500 * time.Millisecond),
+		"1.5s":                  int64(1500 * time.Millisecond),
+		"250ms":                 int64(250 * time.Millisecond),
+		"1h2m":                  int64(time.Hour + 2*time.Minute),
+		"1500000000":            1500000000,
+		int64(42):               42,
+		42:                      42,
+		float64(7):              7,
+		uint64(9):               9,
+	} {
+		got, err := DurationNanos(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+	got, err := DurationNanos([]byte("2s"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2*time.Second), got)
+
+	for _, value := range []interface{}{"soon", 1.5, uint64(math.MaxUint64), true, nil} {
+		_, err := DurationNanos(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrValidation is returned when a schema, query or log entry fails validation
+var ErrValidation = errors.New("validation failed")
+
+// 字段校验失败原因
+const (
+	FieldErrorMissing     = "missing"      // 必填字段缺失或为空
+	FieldErrorInvalidType = "invalid_type" // 值与字段类型不符
+	FieldErrorNull        = "null"         // 不允许为 null 的字段收到 null
+	FieldErrorConstraint  = "constraint"   // 违反长度、取值范围或正则约束
+	FieldErrorUnknown     = "unknown"      // strict schema 未定义的字段
+)
+
+// FieldError 单个字段的校验失败信息
+type FieldError struct {
+	Index        *int        `json:"index,omitempty"` // 批量写入时日志在请求中的位置
+	Field        string      `json:"field"`
+	Reason       string      `json:"reason"`
+	Constraint   string      `json:"constraint,omitempty"`    // 违反的约束：min_length、max_length、min_value、max_value、pattern
+	Expected     string      `json:"expected,omitempty"`      // 期望的字段类型
+	Received     interface{} `json:"received"`                // 收到的值，缺失时为 null
+	ReceivedType string      `json:"received_type,omitempty"` // 收到的值的 Go 类型
+	Message      string      `json:"message"`
+}
+
+// Error 返回字段错误描述，批量写入时附带日志位置
+func (e *FieldError) Error() string {
+	if e.Index != nil {
+		return fmt.Sprintf("#%d %s", *e.Index, e.Message)
+	}
+	return e.Message
+}
+
+// missingField 创建必填字段缺失错误
+func missingField(name string, expected FieldType) *FieldError {
+	return &FieldError{
+		Field:    name,
+		Reason:   FieldErrorMissing,
+		Expected: string(expected),
+		Message:  fmt.Sprintf("缺少必填字段: %s", name),
+	}
+}
+
+// nullField 创建不允许为 null 的字段收到 null 的错误
+func nullField(name string, expected FieldType) *FieldError {
+	return &FieldError{
+		Field:    name,
+		Reason:   FieldErrorNull,
+		Expected: string(expected),
+		Message:  fmt.Sprintf("字段 %s 不允许为 null", name),
+	}
+}
+
+// unknownField 创建 strict schema 未定义字段的错误
+func unknownField(name string, value interface{}) *FieldError {
+	return &FieldError{
+		Field:        name,
+		Reason:       FieldErrorUnknown,
+		Received:     value,
+		ReceivedType: fmt.Sprintf("%T", value),
+		Message:      fmt.Sprintf("未定义的字段: %s", name),
+	}
+}
+
+// InvalidField 创建字段类型错误，cause 描述具体原因
+func InvalidField(name string, expected FieldType, value interface{}, cause error) *FieldError {
+	return &FieldError{
+		Field:        name,
+		Reason:       FieldErrorInvalidType,
+		Expected:     string(expected),
+		Received:     value,
+		ReceivedType: fmt.Sprintf("%T", value),
+		Message:      fmt.Sprintf("字段 %s 类型错误: %v", name, cause),
+	}
+}
+
+// ValidationError 校验失败的具体原因，errors.Is(err, ErrValidation) 为真。
+// 日志校验会收集全部字段错误到 Fields，其余校验只包含 Err
+type ValidationError struct {
+	Err    error
+	Fields []*FieldError
+}
+
+// Error 返回原始校验信息，多个字段错误以分号分隔
+func (e *ValidationError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap 同时匹配 ErrValidation、原始错误与各字段错误
+func (e *ValidationError) Unwrap() []error {
+	errs := []error{ErrValidation}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	for _, field := range e.Fields {
+		errs = append(errs, field)
+	}
+	return errs
+}
+
+// NewFieldErrors 将字段错误合并为一个校验错误，没有错误时返回 nil
+func NewFieldErrors(fields []*FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// FieldErrors 返回错误链中的全部字段错误
+func FieldErrors(err error) []*FieldError {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return verr.Fields
+	}
+	return nil
+}
+
+// invalid 将非空错误标记为校验错误
+func invalid(err error) error {
+	if err == nil || errors.Is(err, ErrValidation) {
+		return err
+	}
+	return &ValidationError{Err: err}
+}
@@ -0,0 +1,44 @@
+package models
+
+import "fmt"
+
+// FieldValue 字段的一个取值及其出现次数
+type FieldValue struct {
+	Value interface{} `json:"value"`
+	Count int64       `json:"count"`
+}
+
+// FieldValues 字段在匹配的日志中出现最多的取值，用于构建过滤下拉框
+type FieldValues struct {
+	Project     string       `json:"project"`
+	Table       string       `json:"table"`
+	Field       string       `json:"field"`
+	Total       int64        `json:"total"`       // 字段有值的匹配日志条数
+	Distinct    int64        `json:"distinct"`    // 不同取值的个数
+	Approximate bool         `json:"approximate"` // distinct 是否为近似值
+	Values      []FieldValue `json:"values"`      // 按出现次数倒序，次数相同时按取值升序
+}
+
+// facetTypes 可以统计取值分布的字段类型
+var facetTypes = map[FieldType]bool{
+	FieldTypeString:   true,
+	FieldTypeInt:      true,
+	FieldTypeFloat:    true,
+	FieldTypeBool:     true,
+	FieldTypeDuration: true,
+	FieldTypeIP:       true,
+}
+
+// FacetField 返回可以统计取值分布的字段，字段不存在或不是标量类型时返回 ErrValidation
+func (s *Schema) FacetField(name string) (*Field, error) {
+	for _, field := range s.Fields {
+		if field.Name != name {
+			continue
+		}
+		if !facetTypes[field.Type] {
+			return nil, invalid(fmt.Errorf("field %s of type %s has no distinct values", name, field.Type))
+		}
+		return field, nil
+	}
+	return nil, invalid(fmt.Errorf("unknown field: %s", name))
+}
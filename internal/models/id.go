@@ -0,0 +1,162 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IDStrategy 日志 ID 生成策略
+type IDStrategy string
+
+const (
+	// IDStrategyULID 26 位 Crockford Base32，按时间有序（默认）
+	IDStrategyULID IDStrategy = "ulid"
+	// IDStrategyUUIDv7 RFC 9562 UUIDv7，按时间有序
+	IDStrategyUUIDv7 IDStrategy = "uuidv7"
+	// IDStrategySnowflake 64 位整数的十进制字符串：41 位毫秒时间戳、10 位节点、12 位序号
+	IDStrategySnowflake IDStrategy = "snowflake"
+)
+
+// ParseIDStrategy 解析 ID 策略，空字符串返回默认策略
+func ParseIDStrategy(s string) (IDStrategy, error) {
+	switch st := IDStrategy(s); st {
+	case "":
+		return IDStrategyULID, nil
+	case IDStrategyULID, IDStrategyUUIDv7, IDStrategySnowflake:
+		return st, nil
+	default:
+		return "", fmt.Errorf("unknown id strategy: %s", s)
+	}
+}
+
+// IDGenerator 生成日志 ID，实现需并发安全
+type IDGenerator interface {
+	NewID() string
+}
+
+// NewIDGenerator 创建 ID 生成器，node 仅用于 snowflake（0-1023）
+func NewIDGenerator(strategy IDStrategy, node int64) (IDGenerator, error) {
+	switch strategy {
+	case IDStrategyULID, "":
+		return &ulidGenerator{}, nil
+	case IDStrategyUUIDv7:
+		return uuidV7Generator{}, nil
+	case IDStrategySnowflake:
+		if node < 0 || node > snowflakeMaxNode {
+			return nil, fmt.Errorf("snowflake node must be between 0 and %d, got %d", snowflakeMaxNode, node)
+		}
+		return &snowflakeGenerator{node: node}, nil
+	default:
+		return nil, fmt.Errorf("unknown id strategy: %s", strategy)
+	}
+}
+
+// crockford ULID 使用的 Base32 字母表
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGenerator 同一毫秒内递增随机部分，保证单调有序
+type ulidGenerator struct {
+	mu      sync.Mutex
+	lastMs  uint64
+	entropy [10]byte
+}
+
+// NewID 生成 ULID
+func (g *ulidGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms > g.lastMs {
+		g.lastMs = ms
+		if _, err := rand.Read(g.entropy[:]); err != nil {
+			panic(fmt.Sprintf("读取随机数失败: %v", err))
+		}
+	} else {
+		// 时钟未前进（或回拨）时沿用上次时间戳并递增随机部分
+		for i := len(g.entropy) - 1; i >= 0; i-- {
+			g.entropy[i]++
+			if g.entropy[i] != 0 {
+				break
+			}
+		}
+	}
+
+	var id [16]byte
+	id[0] = byte(g.lastMs >> 40)
+	id[1] = byte(g.lastMs >> 32)
+	binary.BigEndian.PutUint32(id[2:], uint32(g.lastMs))
+	copy(id[6:], g.entropy[:])
+	return encodeULID(id)
+}
+
+// encodeULID 将 128 位按 Crockford Base32 编码为 26 个字符
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// uuidV7Generator 基于 google/uuid 的 UUIDv7 生成器
+type uuidV7Generator struct{}
+
+// NewID 生成 UUIDv7
+func (uuidV7Generator) NewID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeMaxNode  = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+// snowflakeEpoch snowflake 时间戳起点（2024-01-01 UTC）
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// snowflakeGenerator 每个节点每毫秒最多生成 4096 个 ID
+type snowflakeGenerator struct {
+	mu     sync.Mutex
+	node   int64
+	lastMs int64
+	seq    int64
+}
+
+// NewID 生成 snowflake ID
+func (g *snowflakeGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := time.Now().UnixMilli() - snowflakeEpoch
+	if ms < g.lastMs {
+		// 时钟回拨时沿用上次时间戳，避免生成重复 ID
+		ms = g.lastMs
+	}
+	if ms == g.lastMs {
+		g.seq = (g.seq + 1) & snowflakeMaxSeq
+		if g.seq == 0 {
+			// 当前毫秒序号用尽，借用下一毫秒
+			ms++
+		}
+	} else {
+		g.seq = 0
+	}
+	g.lastMs = ms
+
+	id := ms<<(snowflakeNodeBits+snowflakeSeqBits) | g.node<<snowflakeSeqBits | g.seq
+	return strconv.FormatInt(id, 10)
+}
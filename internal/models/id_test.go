@@ -0,0 +1,72 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIDStrategy(t *testing.T) {
+	strategy, err := ParseIDStrategy("")
+	require.NoError(t, err)
+	assert.Equal(t, IDStrategyULID, strategy)
+
+	strategy, err = ParseIDStrategy("snowflake")
+	require.NoError(t, err)
+	assert.Equal(t, IDStrategySnowflake, strategy)
+
+	_, err = ParseIDStrategy("serial")
+	assert.Error(t, err)
+
+	_, err = NewIDGenerator(IDStrategySnowflake, 1024)
+	assert.Error(t, err)
+}
+
+func TestIDGenerators(t *testing.T) {
+	for _, strategy := range []IDStrategy{IDStrategyULID, IDStrategyUUIDv7, IDStrategySnowflake} {
+		t.Run(string(strategy), func(t *testing.T) {
+			gen, err := NewIDGenerator(strategy, 7)
+			require.NoError(t, err)
+
+			ids := make([]string, 10000)
+			seen := make(map[string]bool, len(ids))
+			for i := range ids {
+				ids[i] = gen.NewID()
+				assert.False(t, seen[ids[i]], "duplicate id %s", ids[i])
+				seen[ids[i]] = true
+			}
+
+			switch strategy {
+			case IDStrategyULID:
+				assert.Len(t, ids[0], 26)
+				assert.True(t, sort.StringsAreSorted(ids), "ULIDs should be monotonic")
+			case IDStrategyUUIDv7:
+				u, err := uuid.Parse(ids[0])
+				require.NoError(t, err)
+				assert.Equal(t, uuid.Version(7), u.Version())
+			case IDStrategySnowflake:
+				prev := int64(0)
+				for _, id := range ids {
+					n, err := strconv.ParseInt(id, 10, 64)
+					require.NoError(t, err)
+					assert.Greater(t, n, prev)
+					assert.Equal(t, int64(7), n>>snowflakeSeqBits&snowflakeMaxNode)
+					prev = n
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeULID(t *testing.T) {
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", encodeULID(max))
+	assert.Equal(t, "00000000000000000000000000", encodeULID([16]byte{}))
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MaxIdentifierLength 标识符的最大长度，取 PostgreSQL 的上限（MySQL 为 64）
+const MaxIdentifierLength = 63
+
+// identifierPattern 项目、表、字段与聚合名称允许的字符集
+var identifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// ValidateIdentifier 校验会拼接进表名或列名的名称：只允许小写字母、数字与下划线，
+// 不能以数字开头，长度不超过 MaxIdentifierLength。kind 为 project、table、field 或 aggregate，
+// 失败时返回 ErrValidation
+func ValidateIdentifier(kind, name string) error {
+	return invalid(validateIdentifier(kind, name))
+}
+
+// validateIdentifier 校验单个标识符
+func validateIdentifier(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s name is required", kind)
+	}
+	if len(name) > MaxIdentifierLength {
+		return fmt.Errorf("%s name %q exceeds %d characters", kind, name, MaxIdentifierLength)
+	}
+	if !identifierPattern.MatchString(name) {
+		return fmt.Errorf("invalid %s name %q: only lowercase letters, digits and underscores are allowed, and it must not start with a digit", kind, name)
+	}
+	return nil
+}
+
+// validateTableIdentifiers 校验项目与表名，并确保组合出的日志表名 logs_<project>_<table> 不超长
+func validateTableIdentifiers(project, table string) error {
+	if err := validateIdentifier("project", project); err != nil {
+		return err
+	}
+	if err := validateIdentifier("table", table); err != nil {
+		return err
+	}
+	if name := "logs_" + project + "_" + table; len(name) > MaxIdentifierLength {
+		return fmt.Errorf("log table name %s exceeds %d characters", name, MaxIdentifierLength)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateIdentifier(t *testing.T) {
+	for _, name := range []string{"app", "access_logs", "_internal", "v2"} {
+		assert.NoError(t, ValidateIdentifier("table", name), name)
+	}
+	for _, name := range []string{
+		"", "App", "2fa", "my-app", "a b", "a;drop", `x"y`, "a`b", "../etc", "logs.v1", "naïve",
+		strings.Repeat("a", MaxIdentifierLength+1),
+	} {
+		assert.ErrorIs(t, ValidateIdentifier("table", name), ErrValidation, name)
+	}
+}
+
+func TestSchemaRejectsHostileIdentifiers(t *testing.T) {
+	valid := func() *Schema {
+		return &Schema{
+			Project:       "app",
+			Table:         "requests",
+			Fields:        []*Field{{Name: "service", Type: FieldTypeString}},
+			SchemaOptions: SchemaOptions{Aggregates: []*Aggregate{{Name: "per_minute", Interval: "1m"}}},
+		}
+	}
+	assert.NoError(t, valid().Validate())
+
+	for name, mutate := range map[string]func(*Schema){
+		"project":    func(s *Schema) { s.Project = "app; DROP TABLE schemas" },
+		"table":      func(s *Schema) { s.Table = `requests"--` },
+		"field":      func(s *Schema) { s.Fields[0].Name = "service TEXT); DROP TABLE schemas; --" },
+		"aggregate":  func(s *Schema) { s.Aggregates[0].Name = "per-minute" },
+		"long table": func(s *Schema) { s.Table = strings.Repeat("t", MaxIdentifierLength-len("logs_app_")+1) },
+		"long aggregate": func(s *Schema) {
+			s.Aggregates[0].Name = strings.Repeat("a", MaxIdentifierLength-len("cq_app_requests_")+1)
+		},
+	} {
+		schema := valid()
+		mutate(schema)
+		assert.ErrorIs(t, schema.Validate(), ErrValidation, name)
+	}
+
+	_, err := SchemaFromYAML([]byte("project: app\ntable: \"x; DROP TABLE y\"\nfields:\n  - name: service\n    type: string\n"))
+	assert.ErrorIs(t, err, ErrValidation)
+}
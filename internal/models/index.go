@@ -0,0 +1,174 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// IndexType 字段索引类型
+type IndexType string
+
+const (
+	IndexBTree IndexType = "btree" // 默认，等值与范围查询
+	IndexBRIN  IndexType = "brin"  // 块范围索引，适合随写入时间单调增长的列
+	IndexGIN   IndexType = "gin"   // 倒排索引，适合 JSONB 与数组的包含查询
+	IndexHash  IndexType = "hash"  // 只支持等值查询
+)
+
+// IndexSpec 字段索引定义，设置后字段视为已索引。
+// 索引类型与表达式只在 PostgreSQL 上生效，其他存储按 indexed: true 为列建立默认索引
+type IndexSpec struct {
+	Type IndexType `yaml:"type,omitempty" json:"type,omitempty"`
+	// Expression 索引表达式，只支持 lower(<field>)、upper(<field>)、
+	// <field>->'<key>' 与 <field>->>'<key>'，为空时索引整列
+	Expression string `yaml:"expression,omitempty" json:"expression,omitempty"`
+}
+
+// IndexExpression 解析后的索引表达式
+type IndexExpression struct {
+	Func     string // lower 或 upper
+	Operator string // -> 或 ->>
+	Key      string // JSON 键
+}
+
+var (
+	// funcExpressionPattern lower(<field>) 或 upper(<field>)
+	funcExpressionPattern = regexp.MustCompile(`(?i)^\s*(lower|upper)\s*\(\s*([a-z_][a-z0-9_]*)\s*\)\s*$`)
+	// pathExpressionPattern <field>->'<key>' 或 <field>->>'<key>'
+	pathExpressionPattern = regexp.MustCompile(`^\s*([a-z_][a-z0-9_]*)\s*(->>?)\s*'([A-Za-z0-9_.-]+)'\s*$`)
+)
+
+// IsIndexed 字段是否需要建立索引
+func (f *Field) IsIndexed() bool {
+	return f.Indexed || f.Index != nil
+}
+
+// IndexedOn 字段在指定存储上是否建立索引。gin 索引只有 PostgreSQL 支持，JSON 路径表达式只有
+// PostgreSQL 与 SQLite（json_extract 表达式索引）支持，其他存储不为这类字段建立索引
+func (f *Field) IndexedOn(dialect string) bool {
+	if !f.IsIndexed() {
+		return false
+	}
+	if dialect == "postgres" {
+		return true
+	}
+	if f.IndexType() == IndexGIN {
+		return false
+	}
+	expr, err := f.IndexExpression()
+	if err != nil || expr == nil || expr.Operator == "" {
+		return true
+	}
+	return dialect == "sqlite" && f.IndexType() == IndexBTree
+}
+
+// jsonPathIndex 是否为 JSON 路径表达式上的 btree 索引，SQLite 以 json_extract 表达式索引实现
+func (f *Field) jsonPathIndex() bool {
+	expr, err := f.IndexExpression()
+	return err == nil && expr != nil && expr.Operator != "" && f.IndexType() == IndexBTree
+}
+
+// IndexType 返回字段的索引类型，未指定时为 btree
+func (f *Field) IndexType() IndexType {
+	if f.Index == nil || f.Index.Type == "" {
+		return IndexBTree
+	}
+	return f.Index.Type
+}
+
+// IndexExpression 解析字段的索引表达式，未设置表达式时返回 nil
+func (f *Field) IndexExpression() (*IndexExpression, error) {
+	if f.Index == nil || f.Index.Expression == "" {
+		return nil, nil
+	}
+	expr := f.Index.Expression
+	var name string
+	var parsed IndexExpression
+	if m := funcExpressionPattern.FindStringSubmatch(expr); m != nil {
+		name = m[2]
+		parsed.Func = strings.ToLower(m[1])
+	} else if m := pathExpressionPattern.FindStringSubmatch(expr); m != nil {
+		name = m[1]
+		parsed.Operator = m[2]
+		parsed.Key = m[3]
+	} else {
+		return nil, fmt.Errorf("invalid index expression %q: expected lower(%s), upper(%s), %s->'key' or %s->>'key'",
+			expr, f.Name, f.Name, f.Name, f.Name)
+	}
+	if name != f.Name {
+		return nil, fmt.Errorf("index expression %q must reference field %s", expr, f.Name)
+	}
+	return &parsed, nil
+}
+
+// validateIndex 校验索引类型与表达式是否适用于字段类型
+func (f *Field) validateIndex() error {
+	if f.Index == nil {
+		return nil
+	}
+	switch f.IndexType() {
+	case IndexBTree, IndexBRIN, IndexGIN, IndexHash:
+	default:
+		return fmt.Errorf("invalid index type for field %s: %s", f.Name, f.Index.Type)
+	}
+
+	expr, err := f.IndexExpression()
+	if err != nil {
+		return err
+	}
+	jsonb := f.Type == FieldTypeJSON || f.Type == FieldTypeRest || f.Type == FieldTypeObject || f.Type == FieldTypeArray
+	switch {
+	case expr != nil && expr.Func != "" && f.Type != FieldTypeString:
+		return fmt.Errorf("index expression %s(...) requires a string field: %s", expr.Func, f.Name)
+	case expr != nil && expr.Operator != "" && !jsonb:
+		return fmt.Errorf("index expression %s requires a json field: %s", expr.Operator, f.Name)
+	}
+
+	if f.IndexType() == IndexGIN {
+		// GIN 只能用于 JSONB 值，->> 取出的是文本
+		if !jsonb || (expr != nil && expr.Operator != "->") {
+			return fmt.Errorf("gin index requires a json, object or array value: %s", f.Name)
+		}
+	} else if jsonb && expr == nil {
+		return fmt.Errorf("json field %s can only be indexed with a gin index or a path expression", f.Name)
+	}
+	return nil
+}
+
+// PostgresIndexTarget 返回 PostgreSQL CREATE INDEX ... ON <table> 之后的索引方法与索引列，
+// 如 USING gin ("payload") 或 USING btree (lower("email"))
+func (f *Field) PostgresIndexTarget() string {
+	column := quoteName("postgres", f.Name)
+	// 表达式已在 schema 校验时检查，这里解析失败时退回索引整列
+	if expr, err := f.IndexExpression(); err == nil && expr != nil {
+		if expr.Func != "" {
+			column = fmt.Sprintf("%s(%s)", expr.Func, column)
+		} else {
+			column = fmt.Sprintf("(%s%s'%s')", column, expr.Operator, expr.Key)
+		}
+	}
+	return fmt.Sprintf("USING %s (%s)", f.IndexType(), column)
+}
+
+// SQLiteIndexTarget 返回 SQLite CREATE INDEX ... ON <table> 之后的索引列，JSON 路径表达式
+// 转换为 json_extract，如 (json_extract("payload", '$."user"'))，与 JSON 路径过滤条件的写法一致才能命中索引。
+// lower、upper 表达式在 SQLite 上忽略，索引整列
+func (f *Field) SQLiteIndexTarget() string {
+	column := quoteName("sqlite", f.Name)
+	if f.jsonPathIndex() {
+		expr, _ := f.IndexExpression()
+		return fmt.Sprintf("(json_extract(%s, '%s'))", column, SQLiteJSONPath(expr.Key))
+	}
+	return "(" + column + ")"
+}
+
+// SQLiteJSONPath 返回 SQLite json_extract 使用的路径，每个键都加引号，如 $."headers"."host"。
+// 键已由路径或索引表达式的格式限制为字母、数字、_、- 与 .，可以直接写入 SQL 字面量
+func SQLiteJSONPath(keys ...string) string {
+	path := "$"
+	for _, key := range keys {
+		path += `."` + key + `"`
+	}
+	return path
+}
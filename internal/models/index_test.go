@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldIndexSpec(t *testing.T) {
+	schema, err := SchemaFromYAML([]byte(`
+project: app
+table: events
+fields:
+  - name: occurred_at
+    type: datetime
+    index: {type: brin}
+  - name: email
+    type: string
+    index: {expression: lower(email)}
+  - name: payload
+    type: json
+    index: {type: gin}
+  - name: attrs
+    type: object
+    fields: [{name: region, type: string}]
+    index: {type: gin, expression: "attrs->'region'"}
+  - name: service
+    type: string
+    indexed: true
+`))
+	require.NoError(t, err)
+
+	occurred := schema.GetField("occurred_at")
+	assert.True(t, occurred.IsIndexed())
+	assert.Equal(t, `USING brin ("occurred_at")`, occurred.PostgresIndexTarget())
+	assert.Equal(t, `USING btree (lower("email"))`, schema.GetField("email").PostgresIndexTarget())
+	assert.Equal(t, `USING gin ("payload")`, schema.GetField("payload").PostgresIndexTarget())
+	assert.Equal(t, `USING gin (("attrs"->'region'))`, schema.GetField("attrs").PostgresIndexTarget())
+	assert.Equal(t, `USING btree ("service")`, schema.GetField("service").PostgresIndexTarget())
+
+	assert.True(t, schema.GetField("payload").IndexedOn("postgres"))
+	assert.False(t, schema.GetField("payload").IndexedOn("mysql"))
+	assert.True(t, schema.GetField("email").IndexedOn("sqlite"))
+	assert.True(t, occurred.IndexedOn("clickhouse"))
+	assert.Len(t, schema.CompatibilityWarnings("mysql"), 4)
+	assert.Empty(t, schema.CompatibilityWarnings("postgres"))
+}
+
+func TestFieldIndexSpecValidation(t *testing.T) {
+	for name, field := range map[string]*Field{
+		"unknown type":     {Name: "a", Type: FieldTypeString, Index: &IndexSpec{Type: "bitmap"}},
+		"gin on string":    {Name: "a", Type: FieldTypeString, Index: &IndexSpec{Type: IndexGIN}},
+		"gin on text path": {Name: "a", Type: FieldTypeJSON, Index: &IndexSpec{Type: IndexGIN, Expression: "a->>'k'"}},
+		"btree on json":    {Name: "a", Type: FieldTypeJSON, Index: &IndexSpec{Type: IndexBTree}},
+		"lower on int":     {Name: "a", Type: FieldTypeInt, Index: &IndexSpec{Expression: "lower(a)"}},
+		"other column":     {Name: "a", Type: FieldTypeString, Index: &IndexSpec{Expression: "lower(b)"}},
+		"injection":        {Name: "a", Type: FieldTypeString, Index: &IndexSpec{Expression: "lower(a)); DROP TABLE schemas; --"}},
+		"quoted key":       {Name: "a", Type: FieldTypeJSON, Index: &IndexSpec{Expression: "a->>'k'') --'"}},
+		"array btree":      {Name: "a", Type: FieldTypeArray, ItemType: FieldTypeString, Indexed: true},
+	} {
+		schema := &Schema{Project: "app", Table: "events", Fields: []*Field{field}}
+		assert.ErrorIs(t, schema.Validate(), ErrValidation, name)
+	}
+
+	schema := &Schema{Project: "app", Table: "events", Fields: []*Field{
+		{Name: "a", Type: FieldTypeJSON, Index: &IndexSpec{Expression: "a->>'user.id'"}},
+		{Name: "b", Type: FieldTypeArray, ItemType: FieldTypeString, Index: &IndexSpec{Type: IndexGIN}},
+	}}
+	assert.NoError(t, schema.Validate())
+	assert.True(t, schema.Fields[0].IndexedOn("postgres"))
+	assert.True(t, schema.Fields[0].IndexedOn("sqlite"))
+	assert.False(t, schema.Fields[0].IndexedOn("mysql"))
+	assert.Equal(t, `(json_extract("a", '$."user.id"'))`, schema.Fields[0].SQLiteIndexTarget())
+	assert.False(t, schema.Fields[1].IndexedOn("sqlite"))
+	assert.Equal(t, `("b")`, schema.Fields[1].SQLiteIndexTarget())
+	assert.Equal(t, `$."headers"."host"`, SQLiteJSONPath("headers", "host"))
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InferredRestField InferSchema 生成的 Rest 字段名，收集推断时未出现或名称不合法的字段
+const InferredRestField = "rest"
+
+// InferSchema 根据日志样本推断 schema：level 与 message 为必填字符串字段，
+// 其余字段按样本中出现的值推断类型，名称不是合法标识符或与内置列、tags 冲突的字段交给 Rest 字段
+func InferSchema(project, table string, logs []*LogEntry) *Schema {
+	types := make(map[string]FieldType)
+	for _, log := range logs {
+		for name, value := range log.Fields {
+			typ, ok := inferType(value)
+			if !ok {
+				continue
+			}
+			if prev, seen := types[name]; seen {
+				typ = mergeTypes(prev, typ)
+			}
+			types[name] = typ
+		}
+	}
+
+	schema := &Schema{
+		Project: project,
+		Table:   table,
+		Fields: []*Field{
+			{Name: "level", Type: FieldTypeString, Required: true, Indexed: true},
+			{Name: "message", Type: FieldTypeString, Required: true},
+		},
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		if name == "level" || name == "message" || name == InferredRestField || name == TagsColumn || name == IngestTimeColumn ||
+			isReservedColumn(name) || validateIdentifier("field", name) != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		schema.Fields = append(schema.Fields, &Field{Name: name, Type: types[name]})
+	}
+	schema.Fields = append(schema.Fields, &Field{Name: InferredRestField, Type: FieldTypeRest})
+	return schema
+}
+
+// NewFields 推断日志字段中 schema 尚未定义的字段，供 auto_evolve 自动添加列。
+// 值为 null、名称不是合法标识符或与内置列、tags 冲突的字段不会返回，结果按名称排序
+func (s *Schema) NewFields(values map[string]interface{}) []*Field {
+	var fields []*Field
+	for name, value := range values {
+		if s.GetField(name) != nil || name == TagsColumn || name == IngestTimeColumn || isReservedColumn(name) || validateIdentifier("field", name) != nil {
+			continue
+		}
+		if typ, ok := inferType(value); ok {
+			fields = append(fields, &Field{Name: name, Type: typ})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
+
+// inferType 推断单个值的字段类型，nil 不参与推断
+func inferType(value interface{}) (FieldType, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		if _, err := time.Parse(time.RFC3339, v); err == nil {
+			return FieldTypeDateTime, true
+		}
+		if net.ParseIP(v) != nil {
+			return FieldTypeIP, true
+		}
+		return FieldTypeString, true
+	case bool:
+		return FieldTypeBool, true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return FieldTypeInt, true
+	case float32, float64:
+		return FieldTypeFloat, true
+	case time.Time:
+		return FieldTypeDateTime, true
+	default:
+		return FieldTypeJSON, true
+	}
+}
+
+// mergeTypes 合并同一字段在不同样本中的类型：int 与 float 合并为 float，
+// datetime、ip 与 string 合并为 string，其他不一致的类型合并为 json
+func mergeTypes(a, b FieldType) FieldType {
+	switch {
+	case a == b:
+		return a
+	case a == FieldTypeInt && b == FieldTypeFloat, a == FieldTypeFloat && b == FieldTypeInt:
+		return FieldTypeFloat
+	case isStringType(a) && isStringType(b):
+		return FieldTypeString
+	default:
+		return FieldTypeJSON
+	}
+}
+
+// isStringType 以字符串表示的字段类型
+func isStringType(t FieldType) bool {
+	return t == FieldTypeString || t == FieldTypeDateTime || t == FieldTypeIP
+}
+
+// indexHintNames 通常用于过滤的字段名
+var indexHintNames = map[string]bool{
+	"service": true, "host": true, "hostname": true, "env": true, "environment": true,
+	"status": true, "status_code": true, "method": true, "path": true, "route": true,
+	"ip": true, "client_ip": true, "error_code": true, "code": true,
+}
+
+// minCardinalitySamples 按取值分布建议索引所需的最少样本数
+const minCardinalitySamples = 4
+
+// SuggestIndexes 为推断出的 schema 建议索引，将建议的字段标记为 Indexed 并返回原因。
+// 建议规则（message 除外）：名称以 _id 结尾或是常用过滤字段（service、host、status 等）的字符串、整数与 IP 字段；
+// 样本不少于 minCardinalitySamples 条时，不同取值不超过样本数一半的字符串字段（低基数，适合等值过滤）
+func SuggestIndexes(schema *Schema, logs []*LogEntry) map[string]string {
+	reasons := make(map[string]string)
+	for _, field := range schema.Fields {
+		if field.Indexed || field.Name == "message" {
+			continue
+		}
+		switch field.Type {
+		case FieldTypeString, FieldTypeInt, FieldTypeIP:
+		default:
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(field.Name, "_id"):
+			reasons[field.Name] = "identifier field"
+		case indexHintNames[field.Name]:
+			reasons[field.Name] = "commonly filtered field"
+		case field.Type == FieldTypeString:
+			distinct, seen := make(map[string]bool), 0
+			for _, log := range logs {
+				if value, ok := log.Fields[field.Name].(string); ok {
+					distinct[value] = true
+					seen++
+				}
+			}
+			if seen >= minCardinalitySamples && len(distinct)*2 <= seen {
+				reasons[field.Name] = fmt.Sprintf("low cardinality (%d distinct values in %d samples)", len(distinct), seen)
+			}
+		}
+		if _, ok := reasons[field.Name]; ok {
+			field.Indexed = true
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferSchema(t *testing.T) {
+	logs := []*LogEntry{
+		{Fields: map[string]interface{}{
+			"level": "info", "message": "a", "service": "api", "latency": int64(3), "ok": true,
+			"at": time.Now().Format(time.RFC3339Nano), "user": map[string]interface{}{"id": 1},
+			"Caller": "x.go:1", "req.id": "r1", "id": "reserved", "maybe": nil,
+		}},
+		{Fields: map[string]interface{}{"latency": 2.5, "at": "yesterday", "ok": "yes"}},
+	}
+
+	schema := InferSchema("app", "events", logs)
+	require.NoError(t, schema.Validate())
+
+	types := make(map[string]FieldType)
+	for _, field := range schema.Fields {
+		types[field.Name] = field.Type
+	}
+	assert.Equal(t, map[string]FieldType{
+		"level":   FieldTypeString,
+		"message": FieldTypeString,
+		"service": FieldTypeString,
+		"latency": FieldTypeFloat,
+		"ok":      FieldTypeJSON,
+		"at":      FieldTypeString,
+		"user":    FieldTypeJSON,
+		"rest":    FieldTypeRest,
+	}, types)
+	assert.Equal(t, "level", schema.Fields[0].Name)
+	assert.Equal(t, "rest", schema.Fields[len(schema.Fields)-1].Name)
+}
+
+func TestSuggestIndexes(t *testing.T) {
+	var logs []*LogEntry
+	for i := 0; i < 6; i++ {
+		logs = append(logs, &LogEntry{Fields: map[string]interface{}{
+			"level":    "info",
+			"message":  "m",
+			"region":   []string{"eu", "us"}[i%2],
+			"path_raw": "/items/" + string(rune('a'+i)),
+			"user_id":  int64(i),
+			"host":     "10.0.0.1",
+			"tags":     map[string]interface{}{"k": "v"},
+		}})
+	}
+
+	schema := InferSchema("app", "events", logs)
+	reasons := SuggestIndexes(schema, logs)
+	require.NoError(t, schema.Validate())
+
+	indexed := make(map[string]bool)
+	for _, field := range schema.Fields {
+		indexed[field.Name] = field.Indexed
+		assert.NotEqual(t, TagsColumn, field.Name)
+	}
+	assert.True(t, indexed["level"])
+	assert.True(t, indexed["region"])
+	assert.True(t, indexed["user_id"])
+	assert.True(t, indexed["host"])
+	assert.False(t, indexed["path_raw"])
+	assert.False(t, indexed["message"])
+	assert.Equal(t, "identifier field", reasons["user_id"])
+	assert.Equal(t, "commonly filtered field", reasons["host"])
+	assert.Contains(t, reasons["region"], "low cardinality")
+	assert.NotContains(t, reasons, "level")
+}
+
+func TestSchemaNewFields(t *testing.T) {
+	schema := &Schema{Fields: []*Field{{Name: "message", Type: FieldTypeString}}}
+	fields := schema.NewFields(map[string]interface{}{
+		"message": "m", "status": int64(200), "ok": true, "tags": "x", "id": "reserved",
+		"req.id": "r1", "empty": nil, "meta": map[string]interface{}{"k": "v"},
+	})
+	assert.Equal(t, []*Field{
+		{Name: "meta", Type: FieldTypeJSON},
+		{Name: "ok", Type: FieldTypeBool},
+		{Name: "status", Type: FieldTypeInt},
+	}, fields)
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ParseIP 解析 IP 字段的值，接受字符串、net.IP 与 netip.Addr，
+// IPv4 映射的 IPv6 地址还原为 IPv4
+func ParseIP(value interface{}) (netip.Addr, error) {
+	var addr netip.Addr
+	switch v := value.(type) {
+	case string:
+		parsed, err := netip.ParseAddr(v)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("无效的 IP 地址: %q", v)
+		}
+		addr = parsed
+	case netip.Addr:
+		addr = v
+	case net.IP:
+		parsed, ok := netip.AddrFromSlice(v)
+		if !ok {
+			return netip.Addr{}, fmt.Errorf("无效的 IP 地址: %v", v)
+		}
+		addr = parsed
+	default:
+		return netip.Addr{}, fmt.Errorf("期望 ip 类型，实际为 %T", value)
+	}
+	if !addr.IsValid() {
+		return netip.Addr{}, fmt.Errorf("无效的 IP 地址: %v", value)
+	}
+	// 不保留 IPv6 zone，列类型无法存储
+	return addr.Unmap().WithZone(""), nil
+}
+
+// ParseIPFilter 解析 IP 字段的过滤值：单个地址或 CIDR 网段（如 10.0.0.0/8），
+// 单个地址返回只包含该地址的网段
+func ParseIPFilter(value interface{}) (netip.Prefix, error) {
+	if s, ok := value.(string); ok && strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("无效的 CIDR 网段: %q", s)
+		}
+		if prefix.Addr().Is4In6() {
+			// ::ffff:10.0.0.0/104 等价于 10.0.0.0/8
+			if prefix.Bits() < 96 {
+				return netip.Prefix{}, fmt.Errorf("无效的 CIDR 网段: %q", s)
+			}
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := ParseIP(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIPFilter(t *testing.T) {
+	addr, err := ParseIP("::ffff:10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", addr.String(), "IPv4-mapped addresses are unmapped")
+
+	prefix, err := ParseIPFilter("10.1.2.3/8")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", prefix.String())
+
+	prefix, err = ParseIPFilter("::ffff:192.168.0.0/112")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.0.0/16", prefix.String())
+
+	prefix, err = ParseIPFilter("2001:db8::1")
+	require.NoError(t, err)
+	assert.Equal(t, 128, prefix.Bits())
+
+	for _, value := range []interface{}{"10.0.0.0/33", "not-an-ip", 42} {
+		_, err := ParseIPFilter(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestIPField(t *testing.T) {
+	schema := &Schema{
+		Project: "edge",
+		Table:   "requests",
+		Fields:  []*Field{{Name: "client_ip", Type: FieldTypeIP, Required: true}},
+	}
+	require.NoError(t, schema.Validate())
+
+	entry := func(ip interface{}) *LogEntry {
+		return &LogEntry{Project: "edge", Table: "requests", Level: "info", Message: "m", Timestamp: time.Now(),
+			Fields: map[string]interface{}{"client_ip": ip}}
+	}
+	assert.NoError(t, schema.ValidateLogEntry(entry("192.0.2.1")))
+	assert.NoError(t, schema.ValidateLogEntry(entry("2001:db8::1")))
+
+	err := schema.ValidateLogEntry(entry("192.0.2.256"))
+	require.ErrorIs(t, err, ErrValidation)
+	assert.Equal(t, FieldErrorInvalidType, FieldErrors(err)[0].Reason)
+
+	assert.NoError(t, (&Query{Filter: map[string]interface{}{"client_ip": "10.0.0.0/8"}}).Validate(schema, BaseColumns))
+	assert.NoError(t, (&Query{Filter: map[string]interface{}{"client_ip": "${net}"}}).Validate(schema, BaseColumns))
+	assert.ErrorIs(t, (&Query{Filter: map[string]interface{}{"client_ip": "10.0.0.0/99"}}).Validate(schema, BaseColumns), ErrValidation)
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrIssueNotFound is returned when an issue is not found
+var ErrIssueNotFound = fmt.Errorf("issue not found")
+
+// IssueStatus 问题的处理状态
+type IssueStatus string
+
+const (
+	IssueUnresolved IssueStatus = "unresolved"
+	IssueResolved   IssueStatus = "resolved" // 再次出现时自动变回 unresolved
+	IssueIgnored    IssueStatus = "ignored"  // 再次出现时只累计次数
+)
+
+// Validate 检查状态取值
+func (s IssueStatus) Validate() error {
+	switch s {
+	case IssueUnresolved, IssueResolved, IssueIgnored:
+		return nil
+	}
+	return invalid(fmt.Errorf("unsupported issue status: %q", s))
+}
+
+// Issue 按指纹归并的同一类错误日志
+type Issue struct {
+	Project     string      `json:"project"`
+	Table       string      `json:"table"`
+	Fingerprint string      `json:"fingerprint"`
+	Title       string      `json:"title"`             // 去除变量部分后的消息模板
+	Culprit     string      `json:"culprit,omitempty"` // 堆栈的首个调用帧
+	Level       string      `json:"level"`
+	Message     string      `json:"message"` // 最近一次出现的原始消息
+	Count       int64       `json:"count"`
+	Status      IssueStatus `json:"status"`
+	FirstSeen   time.Time   `json:"first_seen"`
+	LastSeen    time.Time   `json:"last_seen"`
+}
+
+// IssueFilter 列出问题的条件，零值字段不参与过滤
+type IssueFilter struct {
+	Project string
+	Table   string
+	Status  IssueStatus
+	Limit   int // 默认 100，按最近出现时间倒序
+	Offset  int
+}
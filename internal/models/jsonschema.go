@@ -0,0 +1,335 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// JSONSchemaDialect 导出的 JSON Schema 版本
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema schema 与 JSON Schema 文档互相转换时使用的关键字。
+// x- 开头的扩展关键字保存 JSON Schema 无法表达的属性，导入其他工具生成的文档时可以省略
+type JSONSchema struct {
+	Schema      string         `json:"$schema,omitempty"`
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Type        JSONSchemaType `json:"type,omitempty"`
+	Format      string         `json:"format,omitempty"`
+	AnyOf       []*JSONSchema  `json:"anyOf,omitempty"`
+
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	// AdditionalProperties 为 true 或 schema 对象时，未声明的属性收集到 Rest 字段；为 false 时对应 strict schema
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema `json:"items,omitempty"`
+
+	MinLength  *int        `json:"minLength,omitempty"`
+	MaxLength  *int        `json:"maxLength,omitempty"`
+	Minimum    *float64    `json:"minimum,omitempty"`
+	Maximum    *float64    `json:"maximum,omitempty"`
+	Pattern    string      `json:"pattern,omitempty"`
+	Default    interface{} `json:"default,omitempty"`
+	Deprecated bool        `json:"deprecated,omitempty"`
+
+	Project   string         `json:"x-project,omitempty"`
+	Table     string         `json:"x-table,omitempty"`
+	Version   string         `json:"x-version,omitempty"`
+	RestField string         `json:"x-rest-field,omitempty"`
+	Options   *SchemaOptions `json:"x-options,omitempty"`
+	FieldType FieldType      `json:"x-field-type,omitempty"` // 由 type 与 format 无法确定的字段类型
+	Indexed   bool           `json:"x-indexed,omitempty"`
+	Index     *IndexSpec     `json:"x-index,omitempty"`
+	Nullable  *bool          `json:"x-nullable,omitempty"`
+}
+
+// JSONSchemaType JSON Schema 的 type 关键字，可以是单个类型或类型数组
+type JSONSchemaType []string
+
+// MarshalJSON 单个类型编码为字符串
+func (t JSONSchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON 接受字符串或字符串数组
+func (t *JSONSchemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = JSONSchemaType{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = list
+	return nil
+}
+
+// ToJSONSchema 将 schema 转换为 JSON Schema 文档，描述一条日志写入请求的结构
+func (s *Schema) ToJSONSchema() *JSONSchema {
+	doc := &JSONSchema{
+		Schema:      JSONSchemaDialect,
+		Title:       s.Project + "." + s.Table,
+		Description: s.Description,
+		Type:        JSONSchemaType{"object"},
+		Project:     s.Project,
+		Table:       s.Table,
+		Version:     s.Version,
+	}
+	doc.Properties, doc.Required = fieldsToJSONSchema(s.Fields)
+	if rest := s.RestField(); rest != nil {
+		doc.AdditionalProperties = true
+		doc.RestField = rest.Name
+	} else if s.Strict {
+		doc.AdditionalProperties = false
+	}
+	if s.SchemaOptions.Aggregates != nil || s.Rollups != nil || s.Retention != "" || s.ClickHouse != nil || s.AutoEvolve || s.Strict || s.Timestamps != nil || s.CaptureHeaders != nil {
+		opts := s.SchemaOptions
+		doc.Options = &opts
+	}
+	return doc
+}
+
+// fieldsToJSONSchema 转换字段列表，Rest 字段不作为属性导出
+func fieldsToJSONSchema(fields []*Field) (map[string]*JSONSchema, []string) {
+	var properties map[string]*JSONSchema
+	var required []string
+	for _, field := range fields {
+		if field.Type == FieldTypeRest {
+			continue
+		}
+		if properties == nil {
+			properties = make(map[string]*JSONSchema)
+		}
+		prop := typeToJSONSchema(field.Type, field.ItemType, field.Fields)
+		prop.Description = field.Description
+		prop.Default = field.Default
+		prop.Deprecated = field.Deprecated
+		prop.Indexed = field.Indexed
+		prop.Index = field.Index
+		prop.Nullable = field.Nullable
+		prop.MinLength = field.MinLength
+		prop.MaxLength = field.MaxLength
+		prop.Minimum = field.MinValue
+		prop.Maximum = field.MaxValue
+		prop.Pattern = field.Pattern
+		properties[field.Name] = prop
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+	return properties, required
+}
+
+// typeToJSONSchema 将字段类型转换为 JSON Schema 的 type 与 format
+func typeToJSONSchema(typ, itemType FieldType, fields []*Field) *JSONSchema {
+	switch typ {
+	case FieldTypeString:
+		return &JSONSchema{Type: JSONSchemaType{"string"}}
+	case FieldTypeInt:
+		return &JSONSchema{Type: JSONSchemaType{"integer"}}
+	case FieldTypeFloat:
+		return &JSONSchema{Type: JSONSchemaType{"number"}}
+	case FieldTypeBool:
+		return &JSONSchema{Type: JSONSchemaType{"boolean"}}
+	case FieldTypeDateTime:
+		return &JSONSchema{Type: JSONSchemaType{"string"}, Format: "date-time"}
+	case FieldTypeTime:
+		return &JSONSchema{Type: JSONSchemaType{"string"}, Format: "time"}
+	case FieldTypeDuration:
+		// 接受 Go 持续时间字符串或秒数
+		return &JSONSchema{Type: JSONSchemaType{"string", "number"}, FieldType: FieldTypeDuration}
+	case FieldTypeIP:
+		return &JSONSchema{
+			Type:      JSONSchemaType{"string"},
+			AnyOf:     []*JSONSchema{{Format: "ipv4"}, {Format: "ipv6"}},
+			FieldType: FieldTypeIP,
+		}
+	case FieldTypeObject:
+		prop := &JSONSchema{Type: JSONSchemaType{"object"}}
+		prop.Properties, prop.Required = fieldsToJSONSchema(fields)
+		return prop
+	case FieldTypeArray:
+		return &JSONSchema{Type: JSONSchemaType{"array"}, Items: typeToJSONSchema(itemType, "", fields)}
+	default:
+		// json 字段接受任意值
+		return &JSONSchema{FieldType: FieldTypeJSON}
+	}
+}
+
+// ToSchema 将 JSON Schema 文档转换为 schema，project 与 table 为空时使用文档中的 x-project 与 x-table。
+// 属性按名称排序，未声明类型或类型无法对应的属性转换为 json 字段
+func (j *JSONSchema) ToSchema(project, table string) (*Schema, error) {
+	if project == "" {
+		project = j.Project
+	}
+	if table == "" {
+		table = j.Table
+	}
+	if project == "" || table == "" {
+		return nil, fmt.Errorf("project and table are required")
+	}
+	if types := j.Type.withoutNull(); len(types) > 0 && (len(types) != 1 || types[0] != "object") {
+		return nil, fmt.Errorf("root schema must be an object, got %v", []string(j.Type))
+	}
+
+	fields, err := fieldsFromJSONSchema("", j.Properties, j.Required)
+	if err != nil {
+		return nil, err
+	}
+	if allowsAdditional(j.AdditionalProperties) {
+		name := j.RestField
+		if name == "" {
+			name = InferredRestField
+		}
+		fields = append(fields, &Field{Name: name, Type: FieldTypeRest})
+	}
+
+	schema := &Schema{
+		Project:     project,
+		Table:       table,
+		Description: j.Description,
+		Version:     j.Version,
+		Fields:      fields,
+	}
+	if j.Options != nil {
+		schema.SchemaOptions = *j.Options
+	}
+	// additionalProperties: false 的文档拒绝未声明的属性
+	if allowed, ok := j.AdditionalProperties.(bool); ok && !allowed {
+		schema.Strict = true
+	}
+	return schema, nil
+}
+
+// fieldsFromJSONSchema 转换 properties 与 required，prefix 用于错误信息中的字段路径
+func fieldsFromJSONSchema(prefix string, properties map[string]*JSONSchema, required []string) ([]*Field, error) {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	isRequired := make(map[string]bool, len(required))
+	for _, name := range required {
+		if _, ok := properties[name]; !ok {
+			return nil, fmt.Errorf("required property %s%s is not defined", prefix, name)
+		}
+		isRequired[name] = true
+	}
+
+	fields := make([]*Field, 0, len(names))
+	for _, name := range names {
+		prop := properties[name]
+		if prop == nil {
+			prop = &JSONSchema{}
+		}
+		field := &Field{
+			Name:        name,
+			Required:    isRequired[name],
+			Description: prop.Description,
+			Default:     prop.Default,
+			Deprecated:  prop.Deprecated,
+			Indexed:     prop.Indexed,
+			Index:       prop.Index,
+			Nullable:    prop.Nullable,
+			MinLength:   prop.MinLength,
+			MaxLength:   prop.MaxLength,
+			MinValue:    prop.Minimum,
+			MaxValue:    prop.Maximum,
+			Pattern:     prop.Pattern,
+		}
+		var err error
+		field.Type, field.ItemType, field.Fields, err = typeFromJSONSchema(prefix+name, prop)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// typeFromJSONSchema 根据 x-field-type、type 与 format 确定字段类型
+func typeFromJSONSchema(path string, prop *JSONSchema) (FieldType, FieldType, []*Field, error) {
+	switch prop.FieldType {
+	case "":
+	case FieldTypeObject, FieldTypeArray:
+		// 复杂类型的结构由 properties 与 items 决定
+	case FieldTypeString, FieldTypeInt, FieldTypeFloat, FieldTypeBool, FieldTypeDateTime, FieldTypeTime,
+		FieldTypeDuration, FieldTypeJSON, FieldTypeIP:
+		return prop.FieldType, "", nil, nil
+	default:
+		return "", "", nil, fmt.Errorf("unsupported x-field-type for %s: %s", path, prop.FieldType)
+	}
+
+	types := prop.Type.withoutNull()
+	if len(types) != 1 {
+		return FieldTypeJSON, "", nil, nil
+	}
+	switch types[0] {
+	case "string":
+		switch {
+		case prop.Format == "date-time":
+			return FieldTypeDateTime, "", nil, nil
+		case prop.Format == "time":
+			return FieldTypeTime, "", nil, nil
+		case prop.Format == "ipv4" || prop.Format == "ipv6":
+			return FieldTypeIP, "", nil, nil
+		}
+		return FieldTypeString, "", nil, nil
+	case "integer":
+		return FieldTypeInt, "", nil, nil
+	case "number":
+		return FieldTypeFloat, "", nil, nil
+	case "boolean":
+		return FieldTypeBool, "", nil, nil
+	case "object":
+		if len(prop.Properties) == 0 {
+			return FieldTypeJSON, "", nil, nil
+		}
+		fields, err := fieldsFromJSONSchema(path+".", prop.Properties, prop.Required)
+		return FieldTypeObject, "", fields, err
+	case "array":
+		if prop.Items == nil {
+			return FieldTypeJSON, "", nil, nil
+		}
+		itemType, _, fields, err := typeFromJSONSchema(path+"[]", prop.Items)
+		if err != nil {
+			return "", "", nil, err
+		}
+		if itemType == FieldTypeJSON || itemType == FieldTypeArray {
+			return FieldTypeJSON, "", nil, nil
+		}
+		return FieldTypeArray, itemType, fields, nil
+	default:
+		return FieldTypeJSON, "", nil, nil
+	}
+}
+
+// withoutNull 去掉 null 类型，可空性由字段的 nullable 设置决定
+func (t JSONSchemaType) withoutNull() []string {
+	var types []string
+	for _, typ := range t {
+		if typ != "null" {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// allowsAdditional 判断 additionalProperties 是否允许未声明的属性，未设置时视为不允许
+func allowsAdditional(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case map[string]interface{}:
+		return true
+	default:
+		return false
+	}
+}
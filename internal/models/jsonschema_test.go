@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchemaRoundTrip(t *testing.T) {
+	maxLen, minValue, notNull := 16, 0.0, false
+	schema := &Schema{
+		Project:     "app",
+		Table:       "requests",
+		Description: "HTTP requests",
+		Fields: []*Field{
+			{Name: "client", Type: FieldTypeIP, Indexed: true},
+			{Name: "items", Type: FieldTypeArray, ItemType: FieldTypeObject, Fields: []*Field{
+				{Name: "sku", Type: FieldTypeString, Required: true},
+			}},
+			{Name: "labels", Type: FieldTypeArray, ItemType: FieldTypeString},
+			{Name: "latency", Type: FieldTypeDuration},
+			{Name: "level", Type: FieldTypeString, Required: true, MaxLength: &maxLen},
+			{Name: "message", Type: FieldTypeString, Required: true},
+			{Name: "meta", Type: FieldTypeJSON},
+			{Name: "status", Type: FieldTypeInt, MinValue: &minValue, Default: float64(200), Nullable: &notNull},
+			{Name: "at", Type: FieldTypeDateTime, Deprecated: true},
+			{Name: "extra", Type: FieldTypeRest},
+		},
+		SchemaOptions: SchemaOptions{Retention: "30d"},
+	}
+
+	data, err := json.Marshal(schema.ToJSONSchema())
+	require.NoError(t, err)
+	var doc JSONSchema
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, JSONSchemaDialect, doc.Schema)
+	assert.Equal(t, JSONSchemaType{"string"}, doc.Properties["client"].Type)
+	assert.Equal(t, "date-time", doc.Properties["at"].Format)
+	assert.Equal(t, []string{"level", "message"}, doc.Required)
+	assert.Equal(t, true, doc.AdditionalProperties)
+
+	imported, err := doc.ToSchema("", "")
+	require.NoError(t, err)
+	require.NoError(t, imported.Validate())
+	assert.Equal(t, "app", imported.Project)
+	assert.Equal(t, "requests", imported.Table)
+	assert.Equal(t, "30d", imported.Retention)
+	// 属性按名称排序，Rest 字段放在最后
+	expected := append([]*Field{schema.Fields[8]}, schema.Fields[:8]...)
+	expected = append(expected, schema.Fields[9])
+	assert.Equal(t, expected, imported.Fields)
+}
+
+func TestJSONSchemaImport(t *testing.T) {
+	var doc JSONSchema
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["message"],
+		"properties": {
+			"message": {"type": "string", "minLength": 1},
+			"level": {"type": ["string", "null"]},
+			"addr": {"type": "string", "format": "ipv6"},
+			"count": {"type": "integer", "maximum": 10},
+			"ok": {"type": "boolean"},
+			"any": {},
+			"mixed": {"type": ["string", "number"]},
+			"ctx": {"type": "object"},
+			"user": {"type": "object", "properties": {"Name": {"type": "string"}}}
+		}
+	}`), &doc))
+
+	schema, err := doc.ToSchema("app", "events")
+	require.NoError(t, err)
+	require.NoError(t, schema.Validate())
+	types := make(map[string]FieldType)
+	for _, field := range schema.Fields {
+		types[field.Name] = field.Type
+	}
+	assert.Equal(t, map[string]FieldType{
+		"message": FieldTypeString,
+		"level":   FieldTypeString,
+		"addr":    FieldTypeIP,
+		"count":   FieldTypeInt,
+		"ok":      FieldTypeBool,
+		"any":     FieldTypeJSON,
+		"mixed":   FieldTypeJSON,
+		"ctx":     FieldTypeJSON,
+		"user":    FieldTypeObject,
+	}, types)
+	assert.True(t, schema.GetField("message").Required)
+	assert.Nil(t, schema.RestField())
+
+	_, err = (&JSONSchema{Type: JSONSchemaType{"array"}}).ToSchema("app", "events")
+	assert.Error(t, err)
+	_, err = (&JSONSchema{Required: []string{"missing"}}).ToSchema("app", "events")
+	assert.Error(t, err)
+	_, err = (&JSONSchema{}).ToSchema("", "events")
+	assert.Error(t, err)
+}
+
+func TestJSONSchemaStrict(t *testing.T) {
+	schema := &Schema{
+		Project:       "app",
+		Table:         "events",
+		Fields:        []*Field{{Name: "user", Type: FieldTypeString}},
+		SchemaOptions: SchemaOptions{Strict: true},
+	}
+	doc := schema.ToJSONSchema()
+	assert.Equal(t, false, doc.AdditionalProperties)
+
+	var imported JSONSchema
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "object", "x-project": "app", "x-table": "events",
+		"properties": {"user": {"type": "string"}}, "additionalProperties": false}`), &imported))
+	got, err := imported.ToSchema("", "")
+	require.NoError(t, err)
+	assert.True(t, got.Strict)
+	assert.Nil(t, got.RestField())
+}
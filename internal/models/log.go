@@ -8,7 +8,7 @@ import (
 
 // LogEntry 日志条目
 type LogEntry struct {
-	ID        int                    `json:"id"`
+	ID        string                 `json:"id,omitempty"`
 	Project   string                 `json:"project"`
 	Table     string                 `json:"table"`
 	Level     string                 `json:"level"`
@@ -17,8 +17,14 @@ type LogEntry struct {
 	IP        string                 `json:"ip"`
 	Fields    map[string]interface{} `json:"fields"`
 	Tags      map[string]string      `json:"tags"`
+
+	// IngestTime 服务端接收日志的时间，首次校验时填充，保存在 ingest_time 列
+	IngestTime time.Time `json:"ingest_time,omitempty"`
 }
 
+// TagsColumn 保存 LogEntry.Tags 的内置列名
+const TagsColumn = "tags"
+
 // LogRequest 表示接收日志的请求结构
 type LogRequest struct {
 	Project   string                 `json:"project" binding:"required"`
@@ -81,7 +87,7 @@ func (l *LogEntry) ValidateFields(schema *Schema) error {
 	for _, field := range schema.Fields {
 		if field.Required {
 			if _, exists := l.Fields[field.Name]; !exists {
-				return fmt.Errorf("required field missing: %s", field.Name)
+				return invalid(fmt.Errorf("required field missing: %s", field.Name))
 			}
 		}
 	}
@@ -104,7 +110,7 @@ func (l *LogEntry) ValidateFields(schema *Schema) error {
 
 		// 验证字段类型
 		if err := validateFieldType(value, fieldDef.Type); err != nil {
-			return fmt.Errorf("invalid field type for %s: %v", name, err)
+			return invalid(fmt.Errorf("invalid field type for %s: %v", name, err))
 		}
 	}
 
@@ -161,16 +167,18 @@ func validateFieldType(value interface{}, fieldType FieldType) error {
 		}
 		return fmt.Errorf("expected time, got %T", value)
 	case FieldTypeDuration:
-		_, ok := value.(int64)
-		if ok {
+		switch value.(type) {
+		case int64, time.Duration:
 			return nil
-		}
-		_, ok = value.(string)
-		if ok {
+		case string:
 			// 可以进一步用 time.ParseDuration 校验
 			return nil
 		}
 		return fmt.Errorf("expected duration, got %T", value)
+	case FieldTypeIP:
+		if _, err := ParseIP(value); err != nil {
+			return err
+		}
 	case FieldTypeJSON:
 		// 对于 JSON 类型，我们只验证它是否可以序列化为 JSON
 		if _, err := json.Marshal(value); err != nil {
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+)
+
+// ErrMutationLimit is returned when a log delete or update matches more entries than allowed
+var ErrMutationLimit = fmt.Errorf("too many matching log entries")
+
+// LogFilter 删除或修改日志时选择日志的条件，语义与 Query 的 filter、tags 相同，至少需要一个条件
+type LogFilter struct {
+	Filter map[string]interface{} `json:"filter,omitempty"`
+	Tags   map[string]string      `json:"tags,omitempty"`
+}
+
+// LogDelete 按过滤条件删除日志的请求
+type LogDelete struct {
+	LogFilter
+	DryRun bool `json:"dry_run,omitempty"` // 为 true 时只返回匹配的条数，不删除
+}
+
+// LogUpdate 按过滤条件修改日志字段的请求，常用于脱敏：值为 null 时清空字段，也可替换为固定文本
+type LogUpdate struct {
+	LogFilter
+	Set    map[string]interface{} `json:"set"`
+	DryRun bool                   `json:"dry_run,omitempty"` // 为 true 时只返回匹配的条数，不修改
+}
+
+// Validate 检查至少有一个过滤条件且引用的列均存在于日志表中，失败时返回 ErrValidation；baseColumns 同 Query.Validate
+func (f *LogFilter) Validate(schema *Schema, baseColumns []string) error {
+	if len(f.Filter) == 0 && len(f.Tags) == 0 {
+		return invalid(fmt.Errorf("filter or tags is required"))
+	}
+	q := &Query{Filter: f.Filter, Tags: f.Tags}
+	if params := q.Params(); len(params) > 0 {
+		return invalid(fmt.Errorf("template parameters are not allowed: %v", params))
+	}
+	return q.Validate(schema, baseColumns)
+}
+
+// Validate 检查过滤条件与要修改的字段：只能修改 schema 中定义的字段，
+// 值需符合字段类型与约束，不允许为 null 的字段不能清空；baseColumns 同 Query.Validate
+func (u *LogUpdate) Validate(schema *Schema, baseColumns []string) error {
+	if err := u.LogFilter.Validate(schema, baseColumns); err != nil {
+		return err
+	}
+	if len(u.Set) == 0 {
+		return invalid(fmt.Errorf("set must contain at least one field"))
+	}
+
+	var errs []*FieldError
+	for name, value := range u.Set {
+		field := schema.GetField(name)
+		if field == nil {
+			return invalid(fmt.Errorf("field %s cannot be updated", name))
+		}
+		if value == nil {
+			if !field.IsNullable() {
+				errs = append(errs, nullField(name, field.Type))
+			}
+			continue
+		}
+		errs = append(errs, field.validateValue(name, value)...)
+	}
+	return NewFieldErrors(errs)
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogUpdateValidate(t *testing.T) {
+	notNull := false
+	schema := &Schema{
+		Project: "app",
+		Table:   "events",
+		Fields: []*Field{
+			{Name: "email", Type: FieldTypeString},
+			{Name: "user", Type: FieldTypeString, Nullable: &notNull},
+			{Name: "age", Type: FieldTypeInt},
+		},
+	}
+	filter := LogFilter{Filter: map[string]interface{}{"user": "alice"}}
+
+	require.NoError(t, (&LogUpdate{LogFilter: filter, Set: map[string]interface{}{"email": nil, "user": "anonymous"}}).Validate(schema, BaseColumns))
+
+	for name, update := range map[string]*LogUpdate{
+		"no filter":      {Set: map[string]interface{}{"email": nil}},
+		"unknown filter": {LogFilter: LogFilter{Filter: map[string]interface{}{"missing": 1}}, Set: map[string]interface{}{"email": nil}},
+		"template":       {LogFilter: LogFilter{Filter: map[string]interface{}{"user": "${user}"}}, Set: map[string]interface{}{"email": nil}},
+		"empty set":      {LogFilter: filter},
+		"built-in":       {LogFilter: filter, Set: map[string]interface{}{"timestamp": nil}},
+		"not nullable":   {LogFilter: filter, Set: map[string]interface{}{"user": nil}},
+		"wrong type":     {LogFilter: filter, Set: map[string]interface{}{"age": "old"}},
+	} {
+		assert.ErrorIs(t, update.Validate(schema, BaseColumns), ErrValidation, name)
+	}
+}
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// pathSegmentPattern 嵌套路径中的单个键，键会嵌入 JSON 路径或 JSON 文档，需限制字符集
+var pathSegmentPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// IsNested 字段是否为对象或数组类型
+func (f *Field) IsNested() bool {
+	return f.Type == FieldTypeObject || f.Type == FieldTypeArray
+}
+
+// ItemField 返回数组元素的字段定义，对象元素的子字段沿用数组的 Fields
+func (f *Field) ItemField() *Field {
+	return &Field{Name: f.Name, Type: f.ItemType, Fields: f.Fields}
+}
+
+// validateValue 按字段类型校验值，对象与数组递归校验子字段与元素。
+// path 为值在日志中的位置，如 request.headers.host、items[0].sku，用作 FieldError.Field
+func (f *Field) validateValue(path string, value interface{}) []*FieldError {
+	switch f.Type {
+	case FieldTypeObject:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []*FieldError{InvalidField(path, f.Type, value, fmt.Errorf("期望 object 类型，实际为 %T", value))}
+		}
+		var errs []*FieldError
+		for _, sub := range f.Fields {
+			subPath := path + "." + sub.Name
+			v, exists := obj[sub.Name]
+			switch {
+			case !exists && sub.Default != nil:
+				obj[sub.Name] = sub.Default
+			case !exists:
+				if sub.Required && !sub.Deprecated {
+					errs = append(errs, missingField(subPath, sub.Type))
+				}
+			case v == nil:
+				if !sub.IsNullable() {
+					errs = append(errs, nullField(subPath, sub.Type))
+				}
+			default:
+				errs = append(errs, sub.validateValue(subPath, v)...)
+			}
+		}
+		return errs
+	case FieldTypeArray:
+		items, ok := sliceItems(value)
+		if !ok {
+			return []*FieldError{InvalidField(path, f.Type, value, fmt.Errorf("期望 array 类型，实际为 %T", value))}
+		}
+		item := f.ItemField()
+		var errs []*FieldError
+		for i, v := range items {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if v == nil {
+				errs = append(errs, nullField(itemPath, f.ItemType))
+				continue
+			}
+			errs = append(errs, item.validateValue(itemPath, v)...)
+		}
+		return errs
+	}
+
+	if err := (&Schema{}).validateFieldValue(f.Type, value); err != nil {
+		return []*FieldError{InvalidField(path, f.Type, value, err)}
+	}
+	if fe := f.checkConstraints(value); fe != nil {
+		fe.Field = path
+		return []*FieldError{fe}
+	}
+	return nil
+}
+
+// sliceItems 将任意切片（[]byte 除外）展开为元素列表
+func sliceItems(value interface{}) ([]interface{}, bool) {
+	if items, ok := value.([]interface{}); ok {
+		return items, true
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
+
+// validateNestedDefinition 校验对象子字段与数组元素定义
+func validateNestedDefinition(field *Field) error {
+	if field.IsIndexed() && field.IndexType() != IndexGIN {
+		return fmt.Errorf("object and array fields can only have a gin index: %s", field.Name)
+	}
+
+	if field.Type == FieldTypeArray {
+		if field.ItemType == "" {
+			return fmt.Errorf("array field %s must specify item_type", field.Name)
+		}
+		switch field.ItemType {
+		case FieldTypeString, FieldTypeInt, FieldTypeFloat, FieldTypeBool, FieldTypeDateTime,
+			FieldTypeJSON, FieldTypeRest:
+			return nil
+		case FieldTypeObject:
+			// 对象元素的子字段定义在数组的 fields 中
+		default:
+			return fmt.Errorf("invalid array item type for field %s: %s", field.Name, field.ItemType)
+		}
+	}
+
+	if len(field.Fields) == 0 {
+		return fmt.Errorf("object field %s must have sub-fields", field.Name)
+	}
+	subFieldNames := make(map[string]bool)
+	for _, subField := range field.Fields {
+		if !pathSegmentPattern.MatchString(subField.Name) {
+			return fmt.Errorf("in field %s: invalid sub-field name: %q", field.Name, subField.Name)
+		}
+		if subField.Index != nil {
+			return fmt.Errorf("in field %s: sub-field %s cannot be indexed", field.Name, subField.Name)
+		}
+		if err := validateField(subField, subFieldNames); err != nil {
+			return fmt.Errorf("in field %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// FieldPath 查询中引用的字段路径，如 request.headers.host 或 items.sku
+type FieldPath struct {
+	Field *Field   // 顶层字段
+	Path  []string // 顶层字段之后的键，为空时引用整个字段
+}
+
+// Nested 路径是否需要按嵌套方式查询：引用对象内部的键或数组字段
+func (p *FieldPath) Nested() bool {
+	return len(p.Path) > 0 || p.Field.Type == FieldTypeArray
+}
+
+// Leaf 返回路径末端的字段定义，json 与 rest 字段内部的键没有定义时返回 nil
+func (p *FieldPath) Leaf() *Field {
+	field := p.Field
+	if field.Type == FieldTypeArray {
+		field = field.ItemField()
+	}
+	for _, key := range p.Path {
+		field = subField(field, key)
+		if field == nil {
+			return nil
+		}
+	}
+	return field
+}
+
+// subField 按名称查找子字段
+func subField(field *Field, name string) *Field {
+	for _, sub := range field.Fields {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// ResolvePath 解析以 . 分隔的字段路径。路径可以进入 object 的子字段、
+// 对象数组元素的子字段以及 json、rest 字段内部的任意键，但不能穿过嵌套数组
+func (s *Schema) ResolvePath(name string) (*FieldPath, error) {
+	parts := strings.Split(name, ".")
+	var root *Field
+	for _, field := range s.Fields {
+		if field.Name == parts[0] {
+			root = field
+			break
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("unknown field: %s", parts[0])
+	}
+
+	current := root
+	if root.Type == FieldTypeArray && len(parts) > 1 {
+		current = root.ItemField()
+	}
+	for _, key := range parts[1:] {
+		if !pathSegmentPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid path segment %q in %s", key, name)
+		}
+		switch current.Type {
+		case FieldTypeJSON, FieldTypeRest:
+			// 内部结构不受 schema 约束，后续键均可访问
+			continue
+		case FieldTypeObject:
+			current = subField(current, key)
+			if current == nil {
+				return nil, fmt.Errorf("unknown field: %s", name)
+			}
+			if current.Type == FieldTypeArray {
+				return nil, fmt.Errorf("nested arrays are not supported in query paths: %s", name)
+			}
+		default:
+			return nil, fmt.Errorf("field %s of type %s has no sub-fields", name, current.Type)
+		}
+	}
+	return &FieldPath{Field: root, Path: parts[1:]}, nil
+}
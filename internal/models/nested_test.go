@@ -0,0 +1,113 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nestedSchema() *Schema {
+	return &Schema{
+		Project: "shop",
+		Table:   "orders",
+		Fields: []*Field{
+			{Name: "request", Type: FieldTypeObject, Fields: []*Field{
+				{Name: "method", Type: FieldTypeString, Required: true},
+				{Name: "retries", Type: FieldTypeInt, Default: 0},
+				{Name: "headers", Type: FieldTypeJSON},
+			}},
+			{Name: "labels", Type: FieldTypeArray, ItemType: FieldTypeString},
+			{Name: "items", Type: FieldTypeArray, ItemType: FieldTypeObject, Fields: []*Field{
+				{Name: "sku", Type: FieldTypeString, Required: true},
+				{Name: "qty", Type: FieldTypeInt},
+			}},
+		},
+	}
+}
+
+func TestNestedFieldValidation(t *testing.T) {
+	schema := nestedSchema()
+	require.NoError(t, schema.Validate())
+
+	entry := func(fields map[string]interface{}) *LogEntry {
+		return &LogEntry{Project: "shop", Table: "orders", Level: "info", Message: "m", Timestamp: time.Now(), Fields: fields}
+	}
+
+	ok := entry(map[string]interface{}{
+		"request": map[string]interface{}{"method": "GET"},
+		"labels":  []string{"a", "b"},
+		"items":   []interface{}{map[string]interface{}{"sku": "A-1", "qty": 2.0}},
+	})
+	require.NoError(t, schema.ValidateLogEntry(ok))
+	assert.Equal(t, 0, ok.Fields["request"].(map[string]interface{})["retries"], "sub-field default is applied")
+
+	err := schema.ValidateLogEntry(entry(map[string]interface{}{
+		"request": map[string]interface{}{"retries": "x"},
+		"labels":  []interface{}{"a", 1.0, nil},
+		"items":   []interface{}{map[string]interface{}{"sku": "A-1"}, map[string]interface{}{"qty": 1.0}, "bad"},
+	}))
+	require.ErrorIs(t, err, ErrValidation)
+	reasons := make(map[string]string)
+	for _, fe := range FieldErrors(err) {
+		reasons[fe.Field] = fe.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"request.method":  FieldErrorMissing,
+		"request.retries": FieldErrorInvalidType,
+		"labels[1]":       FieldErrorInvalidType,
+		"labels[2]":       FieldErrorNull,
+		"items[1].sku":    FieldErrorMissing,
+		"items[2]":        FieldErrorInvalidType,
+	}, reasons)
+
+	err = schema.ValidateLogEntry(entry(map[string]interface{}{"request": "GET", "labels": "a"}))
+	require.Len(t, FieldErrors(err), 2)
+}
+
+func TestNestedFieldDefinitions(t *testing.T) {
+	for name, field := range map[string]*Field{
+		"missing item_type":  {Name: "tags", Type: FieldTypeArray},
+		"object items":       {Name: "items", Type: FieldTypeArray, ItemType: FieldTypeObject},
+		"empty object":       {Name: "request", Type: FieldTypeObject},
+		"indexed":            {Name: "labels", Type: FieldTypeArray, ItemType: FieldTypeString, Indexed: true},
+		"bad sub-field name": {Name: "request", Type: FieldTypeObject, Fields: []*Field{{Name: "a.b", Type: FieldTypeString}}},
+		"bad default":        {Name: "labels", Type: FieldTypeArray, ItemType: FieldTypeInt, Default: []interface{}{"x"}},
+	} {
+		schema := &Schema{Project: "p", Table: "t", Fields: []*Field{field}}
+		assert.ErrorIs(t, schema.Validate(), ErrValidation, name)
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	schema := nestedSchema()
+
+	path, err := schema.ResolvePath("request.method")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"method"}, path.Path)
+	assert.True(t, path.Nested())
+	assert.Equal(t, FieldTypeString, path.Leaf().Type)
+
+	path, err = schema.ResolvePath("request.headers.host")
+	require.NoError(t, err, "any key inside a json sub-field")
+	assert.Nil(t, path.Leaf())
+
+	path, err = schema.ResolvePath("items.sku")
+	require.NoError(t, err)
+	assert.Equal(t, "items", path.Field.Name)
+
+	path, err = schema.ResolvePath("labels")
+	require.NoError(t, err)
+	assert.True(t, path.Nested(), "array fields are matched by membership")
+
+	for _, name := range []string{"request.missing", "labels.x", "request.method.x", "request.he'aders", "unknown.x"} {
+		_, err := schema.ResolvePath(name)
+		assert.Error(t, err, name)
+	}
+
+	q := &Query{Filter: map[string]interface{}{"items.sku": "A-1", "request.method": "GET"}}
+	assert.NoError(t, q.Validate(schema, BaseColumns))
+	q = &Query{Filter: map[string]interface{}{"items.price": 1}}
+	assert.ErrorIs(t, q.Validate(schema, BaseColumns), ErrValidation)
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PatchOpType schema 局部更新操作类型
+type PatchOpType string
+
+const (
+	PatchAddField       PatchOpType = "add_field"
+	PatchDeprecateField PatchOpType = "deprecate_field"
+	PatchSetRetention   PatchOpType = "set_retention"
+	PatchSetIndex       PatchOpType = "set_index"
+)
+
+// PatchOp 单个 schema 局部更新操作
+type PatchOp struct {
+	Op        PatchOpType `json:"op"`
+	Field     *Field      `json:"field,omitempty"`     // add_field
+	Name      string      `json:"name,omitempty"`      // deprecate_field, set_index
+	Indexed   *bool       `json:"indexed,omitempty"`   // set_index
+	Retention string      `json:"retention,omitempty"` // set_retention，空字符串表示不过期
+}
+
+// SchemaPatch schema 局部更新请求
+type SchemaPatch struct {
+	Operations []PatchOp `json:"operations"`
+}
+
+// ApplyPatch 依次应用局部更新操作，任一操作失败时 schema 保持不变
+func (s *Schema) ApplyPatch(patch *SchemaPatch) error {
+	if len(patch.Operations) == 0 {
+		return invalid(fmt.Errorf("at least one operation is required"))
+	}
+
+	clone := s.Clone()
+	for i, op := range patch.Operations {
+		if err := clone.applyOp(op); err != nil {
+			return invalid(fmt.Errorf("operation %d (%s): %w", i, op.Op, err))
+		}
+	}
+	if err := clone.Validate(); err != nil {
+		return err
+	}
+
+	*s = *clone
+	return nil
+}
+
+// applyOp 应用单个操作
+func (s *Schema) applyOp(op PatchOp) error {
+	switch op.Op {
+	case PatchAddField:
+		if op.Field == nil {
+			return fmt.Errorf("field is required")
+		}
+		if s.GetField(op.Field.Name) != nil {
+			return fmt.Errorf("field already exists: %s", op.Field.Name)
+		}
+		if op.Field.Required {
+			// 已有数据不包含新字段，因此新字段不能为必填
+			return fmt.Errorf("new field %s cannot be required", op.Field.Name)
+		}
+		s.Fields = append(s.Fields, op.Field)
+	case PatchDeprecateField:
+		field := s.GetField(op.Name)
+		if field == nil {
+			return fmt.Errorf("field not found: %s", op.Name)
+		}
+		field.Deprecated = true
+		field.Required = false
+	case PatchSetRetention:
+		if op.Retention != "" {
+			if _, err := ParseRetention(op.Retention); err != nil {
+				return err
+			}
+		}
+		s.Retention = op.Retention
+	case PatchSetIndex:
+		field := s.GetField(op.Name)
+		if field == nil {
+			return fmt.Errorf("field not found: %s", op.Name)
+		}
+		if op.Indexed == nil {
+			return fmt.Errorf("indexed is required")
+		}
+		field.Indexed = *op.Indexed
+		if !field.Indexed {
+			field.Index = nil
+		}
+	default:
+		return fmt.Errorf("unsupported operation: %s", op.Op)
+	}
+	return nil
+}
+
+// GetField 按名称获取字段定义
+func (s *Schema) GetField(name string) *Field {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// RestField 返回 schema 的 Rest 字段，不存在时返回 nil
+func (s *Schema) RestField() *Field {
+	for _, field := range s.Fields {
+		if field.Type == FieldTypeRest {
+			return field
+		}
+	}
+	return nil
+}
+
+// Clone 深拷贝 schema 的字段与配置，避免修改共享实例
+func (s *Schema) Clone() *Schema {
+	clone := *s
+	clone.Fields = make([]*Field, len(s.Fields))
+	for i, field := range s.Fields {
+		f := *field
+		clone.Fields[i] = &f
+	}
+	clone.Aggregates = make([]*Aggregate, len(s.Aggregates))
+	for i, agg := range s.Aggregates {
+		a := *agg
+		clone.Aggregates[i] = &a
+	}
+	if len(clone.Aggregates) == 0 {
+		clone.Aggregates = nil
+	}
+	clone.Rollups = nil
+	for _, rollup := range s.Rollups {
+		r := *rollup
+		clone.Rollups = append(clone.Rollups, &r)
+	}
+	if s.ClickHouse != nil {
+		opts := *s.ClickHouse
+		opts.OrderBy = append([]string(nil), s.ClickHouse.OrderBy...)
+		if s.ClickHouse.Codecs != nil {
+			opts.Codecs = make(map[string]string, len(s.ClickHouse.Codecs))
+			for name, codec := range s.ClickHouse.Codecs {
+				opts.Codecs[name] = codec
+			}
+		}
+		clone.ClickHouse = &opts
+	}
+	return &clone
+}
+
+// ParseRetention 解析保留期限，支持 Go duration 以及 d（天）后缀
+func ParseRetention(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid retention: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid retention: %s", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPatchTestSchema() *Schema {
+	return &Schema{
+		Project: "test",
+		Table:   "logs",
+		Fields: []*Field{
+			{Name: "user_id", Type: FieldTypeString, Required: true},
+			{Name: "action", Type: FieldTypeString},
+		},
+	}
+}
+
+func TestSchemaApplyPatch(t *testing.T) {
+	schema := newPatchTestSchema()
+	indexed := true
+
+	err := schema.ApplyPatch(&SchemaPatch{Operations: []PatchOp{
+		{Op: PatchAddField, Field: &Field{Name: "duration", Type: FieldTypeDuration}},
+		{Op: PatchDeprecateField, Name: "user_id"},
+		{Op: PatchSetIndex, Name: "action", Indexed: &indexed},
+		{Op: PatchSetRetention, Retention: "30d"},
+	}})
+	require.NoError(t, err)
+
+	require.Len(t, schema.Fields, 3)
+	assert.Equal(t, "duration", schema.Fields[2].Name)
+	assert.True(t, schema.GetField("user_id").Deprecated)
+	assert.False(t, schema.GetField("user_id").Required)
+	assert.True(t, schema.GetField("action").Indexed)
+	assert.Equal(t, "30d", schema.Retention)
+
+	retention, err := ParseRetention(schema.Retention)
+	require.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, retention)
+}
+
+func TestSchemaApplyPatchAtomic(t *testing.T) {
+	schema := newPatchTestSchema()
+
+	err := schema.ApplyPatch(&SchemaPatch{Operations: []PatchOp{
+		{Op: PatchAddField, Field: &Field{Name: "extra", Type: FieldTypeString}},
+		{Op: PatchDeprecateField, Name: "missing"},
+	}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "field not found")
+
+	// 失败时不应产生部分修改
+	assert.Len(t, schema.Fields, 2)
+
+	err = schema.ApplyPatch(&SchemaPatch{Operations: []PatchOp{
+		{Op: PatchAddField, Field: &Field{Name: "action", Type: FieldTypeString}},
+	}})
+	assert.Error(t, err)
+
+	err = schema.ApplyPatch(&SchemaPatch{Operations: []PatchOp{
+		{Op: PatchSetRetention, Retention: "forever"},
+	}})
+	assert.Error(t, err)
+}
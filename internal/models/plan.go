@@ -0,0 +1,13 @@
+package models
+
+// QueryPlan 日志查询在存储后端的执行计划，用于排查慢查询
+type QueryPlan struct {
+	Project  string        `json:"project"`
+	Table    string        `json:"table"`
+	Dialect  string        `json:"dialect"`  // 存储后端的 SQL 方言，如 postgres、mysql、sqlite、clickhouse
+	SQL      string        `json:"sql"`      // 查询对应的 SQL，参数以占位符表示
+	Args     []interface{} `json:"args"`     // 占位符对应的参数
+	Explain  string        `json:"explain"`  // 执行的 EXPLAIN 语句前缀
+	Plan     []string      `json:"plan"`     // 执行计划，每行一个节点，缩进表示层级
+	Analyzed bool          `json:"analyzed"` // 是否实际执行了查询，为 true 时计划包含实际耗时与行数
+}
@@ -0,0 +1,256 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrSavedQueryNotFound is returned when a saved query is not found
+var ErrSavedQueryNotFound = fmt.Errorf("saved query not found")
+
+// BaseColumns 所有存储的日志表中除 schema 字段外都有的基础列；tags 与 ingest_time 由 schema 定义时为普通字段。
+// 个别存储有更多基础列（如 PostgreSQL 的 level、message、ip），由 storage.BaseColumns 报告
+var BaseColumns = []string{"id", "project", "table_name", "timestamp", TagsColumn, IngestTimeColumn}
+
+// tagKeyPattern 标签过滤允许的键，键会嵌入 JSON 路径，需限制字符集
+var tagKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// templateParam 匹配过滤值中的模板参数，如 ${service}
+var templateParam = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// Query 日志查询：等值过滤、时间范围、返回字段与排序
+type Query struct {
+	Filter map[string]interface{} `json:"filter,omitempty"` // 列名或嵌套路径 -> 值，值可以是 ${param} 模板参数
+	Tags   map[string]string      `json:"tags,omitempty"`   // 标签键 -> 值，全部匹配，值可以是 ${param} 模板参数
+	From   *time.Time             `json:"from,omitempty"`   // 只匹配 timestamp 不早于 from 的日志
+	To     *time.Time             `json:"to,omitempty"`     // 只匹配 timestamp 早于 to 的日志
+	Fields []string               `json:"fields,omitempty"` // 返回的列，为空时返回全部
+	Sort   []string               `json:"sort,omitempty"`   // 排序列，以 - 开头或跟 desc 表示降序，如 "-status" 或 "status desc"
+	Limit  int                    `json:"limit,omitempty"`
+	Offset int                    `json:"offset,omitempty"`
+}
+
+// SortKey 解析后的排序键
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// DefaultSortKeys 未指定排序时的顺序：最新的日志在前
+var DefaultSortKeys = []SortKey{{Column: "timestamp", Desc: true}}
+
+// SortKeys 解析排序定义，未指定 Sort 时返回 DefaultSortKeys
+func (q *Query) SortKeys() []SortKey {
+	keys, _ := q.sortKeys()
+	if len(keys) == 0 {
+		return DefaultSortKeys
+	}
+	return keys
+}
+
+// sortKeys 解析 Sort 中显式指定的排序键，每项为 [-|+]field 或 "field asc|desc"
+func (q *Query) sortKeys() ([]SortKey, error) {
+	keys := make([]SortKey, 0, len(q.Sort))
+	for _, item := range q.Sort {
+		parts := strings.Fields(item)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("invalid sort: %q", item)
+		}
+		key := SortKey{Column: parts[0]}
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "asc":
+			case "desc":
+				key.Desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort direction %q for %s, expected asc or desc", parts[1], parts[0])
+			}
+		} else if strings.HasPrefix(key.Column, "-") {
+			key.Column, key.Desc = strings.TrimPrefix(key.Column, "-"), true
+		} else {
+			key.Column = strings.TrimPrefix(key.Column, "+")
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// SortWarnings 返回显式排序中未建立索引的列的提示，这些列排序时需要读取并排序全部匹配行。
+// timestamp 与 id 是各存储的主键或排序键，不产生提示
+func (q *Query) SortWarnings(schema *Schema) []string {
+	keys, err := q.sortKeys()
+	if err != nil {
+		return nil
+	}
+	indexed := map[string]bool{"timestamp": true, "id": true}
+	for _, field := range schema.Fields {
+		if field.IsIndexed() {
+			indexed[field.Name] = true
+		}
+	}
+	var warnings []string
+	for _, key := range keys {
+		if !indexed[key.Column] {
+			warnings = append(warnings, fmt.Sprintf("sort field %s is not indexed, matching rows are sorted without an index", key.Column))
+		}
+	}
+	return warnings
+}
+
+// Validate 检查查询中引用的列均存在于日志表中：schema 字段或 baseColumns 中的基础列，失败时返回 ErrValidation。
+// baseColumns 为存储报告的基础列（storage.BaseColumns），不同存储的日志表基础列不同
+func (q *Query) Validate(schema *Schema, baseColumns []string) error {
+	return invalid(q.validate(schema, baseColumns))
+}
+
+// validate 校验过滤、标签、返回字段与排序列
+func (q *Query) validate(schema *Schema, baseColumns []string) error {
+	columns := make(map[string]bool, len(schema.Fields)+len(baseColumns))
+	for _, name := range baseColumns {
+		columns[name] = true
+	}
+	for _, field := range schema.Fields {
+		columns[field.Name] = true
+	}
+
+	for name, value := range q.Filter {
+		if columns[name] {
+			if err := validateIPFilter(schema, name, value); err != nil {
+				return err
+			}
+			continue
+		}
+		if !strings.Contains(name, ".") {
+			return fmt.Errorf("unknown filter field: %s", name)
+		}
+		// 嵌套路径，如 request.headers.host、items.sku
+		if _, err := schema.ResolvePath(name); err != nil {
+			return fmt.Errorf("invalid filter field %s: %w", name, err)
+		}
+	}
+	if len(q.Tags) > 0 && !schema.StoresTags() {
+		return fmt.Errorf("schema %s:%s defines its own %s field, tag filters are not available", schema.Project, schema.Table, TagsColumn)
+	}
+	for key := range q.Tags {
+		if !tagKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid tag key: %q", key)
+		}
+	}
+	for _, name := range q.Fields {
+		if !columns[name] {
+			return fmt.Errorf("unknown field: %s", name)
+		}
+	}
+	keys, err := q.sortKeys()
+	if err != nil {
+		return err
+	}
+	sorted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !columns[key.Column] {
+			return fmt.Errorf("unknown sort field: %s", key.Column)
+		}
+		if sorted[key.Column] {
+			return fmt.Errorf("duplicate sort field: %s", key.Column)
+		}
+		sorted[key.Column] = true
+	}
+	if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("limit and offset must not be negative")
+	}
+	return nil
+}
+
+// validateIPFilter 校验 IP 字段的过滤值为地址或 CIDR 网段，模板参数在绑定后校验
+func validateIPFilter(schema *Schema, name string, value interface{}) error {
+	for _, field := range schema.Fields {
+		if field.Name != name || field.Type != FieldTypeIP {
+			continue
+		}
+		if s, ok := value.(string); ok && templateParam.MatchString(s) {
+			return nil
+		}
+		if _, err := ParseIPFilter(value); err != nil {
+			return fmt.Errorf("invalid filter for ip field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Params 返回过滤条件与标签过滤中引用的模板参数名
+func (q *Query) Params() []string {
+	var params []string
+	for _, value := range q.Filter {
+		if s, ok := value.(string); ok {
+			if m := templateParam.FindStringSubmatch(s); m != nil {
+				params = append(params, m[1])
+			}
+		}
+	}
+	for _, value := range q.Tags {
+		if m := templateParam.FindStringSubmatch(value); m != nil {
+			params = append(params, m[1])
+		}
+	}
+	return params
+}
+
+// Bind 用参数替换过滤条件中的模板参数，返回新的查询
+func (q *Query) Bind(params map[string]string) (*Query, error) {
+	bound := *q
+	bound.Filter = make(map[string]interface{}, len(q.Filter))
+	for key, value := range q.Filter {
+		if s, ok := value.(string); ok {
+			if m := templateParam.FindStringSubmatch(s); m != nil {
+				v, ok := params[m[1]]
+				if !ok {
+					return nil, invalid(fmt.Errorf("missing query parameter: %s", m[1]))
+				}
+				value = v
+			}
+		}
+		bound.Filter[key] = value
+	}
+	if q.Tags != nil {
+		bound.Tags = make(map[string]string, len(q.Tags))
+		for key, value := range q.Tags {
+			if m := templateParam.FindStringSubmatch(value); m != nil {
+				v, ok := params[m[1]]
+				if !ok {
+					return nil, invalid(fmt.Errorf("missing query parameter: %s", m[1]))
+				}
+				value = v
+			}
+			bound.Tags[key] = value
+		}
+	}
+	return &bound, nil
+}
+
+// SavedQuery 按名称保存的查询，归属于创建者
+type SavedQuery struct {
+	Name        string    `json:"name"`
+	Owner       string    `json:"owner"`
+	Project     string    `json:"project"`
+	Table       string    `json:"table"`
+	Description string    `json:"description,omitempty"`
+	Query       Query     `json:"query"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Validate 验证保存的查询，baseColumns 同 Query.Validate
+func (q *SavedQuery) Validate(schema *Schema, baseColumns []string) error {
+	if q.Name == "" {
+		return invalid(fmt.Errorf("saved query name is required"))
+	}
+	if q.Project == "" || q.Table == "" {
+		return invalid(fmt.Errorf("project and table are required"))
+	}
+	return q.Query.Validate(schema, baseColumns)
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryValidate(t *testing.T) {
+	schema := &Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*Field{{Name: "service", Type: FieldTypeString}},
+	}
+
+	q := &Query{
+		Filter: map[string]interface{}{"service": "api", "level": "error"},
+		Fields: []string{"service", "timestamp"},
+		Sort:   []string{"-timestamp", "service"},
+	}
+	// level 只在存储报告为基础列时可用
+	assert.ErrorIs(t, q.Validate(schema, BaseColumns), ErrValidation)
+	require.NoError(t, q.Validate(schema, append([]string{"level"}, BaseColumns...)))
+	assert.Equal(t, []SortKey{{Column: "timestamp", Desc: true}, {Column: "service"}}, q.SortKeys())
+
+	assert.Error(t, (&Query{Filter: map[string]interface{}{"unknown": 1}}).Validate(schema, BaseColumns))
+	assert.Error(t, (&Query{Fields: []string{"service; DROP TABLE x"}}).Validate(schema, BaseColumns))
+	err := (&Query{Sort: []string{"-unknown"}}).Validate(schema, BaseColumns)
+	assert.ErrorIs(t, err, ErrValidation)
+
+	require.NoError(t, (&Query{Tags: map[string]string{"env": "prod"}, Fields: []string{"tags"}}).Validate(schema, BaseColumns))
+	assert.Error(t, (&Query{Tags: map[string]string{`env") OR 1=1 --`: "x"}}).Validate(schema, BaseColumns))
+
+	// schema 自定义 tags 字段时不支持标签过滤
+	custom := &Schema{Fields: []*Field{{Name: "tags", Type: FieldTypeArray, ItemType: FieldTypeString}}}
+	assert.False(t, custom.StoresTags())
+	assert.Error(t, (&Query{Tags: map[string]string{"env": "prod"}}).Validate(custom, BaseColumns))
+}
+
+func TestQuerySortDirections(t *testing.T) {
+	schema := &Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*Field{
+			{Name: "service", Type: FieldTypeString, Indexed: true},
+			{Name: "status", Type: FieldTypeInt},
+		},
+	}
+
+	assert.Equal(t, DefaultSortKeys, (&Query{}).SortKeys(), "timestamp desc by default")
+
+	q := &Query{Sort: []string{"status DESC", "+service", "timestamp asc"}}
+	require.NoError(t, q.Validate(schema, BaseColumns))
+	assert.Equal(t, []SortKey{{Column: "status", Desc: true}, {Column: "service"}, {Column: "timestamp"}}, q.SortKeys())
+	assert.Equal(t, []string{"sort field status is not indexed, matching rows are sorted without an index"}, q.SortWarnings(schema))
+	assert.Empty(t, (&Query{}).SortWarnings(schema), "the default order is not reported")
+	assert.Empty(t, (&Query{Sort: []string{"-timestamp", "id", "service"}}).SortWarnings(schema))
+
+	for _, invalid := range []*Query{
+		{Sort: []string{"status sideways"}},
+		{Sort: []string{""}},
+		{Sort: []string{"status desc nulls"}},
+		{Sort: []string{"unknown desc"}},
+		{Sort: []string{"status", "-status"}},
+		{Sort: []string{"status", "status desc"}},
+	} {
+		err := invalid.Validate(schema, BaseColumns)
+		assert.ErrorIs(t, err, ErrValidation, "%q", invalid.Sort)
+	}
+}
+
+func TestQueryBind(t *testing.T) {
+	q := &Query{Filter: map[string]interface{}{"service": "${service}", "level": "error"}}
+	assert.Equal(t, []string{"service"}, q.Params())
+
+	bound, err := q.Bind(map[string]string{"service": "api"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"service": "api", "level": "error"}, bound.Filter)
+	assert.Equal(t, "${service}", q.Filter["service"])
+
+	_, err = q.Bind(nil)
+	assert.ErrorIs(t, err, ErrValidation)
+
+	q = &Query{Tags: map[string]string{"env": "${env}", "region": "eu"}}
+	assert.Equal(t, []string{"env"}, q.Params())
+	bound, err = q.Bind(map[string]string{"env": "prod"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "region": "eu"}, bound.Tags)
+	assert.Equal(t, "${env}", q.Tags["env"])
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// SchemaEventType schema 变更类型
+type SchemaEventType string
+
+const (
+	SchemaCreated SchemaEventType = "created"
+	SchemaUpdated SchemaEventType = "updated"
+	SchemaDeleted SchemaEventType = "deleted"
+)
+
+// SchemaEvent 注册表中 schema 的一次变更
+type SchemaEvent struct {
+	Type    SchemaEventType `json:"type"`
+	Project string          `json:"project"`
+	Table   string          `json:"table"`
+	Schema  *Schema         `json:"schema,omitempty"` // 变更后的 schema，删除时为 nil
+}
+
+// SchemaRegistry 并发安全的 schema 注册表，作为 API 服务器、schema 管理器与存储缓存共享的 schema 来源。
+// 注册表只校验 project 与 table，完整的校验由写入存储时完成。保存与返回的都是副本，调用方修改返回值不影响注册表
+type SchemaRegistry struct {
+	mu          sync.RWMutex
+	schemas     map[string]*Schema // key: project:table
+	subscribers map[int]chan SchemaEvent
+	nextID      int
+}
+
+// NewSchemaRegistry 创建新的 schema 注册表
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		schemas:     make(map[string]*Schema),
+		subscribers: make(map[int]chan SchemaEvent),
+	}
+}
+
+// registryKey 返回注册表中的键
+func registryKey(project, table string) string {
+	return project + ":" + table
+}
+
+// Register 注册新的 schema，已存在时返回 ErrSchemaExists
+func (r *SchemaRegistry) Register(schema *Schema) error {
+	if err := invalid(validateTableIdentifiers(schema.Project, schema.Table)); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(schema.Project, schema.Table)
+	if _, exists := r.schemas[key]; exists {
+		return fmt.Errorf("%w: %s", ErrSchemaExists, key)
+	}
+	r.set(key, schema, SchemaCreated)
+	return nil
+}
+
+// Update 替换已注册的 schema，不存在时返回 ErrSchemaNotFound
+func (r *SchemaRegistry) Update(schema *Schema) error {
+	if err := invalid(validateTableIdentifiers(schema.Project, schema.Table)); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(schema.Project, schema.Table)
+	current, exists := r.schemas[key]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrSchemaNotFound, key)
+	}
+	if current.ETag() != schema.ETag() {
+		r.set(key, schema, SchemaUpdated)
+	}
+	return nil
+}
+
+// Put 注册或替换 schema，内容未变化时不发送事件
+func (r *SchemaRegistry) Put(schema *Schema) error {
+	if err := invalid(validateTableIdentifiers(schema.Project, schema.Table)); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(schema.Project, schema.Table)
+	current, exists := r.schemas[key]
+	switch {
+	case !exists:
+		r.set(key, schema, SchemaCreated)
+	case current.ETag() != schema.ETag():
+		r.set(key, schema, SchemaUpdated)
+	}
+	return nil
+}
+
+// Load 保存从存储读取的 schema 而不发送事件，用于缓存未命中后的填充。
+// 读取期间已有其他调用注册了同名 schema 时保留注册表中的版本
+func (r *SchemaRegistry) Load(schema *Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(schema.Project, schema.Table)
+	if _, exists := r.schemas[key]; !exists {
+		r.schemas[key] = schema.Clone()
+	}
+}
+
+// Delete 删除 schema，不存在时返回 ErrSchemaNotFound
+func (r *SchemaRegistry) Delete(project, table string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(project, table)
+	if _, exists := r.schemas[key]; !exists {
+		return fmt.Errorf("%w: %s", ErrSchemaNotFound, key)
+	}
+	delete(r.schemas, key)
+	r.publish(SchemaEvent{Type: SchemaDeleted, Project: project, Table: table})
+	return nil
+}
+
+// Evict 从注册表移除 schema 而不发送事件，用于其他实例修改 schema 后使本地缓存失效，下次读取时从存储重新加载
+func (r *SchemaRegistry) Evict(project, table string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.schemas, registryKey(project, table))
+}
+
+// EvictAll 清空注册表而不发送事件，用于无法确定错过了哪些失效通知时
+func (r *SchemaRegistry) EvictAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas = make(map[string]*Schema)
+}
+
+// Get 获取 schema 的副本
+func (r *SchemaRegistry) Get(project, table string) (*Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key := registryKey(project, table)
+	schema, exists := r.schemas[key]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrSchemaNotFound, key)
+	}
+	return schema.Clone(), nil
+}
+
+// List 按 project、table 排序返回全部 schema 的副本
+func (r *SchemaRegistry) List() []*Schema {
+	r.mu.RLock()
+	schemas := make([]*Schema, 0, len(r.schemas))
+	for _, schema := range r.schemas {
+		schemas = append(schemas, schema.Clone())
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(schemas, func(i, j int) bool {
+		if schemas[i].Project != schemas[j].Project {
+			return schemas[i].Project < schemas[j].Project
+		}
+		return schemas[i].Table < schemas[j].Table
+	})
+	return schemas
+}
+
+// Subscribe 订阅之后的变更事件，返回事件通道与取消订阅的函数，取消后通道关闭。
+// 事件按发生顺序投递，事件中的 schema 由所有订阅者共享，不应修改；
+// 通道缓冲区已满时丢弃事件而不阻塞写入方，buffer 小于 1 时按 1 处理
+func (r *SchemaRegistry) Subscribe(buffer int) (<-chan SchemaEvent, func()) {
+	if buffer < 1 {
+		buffer = 1
+	}
+	ch := make(chan SchemaEvent, buffer)
+
+	r.mu.Lock()
+	id := r.nextID
+	r.nextID++
+	r.subscribers[id] = ch
+	r.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			r.mu.Lock()
+			delete(r.subscribers, id)
+			r.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// set 保存 schema 副本并发送事件，调用方需持有写锁
+func (r *SchemaRegistry) set(key string, schema *Schema, typ SchemaEventType) {
+	stored := schema.Clone()
+	r.schemas[key] = stored
+	r.publish(SchemaEvent{Type: typ, Project: schema.Project, Table: schema.Table, Schema: stored.Clone()})
+}
+
+// publish 向所有订阅者发送事件，调用方需持有写锁
+func (r *SchemaRegistry) publish(event SchemaEvent) {
+	for _, ch := range r.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func registrySchema(table string, fields ...string) *Schema {
+	schema := &Schema{Project: "app", Table: table, Fields: []*Field{{Name: "message", Type: FieldTypeString}}}
+	for _, name := range fields {
+		schema.Fields = append(schema.Fields, &Field{Name: name, Type: FieldTypeString})
+	}
+	return schema
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	r := NewSchemaRegistry()
+	events, cancel := r.Subscribe(10)
+
+	require.NoError(t, r.Register(registrySchema("b", "path")))
+	require.NoError(t, r.Register(registrySchema("a")))
+	assert.ErrorIs(t, r.Register(registrySchema("a")), ErrSchemaExists)
+	assert.ErrorIs(t, r.Register(&Schema{Project: "app", Table: "bad name"}), ErrValidation)
+
+	got, err := r.Get("app", "b")
+	require.NoError(t, err)
+	got.Fields[1].Name = "changed"
+	got, _ = r.Get("app", "b")
+	assert.Equal(t, "path", got.Fields[1].Name, "callers receive copies")
+
+	require.NoError(t, r.Update(registrySchema("b", "path", "status")))
+	require.NoError(t, r.Put(registrySchema("b", "path", "status")), "unchanged schemas do not emit events")
+	assert.ErrorIs(t, r.Update(registrySchema("missing")), ErrSchemaNotFound)
+	require.NoError(t, r.Put(registrySchema("c")))
+
+	list := r.List()
+	require.Len(t, list, 3)
+	assert.Equal(t, []string{"a", "b", "c"}, []string{list[0].Table, list[1].Table, list[2].Table})
+
+	require.NoError(t, r.Delete("app", "a"))
+	assert.ErrorIs(t, r.Delete("app", "a"), ErrSchemaNotFound)
+	_, err = r.Get("app", "a")
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+
+	// 缓存填充不发送事件，也不覆盖已注册的版本
+	r.Load(registrySchema("d"))
+	r.Load(registrySchema("b"))
+	got, _ = r.Get("app", "b")
+	assert.Len(t, got.Fields, 3)
+
+	cancel()
+	cancel()
+	var received []string
+	for event := range events {
+		received = append(received, fmt.Sprintf("%s %s", event.Type, event.Table))
+	}
+	assert.Equal(t, []string{"created b", "created a", "updated b", "created c", "deleted a"}, received)
+}
+
+func TestSchemaRegistrySubscriberOverflow(t *testing.T) {
+	r := NewSchemaRegistry()
+	events, cancel := r.Subscribe(1)
+	defer cancel()
+
+	require.NoError(t, r.Put(registrySchema("a")))
+	require.NoError(t, r.Put(registrySchema("b")), "a full subscriber does not block writers")
+	event := <-events
+	assert.Equal(t, "a", event.Table)
+	assert.Equal(t, "a", event.Schema.Table)
+	assert.Empty(t, events)
+}
+
+func TestSchemaRegistryConcurrent(t *testing.T) {
+	r := NewSchemaRegistry()
+	events, cancel := r.Subscribe(1000)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			table := fmt.Sprintf("t%d", i)
+			for j := 0; j < 20; j++ {
+				assert.NoError(t, r.Put(registrySchema(table, fmt.Sprintf("f%d", j))))
+				_, _ = r.Get("app", table)
+				r.List()
+			}
+			assert.NoError(t, r.Delete("app", table))
+		}(i)
+	}
+	wg.Wait()
+	assert.Empty(t, r.List())
+	assert.Len(t, events, 8*21)
+}
+
+func TestSchemaRegistryEvict(t *testing.T) {
+	r := NewSchemaRegistry()
+	require.NoError(t, r.Put(registrySchema("a")))
+	require.NoError(t, r.Put(registrySchema("b")))
+	events, cancel := r.Subscribe(10)
+	defer cancel()
+
+	r.Evict("app", "a")
+	r.Evict("app", "missing")
+	_, err := r.Get("app", "a")
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+	_, err = r.Get("app", "b")
+	assert.NoError(t, err)
+
+	r.EvictAll()
+	assert.Empty(t, r.List())
+	assert.Empty(t, events, "evictions do not notify subscribers")
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrReportNotFound is returned when a report is not found
+var ErrReportNotFound = fmt.Errorf("report not found")
+
+// ReportFormat 报表内容格式
+type ReportFormat string
+
+const (
+	ReportFormatCSV     ReportFormat = "csv"     // CSV 附件
+	ReportFormatJSON    ReportFormat = "json"    // JSON 附件
+	ReportFormatSummary ReportFormat = "summary" // 正文内联摘要
+)
+
+// ReportChannel 报表投递渠道
+type ReportChannel string
+
+const (
+	ReportChannelWebhook ReportChannel = "webhook"
+	ReportChannelSlack   ReportChannel = "slack"
+	ReportChannelEmail   ReportChannel = "email"
+)
+
+// ReportTarget 报表投递目标
+type ReportTarget struct {
+	Type ReportChannel `json:"type"`
+	URL  string        `json:"url,omitempty"` // webhook 与 slack 使用
+	To   []string      `json:"to,omitempty"`  // email 使用
+}
+
+// Report 按 cron 计划执行保存查询并投递结果的定时报表
+type Report struct {
+	Name       string            `json:"name"`
+	Owner      string            `json:"owner"`
+	SavedQuery string            `json:"saved_query"`      // 同一所有者的保存查询名称
+	Params     map[string]string `json:"params,omitempty"` // 填充保存查询中的 ${param}
+	Schedule   string            `json:"schedule"`         // 标准 5 段 cron 表达式
+	Format     ReportFormat      `json:"format"`
+	Targets    []*ReportTarget   `json:"targets"`
+	Enabled    bool              `json:"enabled"`
+
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Validate 验证报表定义，cron 表达式由调度器校验，失败时返回 ErrValidation
+func (r *Report) Validate() error {
+	return invalid(r.validate())
+}
+
+// validate 校验名称、格式与投递目标，未指定格式时使用 summary
+func (r *Report) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("report name is required")
+	}
+	if r.SavedQuery == "" {
+		return fmt.Errorf("saved_query is required")
+	}
+	if r.Schedule == "" {
+		return fmt.Errorf("schedule is required")
+	}
+
+	switch r.Format {
+	case ReportFormatCSV, ReportFormatJSON, ReportFormatSummary:
+	case "":
+		r.Format = ReportFormatSummary
+	default:
+		return fmt.Errorf("unsupported report format: %s", r.Format)
+	}
+
+	if len(r.Targets) == 0 {
+		return fmt.Errorf("at least one target is required")
+	}
+	for i, target := range r.Targets {
+		switch target.Type {
+		case ReportChannelWebhook, ReportChannelSlack:
+			if target.URL == "" {
+				return fmt.Errorf("target %d: url is required for %s", i, target.Type)
+			}
+		case ReportChannelEmail:
+			if len(target.To) == 0 {
+				return fmt.Errorf("target %d: recipients are required for email", i)
+			}
+		default:
+			return fmt.Errorf("target %d: unsupported channel: %s", i, target.Type)
+		}
+	}
+	return nil
+}
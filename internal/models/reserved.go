@@ -0,0 +1,130 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ReservedColumns 所有存储的日志表都包含的内置列，字段不能使用这些名称
+var ReservedColumns = []string{"id", "project", "table_name", "timestamp"}
+
+// storageTypes 支持的存储类型
+var storageTypes = []string{"postgres", "mysql", "sqlite", "clickhouse"}
+
+// commonReservedWords 各存储共有的 SQL 保留字
+const commonReservedWords = `all and as asc between by case check column constraint create cross default
+delete desc distinct drop else exists false for foreign from group having in index inner insert
+intersect into is join key left like limit not null offset on or order outer primary references
+right select set table then to true union unique update using values when where with`
+
+// reservedWords 各存储额外的保留字（只收录常见的），作为列名时需要加引号
+var reservedWords = map[string]map[string]bool{
+	"postgres": wordSet(commonReservedWords, `analyse analyze array asymmetric both cast collate
+current_date current_time current_timestamp current_user deferrable do except fetch grant
+initially lateral leading localtime localtimestamp only placing returning session_user some
+symmetric trailing user variadic window`),
+	"mysql": wordSet(commonReservedWords, `add alter change condition database databases div dual
+explain fulltext interval keys kill lock match mod range read regexp rename replace require rlike
+schema show signal spatial usage write xor zerofill int integer bigint double float decimal char
+varchar binary`),
+	"sqlite": wordSet(commonReservedWords, `abort action after analyze attach autoincrement before
+begin cascade commit conflict database deferred detach each escape except exclusive explain fail
+glob if ignore immediate indexed initially instead isnull natural no notnull of plan pragma query
+raise recursive regexp reindex release rename replace restrict rollback row savepoint temp
+temporary transaction trigger vacuum view virtual without`),
+	"clickhouse": wordSet(commonReservedWords, `array final format global interval prewhere sample
+settings`),
+}
+
+// wordSet 将空白分隔的单词列表合并为集合
+func wordSet(lists ...string) map[string]bool {
+	set := make(map[string]bool)
+	for _, list := range lists {
+		for _, word := range strings.Fields(list) {
+			set[word] = true
+		}
+	}
+	return set
+}
+
+// IsReservedWord 判断名称是否为指定存储的 SQL 保留字
+func IsReservedWord(dialect, name string) bool {
+	return reservedWords[dialect][strings.ToLower(name)]
+}
+
+// quoteName 按存储类型引用列名，使保留字可以用作字段名
+func quoteName(dialect, name string) string {
+	if dialect == "mysql" || dialect == "clickhouse" {
+		return "`" + name + "`"
+	}
+	return `"` + name + `"`
+}
+
+// isReservedColumn 判断名称是否与内置列冲突
+func isReservedColumn(name string) bool {
+	for _, column := range ReservedColumns {
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+// CompatibilityWarnings 返回 schema 在指定存储上可能出现问题的名称。
+// 保留字由存储层自动加引号，不影响写入与查询，但手写 SQL 时需要引用；
+// 超长的索引名在 PostgreSQL 上会被截断，在 MySQL 上会导致建索引失败；
+// 索引类型与表达式只在 PostgreSQL 上生效。
+// dialect 为空时检查所有存储
+func (s *Schema) CompatibilityWarnings(dialect string) []string {
+	dialects := storageTypes
+	if dialect != "" {
+		dialects = []string{dialect}
+	}
+
+	var warnings []string
+	for _, field := range s.Fields {
+		var reserved []string
+		for _, d := range dialects {
+			if IsReservedWord(d, field.Name) {
+				reserved = append(reserved, d)
+			}
+		}
+		if len(reserved) > 0 {
+			sort.Strings(reserved)
+			warnings = append(warnings, fmt.Sprintf("field %s is a reserved word on %s and must be quoted in hand-written SQL",
+				field.Name, strings.Join(reserved, ", ")))
+		}
+		if !field.IsIndexed() {
+			continue
+		}
+		var ignored []string
+		for _, d := range dialects {
+			if d == "sqlite" && field.jsonPathIndex() {
+				continue
+			}
+			if d != "postgres" && field.Index != nil && (field.IndexType() != IndexBTree || field.Index.Expression != "") {
+				ignored = append(ignored, d)
+			}
+		}
+		if len(ignored) > 0 {
+			sort.Strings(ignored)
+			warnings = append(warnings, fmt.Sprintf("index type and expression of field %s only apply to postgres and are ignored on %s",
+				field.Name, strings.Join(ignored, ", ")))
+		}
+		for _, d := range dialects {
+			switch d {
+			case "postgres":
+				if name := fmt.Sprintf("idx_%s_%s_%s", s.Project, s.Table, field.Name); len(name) > MaxIdentifierLength {
+					warnings = append(warnings, fmt.Sprintf("index name %s exceeds %d characters and will be truncated on postgres", name, MaxIdentifierLength))
+				}
+			case "mysql":
+				// MySQL 的标识符上限为 64
+				if name := "idx_" + field.Name; len(name) > MaxIdentifierLength+1 {
+					warnings = append(warnings, fmt.Sprintf("index name %s exceeds %d characters and cannot be created on mysql", name, MaxIdentifierLength+1))
+				}
+			}
+		}
+	}
+	return warnings
+}
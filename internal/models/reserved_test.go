@@ -0,0 +1,51 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReservedColumnNames(t *testing.T) {
+	for _, name := range ReservedColumns {
+		schema := &Schema{Project: "app", Table: "events", Fields: []*Field{{Name: name, Type: FieldTypeString}}}
+		assert.ErrorIs(t, schema.Validate(), ErrValidation, name)
+	}
+
+	// SQL 保留字由存储层加引号，可以作为字段名
+	schema := &Schema{Project: "app", Table: "events", Fields: []*Field{
+		{Name: "order", Type: FieldTypeString},
+		{Name: "table", Type: FieldTypeString},
+		{Name: "level", Type: FieldTypeString},
+	}}
+	assert.NoError(t, schema.Validate())
+}
+
+func TestCompatibilityWarnings(t *testing.T) {
+	schema := &Schema{Project: "app", Table: "events", Fields: []*Field{
+		{Name: "order", Type: FieldTypeString},
+		{Name: "interval", Type: FieldTypeInt},
+		{Name: "service", Type: FieldTypeString},
+	}}
+
+	warnings := schema.CompatibilityWarnings("")
+	require.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "order is a reserved word on clickhouse, mysql, postgres, sqlite")
+	assert.Contains(t, warnings[1], "interval is a reserved word on clickhouse, mysql")
+	assert.Len(t, schema.CompatibilityWarnings("sqlite"), 1)
+	assert.True(t, IsReservedWord("postgres", "USER"))
+	assert.False(t, IsReservedWord("sqlite", "user"))
+
+	long := strings.Repeat("f", MaxIdentifierLength)
+	schema = &Schema{Project: "app", Table: "events", Fields: []*Field{{Name: long, Type: FieldTypeString, Indexed: true}}}
+	require.NoError(t, schema.Validate())
+	assert.Len(t, schema.CompatibilityWarnings("postgres"), 1)
+	assert.Len(t, schema.CompatibilityWarnings("mysql"), 1)
+	assert.Empty(t, schema.CompatibilityWarnings("sqlite"))
+
+	sql, err := (&Schema{Project: "app", Table: "events", Fields: []*Field{{Name: "order", Type: FieldTypeString}}}).GenerateTableSQL("postgres")
+	require.NoError(t, err)
+	assert.Contains(t, sql, `"order" TEXT`)
+}
@@ -1,8 +1,12 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -26,6 +30,7 @@ const (
 	FieldTypeDuration FieldType = "duration"
 	FieldTypeJSON     FieldType = "json"
 	FieldTypeRest     FieldType = "rest" // 新增 Rest 类型
+	FieldTypeIP       FieldType = "ip"   // IPv4 或 IPv6 地址
 
 	// 复杂类型
 	FieldTypeObject FieldType = "object"
@@ -38,9 +43,12 @@ type Field struct {
 	Type        FieldType   `yaml:"type" json:"type"`
 	Required    bool        `yaml:"required" json:"required"`
 	Indexed     bool        `yaml:"indexed" json:"indexed"`
+	Index       *IndexSpec  `yaml:"index,omitempty" json:"index,omitempty"` // 索引类型与表达式，设置后字段视为已索引
 	Description string      `yaml:"description,omitempty" json:"description,omitempty"`
-	Default     interface{} `yaml:"default,omitempty" json:"default,omitempty"`
-	Rest        bool        `yaml:"rest,omitempty" json:"rest,omitempty"` // 新增 Rest 标记
+	Default     interface{} `yaml:"default,omitempty" json:"default,omitempty"`       // 非必填字段缺失时写入的值
+	Nullable    *bool       `yaml:"nullable,omitempty" json:"nullable,omitempty"`     // 为 false 时列为 NOT NULL，未设置时允许 NULL
+	Rest        bool        `yaml:"rest,omitempty" json:"rest,omitempty"`             // 新增 Rest 标记
+	Deprecated  bool        `yaml:"deprecated,omitempty" json:"deprecated,omitempty"` // 已弃用，保留列但不再要求写入
 
 	// 用于复杂类型
 	Fields    []*Field  `yaml:"fields,omitempty" json:"fields,omitempty"`       // 对象类型的子字段
@@ -54,83 +62,26 @@ type Field struct {
 
 // Schema 表示日志的 schema 定义
 type Schema struct {
-	Project     string    `yaml:"project" json:"project"`         // 项目名称
-	Table       string    `yaml:"table" json:"table"`             // 表名
-	Description string    `yaml:"description" json:"description"` // 描述
-	Version     string    `yaml:"version" json:"version"`         // 版本号
-	Fields      []*Field  `yaml:"fields" json:"fields"`           // 字段定义
-	CreatedAt   time.Time `yaml:"created_at" json:"created_at"`   // 创建时间
-	UpdatedAt   time.Time `yaml:"updated_at" json:"updated_at"`   // 更新时间
+	Project     string    `yaml:"project" json:"project"`                 // 项目名称
+	Table       string    `yaml:"table" json:"table"`                     // 表名
+	Description string    `yaml:"description" json:"description"`         // 描述
+	Version     string    `yaml:"version" json:"version"`                 // 版本号
+	Fields      []*Field  `yaml:"fields" json:"fields"`                   // 字段定义
+	CreatedAt   time.Time `yaml:"created_at,omitempty" json:"created_at"` // 创建时间
+	UpdatedAt   time.Time `yaml:"updated_at,omitempty" json:"updated_at"` // 更新时间
+
+	SchemaOptions `yaml:",inline"` // 表级别可选配置，随 schema 一起持久化
 }
 
-// SchemaRegistry 管理 schema 注册
-type SchemaRegistry struct {
-	schemas map[string]*Schema // key: project:table
-}
-
-// NewSchemaRegistry 创建新的 schema 注册表
-func NewSchemaRegistry() *SchemaRegistry {
-	return &SchemaRegistry{
-		schemas: make(map[string]*Schema),
-	}
-}
-
-// Register 注册新的 schema
-func (r *SchemaRegistry) Register(schema *Schema) error {
-	key := fmt.Sprintf("%s:%s", schema.Project, schema.Table)
-	if _, exists := r.schemas[key]; exists {
-		return fmt.Errorf("schema already exists: %s", key)
-	}
-
-	// 验证字段
-	if err := r.validateSchema(schema); err != nil {
-		return err
-	}
-
-	r.schemas[key] = schema
-	return nil
-}
-
-// Get 获取 schema
-func (r *SchemaRegistry) Get(project, table string) (*Schema, error) {
-	key := fmt.Sprintf("%s:%s", project, table)
-	schema, exists := r.schemas[key]
-	if !exists {
-		return nil, fmt.Errorf("schema not found: %s", key)
-	}
-	return schema, nil
-}
-
-// validateSchema 验证 schema
-func (r *SchemaRegistry) validateSchema(schema *Schema) error {
-	if schema.Project == "" {
-		return fmt.Errorf("project name is required")
-	}
-	if schema.Table == "" {
-		return fmt.Errorf("table name is required")
-	}
-
-	// 验证字段名称唯一性
-	fieldNames := make(map[string]bool)
-	for _, field := range schema.Fields {
-		if field.Name == "" {
-			return fmt.Errorf("field name is required")
-		}
-		if fieldNames[field.Name] {
-			return fmt.Errorf("duplicate field name: %s", field.Name)
-		}
-		fieldNames[field.Name] = true
-
-		// 验证字段类型
-		switch field.Type {
-		case FieldTypeString, FieldTypeInt, FieldTypeFloat, FieldTypeBool, FieldTypeDateTime, FieldTypeJSON, FieldTypeTime, FieldTypeDuration:
-			// 有效类型
-		default:
-			return fmt.Errorf("invalid field type: %s", field.Type)
-		}
+// ETag 根据 schema 内容计算实体标签，任何来源（文件、API）的修改都会使其变化，
+// 用于 schema 更新的乐观并发控制
+func (s *Schema) ETag() string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return ""
 	}
-
-	return nil
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
 }
 
 // GenerateTableSQL 生成创建表的 SQL 语句
@@ -174,12 +125,16 @@ func (s *Schema) generateClickHouseSQL() (string, error) {
 			columnType = "DateTime64(3)" // ClickHouse 没有 time 类型，用高精度 DateTime64 代替
 		case FieldTypeDuration:
 			columnType = "Int64" // duration 用 Int64 存储纳秒
-		case FieldTypeJSON, FieldTypeRest:
+		case FieldTypeIP:
+			columnType = "IPv6" // IPv4 以映射地址存储
+		case FieldTypeJSON, FieldTypeRest, FieldTypeObject:
 			columnType = "String"
+		case FieldTypeArray:
+			columnType = "String" // 以 JSON 文本存储
 		default:
 			return "", fmt.Errorf("unsupported field type: %s", field.Type)
 		}
-		columns = append(columns, fmt.Sprintf("%s %s", field.Name, columnType))
+		columns = append(columns, fmt.Sprintf("%s %s", quoteName("clickhouse", field.Name), columnType))
 	}
 
 	// 添加索引
@@ -188,8 +143,8 @@ func (s *Schema) generateClickHouseSQL() (string, error) {
 		"INDEX idx_timestamp timestamp",
 	}
 	for _, field := range s.Fields {
-		if field.Indexed {
-			indexes = append(indexes, fmt.Sprintf("INDEX idx_%s %s", field.Name, field.Name))
+		if field.IndexedOn("clickhouse") {
+			indexes = append(indexes, fmt.Sprintf("INDEX idx_%s %s", field.Name, quoteName("clickhouse", field.Name)))
 		}
 	}
 
@@ -234,12 +189,14 @@ func (s *Schema) generatePostgresSQL() (string, error) {
 			columnType = "TIME"
 		case FieldTypeDuration:
 			columnType = "BIGINT" // duration 用 BIGINT 存储纳秒
-		case FieldTypeJSON, FieldTypeRest:
+		case FieldTypeIP:
+			columnType = "INET"
+		case FieldTypeJSON, FieldTypeRest, FieldTypeObject, FieldTypeArray:
 			columnType = "JSONB"
 		default:
 			columnType = "TEXT"
 		}
-		columns = append(columns, fmt.Sprintf("%s %s", field.Name, columnType))
+		columns = append(columns, fmt.Sprintf("%s %s", quoteName("postgres", field.Name), columnType))
 	}
 
 	// 生成建表语句
@@ -255,9 +212,9 @@ func (s *Schema) generatePostgresSQL() (string, error) {
 		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_timestamp ON %s.%s (timestamp);", s.Table, s.Project, s.Table),
 	}
 	for _, field := range s.Fields {
-		if field.Indexed {
-			indexes = append(indexes, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s ON %s.%s (%s);",
-				s.Table, field.Name, s.Project, s.Table, field.Name))
+		if field.IsIndexed() {
+			indexes = append(indexes, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s ON %s.%s %s;",
+				s.Table, field.Name, s.Project, s.Table, field.PostgresIndexTarget()))
 		}
 	}
 
@@ -279,11 +236,16 @@ type YAMLSchema struct {
 
 // YAMLField 定义 YAML 格式的字段配置
 type YAMLField struct {
-	Name        string `yaml:"name"`
-	Type        string `yaml:"type"`
-	Description string `yaml:"description,omitempty"`
-	Required    bool   `yaml:"required"`
-	Indexed     bool   `yaml:"indexed"`
+	Name        string      `yaml:"name"`
+	Type        string      `yaml:"type"`
+	Description string      `yaml:"description,omitempty"`
+	Required    bool        `yaml:"required"`
+	Indexed     bool        `yaml:"indexed"`
+	Index       *IndexSpec  `yaml:"index,omitempty"`
+	Default     interface{} `yaml:"default,omitempty"`
+	Nullable    *bool       `yaml:"nullable,omitempty"`
+	Fields      []YAMLField `yaml:"fields,omitempty"`    // object 字段或对象数组元素的子字段
+	ItemType    string      `yaml:"item_type,omitempty"` // array 字段的元素类型
 }
 
 // FromYAML 从 YAML 数据创建 Schema
@@ -302,25 +264,17 @@ func SchemaFromYAML(data []byte) (*Schema, error) {
 	}
 
 	for _, yamlField := range yamlSchema.Fields {
-		fieldType := FieldType(yamlField.Type)
-		switch fieldType {
-		case FieldTypeString, FieldTypeInt, FieldTypeFloat, FieldTypeBool,
-			FieldTypeDateTime, FieldTypeJSON, FieldTypeTime, FieldTypeDuration:
-			// 有效类型
-		default:
-			return nil, fmt.Errorf("invalid field type for field %s: %s", yamlField.Name, yamlField.Type)
-		}
-
-		field := &Field{
-			Name:        yamlField.Name,
-			Type:        fieldType,
-			Description: yamlField.Description,
-			Required:    yamlField.Required,
-			Indexed:     yamlField.Indexed,
+		field, err := yamlField.toField()
+		if err != nil {
+			return nil, err
 		}
 		schema.Fields = append(schema.Fields, field)
 	}
 
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+
 	return schema, nil
 }
 
@@ -333,19 +287,64 @@ func (s *Schema) ToYAML() ([]byte, error) {
 	}
 
 	for _, field := range s.Fields {
-		yamlField := YAMLField{
-			Name:        field.Name,
-			Type:        string(field.Type),
-			Description: field.Description,
-			Required:    field.Required,
-			Indexed:     field.Indexed,
-		}
-		yamlSchema.Fields = append(yamlSchema.Fields, yamlField)
+		yamlSchema.Fields = append(yamlSchema.Fields, fieldToYAML(field))
 	}
 
 	return yaml.Marshal(yamlSchema)
 }
 
+// toField 转换为字段定义，递归转换子字段
+func (yf YAMLField) toField() (*Field, error) {
+	fieldType := FieldType(yf.Type)
+	switch fieldType {
+	case FieldTypeString, FieldTypeInt, FieldTypeFloat, FieldTypeBool,
+		FieldTypeDateTime, FieldTypeJSON, FieldTypeTime, FieldTypeDuration,
+		FieldTypeObject, FieldTypeArray, FieldTypeIP:
+		// 有效类型
+	default:
+		return nil, fmt.Errorf("invalid field type for field %s: %s", yf.Name, yf.Type)
+	}
+
+	field := &Field{
+		Name:        yf.Name,
+		Type:        fieldType,
+		Description: yf.Description,
+		Required:    yf.Required,
+		Indexed:     yf.Indexed,
+		Index:       yf.Index,
+		Default:     yf.Default,
+		Nullable:    yf.Nullable,
+		ItemType:    FieldType(yf.ItemType),
+	}
+	for _, sub := range yf.Fields {
+		subField, err := sub.toField()
+		if err != nil {
+			return nil, fmt.Errorf("in field %s: %w", yf.Name, err)
+		}
+		field.Fields = append(field.Fields, subField)
+	}
+	return field, nil
+}
+
+// fieldToYAML 转换为 YAML 字段配置，递归转换子字段
+func fieldToYAML(field *Field) YAMLField {
+	yf := YAMLField{
+		Name:        field.Name,
+		Type:        string(field.Type),
+		Description: field.Description,
+		Required:    field.Required,
+		Indexed:     field.Indexed,
+		Index:       field.Index,
+		Default:     field.Default,
+		Nullable:    field.Nullable,
+		ItemType:    string(field.ItemType),
+	}
+	for _, sub := range field.Fields {
+		yf.Fields = append(yf.Fields, fieldToYAML(sub))
+	}
+	return yf
+}
+
 // LoadSchemaFromFile 从 YAML 文件加载 Schema
 func LoadSchemaFromFile(filename string) (*Schema, error) {
 	data, err := os.ReadFile(filename)
@@ -380,21 +379,55 @@ func (s *Schema) SaveToFile(filename string) error {
 	return nil
 }
 
-// ValidateLogEntry 验证日志条目是否符合 schema 定义
+// ValidateLogEntry 验证日志条目是否符合 schema 定义，失败时返回 ErrValidation，
+// 字段问题会全部收集到 ValidationError.Fields 中
 func (s *Schema) ValidateLogEntry(entry *LogEntry) error {
+	return invalid(s.validateLogEntry(entry))
+}
+
+// UnknownFields 返回 strict schema 未定义的日志字段错误，按字段名排序。
+// 未开启 strict 或有 Rest 字段收集未定义字段时返回 nil
+func (s *Schema) UnknownFields(values map[string]interface{}) []*FieldError {
+	if !s.Strict || s.RestField() != nil {
+		return nil
+	}
+	var errs []*FieldError
+	for name, value := range values {
+		if s.GetField(name) == nil {
+			errs = append(errs, unknownField(name, value))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// validateLogEntry 校验基本字段、必填字段与字段类型，全部通过后收集 Rest 字段
+func (s *Schema) validateLogEntry(entry *LogEntry) error {
 	if entry.Project != s.Project || entry.Table != s.Table {
 		return fmt.Errorf("project 或 table 不匹配")
 	}
+	s.applyTimestampPolicy(entry)
+
+	var errs []*FieldError
 
 	// 验证基本字段
-	if entry.Level == "" {
-		return fmt.Errorf("level 字段不能为空")
-	}
-	if entry.Message == "" {
-		return fmt.Errorf("message 字段不能为空")
-	}
-	if entry.Timestamp.IsZero() {
-		return fmt.Errorf("timestamp 字段不能为空")
+	for _, base := range []struct {
+		name  string
+		typ   FieldType
+		empty bool
+	}{
+		{"level", FieldTypeString, entry.Level == ""},
+		{"message", FieldTypeString, entry.Message == ""},
+		{"timestamp", FieldTypeDateTime, entry.Timestamp.IsZero()},
+	} {
+		if base.empty {
+			errs = append(errs, &FieldError{
+				Field:    base.name,
+				Reason:   FieldErrorMissing,
+				Expected: string(base.typ),
+				Message:  fmt.Sprintf("%s 字段不能为空", base.name),
+			})
+		}
 	}
 
 	// 找到 Rest 字段（如果存在）
@@ -418,17 +451,33 @@ func (s *Schema) ValidateLogEntry(entry *LogEntry) error {
 		}
 
 		value, exists := entry.Fields[strings.ToLower(field.Name)]
-		if field.Required && !exists {
-			return fmt.Errorf("缺少必填字段: %s", field.Name)
+		if field.Required && !field.Deprecated && !exists {
+			errs = append(errs, missingField(field.Name, field.Type))
+			continue
+		}
+		if !exists && field.Default != nil {
+			// 非必填字段缺失时写入默认值
+			if entry.Fields == nil {
+				entry.Fields = make(map[string]interface{})
+			}
+			entry.Fields[strings.ToLower(field.Name)] = field.Default
+			continue
 		}
 		if !exists {
 			continue
 		}
-
-		// 验证字段类型
-		if err := s.validateFieldValue(field.Type, value); err != nil {
-			return fmt.Errorf("字段 %s 类型错误: %w", field.Name, err)
+		if value == nil {
+			if !field.IsNullable() {
+				errs = append(errs, nullField(field.Name, field.Type))
+			}
+			continue
 		}
+
+		// 验证字段类型与长度、取值范围、正则约束，对象与数组递归校验
+		errs = append(errs, field.validateValue(field.Name, value)...)
+	}
+	if len(errs) > 0 {
+		return NewFieldErrors(errs)
 	}
 
 	// 如果有 Rest 字段，收集所有未定义的字段
@@ -519,6 +568,10 @@ func (s *Schema) validateFieldValue(fieldType FieldType, value interface{}) erro
 		default:
 			return fmt.Errorf("期望 duration 类型")
 		}
+	case FieldTypeIP:
+		if _, err := ParseIP(value); err != nil {
+			return err
+		}
 	case FieldTypeJSON, FieldTypeRest:
 		// JSON 和 Rest 类型可以是任何值
 	default:
@@ -528,26 +581,67 @@ func (s *Schema) validateFieldValue(fieldType FieldType, value interface{}) erro
 	return nil
 }
 
-// Validate 验证 schema 是否有效
-func (s *Schema) Validate() error {
-	if s.Project == "" {
-		return fmt.Errorf("project name is required")
+// StoresTags 日志表是否包含保存 LogEntry.Tags 的内置 tags 列；
+// schema 自定义了同名字段时以该字段为准，不再创建内置列
+func (s *Schema) StoresTags() bool {
+	for _, field := range s.Fields {
+		if field.Name == TagsColumn {
+			return false
+		}
 	}
-	if s.Table == "" {
-		return fmt.Errorf("table name is required")
+	return true
+}
+
+// Validate 验证 schema 是否有效，失败时返回 ErrValidation
+func (s *Schema) Validate() error {
+	return invalid(s.validate())
+}
+
+// validate 依次校验表名、字段与聚合定义
+func (s *Schema) validate() error {
+	if err := validateTableIdentifiers(s.Project, s.Table); err != nil {
+		return err
 	}
 	if len(s.Fields) == 0 {
 		return fmt.Errorf("at least one field is required")
 	}
 
-	// 验证字段
+	// 验证字段，顶层字段名会作为列名，子字段名只出现在 JSON 路径中
 	fieldNames := make(map[string]bool)
 	for _, field := range s.Fields {
+		if err := validateIdentifier("field", field.Name); err != nil {
+			return err
+		}
+		if isReservedColumn(field.Name) {
+			return fmt.Errorf("field name %s is reserved for a built-in column", field.Name)
+		}
 		if err := validateField(field, fieldNames); err != nil {
 			return err
 		}
 	}
 
+	if s.Strict && s.AutoEvolve {
+		return fmt.Errorf("strict and auto_evolve cannot both be enabled")
+	}
+	if s.Timestamps != nil {
+		if err := s.Timestamps.validate(); err != nil {
+			return err
+		}
+	}
+	if err := s.validateCaptureHeaders(); err != nil {
+		return err
+	}
+
+	// 验证聚合定义
+	if err := s.validateAggregates(); err != nil {
+		return err
+	}
+
+	// 验证 ClickHouse 建表参数
+	if err := s.validateClickHouse(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -561,35 +655,40 @@ func validateField(field *Field, fieldNames map[string]bool) error {
 	}
 	fieldNames[field.Name] = true
 
+	if !field.IsNullable() && !field.Required && field.Default == nil {
+		return fmt.Errorf("non-nullable field %s must be required or have a default", field.Name)
+	}
+
 	switch field.Type {
 	case FieldTypeString, FieldTypeInt, FieldTypeFloat, FieldTypeBool, FieldTypeDateTime,
-		FieldTypeTime, FieldTypeDuration, FieldTypeJSON, FieldTypeRest:
+		FieldTypeTime, FieldTypeDuration, FieldTypeJSON, FieldTypeRest, FieldTypeIP:
 		// 基本类型不需要额外验证
-	case FieldTypeObject:
-		if len(field.Fields) == 0 {
-			return fmt.Errorf("object field %s must have sub-fields", field.Name)
-		}
-		// 验证子字段
-		subFieldNames := make(map[string]bool)
-		for _, subField := range field.Fields {
-			if err := validateField(subField, subFieldNames); err != nil {
-				return fmt.Errorf("in field %s: %w", field.Name, err)
-			}
-		}
-	case FieldTypeArray:
-		if field.ItemType == "" {
-			return fmt.Errorf("array field %s must specify item_type", field.Name)
-		}
-		switch field.ItemType {
-		case FieldTypeString, FieldTypeInt, FieldTypeFloat, FieldTypeBool, FieldTypeDateTime,
-			FieldTypeObject, FieldTypeJSON, FieldTypeRest:
-			// 有效的数组元素类型
-		default:
-			return fmt.Errorf("invalid array item type for field %s: %s", field.Name, field.ItemType)
+	case FieldTypeObject, FieldTypeArray:
+		if err := validateNestedDefinition(field); err != nil {
+			return err
 		}
 	default:
 		return fmt.Errorf("invalid field type for field %s: %s", field.Name, field.Type)
 	}
 
+	if err := field.validateConstraints(); err != nil {
+		return err
+	}
+
+	if err := field.validateIndex(); err != nil {
+		return err
+	}
+
+	if field.Default != nil {
+		if errs := field.validateValue(field.Name, field.Default); len(errs) > 0 {
+			return fmt.Errorf("invalid default for field %s: %s", field.Name, errs[0].Message)
+		}
+	}
+
 	return nil
 }
+
+// IsNullable 字段列是否允许 NULL，未显式设置 nullable 时允许
+func (f *Field) IsNullable() bool {
+	return f.Nullable == nil || *f.Nullable
+}
@@ -1,12 +1,14 @@
 package models
 
 import (
+	"encoding/json"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestSchemaYAML(t *testing.T) {
@@ -172,3 +174,190 @@ fields:
 	assert.Equal(t, FieldTypeDuration, schema.Fields[6].Type)
 	assert.Equal(t, FieldTypeJSON, schema.Fields[7].Type)
 }
+
+func TestSchemaETag(t *testing.T) {
+	schema := &Schema{
+		Project: "test",
+		Table:   "logs",
+		Fields:  []*Field{{Name: "message", Type: FieldTypeString}},
+	}
+
+	etag := schema.ETag()
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, etag, schema.Clone().ETag())
+
+	changed := schema.Clone()
+	changed.Fields[0].Indexed = true
+	assert.NotEqual(t, etag, changed.ETag())
+}
+
+func TestSchemaValidationError(t *testing.T) {
+	err := (&Schema{Table: "logs"}).Validate()
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.Equal(t, "project name is required", err.Error())
+
+	schema := &Schema{
+		Project: "test",
+		Table:   "logs",
+		Fields:  []*Field{{Name: "user_id", Type: FieldTypeInt, Required: true}},
+	}
+	require.NoError(t, schema.Validate())
+
+	err = schema.ValidateLogEntry(&LogEntry{Project: "test", Table: "logs", Level: "info", Message: "m", Timestamp: time.Now()})
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.NotErrorIs(t, err, ErrSchemaNotFound)
+}
+
+func TestValidateLogEntryFieldErrors(t *testing.T) {
+	schema := &Schema{
+		Project: "test",
+		Table:   "logs",
+		Fields: []*Field{
+			{Name: "user_id", Type: FieldTypeInt, Required: true},
+			{Name: "action", Type: FieldTypeString, Required: true},
+			{Name: "success", Type: FieldTypeBool},
+		},
+	}
+
+	err := schema.ValidateLogEntry(&LogEntry{
+		Project:   "test",
+		Table:     "logs",
+		Message:   "m",
+		Timestamp: time.Now(),
+		Fields:    map[string]interface{}{"user_id": "abc", "success": "yes"},
+	})
+	require.ErrorIs(t, err, ErrValidation)
+
+	fields := FieldErrors(err)
+	require.Len(t, fields, 4)
+	assert.Equal(t, "level", fields[0].Field)
+	assert.Equal(t, FieldErrorMissing, fields[0].Reason)
+	assert.Equal(t, "user_id", fields[1].Field)
+	assert.Equal(t, FieldErrorInvalidType, fields[1].Reason)
+	assert.Equal(t, "int", fields[1].Expected)
+	assert.Equal(t, "abc", fields[1].Received)
+	assert.Equal(t, "string", fields[1].ReceivedType)
+	assert.Equal(t, "action", fields[2].Field)
+	assert.Equal(t, FieldErrorMissing, fields[2].Reason)
+	assert.Equal(t, "success", fields[3].Field)
+	assert.Contains(t, err.Error(), "缺少必填字段: action")
+}
+
+func TestFieldDefaultsAndNullable(t *testing.T) {
+	notNull := false
+	schema := &Schema{
+		Project: "test",
+		Table:   "logs",
+		Fields: []*Field{
+			{Name: "status", Type: FieldTypeString, Nullable: &notNull},
+		},
+	}
+	assert.ErrorIs(t, schema.Validate(), ErrValidation, "non-nullable optional field needs a default")
+
+	schema.Fields[0].Default = 1
+	assert.ErrorContains(t, schema.Validate(), "invalid default for field status")
+
+	schema.Fields[0].Default = "new"
+	require.NoError(t, schema.Validate())
+	assert.False(t, schema.Fields[0].IsNullable())
+	assert.True(t, (&Field{Name: "note"}).IsNullable())
+
+	entry := &LogEntry{Project: "test", Table: "logs", Level: "info", Message: "m", Timestamp: time.Now()}
+	require.NoError(t, schema.ValidateLogEntry(entry))
+	assert.Equal(t, "new", entry.Fields["status"])
+}
+
+func TestSchemaYAMLRollups(t *testing.T) {
+	var schema Schema
+	require.NoError(t, yaml.Unmarshal([]byte(`
+project: app
+table: requests
+fields:
+  - name: latency
+    type: float
+rollup_after: 30d
+rollups:
+  - name: hourly
+    interval: 1h
+    group_by: [level]
+    metrics:
+      - func: avg
+        field: latency
+`), &schema))
+	require.NoError(t, schema.Validate())
+	rollup, ok := schema.GetRollup("hourly")
+	require.True(t, ok)
+	assert.Equal(t, []string{"level"}, rollup.GroupBy)
+
+	now := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	cutoff, ok := schema.RollupCutoff(now)
+	require.True(t, ok)
+	assert.Equal(t, now.AddDate(0, 0, -30), cutoff)
+
+	schema.RollupAfter = ""
+	assert.ErrorContains(t, schema.Validate(), "rollup_after is required")
+	schema.RollupAfter = "soon"
+	assert.ErrorContains(t, schema.Validate(), "invalid rollup_after")
+	schema.RollupAfter = "1d"
+	schema.Rollups[0].Metrics[0].Field = "missing"
+	assert.ErrorContains(t, schema.Validate(), "rollup hourly references unknown field")
+}
+
+func TestSchemaUnknownFields(t *testing.T) {
+	schema := &Schema{
+		Project: "test",
+		Table:   "logs",
+		Fields:  []*Field{{Name: "user_id", Type: FieldTypeInt}},
+	}
+	values := map[string]interface{}{"user_id": 1, "usr_id": 2, "actoin": "login"}
+	assert.Nil(t, schema.UnknownFields(values), "unknown fields are dropped unless strict")
+
+	schema.Strict = true
+	require.NoError(t, schema.Validate())
+	errs := schema.UnknownFields(values)
+	require.Len(t, errs, 2)
+	assert.Equal(t, "actoin", errs[0].Field)
+	assert.Equal(t, FieldErrorUnknown, errs[0].Reason)
+	assert.Equal(t, "login", errs[0].Received)
+	assert.Equal(t, "usr_id", errs[1].Field)
+
+	schema.AutoEvolve = true
+	assert.ErrorContains(t, schema.Validate(), "strict and auto_evolve cannot both be enabled")
+	schema.AutoEvolve = false
+
+	// Rest 字段收集未定义的字段，strict 不生效
+	schema.Fields = append(schema.Fields, &Field{Name: "extra", Type: FieldTypeRest})
+	assert.Nil(t, schema.UnknownFields(values))
+}
+
+func TestCaptureHeaders(t *testing.T) {
+	var schema Schema
+	require.NoError(t, yaml.Unmarshal([]byte(`
+project: app
+table: access
+fields:
+  - name: user_agent
+    type: string
+  - name: trace
+    type: string
+capture_headers:
+  - User-Agent
+  - header: X-Trace-ID
+    field: trace
+`), &schema))
+	require.Len(t, schema.CaptureHeaders, 2)
+	assert.Equal(t, "user_agent", schema.CaptureHeaders[0].FieldName())
+	assert.Equal(t, "trace", schema.CaptureHeaders[1].FieldName())
+	require.NoError(t, schema.Validate())
+
+	var decoded SchemaOptions
+	require.NoError(t, json.Unmarshal([]byte(`{"capture_headers":["Referer",{"header":"X-Trace-ID","field":"trace"}]}`), &decoded))
+	assert.Equal(t, []*HeaderCapture{{Header: "Referer"}, {Header: "X-Trace-ID", Field: "trace"}}, decoded.CaptureHeaders)
+
+	schema.CaptureHeaders = []*HeaderCapture{{Header: "Referer"}}
+	assert.ErrorContains(t, schema.Validate(), "field referer for header Referer is not defined")
+	schema.CaptureHeaders = []*HeaderCapture{{Header: "User-Agent"}, {Header: "X-UA", Field: "user_agent"}}
+	assert.ErrorContains(t, schema.Validate(), "captured more than once")
+	schema.CaptureHeaders = []*HeaderCapture{{Header: " "}}
+	assert.ErrorContains(t, schema.Validate(), "header name is required")
+}
@@ -0,0 +1,12 @@
+package models
+
+// TableSize 日志表占用的存储空间，数值来自数据库的统计信息或抽样估算，可能滞后于最近的写入
+type TableSize struct {
+	Project    string `json:"project"`
+	Table      string `json:"table"`
+	Rows       int64  `json:"rows"`        // 估算的行数
+	DataBytes  int64  `json:"data_bytes"`  // 表数据的大小
+	IndexBytes int64  `json:"index_bytes"` // 索引的大小，无法获取时为 0
+	TotalBytes int64  `json:"total_bytes"` // 数据、索引与其他附属存储的合计
+	Source     string `json:"source"`      // 统计来源，如 pg_total_relation_size、information_schema、system.parts
+}
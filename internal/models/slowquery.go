@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// SlowQuery 一次执行时间超过阈值的日志查询
+type SlowQuery struct {
+	Time       time.Time `json:"time"`
+	Project    string    `json:"project"`
+	Table      string    `json:"table"`
+	Endpoint   string    `json:"endpoint"`    // 查询接口的路由，如 POST /api/v1/logs/:project/:table/search
+	Query      string    `json:"query"`       // 查询条件的 JSON
+	DurationMS float64   `json:"duration_ms"` // 访问存储的耗时
+	Rows       int       `json:"rows"`        // 返回的行数，失败时为 0
+	Caller     string    `json:"caller"`      // 调用方，API Key 的摘要或 X-User
+	ClientIP   string    `json:"client_ip"`
+	Backend    string    `json:"backend"`         // 存储类型
+	Error      string    `json:"error,omitempty"` // 查询失败或超时的错误
+}
+
+// SlowQuerySort 慢查询的排序方式
+const (
+	SlowQueryByTime     = "time"     // 最近的在前
+	SlowQueryByDuration = "duration" // 最慢的在前
+)
+
+// SlowQueryFilter 列出慢查询的条件，零值字段不参与过滤
+type SlowQueryFilter struct {
+	Project     string
+	Table       string
+	From        time.Time // 记录时间不早于 From
+	To          time.Time // 记录时间早于 To
+	MinDuration time.Duration
+	Sort        string // SlowQueryByTime 或 SlowQueryByDuration，默认按时间
+	Limit       int    // 默认 100
+	Offset      int
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// IngestTimeColumn 保存 LogEntry.IngestTime 的内置列名，与日志自身的 timestamp 分开记录服务端接收时间
+const IngestTimeColumn = "ingest_time"
+
+// DefaultTimestampTolerance clamp 模式下日志时间与接收时间默认允许相差的范围
+const DefaultTimestampTolerance = 5 * time.Minute
+
+// TimestampMode 日志时间的处理方式
+type TimestampMode string
+
+const (
+	TimestampClient TimestampMode = "client" // 信任客户端提供的时间，默认
+	TimestampClamp  TimestampMode = "clamp"  // 早于或晚于接收时间超过 tolerance 的时间截断到边界
+	TimestampServer TimestampMode = "server" // 总是使用服务端接收时间
+)
+
+// TimestampPrecision 日志时间列保存的精度
+type TimestampPrecision string
+
+const (
+	PrecisionSecond TimestampPrecision = "s"
+	PrecisionMilli  TimestampPrecision = "ms"
+	PrecisionMicro  TimestampPrecision = "us"
+	PrecisionNano   TimestampPrecision = "ns"
+)
+
+// precisionDigits 各精度对应的秒的小数位数
+var precisionDigits = map[TimestampPrecision]int{
+	PrecisionSecond: 0,
+	PrecisionMilli:  3,
+	PrecisionMicro:  6,
+	PrecisionNano:   9,
+}
+
+// TimestampPolicy 日志时间的处理规则，防止时钟偏差或延迟发送的日志落在错误的时间范围
+type TimestampPolicy struct {
+	Mode      TimestampMode `yaml:"mode,omitempty" json:"mode,omitempty"`           // 为空时同 client
+	Tolerance string        `yaml:"tolerance,omitempty" json:"tolerance,omitempty"` // clamp 模式允许的偏差，如 30s、1h、1d，默认 DefaultTimestampTolerance
+
+	// Precision timestamp 列的精度，为空时使用存储后端支持的最高精度（PostgreSQL、MySQL 为微秒，其余为纳秒）。
+	// 超出精度的部分在写入前截断，各后端的结果一致
+	Precision TimestampPrecision `yaml:"precision,omitempty" json:"precision,omitempty"`
+}
+
+// validate 检查模式、偏差范围与精度
+func (p *TimestampPolicy) validate() error {
+	switch p.Mode {
+	case "", TimestampClient, TimestampClamp, TimestampServer:
+	default:
+		return fmt.Errorf("unsupported timestamp mode: %q", p.Mode)
+	}
+	if _, ok := precisionDigits[p.Precision]; p.Precision != "" && !ok {
+		return fmt.Errorf("unsupported timestamp precision: %q (use s, ms, us or ns)", p.Precision)
+	}
+	if p.Tolerance != "" {
+		if p.Mode != TimestampClamp {
+			return fmt.Errorf("timestamp tolerance only applies to the clamp mode")
+		}
+		if _, err := ParseRetention(p.Tolerance); err != nil {
+			return fmt.Errorf("invalid timestamp tolerance: %s", p.Tolerance)
+		}
+	}
+	return nil
+}
+
+// tolerance 返回 clamp 模式允许的偏差
+func (p *TimestampPolicy) tolerance() time.Duration {
+	if d, err := ParseRetention(p.Tolerance); err == nil {
+		return d
+	}
+	return DefaultTimestampTolerance
+}
+
+// TimestampDigits 返回 timestamp 列保存的秒的小数位数，不超过存储后端支持的 max 位
+func (s *Schema) TimestampDigits(max int) int {
+	if s.Timestamps == nil || s.Timestamps.Precision == "" {
+		return max
+	}
+	return min(precisionDigits[s.Timestamps.Precision], max)
+}
+
+// StoresIngestTime 日志表是否包含保存 LogEntry.IngestTime 的内置 ingest_time 列；
+// schema 自定义了同名字段时以该字段为准，不再创建内置列
+func (s *Schema) StoresIngestTime() bool {
+	return s.GetField(IngestTimeColumn) == nil
+}
+
+// applyTimestampPolicy 首次处理日志时以当前时间作为接收时间写入 IngestTime，再按 schema 的规则调整日志时间。
+// 调整只依赖 IngestTime，同一条日志重复校验时结果不变
+func (s *Schema) applyTimestampPolicy(entry *LogEntry) {
+	if entry.IngestTime.IsZero() {
+		entry.IngestTime = time.Now()
+	}
+	policy := s.Timestamps
+	if policy == nil {
+		return
+	}
+
+	received := entry.IngestTime
+	switch policy.Mode {
+	case TimestampServer:
+		entry.Timestamp = received
+	case TimestampClamp:
+		tolerance := policy.tolerance()
+		switch {
+		case entry.Timestamp.IsZero():
+			entry.Timestamp = received
+		case entry.Timestamp.Before(received.Add(-tolerance)):
+			entry.Timestamp = received.Add(-tolerance)
+		case entry.Timestamp.After(received.Add(tolerance)):
+			entry.Timestamp = received.Add(tolerance)
+		}
+	}
+	if digits, ok := precisionDigits[policy.Precision]; ok {
+		unit := time.Second
+		for i := 0; i < digits; i++ {
+			unit /= 10
+		}
+		entry.Timestamp = entry.Timestamp.Truncate(unit)
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampPolicy(t *testing.T) {
+	received := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	schema := &Schema{
+		Project: "app",
+		Table:   "events",
+		Fields:  []*Field{{Name: "user", Type: FieldTypeString}},
+	}
+	validate := func(ts time.Time) *LogEntry {
+		entry := &LogEntry{Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: ts, IngestTime: received}
+		require.NoError(t, schema.ValidateLogEntry(entry))
+		return entry
+	}
+
+	skewed := received.Add(-time.Hour)
+	assert.Equal(t, skewed, validate(skewed).Timestamp, "client timestamps are trusted by default")
+
+	schema.Timestamps = &TimestampPolicy{Mode: TimestampClamp, Tolerance: "10m"}
+	require.NoError(t, schema.Validate())
+	assert.Equal(t, received.Add(-10*time.Minute), validate(skewed).Timestamp)
+	assert.Equal(t, received.Add(10*time.Minute), validate(received.Add(48*time.Hour)).Timestamp)
+	assert.Equal(t, received.Add(time.Minute), validate(received.Add(time.Minute)).Timestamp)
+
+	// 重复校验同一条日志结果不变
+	entry := validate(skewed)
+	require.NoError(t, schema.ValidateLogEntry(entry))
+	assert.Equal(t, received.Add(-10*time.Minute), entry.Timestamp)
+
+	schema.Timestamps = &TimestampPolicy{Mode: TimestampClamp}
+	assert.Equal(t, received.Add(-DefaultTimestampTolerance), validate(skewed).Timestamp)
+
+	schema.Timestamps = &TimestampPolicy{Mode: TimestampServer}
+	assert.Equal(t, received, validate(skewed).Timestamp)
+	// 服务端时间模式下客户端可以不提供时间
+	assert.Equal(t, received, validate(time.Time{}).Timestamp)
+
+	// 未设置接收时间时以校验时间填充
+	schema.Timestamps = nil
+	entry = &LogEntry{Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: skewed}
+	require.NoError(t, schema.ValidateLogEntry(entry))
+	assert.WithinDuration(t, time.Now(), entry.IngestTime, time.Minute)
+
+	// 按精度截断，未设置模式时信任客户端时间
+	schema.Timestamps = &TimestampPolicy{Precision: PrecisionMilli}
+	require.NoError(t, schema.Validate())
+	precise := skewed.Add(123456789)
+	assert.Equal(t, skewed.Add(123*time.Millisecond), validate(precise).Timestamp)
+	assert.Equal(t, 3, schema.TimestampDigits(9))
+	assert.Equal(t, 0, (&Schema{SchemaOptions: SchemaOptions{Timestamps: &TimestampPolicy{Precision: PrecisionSecond}}}).TimestampDigits(6))
+	schema.Timestamps = &TimestampPolicy{Precision: PrecisionNano}
+	assert.Equal(t, precise, validate(precise).Timestamp)
+	assert.Equal(t, 6, schema.TimestampDigits(6), "limited by the backend")
+	schema.Timestamps = nil
+	assert.Equal(t, 9, schema.TimestampDigits(9))
+
+	for _, policy := range []*TimestampPolicy{
+		{Precision: "minute"},
+		{Mode: "local"},
+		{Mode: TimestampClamp, Tolerance: "soon"},
+		{Mode: TimestampServer, Tolerance: "5m"},
+	} {
+		schema.Timestamps = policy
+		assert.ErrorIs(t, schema.Validate(), ErrValidation, policy)
+	}
+}
+
+func TestStoresIngestTime(t *testing.T) {
+	schema := &Schema{Project: "app", Table: "events", Fields: []*Field{{Name: "user", Type: FieldTypeString}}}
+	assert.True(t, schema.StoresIngestTime())
+	schema.Fields = append(schema.Fields, &Field{Name: IngestTimeColumn, Type: FieldTypeDateTime})
+	assert.False(t, schema.StoresIngestTime())
+}
@@ -0,0 +1,185 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Generator 根据 Go 类型生成 schema，具名结构体注册为组件并以 $ref 引用，
+// 属性名取自 json 标签，匿名嵌入的结构体展开到外层对象中
+type Generator struct {
+	schemas  map[string]*Schema
+	names    map[reflect.Type]string
+	enums    map[reflect.Type][]string
+	required map[reflect.Type][]string
+	pending  map[reflect.Type]bool // 已指定名称、尚未生成的类型
+}
+
+// NewGenerator 创建 Generator
+func NewGenerator() *Generator {
+	return &Generator{
+		schemas:  make(map[string]*Schema),
+		names:    make(map[reflect.Type]string),
+		enums:    make(map[reflect.Type][]string),
+		required: make(map[reflect.Type][]string),
+		pending:  make(map[reflect.Type]bool),
+	}
+}
+
+// Enum 声明 v 的类型只能取 values 中的值，需在生成用到该类型的 schema 之前调用
+func (g *Generator) Enum(v interface{}, values ...string) {
+	g.enums[reflect.TypeOf(v)] = values
+}
+
+// Name 指定结构体 v 的组件名，用于类型名本身不够明确的情况，需在生成 schema 之前调用
+func (g *Generator) Name(v interface{}, name string) {
+	t := indirect(reflect.TypeOf(v))
+	g.names[t] = name
+	g.pending[t] = true
+}
+
+// Require 声明结构体 v 在请求中必须提供的属性，需在生成 schema 之前调用
+func (g *Generator) Require(v interface{}, properties ...string) {
+	g.required[indirect(reflect.TypeOf(v))] = properties
+}
+
+// Schema 返回 v 的类型对应的 schema，v 为 nil 时返回不限制类型的 schema
+func (g *Generator) Schema(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return g.schemaFor(reflect.TypeOf(v))
+}
+
+// Components 返回已注册的组件 schema
+func (g *Generator) Components() map[string]*Schema {
+	return g.schemas
+}
+
+// schemaFor 生成类型 t 的 schema
+func (g *Generator) schemaFor(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		schema := g.schemaFor(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	}
+	if values, ok := g.enums[t]; ok {
+		return &Schema{Type: "string", Enum: values}
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	// 自定义序列化的类型无法从结构推断
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		// nil 切片编码为 null
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok || g.pending[t] {
+			if !ok {
+				name = g.componentName(t)
+				g.names[t] = name
+			}
+			delete(g.pending, t)
+			// 先占位，递归引用自身的类型（如嵌套字段）直接使用引用
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return Ref(name)
+	default:
+		return &Schema{}
+	}
+}
+
+// structSchema 生成结构体的对象 schema
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(schema, t)
+	for _, name := range g.required[t] {
+		if _, ok := schema.Properties[name]; ok {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// addFields 将结构体字段加入对象 schema 的属性
+func (g *Generator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			if embedded := indirect(f.Type); embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema.Properties[name] = g.schemaFor(f.Type)
+	}
+}
+
+// componentName 组件名使用类型名，首字母大写；不同包的同名类型加上包名前缀
+func (g *Generator) componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	candidate := string(name)
+	if _, taken := g.schemas[candidate]; !taken {
+		return candidate
+	}
+	pkg := t.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	pkgName := []rune(pkg)
+	pkgName[0] = unicode.ToUpper(pkgName[0])
+	return string(pkgName) + candidate
+}
+
+// indirect 去掉指针
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,62 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type color string
+
+type node struct {
+	Name     string            `json:"name"`
+	Color    color             `json:"color,omitempty"`
+	Children []*node           `json:"children,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	Weight   *float64          `json:"weight,omitempty"`
+	Created  time.Time         `json:"created_at"`
+	Raw      json.RawMessage   `json:"raw,omitempty"`
+	Any      interface{}       `json:"any"`
+	Skipped  string            `json:"-"`
+	internal string
+	embedded
+}
+
+type embedded struct {
+	Extra int64 `json:"extra"`
+}
+
+func TestGenerator(t *testing.T) {
+	g := NewGenerator()
+	g.Enum(color(""), "red", "green")
+	g.Require(node{}, "name", "missing")
+
+	ref := g.Schema(&node{})
+	assert.Equal(t, "#/components/schemas/Node", ref.Ref)
+	schema := g.Components()["Node"]
+	require.NotNil(t, schema)
+	assert.Equal(t, "object", schema.Type)
+	assert.Equal(t, []string{"name"}, schema.Required)
+	assert.Equal(t, []string{"red", "green"}, schema.Properties["color"].Enum)
+	assert.Equal(t, "#/components/schemas/Node", schema.Properties["children"].Items.Ref, "recursive types use references")
+	assert.Equal(t, "string", schema.Properties["labels"].AdditionalProperties.Type)
+	assert.True(t, schema.Properties["weight"].Nullable)
+	assert.Equal(t, "date-time", schema.Properties["created_at"].Format)
+	assert.Equal(t, &Schema{}, schema.Properties["raw"], "custom marshalers are unconstrained")
+	assert.Equal(t, &Schema{}, schema.Properties["any"])
+	assert.Equal(t, "int64", schema.Properties["extra"].Format, "embedded structs are flattened")
+	assert.NotContains(t, schema.Properties, "Skipped")
+	assert.NotContains(t, schema.Properties, "internal")
+
+	list := g.Schema([]node{})
+	assert.Equal(t, "array", list.Type)
+	assert.Equal(t, ref.Ref, list.Items.Ref)
+	assert.Len(t, g.Components(), 1)
+
+	g.Name(embedded{}, "Extras")
+	assert.Equal(t, "#/components/schemas/Extras", g.Schema(embedded{}).Ref)
+	assert.Contains(t, g.Components()["Extras"].Properties, "extra")
+}
@@ -0,0 +1,211 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxProblems 一次校验最多报告的问题数
+const maxProblems = 20
+
+// ValidationError 值不符合文档的错误，Problems 列出每个不符合的位置
+type ValidationError struct {
+	Problems []string
+}
+
+// Error 返回全部问题，以分号分隔
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// DecodeJSON 解码 JSON，数字保留为 json.Number 以便区分整数
+func DecodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+// Validate 校验 value 是否符合 schema，value 为 DecodeJSON 的解码结果，
+// path 为问题描述中使用的位置前缀，如 body
+func (d *Document) Validate(schema *Schema, value interface{}, path string) error {
+	v := &validator{doc: d}
+	v.check(schema, value, path)
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// ValidateParameter 校验查询或请求头参数的原始值
+func (d *Document) ValidateParameter(p *Parameter, raw string) error {
+	schema := d.resolve(p.Schema)
+	path := p.In + " parameter " + p.Name
+	var value interface{} = raw
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return &ValidationError{Problems: []string{fmt.Sprintf("%s: %q is not a valid %s", path, raw, schema.Type)}}
+		}
+		value = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return &ValidationError{Problems: []string{fmt.Sprintf("%s: %q is not a boolean", path, raw)}}
+		}
+		value = b
+	}
+	return d.Validate(schema, value, path)
+}
+
+// resolve 解析组件引用
+func (d *Document) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	if schema == nil {
+		return &Schema{}
+	}
+	return schema
+}
+
+// validator 记录一次校验中发现的问题
+type validator struct {
+	doc      *Document
+	problems []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if len(v.problems) < maxProblems {
+		v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) check(schema *Schema, value interface{}, path string) {
+	schema = v.doc.resolve(schema)
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			v.fail(path, "must not be null")
+		}
+		return
+	}
+
+	switch schema.Type {
+	case "":
+		return
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			v.fail(path, "expected string, got %s", typeName(value))
+			return
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				v.fail(path, "%q is not an RFC 3339 date-time", s)
+			}
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+			v.fail(path, "%q is not one of %s", s, strings.Join(schema.Enum, ", "))
+		}
+	case "integer", "number":
+		n, ok := number(value)
+		if !ok {
+			v.fail(path, "expected %s, got %s", schema.Type, typeName(value))
+			return
+		}
+		if schema.Type == "integer" && n != math.Trunc(n) {
+			v.fail(path, "expected integer, got %v", value)
+			return
+		}
+		if schema.Minimum != nil && n < *schema.Minimum {
+			v.fail(path, "must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			v.fail(path, "must be at most %v", *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "expected boolean, got %s", typeName(value))
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, "expected array, got %s", typeName(value))
+			return
+		}
+		for i, item := range items {
+			v.check(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			v.fail(path, "expected object, got %s", typeName(value))
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				v.fail(path+"."+name, "is required")
+			}
+		}
+		for name, item := range obj {
+			if prop, ok := schema.Properties[name]; ok {
+				v.check(prop, item, path+"."+name)
+			} else if schema.AdditionalProperties != nil {
+				v.check(schema.AdditionalProperties, item, path+"."+name)
+			}
+		}
+	}
+}
+
+// number 取出数值，兼容 UseNumber 与默认的解码结果
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// typeName 返回 JSON 值的类型名
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case json.Number, float64, int, int64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
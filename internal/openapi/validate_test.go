@@ -0,0 +1,65 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	min := 1.0
+	doc := &Document{Components: Components{Schemas: map[string]*Schema{
+		"Item": {
+			Type:     "object",
+			Required: []string{"name", "count"},
+			Properties: map[string]*Schema{
+				"name":  {Type: "string", Enum: []string{"a", "b"}},
+				"count": {Type: "integer", Minimum: &min},
+				"at":    {Type: "string", Format: "date-time"},
+				"tags":  {Type: "array", Items: &Schema{Type: "string"}, Nullable: true},
+				"attrs": {Type: "object", AdditionalProperties: &Schema{Type: "boolean"}},
+				"any":   {},
+			},
+		},
+	}}}
+	list := &Schema{Type: "array", Items: Ref("Item")}
+
+	decode := func(s string) interface{} {
+		value, err := DecodeJSON([]byte(s))
+		require.NoError(t, err)
+		return value
+	}
+	assert.NoError(t, doc.Validate(list, decode(`[{"name": "a", "count": 2, "at": "2024-01-01T00:00:00Z", "tags": null, "attrs": {"x": true}, "any": [1], "extra": 1}]`), "body"))
+
+	err := doc.Validate(list, decode(`[{"name": "c", "count": 1.5, "at": "yesterday", "tags": [1], "attrs": {"x": "yes"}}, {"count": 0}]`), "body")
+	require.Error(t, err)
+	assert.ElementsMatch(t, []string{
+		`body[0].name: "c" is not one of a, b`,
+		"body[0].count: expected integer, got 1.5",
+		`body[0].at: "yesterday" is not an RFC 3339 date-time`,
+		"body[0].tags[0]: expected string, got number",
+		"body[0].attrs.x: expected boolean, got string",
+		"body[1].name: is required",
+		"body[1].count: must be at least 1",
+	}, err.(*ValidationError).Problems)
+
+	assert.EqualError(t, doc.Validate(list, decode(`{"name": "a"}`), "body"), "body: expected array, got object")
+	assert.EqualError(t, doc.Validate(Ref("Item"), nil, "body"), "body: must not be null")
+
+	_, err = DecodeJSON([]byte(`{} {}`))
+	assert.Error(t, err)
+}
+
+func TestValidateParameter(t *testing.T) {
+	min := 0.0
+	doc := &Document{}
+	limit := &Parameter{Name: "limit", In: "query", Schema: &Schema{Type: "integer", Minimum: &min}}
+	assert.NoError(t, doc.ValidateParameter(limit, "10"))
+	assert.EqualError(t, doc.ValidateParameter(limit, "ten"), `query parameter limit: "ten" is not a valid integer`)
+	assert.EqualError(t, doc.ValidateParameter(limit, "-1"), "query parameter limit: must be at least 0")
+
+	format := &Parameter{Name: "format", In: "query", Schema: &Schema{Type: "string", Enum: []string{"jsonschema"}}}
+	assert.NoError(t, doc.ValidateParameter(format, "jsonschema"))
+	assert.Error(t, doc.ValidateParameter(format, "xml"))
+}
@@ -0,0 +1,187 @@
+// Package patterns 使用 Drain 算法将日志消息聚类为模板，统计各模板的出现次数，
+// 用于找出产生日志最多的语句
+package patterns
+
+import (
+	"sort"
+	"strings"
+
+	"pkg.blksails.net/logs/internal/issues"
+)
+
+// Wildcard 模板中变量位置的占位符
+const Wildcard = "<*>"
+
+const (
+	// DefaultDepth 默认的解析树深度，包含长度层与叶子层
+	DefaultDepth = 4
+	// DefaultSimilarity 默认的相似度阈值，相同位置的词占比不低于该值时并入已有模板
+	DefaultSimilarity = 0.4
+	// DefaultMaxChildren 默认的内部节点最多子节点数，超出后新词归入通配子节点
+	DefaultMaxChildren = 100
+)
+
+// Config 聚类配置
+type Config struct {
+	Depth       int     // 解析树深度，至少为 3，默认 DefaultDepth
+	Similarity  float64 // 相似度阈值，取值 (0, 1]，默认 DefaultSimilarity
+	MaxChildren int     // 内部节点最多子节点数，默认 DefaultMaxChildren
+}
+
+// Cluster 一个消息模板及其统计
+type Cluster struct {
+	Template string `json:"template"`
+	Count    int64  `json:"count"`
+	Sample   string `json:"sample"` // 首条归入该模板的原始消息
+
+	tokens []string
+}
+
+// node 解析树节点，叶子节点保存模板
+type node struct {
+	children map[string]*node
+	clusters []*Cluster
+}
+
+// Miner 在线聚类日志消息。不是并发安全的
+type Miner struct {
+	config   Config
+	byLength map[int]*node
+	clusters []*Cluster
+}
+
+// NewMiner 创建聚类器
+func NewMiner(config Config) *Miner {
+	if config.Depth < 3 {
+		config.Depth = DefaultDepth
+	}
+	if config.Similarity <= 0 || config.Similarity > 1 {
+		config.Similarity = DefaultSimilarity
+	}
+	if config.MaxChildren <= 0 {
+		config.MaxChildren = DefaultMaxChildren
+	}
+	return &Miner{config: config, byLength: make(map[int]*node)}
+}
+
+// Add 将消息归入最相似的模板，没有足够相似的模板时新建。返回消息所属的模板
+func (m *Miner) Add(message string) *Cluster {
+	tokens := Tokenize(message)
+
+	root, ok := m.byLength[len(tokens)]
+	if !ok {
+		root = &node{children: make(map[string]*node)}
+		m.byLength[len(tokens)] = root
+	}
+	leaf := m.descend(root, tokens)
+
+	if cluster := m.match(leaf.clusters, tokens); cluster != nil {
+		cluster.Count++
+		merge(cluster, tokens)
+		return cluster
+	}
+	cluster := &Cluster{Template: strings.Join(tokens, " "), Count: 1, Sample: message, tokens: tokens}
+	leaf.clusters = append(leaf.clusters, cluster)
+	m.clusters = append(m.clusters, cluster)
+	return cluster
+}
+
+// Clusters 返回全部模板，按出现次数倒序，次数相同时按模板排序
+func (m *Miner) Clusters() []*Cluster {
+	clusters := make([]*Cluster, len(m.clusters))
+	copy(clusters, m.clusters)
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if clusters[i].Count != clusters[j].Count {
+			return clusters[i].Count > clusters[j].Count
+		}
+		return clusters[i].Template < clusters[j].Template
+	})
+	return clusters
+}
+
+// Tokenize 将消息中的变量部分替换为通配符后按空白切分
+func Tokenize(message string) []string {
+	tokens := strings.Fields(issues.Template(message))
+	for i, token := range tokens {
+		if isVariable(token) {
+			tokens[i] = Wildcard
+		}
+	}
+	return tokens
+}
+
+// descend 按前 Depth-2 个词沿解析树向下，缺少的节点按需创建。
+// 变量词与超出子节点上限的词走通配子节点
+func (m *Miner) descend(root *node, tokens []string) *node {
+	current := root
+	for i := 0; i < m.config.Depth-2 && i < len(tokens); i++ {
+		key := tokens[i]
+		if _, ok := current.children[key]; !ok && key != Wildcard && len(current.children) >= m.config.MaxChildren-1 {
+			key = Wildcard
+		}
+		next, ok := current.children[key]
+		if !ok {
+			next = &node{children: make(map[string]*node)}
+			current.children[key] = next
+		}
+		current = next
+	}
+	return current
+}
+
+// match 返回叶子中相似度最高且不低于阈值的模板，相似度相同时优先通配符更多的模板
+func (m *Miner) match(clusters []*Cluster, tokens []string) *Cluster {
+	var best *Cluster
+	bestSimilarity, bestWildcards := -1.0, -1
+	for _, cluster := range clusters {
+		similarity, wildcards := similarity(cluster.tokens, tokens)
+		if similarity > bestSimilarity || (similarity == bestSimilarity && wildcards > bestWildcards) {
+			best, bestSimilarity, bestWildcards = cluster, similarity, wildcards
+		}
+	}
+	if best == nil || bestSimilarity < m.config.Similarity {
+		return nil
+	}
+	return best
+}
+
+// similarity 计算模板与消息相同位置的词相同的比例，通配符不计入相同
+func similarity(template, tokens []string) (float64, int) {
+	if len(tokens) == 0 {
+		return 1, 0
+	}
+	var same, wildcards int
+	for i, token := range template {
+		switch {
+		case token == Wildcard:
+			wildcards++
+		case token == tokens[i]:
+			same++
+		}
+	}
+	return float64(same) / float64(len(tokens)), wildcards
+}
+
+// merge 将模板中与消息不同的位置替换为通配符
+func merge(cluster *Cluster, tokens []string) {
+	changed := false
+	for i, token := range cluster.tokens {
+		if token != Wildcard && token != tokens[i] {
+			cluster.tokens[i] = Wildcard
+			changed = true
+		}
+	}
+	if changed {
+		cluster.Template = strings.Join(cluster.tokens, " ")
+	}
+}
+
+// isVariable 判断词是否包含 issues.Template 生成的占位符
+func isVariable(token string) bool {
+	for _, placeholder := range []string{"<num>", "<str>", "<ip>", "<hex>", "<uuid>"} {
+		if strings.Contains(token, placeholder) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package patterns
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"timeout", "after", "<*>", "from", "<*>"}, Tokenize("timeout after 30ms from 10.0.0.1:80"))
+	assert.Empty(t, Tokenize("   "))
+}
+
+func TestMiner(t *testing.T) {
+	m := NewMiner(Config{})
+	for i := 0; i < 5; i++ {
+		m.Add(fmt.Sprintf("user %d logged in", i))
+	}
+	m.Add("connected to db-primary")
+	m.Add("connected to db-replica")
+	m.Add("connected to cache")
+	m.Add("shutting down")
+
+	clusters := m.Clusters()
+	require.Len(t, clusters, 3)
+	assert.Equal(t, "user <*> logged in", clusters[0].Template)
+	assert.EqualValues(t, 5, clusters[0].Count)
+	assert.Equal(t, "user 0 logged in", clusters[0].Sample)
+	assert.Equal(t, "connected to <*>", clusters[1].Template, "differing tokens merge into a wildcard")
+	assert.EqualValues(t, 3, clusters[1].Count)
+	assert.Equal(t, "shutting down", clusters[2].Template)
+}
+
+func TestMinerSimilarity(t *testing.T) {
+	m := NewMiner(Config{Similarity: 0.8})
+	m.Add("GET /users returned ok")
+	m.Add("GET /orders failed badly")
+	assert.Len(t, m.Clusters(), 2, "messages below the similarity threshold start new templates")
+
+	// 不同长度的消息不会合并
+	m.Add("GET /users returned ok again")
+	assert.Len(t, m.Clusters(), 3)
+}
+
+func TestMinerMaxChildren(t *testing.T) {
+	m := NewMiner(Config{MaxChildren: 2})
+	m.Add("alpha event happened")
+	m.Add("beta event happened")
+	m.Add("gamma event happened")
+
+	clusters := m.Clusters()
+	require.Len(t, clusters, 2)
+	assert.Equal(t, "<*> event happened", clusters[0].Template, "tokens past the child limit share the wildcard branch")
+	assert.EqualValues(t, 2, clusters[0].Count)
+}
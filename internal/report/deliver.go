@@ -0,0 +1,264 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// summaryRows 内联摘要中展示的最大行数
+const summaryRows = 10
+
+// slackLimit Slack 消息中附带内容的最大长度
+const slackLimit = 3000
+
+// attachment 渲染后的报表附件
+type attachment struct {
+	filename    string
+	contentType string
+	data        []byte
+}
+
+// columns 返回结果中出现过的全部列，按名称排序
+func columns(rows []map[string]interface{}) []string {
+	set := make(map[string]bool)
+	for _, row := range rows {
+		for key := range row {
+			set[key] = true
+		}
+	}
+	cols := make([]string, 0, len(set))
+	for key := range set {
+		cols = append(cols, key)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// formatValue 将单元格值转换为文本
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// summary 生成内联摘要文本
+func summary(r *models.Report, result *Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Report %s: %d rows from %s/%s at %s\n",
+		r.Name, result.Count, result.Project, result.Table, result.GeneratedAt.Format(time.RFC3339))
+
+	if r.Format != models.ReportFormatSummary || result.Count == 0 {
+		return b.String()
+	}
+
+	cols := columns(result.Entries)
+	for i, row := range result.Entries {
+		if i == summaryRows {
+			fmt.Fprintf(&b, "... %d more rows\n", result.Count-summaryRows)
+			break
+		}
+		parts := make([]string, 0, len(cols))
+		for _, col := range cols {
+			if v, ok := row[col]; ok && v != nil {
+				parts = append(parts, col+"="+formatValue(v))
+			}
+		}
+		b.WriteString(strings.Join(parts, " ") + "\n")
+	}
+	return b.String()
+}
+
+// render 按报表格式生成附件，summary 格式没有附件
+func render(r *models.Report, result *Result) (*attachment, error) {
+	name := fmt.Sprintf("%s-%s", r.Name, result.GeneratedAt.Format("20060102T150405"))
+
+	switch r.Format {
+	case models.ReportFormatCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		cols := columns(result.Entries)
+		if err := w.Write(cols); err != nil {
+			return nil, err
+		}
+		for _, row := range result.Entries {
+			record := make([]string, len(cols))
+			for i, col := range cols {
+				record[i] = formatValue(row[col])
+			}
+			if err := w.Write(record); err != nil {
+				return nil, err
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, fmt.Errorf("生成 CSV 失败: %w", err)
+		}
+		return &attachment{filename: name + ".csv", contentType: "text/csv", data: buf.Bytes()}, nil
+	case models.ReportFormatJSON:
+		data, err := json.MarshalIndent(result.Entries, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("生成 JSON 失败: %w", err)
+		}
+		return &attachment{filename: name + ".json", contentType: "application/json", data: data}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// deliver 将结果投递到报表的全部目标，单个目标失败不影响其他目标
+func (s *Scheduler) deliver(ctx context.Context, r *models.Report, result *Result) error {
+	att, err := render(r, result)
+	if err != nil {
+		return err
+	}
+	text := summary(r, result)
+
+	var errs []error
+	for _, target := range r.Targets {
+		var err error
+		switch target.Type {
+		case models.ReportChannelWebhook:
+			err = s.sendWebhook(ctx, target.URL, r, result, text, att)
+		case models.ReportChannelSlack:
+			err = s.sendSlack(ctx, target.URL, text, att)
+		case models.ReportChannelEmail:
+			err = s.sendEmail(target.To, r, text, att)
+		default:
+			err = fmt.Errorf("unsupported channel: %s", target.Type)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postJSON 发送 JSON 请求，非 2xx 响应视为失败
+func (s *Scheduler) postJSON(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// sendWebhook 以 JSON 投递结果，csv/json 格式的附件内容放在 content 字段
+func (s *Scheduler) sendWebhook(ctx context.Context, url string, r *models.Report, result *Result, text string, att *attachment) error {
+	payload := map[string]interface{}{
+		"report":       r.Name,
+		"project":      result.Project,
+		"table":        result.Table,
+		"format":       r.Format,
+		"count":        result.Count,
+		"generated_at": result.GeneratedAt,
+		"summary":      text,
+	}
+	if att != nil {
+		payload["filename"] = att.filename
+		payload["content_type"] = att.contentType
+		payload["content"] = string(att.data)
+	}
+	return s.postJSON(ctx, url, payload)
+}
+
+// sendSlack 通过 Incoming Webhook 投递，附件内容截断后以代码块附在消息中
+func (s *Scheduler) sendSlack(ctx context.Context, url, text string, att *attachment) error {
+	if att != nil {
+		content := string(att.data)
+		if len(content) > slackLimit {
+			content = content[:slackLimit] + "\n..."
+		}
+		text += "```\n" + content + "\n```"
+	}
+	return s.postJSON(ctx, url, map[string]string{"text": text})
+}
+
+// sendEmail 通过 SMTP 投递，附件以 base64 编码
+func (s *Scheduler) sendEmail(to []string, r *models.Report, text string, att *attachment) error {
+	cfg := s.config.SMTP
+	if cfg.Host == "" {
+		return fmt.Errorf("smtp is not configured")
+	}
+
+	msg := buildMessage(cfg.From, to, "Report: "+r.Name, text, att)
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 25
+	}
+	return smtp.SendMail(cfg.Host+":"+strconv.Itoa(port), auth, cfg.From, to, msg)
+}
+
+// buildMessage 构造 MIME 邮件，有附件时使用 multipart/mixed
+func buildMessage(from string, to []string, subject, text string, att *attachment) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if att == nil {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		buf.WriteString(text)
+		return buf.Bytes()
+	}
+
+	boundary := fmt.Sprintf("report-%d", time.Now().UnixNano())
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	buf.WriteString(text + "\r\n")
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	fmt.Fprintf(&buf, "Content-Type: %s; name=%q\r\n", att.contentType, att.filename)
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	fmt.Fprintf(&buf, "Content-Disposition: attachment; filename=%q\r\n\r\n", att.filename)
+	encoded := base64.StdEncoding.EncodeToString(att.data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes()
+}
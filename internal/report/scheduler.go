@@ -0,0 +1,284 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/logging"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// SMTPConfig 邮件投递配置
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Config 报表调度器配置
+type Config struct {
+	SMTP       SMTPConfig
+	HTTPClient *http.Client  // webhook 与 Slack 投递使用，默认带超时的客户端
+	Timeout    time.Duration // 单次报表执行超时，默认 5 分钟
+	Logger     *zap.Logger   // 为空时使用全局 logger
+
+	// Leading 可选，多实例部署时到达计划时间的报表只在返回 true 的实例上执行，为空时总是执行。
+	// 通过 API 立即执行的报表不受影响
+	Leading func(ctx context.Context) bool
+}
+
+// Result 一次报表执行的结果
+type Result struct {
+	Report      string                   `json:"report"`
+	Project     string                   `json:"project"`
+	Table       string                   `json:"table"`
+	Count       int                      `json:"count"`
+	Entries     []map[string]interface{} `json:"entries"`
+	GeneratedAt time.Time                `json:"generated_at"`
+}
+
+// Scheduler 按 cron 计划执行保存查询并投递结果
+type Scheduler struct {
+	storage storage.Storage
+	reports storage.ReportStore
+	queries storage.SavedQueryStore
+	querier storage.LogQuerier
+	config  Config
+	logger  *zap.Logger
+
+	cron    *cron.Cron
+	entries map[string]cron.EntryID // key: owner/name
+	mu      sync.Mutex
+}
+
+// NewScheduler 创建报表调度器，存储需支持保存查询、报表与日志查询
+func NewScheduler(store storage.Storage, config Config) (*Scheduler, error) {
+	reports, ok := storage.As[storage.ReportStore](store)
+	if !ok {
+		return nil, fmt.Errorf("storage does not support reports")
+	}
+	queries, ok := storage.As[storage.SavedQueryStore](store)
+	if !ok {
+		return nil, fmt.Errorf("storage does not support saved queries")
+	}
+	querier, ok := storage.As[storage.LogQuerier](store)
+	if !ok {
+		return nil, fmt.Errorf("storage does not support log queries")
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Minute
+	}
+
+	return &Scheduler{
+		storage: store,
+		reports: reports,
+		queries: queries,
+		querier: querier,
+		config:  config,
+		logger:  logging.Component(config.Logger, "report"),
+		cron:    cron.New(),
+		entries: make(map[string]cron.EntryID),
+	}, nil
+}
+
+// ParseSchedule 校验标准 5 段 cron 表达式（支持 @daily 等描述符）
+func ParseSchedule(spec string) error {
+	if _, err := cron.ParseStandard(spec); err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	return nil
+}
+
+// Start 加载已保存的报表并启动调度
+func (s *Scheduler) Start(ctx context.Context) error {
+	if err := s.Reload(ctx); err != nil {
+		return err
+	}
+	s.cron.Start()
+	return nil
+}
+
+// Reload 按存储中的报表重建全部调度，用于其他实例修改报表而本实例可能错过了通知时
+func (s *Scheduler) Reload(ctx context.Context) error {
+	reports, err := s.reports.ListReports(ctx, "")
+	if err != nil {
+		return fmt.Errorf("加载报表失败: %w", err)
+	}
+	s.mu.Lock()
+	for key, id := range s.entries {
+		s.cron.Remove(id)
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+	for _, r := range reports {
+		if err := s.Sync(r); err != nil {
+			s.logger.Error("failed to schedule report",
+				zap.String("owner", r.Owner), zap.String("report", r.Name), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// Refresh 从存储重新读取报表并更新调度，报表已被删除时移除调度，用于其他实例修改报表之后
+func (s *Scheduler) Refresh(ctx context.Context, owner, name string) error {
+	r, err := s.reports.GetReport(ctx, owner, name)
+	if errors.Is(err, models.ErrReportNotFound) {
+		s.Remove(owner, name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.Sync(r)
+}
+
+// Stop 停止调度并等待正在执行的报表完成
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+// Sync 根据报表定义更新调度，未启用的报表只会被移除
+func (s *Scheduler) Sync(r *models.Report) error {
+	schedule, err := cron.ParseStandard(r.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", r.Schedule, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.Owner + "/" + r.Name
+	if id, ok := s.entries[key]; ok {
+		s.cron.Remove(id)
+		delete(s.entries, key)
+	}
+	if !r.Enabled {
+		return nil
+	}
+
+	owner, name := r.Owner, r.Name
+	s.entries[key] = s.cron.Schedule(schedule, cron.FuncJob(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+		defer cancel()
+		if s.config.Leading != nil && !s.config.Leading(ctx) {
+			return
+		}
+		if _, err := s.Run(ctx, owner, name); err != nil {
+			s.logger.Error("failed to run report",
+				zap.String("owner", owner), zap.String("report", name), zap.Error(err))
+		}
+	}))
+	return nil
+}
+
+// Remove 移除报表的调度
+func (s *Scheduler) Remove(owner, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := owner + "/" + name
+	if id, ok := s.entries[key]; ok {
+		s.cron.Remove(id)
+		delete(s.entries, key)
+	}
+}
+
+// Next 返回报表的下次执行时间，未调度时返回零值
+func (s *Scheduler) Next(owner, name string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.entries[owner+"/"+name]; ok {
+		entry := s.cron.Entry(id)
+		if entry.Next.IsZero() {
+			// 调度器尚未启动时根据计划推算
+			return entry.Schedule.Next(time.Now())
+		}
+		return entry.Next
+	}
+	return time.Time{}
+}
+
+// Run 立即执行报表并投递结果，执行状态会写回报表定义。写回前重新读取报表，
+// 执行期间通过 API 做的修改不会被覆盖；执行期间报表被删除时不再写回
+func (s *Scheduler) Run(ctx context.Context, owner, name string) (*Result, error) {
+	r, err := s.reports.GetReport(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	result, runErr := s.execute(ctx, r)
+	if runErr == nil {
+		runErr = s.deliver(ctx, r, result)
+	}
+
+	now := time.Now()
+	latest, err := s.reports.GetReport(ctx, owner, name)
+	if errors.Is(err, models.ErrReportNotFound) {
+		return result, runErr
+	}
+	if err != nil {
+		return result, errors.Join(runErr, fmt.Errorf("更新报表状态失败: %w", err))
+	}
+	latest.LastRunAt = &now
+	latest.LastError = ""
+	if runErr != nil {
+		latest.LastError = runErr.Error()
+	}
+	if err := s.reports.SaveReport(ctx, latest); err != nil {
+		return result, errors.Join(runErr, fmt.Errorf("更新报表状态失败: %w", err))
+	}
+	return result, runErr
+}
+
+// execute 执行报表引用的保存查询
+func (s *Scheduler) execute(ctx context.Context, r *models.Report) (*Result, error) {
+	saved, err := s.queries.GetSavedQuery(ctx, r.Owner, r.SavedQuery)
+	if err != nil {
+		return nil, fmt.Errorf("获取保存查询失败: %w", err)
+	}
+
+	query, err := saved.Query.Bind(r.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	// schema 可能在保存后发生变化，执行前重新校验
+	schema, err := s.storage.GetSchema(ctx, saved.Project, saved.Table)
+	if err != nil {
+		return nil, err
+	}
+	if err := query.Validate(schema, storage.BaseColumns(s.storage, schema)); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.querier.SearchLogs(ctx, saved.Project, saved.Table, query)
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		rows = make([]map[string]interface{}, 0)
+	}
+
+	return &Result{
+		Report:      r.Name,
+		Project:     saved.Project,
+		Table:       saved.Table,
+		Count:       len(rows),
+		Entries:     rows,
+		GeneratedAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,238 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func setupStore(t *testing.T) *storage.SQLiteStorage {
+	ctx := context.Background()
+	store := storage.NewSQLiteStorage(storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	t.Cleanup(func() { store.Close() })
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "service", Type: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeInt},
+		},
+	}
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	logs := make([]*models.LogEntry, 0, 3)
+	for i, service := range []string{"api", "web", "api"} {
+		logs = append(logs, &models.LogEntry{
+			Project:   "app",
+			Table:     "requests",
+			Level:     "info",
+			Message:   "request",
+			Timestamp: time.Now(),
+			Fields:    map[string]interface{}{"service": service, "latency": int64(10 * (i + 1))},
+		})
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", logs))
+
+	require.NoError(t, store.SaveQuery(ctx, &models.SavedQuery{
+		Name:    "by_service",
+		Owner:   "user:alice",
+		Project: "app",
+		Table:   "requests",
+		Query: models.Query{
+			Filter: map[string]interface{}{"service": "${service}"},
+			Fields: []string{"service", "latency"},
+			Sort:   []string{"-latency"},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}))
+	return store
+}
+
+func TestSchedulerRun(t *testing.T) {
+	ctx := context.Background()
+	store := setupStore(t)
+
+	var webhook map[string]interface{}
+	var slack map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webhook":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&webhook))
+		case "/slack":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&slack))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	scheduler, err := NewScheduler(store, Config{})
+	require.NoError(t, err)
+
+	report := &models.Report{
+		Name:       "api_latency",
+		Owner:      "user:alice",
+		SavedQuery: "by_service",
+		Params:     map[string]string{"service": "api"},
+		Schedule:   "@daily",
+		Format:     models.ReportFormatCSV,
+		Targets: []*models.ReportTarget{
+			{Type: models.ReportChannelWebhook, URL: server.URL + "/webhook"},
+			{Type: models.ReportChannelSlack, URL: server.URL + "/slack"},
+		},
+		Enabled: true,
+	}
+	require.NoError(t, report.Validate())
+	require.NoError(t, store.SaveReport(ctx, report))
+
+	result, err := scheduler.Run(ctx, "user:alice", "api_latency")
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Count)
+
+	assert.Equal(t, "api_latency", webhook["report"])
+	assert.Equal(t, float64(2), webhook["count"])
+	assert.Equal(t, "latency,service\n30,api\n10,api\n", webhook["content"])
+	assert.Contains(t, slack["text"], "Report api_latency: 2 rows from app/requests")
+	assert.Contains(t, slack["text"], "30,api")
+
+	saved, err := store.GetReport(ctx, "user:alice", "api_latency")
+	require.NoError(t, err)
+	require.NotNil(t, saved.LastRunAt)
+	assert.Empty(t, saved.LastError)
+
+	// 投递失败时记录错误，但仍返回查询结果
+	saved.Targets = []*models.ReportTarget{{Type: models.ReportChannelWebhook, URL: server.URL + "/missing"}}
+	require.NoError(t, store.SaveReport(ctx, saved))
+	result, err = scheduler.Run(ctx, "user:alice", "api_latency")
+	assert.Error(t, err)
+	assert.NotNil(t, result)
+
+	saved, err = store.GetReport(ctx, "user:alice", "api_latency")
+	require.NoError(t, err)
+	assert.Contains(t, saved.LastError, "webhook")
+
+	_, err = scheduler.Run(ctx, "user:bob", "api_latency")
+	assert.ErrorIs(t, err, models.ErrReportNotFound)
+}
+
+func TestSchedulerRunKeepsConcurrentEdits(t *testing.T) {
+	ctx := context.Background()
+	store := setupStore(t)
+	scheduler, err := NewScheduler(store, Config{})
+	require.NoError(t, err)
+
+	var edit func()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 投递期间通过其他途径修改或删除报表
+		edit()
+	}))
+	defer server.Close()
+
+	report := &models.Report{
+		Name:       "api_latency",
+		Owner:      "user:alice",
+		SavedQuery: "by_service",
+		Params:     map[string]string{"service": "api"},
+		Schedule:   "@daily",
+		Format:     models.ReportFormatCSV,
+		Targets:    []*models.ReportTarget{{Type: models.ReportChannelWebhook, URL: server.URL}},
+		Enabled:    true,
+	}
+	require.NoError(t, store.SaveReport(ctx, report))
+
+	edit = func() {
+		current, err := store.GetReport(ctx, "user:alice", "api_latency")
+		require.NoError(t, err)
+		current.Schedule = "@hourly"
+		current.Params = map[string]string{"service": "web"}
+		require.NoError(t, store.SaveReport(ctx, current))
+	}
+	_, err = scheduler.Run(ctx, "user:alice", "api_latency")
+	require.NoError(t, err)
+
+	saved, err := store.GetReport(ctx, "user:alice", "api_latency")
+	require.NoError(t, err)
+	assert.Equal(t, "@hourly", saved.Schedule, "edits made during the run are kept")
+	assert.Equal(t, map[string]string{"service": "web"}, saved.Params)
+	require.NotNil(t, saved.LastRunAt)
+	assert.Empty(t, saved.LastError)
+
+	// 执行期间被删除的报表不会因写回执行状态而恢复
+	edit = func() {
+		require.NoError(t, store.DeleteReport(ctx, "user:alice", "api_latency"))
+	}
+	_, err = scheduler.Run(ctx, "user:alice", "api_latency")
+	require.NoError(t, err)
+	_, err = store.GetReport(ctx, "user:alice", "api_latency")
+	assert.ErrorIs(t, err, models.ErrReportNotFound)
+}
+
+func TestSchedulerSync(t *testing.T) {
+	store := setupStore(t)
+	scheduler, err := NewScheduler(store, Config{})
+	require.NoError(t, err)
+
+	report := &models.Report{Name: "daily", Owner: "user:alice", Schedule: "0 8 * * *", Enabled: true}
+	require.NoError(t, scheduler.Sync(report))
+	assert.False(t, scheduler.Next("user:alice", "daily").IsZero())
+
+	report.Enabled = false
+	require.NoError(t, scheduler.Sync(report))
+	assert.True(t, scheduler.Next("user:alice", "daily").IsZero())
+
+	report.Schedule = "not a schedule"
+	assert.Error(t, scheduler.Sync(report))
+	assert.Error(t, ParseSchedule("61 * * * *"))
+}
+
+func TestSchedulerRefresh(t *testing.T) {
+	ctx := context.Background()
+	store := setupStore(t)
+	scheduler, err := NewScheduler(store, Config{})
+	require.NoError(t, err)
+
+	// 其他实例保存的报表在 Refresh 后被调度
+	report := &models.Report{Name: "daily", Owner: "user:alice", SavedQuery: "by_service", Schedule: "0 8 * * *", Enabled: true}
+	require.NoError(t, store.SaveReport(ctx, report))
+	assert.True(t, scheduler.Next("user:alice", "daily").IsZero())
+	require.NoError(t, scheduler.Refresh(ctx, "user:alice", "daily"))
+	assert.False(t, scheduler.Next("user:alice", "daily").IsZero())
+
+	require.NoError(t, store.DeleteReport(ctx, "user:alice", "daily"))
+	require.NoError(t, scheduler.Refresh(ctx, "user:alice", "daily"), "a deleted report is unscheduled")
+	assert.True(t, scheduler.Next("user:alice", "daily").IsZero())
+
+	// Reload 以存储为准，移除存储中已不存在的调度
+	require.NoError(t, scheduler.Sync(&models.Report{Name: "stale", Owner: "user:bob", Schedule: "@hourly", Enabled: true}))
+	report.Name = "weekly"
+	require.NoError(t, store.SaveReport(ctx, report))
+	require.NoError(t, scheduler.Reload(ctx))
+	assert.True(t, scheduler.Next("user:bob", "stale").IsZero())
+	assert.False(t, scheduler.Next("user:alice", "weekly").IsZero())
+}
+
+func TestBuildMessage(t *testing.T) {
+	msg := string(buildMessage("logs@example.com", []string{"a@example.com", "b@example.com"}, "Report: daily", "2 rows\n",
+		&attachment{filename: "daily.csv", contentType: "text/csv", data: []byte("a,b\n1,2\n")}))
+
+	assert.Contains(t, msg, "To: a@example.com, b@example.com\r\n")
+	assert.Contains(t, msg, "Content-Type: multipart/mixed")
+	assert.Contains(t, msg, `Content-Disposition: attachment; filename="daily.csv"`)
+	assert.True(t, strings.Contains(msg, "YSxiCjEsMgo="))
+}
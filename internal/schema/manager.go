@@ -2,31 +2,180 @@ package schema
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
+	"pkg.blksails.net/logs/internal/logging"
 	"pkg.blksails.net/logs/internal/models"
 	"pkg.blksails.net/logs/internal/storage"
+	"pkg.blksails.net/logs/pkg/clock"
 )
 
+// defaultDebounce 文件事件合并窗口，编辑器保存时通常会产生多个连续事件
+const defaultDebounce = 100 * time.Millisecond
+
+// ErrSchemaConflict 多个 schema 文件声明了同一个 project/table
+var ErrSchemaConflict = errors.New("schema file conflict")
+
+// ConflictPolicy 定义多个文件声明同一 schema 时的处理策略
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyError 拒绝加载冲突的文件并返回错误
+	ConflictPolicyError ConflictPolicy = "error"
+	// ConflictPolicyFirstWins 保留最先加载的文件
+	ConflictPolicyFirstWins ConflictPolicy = "first-wins"
+	// ConflictPolicyNewestWins 保留修改时间最新的文件
+	ConflictPolicyNewestWins ConflictPolicy = "newest-mtime-wins"
+)
+
+// ParseConflictPolicy 解析冲突策略，空字符串返回默认策略
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case "":
+		return ConflictPolicyNewestWins, nil
+	case ConflictPolicyError, ConflictPolicyFirstWins, ConflictPolicyNewestWins:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy: %s", s)
+	}
+}
+
+// DeletePolicy 定义 schema 文件被删除时对存储的处理策略
+type DeletePolicy string
+
+const (
+	// DeletePolicyIgnore 只移除内存中的 schema，存储中的 schema 与日志表保持不变
+	DeletePolicyIgnore DeletePolicy = "ignore"
+	// DeletePolicySoftDelete 删除存储中的 schema 记录，保留日志表与数据，重新添加文件后可继续查询
+	DeletePolicySoftDelete DeletePolicy = "soft-delete"
+	// DeletePolicyDropTable 删除 schema 记录并删除日志表
+	DeletePolicyDropTable DeletePolicy = "drop-table"
+)
+
+// ParseDeletePolicy 解析删除策略，空字符串返回默认策略
+func ParseDeletePolicy(s string) (DeletePolicy, error) {
+	switch p := DeletePolicy(s); p {
+	case "":
+		return DeletePolicySoftDelete, nil
+	case DeletePolicyIgnore, DeletePolicySoftDelete, DeletePolicyDropTable:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown delete policy: %s", s)
+	}
+}
+
+// Conflict 记录一次 schema 文件冲突
+type Conflict struct {
+	Key        string    `json:"key"`
+	Files      []string  `json:"files"`
+	Winner     string    `json:"winner,omitempty"`
+	Policy     string    `json:"policy"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// Status 描述 schema 管理器的当前状态
+type Status struct {
+	Dir            string            `json:"dir"`
+	ConflictPolicy ConflictPolicy    `json:"conflict_policy"`
+	Schemas        int               `json:"schemas"`
+	Files          map[string]string `json:"files"` // key: 文件路径, value: project:table
+	Conflicts      []Conflict        `json:"conflicts"`
+}
+
+// schemaSource 记录 schema 来自哪个文件
+type schemaSource struct {
+	file    string
+	path    string // canonicalPath 规范化后的路径，比较文件时使用
+	modTime time.Time
+	digest  string // 反向同步写入的文件内容摘要，文件事件内容相同时跳过重新加载
+}
+
 // Manager 管理 schema 的加载和更新
 type Manager struct {
-	storage    storage.Storage
-	schemasDir string
-	watcher    *fsnotify.Watcher
-	schemas    map[string]*models.Schema // key: project:table
-	mu         sync.RWMutex
-	ctx        context.Context
-	cancel     context.CancelFunc
+	storage        storage.Storage
+	schemasDir     string
+	watcher        *fsnotify.Watcher
+	registry       *models.SchemaRegistry   // 文件声明的 schema 在 sources 中记录来源，内容保存在注册表
+	sources        map[string]schemaSource  // key: project:table
+	files          map[string]string        // key: 规范化的文件路径, value: project:table
+	conflicts      map[string]conflictEntry // key: conflictID
+	conflictPolicy ConflictPolicy
+	deletePolicy   DeletePolicy
+	writeBack      bool
+	mu             sync.RWMutex
+	writeMu        *sync.Mutex // 串行化写入存储的 schema 变更，与 API 服务器共用，见 WriteLock
+	ctx            context.Context
+	cancel         context.CancelFunc
+	logger         *zap.Logger
+
+	clock    clock.Clock
+	debounce time.Duration
+	pending  map[string]bool // 等待处理的文件
+	timer    clock.Timer
+	pendMu   sync.Mutex
+}
+
+// Option 配置 Manager
+type Option func(*Manager)
+
+// WithConflictPolicy 设置 schema 文件冲突策略
+func WithConflictPolicy(policy ConflictPolicy) Option {
+	return func(m *Manager) {
+		m.conflictPolicy = policy
+	}
+}
+
+// WithDeletePolicy 设置 schema 文件被删除时对存储的处理策略
+func WithDeletePolicy(policy DeletePolicy) Option {
+	return func(m *Manager) {
+		m.deletePolicy = policy
+	}
+}
+
+// WithLogger 设置日志记录器，为空时使用全局 logger
+func WithLogger(logger *zap.Logger) Option {
+	return func(m *Manager) {
+		m.logger = logging.Component(logger, "schema")
+	}
+}
+
+// WithClock 设置时间源，测试中可使用 clock.Mock 推进事件合并窗口
+func WithClock(c clock.Clock) Option {
+	return func(m *Manager) {
+		m.clock = clock.OrReal(c)
+	}
+}
+
+// WithRegistry 设置保存 schema 的注册表，与 API 服务器的 schema 缓存共享同一注册表时，
+// 文件的加载与删除会立即反映到 API 读取的 schema 并通知注册表的订阅者。为空时使用独立的注册表
+func WithRegistry(registry *models.SchemaRegistry) Option {
+	return func(m *Manager) {
+		if registry != nil {
+			m.registry = registry
+		}
+	}
+}
+
+// WithDebounce 设置文件事件合并窗口，窗口内同一文件的多个事件只处理一次
+func WithDebounce(d time.Duration) Option {
+	return func(m *Manager) {
+		m.debounce = d
+	}
 }
 
 // NewManager 创建新的 schema 管理器
-func NewManager(storage storage.Storage, schemasDir string) (*Manager, error) {
+func NewManager(storage storage.Storage, schemasDir string, opts ...Option) (*Manager, error) {
 	// 确保目录存在
 	if err := os.MkdirAll(schemasDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create schemas directory: %w", err)
@@ -39,14 +188,34 @@ func NewManager(storage storage.Storage, schemasDir string) (*Manager, error) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Manager{
-		storage:    storage,
-		schemasDir: schemasDir,
-		watcher:    watcher,
-		schemas:    make(map[string]*models.Schema),
-		ctx:        ctx,
-		cancel:     cancel,
-	}, nil
+	m := &Manager{
+		storage:        storage,
+		schemasDir:     schemasDir,
+		watcher:        watcher,
+		registry:       models.NewSchemaRegistry(),
+		sources:        make(map[string]schemaSource),
+		conflicts:      make(map[string]conflictEntry),
+		writeMu:        &sync.Mutex{},
+		files:          make(map[string]string),
+		conflictPolicy: ConflictPolicyNewestWins,
+		deletePolicy:   DeletePolicySoftDelete,
+		ctx:            ctx,
+		cancel:         cancel,
+		clock:          clock.New(),
+		debounce:       defaultDebounce,
+		pending:        make(map[string]bool),
+		logger:         logging.Component(nil, "schema"),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if !m.supportsDeletePolicy() {
+		watcher.Close()
+		cancel()
+		return nil, fmt.Errorf("delete policy %s is not supported by the storage", m.deletePolicy)
+	}
+
+	return m, nil
 }
 
 // Start 启动 schema 管理器
@@ -56,6 +225,13 @@ func (m *Manager) Start() error {
 		return err
 	}
 
+	// 将只存在于存储中的 schema 写入目录
+	if m.writeBack {
+		if err := m.exportMissing(); err != nil {
+			return err
+		}
+	}
+
 	// 监控目录变化
 	if err := m.watcher.Add(m.schemasDir); err != nil {
 		return fmt.Errorf("failed to watch directory: %w", err)
@@ -69,6 +245,11 @@ func (m *Manager) Start() error {
 // Stop 停止 schema 管理器
 func (m *Manager) Stop() error {
 	m.cancel()
+	m.pendMu.Lock()
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.pendMu.Unlock()
 	return m.watcher.Close()
 }
 
@@ -77,12 +258,15 @@ func (m *Manager) GetSchemasDir() string {
 	return m.schemasDir
 }
 
-// loadSchemas 加载所有 schema 文件
+// loadSchemas 加载所有 schema 文件，冲突记录按本次加载重新生成
 func (m *Manager) loadSchemas() error {
 	files, err := os.ReadDir(m.schemasDir)
 	if err != nil {
 		return fmt.Errorf("failed to read directory: %w", err)
 	}
+	m.mu.Lock()
+	m.conflicts = make(map[string]conflictEntry)
+	m.mu.Unlock()
 
 	for _, file := range files {
 		if file.IsDir() || filepath.Ext(file.Name()) != ".yaml" {
@@ -90,8 +274,12 @@ func (m *Manager) loadSchemas() error {
 		}
 
 		if err := m.loadSchema(filepath.Join(m.schemasDir, file.Name())); err != nil {
+			// error 策略下冲突直接导致启动失败
+			if errors.Is(err, ErrSchemaConflict) {
+				return err
+			}
 			// 记录错误但继续处理其他文件
-			fmt.Printf("Failed to load schema %s: %v\n", file.Name(), err)
+			m.logger.Error("failed to load schema", zap.String("file", file.Name()), zap.Error(err))
 		}
 	}
 
@@ -112,8 +300,41 @@ func (m *Manager) loadSchema(filename string) error {
 		return fmt.Errorf("解析 YAML 失败: %w", err)
 	}
 
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fmt.Errorf("读取文件信息失败: %w", err)
+	}
+
+	m.writeMu.Lock()
+	previous, err := m.storeSchema(schema, filename, info.ModTime(), data)
+	m.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+	// 文件改为声明其他 schema，原 schema 按文件被删除处理
+	if previous != "" {
+		m.dropSchema(previous)
+	}
+	return nil
+}
+
+// storeSchema 按冲突策略将文件声明的 schema 写入存储与注册表，返回该文件此前声明的其他 schema。调用方需持有 m.writeMu
+func (m *Manager) storeSchema(schema *models.Schema, filename string, modTime time.Time, data []byte) (previous string, err error) {
+	key := schema.Project + ":" + schema.Table
+	path := canonicalPath(filename)
+	// 反向同步刚写入的文件，内容与存储一致
+	m.mu.RLock()
+	source, ok := m.sources[key]
+	m.mu.RUnlock()
+	if ok && source.path == path && source.digest != "" && source.digest == digest(data) {
+		return "", nil
+	}
+	if ok, err := m.resolveConflict(key, filename, path, modTime); !ok {
+		return "", err
+	}
+
 	// 更新时间戳
-	now := time.Now()
+	now := m.clock.Now()
 	if schema.CreatedAt.IsZero() {
 		schema.CreatedAt = now
 	}
@@ -121,15 +342,104 @@ func (m *Manager) loadSchema(filename string) error {
 
 	// 保存到存储
 	if err := m.storage.CreateSchema(m.ctx, schema); err != nil {
-		return err
+		return "", err
 	}
 
-	// 更新内存缓存
+	// 更新注册表
 	m.mu.Lock()
-	m.schemas[schema.Project+":"+schema.Table] = schema
+	previous = m.track(key, schemaSource{file: filename, path: path, modTime: modTime})
 	m.mu.Unlock()
 
-	return nil
+	return previous, m.registry.Put(schema)
+}
+
+// track 记录 key 由文件声明并维护文件到 schema 的映射，返回该文件此前声明的其他 schema。调用方需持有 m.mu
+func (m *Manager) track(key string, source schemaSource) (previous string) {
+	if old, ok := m.sources[key]; ok && old.path != source.path {
+		delete(m.files, old.path)
+	}
+	if other, ok := m.files[source.path]; ok && other != key {
+		delete(m.sources, other)
+		previous = other
+	}
+	m.sources[key] = source
+	m.files[source.path] = key
+	return previous
+}
+
+// untrack 移除 key 的来源记录。调用方需持有 m.mu
+func (m *Manager) untrack(key string) {
+	if source, ok := m.sources[key]; ok {
+		delete(m.files, source.path)
+		delete(m.sources, key)
+	}
+}
+
+// resolveConflict 检查 key 是否已由其他文件声明，并按策略决定是否加载当前文件
+func (m *Manager) resolveConflict(key, filename, path string, modTime time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 文件改为声明其他 schema 后，此前记录的冲突已不存在
+	m.clearConflicts(path, key)
+	owner, exists := m.sources[key]
+	if !exists || owner.path == path {
+		return true, nil
+	}
+	// 原文件已被删除，视为无冲突
+	if _, err := os.Stat(owner.file); os.IsNotExist(err) {
+		m.clearConflicts(owner.path, "")
+		return true, nil
+	}
+
+	conflict := Conflict{
+		Key:        key,
+		Files:      []string{owner.file, filename},
+		Policy:     string(m.conflictPolicy),
+		DetectedAt: m.clock.Now(),
+	}
+
+	var load bool
+	var err error
+	switch m.conflictPolicy {
+	case ConflictPolicyError:
+		err = fmt.Errorf("%w: %s declared by %s and %s", ErrSchemaConflict, key, owner.file, filename)
+	case ConflictPolicyFirstWins:
+		conflict.Winner = owner.file
+	default:
+		if modTime.Before(owner.modTime) {
+			conflict.Winner = owner.file
+		} else {
+			conflict.Winner = filename
+			load = true
+		}
+	}
+	// 同一对文件的冲突只保留最近一次的记录
+	m.conflicts[conflictID(key, owner.path, path)] = conflictEntry{conflict: conflict, paths: []string{owner.path, path}}
+	return load, err
+}
+
+// conflictEntry 冲突记录及涉及的两个文件的规范化路径
+type conflictEntry struct {
+	conflict Conflict
+	paths    []string
+}
+
+// conflictID 返回 key 在两个文件之间的冲突的标识，与文件顺序无关
+func conflictID(key, a, b string) string {
+	if b < a {
+		a, b = b, a
+	}
+	return key + "\x00" + a + "\x00" + b
+}
+
+// clearConflicts 移除涉及 path 且 schema 不是 keep 的冲突记录，keep 为空时移除涉及 path 的全部记录。调用方需持有 m.mu
+func (m *Manager) clearConflicts(path, keep string) {
+	for id, entry := range m.conflicts {
+		if entry.conflict.Key != keep && (entry.paths[0] == path || entry.paths[1] == path) {
+			delete(m.conflicts, id)
+		}
+	}
 }
 
 // watchChanges 监控文件变化
@@ -144,29 +454,15 @@ func (m *Manager) watchChanges() {
 			if filepath.Ext(event.Name) != ".yaml" {
 				continue
 			}
-
-			switch {
-			case event.Op&(fsnotify.Create|fsnotify.Write) != 0:
-				if err := m.loadSchema(event.Name); err != nil {
-					fmt.Printf("Failed to load schema %s: %v\n", event.Name, err)
-				}
-			case event.Op&fsnotify.Remove != 0:
-				// 从内存缓存中删除
-				m.mu.Lock()
-				for key, schema := range m.schemas {
-					if filepath.Join(m.schemasDir, schema.Project+"_"+schema.Table+".yaml") == event.Name {
-						delete(m.schemas, key)
-						break
-					}
-				}
-				m.mu.Unlock()
+			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0 {
+				m.schedule(event.Name)
 			}
 
 		case err, ok := <-m.watcher.Errors:
 			if !ok {
 				return
 			}
-			fmt.Printf("Watcher error: %v\n", err)
+			m.logger.Error("schema watcher error", zap.Error(err))
 
 		case <-m.ctx.Done():
 			return
@@ -174,26 +470,210 @@ func (m *Manager) watchChanges() {
 	}
 }
 
-// GetSchema 获取指定的 schema
-func (m *Manager) GetSchema(project, table string) (*models.Schema, error) {
+// schedule 记录文件变化，并在合并窗口结束后统一处理
+func (m *Manager) schedule(filename string) {
+	m.pendMu.Lock()
+	defer m.pendMu.Unlock()
+
+	m.pending[filename] = true
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.timer = m.clock.AfterFunc(m.debounce, m.processPending)
+}
+
+// processPending 按文件当前状态处理积累的变化：文件存在则重新加载，不存在则移除
+func (m *Manager) processPending() {
+	m.pendMu.Lock()
+	files := make([]string, 0, len(m.pending))
+	for file := range m.pending {
+		files = append(files, file)
+	}
+	m.pending = make(map[string]bool)
+	m.timer = nil
+	m.pendMu.Unlock()
+
+	if m.ctx.Err() != nil {
+		return
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			m.removeFile(file)
+			continue
+		}
+		if err := m.loadSchema(file); err != nil {
+			m.logger.Error("failed to load schema", zap.String("file", file), zap.Error(err))
+		}
+	}
+}
+
+// removeFile 移除文件声明的 schema。冲突中同样声明该 schema 的其他文件重新加载并接替，
+// 没有文件再声明时按删除策略处理存储与注册表中的 schema。
+// 文件按规范化路径查找，事件路径的分隔符、相对路径或符号链接与加载时不同也能匹配
+func (m *Manager) removeFile(filename string) {
+	path := canonicalPath(filename)
+	m.mu.Lock()
+	key, ok := m.files[path]
+	var others []string
+	if ok {
+		m.untrack(key)
+		others = m.conflictingFiles(key, path)
+	}
+	m.clearConflicts(path, "")
+	m.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	for _, file := range others {
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		if err := m.loadSchema(file); err != nil {
+			m.logger.Error("failed to load schema", zap.String("file", file), zap.Error(err))
+		}
+	}
 	m.mu.RLock()
-	defer m.mu.RUnlock()
+	_, declared := m.sources[key]
+	m.mu.RUnlock()
+	if !declared {
+		m.dropSchema(key)
+	}
+}
+
+// conflictingFiles 返回冲突记录中与 path 同样声明 key 的其他文件，按文件名排序。调用方需持有 m.mu
+func (m *Manager) conflictingFiles(key, path string) []string {
+	var files []string
+	for _, entry := range m.conflicts {
+		if entry.conflict.Key != key {
+			continue
+		}
+		for i, other := range entry.paths {
+			if other != path && entry.paths[1-i] == path {
+				files = append(files, entry.conflict.Files[i])
+			}
+		}
+	}
+	sort.Strings(files)
+	return slices.Compact(files)
+}
+
+// dropSchema 按删除策略处理不再由任何文件声明的 schema
+func (m *Manager) dropSchema(key string) {
+	project, table, _ := strings.Cut(key, ":")
+	m.writeMu.Lock()
+	err := m.deleteFromStorage(project, table)
+	m.writeMu.Unlock()
+	if err != nil {
+		m.logger.Error("failed to delete schema", zap.String("project", project), zap.String("table", table), zap.Error(err))
+		return
+	}
+	// ignore 策略下存储中的 schema 仍然有效，保留在注册表中
+	if m.deletePolicy != DeletePolicyIgnore {
+		m.forget(project, table)
+	}
+}
+
+// forget 从注册表中移除 schema，已不存在时忽略
+func (m *Manager) forget(project, table string) {
+	if err := m.registry.Delete(project, table); err != nil && !errors.Is(err, models.ErrSchemaNotFound) {
+		m.logger.Error("failed to remove schema from registry", zap.String("project", project), zap.String("table", table), zap.Error(err))
+	}
+}
+
+// supportsDeletePolicy 存储是否支持当前的删除策略，软删除需要存储能只删除 schema 记录
+func (m *Manager) supportsDeletePolicy() bool {
+	if m.deletePolicy != DeletePolicySoftDelete {
+		return true
+	}
+	_, ok := storage.As[storage.SchemaRecordDeleter](m.storage)
+	return ok
+}
+
+// deleteFromStorage 按删除策略删除存储中的 schema，已不存在时视为成功
+func (m *Manager) deleteFromStorage(project, table string) error {
+	var err error
+	switch m.deletePolicy {
+	case DeletePolicyIgnore:
+		return nil
+	case DeletePolicyDropTable:
+		err = m.storage.DeleteSchema(m.ctx, project, table)
+	default:
+		deleter, ok := storage.As[storage.SchemaRecordDeleter](m.storage)
+		if !ok {
+			return fmt.Errorf("delete policy %s is not supported by the storage", m.deletePolicy)
+		}
+		err = deleter.DeleteSchemaRecord(m.ctx, project, table)
+	}
+	if errors.Is(err, models.ErrSchemaNotFound) {
+		return nil
+	}
+	return err
+}
+
+// WriteLock 返回文件变更写入存储时持有的锁。API 服务器在 If-Match 校验与写入期间持有同一把锁，
+// 文件重新加载不会覆盖刚通过校验的更新
+func (m *Manager) WriteLock() *sync.Mutex {
+	return m.writeMu
+}
+
+// Registry 返回保存 schema 的注册表
+func (m *Manager) Registry() *models.SchemaRegistry {
+	return m.registry
+}
 
-	schema, ok := m.schemas[project+":"+table]
+// GetSchema 获取文件声明的 schema
+func (m *Manager) GetSchema(project, table string) (*models.Schema, error) {
+	m.mu.RLock()
+	_, ok := m.sources[project+":"+table]
+	m.mu.RUnlock()
 	if !ok {
-		return nil, fmt.Errorf("schema not found: %s:%s", project, table)
+		return nil, fmt.Errorf("%w: %s:%s", models.ErrSchemaNotFound, project, table)
 	}
-	return schema, nil
+	return m.registry.Get(project, table)
 }
 
-// ListSchemas 列出所有 schema
+// ListSchemas 按 project、table 排序列出文件声明的 schema
 func (m *Manager) ListSchemas() []*models.Schema {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	schemas := make([]*models.Schema, 0, len(m.schemas))
-	for _, schema := range m.schemas {
-		schemas = append(schemas, schema)
+	schemas := make([]*models.Schema, 0, len(m.sources))
+	for _, schema := range m.registry.List() {
+		if _, ok := m.sources[schema.Project+":"+schema.Table]; ok {
+			schemas = append(schemas, schema)
+		}
 	}
 	return schemas
 }
+
+// Status 返回管理器状态，包括已加载的文件和检测到的冲突
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	files := make(map[string]string, len(m.sources))
+	for key, source := range m.sources {
+		files[source.file] = key
+	}
+	conflicts := make([]Conflict, 0, len(m.conflicts))
+	for _, entry := range m.conflicts {
+		conflicts = append(conflicts, entry.conflict)
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if !conflicts[i].DetectedAt.Equal(conflicts[j].DetectedAt) {
+			return conflicts[i].DetectedAt.Before(conflicts[j].DetectedAt)
+		}
+		return conflicts[i].Key < conflicts[j].Key
+	})
+
+	return Status{
+		Dir:            m.schemasDir,
+		ConflictPolicy: m.conflictPolicy,
+		Schemas:        len(m.sources),
+		Files:          files,
+		Conflicts:      conflicts,
+	}
+}
@@ -10,10 +10,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+	"pkg.blksails.net/logs/pkg/clock"
 )
 
 type mockStorage struct {
 	schemas map[string]*models.Schema
+	dropped []string // DeleteSchema 删除了日志表的 project:table
 }
 
 func newMockStorage() *mockStorage {
@@ -27,13 +30,34 @@ func (s *mockStorage) BatchInsertLogs(ctx context.Context, project, table string
 	return nil
 }
 
-func (s *mockStorage) DeleteSchema(ctx context.Context, project, table string) error { return nil }
+func (s *mockStorage) DeleteSchema(ctx context.Context, project, table string) error {
+	if err := s.DeleteSchemaRecord(ctx, project, table); err != nil {
+		return err
+	}
+	s.dropped = append(s.dropped, project+":"+table)
+	return nil
+}
+
+func (s *mockStorage) DeleteSchemaRecord(ctx context.Context, project, table string) error {
+	key := project + ":" + table
+	if _, ok := s.schemas[key]; !ok {
+		return models.ErrSchemaNotFound
+	}
+	delete(s.schemas, key)
+	return nil
+}
 func (s *mockStorage) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
 	return nil
 }
-func (s *mockStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error) { return nil, nil }
-func (s *mockStorage) Close() error                                              { return nil }
-func (s *mockStorage) Ping(ctx context.Context) error                            { return nil }
+func (s *mockStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error) {
+	schemas := make([]*models.Schema, 0, len(s.schemas))
+	for _, schema := range s.schemas {
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+func (s *mockStorage) Close() error                   { return nil }
+func (s *mockStorage) Ping(ctx context.Context) error { return nil }
 
 func (s *mockStorage) UpdateSchema(ctx context.Context, schema *models.Schema) error {
 	key := schema.Project + ":" + schema.Table
@@ -64,9 +88,17 @@ func TestManager(t *testing.T) {
 	// 创建存储实例
 	storage := newMockStorage()
 
-	// 创建管理器
-	manager, err := NewManager(storage, tempDir)
+	// 创建管理器，使用模拟时钟推进事件合并窗口
+	mock := clock.NewMock(time.Now())
+	manager, err := NewManager(storage, tempDir, WithClock(mock))
 	require.NoError(t, err)
+	defer manager.Stop()
+
+	// waitReload 等待文件事件到达后推进时钟，触发重新加载
+	waitReload := func() {
+		mock.BlockUntil(1)
+		mock.Add(defaultDebounce)
+	}
 
 	// 创建测试 schema
 	schema := &models.Schema{
@@ -104,9 +136,6 @@ func TestManager(t *testing.T) {
 	err = manager.Start()
 	require.NoError(t, err)
 
-	// 等待 schema 加载
-	time.Sleep(time.Second)
-
 	// 验证 schema 是否被加载
 	loadedSchema, err := storage.GetSchema(ctx, "test", "logs")
 	require.NoError(t, err)
@@ -121,7 +150,7 @@ func TestManager(t *testing.T) {
 	require.NoError(t, err)
 
 	// 等待 schema 重新加载
-	time.Sleep(time.Second)
+	waitReload()
 
 	// 验证 schema 是否被更新
 	updatedSchema, err := storage.GetSchema(ctx, "test", "logs")
@@ -133,7 +162,7 @@ func TestManager(t *testing.T) {
 	require.NoError(t, err)
 
 	// 等待 schema 被删除
-	time.Sleep(time.Second)
+	waitReload()
 
 	// 验证 schema 是否被删除
 	_, err = storage.GetSchema(ctx, "test", "logs")
@@ -164,9 +193,7 @@ func TestManagerInvalidSchema(t *testing.T) {
 
 	err = manager.Start()
 	require.NoError(t, err)
-
-	// 等待 schema 加载
-	time.Sleep(time.Second)
+	defer manager.Stop()
 
 	// 验证无效的 schema 是否被忽略
 	_, err = storage.GetSchema(ctx, "invalid", "logs")
@@ -220,12 +247,242 @@ func TestManagerDuplicateSchema(t *testing.T) {
 
 	err = manager.Start()
 	require.NoError(t, err)
-
-	// 等待 schema 加载
-	time.Sleep(time.Second)
+	defer manager.Stop()
 
 	// 验证只有一个 schema 被加载
 	loadedSchema, err := storage.GetSchema(ctx, "test", "logs")
 	require.NoError(t, err)
 	assert.Equal(t, "Duplicate test logs", loadedSchema.Description)
 }
+
+func TestManagerConflictPolicy(t *testing.T) {
+	writeSchemas := func(t *testing.T, dir string) {
+		schema := &models.Schema{
+			Project:     "test",
+			Table:       "logs",
+			Description: "First",
+			Fields: []*models.Field{
+				{Name: "level", Type: models.FieldTypeString},
+			},
+		}
+		require.NoError(t, schema.SaveToFile(filepath.Join(dir, "test_logs_1.yaml")))
+		schema.Description = "Second"
+		require.NoError(t, schema.SaveToFile(filepath.Join(dir, "test_logs_2.yaml")))
+
+		// 确保第二个文件的修改时间更新
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "test_logs_2.yaml"), later, later))
+	}
+
+	t.Run("first-wins", func(t *testing.T) {
+		tempDir := t.TempDir()
+		writeSchemas(t, tempDir)
+
+		storage := newMockStorage()
+		manager, err := NewManager(storage, tempDir, WithConflictPolicy(ConflictPolicyFirstWins))
+		require.NoError(t, err)
+		require.NoError(t, manager.Start())
+		defer manager.Stop()
+
+		loaded, err := storage.GetSchema(context.Background(), "test", "logs")
+		require.NoError(t, err)
+		assert.Equal(t, "First", loaded.Description)
+
+		status := manager.Status()
+		require.Len(t, status.Conflicts, 1)
+		assert.Equal(t, "test:logs", status.Conflicts[0].Key)
+		assert.Equal(t, filepath.Join(tempDir, "test_logs_1.yaml"), status.Conflicts[0].Winner)
+	})
+
+	t.Run("newest-mtime-wins", func(t *testing.T) {
+		tempDir := t.TempDir()
+		writeSchemas(t, tempDir)
+
+		storage := newMockStorage()
+		manager, err := NewManager(storage, tempDir)
+		require.NoError(t, err)
+		require.NoError(t, manager.Start())
+		defer manager.Stop()
+
+		loaded, err := storage.GetSchema(context.Background(), "test", "logs")
+		require.NoError(t, err)
+		assert.Equal(t, "Second", loaded.Description)
+		assert.Len(t, manager.Status().Conflicts, 1)
+
+		// 重复加载同一对文件不会累积冲突记录
+		first, second := filepath.Join(tempDir, "test_logs_1.yaml"), filepath.Join(tempDir, "test_logs_2.yaml")
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, manager.loadSchema(first))
+			assert.NoError(t, manager.loadSchema(second))
+		}
+		require.NoError(t, manager.loadSchemas())
+		assert.Len(t, manager.Status().Conflicts, 1)
+
+		// 冲突解决后不再报告
+		require.NoError(t, os.Remove(first))
+		manager.removeFile(first)
+		assert.Empty(t, manager.Status().Conflicts)
+	})
+
+	t.Run("winner removed", func(t *testing.T) {
+		tempDir := t.TempDir()
+		writeSchemas(t, tempDir)
+
+		storage := newMockStorage()
+		manager, err := NewManager(storage, tempDir, WithDeletePolicy(DeletePolicyDropTable))
+		require.NoError(t, err)
+		require.NoError(t, manager.Start())
+		defer manager.Stop()
+		require.Len(t, manager.Status().Conflicts, 1)
+
+		// 另一个文件仍声明该 schema，接替被删除的文件而不是按删除策略删除
+		first, second := filepath.Join(tempDir, "test_logs_1.yaml"), filepath.Join(tempDir, "test_logs_2.yaml")
+		require.NoError(t, os.Remove(second))
+		manager.removeFile(second)
+
+		loaded, err := storage.GetSchema(context.Background(), "test", "logs")
+		require.NoError(t, err)
+		assert.Equal(t, "First", loaded.Description)
+		assert.Empty(t, storage.dropped)
+		registered, err := manager.GetSchema("test", "logs")
+		require.NoError(t, err)
+		assert.Equal(t, "First", registered.Description)
+		status := manager.Status()
+		assert.Equal(t, map[string]string{first: "test:logs"}, status.Files)
+		assert.Empty(t, status.Conflicts)
+
+		// 最后一个声明的文件删除后按删除策略处理
+		require.NoError(t, os.Remove(first))
+		manager.removeFile(first)
+		_, err = storage.GetSchema(context.Background(), "test", "logs")
+		assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+		assert.Equal(t, []string{"test:logs"}, storage.dropped)
+	})
+
+	t.Run("file declares another schema", func(t *testing.T) {
+		tempDir := t.TempDir()
+		writeSchemas(t, tempDir)
+
+		manager, err := NewManager(newMockStorage(), tempDir, WithConflictPolicy(ConflictPolicyFirstWins))
+		require.NoError(t, err)
+		require.NoError(t, manager.Start())
+		defer manager.Stop()
+		require.Len(t, manager.Status().Conflicts, 1)
+
+		second := filepath.Join(tempDir, "test_logs_2.yaml")
+		require.NoError(t, (&models.Schema{
+			Project: "test", Table: "other", Fields: []*models.Field{{Name: "level", Type: models.FieldTypeString}},
+		}).SaveToFile(second))
+		require.NoError(t, manager.loadSchema(second))
+		assert.Empty(t, manager.Status().Conflicts)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		tempDir := t.TempDir()
+		writeSchemas(t, tempDir)
+
+		manager, err := NewManager(newMockStorage(), tempDir, WithConflictPolicy(ConflictPolicyError))
+		require.NoError(t, err)
+		defer manager.Stop()
+
+		err = manager.Start()
+		assert.ErrorIs(t, err, ErrSchemaConflict)
+		assert.Len(t, manager.Status().Conflicts, 1)
+	})
+}
+
+func TestManagerDeletePolicy(t *testing.T) {
+	tests := []struct {
+		policy  DeletePolicy
+		kept    bool
+		dropped []string
+	}{
+		{DeletePolicyIgnore, true, nil},
+		{DeletePolicySoftDelete, false, nil},
+		{DeletePolicyDropTable, false, []string{"test:logs"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			dir := t.TempDir()
+			storage := newMockStorage()
+			mock := clock.NewMock(time.Now())
+			manager, err := NewManager(storage, dir, WithClock(mock), WithDeletePolicy(tt.policy))
+			require.NoError(t, err)
+			defer manager.Stop()
+
+			schemaFile := filepath.Join(dir, "test_logs.yaml")
+			require.NoError(t, (&models.Schema{Project: "test", Table: "logs"}).SaveToFile(schemaFile))
+			require.NoError(t, manager.Start())
+
+			require.NoError(t, os.Remove(schemaFile))
+			mock.BlockUntil(1)
+			mock.Add(defaultDebounce)
+
+			_, err = storage.GetSchema(context.Background(), "test", "logs")
+			assert.Equal(t, tt.kept, err == nil)
+			assert.Equal(t, tt.dropped, storage.dropped)
+			_, err = manager.GetSchema("test", "logs")
+			assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+		})
+	}
+}
+
+func TestManagerSharedRegistry(t *testing.T) {
+	dir := t.TempDir()
+	backend := newMockStorage()
+	registry := models.NewSchemaRegistry()
+	events, cancel := registry.Subscribe(10)
+	defer cancel()
+	mock := clock.NewMock(time.Now())
+	manager, err := NewManager(backend, dir, WithClock(mock), WithRegistry(registry))
+	require.NoError(t, err)
+	defer manager.Stop()
+	assert.Same(t, registry, manager.Registry())
+
+	schemaFile := filepath.Join(dir, "test_logs.yaml")
+	require.NoError(t, (&models.Schema{Project: "test", Table: "logs"}).SaveToFile(schemaFile))
+	require.NoError(t, manager.Start())
+	event := <-events
+	assert.Equal(t, models.SchemaCreated, event.Type)
+	assert.Equal(t, "logs", event.Table)
+
+	// 经由缓存存储创建的 schema 进入注册表，但不属于文件声明的 schema
+	cached := storage.WithSchemaCache(backend, registry)
+	require.NoError(t, cached.CreateSchema(context.Background(), &models.Schema{Project: "test", Table: "api"}))
+	assert.Equal(t, models.SchemaCreated, (<-events).Type)
+	assert.Len(t, registry.List(), 2)
+	require.Len(t, manager.ListSchemas(), 1)
+	assert.Equal(t, "logs", manager.ListSchemas()[0].Table)
+	assert.Equal(t, 1, manager.Status().Schemas)
+
+	require.NoError(t, os.Remove(schemaFile))
+	mock.BlockUntil(1)
+	mock.Add(defaultDebounce)
+	event = <-events
+	assert.Equal(t, models.SchemaDeleted, event.Type)
+	assert.Equal(t, "logs", event.Table)
+	_, err = cached.GetSchema(context.Background(), "test", "logs")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+}
+
+func TestManagerSoftDeleteRequiresSupport(t *testing.T) {
+	// 只暴露 Storage 接口的方法，不支持只删除 schema 记录
+	store := struct{ storage.Storage }{newMockStorage()}
+
+	_, err := NewManager(store, t.TempDir())
+	assert.Error(t, err)
+	manager, err := NewManager(store, t.TempDir(), WithDeletePolicy(DeletePolicyDropTable))
+	require.NoError(t, err)
+	manager.Stop()
+}
+
+func TestParseDeletePolicy(t *testing.T) {
+	policy, err := ParseDeletePolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, DeletePolicySoftDelete, policy)
+	policy, err = ParseDeletePolicy("drop-table")
+	require.NoError(t, err)
+	assert.Equal(t, DeletePolicyDropTable, policy)
+	_, err = ParseDeletePolicy("purge")
+	assert.Error(t, err)
+}
@@ -0,0 +1,23 @@
+package schema
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// canonicalPath 返回用于比较的文件路径：绝对路径、统一分隔符并解析所在目录的符号链接，
+// Windows 的文件系统不区分大小写，统一为小写。文件本身可能已被删除，只解析目录
+func canonicalPath(filename string) string {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		path = filepath.Clean(filename)
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+	if runtime.GOOS == "windows" {
+		path = strings.ToLower(path)
+	}
+	return path
+}
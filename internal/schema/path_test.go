@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app_events.yaml")
+	sep := string(filepath.Separator)
+
+	assert.Equal(t, canonicalPath(file), canonicalPath(dir+sep+"."+sep+"sub"+sep+".."+sep+"app_events.yaml"))
+	assert.NotEqual(t, canonicalPath(file), canonicalPath(filepath.Join(dir, "app_other.yaml")))
+	if runtime.GOOS == "windows" {
+		assert.Equal(t, canonicalPath(file), canonicalPath(strings.ToUpper(file)))
+		assert.Equal(t, canonicalPath(file), canonicalPath(strings.ReplaceAll(file, `\`, "/")))
+	}
+
+	// 相对路径按当前目录解析
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, canonicalPath(filepath.Join(wd, "schemas", "a.yaml")), canonicalPath(filepath.Join("schemas", "a.yaml")))
+
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	assert.Equal(t, canonicalPath(file), canonicalPath(filepath.Join(link, "app_events.yaml")))
+}
+
+func TestManagerRemoveFileBySymlink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(t.TempDir(), "schemas")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	storage := newMockStorage()
+	manager, err := NewManager(storage, link)
+	require.NoError(t, err)
+	defer manager.Stop()
+
+	require.NoError(t, (&models.Schema{Project: "test", Table: "logs"}).SaveToFile(filepath.Join(link, "test_logs.yaml")))
+	require.NoError(t, manager.loadSchemas())
+	_, err = manager.GetSchema("test", "logs")
+	require.NoError(t, err)
+
+	// 事件路径经过符号链接解析后仍指向加载时的文件
+	require.NoError(t, os.Remove(filepath.Join(dir, "test_logs.yaml")))
+	manager.removeFile(filepath.Join(dir, "test_logs.yaml"))
+	_, err = manager.GetSchema("test", "logs")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+	_, err = storage.GetSchema(context.Background(), "test", "logs")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+	assert.Empty(t, manager.Status().Files)
+}
+
+func TestManagerFileDeclaresOtherSchema(t *testing.T) {
+	dir := t.TempDir()
+	storage := newMockStorage()
+	mock := clock.NewMock(time.Now())
+	manager, err := NewManager(storage, dir, WithClock(mock))
+	require.NoError(t, err)
+	defer manager.Stop()
+
+	schemaFile := filepath.Join(dir, "events.yaml")
+	require.NoError(t, (&models.Schema{Project: "test", Table: "logs"}).SaveToFile(schemaFile))
+	require.NoError(t, manager.Start())
+
+	// 同一文件改为声明其他表，原 schema 按文件被删除处理
+	require.NoError(t, (&models.Schema{Project: "test", Table: "events"}).SaveToFile(schemaFile))
+	mock.BlockUntil(1)
+	mock.Add(defaultDebounce)
+
+	_, err = manager.GetSchema("test", "logs")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+	_, err = storage.GetSchema(context.Background(), "test", "logs")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+	_, err = manager.GetSchema("test", "events")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{schemaFile: "test:events"}, manager.Status().Files)
+
+	require.NoError(t, os.Remove(schemaFile))
+	mock.BlockUntil(1)
+	mock.Add(defaultDebounce)
+	_, err = manager.GetSchema("test", "events")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+}
@@ -0,0 +1,179 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// wellKnownTypes protobuf 常用包装类型对应的字段类型
+var wellKnownTypes = map[protoreflect.FullName]models.FieldType{
+	"google.protobuf.Timestamp":   models.FieldTypeDateTime,
+	"google.protobuf.Duration":    models.FieldTypeDuration,
+	"google.protobuf.Struct":      models.FieldTypeJSON,
+	"google.protobuf.Value":       models.FieldTypeJSON,
+	"google.protobuf.ListValue":   models.FieldTypeJSON,
+	"google.protobuf.Any":         models.FieldTypeJSON,
+	"google.protobuf.Empty":       models.FieldTypeJSON,
+	"google.protobuf.FieldMask":   models.FieldTypeString,
+	"google.protobuf.StringValue": models.FieldTypeString,
+	"google.protobuf.BytesValue":  models.FieldTypeString,
+	"google.protobuf.BoolValue":   models.FieldTypeBool,
+	"google.protobuf.DoubleValue": models.FieldTypeFloat,
+	"google.protobuf.FloatValue":  models.FieldTypeFloat,
+	"google.protobuf.Int32Value":  models.FieldTypeInt,
+	"google.protobuf.Int64Value":  models.FieldTypeInt,
+	"google.protobuf.UInt32Value": models.FieldTypeInt,
+	"google.protobuf.UInt64Value": models.FieldTypeInt,
+}
+
+// FromDescriptorSet 读取 protoc --descriptor_set_out 生成的 FileDescriptorSet，
+// 将 messages 中列出的消息（全名，如 acme.events.Login）转换为 project 下的 schema，
+// 表名为消息名的 snake_case 形式。未使用 --include_imports 时，缺失的依赖消息除常用包装类型外都转换为 json 字段
+func FromDescriptorSet(data []byte, project string, messages []string) ([]*models.Schema, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse descriptor set: %w", err)
+	}
+	files, err := protodesc.FileOptions{AllowUnresolvable: true}.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("parse descriptor set: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("at least one message is required")
+	}
+
+	schemas := make([]*models.Schema, 0, len(messages))
+	for _, name := range messages {
+		desc, err := files.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return nil, fmt.Errorf("message %s not found in descriptor set", name)
+		}
+		md, ok := desc.(protoreflect.MessageDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a message", name)
+		}
+		schema, err := SchemaFromMessage(project, snakeCase(string(md.Name())), md)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+// SchemaFromMessage 将 protobuf 消息转换为 schema。标量与枚举转换为对应的基本类型，
+// 嵌套消息转换为 object 字段，map 与递归引用的消息转换为 json 字段；
+// 名为 timestamp 的字段写入内置时间列，不生成字段
+func SchemaFromMessage(project, table string, md protoreflect.MessageDescriptor) (*models.Schema, error) {
+	schema := &models.Schema{
+		Project:     project,
+		Table:       table,
+		Description: comments(md),
+	}
+
+	visiting := map[protoreflect.FullName]bool{md.FullName(): true}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := string(fd.Name())
+		if name == "timestamp" {
+			continue
+		}
+		for _, column := range models.ReservedColumns {
+			if name == column {
+				return nil, fmt.Errorf("field %s of message %s conflicts with a built-in column", name, md.FullName())
+			}
+		}
+		schema.Fields = append(schema.Fields, protoField(fd, visiting))
+	}
+	return schema, nil
+}
+
+// protoField 转换单个字段，visiting 记录当前路径上的消息，用于识别递归引用
+func protoField(fd protoreflect.FieldDescriptor, visiting map[protoreflect.FullName]bool) *models.Field {
+	field := &models.Field{
+		Name:        string(fd.Name()),
+		Required:    fd.Cardinality() == protoreflect.Required,
+		Description: comments(fd),
+	}
+	if fd.IsMap() {
+		field.Type = models.FieldTypeJSON
+		return field
+	}
+
+	typ, sub := protoType(fd, visiting)
+	if !fd.IsList() {
+		field.Type, field.Fields = typ, sub
+		return field
+	}
+	switch typ {
+	case models.FieldTypeString, models.FieldTypeInt, models.FieldTypeFloat, models.FieldTypeBool,
+		models.FieldTypeDateTime, models.FieldTypeObject:
+		field.Type, field.ItemType, field.Fields = models.FieldTypeArray, typ, sub
+	default:
+		field.Type = models.FieldTypeJSON
+	}
+	return field
+}
+
+// protoType 返回字段值的类型，嵌套消息同时返回子字段
+func protoType(fd protoreflect.FieldDescriptor, visiting map[protoreflect.FullName]bool) (models.FieldType, []*models.Field) {
+	switch fd.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.EnumKind:
+		return models.FieldTypeString, nil
+	case protoreflect.BoolKind:
+		return models.FieldTypeBool, nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return models.FieldTypeFloat, nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		md := fd.Message()
+		if typ, ok := wellKnownTypes[md.FullName()]; ok {
+			return typ, nil
+		}
+		// 未解析的依赖没有字段定义，递归引用无法展开为固定的列
+		if md.IsPlaceholder() || md.Fields().Len() == 0 || visiting[md.FullName()] {
+			return models.FieldTypeJSON, nil
+		}
+		visiting[md.FullName()] = true
+		defer delete(visiting, md.FullName())
+
+		fields := md.Fields()
+		sub := make([]*models.Field, 0, fields.Len())
+		for i := 0; i < fields.Len(); i++ {
+			sub = append(sub, protoField(fields.Get(i), visiting))
+		}
+		return models.FieldTypeObject, sub
+	default:
+		// 各种整数类型
+		return models.FieldTypeInt, nil
+	}
+}
+
+// comments 返回描述符的前导注释，描述符集合需使用 --include_source_info 生成
+func comments(desc protoreflect.Descriptor) string {
+	loc := desc.ParentFile().SourceLocations().ByDescriptor(desc)
+	return strings.TrimSpace(loc.LeadingComments)
+}
+
+// snakeCase 将消息名转换为 snake_case 表名，如 LoginEvent 转换为 login_event
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// testDescriptorSet 构造 events.proto 的 FileDescriptorSet，includeImports 控制是否包含 timestamp.proto
+func testDescriptorSet(t *testing.T, includeImports bool) []byte {
+	t.Helper()
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(num),
+			Type:   typ.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	repeated := func(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return f
+	}
+	const (
+		tString  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		tInt64   = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		tUint32  = descriptorpb.FieldDescriptorProto_TYPE_UINT32
+		tBool    = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+		tDouble  = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+		tMessage = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+		tEnum    = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+	)
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("events.proto"),
+		Package:    proto.String("acme.events"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("LoginEvent"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("level", 1, tString, ""),
+					field("message", 2, tString, ""),
+					field("user_id", 3, tInt64, ""),
+					field("ok", 4, tBool, ""),
+					field("latency", 5, tDouble, ""),
+					field("at", 6, tMessage, ".google.protobuf.Timestamp"),
+					repeated(field("labels", 7, tString, "")),
+					field("client", 8, tMessage, ".acme.events.Client"),
+					repeated(field("attrs", 9, tMessage, ".acme.events.LoginEvent.AttrsEntry")),
+					field("parent", 10, tMessage, ".acme.events.LoginEvent"),
+					field("kind", 11, tEnum, ".acme.events.Kind"),
+					field("timestamp", 12, tMessage, ".google.protobuf.Timestamp"),
+					repeated(field("hops", 13, tMessage, ".acme.events.Client")),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("AttrsEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, tString, ""),
+						field("value", 2, tString, ""),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+			},
+			{
+				Name: proto.String("Client"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("ip", 1, tString, ""),
+					field("port", 2, tUint32, ""),
+				},
+			},
+			{
+				Name:  proto.String("HTTPRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{field("id", 1, tString, "")},
+			},
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name:  proto.String("Kind"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)}},
+		}},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{Location: []*descriptorpb.SourceCodeInfo_Location{
+			{Path: []int32{4, 0}, Span: []int32{0, 0, 0}, LeadingComments: proto.String(" A user logged in.\n")},
+			{Path: []int32{4, 0, 2, 2}, Span: []int32{0, 0, 0}, LeadingComments: proto.String(" Internal user ID.\n")},
+		}},
+	}
+
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}}
+	if includeImports {
+		timestamp := protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto)
+		set.File = append([]*descriptorpb.FileDescriptorProto{timestamp}, set.File...)
+	}
+	data, err := proto.Marshal(set)
+	require.NoError(t, err)
+	return data
+}
+
+func TestFromDescriptorSet(t *testing.T) {
+	for _, includeImports := range []bool{true, false} {
+		schemas, err := FromDescriptorSet(testDescriptorSet(t, includeImports), "app", []string{"acme.events.LoginEvent", "acme.events.Client"})
+		require.NoError(t, err)
+		require.Len(t, schemas, 2)
+
+		login := schemas[0]
+		require.NoError(t, login.Validate())
+		assert.Equal(t, "login_event", login.Table)
+		assert.Equal(t, "A user logged in.", login.Description)
+		assert.Equal(t, "Internal user ID.", login.GetField("user_id").Description)
+		assert.Nil(t, login.GetField("timestamp"))
+
+		types := make(map[string]models.FieldType)
+		for _, field := range login.Fields {
+			types[field.Name] = field.Type
+		}
+		assert.Equal(t, map[string]models.FieldType{
+			"level":   models.FieldTypeString,
+			"message": models.FieldTypeString,
+			"user_id": models.FieldTypeInt,
+			"ok":      models.FieldTypeBool,
+			"latency": models.FieldTypeFloat,
+			"at":      models.FieldTypeDateTime,
+			"labels":  models.FieldTypeArray,
+			"client":  models.FieldTypeObject,
+			"attrs":   models.FieldTypeJSON,
+			"parent":  models.FieldTypeJSON,
+			"kind":    models.FieldTypeString,
+			"hops":    models.FieldTypeArray,
+		}, types)
+		assert.Equal(t, models.FieldTypeString, login.GetField("labels").ItemType)
+		hops := login.GetField("hops")
+		assert.Equal(t, models.FieldTypeObject, hops.ItemType)
+		assert.Equal(t, []*models.Field{
+			{Name: "ip", Type: models.FieldTypeString},
+			{Name: "port", Type: models.FieldTypeInt},
+		}, hops.Fields)
+		assert.Equal(t, hops.Fields, login.GetField("client").Fields)
+
+		assert.Equal(t, "client", schemas[1].Table)
+		require.NoError(t, schemas[1].Validate())
+	}
+}
+
+func TestFromDescriptorSetErrors(t *testing.T) {
+	data := testDescriptorSet(t, true)
+
+	_, err := FromDescriptorSet(data, "app", []string{"acme.events.Missing"})
+	assert.ErrorContains(t, err, "not found")
+	_, err = FromDescriptorSet(data, "app", []string{"acme.events.Kind"})
+	assert.ErrorContains(t, err, "is not a message")
+	_, err = FromDescriptorSet(data, "app", []string{"acme.events.HTTPRequest"})
+	assert.ErrorContains(t, err, "built-in column")
+	_, err = FromDescriptorSet(data, "app", nil)
+	assert.Error(t, err)
+	_, err = FromDescriptorSet([]byte("not a descriptor set"), "app", []string{"acme.events.LoginEvent"})
+	assert.Error(t, err)
+}
+
+func TestSnakeCase(t *testing.T) {
+	assert.Equal(t, "login_event", snakeCase("LoginEvent"))
+	assert.Equal(t, "http_request", snakeCase("HTTPRequest"))
+	assert.Equal(t, "event", snakeCase("event"))
+}
@@ -0,0 +1,149 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// unknownKeyPattern yaml 严格解析时未知配置项的错误信息
+var unknownKeyPattern = regexp.MustCompile(`^(line \d+): field (\S+) not found in type \S+$`)
+
+// FileResult 单个 schema 文件的校验结果
+type FileResult struct {
+	File     string   `json:"file"`
+	Project  string   `json:"project,omitempty"`
+	Table    string   `json:"table,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Report schema 目录的校验报告
+type Report struct {
+	Dir         string        `json:"dir"`
+	StorageType string        `json:"storage_type,omitempty"` // 为空时检查所有存储的兼容性
+	Files       []*FileResult `json:"files"`
+}
+
+// ValidateDir 按管理器加载的方式解析目录中的全部 schema 文件，执行 Schema.Validate 与
+// storageType 上的兼容性检查，不访问存储。多个文件声明同一个 schema 视为错误，
+// 未知的配置项（通常是拼写错误）作为警告报告
+func ValidateDir(dir, storageType string) (*Report, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	report := &Report{Dir: dir, StorageType: storageType, Files: []*FileResult{}}
+	owners := make(map[string]string)
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".yaml" {
+			continue
+		}
+
+		filename := filepath.Join(dir, file.Name())
+		result := validateFile(filename, storageType)
+		if result.Project != "" || result.Table != "" {
+			key := result.Project + ":" + result.Table
+			if owner, ok := owners[key]; ok {
+				result.Errors = append(result.Errors, fmt.Sprintf("schema %s is already declared in %s", key, owner))
+			} else {
+				owners[key] = filename
+			}
+		}
+		report.Files = append(report.Files, result)
+	}
+	return report, nil
+}
+
+// validateFile 解析并校验单个文件
+func validateFile(filename, storageType string) *FileResult {
+	result := &FileResult{File: filename}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+
+	schema := &models.Schema{}
+	if err := yaml.Unmarshal(data, schema); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("invalid YAML: %v", err))
+		return result
+	}
+	result.Project = schema.Project
+	result.Table = schema.Table
+
+	if err := schema.Validate(); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	result.Warnings = append(result.Warnings, unknownKeys(data)...)
+	result.Warnings = append(result.Warnings, schema.CompatibilityWarnings(storageType)...)
+	return result
+}
+
+// unknownKeys 严格解析文件，返回管理器会忽略的配置项
+func unknownKeys(data []byte) []string {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var schema models.Schema
+	err := decoder.Decode(&schema)
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return nil
+	}
+
+	var warnings []string
+	for _, message := range typeErr.Errors {
+		if m := unknownKeyPattern.FindStringSubmatch(message); m != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown key %s is ignored", m[1], m[2]))
+		}
+	}
+	return warnings
+}
+
+// ErrorCount 返回错误总数
+func (r *Report) ErrorCount() int {
+	count := 0
+	for _, file := range r.Files {
+		count += len(file.Errors)
+	}
+	return count
+}
+
+// WarningCount 返回警告总数
+func (r *Report) WarningCount() int {
+	count := 0
+	for _, file := range r.Files {
+		count += len(file.Warnings)
+	}
+	return count
+}
+
+// Print 以文本形式输出报告，每个文件一行结果，错误与警告缩进列出
+func (r *Report) Print(w io.Writer) {
+	for _, file := range r.Files {
+		status := "ok"
+		if len(file.Errors) > 0 {
+			status = "invalid"
+		}
+		if file.Project != "" || file.Table != "" {
+			fmt.Fprintf(w, "%s (%s:%s): %s\n", file.File, file.Project, file.Table, status)
+		} else {
+			fmt.Fprintf(w, "%s: %s\n", file.File, status)
+		}
+		for _, message := range file.Errors {
+			fmt.Fprintf(w, "  error: %s\n", message)
+		}
+		for _, message := range file.Warnings {
+			fmt.Fprintf(w, "  warning: %s\n", message)
+		}
+	}
+	fmt.Fprintf(w, "%d files, %d errors, %d warnings\n", len(r.Files), r.ErrorCount(), r.WarningCount())
+}
@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a_valid.yaml": `project: app
+table: logs
+fields:
+  - name: level
+    type: string
+    indexd: true
+  - name: order
+    type: string
+`,
+		"b_duplicate.yaml": `project: app
+table: logs
+fields:
+  - name: level
+    type: string
+`,
+		"c_invalid.yaml": `project: app
+table: events
+fields:
+  - name: id
+    type: string
+`,
+		"d_broken.yaml": "fields: [",
+		"notes.txt":     "ignored",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	report, err := ValidateDir(dir, "mysql")
+	require.NoError(t, err)
+	require.Len(t, report.Files, 4)
+
+	valid := report.Files[0]
+	assert.Equal(t, "app", valid.Project)
+	assert.Equal(t, "logs", valid.Table)
+	assert.Empty(t, valid.Errors)
+	require.Len(t, valid.Warnings, 2)
+	assert.Equal(t, "line 6: unknown key indexd is ignored", valid.Warnings[0])
+	assert.Contains(t, valid.Warnings[1], "field order is a reserved word on mysql")
+
+	require.Len(t, report.Files[1].Errors, 1)
+	assert.Contains(t, report.Files[1].Errors[0], "already declared in "+filepath.Join(dir, "a_valid.yaml"))
+	require.Len(t, report.Files[2].Errors, 1)
+	assert.Contains(t, report.Files[2].Errors[0], "reserved")
+	require.Len(t, report.Files[3].Errors, 1)
+	assert.Contains(t, report.Files[3].Errors[0], "invalid YAML")
+
+	assert.Equal(t, 3, report.ErrorCount())
+	assert.Equal(t, 2, report.WarningCount())
+
+	var out bytes.Buffer
+	report.Print(&out)
+	assert.Contains(t, out.String(), "a_valid.yaml (app:logs): ok\n")
+	assert.Contains(t, out.String(), "d_broken.yaml: invalid\n  error: invalid YAML")
+	assert.Contains(t, out.String(), "4 files, 3 errors, 2 warnings\n")
+
+	_, err = ValidateDir(filepath.Join(dir, "missing"), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// WithWriteBack 开启反向同步：通过 API 创建、修改或删除的 schema 同步到 schema 目录中的 YAML 文件
+func WithWriteBack(enabled bool) Option {
+	return func(m *Manager) {
+		m.writeBack = enabled
+	}
+}
+
+// WriteBack 将 schema 写回声明它的文件，没有对应文件时新建 <project>_<table>.yaml。
+// 文件中不保存创建与更新时间，避免版本库中出现无意义的变更；未开启反向同步时不做任何操作
+func (m *Manager) WriteBack(schema *models.Schema) error {
+	if !m.writeBack {
+		return nil
+	}
+
+	file := schema.Clone()
+	file.CreatedAt = time.Time{}
+	file.UpdatedAt = time.Time{}
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+
+	key := schema.Project + ":" + schema.Table
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	filename := filepath.Join(m.schemasDir, schema.Project+"_"+schema.Table+".yaml")
+	if source, ok := m.sources[key]; ok {
+		filename = source.file
+	} else if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("%w: %s already exists and does not declare %s", ErrSchemaConflict, filename, key)
+	}
+
+	if err := writeFileAtomic(filename, data); err != nil {
+		return err
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fmt.Errorf("failed to stat schema file: %w", err)
+	}
+	m.track(key, schemaSource{file: filename, path: canonicalPath(filename), modTime: info.ModTime(), digest: digest(data)})
+	return m.registry.Put(schema)
+}
+
+// DeleteFile 删除声明 schema 的文件，用于同步通过 API 删除的 schema；未开启反向同步时不做任何操作
+func (m *Manager) DeleteFile(project, table string) error {
+	if !m.writeBack {
+		return nil
+	}
+
+	key := project + ":" + table
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	source, ok := m.sources[key]
+	if !ok {
+		return nil
+	}
+	if err := os.Remove(source.file); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove schema file: %w", err)
+	}
+	m.untrack(key)
+	m.forget(project, table)
+	return nil
+}
+
+// RenameFile 将声明 project/table 的文件改为声明重命名后的 schema。文件名为默认的 <project>_<table>.yaml 时
+// 改用新名称的默认文件，否则在原文件中写入；未开启反向同步时不做任何操作
+func (m *Manager) RenameFile(project, table string, schema *models.Schema) error {
+	if !m.writeBack {
+		return nil
+	}
+
+	key := project + ":" + table
+	m.mu.Lock()
+	source, ok := m.sources[key]
+	if ok {
+		m.untrack(key)
+		if filepath.Base(source.file) == project+"_"+table+".yaml" {
+			if err := os.Remove(source.file); err != nil && !os.IsNotExist(err) {
+				m.mu.Unlock()
+				return fmt.Errorf("failed to remove schema file: %w", err)
+			}
+		} else {
+			m.track(schema.Project+":"+schema.Table, source)
+		}
+	}
+	m.mu.Unlock()
+	m.forget(project, table)
+
+	return m.WriteBack(schema)
+}
+
+// exportMissing 将存储中没有对应文件的 schema 写入 schema 目录
+func (m *Manager) exportMissing() error {
+	schemas, err := m.storage.ListSchemas(m.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list schemas: %w", err)
+	}
+
+	for _, schema := range schemas {
+		m.mu.RLock()
+		_, ok := m.sources[schema.Project+":"+schema.Table]
+		m.mu.RUnlock()
+		if ok {
+			continue
+		}
+		if err := m.WriteBack(schema); err != nil {
+			// 记录错误但继续处理其他 schema
+			m.logger.Error("failed to write schema",
+				zap.String("project", schema.Project), zap.String("table", schema.Table), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免文件监控读到写了一半的内容。
+// 临时文件不以 .yaml 结尾，不会触发重新加载
+func writeFileAtomic(filename string, data []byte) error {
+	tmp := filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schema file: %w", err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write schema file: %w", err)
+	}
+	return nil
+}
+
+// digest 计算文件内容摘要
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,130 @@
+package schema
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+func testSchema(project, table, description string) *models.Schema {
+	return &models.Schema{
+		Project:     project,
+		Table:       table,
+		Description: description,
+		Fields: []*models.Field{
+			{Name: "level", Type: models.FieldTypeString, Required: true},
+			{Name: "message", Type: models.FieldTypeString, Required: true},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func readSchemaFile(t *testing.T, filename string) (*models.Schema, string) {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	var schema models.Schema
+	require.NoError(t, yaml.Unmarshal(data, &schema))
+	return &schema, string(data)
+}
+
+func TestManagerWriteBack(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage := newMockStorage()
+	// 只存在于存储中的 schema 在启动时写入目录
+	require.NoError(t, storage.CreateSchema(ctx, testSchema("app", "orphan", "From the database")))
+	require.NoError(t, testSchema("app", "requests", "From git").SaveToFile(filepath.Join(dir, "requests.yaml")))
+
+	mock := clock.NewMock(time.Now())
+	manager, err := NewManager(storage, dir, WithClock(mock), WithWriteBack(true))
+	require.NoError(t, err)
+	defer manager.Stop()
+	require.NoError(t, manager.Start())
+
+	orphan, data := readSchemaFile(t, filepath.Join(dir, "app_orphan.yaml"))
+	assert.Equal(t, "From the database", orphan.Description)
+	assert.NotContains(t, data, "created_at")
+
+	// 已有文件的 schema 写回原文件
+	updated := testSchema("app", "requests", "Updated via API")
+	require.NoError(t, manager.WriteBack(updated))
+	written, data := readSchemaFile(t, filepath.Join(dir, "requests.yaml"))
+	assert.Equal(t, "Updated via API", written.Description)
+	assert.NotContains(t, data, "updated_at")
+	_, err = os.Stat(filepath.Join(dir, "app_requests.yaml"))
+	assert.True(t, os.IsNotExist(err))
+
+	// 写回触发的文件事件不会再次写入存储
+	sentinel := testSchema("app", "requests", "Changed in storage")
+	require.NoError(t, storage.UpdateSchema(ctx, sentinel))
+	mock.BlockUntil(1)
+	mock.Add(defaultDebounce)
+	current, err := storage.GetSchema(ctx, "app", "requests")
+	require.NoError(t, err)
+	assert.Same(t, sentinel, current)
+
+	// 新 schema 写入 <project>_<table>.yaml
+	require.NoError(t, manager.WriteBack(testSchema("app", "events", "Created via API")))
+	created, _ := readSchemaFile(t, filepath.Join(dir, "app_events.yaml"))
+	assert.Equal(t, "Created via API", created.Description)
+	assert.Equal(t, "app:events", manager.Status().Files[filepath.Join(dir, "app_events.yaml")])
+
+	// 不属于该 schema 的同名文件不会被覆盖
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app_other.yaml"), []byte("invalid yaml"), 0644))
+	assert.ErrorIs(t, manager.WriteBack(testSchema("app", "other", "")), ErrSchemaConflict)
+
+	require.NoError(t, manager.DeleteFile("app", "events"))
+	_, err = os.Stat(filepath.Join(dir, "app_events.yaml"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = manager.GetSchema("app", "events")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+}
+
+func TestManagerWriteBackDisabled(t *testing.T) {
+	dir := t.TempDir()
+	manager, err := NewManager(newMockStorage(), dir)
+	require.NoError(t, err)
+	defer manager.Stop()
+
+	require.NoError(t, manager.WriteBack(testSchema("app", "events", "")))
+	require.NoError(t, manager.DeleteFile("app", "events"))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestManagerRenameFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, testSchema("app", "requests", "From git").SaveToFile(filepath.Join(dir, "requests.yaml")))
+	manager, err := NewManager(newMockStorage(), dir, WithWriteBack(true))
+	require.NoError(t, err)
+	defer manager.Stop()
+	require.NoError(t, manager.Start())
+	require.NoError(t, manager.WriteBack(testSchema("app", "events", "Created via API")))
+
+	// 默认文件名随 schema 一起重命名
+	require.NoError(t, manager.RenameFile("app", "events", testSchema("app", "audit", "Created via API")))
+	_, err = os.Stat(filepath.Join(dir, "app_events.yaml"))
+	assert.True(t, os.IsNotExist(err))
+	renamed, _ := readSchemaFile(t, filepath.Join(dir, "app_audit.yaml"))
+	assert.Equal(t, "audit", renamed.Table)
+
+	// 自定义文件名保持不变
+	require.NoError(t, manager.RenameFile("app", "requests", testSchema("web", "requests", "From git")))
+	moved, _ := readSchemaFile(t, filepath.Join(dir, "requests.yaml"))
+	assert.Equal(t, "web", moved.Project)
+	files := manager.Status().Files
+	assert.Equal(t, "web:requests", files[filepath.Join(dir, "requests.yaml")])
+	assert.Equal(t, "app:audit", files[filepath.Join(dir, "app_audit.yaml")])
+	assert.Len(t, files, 2)
+}
@@ -0,0 +1,147 @@
+// Package sketch 实现可合并的分位数草图（DDSketch）。数值按对数划分到相对宽度固定的桶中，
+// 估算的分位数与真实值的相对误差不超过 RelativeAccuracy，两个草图合并后与直接统计全部数值的结果相同
+package sketch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// RelativeAccuracy 分位数估算的相对误差上限
+const RelativeAccuracy = 0.01
+
+// minIndexable 绝对值小于该值的数按 0 统计
+const minIndexable = 1e-9
+
+var (
+	gamma    = (1 + RelativeAccuracy) / (1 - RelativeAccuracy)
+	logGamma = math.Log(gamma)
+)
+
+// Sketch 分位数草图，零值不可用，使用 New 创建
+type Sketch struct {
+	positive map[int]int64 // 正数所在桶的序号 -> 个数
+	negative map[int]int64 // 负数绝对值所在桶的序号 -> 个数
+	zero     int64
+	count    int64
+}
+
+// New 创建空草图
+func New() *Sketch {
+	return &Sketch{positive: make(map[int]int64), negative: make(map[int]int64)}
+}
+
+// index 返回正数 v 所在桶的序号，桶 i 覆盖 (gamma^(i-1), gamma^i]
+func index(v float64) int {
+	return int(math.Ceil(math.Log(v) / logGamma))
+}
+
+// value 返回桶 i 的代表值，与桶内任意数的相对误差不超过 RelativeAccuracy
+func value(i int) float64 {
+	return 2 * math.Pow(gamma, float64(i)) / (gamma + 1)
+}
+
+// Add 加入一个数，NaN 与无穷大被忽略
+func (s *Sketch) Add(v float64) {
+	switch {
+	case math.IsNaN(v) || math.IsInf(v, 0):
+		return
+	case v >= minIndexable:
+		s.positive[index(v)]++
+	case v <= -minIndexable:
+		s.negative[index(-v)]++
+	default:
+		s.zero++
+	}
+	s.count++
+}
+
+// Merge 将 other 中的数并入 s
+func (s *Sketch) Merge(other *Sketch) {
+	for i, n := range other.positive {
+		s.positive[i] += n
+	}
+	for i, n := range other.negative {
+		s.negative[i] += n
+	}
+	s.zero += other.zero
+	s.count += other.count
+}
+
+// Count 返回加入的数的个数
+func (s *Sketch) Count() int64 {
+	return s.count
+}
+
+// Quantile 返回 q（0 到 1）分位数的估算值，草图为空时返回 false
+func (s *Sketch) Quantile(q float64) (float64, bool) {
+	if s.count == 0 || q < 0 || q > 1 {
+		return 0, false
+	}
+	rank := int64(q * float64(s.count-1))
+
+	// 负数按绝对值从大到小，即数值从小到大
+	var seen int64
+	for _, i := range keys(s.negative, true) {
+		if seen += s.negative[i]; seen > rank {
+			return -value(i), true
+		}
+	}
+	if seen += s.zero; seen > rank {
+		return 0, true
+	}
+	for _, i := range keys(s.positive, false) {
+		if seen += s.positive[i]; seen > rank {
+			return value(i), true
+		}
+	}
+	return 0, false
+}
+
+// keys 返回排序后的桶序号
+func keys(bins map[int]int64, desc bool) []int {
+	result := make([]int, 0, len(bins))
+	for i := range bins {
+		result = append(result, i)
+	}
+	if desc {
+		sort.Sort(sort.Reverse(sort.IntSlice(result)))
+	} else {
+		sort.Ints(result)
+	}
+	return result
+}
+
+// encoded 草图的 JSON 编码，桶序号作为对象的键
+type encoded struct {
+	Positive map[int]int64 `json:"p,omitempty"`
+	Negative map[int]int64 `json:"n,omitempty"`
+	Zero     int64         `json:"z,omitempty"`
+}
+
+// MarshalJSON 将草图编码为 JSON，用于保存到文本列
+func (s *Sketch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encoded{Positive: s.positive, Negative: s.negative, Zero: s.zero})
+}
+
+// UnmarshalJSON 从 JSON 解码草图
+func (s *Sketch) UnmarshalJSON(data []byte) error {
+	var e encoded
+	if err := json.Unmarshal(data, &e); err != nil {
+		return fmt.Errorf("invalid sketch: %w", err)
+	}
+	*s = *New()
+	for i, n := range e.Positive {
+		s.positive[i] = n
+		s.count += n
+	}
+	for i, n := range e.Negative {
+		s.negative[i] = n
+		s.count += n
+	}
+	s.zero = e.Zero
+	s.count += e.Zero
+	return nil
+}
@@ -0,0 +1,69 @@
+package sketch
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuantile(t *testing.T) {
+	s := New()
+	_, ok := s.Quantile(0.5)
+	assert.False(t, ok, "empty sketch")
+
+	for i := 1; i <= 10000; i++ {
+		s.Add(float64(i))
+	}
+	s.Add(math.NaN())
+	assert.Equal(t, int64(10000), s.Count())
+	for _, q := range []float64{0, 0.5, 0.9, 0.99, 1} {
+		got, ok := s.Quantile(q)
+		require.True(t, ok)
+		want := 1 + q*9999
+		assert.InEpsilon(t, want, got, RelativeAccuracy, "q=%v", q)
+	}
+	_, ok = s.Quantile(1.5)
+	assert.False(t, ok)
+}
+
+func TestNegativeAndZero(t *testing.T) {
+	s := New()
+	for _, v := range []float64{-100, -10, 0, 0, 10} {
+		s.Add(v)
+	}
+	low, _ := s.Quantile(0)
+	assert.InEpsilon(t, -100, low, RelativeAccuracy)
+	mid, _ := s.Quantile(0.5)
+	assert.Zero(t, mid)
+	high, _ := s.Quantile(1)
+	assert.InEpsilon(t, 10, high, RelativeAccuracy)
+}
+
+func TestMergeAndEncode(t *testing.T) {
+	all, a, b := New(), New(), New()
+	for i := 1; i <= 1000; i++ {
+		all.Add(float64(i))
+		if i%2 == 0 {
+			a.Add(float64(i))
+		} else {
+			b.Add(float64(i))
+		}
+	}
+	a.Merge(b)
+	assert.Equal(t, all, a)
+
+	data, err := json.Marshal(a)
+	require.NoError(t, err)
+	decoded := New()
+	require.NoError(t, json.Unmarshal(data, decoded))
+	assert.Equal(t, a, decoded)
+	for _, q := range []float64{0.5, 0.99} {
+		want, _ := all.Quantile(q)
+		got, _ := decoded.Quantile(q)
+		assert.Equal(t, want, got)
+	}
+	assert.Error(t, json.Unmarshal([]byte(`{"p": []}`), decoded))
+}
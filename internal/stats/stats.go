@@ -0,0 +1,253 @@
+// Package stats 按项目与表增量统计写入的日志条数、估算字节数、被拒绝的条数与最近写入时间。
+// 计数以分钟为粒度保留 MaxWindow，查询任意窗口只合并内存中的计数，不扫描日志表
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+const (
+	// Resolution 计数的时间粒度
+	Resolution = time.Minute
+	// MaxWindow 可查询的最长窗口，更早的分钟计数被丢弃，只计入总数
+	MaxWindow = 24 * time.Hour
+	// DefaultWindow 未指定窗口时使用的窗口
+	DefaultWindow = time.Hour
+	// MaxTables 最多统计的项目/表组合数，超过后新的组合不再统计
+	MaxTables = 1000
+)
+
+// key 统计维度
+type key struct {
+	project, table string
+}
+
+// bucket 一分钟内的计数
+type bucket struct {
+	minute   int64 // Unix 分钟数
+	rows     int64
+	bytes    int64
+	rejected int64
+}
+
+// add 累加另一组计数
+func (b *bucket) add(other *bucket) {
+	b.rows += other.rows
+	b.bytes += other.bytes
+	b.rejected += other.rejected
+}
+
+// series 一张表的分钟计数与累计总数
+type series struct {
+	buckets   []bucket // 按分钟升序，只保留 MaxWindow 内有写入的分钟
+	total     bucket
+	lastWrite time.Time
+}
+
+// current 返回 minute 的计数，并丢弃超出 MaxWindow 的旧计数
+func (s *series) current(minute int64) *bucket {
+	if n := len(s.buckets); n > 0 && s.buckets[n-1].minute == minute {
+		return &s.buckets[n-1]
+	}
+	oldest := minute - int64(MaxWindow/Resolution)
+	i := 0
+	for i < len(s.buckets) && s.buckets[i].minute <= oldest {
+		i++
+	}
+	s.buckets = append(s.buckets[i:], bucket{minute: minute})
+	return &s.buckets[len(s.buckets)-1]
+}
+
+// TableStats 一张表在窗口内的写入统计
+type TableStats struct {
+	Project       string     `json:"project"`
+	Table         string     `json:"table"`
+	Rows          int64      `json:"rows"`           // 写入成功的日志条数
+	Bytes         int64      `json:"bytes"`          // 写入成功的日志的估算大小，按字段内容计算，不含存储开销
+	Rejected      int64      `json:"rejected"`       // 未通过校验或写入失败的日志条数
+	ErrorRate     float64    `json:"error_rate"`     // Rejected 占全部写入尝试的比例
+	AvgEntrySize  float64    `json:"avg_entry_size"` // Bytes / Rows
+	RowsPerSecond float64    `json:"rows_per_second"`
+	LastWrite     *time.Time `json:"last_write,omitempty"` // 最近一次写入成功的时间，不受窗口限制
+}
+
+// Tracker 按项目与表统计写入，可并发使用；nil 的 Tracker 不统计
+type Tracker struct {
+	clock   clock.Clock
+	started time.Time
+
+	mu     sync.Mutex
+	series map[key]*series
+}
+
+// New 创建 Tracker，c 为 nil 时使用系统时间
+func New(c clock.Clock) *Tracker {
+	c = clock.OrReal(c)
+	return &Tracker{clock: c, started: c.Now(), series: make(map[key]*series)}
+}
+
+// Started 返回开始统计的时间，早于该时间的写入不在统计中
+func (t *Tracker) Started() time.Time {
+	return t.started
+}
+
+// Observe 将成功写入的一批日志计入统计
+func (t *Tracker) Observe(project, table string, logs []*models.LogEntry) {
+	if t == nil || len(logs) == 0 {
+		return
+	}
+	var size int64
+	for _, log := range logs {
+		size += EntrySize(log)
+	}
+	now := t.clock.Now()
+	t.record(project, table, now, &bucket{rows: int64(len(logs)), bytes: size})
+}
+
+// Reject 将 n 条未通过校验或写入失败的日志计入统计
+func (t *Tracker) Reject(project, table string, n int) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.record(project, table, t.clock.Now(), &bucket{rejected: int64(n)})
+}
+
+// record 累加 now 所在分钟的计数
+func (t *Tracker) record(project, table string, now time.Time, counts *bucket) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key{project, table}
+	s, ok := t.series[k]
+	if !ok {
+		if len(t.series) >= MaxTables {
+			return
+		}
+		s = &series{}
+		t.series[k] = s
+	}
+	s.current(now.Unix() / int64(Resolution/time.Second)).add(counts)
+	s.total.add(counts)
+	if counts.rows > 0 {
+		s.lastWrite = now
+	}
+}
+
+// ParseWindow 解析窗口参数：空值为 DefaultWindow，all 表示自开始统计以来（返回 0），
+// 其余为 1m 以上、不超过 MaxWindow 的时长，如 5m、1h、1d
+func ParseWindow(value string) (time.Duration, error) {
+	switch value {
+	case "":
+		return DefaultWindow, nil
+	case "all":
+		return 0, nil
+	}
+	window, err := models.ParseRetention(value)
+	if err != nil || window < Resolution || window > MaxWindow {
+		return 0, fmt.Errorf("invalid window %q: use a duration between 1m and 24h, or all", value)
+	}
+	return window, nil
+}
+
+// Stats 返回各表在最近 window 内的统计，window 为 0 时返回自开始统计以来的总数；
+// project、table 非空时只返回匹配的表。结果按项目与表名排序
+func (t *Tracker) Stats(window time.Duration, project, table string) []*TableStats {
+	now := t.clock.Now()
+	elapsed := now.Sub(t.started)
+	if window > 0 && window < elapsed {
+		elapsed = window
+	}
+	// 当前分钟也计入窗口，窗口覆盖最近 window/Resolution 个分钟
+	first := now.Unix()/int64(Resolution/time.Second) - int64(window/Resolution) + 1
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]*TableStats, 0, len(t.series))
+	for k, s := range t.series {
+		if (project != "" && k.project != project) || (table != "" && k.table != table) {
+			continue
+		}
+		counts := s.total
+		if window > 0 {
+			counts = bucket{}
+			for i := range s.buckets {
+				if s.buckets[i].minute >= first {
+					counts.add(&s.buckets[i])
+				}
+			}
+		}
+		stats := &TableStats{Project: k.project, Table: k.table, Rows: counts.rows, Bytes: counts.bytes, Rejected: counts.rejected}
+		if attempts := counts.rows + counts.rejected; attempts > 0 {
+			stats.ErrorRate = float64(counts.rejected) / float64(attempts)
+		}
+		if counts.rows > 0 {
+			stats.AvgEntrySize = float64(counts.bytes) / float64(counts.rows)
+		}
+		if seconds := elapsed.Seconds(); seconds > 0 {
+			stats.RowsPerSecond = float64(counts.rows) / seconds
+		}
+		if !s.lastWrite.IsZero() {
+			lastWrite := s.lastWrite
+			stats.LastWrite = &lastWrite
+		}
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Project != result[j].Project {
+			return result[i].Project < result[j].Project
+		}
+		return result[i].Table < result[j].Table
+	})
+	return result
+}
+
+// EntrySize 估算日志的大小：消息、级别、标签与字段的键和值的字节数，数值与时间按 8 字节计
+func EntrySize(log *models.LogEntry) int64 {
+	size := int64(len(log.Message) + len(log.Level))
+	for name, value := range log.Fields {
+		// 消息与级别已按 LogEntry 的字段计入
+		if name == "message" || name == "level" {
+			continue
+		}
+		size += int64(len(name)) + valueSize(value)
+	}
+	for name, value := range log.Tags {
+		size += int64(len(name) + len(value))
+	}
+	return size
+}
+
+// valueSize 估算字段值的字节数
+func valueSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case bool:
+		return 1
+	case map[string]interface{}:
+		var size int64
+		for name, item := range v {
+			size += int64(len(name)) + valueSize(item)
+		}
+		return size
+	case []interface{}:
+		var size int64
+		for _, item := range v {
+			size += valueSize(item)
+		}
+		return size
+	default:
+		return 8
+	}
+}
@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+func TestTracker(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	mock := clock.NewMock(start)
+	tracker := New(mock)
+
+	entry := &models.LogEntry{Level: "info", Message: "hello", Fields: map[string]interface{}{
+		"message": "hello", "level": "info", "status": 200, "path": "/api",
+	}}
+	// info + hello + status(6+8) + path(4+4)
+	assert.Equal(t, int64(31), EntrySize(entry))
+
+	tracker.Observe("app", "requests", []*models.LogEntry{entry, entry})
+	tracker.Reject("app", "requests", 2)
+	mock.Add(90 * time.Minute)
+	tracker.Observe("app", "requests", []*models.LogEntry{entry})
+	tracker.Observe("app", "jobs", []*models.LogEntry{entry})
+	var nilTracker *Tracker
+	nilTracker.Observe("app", "requests", []*models.LogEntry{entry})
+
+	// 最近一小时只包含之后的写入
+	stats := tracker.Stats(time.Hour, "", "")
+	require.Len(t, stats, 2)
+	assert.Equal(t, "jobs", stats[0].Table)
+	requests := stats[1]
+	assert.Equal(t, int64(1), requests.Rows)
+	assert.Equal(t, int64(31), requests.Bytes)
+	assert.Zero(t, requests.Rejected)
+	assert.Zero(t, requests.ErrorRate)
+	assert.Equal(t, 31.0, requests.AvgEntrySize)
+	assert.InDelta(t, 1.0/3600, requests.RowsPerSecond, 1e-9)
+	assert.Equal(t, start.Add(90*time.Minute), *requests.LastWrite)
+
+	// 自开始统计以来
+	stats = tracker.Stats(0, "app", "requests")
+	require.Len(t, stats, 1)
+	assert.Equal(t, int64(3), stats[0].Rows)
+	assert.Equal(t, int64(2), stats[0].Rejected)
+	assert.Equal(t, 0.4, stats[0].ErrorRate)
+	assert.InDelta(t, 3.0/5400, stats[0].RowsPerSecond, 1e-9)
+
+	stats = tracker.Stats(2*time.Hour, "app", "requests")
+	assert.Equal(t, int64(3), stats[0].Rows)
+
+	// 超过 MaxWindow 的分钟计数被丢弃，总数保留
+	mock.Add(MaxWindow)
+	tracker.Reject("app", "requests", 1)
+	stats = tracker.Stats(MaxWindow, "app", "requests")
+	assert.Zero(t, stats[0].Rows)
+	assert.Equal(t, int64(1), stats[0].Rejected)
+	assert.Equal(t, 1.0, stats[0].ErrorRate)
+	assert.NotNil(t, stats[0].LastWrite, "the last write time does not depend on the window")
+	tracker.mu.Lock()
+	assert.Len(t, tracker.series[key{"app", "requests"}].buckets, 1)
+	tracker.mu.Unlock()
+	assert.Equal(t, int64(3), tracker.Stats(0, "app", "requests")[0].Rows)
+}
+
+func TestParseWindow(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": DefaultWindow, "all": 0, "5m": 5 * time.Minute, "1d": 24 * time.Hour} {
+		window, err := ParseWindow(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, window)
+	}
+	for _, value := range []string{"30s", "2d", "soon", "-1h"} {
+		_, err := ParseWindow(value)
+		assert.Error(t, err, value)
+	}
+}
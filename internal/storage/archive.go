@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+// SchemaArchiver 软删除 schema 的可选能力：删除 schema 时保留日志数据，
+// 日志表重命名为 <表名>_archived_<UTC 时间>，永久删除前可恢复
+type SchemaArchiver interface {
+	// ArchiveSchema 删除 schema 记录并归档日志表，持续聚合表直接删除，恢复时重新创建
+	ArchiveSchema(ctx context.Context, project, table string) (*models.ArchivedSchema, error)
+	// ListArchivedSchemas 按归档时间从旧到新列出全部归档
+	ListArchivedSchemas(ctx context.Context) ([]*models.ArchivedSchema, error)
+	// RestoreSchema 恢复 project/table 最近一次的归档，同名 schema 已存在时返回 ErrSchemaExists
+	RestoreSchema(ctx context.Context, project, table string) (*models.Schema, error)
+	// PurgeArchivedSchemas 永久删除 before 之前归档的日志表，返回被删除的归档
+	PurgeArchivedSchemas(ctx context.Context, before time.Time) ([]*models.ArchivedSchema, error)
+}
+
+// schemaArchives 基于 archived_schemas 表的归档记录，供 SQL 存储共用
+type schemaArchives struct {
+	db      *sql.DB
+	dialect string
+}
+
+// newSchemaArchives 创建归档记录存储
+func newSchemaArchives(db *sql.DB, dialect string) *schemaArchives {
+	return &schemaArchives{db: db, dialect: dialect}
+}
+
+// createTable 创建归档记录表，schema 定义以 JSON 保存
+func (a *schemaArchives) createTable(ctx context.Context) error {
+	text, ts := "TEXT", "TIMESTAMP"
+	switch a.dialect {
+	case "mysql":
+		ts = "DATETIME(6)"
+	case "postgres":
+		text, ts = "JSONB", "TIMESTAMP WITH TIME ZONE"
+	}
+
+	query := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS archived_schemas (
+		archive_table VARCHAR(255) PRIMARY KEY,
+		project VARCHAR(255),
+		table_name VARCHAR(255),
+		definition %s,
+		archived_at %s
+	)`, text, ts)
+
+	if _, err := a.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("创建归档表失败: %w", err)
+	}
+	return nil
+}
+
+// archive 在一个事务中删除 schema 记录并登记归档，rename 在同一事务提交前将日志表重命名为归档表。
+// name 为当前日志表名（未引用）
+func (a *schemaArchives) archive(ctx context.Context, store Storage, project, table, name string, c clock.Clock,
+	rename func(tx *sql.Tx, archived *models.ArchivedSchema) error) (*models.ArchivedSchema, error) {
+	schema, err := store.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+	now := clock.OrReal(c).Now().UTC()
+	archived := &models.ArchivedSchema{
+		Project:      project,
+		Table:        table,
+		ArchiveTable: models.ArchiveTableName(name, now),
+		Schema:       schema,
+		ArchivedAt:   now,
+	}
+	definition, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("序列化 schema 失败: %w", err)
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开始事务失败: %w", unavailable(err))
+	}
+	defer tx.Rollback()
+
+	if err := deleteSchemaRecord(ctx, tx, a.dialect, project, table); err != nil {
+		return nil, err
+	}
+	p := func(n int) string { return placeholder(a.dialect, n) }
+	query := fmt.Sprintf(`INSERT INTO archived_schemas (archive_table, project, table_name, definition, archived_at)
+	VALUES (%s, %s, %s, %s, %s)`, p(1), p(2), p(3), p(4), p(5))
+	if _, err := tx.ExecContext(ctx, query, archived.ArchiveTable, project, table, string(definition), now); err != nil {
+		return nil, fmt.Errorf("保存归档记录失败: %w", unavailable(err))
+	}
+	if err := rename(tx, archived); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", unavailable(err))
+	}
+	return archived, nil
+}
+
+// restore 恢复 project/table 最近一次的归档：rename 将归档表改回原名，随后删除归档记录并重新创建 schema
+func (a *schemaArchives) restore(ctx context.Context, store Storage, project, table string,
+	rename func(archived *models.ArchivedSchema) error) (*models.Schema, error) {
+	archives, err := a.scan(a.db.QueryContext(ctx, fmt.Sprintf(`SELECT archive_table, project, table_name, definition, archived_at
+	FROM archived_schemas WHERE project = %s AND table_name = %s ORDER BY archived_at DESC`,
+		placeholder(a.dialect, 1), placeholder(a.dialect, 2)), project, table))
+	if err != nil {
+		return nil, err
+	}
+	if len(archives) == 0 {
+		return nil, fmt.Errorf("%w: %s_%s", models.ErrArchivedSchemaNotFound, project, table)
+	}
+	archived := archives[0]
+
+	if _, err := store.GetSchema(ctx, project, table); err == nil {
+		return nil, fmt.Errorf("%w: %s_%s", models.ErrSchemaExists, project, table)
+	} else if !errors.Is(err, models.ErrSchemaNotFound) {
+		return nil, err
+	}
+
+	if err := rename(archived); err != nil {
+		return nil, err
+	}
+	if err := a.remove(ctx, archived.ArchiveTable); err != nil {
+		return nil, err
+	}
+	// 日志表已存在，CreateSchema 只保存 schema 记录并重新创建持续聚合表
+	if err := store.CreateSchema(ctx, archived.Schema); err != nil {
+		return nil, err
+	}
+	return archived.Schema, nil
+}
+
+// purge 永久删除 before 之前的归档，drop 删除归档日志表
+func (a *schemaArchives) purge(ctx context.Context, before time.Time,
+	drop func(archived *models.ArchivedSchema) error) ([]*models.ArchivedSchema, error) {
+	archives, err := a.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	purged := make([]*models.ArchivedSchema, 0)
+	for _, archived := range archives {
+		if !archived.ArchivedAt.Before(before) {
+			continue
+		}
+		if err := drop(archived); err != nil {
+			return purged, fmt.Errorf("删除归档日志表失败: %w", unavailable(err))
+		}
+		if err := a.remove(ctx, archived.ArchiveTable); err != nil {
+			return purged, err
+		}
+		purged = append(purged, archived)
+	}
+	return purged, nil
+}
+
+// list 按归档时间从旧到新列出全部归档
+func (a *schemaArchives) list(ctx context.Context) ([]*models.ArchivedSchema, error) {
+	return a.scan(a.db.QueryContext(ctx, `SELECT archive_table, project, table_name, definition, archived_at
+	FROM archived_schemas ORDER BY archived_at, archive_table`))
+}
+
+// remove 删除归档记录
+func (a *schemaArchives) remove(ctx context.Context, archiveTable string) error {
+	query := fmt.Sprintf(`DELETE FROM archived_schemas WHERE archive_table = %s`, placeholder(a.dialect, 1))
+	if _, err := a.db.ExecContext(ctx, query, archiveTable); err != nil {
+		return fmt.Errorf("删除归档记录失败: %w", unavailable(err))
+	}
+	return nil
+}
+
+// scan 解析归档记录的结果集
+func (a *schemaArchives) scan(rows *sql.Rows, err error) ([]*models.ArchivedSchema, error) {
+	if err != nil {
+		return nil, fmt.Errorf("查询归档记录失败: %w", unavailable(err))
+	}
+	defer rows.Close()
+
+	archives := make([]*models.ArchivedSchema, 0)
+	for rows.Next() {
+		var (
+			archived   models.ArchivedSchema
+			definition []byte
+		)
+		if err := rows.Scan(&archived.ArchiveTable, &archived.Project, &archived.Table, &definition, &archived.ArchivedAt); err != nil {
+			return nil, fmt.Errorf("扫描归档记录失败: %w", err)
+		}
+		if err := json.Unmarshal(definition, &archived.Schema); err != nil {
+			return nil, fmt.Errorf("解析归档 schema 失败: %w", err)
+		}
+		archived.ArchivedAt = archived.ArchivedAt.UTC()
+		archives = append(archives, &archived)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %w", err)
+	}
+	return archives, nil
+}
+
+// renameTable 重命名表，from 为引用后的完整表名，to 为未引用的新表名
+func renameTable(ctx context.Context, db execer, dialect, from, to string) error {
+	query := fmt.Sprintf("ALTER TABLE %s RENAME TO %s", from, quoteIdent(dialect, to))
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("重命名日志表失败: %w", unavailable(err))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+func TestSQLiteArchiveSchema(t *testing.T) {
+	for _, perProject := range []bool{false, true} {
+		t.Run(map[bool]string{false: "shared", true: "per_project"}[perProject], func(t *testing.T) {
+			ctx := context.Background()
+			mock := clock.NewMock(time.Date(2024, 3, 14, 10, 0, 0, 0, time.UTC))
+			store := NewSQLiteStorage(Config{
+				Type:   "sqlite",
+				SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db"), PerProject: perProject},
+				Clock:  mock,
+			})
+			require.NoError(t, store.Initialize(ctx))
+			defer store.Close()
+
+			schema := &models.Schema{
+				Project: "app",
+				Table:   "events",
+				Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString}},
+			}
+			require.NoError(t, store.CreateSchema(ctx, schema))
+			require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+				Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: time.Now(),
+				Fields: map[string]interface{}{"name": "kept"},
+			}))
+
+			archived, err := store.ArchiveSchema(ctx, "app", "events")
+			require.NoError(t, err)
+			assert.Equal(t, "logs_app_events_archived_20240314100000", archived.ArchiveTable)
+			_, err = store.GetSchema(ctx, "app", "events")
+			assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+			_, err = store.ArchiveSchema(ctx, "app", "events")
+			assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+
+			archives, err := store.ListArchivedSchemas(ctx)
+			require.NoError(t, err)
+			require.Len(t, archives, 1)
+			assert.Equal(t, "events", archives[0].Table)
+			assert.Equal(t, mock.Now(), archives[0].ArchivedAt)
+			assert.Len(t, archives[0].Schema.Fields, 1)
+
+			// 同名 schema 重新创建后不能恢复
+			require.NoError(t, store.CreateSchema(ctx, schema))
+			_, err = store.RestoreSchema(ctx, "app", "events")
+			assert.ErrorIs(t, err, models.ErrSchemaExists)
+			require.NoError(t, store.DeleteSchema(ctx, "app", "events"))
+
+			restored, err := store.RestoreSchema(ctx, "app", "events")
+			require.NoError(t, err)
+			assert.Equal(t, "events", restored.Table)
+			rows, err := store.QueryLogs(ctx, "app", "events", nil, 10, 0)
+			require.NoError(t, err)
+			require.Len(t, rows, 1)
+			assert.Equal(t, "kept", rows[0]["name"])
+			_, err = store.RestoreSchema(ctx, "app", "events")
+			assert.ErrorIs(t, err, models.ErrArchivedSchemaNotFound)
+
+			// 宽限期内的归档不会被删除
+			_, err = store.ArchiveSchema(ctx, "app", "events")
+			require.NoError(t, err)
+			purged, err := store.PurgeArchivedSchemas(ctx, mock.Now())
+			require.NoError(t, err)
+			assert.Empty(t, purged)
+
+			mock.Add(time.Hour)
+			purged, err = store.PurgeArchivedSchemas(ctx, mock.Now())
+			require.NoError(t, err)
+			require.Len(t, purged, 1)
+			archives, err = store.ListArchivedSchemas(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, archives)
+
+			// 归档表已删除，重新创建的 schema 没有旧数据
+			require.NoError(t, store.CreateSchema(ctx, schema))
+			rows, err = store.QueryLogs(ctx, "app", "events", nil, 10, 0)
+			require.NoError(t, err)
+			assert.Empty(t, rows)
+		})
+	}
+}
+
+func TestArchiveRetryStorage(t *testing.T) {
+	ctx := context.Background()
+	store, err := New(ctx, Config{Type: "sqlite", SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")}})
+	require.NoError(t, err)
+	defer store.Close()
+
+	archiver, ok := As[SchemaArchiver](store)
+	require.True(t, ok)
+	_, err = archiver.ArchiveSchema(ctx, "app", "missing")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+
+	_, ok = As[SchemaArchiver](WithRetry(NewFileStorage(Config{}), RetryConfig{}, nil, nil))
+	assert.False(t, ok)
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// 基准测试通过环境变量配置：
+//
+//	BENCH_BACKENDS      参与测试的存储，逗号分隔，默认 sqlite
+//	BENCH_BATCH_SIZES   每批写入条数，默认 100,1000
+//	BENCH_FIELD_COUNTS  schema 字段数，默认 5,20
+//	BENCH_QUERY_ROWS    查询基准预先写入的行数，默认 10000
+//
+// 外部数据库的连接参数与集成测试相同（POSTGRES_*、MYSQL_*、CLICKHOUSE_*），
+// 可用 docker-compose.bench.yml 启动，无法连接的存储会被跳过
+
+// benchInts 读取逗号分隔的整数列表
+func benchInts(b *testing.B, key string, defaults []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaults
+	}
+	var values []int
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 {
+			b.Fatalf("invalid %s: %q", key, value)
+		}
+		values = append(values, n)
+	}
+	return values
+}
+
+// benchBackends 返回要测试的存储类型
+func benchBackends() []string {
+	value := getEnvOrDefault("BENCH_BACKENDS", "sqlite")
+	var backends []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			backends = append(backends, part)
+		}
+	}
+	return backends
+}
+
+// openBenchStorage 创建并初始化存储，无法连接时跳过
+func openBenchStorage(b *testing.B, backend string) Storage {
+	config := Config{Type: backend, Logger: zap.NewNop()}
+	var store Storage
+	switch backend {
+	case "sqlite":
+		config.SQLite = SQLiteConfig{Path: filepath.Join(b.TempDir(), "bench.db")}
+		store = NewSQLiteStorage(config)
+	case "postgres":
+		config.Postgres = testConfig.Postgres
+		config.Postgres.Schema = "logs_bench"
+		store = NewPostgresStorage(config)
+	case "mysql":
+		config.MySQL = MySQLConfig{
+			Host:     getEnvOrDefault("MYSQL_HOST", "localhost"),
+			Port:     getEnvOrDefaultInt("MYSQL_PORT", 3306),
+			Database: getEnvOrDefault("MYSQL_DATABASE", "logs_test"),
+			Username: getEnvOrDefault("MYSQL_USERNAME", "root"),
+			Password: getEnvOrDefault("MYSQL_PASSWORD", "root"),
+		}
+		store = NewMySQLStorage(config)
+	case "clickhouse":
+		config.ClickHouse = ClickHouseConfig{
+			Host:     getEnvOrDefault("CLICKHOUSE_HOST", "localhost"),
+			Port:     getEnvOrDefaultInt("CLICKHOUSE_PORT", 9000),
+			Database: getEnvOrDefault("CLICKHOUSE_DATABASE", "logs_test"),
+			Username: getEnvOrDefault("CLICKHOUSE_USERNAME", "default"),
+			Password: getEnvOrDefault("CLICKHOUSE_PASSWORD", ""),
+		}
+		store = NewClickHouseStorage(config)
+	default:
+		b.Fatalf("unknown backend: %s", backend)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := store.Initialize(ctx); err != nil {
+		b.Skipf("Skipping benchmark: cannot connect to %s: %v", backend, err)
+	}
+	b.Cleanup(func() { store.Close() })
+	return store
+}
+
+// benchSchema 创建包含 fields 个字段的 schema，字段类型在 string、int、float 之间轮换，第一个字段建索引
+func benchSchema(b *testing.B, store Storage, table string, fields int) *models.Schema {
+	schema := &models.Schema{Project: "bench", Table: table}
+	types := []models.FieldType{models.FieldTypeString, models.FieldTypeInt, models.FieldTypeFloat}
+	for i := 0; i < fields; i++ {
+		schema.Fields = append(schema.Fields, &models.Field{
+			Name:    fmt.Sprintf("f%d", i),
+			Type:    types[i%len(types)],
+			Indexed: i == 0,
+		})
+	}
+
+	ctx := context.Background()
+	store.DeleteSchema(ctx, schema.Project, schema.Table)
+	if err := store.CreateSchema(ctx, schema); err != nil {
+		b.Fatalf("create schema: %v", err)
+	}
+	b.Cleanup(func() { store.DeleteSchema(context.Background(), schema.Project, schema.Table) })
+	return schema
+}
+
+// benchLogs 生成 n 条日志，第一个字段在 10 个值之间轮换
+func benchLogs(schema *models.Schema, n, offset int) []*models.LogEntry {
+	now := time.Now()
+	logs := make([]*models.LogEntry, n)
+	for i := range logs {
+		seq := offset + i
+		fields := make(map[string]interface{}, len(schema.Fields))
+		for j, field := range schema.Fields {
+			switch field.Type {
+			case models.FieldTypeString:
+				fields[field.Name] = fmt.Sprintf("value-%d", (seq+j)%10)
+			case models.FieldTypeInt:
+				fields[field.Name] = int64(seq)
+			case models.FieldTypeFloat:
+				fields[field.Name] = float64(seq) / 10
+			}
+		}
+		logs[i] = &models.LogEntry{
+			Project:   schema.Project,
+			Table:     schema.Table,
+			Level:     "info",
+			Message:   fmt.Sprintf("benchmark message %d", seq),
+			Timestamp: now.Add(time.Duration(seq) * time.Millisecond),
+			Fields:    fields,
+		}
+	}
+	return logs
+}
+
+func BenchmarkBatchInsert(b *testing.B) {
+	for _, backend := range benchBackends() {
+		b.Run(backend, func(b *testing.B) {
+			store := openBenchStorage(b, backend)
+			for _, fields := range benchInts(b, "BENCH_FIELD_COUNTS", []int{5, 20}) {
+				schema := benchSchema(b, store, fmt.Sprintf("insert_f%d", fields), fields)
+				for _, size := range benchInts(b, "BENCH_BATCH_SIZES", []int{100, 1000}) {
+					b.Run(fmt.Sprintf("fields=%d/batch=%d", fields, size), func(b *testing.B) {
+						ctx := context.Background()
+						logs := benchLogs(schema, size, 0)
+						b.ReportAllocs()
+						b.ResetTimer()
+						for i := 0; i < b.N; i++ {
+							// 写入时会生成 ID，每轮清空以免主键冲突
+							for _, log := range logs {
+								log.ID = ""
+							}
+							if err := store.BatchInsertLogs(ctx, schema.Project, schema.Table, logs); err != nil {
+								b.Fatal(err)
+							}
+						}
+						b.ReportMetric(float64(b.N*size)/b.Elapsed().Seconds(), "logs/s")
+					})
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkQuery(b *testing.B) {
+	for _, backend := range benchBackends() {
+		b.Run(backend, func(b *testing.B) {
+			store := openBenchStorage(b, backend)
+			querier, ok := As[LogQuerier](store)
+			if !ok {
+				b.Skipf("%s does not support structured queries", backend)
+			}
+			rows := benchInts(b, "BENCH_QUERY_ROWS", []int{10000})[0]
+			for _, fields := range benchInts(b, "BENCH_FIELD_COUNTS", []int{5, 20}) {
+				schema := benchSchema(b, store, fmt.Sprintf("query_f%d", fields), fields)
+				ctx := context.Background()
+				for offset := 0; offset < rows; offset += 1000 {
+					if err := store.BatchInsertLogs(ctx, schema.Project, schema.Table, benchLogs(schema, min(1000, rows-offset), offset)); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				queries := []struct {
+					name  string
+					query *models.Query
+				}{
+					{"filter", &models.Query{Filter: map[string]interface{}{"f0": "value-3"}, Limit: 100}},
+					{"filter_sort", &models.Query{Filter: map[string]interface{}{"f0": "value-3"}, Sort: []string{"-timestamp"}, Limit: 100}},
+					{"scan", &models.Query{Limit: 1000}},
+				}
+				for _, tc := range queries {
+					query := tc.query
+					if err := query.Validate(schema, models.BaseColumns); err != nil {
+						b.Fatal(err)
+					}
+					b.Run(fmt.Sprintf("fields=%d/%s", fields, tc.name), func(b *testing.B) {
+						b.ReportAllocs()
+						for i := 0; i < b.N; i++ {
+							if _, err := querier.SearchLogs(ctx, schema.Project, schema.Table, query); err != nil {
+								b.Fatal(err)
+							}
+						}
+					})
+				}
+			}
+		})
+	}
+}
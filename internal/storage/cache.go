@@ -0,0 +1,475 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// CachedStorage 以 SchemaRegistry 缓存 schema 的存储包装器。GetSchema 优先读取注册表，
+// 未命中时从被包装的存储读取并填充；经由包装器的 schema 创建、修改、删除、归档、恢复与重命名
+// 在存储成功后同步到注册表并向订阅者发送事件。其他实例直接修改存储中的 schema 时缓存不会失效，
+// 多个实例共享同一数据库时需通过 cluster 广播变更并 Evict 失效的条目
+type CachedStorage struct {
+	store    Storage
+	registry *models.SchemaRegistry
+}
+
+// WithSchemaCache 包装存储，registry 为 nil 时原样返回
+func WithSchemaCache(store Storage, registry *models.SchemaRegistry) Storage {
+	if registry == nil {
+		return store
+	}
+	return &CachedStorage{store: store, registry: registry}
+}
+
+// Unwrap 返回被包装的存储
+func (c *CachedStorage) Unwrap() Storage {
+	return c.store
+}
+
+// Registry 返回缓存使用的注册表
+func (c *CachedStorage) Registry() *models.SchemaRegistry {
+	return c.registry
+}
+
+// forget 从注册表中移除 schema，尚未缓存时返回的 ErrSchemaNotFound 可以忽略
+func (c *CachedStorage) forget(project, table string) {
+	_ = c.registry.Delete(project, table)
+}
+
+// remember 将存储接受的 schema 写入注册表。注册表校验失败时移除旧版本，下次读取从存储重新加载
+func (c *CachedStorage) remember(schema *models.Schema) {
+	if err := c.registry.Put(schema); err != nil {
+		c.forget(schema.Project, schema.Table)
+	}
+}
+
+// Initialize 初始化被包装的存储
+func (c *CachedStorage) Initialize(ctx context.Context) error {
+	return c.store.Initialize(ctx)
+}
+
+// CreateSchema 创建 schema 并写入注册表
+func (c *CachedStorage) CreateSchema(ctx context.Context, schema *models.Schema) error {
+	if err := c.store.CreateSchema(ctx, schema); err != nil {
+		return err
+	}
+	c.remember(schema)
+	return nil
+}
+
+// UpdateSchema 更新 schema 并写入注册表
+func (c *CachedStorage) UpdateSchema(ctx context.Context, schema *models.Schema) error {
+	if err := c.store.UpdateSchema(ctx, schema); err != nil {
+		return err
+	}
+	c.remember(schema)
+	return nil
+}
+
+// DeleteSchema 删除 schema 并从注册表移除
+func (c *CachedStorage) DeleteSchema(ctx context.Context, project, table string) error {
+	if err := c.store.DeleteSchema(ctx, project, table); err != nil {
+		return err
+	}
+	c.forget(project, table)
+	return nil
+}
+
+// DeleteSchemaRecord 只删除 schema 记录并从注册表移除
+func (c *CachedStorage) DeleteSchemaRecord(ctx context.Context, project, table string) error {
+	deleter, ok := c.store.(SchemaRecordDeleter)
+	if !ok {
+		return errNotSupported("schema record deletion")
+	}
+	if err := deleter.DeleteSchemaRecord(ctx, project, table); err != nil {
+		return err
+	}
+	c.forget(project, table)
+	return nil
+}
+
+// GetSchema 优先从注册表读取 schema，未命中时从存储读取并填充
+func (c *CachedStorage) GetSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	if schema, err := c.registry.Get(project, table); err == nil {
+		return schema, nil
+	}
+	schema, err := c.store.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+	c.registry.Load(schema)
+	return schema, nil
+}
+
+// ListSchemas 从存储列出所有 schema，顺带填充注册表
+func (c *CachedStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error) {
+	schemas, err := c.store.ListSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, schema := range schemas {
+		c.registry.Load(schema)
+	}
+	return schemas, nil
+}
+
+// InsertLog 写入单条日志
+func (c *CachedStorage) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
+	return c.store.InsertLog(ctx, project, table, log)
+}
+
+// BatchInsertLogs 批量写入日志
+func (c *CachedStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	return c.store.BatchInsertLogs(ctx, project, table, logs)
+}
+
+// Close 关闭被包装的存储
+func (c *CachedStorage) Close() error {
+	return c.store.Close()
+}
+
+// Ping 检查被包装的存储
+func (c *CachedStorage) Ping(ctx context.Context) error {
+	return c.store.Ping(ctx)
+}
+
+// QueryLogs 查询日志
+func (c *CachedStorage) QueryLogs(ctx context.Context, project, table string, query map[string]interface{}, limit, offset int) ([]map[string]interface{}, error) {
+	querier, ok := c.store.(LogQuerier)
+	if !ok {
+		return nil, errNotSupported("log queries")
+	}
+	return querier.QueryLogs(ctx, project, table, query, limit, offset)
+}
+
+// SearchLogs 执行带字段选择与排序的日志查询
+func (c *CachedStorage) SearchLogs(ctx context.Context, project, table string, query *models.Query) ([]map[string]interface{}, error) {
+	querier, ok := c.store.(LogQuerier)
+	if !ok {
+		return nil, errNotSupported("log queries")
+	}
+	return querier.SearchLogs(ctx, project, table, query)
+}
+
+// QueryRange 按时间范围读取日志
+func (c *CachedStorage) QueryRange(ctx context.Context, project, table string, from, to time.Time, limit, offset int) ([]map[string]interface{}, error) {
+	querier, ok := c.store.(RangeQuerier)
+	if !ok {
+		return nil, errNotSupported("range queries")
+	}
+	return querier.QueryRange(ctx, project, table, from, to, limit, offset)
+}
+
+// QueryAggregate 查询持续聚合结果
+func (c *CachedStorage) QueryAggregate(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	querier, ok := c.store.(ContinuousQuerier)
+	if !ok {
+		return nil, errNotSupported("continuous aggregates")
+	}
+	return querier.QueryAggregate(ctx, project, table, name, from, to)
+}
+
+// SaveQuery 保存查询
+func (c *CachedStorage) SaveQuery(ctx context.Context, query *models.SavedQuery) error {
+	store, ok := c.store.(SavedQueryStore)
+	if !ok {
+		return errNotSupported("saved queries")
+	}
+	return store.SaveQuery(ctx, query)
+}
+
+// GetSavedQuery 获取保存的查询
+func (c *CachedStorage) GetSavedQuery(ctx context.Context, owner, name string) (*models.SavedQuery, error) {
+	store, ok := c.store.(SavedQueryStore)
+	if !ok {
+		return nil, errNotSupported("saved queries")
+	}
+	return store.GetSavedQuery(ctx, owner, name)
+}
+
+// ListSavedQueries 列出保存的查询
+func (c *CachedStorage) ListSavedQueries(ctx context.Context, owner string) ([]*models.SavedQuery, error) {
+	store, ok := c.store.(SavedQueryStore)
+	if !ok {
+		return nil, errNotSupported("saved queries")
+	}
+	return store.ListSavedQueries(ctx, owner)
+}
+
+// DeleteSavedQuery 删除保存的查询
+func (c *CachedStorage) DeleteSavedQuery(ctx context.Context, owner, name string) error {
+	store, ok := c.store.(SavedQueryStore)
+	if !ok {
+		return errNotSupported("saved queries")
+	}
+	return store.DeleteSavedQuery(ctx, owner, name)
+}
+
+// SaveReport 保存报表
+func (c *CachedStorage) SaveReport(ctx context.Context, report *models.Report) error {
+	store, ok := c.store.(ReportStore)
+	if !ok {
+		return errNotSupported("reports")
+	}
+	return store.SaveReport(ctx, report)
+}
+
+// GetReport 获取报表
+func (c *CachedStorage) GetReport(ctx context.Context, owner, name string) (*models.Report, error) {
+	store, ok := c.store.(ReportStore)
+	if !ok {
+		return nil, errNotSupported("reports")
+	}
+	return store.GetReport(ctx, owner, name)
+}
+
+// ListReports 列出报表
+func (c *CachedStorage) ListReports(ctx context.Context, owner string) ([]*models.Report, error) {
+	store, ok := c.store.(ReportStore)
+	if !ok {
+		return nil, errNotSupported("reports")
+	}
+	return store.ListReports(ctx, owner)
+}
+
+// DeleteReport 删除报表
+func (c *CachedStorage) DeleteReport(ctx context.Context, owner, name string) error {
+	store, ok := c.store.(ReportStore)
+	if !ok {
+		return errNotSupported("reports")
+	}
+	return store.DeleteReport(ctx, owner, name)
+}
+
+// RecordIssues 记录问题
+func (c *CachedStorage) RecordIssues(ctx context.Context, issues []*models.Issue) error {
+	store, ok := c.store.(IssueStore)
+	if !ok {
+		return errNotSupported("issues")
+	}
+	return store.RecordIssues(ctx, issues)
+}
+
+// GetIssue 获取问题
+func (c *CachedStorage) GetIssue(ctx context.Context, project, table, fingerprint string) (*models.Issue, error) {
+	store, ok := c.store.(IssueStore)
+	if !ok {
+		return nil, errNotSupported("issues")
+	}
+	return store.GetIssue(ctx, project, table, fingerprint)
+}
+
+// ListIssues 列出问题
+func (c *CachedStorage) ListIssues(ctx context.Context, filter *models.IssueFilter) ([]*models.Issue, error) {
+	store, ok := c.store.(IssueStore)
+	if !ok {
+		return nil, errNotSupported("issues")
+	}
+	return store.ListIssues(ctx, filter)
+}
+
+// SetIssueStatus 更新问题状态
+func (c *CachedStorage) SetIssueStatus(ctx context.Context, project, table, fingerprint string, status models.IssueStatus) error {
+	store, ok := c.store.(IssueStore)
+	if !ok {
+		return errNotSupported("issues")
+	}
+	return store.SetIssueStatus(ctx, project, table, fingerprint, status)
+}
+
+// DeleteIssue 删除问题
+func (c *CachedStorage) DeleteIssue(ctx context.Context, project, table, fingerprint string) error {
+	store, ok := c.store.(IssueStore)
+	if !ok {
+		return errNotSupported("issues")
+	}
+	return store.DeleteIssue(ctx, project, table, fingerprint)
+}
+
+// ArchiveSchema 归档 schema 并从注册表移除
+func (c *CachedStorage) ArchiveSchema(ctx context.Context, project, table string) (*models.ArchivedSchema, error) {
+	archiver, ok := c.store.(SchemaArchiver)
+	if !ok {
+		return nil, errNotSupported("schema archiving")
+	}
+	archived, err := archiver.ArchiveSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+	c.forget(project, table)
+	return archived, nil
+}
+
+// ListArchivedSchemas 列出归档
+func (c *CachedStorage) ListArchivedSchemas(ctx context.Context) ([]*models.ArchivedSchema, error) {
+	archiver, ok := c.store.(SchemaArchiver)
+	if !ok {
+		return nil, errNotSupported("schema archiving")
+	}
+	return archiver.ListArchivedSchemas(ctx)
+}
+
+// RestoreSchema 恢复归档的 schema 并写入注册表
+func (c *CachedStorage) RestoreSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	archiver, ok := c.store.(SchemaArchiver)
+	if !ok {
+		return nil, errNotSupported("schema archiving")
+	}
+	schema, err := archiver.RestoreSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+	c.remember(schema)
+	return schema, nil
+}
+
+// PurgeArchivedSchemas 永久删除过期的归档
+func (c *CachedStorage) PurgeArchivedSchemas(ctx context.Context, before time.Time) ([]*models.ArchivedSchema, error) {
+	archiver, ok := c.store.(SchemaArchiver)
+	if !ok {
+		return nil, errNotSupported("schema archiving")
+	}
+	return archiver.PurgeArchivedSchemas(ctx, before)
+}
+
+// RenameSchema 重命名 schema，注册表中移除旧名称并写入新名称
+func (c *CachedStorage) RenameSchema(ctx context.Context, project, table, newProject, newTable string) (*models.Schema, error) {
+	renamer, ok := c.store.(SchemaRenamer)
+	if !ok {
+		return nil, errNotSupported("schema renaming")
+	}
+	schema, err := renamer.RenameSchema(ctx, project, table, newProject, newTable)
+	if err != nil {
+		return nil, err
+	}
+	c.forget(project, table)
+	c.remember(schema)
+	return schema, nil
+}
+
+// CountMatching 统计匹配过滤条件的日志数
+func (c *CachedStorage) CountMatching(ctx context.Context, project, table string, filter *models.LogFilter) (int64, error) {
+	mutator, ok := c.store.(LogMutator)
+	if !ok {
+		return 0, errNotSupported("log mutations")
+	}
+	return mutator.CountMatching(ctx, project, table, filter)
+}
+
+// DeleteLogs 删除匹配过滤条件的日志
+func (c *CachedStorage) DeleteLogs(ctx context.Context, project, table string, filter *models.LogFilter, limit int64) (int64, error) {
+	mutator, ok := c.store.(LogMutator)
+	if !ok {
+		return 0, errNotSupported("log mutations")
+	}
+	return mutator.DeleteLogs(ctx, project, table, filter, limit)
+}
+
+// UpdateLogs 修改匹配过滤条件的日志字段
+func (c *CachedStorage) UpdateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	mutator, ok := c.store.(LogMutator)
+	if !ok {
+		return 0, errNotSupported("log mutations")
+	}
+	return mutator.UpdateLogs(ctx, project, table, filter, set, limit)
+}
+
+// RollupLogs 汇总并删除早于 before 的日志
+func (c *CachedStorage) RollupLogs(ctx context.Context, project, table string, before time.Time) (int64, error) {
+	roller, ok := c.store.(Roller)
+	if !ok {
+		return 0, errNotSupported("rollups")
+	}
+	return roller.RollupLogs(ctx, project, table, before)
+}
+
+// QueryRollup 查询 rollup 汇总结果
+func (c *CachedStorage) QueryRollup(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	roller, ok := c.store.(Roller)
+	if !ok {
+		return nil, errNotSupported("rollups")
+	}
+	return roller.QueryRollup(ctx, project, table, name, from, to)
+}
+
+// TableSizes 返回各日志表的大小
+func (c *CachedStorage) TableSizes(ctx context.Context) ([]*models.TableSize, error) {
+	reporter, ok := c.store.(SizeReporter)
+	if !ok {
+		return nil, errNotSupported("table sizes")
+	}
+	return reporter.TableSizes(ctx)
+}
+
+// ExplainQuery 返回日志查询的执行计划
+func (c *CachedStorage) ExplainQuery(ctx context.Context, project, table string, query *models.Query, analyze bool) (*models.QueryPlan, error) {
+	explainer, ok := c.store.(QueryExplainer)
+	if !ok {
+		return nil, errNotSupported("query plans")
+	}
+	return explainer.ExplainQuery(ctx, project, table, query, analyze)
+}
+
+// FieldValues 统计字段出现最多的取值
+func (c *CachedStorage) FieldValues(ctx context.Context, project, table, field string, query *models.Query) (*models.FieldValues, error) {
+	querier, ok := c.store.(FieldValuesQuerier)
+	if !ok {
+		return nil, errNotSupported("field values")
+	}
+	return querier.FieldValues(ctx, project, table, field, query)
+}
+
+// BaseColumns 返回被包装存储的基础列
+func (c *CachedStorage) BaseColumns(schema *models.Schema) []string {
+	return BaseColumns(c.store, schema)
+}
+
+// RecordSlowQuery 记录慢查询
+func (c *CachedStorage) RecordSlowQuery(ctx context.Context, query *models.SlowQuery) error {
+	store, ok := c.store.(SlowQueryStore)
+	if !ok {
+		return errNotSupported("slow queries")
+	}
+	return store.RecordSlowQuery(ctx, query)
+}
+
+// ListSlowQueries 列出慢查询
+func (c *CachedStorage) ListSlowQueries(ctx context.Context, filter *models.SlowQueryFilter) ([]*models.SlowQuery, error) {
+	store, ok := c.store.(SlowQueryStore)
+	if !ok {
+		return nil, errNotSupported("slow queries")
+	}
+	return store.ListSlowQueries(ctx, filter)
+}
+
+// PurgeSlowQueries 删除过期的慢查询记录
+func (c *CachedStorage) PurgeSlowQueries(ctx context.Context, before time.Time) (int64, error) {
+	store, ok := c.store.(SlowQueryStore)
+	if !ok {
+		return 0, errNotSupported("slow queries")
+	}
+	return store.PurgeSlowQueries(ctx, before)
+}
+
+var (
+	_ Storage             = (*CachedStorage)(nil)
+	_ SchemaRecordDeleter = (*CachedStorage)(nil)
+	_ LogQuerier          = (*CachedStorage)(nil)
+	_ RangeQuerier        = (*CachedStorage)(nil)
+	_ ContinuousQuerier   = (*CachedStorage)(nil)
+	_ SavedQueryStore     = (*CachedStorage)(nil)
+	_ ReportStore         = (*CachedStorage)(nil)
+	_ IssueStore          = (*CachedStorage)(nil)
+	_ SchemaArchiver      = (*CachedStorage)(nil)
+	_ SchemaRenamer       = (*CachedStorage)(nil)
+	_ LogMutator          = (*CachedStorage)(nil)
+	_ Roller              = (*CachedStorage)(nil)
+	_ SizeReporter        = (*CachedStorage)(nil)
+	_ QueryExplainer      = (*CachedStorage)(nil)
+	_ SlowQueryStore      = (*CachedStorage)(nil)
+	_ FieldValuesQuerier  = (*CachedStorage)(nil)
+	_ BaseColumnLister    = (*CachedStorage)(nil)
+)
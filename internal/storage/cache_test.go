@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// countingStorage 统计 GetSchema 调用次数的存储
+type countingStorage struct {
+	*SQLiteStorage
+	gets int
+}
+
+func (c *countingStorage) GetSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	c.gets++
+	return c.SQLiteStorage.GetSchema(ctx, project, table)
+}
+
+func TestCachedStorage(t *testing.T) {
+	ctx := context.Background()
+	sqlite := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, sqlite.Initialize(ctx))
+	defer sqlite.Close()
+	backend := &countingStorage{SQLiteStorage: sqlite}
+	schema := &models.Schema{Project: "app", Table: "events", Fields: []*models.Field{{Name: "message", Type: models.FieldTypeString}}}
+	require.NoError(t, backend.CreateSchema(ctx, schema))
+
+	registry := models.NewSchemaRegistry()
+	events, cancel := registry.Subscribe(10)
+	defer cancel()
+	store := WithSchemaCache(backend, registry)
+	assert.Same(t, Storage(backend), WithSchemaCache(backend, nil))
+
+	// 未命中时从存储读取，之后命中注册表
+	for i := 0; i < 3; i++ {
+		got, err := store.GetSchema(ctx, "app", "events")
+		require.NoError(t, err)
+		assert.Len(t, got.Fields, 1)
+	}
+	assert.Equal(t, 1, backend.gets)
+	assert.Empty(t, events, "cache fills are not changes")
+
+	updated := schema.Clone()
+	updated.Fields = append(updated.Fields, &models.Field{Name: "status", Type: models.FieldTypeInt})
+	require.NoError(t, store.UpdateSchema(ctx, updated))
+	got, err := store.GetSchema(ctx, "app", "events")
+	require.NoError(t, err)
+	assert.Len(t, got.Fields, 2)
+	assert.Equal(t, 1, backend.gets)
+	assert.Equal(t, models.SchemaUpdated, (<-events).Type)
+
+	renamer, ok := As[SchemaRenamer](store)
+	require.True(t, ok)
+	_, err = renamer.RenameSchema(ctx, "app", "events", "app", "requests")
+	require.NoError(t, err)
+	assert.Equal(t, models.SchemaDeleted, (<-events).Type)
+	event := <-events
+	assert.Equal(t, models.SchemaCreated, event.Type)
+	assert.Equal(t, "requests", event.Table)
+	_, err = store.GetSchema(ctx, "app", "events")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+
+	require.NoError(t, store.DeleteSchema(ctx, "app", "requests"))
+	assert.Equal(t, models.SchemaDeleted, (<-events).Type)
+	assert.Empty(t, registry.List())
+
+	_, ok = As[ContinuousQuerier](store)
+	assert.True(t, ok)
+	_, ok = As[RangeQuerier](store)
+	assert.False(t, ok, "capabilities still follow the wrapped storage")
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,6 +18,7 @@ import (
 type ClickHouseStorage struct {
 	db     *sql.DB
 	config Config
+	ids    models.IDGenerator
 }
 
 // NewClickHouseStorage 创建 ClickHouse 存储实例
@@ -27,6 +30,12 @@ func NewClickHouseStorage(config Config) *ClickHouseStorage {
 
 // Initialize 初始化 ClickHouse 连接和表结构
 func (s *ClickHouseStorage) Initialize(ctx context.Context) error {
+	ids, err := newIDGenerator(s.config)
+	if err != nil {
+		return err
+	}
+	s.ids = ids
+
 	// 构建连接字符串
 	connStr := fmt.Sprintf("clickhouse://%s:%s@%s:%d/%s?dial_timeout=10s&read_timeout=20s",
 		s.config.ClickHouse.Username,
@@ -35,11 +44,16 @@ func (s *ClickHouseStorage) Initialize(ctx context.Context) error {
 		s.config.ClickHouse.Port,
 		s.config.ClickHouse.Database,
 	)
+	settings, err := clickhouseSettings(s.config.ClickHouse)
+	if err != nil {
+		return err
+	}
+	connStr += settings
 
 	// 连接数据库
 	db, err := sql.Open("clickhouse", connStr)
 	if err != nil {
-		return fmt.Errorf("连接数据库失败: %w", err)
+		return fmt.Errorf("连接数据库失败: %w", unavailable(err))
 	}
 	s.db = db
 
@@ -48,24 +62,47 @@ func (s *ClickHouseStorage) Initialize(ctx context.Context) error {
 		return err
 	}
 
+	// 将已有日志表的索引字段从物化视图迁移到跳数索引
+	schemas, err := s.ListSchemas(ctx)
+	if err != nil {
+		return err
+	}
+	for _, schema := range schemas {
+		if err := s.syncSkippingIndexes(ctx, schema); err != nil {
+			return fmt.Errorf("迁移 %s_%s 的索引失败: %w", schema.Project, schema.Table, err)
+		}
+	}
+
 	return nil
 }
 
 // createSchemaTable 创建 schema 表
 func (s *ClickHouseStorage) createSchemaTable(ctx context.Context) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS schemas (
+	// 集群模式下 schema 表不分片，所有节点都是同一张复制表的副本
+	engine := "ReplacingMergeTree(updated_at)"
+	if s.config.ClickHouse.Cluster != "" {
+		engine = "ReplicatedReplacingMergeTree('/clickhouse/tables/{database}/schemas', '{replica}', updated_at)"
+	}
+	onCluster := clickhouseOnCluster(s.config.ClickHouse.Cluster)
+
+	query := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS schemas%s (
 		project String,
 		table_name String,
 		description String,
 		fields String,
 		created_at DateTime64(3),
-		updated_at DateTime64(3)
-	) ENGINE = ReplacingMergeTree(updated_at)
-	ORDER BY (project, table_name)`
+		updated_at DateTime64(3),
+		options String
+	) ENGINE = %s
+	ORDER BY (project, table_name)`, onCluster, engine)
 
 	if _, err := s.db.ExecContext(ctx, query); err != nil {
-		return fmt.Errorf("创建 schema 表失败: %w", err)
+		return fmt.Errorf("创建 schema 表失败: %w", unavailable(err))
+	}
+
+	if _, err := s.db.ExecContext(ctx, `ALTER TABLE schemas`+onCluster+` ADD COLUMN IF NOT EXISTS options String`); err != nil {
+		return fmt.Errorf("升级 schema 表失败: %w", err)
 	}
 
 	return nil
@@ -73,6 +110,9 @@ func (s *ClickHouseStorage) createSchemaTable(ctx context.Context) error {
 
 // CreateSchema 创建或更新 schema
 func (s *ClickHouseStorage) CreateSchema(ctx context.Context, schema *models.Schema) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	// 将字段转换为 JSON
 	fieldsJSON, err := json.Marshal(schema.Fields)
 	if err != nil {
@@ -82,26 +122,42 @@ func (s *ClickHouseStorage) CreateSchema(ctx context.Context, schema *models.Sch
 	// 将字节数组转换为字符串
 	fieldsJSONString := string(fieldsJSON)
 
+	optionsJSON, err := marshalSchemaOptions(schema)
+	if err != nil {
+		return err
+	}
+
+	old, err := s.GetSchema(ctx, schema.Project, schema.Table)
+	if err != nil && !errors.Is(err, models.ErrSchemaNotFound) {
+		return err
+	}
+
 	// 创建日志表
 	if err := s.createLogTable(ctx, schema); err != nil {
 		return err
 	}
 
+	// 持续聚合物化视图
+	if err := s.syncAggregates(ctx, old, schema); err != nil {
+		return err
+	}
+
 	// 保存 schema
 	query := `
-	INSERT INTO schemas (project, table_name, description, fields, created_at, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?)`
+	INSERT INTO schemas (project, table_name, description, fields, options, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
 
 	_, err = s.db.ExecContext(ctx, query,
 		schema.Project,
 		schema.Table,
 		schema.Description,
 		fieldsJSONString,
+		optionsJSON,
 		schema.CreatedAt,
 		schema.UpdatedAt,
 	)
 	if err != nil {
-		return fmt.Errorf("保存 schema 失败: %w", err)
+		return fmt.Errorf("保存 schema 失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -109,8 +165,11 @@ func (s *ClickHouseStorage) CreateSchema(ctx context.Context, schema *models.Sch
 
 // GetSchema 获取指定的 schema
 func (s *ClickHouseStorage) GetSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	query := `
-	SELECT description, fields, created_at, updated_at
+	SELECT description, fields, options, created_at, updated_at
 	FROM schemas
 	WHERE project = ? AND table_name = ?
 	ORDER BY updated_at DESC
@@ -119,6 +178,7 @@ func (s *ClickHouseStorage) GetSchema(ctx context.Context, project, table string
 	var (
 		description string
 		fieldsJSON  []byte
+		optionsJSON []byte
 		createdAt   time.Time
 		updatedAt   time.Time
 	)
@@ -126,17 +186,16 @@ func (s *ClickHouseStorage) GetSchema(ctx context.Context, project, table string
 	err := s.db.QueryRowContext(ctx, query, project, table).Scan(
 		&description,
 		&fieldsJSON,
+		&optionsJSON,
 		&createdAt,
 		&updatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("schema not found")
+		return nil, models.ErrSchemaNotFound
 	}
 
-	fmt.Println("fieldsJSON string:", string(fieldsJSON)) // 会显示为真实的 JSON 字符串
-
 	if err != nil {
-		return nil, fmt.Errorf("查询 schema 失败: %w", err)
+		return nil, fmt.Errorf("查询 schema 失败: %w", unavailable(err))
 	}
 
 	var fields []models.Field
@@ -150,71 +209,340 @@ func (s *ClickHouseStorage) GetSchema(ctx context.Context, project, table string
 		fieldPtrs[i] = &fields[i]
 	}
 
-	return &models.Schema{
+	schema := &models.Schema{
 		Project:     project,
 		Table:       table,
 		Description: description,
 		Fields:      fieldPtrs,
 		CreatedAt:   createdAt,
 		UpdatedAt:   updatedAt,
-	}, nil
+	}
+	if err := unmarshalSchemaOptions(optionsJSON, schema); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
 }
 
+// clickhouseDateTimeType datetime 字段与 ingest_time 的列类型，timestamp 列的精度由 schema 决定。
+// 显式指定 UTC 时区，时间函数与文本格式不随服务器时区变化
+const clickhouseDateTimeType = "DateTime64(3, 'UTC')"
+
+// clickhouseIngestTimeType ingest_time 列的类型，缺失时以写入时间填充
+const clickhouseIngestTimeType = clickhouseDateTimeType + " DEFAULT now64(3)"
+
 // createLogTable 创建日志表
 func (s *ClickHouseStorage) createLogTable(ctx context.Context, schema *models.Schema) error {
-	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", schema.Project, schema.Table)
+	cluster := s.config.ClickHouse.Cluster
+	onCluster := clickhouseOnCluster(cluster)
+
+	// 集群模式下数据写入各分片的本地表，查询与写入通过同名的 Distributed 表完成
+	localTable := clickhouseLocalTable(schema.Project, schema.Table, cluster)
+	tables := []string{localTable}
+	if cluster != "" {
+		tables = append(tables, logTable("clickhouse", schema.Project, schema.Table))
+	}
+
+	// 创建表
+	for _, query := range clickhouseCreateTable(schema, s.getClickHouseType, cluster) {
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("创建日志表失败: %w", err)
+		}
+	}
+
+	// 为已存在的表补充新增字段
+	for _, field := range schema.Fields {
+		column := clickhouseColumn(schema, field.Name, columnType("clickhouse", field, s.getClickHouseType)+columnConstraints("clickhouse", field, true))
+		for _, tableName := range tables {
+			alterQuery := fmt.Sprintf("ALTER TABLE %s%s ADD COLUMN IF NOT EXISTS %s", tableName, onCluster, column)
+			if _, err := s.db.ExecContext(ctx, alterQuery); err != nil {
+				return fmt.Errorf("添加字段失败: %w", err)
+			}
+		}
+	}
+	if schema.StoresTags() {
+		var alterQueries []string
+		for _, tableName := range tables {
+			alterQueries = append(alterQueries, fmt.Sprintf("ALTER TABLE %s%s ADD COLUMN IF NOT EXISTS tags Map(String, String)", tableName, onCluster))
+		}
+		alterQueries = append(alterQueries,
+			fmt.Sprintf("ALTER TABLE %s%s ADD INDEX IF NOT EXISTS idx_tags_keys mapKeys(tags) TYPE bloom_filter GRANULARITY 1", localTable, onCluster),
+			fmt.Sprintf("ALTER TABLE %s%s ADD INDEX IF NOT EXISTS idx_tags_values mapValues(tags) TYPE bloom_filter GRANULARITY 1", localTable, onCluster),
+		)
+		for _, alterQuery := range alterQueries {
+			if _, err := s.db.ExecContext(ctx, alterQuery); err != nil {
+				return fmt.Errorf("添加 tags 字段失败: %w", err)
+			}
+		}
+	}
+	if schema.StoresIngestTime() {
+		column := clickhouseColumn(schema, models.IngestTimeColumn, clickhouseIngestTimeType)
+		for _, tableName := range tables {
+			alterQuery := fmt.Sprintf("ALTER TABLE %s%s ADD COLUMN IF NOT EXISTS %s", tableName, onCluster, column)
+			if _, err := s.db.ExecContext(ctx, alterQuery); err != nil {
+				return fmt.Errorf("添加 ingest_time 字段失败: %w", err)
+			}
+		}
+	}
 
+	// 已存在的表同步列编码与 TTL，排序键只能在建表时指定
+	for _, alterQuery := range clickhouseAlterOptions(schema, localTable+onCluster) {
+		if _, err := s.db.ExecContext(ctx, alterQuery); err != nil {
+			return fmt.Errorf("更新表参数失败: %w", err)
+		}
+	}
+
+	// 为索引字段维护跳数索引，并清理旧版本的全量物化视图
+	if err := s.syncSkippingIndexes(ctx, schema); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// syncSkippingIndexes 同步索引字段的跳数索引：为新增的索引字段添加索引并对已有数据物化，
+// 删除取消索引的字段的索引，并删除旧版本为索引字段创建的 <table>_<field>_mv 物化视图。
+// 旧视图复制了整张日志表，迁移后查询直接由日志表上的跳数索引加速
+func (s *ClickHouseStorage) syncSkippingIndexes(ctx context.Context, schema *models.Schema) error {
+	cluster := s.config.ClickHouse.Cluster
+	onCluster := clickhouseOnCluster(cluster)
+	localTable := clickhouseLocalTable(schema.Project, schema.Table, cluster)
+	localName := logTableName(schema.Project, schema.Table)
+	if cluster != "" {
+		localName += "_local"
+	}
+
+	have, err := queryNames(ctx, s.db, `SELECT name FROM system.data_skipping_indices WHERE database = currentDatabase() AND table = ?`, localName)
+	if err != nil {
+		return fmt.Errorf("查询跳数索引失败: %w", unavailable(err))
+	}
+
+	want := make(map[string]bool)
+	for _, field := range schema.Fields {
+		if !field.IndexedOn("clickhouse") {
+			continue
+		}
+		name := clickhouseIndexName(field.Name)
+		want[name] = true
+		if have[name] {
+			continue
+		}
+		queries := []string{
+			fmt.Sprintf("ALTER TABLE %s%s ADD %s", localTable, onCluster, clickhouseSkippingIndex(field)),
+			// 新增索引只对之后写入的数据生效，已有数据需要物化
+			fmt.Sprintf("ALTER TABLE %s%s MATERIALIZE INDEX %s", localTable, onCluster, quoteIdent("clickhouse", name)),
+		}
+		for _, query := range queries {
+			if _, err := s.db.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("创建跳数索引失败: %w", err)
+			}
+		}
+	}
+	for name := range have {
+		// idx_tags_* 由 tags 列维护
+		if want[name] || !strings.HasPrefix(name, "idx_") || strings.HasPrefix(name, "idx_tags_") {
+			continue
+		}
+		query := fmt.Sprintf("ALTER TABLE %s%s DROP INDEX IF EXISTS %s", localTable, onCluster, quoteIdent("clickhouse", name))
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("删除跳数索引失败: %w", err)
+		}
+	}
+
+	views, err := queryNames(ctx, s.db, `SELECT name FROM system.tables
+	WHERE database = currentDatabase() AND engine = 'MaterializedView' AND startsWith(name, ?) AND endsWith(name, '_mv')`,
+		logTableName(schema.Project, schema.Table)+"_")
+	if err != nil {
+		return fmt.Errorf("查询物化视图失败: %w", unavailable(err))
+	}
+	for view := range views {
+		field := strings.TrimSuffix(strings.TrimPrefix(view, logTableName(schema.Project, schema.Table)+"_"), "_mv")
+		if schema.GetField(field) == nil {
+			// 不属于该表索引字段的视图（如其他表名前缀相同的表）不做处理
+			continue
+		}
+		query := fmt.Sprintf("DROP VIEW IF EXISTS %s%s", quoteIdent("clickhouse", view), onCluster)
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("删除物化视图失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// clickhouseIndexName 返回索引字段的跳数索引名称
+func clickhouseIndexName(field string) string {
+	return "idx_" + field
+}
+
+// clickhouseSkippingIndex 返回索引字段的跳数索引定义：字符串类字段使用 bloom_filter
+// 加速等值与 IN 查询，布尔字段使用 set，数值、时间与 IP 字段使用 minmax 加速范围查询
+func clickhouseSkippingIndex(field *models.Field) string {
+	var typ string
+	switch field.Type {
+	case models.FieldTypeBool:
+		typ = "set(2)"
+	case models.FieldTypeInt, models.FieldTypeFloat, models.FieldTypeDateTime, models.FieldTypeDuration, models.FieldTypeIP:
+		typ = "minmax"
+	default:
+		typ = "bloom_filter"
+	}
+	return fmt.Sprintf("INDEX %s %s TYPE %s GRANULARITY 1",
+		quoteIdent("clickhouse", clickhouseIndexName(field.Name)), quoteIdent("clickhouse", field.Name), typ)
+}
+
+// clickhouseOnCluster 返回集群 DDL 的 ON CLUSTER 子句，未配置集群时返回空字符串
+func clickhouseOnCluster(cluster string) string {
+	if cluster == "" {
+		return ""
+	}
+	return " ON CLUSTER " + quoteIdent("clickhouse", cluster)
+}
+
+// clickhouseLocalTable 返回实际存储数据的表：单节点为日志表本身，集群模式下为各分片的 <table>_local
+func clickhouseLocalTable(project, table, cluster string) string {
+	if cluster == "" {
+		return logTable("clickhouse", project, table)
+	}
+	return quoteIdent("clickhouse", logTableName(project, table)+"_local")
+}
+
+// clickhouseCreateTable 生成日志表的建表语句，排序键、TTL 与列编码来自 schema 的 clickhouse 配置。
+// 配置集群时返回本地复制表与 Distributed 表两条语句
+func clickhouseCreateTable(schema *models.Schema, scalar func(models.FieldType) string, cluster string) []string {
 	// 构建字段定义
 	columns := []string{
-		"id String",
-		"project String",
-		"table_name String",
-		"timestamp DateTime64(3)",
+		clickhouseColumn(schema, "id", "String"),
+		clickhouseColumn(schema, "project", "String"),
+		clickhouseColumn(schema, "table_name", "String"),
+		clickhouseColumn(schema, "timestamp", fmt.Sprintf("DateTime64(%d, 'UTC')", timestampDigits("clickhouse", schema))),
+	}
+
+	// 内置 tags 列保存标签，键和值分别建立 bloom_filter 跳数索引
+	if schema.StoresTags() {
+		columns = append(columns,
+			models.TagsColumn+" Map(String, String)",
+			"INDEX idx_tags_keys mapKeys(tags) TYPE bloom_filter GRANULARITY 1",
+			"INDEX idx_tags_values mapValues(tags) TYPE bloom_filter GRANULARITY 1",
+		)
+	}
+	if schema.StoresIngestTime() {
+		columns = append(columns, clickhouseColumn(schema, models.IngestTimeColumn, clickhouseIngestTimeType))
 	}
 
 	// 添加自定义字段
 	for _, field := range schema.Fields {
-		colType := s.getClickHouseType(field.Type)
-		colDef := fmt.Sprintf("%s %s", field.Name, colType)
-		columns = append(columns, colDef)
+		colType := columnType("clickhouse", field, scalar)
+		columns = append(columns, clickhouseColumn(schema, field.Name, colType+columnConstraints("clickhouse", field, false)))
+	}
+	for _, field := range schema.Fields {
+		if field.IndexedOn("clickhouse") {
+			columns = append(columns, clickhouseSkippingIndex(field))
+		}
 	}
 
-	// 创建表
+	orderBy := "timestamp, id"
+	if schema.ClickHouse != nil && len(schema.ClickHouse.OrderBy) > 0 {
+		orderBy = quoteIdents("clickhouse", schema.ClickHouse.OrderBy)
+	}
+	engine := "MergeTree()"
+	if cluster != "" {
+		// 使用服务端 default_replica_path 配置的复制路径
+		engine = "ReplicatedMergeTree()"
+	}
+
+	localTable := clickhouseLocalTable(schema.Project, schema.Table, cluster)
+	onCluster := clickhouseOnCluster(cluster)
 	query := fmt.Sprintf(`
-	CREATE TABLE IF NOT EXISTS %s (
+	CREATE TABLE IF NOT EXISTS %s%s (
 		%s
-	) ENGINE = MergeTree()
-	ORDER BY (timestamp, id)
+	) ENGINE = %s
+	ORDER BY (%s)
 	PARTITION BY toYYYYMM(timestamp)`,
-		tableName,
+		localTable,
+		onCluster,
 		strings.Join(columns, ",\n"),
+		engine,
+		orderBy,
 	)
+	if ttl := clickhouseTTL(schema); ttl != "" {
+		query += "\n\tTTL " + ttl
+	}
+	if cluster == "" {
+		return []string{query}
+	}
 
-	if _, err := s.db.ExecContext(ctx, query); err != nil {
-		return fmt.Errorf("创建日志表失败: %w", err)
+	// 写入按 rand() 分散到各分片
+	distributed := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s%s AS %s ENGINE = Distributed(%s, currentDatabase(), %s, rand())",
+		logTable("clickhouse", schema.Project, schema.Table), onCluster, localTable,
+		quoteLiteral("clickhouse", cluster), quoteLiteral("clickhouse", logTableName(schema.Project, schema.Table)+"_local"))
+	return []string{query, distributed}
+}
+
+// clickhouseSettings 将写入相关配置转换为连接串中的会话设置
+func clickhouseSettings(config ClickHouseConfig) (string, error) {
+	if config.InsertQuorum < 0 {
+		return "", fmt.Errorf("无效的 insert_quorum: %d", config.InsertQuorum)
+	}
+	var settings string
+	if config.AsyncInsert {
+		settings += "&async_insert=1"
+		if config.WaitForAsyncInsert != nil && !*config.WaitForAsyncInsert {
+			settings += "&wait_for_async_insert=0"
+		} else {
+			settings += "&wait_for_async_insert=1"
+		}
+	}
+	if config.InsertQuorum > 0 {
+		settings += fmt.Sprintf("&insert_quorum=%d", config.InsertQuorum)
 	}
+	return settings, nil
+}
 
-	// 为索引字段创建物化视图
-	for _, field := range schema.Fields {
-		if field.Indexed {
-			viewName := fmt.Sprintf("%s_%s_mv", tableName, field.Name)
-			viewQuery := fmt.Sprintf(`
-			CREATE MATERIALIZED VIEW IF NOT EXISTS %s
-			ENGINE = MergeTree()
-			ORDER BY (%s, timestamp)
-			PARTITION BY toYYYYMM(timestamp)
-			AS SELECT *
-			FROM %s`,
-				viewName, field.Name, tableName,
-			)
-			if _, err := s.db.ExecContext(ctx, viewQuery); err != nil {
-				return fmt.Errorf("创建物化视图失败: %w", err)
-			}
+// clickhouseColumn 返回列定义，definition 为类型及 DEFAULT 子句，配置了编码时追加 CODEC 子句
+func clickhouseColumn(schema *models.Schema, name, definition string) string {
+	column := quoteIdent("clickhouse", name) + " " + definition
+	if schema.ClickHouse != nil {
+		if codec := schema.ClickHouse.Codecs[name]; codec != "" {
+			column += " CODEC(" + codec + ")"
 		}
 	}
+	return column
+}
 
-	return nil
+// clickhouseTTL 返回表级 TTL 表达式，未配置时返回空字符串。
+// 时间列为 DateTime64，转换为 DateTime 以兼容旧版本
+func clickhouseTTL(schema *models.Schema) string {
+	if schema.ClickHouse == nil || schema.ClickHouse.TTL == "" {
+		return ""
+	}
+	ttl, err := models.ParseClickHouseTTL(schema.ClickHouse.TTL)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("toDateTime(%s) + INTERVAL %d %s", quoteIdent("clickhouse", ttl.Column), ttl.Interval, ttl.Unit)
+}
+
+// clickhouseAlterOptions 返回将列编码与 TTL 应用到已存在表的语句
+func clickhouseAlterOptions(schema *models.Schema, tableName string) []string {
+	if schema.ClickHouse == nil {
+		return nil
+	}
+	names := make([]string, 0, len(schema.ClickHouse.Codecs))
+	for name := range schema.ClickHouse.Codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queries := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		queries = append(queries, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s CODEC(%s)",
+			tableName, quoteIdent("clickhouse", name), schema.ClickHouse.Codecs[name]))
+	}
+	if ttl := clickhouseTTL(schema); ttl != "" {
+		queries = append(queries, fmt.Sprintf("ALTER TABLE %s MODIFY TTL %s", tableName, ttl))
+	}
+	return queries
 }
 
 // getClickHouseType 获取 ClickHouse 字段类型
@@ -229,11 +557,13 @@ func (s *ClickHouseStorage) getClickHouseType(fieldType models.FieldType) string
 	case models.FieldTypeBool:
 		return "UInt8"
 	case models.FieldTypeDateTime:
-		return "DateTime64(3)"
+		return clickhouseDateTimeType
 	case models.FieldTypeTime:
 		return "String"
 	case models.FieldTypeDuration:
 		return "Int64" // 存储为纳秒
+	case models.FieldTypeIP:
+		return "IPv6" // IPv4 以映射地址存储
 	case models.FieldTypeJSON:
 		return "String"
 	default:
@@ -241,8 +571,16 @@ func (s *ClickHouseStorage) getClickHouseType(fieldType models.FieldType) string
 	}
 }
 
+// clickhouseNested 对象数组字段以 Nested 列存储，写入时按子列展开
+func clickhouseNested(field *models.Field) bool {
+	return field.Type == models.FieldTypeArray && field.ItemType == models.FieldTypeObject
+}
+
 // Store 存储单条日志
 func (s *ClickHouseStorage) Store(ctx context.Context, log *models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
 	// 获取 schema
 	schema, err := s.GetSchema(ctx, log.Project, log.Table)
 	if err != nil {
@@ -255,31 +593,32 @@ func (s *ClickHouseStorage) Store(ctx context.Context, log *models.LogEntry) err
 	}
 
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", log.Project, log.Table)
+	tableName := logTable("clickhouse", log.Project, log.Table)
 
 	// 构建插入语句
+	assignIDs(s.ids, []*models.LogEntry{log})
 	columns := []string{"id", "project", "table_name", "timestamp"}
-	values := []interface{}{log.ID, log.Project, log.Table, log.Timestamp}
+	values := []interface{}{log.ID, log.Project, log.Table, log.Timestamp.UTC()}
 	placeholders := []string{"?", "?", "?", "?"}
 
+	// 写入全部 schema 字段，缺失的字段以 NULL 占位
 	for _, field := range schema.Fields {
-		if value, ok := log.Fields[field.Name]; ok {
-			columns = append(columns, field.Name)
-			values = append(values, value)
-			placeholders = append(placeholders, "?")
-		}
+		value, _ := entryField(log, field.Name)
+		columns = append(columns, field.Name)
+		values = append(values, value)
+		placeholders = append(placeholders, "?")
 	}
 
 	query := fmt.Sprintf(`
 	INSERT INTO %s (%s)
 	VALUES (%s)`,
 		tableName,
-		strings.Join(columns, ", "),
+		quoteIdents("clickhouse", columns),
 		strings.Join(placeholders, ", "),
 	)
 
 	if _, err := s.db.ExecContext(ctx, query, values...); err != nil {
-		return fmt.Errorf("插入日志失败: %w", err)
+		return fmt.Errorf("插入日志失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -287,6 +626,9 @@ func (s *ClickHouseStorage) Store(ctx context.Context, log *models.LogEntry) err
 
 // BatchStore 批量存储日志
 func (s *ClickHouseStorage) BatchStore(ctx context.Context, logs []*models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
 	if len(logs) == 0 {
 		return nil
 	}
@@ -294,7 +636,7 @@ func (s *ClickHouseStorage) BatchStore(ctx context.Context, logs []*models.LogEn
 	// 使用事务批量插入
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
@@ -305,7 +647,7 @@ func (s *ClickHouseStorage) BatchStore(ctx context.Context, logs []*models.LogEn
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -321,18 +663,13 @@ func (s *ClickHouseStorage) Close() error {
 
 // BatchInsertLogs 批量插入日志
 func (s *ClickHouseStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
 	if len(logs) == 0 {
 		return nil
 	}
 
-	// 打印日志的 JSON 格式（调试用）
-	logsJSON, err := json.MarshalIndent(logs, "", "  ")
-	if err != nil {
-		fmt.Println("Error marshalling logs:", err)
-		return err
-	}
-	fmt.Println("logs:", string(logsJSON))
-
 	// 获取 schema
 	schema, err := s.GetSchema(ctx, project, table)
 	if err != nil {
@@ -342,135 +679,96 @@ func (s *ClickHouseStorage) BatchInsertLogs(ctx context.Context, project, table
 	// 使用事务批量插入
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("clickhouse", project, table)
 
-	// 准备字段列表
-	var columns []string
+	// 列顺序固定为基础列加 schema 字段，对象数组展开为 Nested 的各个子列
+	columns := []string{"id", "project", "table_name", "timestamp"}
+	if schema.StoresTags() {
+		columns = append(columns, models.TagsColumn)
+	}
+	if schema.StoresIngestTime() {
+		columns = append(columns, models.IngestTimeColumn)
+	}
 	for _, field := range schema.Fields {
-		columns = append(columns, field.Name)
+		if !clickhouseNested(field) {
+			columns = append(columns, field.Name)
+			continue
+		}
+		for _, sub := range field.Fields {
+			columns = append(columns, field.Name+"."+sub.Name)
+		}
 	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		tableName,
+		quoteIdents("clickhouse", columns),
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
 
 	// 批量插入
+	assignIDs(s.ids, logs)
 	for _, log := range logs {
+		// 取消或超时后不再继续写入，事务由 defer 回滚
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("批量写入已中止: %w", err)
+		}
+
 		// 验证日志数据
 		if err := schema.ValidateLogEntry(log); err != nil {
 			return fmt.Errorf("日志数据验证失败: %w", err)
 		}
 
-		values := make([]interface{}, 0, len(columns))
-		placeholders := make([]string, 0, len(columns))
-		for _, col := range columns {
-			if value, ok := log.Fields[col]; ok {
-				values = append(values, value)
-				placeholders = append(placeholders, "?")
+		values := []interface{}{log.ID, project, table, log.Timestamp.UTC()}
+		if schema.StoresTags() {
+			tags := log.Tags
+			if tags == nil {
+				tags = map[string]string{}
 			}
+			values = append(values, tags)
+		}
+		if schema.StoresIngestTime() {
+			values = append(values, log.IngestTime.UTC())
+		}
+		// 缺失的字段写入 NULL，由 ClickHouse 转换为列默认值；缺失的对象数组写入空数组
+		for _, field := range schema.Fields {
+			value, _ := entryField(log, field.Name)
+			if clickhouseNested(field) {
+				_, subValues, err := clickhouseNestedColumns(field, value)
+				if err != nil {
+					return err
+				}
+				values = append(values, subValues...)
+				continue
+			}
+			encoded, err := encodeFieldValue("clickhouse", field, value)
+			if err != nil {
+				return err
+			}
+			values = append(values, encoded)
 		}
-
-		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-			tableName,
-			strings.Join(columns, ", "),
-			strings.Join(placeholders, ", "))
 
 		if _, err := tx.ExecContext(ctx, query, values...); err != nil {
-			return fmt.Errorf("插入日志失败: %w", err)
+			return fmt.Errorf("插入日志失败: %w", unavailable(err))
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
 }
 
-//// BatchInsertLogs 批量插入日志
-//func (s *ClickHouseStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
-//	if len(logs) == 0 {
-//		return nil
-//	}
-//
-//	// 打印日志的 JSON 格式（调试用）
-//	logsJSON, err := json.MarshalIndent(logs, "", "  ")
-//	if err != nil {
-//		fmt.Println("Error marshalling logs:", err)
-//		return err
-//	}
-//	fmt.Println("logs:", string(logsJSON))
-//
-//	// 使用事务批量插入
-//	tx, err := s.db.BeginTx(ctx, nil)
-//	if err != nil {
-//		return fmt.Errorf("开始事务失败: %w", err)
-//	}
-//	defer tx.Rollback()
-//
-//	// 构建表名
-//	tableName := fmt.Sprintf("logs_%s_%s", project, table)
-//
-//	// 准备插入的字段列表（即 logs 中的 key）
-//	var columns []string
-//	var allValues []interface{}
-//	var allPlaceholders []string
-//
-//	for _, log := range logs {
-//		// 从 log 的根级别提取 'level' 和 'message' 字段
-//		values := make([]interface{}, 0)
-//		placeholders := make([]string, 0)
-//
-//		// 根级字段 level 和 message
-//		columns = append(columns, "level", "message")
-//		values = append(values, log.Level, log.Message)
-//		placeholders = append(placeholders, "?", "?")
-//
-//		// 从 log.Fields 中提取出字段名（key）和值（value）
-//		for key, value := range log.Fields {
-//			columns = append(columns, key) // 将 key 作为列名
-//			values = append(values, value) // 将 value 作为值
-//			placeholders = append(placeholders, "?")
-//		}
-//
-//		// 准备一个占位符，并将其添加到查询中
-//		allPlaceholders = append(allPlaceholders, "("+strings.Join(placeholders, ", ")+")")
-//		allValues = append(allValues, values...)
-//	}
-//
-//	// 批量插入查询
-//	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
-//		tableName,
-//		strings.Join(columns, ", "),
-//		strings.Join(allPlaceholders, ", "),
-//	)
-//
-//	// 打印最终的 SQL 查询（调试用）
-//	logrus.Info("Executing Query: ", query)
-//	fmt.Println("SQL Query: ", query)
-//
-//	// 执行批量插入
-//	if _, err := tx.ExecContext(ctx, query, allValues...); err != nil {
-//		return fmt.Errorf("插入日志失败: %w", err)
-//	}
-//
-//	// 提交事务
-//	if err := tx.Commit(); err != nil {
-//		return fmt.Errorf("提交事务失败: %w", err)
-//	}
-//
-//	return nil
-//}
-
-func printQuery(query string, values []interface{}) string {
-	return fmt.Sprintf("Executing query: %s with values: %v", query, values)
-}
-
 // CountLogs 统计日志数量
 func (s *ClickHouseStorage) CountLogs(ctx context.Context, project, table string, query map[string]interface{}) (int64, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("clickhouse", project, table)
 
 	// 构建查询条件
 	conditions := make([]string, 0, len(query))
@@ -478,7 +776,7 @@ func (s *ClickHouseStorage) CountLogs(ctx context.Context, project, table string
 	paramCount := 1
 
 	for key, value := range query {
-		conditions = append(conditions, fmt.Sprintf("%s = ?", key))
+		conditions = append(conditions, fmt.Sprintf("%s = ?", quoteIdent("clickhouse", key)))
 		values = append(values, value)
 		paramCount++
 	}
@@ -493,7 +791,7 @@ func (s *ClickHouseStorage) CountLogs(ctx context.Context, project, table string
 	var count int64
 	err := s.db.QueryRowContext(ctx, sql, values...).Scan(&count)
 	if err != nil {
-		return 0, fmt.Errorf("统计日志失败: %w", err)
+		return 0, fmt.Errorf("统计日志失败: %w", unavailable(err))
 	}
 
 	return count, nil
@@ -501,10 +799,18 @@ func (s *ClickHouseStorage) CountLogs(ctx context.Context, project, table string
 
 // DeleteSchema 删除 schema
 func (s *ClickHouseStorage) DeleteSchema(ctx context.Context, project, table string) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil && !errors.Is(err, models.ErrSchemaNotFound) {
+		return err
+	}
+
 	// 开启事务
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
@@ -512,7 +818,7 @@ func (s *ClickHouseStorage) DeleteSchema(ctx context.Context, project, table str
 	query := `DELETE FROM schemas WHERE project = ? AND table_name = ?`
 	result, err := tx.ExecContext(ctx, query, project, table)
 	if err != nil {
-		return fmt.Errorf("删除 schema 失败: %w", err)
+		return fmt.Errorf("删除 schema 失败: %w", unavailable(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -520,24 +826,48 @@ func (s *ClickHouseStorage) DeleteSchema(ctx context.Context, project, table str
 		return fmt.Errorf("获取影响行数失败: %w", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("schema not found: %s_%s", project, table)
+		return fmt.Errorf("%w: %s_%s", models.ErrSchemaNotFound, project, table)
+	}
+
+	// 删除日志表，集群模式下同时删除各分片的本地表
+	cluster := s.config.ClickHouse.Cluster
+	tables := []string{logTable("clickhouse", project, table)}
+	if cluster != "" {
+		tables = append(tables, clickhouseLocalTable(project, table, cluster))
+	}
+	for _, tableName := range tables {
+		dropQuery := fmt.Sprintf("DROP TABLE IF EXISTS %s%s", tableName, clickhouseOnCluster(cluster))
+		if _, err := tx.ExecContext(ctx, dropQuery); err != nil {
+			return fmt.Errorf("删除日志表失败: %w", err)
+		}
 	}
 
-	// 删除日志表
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
-	dropQuery := fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName)
-	if _, err := tx.ExecContext(ctx, dropQuery); err != nil {
-		return fmt.Errorf("删除日志表失败: %w", err)
+	// 删除持续聚合物化视图
+	if schema != nil {
+		for _, agg := range schema.Aggregates {
+			dropQuery := "DROP VIEW IF EXISTS " + quoteIdent("clickhouse", aggregateTableName(project, table, agg.Name))
+			if _, err := tx.ExecContext(ctx, dropQuery); err != nil {
+				return fmt.Errorf("删除聚合物化视图失败: %w", err)
+			}
+		}
 	}
 
 	// 提交事务
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
 }
 
+// DeleteSchemaRecord 只删除 schema 记录，保留日志表与持续聚合表
+func (s *ClickHouseStorage) DeleteSchemaRecord(ctx context.Context, project, table string) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return deleteSchemaRecord(ctx, s.db, "clickhouse", project, table)
+}
+
 // InsertLog 插入单条日志
 func (s *ClickHouseStorage) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
 	return s.BatchInsertLogs(ctx, project, table, []*models.LogEntry{log})
@@ -545,26 +875,30 @@ func (s *ClickHouseStorage) InsertLog(ctx context.Context, project, table string
 
 // ListSchemas 列出所有 schemas
 func (s *ClickHouseStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	query := `
-	SELECT project, table_name, description, fields, created_at, updated_at
+	SELECT project, table_name, description, fields, options, created_at, updated_at
 	FROM schemas
-	GROUP BY project, table_name, description, fields, created_at, updated_at`
+	GROUP BY project, table_name, description, fields, options, created_at, updated_at`
 
 	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("查询 schemas 失败: %w", err)
+		return nil, fmt.Errorf("查询 schemas 失败: %w", unavailable(err))
 	}
 	defer rows.Close()
 
 	var schemas []*models.Schema
 	for rows.Next() {
 		var schema models.Schema
-		var fieldsJSON []byte
+		var fieldsJSON, optionsJSON []byte
 		err := rows.Scan(
 			&schema.Project,
 			&schema.Table,
 			&schema.Description,
 			&fieldsJSON,
+			&optionsJSON,
 			&schema.CreatedAt,
 			&schema.UpdatedAt,
 		)
@@ -577,6 +911,9 @@ func (s *ClickHouseStorage) ListSchemas(ctx context.Context) ([]*models.Schema,
 			return nil, fmt.Errorf("解析字段失败: %w", err)
 		}
 		schema.Fields = fields
+		if err := unmarshalSchemaOptions(optionsJSON, &schema); err != nil {
+			return nil, err
+		}
 		schemas = append(schemas, &schema)
 	}
 
@@ -585,13 +922,19 @@ func (s *ClickHouseStorage) ListSchemas(ctx context.Context) ([]*models.Schema,
 
 // Ping 测试数据库连接
 func (s *ClickHouseStorage) Ping(ctx context.Context) error {
-	return s.db.PingContext(ctx)
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%w: %w", ErrBackendUnavailable, err)
+	}
+	return nil
 }
 
 // QueryLogs 查询日志
 func (s *ClickHouseStorage) QueryLogs(ctx context.Context, project, table string, query map[string]interface{}, limit, offset int) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("clickhouse", project, table)
 
 	// 构建查询条件
 	conditions := make([]string, 0, len(query))
@@ -599,7 +942,7 @@ func (s *ClickHouseStorage) QueryLogs(ctx context.Context, project, table string
 	paramCount := 1
 
 	for key, value := range query {
-		conditions = append(conditions, fmt.Sprintf("%s = ?", key))
+		conditions = append(conditions, fmt.Sprintf("%s = ?", quoteIdent("clickhouse", key)))
 		values = append(values, value)
 		paramCount++
 	}
@@ -614,7 +957,7 @@ func (s *ClickHouseStorage) QueryLogs(ctx context.Context, project, table string
 	// 执行查询
 	rows, err := s.db.QueryContext(ctx, sql, values...)
 	if err != nil {
-		return nil, fmt.Errorf("查询日志失败: %w", err)
+		return nil, fmt.Errorf("查询日志失败: %w", unavailable(err))
 	}
 	defer rows.Close()
 
@@ -661,4 +1004,115 @@ func (s *ClickHouseStorage) UpdateSchema(ctx context.Context, schema *models.Sch
 	return s.CreateSchema(ctx, schema)
 }
 
-var _ Storage = (*ClickHouseStorage)(nil)
+// SearchLogs 执行带字段选择与排序的日志查询
+func (s *ClickHouseStorage) SearchLogs(ctx context.Context, project, table string, query *models.Query) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	return searchLogs(ctx, s.db, "clickhouse", logTable("clickhouse", project, table), schema, query)
+}
+
+// ExplainQuery 返回日志查询的执行计划，包含使用的主键与跳数索引
+func (s *ClickHouseStorage) ExplainQuery(ctx context.Context, project, table string, query *models.Query, analyze bool) (*models.QueryPlan, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	return explainQuery(ctx, s.db, "clickhouse", logTable("clickhouse", project, table), schema, query, analyze)
+}
+
+// FieldValues 统计字段出现最多的取值，不同取值个数由 uniqCombined 近似计算
+func (s *ClickHouseStorage) FieldValues(ctx context.Context, project, table, field string, query *models.Query) (*models.FieldValues, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	return topFieldValues(ctx, s.db, "clickhouse", logTable("clickhouse", project, table), schema, field, query)
+}
+
+// CountMatching 统计匹配过滤条件的日志数
+func (s *ClickHouseStorage) CountMatching(ctx context.Context, project, table string, filter *models.LogFilter) (int64, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return 0, err
+	}
+
+	return countMatching(ctx, s.db, "clickhouse", logTable("clickhouse", project, table), schema, filter)
+}
+
+// DeleteLogs 删除匹配过滤条件的日志
+func (s *ClickHouseStorage) DeleteLogs(ctx context.Context, project, table string, filter *models.LogFilter, limit int64) (int64, error) {
+	return s.mutateLogs(ctx, project, table, filter, nil, limit)
+}
+
+// UpdateLogs 修改匹配过滤条件的日志字段
+func (s *ClickHouseStorage) UpdateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	return s.mutateLogs(ctx, project, table, filter, set, limit)
+}
+
+// mutateLogs 删除或修改匹配的日志
+func (s *ClickHouseStorage) mutateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return 0, err
+	}
+
+	return mutateLogs(ctx, s.db, "clickhouse", logTable("clickhouse", project, table), schema, filter, set, limit)
+}
+
+// TableSizes 汇总 system.parts 中各日志表活动数据分片的行数与磁盘大小，数据大小为压缩后的列数据，
+// 其余（标记、主键与跳数索引等）计为索引。集群模式下统计各分片的 <table>_local，每个分片取一个副本
+func (s *ClickHouseStorage) TableSizes(ctx context.Context) ([]*models.TableSize, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	cluster := s.config.ClickHouse.Cluster
+	parts := "system.parts"
+	if cluster != "" {
+		parts = fmt.Sprintf("cluster(%s, system.parts)", quoteLiteral("clickhouse", cluster))
+	}
+	query := fmt.Sprintf(`
+	SELECT toInt64(sum(rows)), toInt64(sum(data_compressed_bytes)), toInt64(sum(bytes_on_disk))
+	FROM %s WHERE database = currentDatabase() AND table = ? AND active`, parts)
+	return tableSizes(ctx, s, "system.parts", func(ctx context.Context, schema *models.Schema) (*models.TableSize, error) {
+		name := logTableName(schema.Project, schema.Table)
+		if cluster != "" {
+			name += "_local"
+		}
+		size := &models.TableSize{}
+		if err := s.db.QueryRowContext(ctx, query, name).Scan(&size.Rows, &size.DataBytes, &size.TotalBytes); err != nil {
+			return nil, unavailable(err)
+		}
+		if size.TotalBytes > size.DataBytes {
+			size.IndexBytes = size.TotalBytes - size.DataBytes
+		}
+		return size, nil
+	})
+}
+
+var (
+	_ Storage           = (*ClickHouseStorage)(nil)
+	_ LogQuerier        = (*ClickHouseStorage)(nil)
+	_ LogMutator        = (*ClickHouseStorage)(nil)
+	_ ContinuousQuerier = (*ClickHouseStorage)(nil)
+	_ SizeReporter      = (*ClickHouseStorage)(nil)
+)
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// clickhouseStateSuffix 物化视图中保存聚合中间状态的列名后缀。
+// 查询时的别名与列名不同，避免 ClickHouse 用别名替换 -Merge 函数的参数
+const clickhouseStateSuffix = "_state"
+
+// clickhouseAggregateView 返回持续聚合的物化视图定义。视图以 AggregatingMergeTree 保存各时间桶的
+// 聚合中间状态，日志写入时由 ClickHouse 增量更新；视图只聚合创建之后写入的日志
+func clickhouseAggregateView(schema *models.Schema, agg *models.Aggregate) (string, error) {
+	size, err := agg.BucketSize()
+	if err != nil {
+		return "", err
+	}
+	interval := fmt.Sprintf("INTERVAL %d SECOND", size/time.Second)
+	if size%time.Second != 0 {
+		interval = fmt.Sprintf("INTERVAL %d MILLISECOND", size/time.Millisecond)
+	}
+
+	// 时间桶按 UTC 对齐，与其他后端一致，也不受旧表 timestamp 列未指定时区的影响
+	selects := []string{fmt.Sprintf("toStartOfInterval(timestamp, %s, 'UTC') AS bucket", interval)}
+	keys := clickhouseAggregateKeys(agg)
+	for _, name := range agg.GroupBy {
+		column := quoteIdent("clickhouse", name)
+		// 与 SQLite/MySQL 侧表一致，分组值为字符串，缺失时为空字符串；未定义 level 字段时 level 分组为空
+		expr := "''"
+		if schema.GetField(name) != nil {
+			expr = fmt.Sprintf("ifNull(toString(%s), '')", column)
+		}
+		selects = append(selects, fmt.Sprintf("%s AS %s", expr, column))
+	}
+	selects = append(selects, "countState() AS "+quoteIdent("clickhouse", "count"+clickhouseStateSuffix))
+
+	seen := make(map[string]bool)
+	for _, metric := range agg.Metrics {
+		if metric.Func == models.AggregateCount || seen[metric.Column()] {
+			continue
+		}
+		seen[metric.Column()] = true
+		name, params := clickhouseAggregateFunc(metric.Func)
+		selects = append(selects, fmt.Sprintf("%sState%s(toFloat64(%s)) AS %s", name, params,
+			quoteIdent("clickhouse", metric.Field), quoteIdent("clickhouse", metric.Column()+clickhouseStateSuffix)))
+	}
+
+	return fmt.Sprintf(`CREATE MATERIALIZED VIEW IF NOT EXISTS %s
+	ENGINE = AggregatingMergeTree()
+	PARTITION BY toYYYYMM(bucket)
+	ORDER BY (%s)
+	AS SELECT %s
+	FROM %s
+	GROUP BY %s`,
+		quoteIdent("clickhouse", aggregateTableName(schema.Project, schema.Table, agg.Name)),
+		keys,
+		strings.Join(selects, ", "),
+		logTable("clickhouse", schema.Project, schema.Table),
+		keys,
+	), nil
+}
+
+// clickhouseAggregateFunc 返回聚合函数在 ClickHouse 中的函数名与参数，百分位数使用 quantile(q)，
+// 标准差使用 stddevSamp，与 SQLite/MySQL 的样本标准差一致
+func clickhouseAggregateFunc(f models.AggregateFunc) (name, params string) {
+	if q, ok := f.Quantile(); ok {
+		return "quantile", fmt.Sprintf("(%g)", q)
+	}
+	if f == models.AggregateStddev {
+		return "stddevSamp", ""
+	}
+	return string(f), ""
+}
+
+// clickhouseAggregateKeys 返回物化视图的排序与分组键
+func clickhouseAggregateKeys(agg *models.Aggregate) string {
+	keys := "bucket"
+	if len(agg.GroupBy) > 0 {
+		keys += ", " + quoteIdents("clickhouse", agg.GroupBy)
+	}
+	return keys
+}
+
+// clickhouseAggregateQuery 返回读取 bucket 位于 [from, to) 的聚合结果的查询，零值表示不限制。
+// 同一时间桶可能有多行尚未合并的中间状态，查询时按键分组合并，列名与 SQLite/MySQL 的结果相同
+func clickhouseAggregateQuery(schema *models.Schema, agg *models.Aggregate, from, to time.Time) (string, []interface{}) {
+	keys := clickhouseAggregateKeys(agg)
+	selects := []string{keys, fmt.Sprintf("countMerge(%s) AS count", quoteIdent("clickhouse", "count"+clickhouseStateSuffix))}
+	seen := make(map[string]bool)
+	for _, metric := range agg.Metrics {
+		if metric.Func == models.AggregateCount || seen[metric.Column()] {
+			continue
+		}
+		seen[metric.Column()] = true
+		name, params := clickhouseAggregateFunc(metric.Func)
+		selects = append(selects, fmt.Sprintf("%sMerge%s(%s) AS %s", name, params,
+			quoteIdent("clickhouse", metric.Column()+clickhouseStateSuffix), quoteIdent("clickhouse", metric.Column())))
+	}
+
+	var conditions []string
+	var args []interface{}
+	if !from.IsZero() {
+		conditions = append(conditions, "bucket >= ?")
+		args = append(args, from.UTC())
+	}
+	if !to.IsZero() {
+		conditions = append(conditions, "bucket < ?")
+		args = append(args, to.UTC())
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "),
+		quoteIdent("clickhouse", aggregateTableName(schema.Project, schema.Table, agg.Name)))
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" GROUP BY %s ORDER BY %s", keys, keys)
+	return query, args
+}
+
+// clickhouseSyncAggregates 返回将物化视图从 old 的聚合定义同步到 schema 的语句：删除已移除或定义变化的视图，
+// 再创建缺少的视图。定义变化的视图重建后从头累计。old 为 nil 表示新建的 schema
+func clickhouseSyncAggregates(old, schema *models.Schema) ([]string, error) {
+	var queries []string
+	if old != nil {
+		for _, prev := range old.Aggregates {
+			if agg, ok := schema.GetAggregate(prev.Name); ok && reflect.DeepEqual(agg, prev) {
+				continue
+			}
+			queries = append(queries, "DROP VIEW IF EXISTS "+
+				quoteIdent("clickhouse", aggregateTableName(old.Project, old.Table, prev.Name)))
+		}
+	}
+	for _, agg := range schema.Aggregates {
+		view, err := clickhouseAggregateView(schema, agg)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, view)
+	}
+	return queries, nil
+}
+
+// syncAggregates 按 schema 的聚合定义创建、重建或删除持续聚合物化视图
+func (s *ClickHouseStorage) syncAggregates(ctx context.Context, old, schema *models.Schema) error {
+	if len(schema.Aggregates) > 0 && s.config.ClickHouse.Cluster != "" {
+		return fmt.Errorf("%w: continuous aggregates are not supported in ClickHouse cluster mode", models.ErrValidation)
+	}
+	queries, err := clickhouseSyncAggregates(old, schema)
+	if err != nil {
+		return err
+	}
+	for _, query := range queries {
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("同步聚合物化视图失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// QueryAggregate 查询持续聚合结果
+func (s *ClickHouseStorage) QueryAggregate(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+	agg, ok := schema.GetAggregate(name)
+	if !ok {
+		return nil, fmt.Errorf("aggregate not found: %s", name)
+	}
+
+	query, args := clickhouseAggregateQuery(schema, agg, from, to)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询聚合失败: %w", unavailable(err))
+	}
+	defer rows.Close()
+
+	return scanRows(rows)
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestClickHouseCreateTable(t *testing.T) {
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "service", Type: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeFloat},
+		},
+	}
+	store := &ClickHouseStorage{}
+
+	queries := clickhouseCreateTable(schema, store.getClickHouseType, "")
+	require.Len(t, queries, 1)
+	query := queries[0]
+	assert.Contains(t, query, "ORDER BY (timestamp, id)")
+	assert.Contains(t, query, "`timestamp` DateTime64(9, 'UTC')")
+	assert.NotContains(t, query, "TTL")
+	assert.Empty(t, clickhouseAlterOptions(schema, "t"))
+
+	schema.Timestamps = &models.TimestampPolicy{Precision: models.PrecisionMilli}
+	schema.ClickHouse = &models.ClickHouseOptions{
+		OrderBy: []string{"service", "timestamp"},
+		TTL:     "timestamp + interval 30 day",
+		Codecs:  map[string]string{"timestamp": "Delta, ZSTD(1)", "latency": "Gorilla"},
+	}
+	require.NoError(t, schema.Validate())
+
+	query = clickhouseCreateTable(schema, store.getClickHouseType, "")[0]
+	assert.Contains(t, query, "ORDER BY (`service`, `timestamp`)")
+	assert.Contains(t, query, "`timestamp` DateTime64(3, 'UTC') CODEC(Delta, ZSTD(1))")
+	assert.Contains(t, query, "`latency` Float64 CODEC(Gorilla)")
+	assert.Contains(t, query, "TTL toDateTime(`timestamp`) + INTERVAL 30 DAY")
+	assert.Equal(t, []string{
+		"ALTER TABLE t MODIFY COLUMN `latency` CODEC(Gorilla)",
+		"ALTER TABLE t MODIFY COLUMN `timestamp` CODEC(Delta, ZSTD(1))",
+		"ALTER TABLE t MODIFY TTL toDateTime(`timestamp`) + INTERVAL 30 DAY",
+	}, clickhouseAlterOptions(schema, "t"))
+}
+
+func TestClickHouseCluster(t *testing.T) {
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "service", Type: models.FieldTypeString}},
+	}
+	store := &ClickHouseStorage{}
+
+	queries := clickhouseCreateTable(schema, store.getClickHouseType, "logs")
+	require.Len(t, queries, 2)
+	assert.Contains(t, queries[0], "CREATE TABLE IF NOT EXISTS `logs_app_requests_local` ON CLUSTER `logs`")
+	assert.Contains(t, queries[0], "ENGINE = ReplicatedMergeTree()")
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `logs_app_requests` ON CLUSTER `logs` AS `logs_app_requests_local` "+
+		"ENGINE = Distributed('logs', currentDatabase(), 'logs_app_requests_local', rand())", queries[1])
+	assert.Equal(t, "`logs_app_requests`", clickhouseLocalTable("app", "requests", ""))
+	assert.Empty(t, clickhouseOnCluster(""))
+}
+
+func TestClickHouseSettings(t *testing.T) {
+	settings, err := clickhouseSettings(ClickHouseConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, settings)
+
+	noWait := false
+	settings, err = clickhouseSettings(ClickHouseConfig{AsyncInsert: true, WaitForAsyncInsert: &noWait, InsertQuorum: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "&async_insert=1&wait_for_async_insert=0&insert_quorum=2", settings)
+
+	settings, err = clickhouseSettings(ClickHouseConfig{AsyncInsert: true})
+	require.NoError(t, err)
+	assert.Equal(t, "&async_insert=1&wait_for_async_insert=1", settings)
+
+	_, err = clickhouseSettings(ClickHouseConfig{InsertQuorum: -1})
+	assert.Error(t, err)
+}
+
+func TestClickHouseSkippingIndexes(t *testing.T) {
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "service", Type: models.FieldTypeString, Indexed: true},
+			{Name: "latency", Type: models.FieldTypeFloat, Indexed: true},
+			{Name: "cached", Type: models.FieldTypeBool, Indexed: true},
+			{Name: "path", Type: models.FieldTypeString},
+		},
+	}
+	store := &ClickHouseStorage{}
+
+	query := clickhouseCreateTable(schema, store.getClickHouseType, "")[0]
+	assert.Contains(t, query, "INDEX `idx_service` `service` TYPE bloom_filter GRANULARITY 1")
+	assert.Contains(t, query, "INDEX `idx_latency` `latency` TYPE minmax GRANULARITY 1")
+	assert.Contains(t, query, "INDEX `idx_cached` `cached` TYPE set(2) GRANULARITY 1")
+	assert.NotContains(t, query, "idx_path")
+	assert.NotContains(t, query, "MATERIALIZED VIEW")
+}
+
+func TestClickHouseAggregateViews(t *testing.T) {
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "path", Type: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeFloat},
+		},
+		SchemaOptions: models.SchemaOptions{
+			Aggregates: []*models.Aggregate{{
+				Name:     "per_minute",
+				Interval: "1m",
+				GroupBy:  []string{"path", "level"},
+				Metrics: []*models.AggregateMetric{
+					{Func: models.AggregateCount},
+					{Func: models.AggregateAvg, Field: "latency"},
+					{Func: models.AggregateMax, Field: "latency"},
+				},
+			}},
+		},
+	}
+	require.NoError(t, schema.Validate())
+
+	queries, err := clickhouseSyncAggregates(nil, schema)
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	view := queries[0]
+	assert.Contains(t, view, "CREATE MATERIALIZED VIEW IF NOT EXISTS `cq_app_requests_per_minute`")
+	assert.Contains(t, view, "ENGINE = AggregatingMergeTree()")
+	assert.Contains(t, view, "ORDER BY (bucket, `path`, `level`)")
+	assert.Contains(t, view, "toStartOfInterval(timestamp, INTERVAL 60 SECOND, 'UTC') AS bucket")
+	assert.Contains(t, view, "ifNull(toString(`path`), '') AS `path`")
+	assert.Contains(t, view, "'' AS `level`")
+	assert.Contains(t, view, "countState() AS `count_state`")
+	assert.Contains(t, view, "avgState(toFloat64(`latency`)) AS `avg_latency_state`")
+	assert.Contains(t, view, "FROM `logs_app_requests`")
+
+	from := time.Date(2024, 3, 14, 10, 0, 0, 0, time.UTC)
+	query, args := clickhouseAggregateQuery(schema, schema.Aggregates[0], from, time.Time{})
+	assert.Equal(t, "SELECT bucket, `path`, `level`, countMerge(`count_state`) AS count, "+
+		"avgMerge(`avg_latency_state`) AS `avg_latency`, maxMerge(`max_latency_state`) AS `max_latency` "+
+		"FROM `cq_app_requests_per_minute` WHERE bucket >= ? "+
+		"GROUP BY bucket, `path`, `level` ORDER BY bucket, `path`, `level`", query)
+	assert.Equal(t, []interface{}{from}, args)
+
+	// 未变化的视图保留，定义变化的视图重建，移除的视图删除
+	queries, err = clickhouseSyncAggregates(schema, schema.Clone())
+	require.NoError(t, err)
+	assert.NotContains(t, strings.Join(queries, "\n"), "DROP VIEW")
+
+	updated := schema.Clone()
+	updated.Aggregates = []*models.Aggregate{
+		{Name: "per_minute", Interval: "5m"},
+		{Name: "per_hour", Interval: "1h"},
+	}
+	queries, err = clickhouseSyncAggregates(schema, updated)
+	require.NoError(t, err)
+	require.Len(t, queries, 3)
+	assert.Equal(t, "DROP VIEW IF EXISTS `cq_app_requests_per_minute`", queries[0])
+	assert.Contains(t, queries[1], "INTERVAL 300 SECOND")
+	assert.Contains(t, queries[2], "`cq_app_requests_per_hour`")
+
+	queries, err = clickhouseSyncAggregates(updated, &models.Schema{Project: "app", Table: "requests"})
+	require.NoError(t, err)
+	assert.Len(t, queries, 2)
+}
+
+func TestClickHouseStatisticalAggregates(t *testing.T) {
+	agg := &models.Aggregate{
+		Name:     "latency",
+		Interval: "1m",
+		Metrics: []*models.AggregateMetric{
+			{Func: models.AggregateP50, Field: "latency"},
+			{Func: models.AggregateP99, Field: "latency"},
+			{Func: models.AggregateStddev, Field: "latency"},
+		},
+	}
+	schema := &models.Schema{
+		Project:       "app",
+		Table:         "requests",
+		Fields:        []*models.Field{{Name: "latency", Type: models.FieldTypeDuration}},
+		SchemaOptions: models.SchemaOptions{Aggregates: []*models.Aggregate{agg}},
+	}
+	require.NoError(t, schema.Validate())
+
+	view, err := clickhouseAggregateView(schema, agg)
+	require.NoError(t, err)
+	assert.Contains(t, view, "quantileState(0.5)(toFloat64(`latency`)) AS `p50_latency_state`")
+	assert.Contains(t, view, "quantileState(0.99)(toFloat64(`latency`)) AS `p99_latency_state`")
+	assert.Contains(t, view, "stddevSampState(toFloat64(`latency`)) AS `stddev_latency_state`")
+
+	query, _ := clickhouseAggregateQuery(schema, agg, time.Time{}, time.Time{})
+	assert.Contains(t, query, "quantileMerge(0.99)(`p99_latency_state`) AS `p99_latency`")
+	assert.Contains(t, query, "stddevSampMerge(`stddev_latency_state`) AS `stddev_latency`")
+}
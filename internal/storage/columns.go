@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// columnConstraints 返回字段列定义后的 NOT NULL、DEFAULT 与 CHECK 子句。alter 为 true 时表示为已有表补充列，
+// 此时没有默认值的列不加 NOT NULL，避免已有数据无法满足约束。ClickHouse 的列本身不可为 NULL，只添加 DEFAULT
+func columnConstraints(dialect string, field *models.Field, alter bool) string {
+	var parts []string
+	literal, hasDefault := defaultLiteral(dialect, field)
+	if !field.IsNullable() && dialect != "clickhouse" && (hasDefault || !alter) {
+		parts = append(parts, "NOT NULL")
+	}
+	if hasDefault {
+		parts = append(parts, "DEFAULT "+literal)
+	}
+	if check := checkExpression(dialect, field); check != "" {
+		parts = append(parts, "CHECK ("+check+")")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, " ")
+}
+
+// defaultLiteral 将字段默认值转换为 SQL 字面量。JSON、时长、日期时间等类型的默认值
+// 只在写入时由 Schema.ValidateLogEntry 填充，不写入列定义
+func defaultLiteral(dialect string, field *models.Field) (string, bool) {
+	if field.Default == nil {
+		return "", false
+	}
+
+	switch field.Type {
+	case models.FieldTypeInt, models.FieldTypeFloat:
+		switch v := field.Default.(type) {
+		case int, int32, int64, float32, float64:
+			return fmt.Sprint(v), true
+		}
+	case models.FieldTypeBool:
+		v, ok := field.Default.(bool)
+		if !ok {
+			return "", false
+		}
+		if dialect == "sqlite" || dialect == "clickhouse" {
+			if v {
+				return "1", true
+			}
+			return "0", true
+		}
+		if v {
+			return "TRUE", true
+		}
+		return "FALSE", true
+	case models.FieldTypeString, models.FieldTypeTime:
+		v, ok := field.Default.(string)
+		// MySQL 的 TEXT 列不支持字面量默认值
+		if !ok || (dialect == "mysql" && field.Type == models.FieldTypeString) {
+			return "", false
+		}
+		return quoteLiteral(dialect, v), true
+	}
+	return "", false
+}
+
+// quoteLiteral 转义字符串字面量，MySQL 与 ClickHouse 还需转义反斜杠
+func quoteLiteral(dialect, value string) string {
+	if dialect == "mysql" || dialect == "clickhouse" {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// checkExpression 返回字段约束对应的 CHECK 表达式。SQLite 没有内置 REGEXP，正则只在写入时校验；
+// ClickHouse 缺失的列会写入类型零值，约束同样只在写入时校验
+func checkExpression(dialect string, field *models.Field) string {
+	if !field.HasConstraints() || dialect == "clickhouse" {
+		return ""
+	}
+
+	length := "length"
+	switch dialect {
+	case "postgres":
+		length = "char_length"
+	case "mysql":
+		length = "CHAR_LENGTH"
+	}
+
+	column := quoteIdent(dialect, field.Name)
+	var conditions []string
+	if field.MinLength != nil {
+		conditions = append(conditions, fmt.Sprintf("%s(%s) >= %d", length, column, *field.MinLength))
+	}
+	if field.MaxLength != nil {
+		conditions = append(conditions, fmt.Sprintf("%s(%s) <= %d", length, column, *field.MaxLength))
+	}
+	if field.MinValue != nil {
+		conditions = append(conditions, fmt.Sprintf("%s >= %s", column, strconv.FormatFloat(*field.MinValue, 'g', -1, 64)))
+	}
+	if field.MaxValue != nil {
+		conditions = append(conditions, fmt.Sprintf("%s <= %s", column, strconv.FormatFloat(*field.MaxValue, 'g', -1, 64)))
+	}
+	if field.Pattern != "" {
+		switch dialect {
+		case "postgres":
+			conditions = append(conditions, fmt.Sprintf("%s ~ %s", column, quoteLiteral(dialect, field.Pattern)))
+		case "mysql":
+			conditions = append(conditions, fmt.Sprintf("REGEXP_LIKE(%s, %s)", column, quoteLiteral(dialect, field.Pattern)))
+		}
+	}
+	return strings.Join(conditions, " AND ")
+}
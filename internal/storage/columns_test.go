@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestColumnConstraints(t *testing.T) {
+	notNull := false
+	status := &models.Field{Name: "status", Type: models.FieldTypeString, Default: "it's ok", Nullable: &notNull}
+	assert.Equal(t, " NOT NULL DEFAULT 'it''s ok'", columnConstraints("postgres", status, false))
+	assert.Equal(t, "", columnConstraints("mysql", status, true), "TEXT columns cannot have a literal default")
+	assert.Equal(t, " NOT NULL", columnConstraints("mysql", status, false))
+	assert.Equal(t, " DEFAULT 'it''s ok'", columnConstraints("clickhouse", status, false))
+
+	retries := &models.Field{Name: "retries", Type: models.FieldTypeInt, Required: true, Nullable: &notNull}
+	assert.Equal(t, " NOT NULL", columnConstraints("sqlite", retries, false))
+	assert.Equal(t, "", columnConstraints("sqlite", retries, true), "NOT NULL without default is not added to existing tables")
+
+	cached := &models.Field{Name: "cached", Type: models.FieldTypeBool, Default: true}
+	assert.Equal(t, " DEFAULT 1", columnConstraints("sqlite", cached, false))
+	assert.Equal(t, " DEFAULT TRUE", columnConstraints("postgres", cached, false))
+
+	assert.Equal(t, "", columnConstraints("sqlite", &models.Field{Name: "meta", Type: models.FieldTypeJSON, Default: map[string]interface{}{}}, false))
+}
+
+func TestSQLiteDefaults(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	notNull := false
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "jobs",
+		Fields: []*models.Field{
+			{Name: "service", Type: models.FieldTypeString, Required: true},
+			{Name: "status", Type: models.FieldTypeString, Default: "queued", Nullable: &notNull},
+			{Name: "retries", Type: models.FieldTypeInt, Default: 3},
+			{Name: "note", Type: models.FieldTypeString},
+		},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	var notnull int
+	var dflt string
+	require.NoError(t, store.db.QueryRowContext(ctx,
+		`SELECT "notnull", dflt_value FROM pragma_table_info('logs_app_jobs') WHERE name = 'status'`).Scan(&notnull, &dflt))
+	assert.Equal(t, 1, notnull)
+	assert.Equal(t, "'queued'", dflt)
+
+	require.NoError(t, store.InsertLog(ctx, "app", "jobs", &models.LogEntry{
+		Project:   "app",
+		Table:     "jobs",
+		Level:     "info",
+		Message:   "enqueued",
+		Timestamp: time.Now(),
+		Fields:    map[string]interface{}{"service": "worker"},
+	}))
+
+	rows, err := store.SearchLogs(ctx, "app", "jobs", &models.Query{Fields: []string{"service", "status", "retries", "note"}})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "queued", rows[0]["status"])
+	assert.EqualValues(t, 3, rows[0]["retries"])
+	assert.Nil(t, rows[0]["note"])
+
+	err = store.InsertLog(ctx, "app", "jobs", &models.LogEntry{
+		Project:   "app",
+		Table:     "jobs",
+		Level:     "info",
+		Message:   "null status",
+		Timestamp: time.Now(),
+		Fields:    map[string]interface{}{"service": "worker", "status": nil},
+	})
+	assert.ErrorIs(t, err, models.ErrValidation)
+	require.Len(t, models.FieldErrors(err), 1)
+	assert.Equal(t, models.FieldErrorNull, models.FieldErrors(err)[0].Reason)
+}
+
+func TestCheckExpression(t *testing.T) {
+	maxLen := 8
+	minValue := 0.5
+	code := &models.Field{Name: "code", Type: models.FieldTypeString, MaxLength: &maxLen, Pattern: `^\d+$`}
+	assert.Equal(t, `char_length("code") <= 8 AND "code" ~ '^\d+$'`, checkExpression("postgres", code))
+	assert.Equal(t, "CHAR_LENGTH(`code`) <= 8 AND REGEXP_LIKE(`code`, '^\\\\d+$')", checkExpression("mysql", code))
+	assert.Equal(t, `length("code") <= 8`, checkExpression("sqlite", code))
+	assert.Equal(t, "", checkExpression("clickhouse", code))
+
+	score := &models.Field{Name: "score", Type: models.FieldTypeFloat, MinValue: &minValue}
+	assert.Equal(t, ` CHECK ("score" >= 0.5)`, columnConstraints("sqlite", score, false))
+}
+
+func TestSQLiteCheckConstraints(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	maxLen := 3
+	maxValue := 10.0
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "codes",
+		Fields: []*models.Field{
+			{Name: "code", Type: models.FieldTypeString, MaxLength: &maxLen},
+			{Name: "level_no", Type: models.FieldTypeInt, MaxValue: &maxValue},
+		},
+	}))
+
+	// 绕过写入校验直接写库，由 CHECK 约束拒绝
+	_, err := store.db.ExecContext(ctx, `INSERT INTO logs_app_codes (id, code) VALUES ('a', 'toolong')`)
+	assert.ErrorContains(t, err, "CHECK constraint failed")
+	_, err = store.db.ExecContext(ctx, `INSERT INTO logs_app_codes (id, level_no) VALUES ('b', 11)`)
+	assert.ErrorContains(t, err, "CHECK constraint failed")
+	_, err = store.db.ExecContext(ctx, `INSERT INTO logs_app_codes (id, code, level_no) VALUES ('c', 'ok', 10)`)
+	assert.NoError(t, err)
+}
+
+func TestFieldValuesOrder(t *testing.T) {
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields: []*models.Field{
+			{Name: "service", Type: models.FieldTypeString},
+			{Name: "message", Type: models.FieldTypeString},
+			{Name: "tags", Type: models.FieldTypeArray, ItemType: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeFloat},
+		},
+	}
+	assert.Equal(t, []string{"service", "message", "tags", "latency"}, fieldColumns(schema))
+
+	values, err := fieldValues("mysql", schema, &models.LogEntry{
+		Message: "hello",
+		Fields:  map[string]interface{}{"latency": 1.5, "tags": []interface{}{"a"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{nil, "hello", `["a"]`, 1.5}, values)
+}
+
+func TestSQLiteBatchMissingFields(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields: []*models.Field{
+			{Name: "service", Type: models.FieldTypeString},
+			{Name: "status", Type: models.FieldTypeInt},
+			{Name: "region", Type: models.FieldTypeString, Default: "eu"},
+			{Name: "latency", Type: models.FieldTypeFloat},
+		},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	// 每行缺失的字段不同，字段在 map 中的顺序也与 schema 无关
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := func(i int, fields map[string]interface{}) *models.LogEntry {
+		return &models.LogEntry{
+			Project: "app", Table: "events", Level: "info", Message: "request",
+			Timestamp: base.Add(time.Duration(i) * time.Second), Fields: fields,
+		}
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "events", []*models.LogEntry{
+		entry(0, map[string]interface{}{"latency": 0.5, "service": "api"}),
+		entry(1, map[string]interface{}{"status": 500}),
+		entry(2, map[string]interface{}{"region": "us", "latency": 2.5, "status": 200, "service": "web"}),
+		entry(3, nil),
+	}))
+
+	rows, err := store.SearchLogs(ctx, "app", "events", &models.Query{
+		Fields: []string{"service", "status", "region", "latency"},
+		Sort:   []string{"timestamp"},
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	want := []map[string]interface{}{
+		{"service": "api", "status": nil, "region": "eu", "latency": 0.5},
+		{"service": nil, "status": int64(500), "region": "eu", "latency": nil},
+		{"service": "web", "status": int64(200), "region": "us", "latency": 2.5},
+		{"service": nil, "status": nil, "region": "eu", "latency": nil},
+	}
+	for i, row := range rows {
+		for name, value := range want[i] {
+			assert.EqualValues(t, value, row[name], "row %d field %s", i, name)
+		}
+	}
+}
+
+func TestSQLiteIngestTime(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "service", Type: models.FieldTypeString}},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	// 延迟发送的日志：事件时间早于接收时间
+	event := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	received := event.Add(time.Hour)
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "events", []*models.LogEntry{
+		{Project: "app", Table: "events", Level: "info", Message: "late", Timestamp: event, IngestTime: received, Fields: map[string]interface{}{"service": "batch"}},
+		{Project: "app", Table: "events", Level: "info", Message: "on time", Timestamp: event.Add(2 * time.Hour), IngestTime: event.Add(2 * time.Hour), Fields: map[string]interface{}{"service": "api"}},
+	}))
+
+	query := &models.Query{Fields: []string{"service", "timestamp", models.IngestTimeColumn}, Sort: []string{models.IngestTimeColumn}}
+	require.NoError(t, query.Validate(schema, models.BaseColumns))
+	rows, err := store.SearchLogs(ctx, "app", "events", query)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "batch", rows[0]["service"])
+	ingest, ok := rows[0][models.IngestTimeColumn].(time.Time)
+	require.True(t, ok, rows[0])
+	assert.True(t, ingest.Equal(received), ingest)
+	ts, ok := rows[0]["timestamp"].(time.Time)
+	require.True(t, ok, rows[0])
+	assert.True(t, ts.Equal(event), ts)
+}
+
+func TestSQLiteTimestampPrecision(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "seq", Type: models.FieldTypeInt}},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	// 同一微秒内的事件按纳秒排序
+	base := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	var logs []*models.LogEntry
+	for i, offset := range []int{700, 5, 350, 0} {
+		logs = append(logs, &models.LogEntry{
+			Project: "app", Table: "events", Level: "info", Message: "tick",
+			Timestamp: base.Add(time.Duration(offset)), Fields: map[string]interface{}{"seq": i},
+		})
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "events", logs))
+
+	rows, err := store.SearchLogs(ctx, "app", "events", &models.Query{Fields: []string{"seq", "timestamp"}, Sort: []string{"timestamp"}})
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	var order []interface{}
+	for _, row := range rows {
+		order = append(order, row["seq"])
+	}
+	assert.Equal(t, []interface{}{int64(3), int64(1), int64(2), int64(0)}, order)
+	ts, ok := rows[3]["timestamp"].(time.Time)
+	require.True(t, ok, rows[3])
+	assert.True(t, ts.Equal(base.Add(700)), ts)
+}
@@ -0,0 +1,100 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/storage"
+	"pkg.blksails.net/logs/internal/storage/storagetest"
+)
+
+// 一致性测试的后端由 STORAGE_BACKENDS 指定，逗号分隔，默认 sqlite,file。
+// 外部数据库使用 POSTGRES_*、MYSQL_*、CLICKHOUSE_* 环境变量中的地址；未设置 *_HOST 时通过 testcontainers-go 启动一次性容器（需要 -tags testcontainers）。
+// 显式指定的后端无法连接时测试失败而不是跳过
+func TestConformance(t *testing.T) {
+	backends := strings.Split(envOr("STORAGE_BACKENDS", "sqlite,file"), ",")
+	for _, backend := range backends {
+		backend = strings.TrimSpace(backend)
+		if backend == "" {
+			continue
+		}
+		t.Run(backend, func(t *testing.T) {
+			storagetest.Run(t, openConformanceStorage(t, backend))
+		})
+	}
+}
+
+// openConformanceStorage 创建并初始化存储
+func openConformanceStorage(t *testing.T, backend string) storage.Storage {
+	config := storage.Config{Type: backend, Logger: zap.NewNop()}
+	switch backend {
+	case "sqlite":
+		config.SQLite = storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")}
+	case "file":
+		config.File = storage.FileConfig{Dir: t.TempDir()}
+	case "postgres":
+		host, port := backendAddress(t, backend, "POSTGRES", 5432)
+		config.Postgres = storage.PostgresConfig{
+			Host: host, Port: port,
+			Database: envOr("POSTGRES_DATABASE", "logs_test"),
+			Username: envOr("POSTGRES_USERNAME", "postgres"),
+			Password: envOr("POSTGRES_PASSWORD", "postgres"),
+			Schema:   "logs_conformance",
+		}
+	case "mysql":
+		host, port := backendAddress(t, backend, "MYSQL", 3306)
+		config.MySQL = storage.MySQLConfig{
+			Host: host, Port: port,
+			Database: envOr("MYSQL_DATABASE", "logs_test"),
+			Username: envOr("MYSQL_USERNAME", "root"),
+			Password: envOr("MYSQL_PASSWORD", "root"),
+		}
+	case "clickhouse":
+		host, port := backendAddress(t, backend, "CLICKHOUSE", 9000)
+		config.ClickHouse = storage.ClickHouseConfig{
+			Host: host, Port: port,
+			Database: envOr("CLICKHOUSE_DATABASE", "logs_test"),
+			Username: envOr("CLICKHOUSE_USERNAME", "default"),
+			Password: os.Getenv("CLICKHOUSE_PASSWORD"),
+		}
+	}
+
+	var store storage.Storage
+	err := storagetest.Retry(90*time.Second, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var err error
+		store, err = storage.New(ctx, config)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("cannot open %s storage: %v", backend, err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// backendAddress 返回外部数据库的地址：设置了 <PREFIX>_HOST 时使用环境变量，否则启动容器
+func backendAddress(t *testing.T, backend, prefix string, defaultPort int) (string, int) {
+	if host := os.Getenv(prefix + "_HOST"); host != "" {
+		port, err := strconv.Atoi(envOr(prefix+"_PORT", strconv.Itoa(defaultPort)))
+		if err != nil {
+			t.Fatalf("invalid %s_PORT: %v", prefix, err)
+		}
+		return host, port
+	}
+	return storagetest.Containers[backend].Start(t)
+}
+
+func envOr(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
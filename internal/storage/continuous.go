@@ -0,0 +1,781 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/sketch"
+)
+
+// ContinuousQuerier 支持读取持续聚合结果的存储
+type ContinuousQuerier interface {
+	QueryAggregate(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error)
+}
+
+// continuousQueries 为不支持物化视图的后端在写入时增量维护聚合侧表
+type continuousQueries struct {
+	db      *sql.DB
+	dialect string // sqlite 或 mysql
+}
+
+// newContinuousQueries 创建持续聚合引擎
+func newContinuousQueries(db *sql.DB, dialect string) *continuousQueries {
+	return &continuousQueries{db: db, dialect: dialect}
+}
+
+// aggregateTableName 返回聚合侧表名
+func aggregateTableName(project, table, name string) string {
+	return fmt.Sprintf("cq_%s_%s_%s", project, table, name)
+}
+
+// rollupTableName 返回 rollup 汇总表名
+func rollupTableName(project, table, name string) string {
+	return fmt.Sprintf("rollup_%s_%s_%s", project, table, name)
+}
+
+// summaryTable 持续聚合侧表或 rollup 汇总表，两者结构相同
+type summaryTable struct {
+	name string // 未加引号的表名
+	agg  *models.Aggregate
+}
+
+// summaryTables 返回 schema 的所有持续聚合侧表与 rollup 汇总表
+func summaryTables(schema *models.Schema) []summaryTable {
+	tables := make([]summaryTable, 0, len(schema.Aggregates)+len(schema.Rollups))
+	for _, agg := range schema.Aggregates {
+		tables = append(tables, summaryTable{aggregateTableName(schema.Project, schema.Table, agg.Name), agg})
+	}
+	for _, rollup := range schema.Rollups {
+		tables = append(tables, summaryTable{rollupTableName(schema.Project, schema.Table, rollup.Name), rollup})
+	}
+	return tables
+}
+
+// table 返回聚合侧表的引用标识符
+func (cq *continuousQueries) table(schema *models.Schema, name string) string {
+	return quoteIdent(cq.dialect, aggregateTableName(schema.Project, schema.Table, name))
+}
+
+// rollupTable 返回 rollup 汇总表的引用标识符
+func (cq *continuousQueries) rollupTable(schema *models.Schema, name string) string {
+	return quoteIdent(cq.dialect, rollupTableName(schema.Project, schema.Table, name))
+}
+
+// createTables 为 schema 中声明的所有聚合与 rollup 创建表
+func (cq *continuousQueries) createTables(ctx context.Context, schema *models.Schema) error {
+	for _, summary := range summaryTables(schema) {
+		agg := summary.agg
+		keyType, numType, sketchType := "TEXT", "REAL", "TEXT"
+		if cq.dialect == "mysql" {
+			keyType, numType, sketchType = "VARCHAR(255)", "DOUBLE", "MEDIUMTEXT"
+		}
+
+		columns := []string{"bucket TIMESTAMP NOT NULL"}
+		keys := []string{"bucket"}
+		for _, name := range agg.GroupBy {
+			// 分组列沿用字段名，可能是保留字
+			columns = append(columns, fmt.Sprintf("%s %s NOT NULL DEFAULT ''", quoteIdent(cq.dialect, name), keyType))
+			keys = append(keys, quoteIdent(cq.dialect, name))
+		}
+		columns = append(columns, "count BIGINT NOT NULL DEFAULT 0")
+		for _, metric := range agg.Metrics {
+			switch metric.Func {
+			case models.AggregateCount:
+				continue
+			case models.AggregateAvg:
+				columns = append(columns,
+					fmt.Sprintf("sum_%s %s", metric.Field, numType),
+					fmt.Sprintf("count_%s BIGINT NOT NULL DEFAULT 0", metric.Field))
+			case models.AggregateStddev:
+				columns = append(columns,
+					fmt.Sprintf("sum_%s %s", metric.Field, numType),
+					fmt.Sprintf("count_%s BIGINT NOT NULL DEFAULT 0", metric.Field),
+					fmt.Sprintf("sumsq_%s %s", metric.Field, numType))
+			default:
+				if _, ok := metric.Func.Quantile(); ok {
+					// 同一字段的各百分位数共用一个分位数草图
+					columns = append(columns, fmt.Sprintf("sketch_%s %s", metric.Field, sketchType))
+					continue
+				}
+				columns = append(columns, fmt.Sprintf("%s %s", metric.Column(), numType))
+			}
+		}
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keys, ", ")))
+
+		query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n)",
+			quoteIdent(cq.dialect, summary.name),
+			strings.Join(dedupColumns(columns), ",\n"))
+		if _, err := cq.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("创建聚合表失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// dropTables 删除 schema 的所有聚合侧表与 rollup 汇总表
+func (cq *continuousQueries) dropTables(ctx context.Context, tx *sql.Tx, schema *models.Schema) error {
+	for _, summary := range summaryTables(schema) {
+		query := "DROP TABLE IF EXISTS " + quoteIdent(cq.dialect, summary.name)
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("删除聚合表失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// partial 一个时间桶内的部分聚合结果
+type partial struct {
+	bucket time.Time
+	groups []string
+	count  int64
+	sums   map[string]float64
+	counts map[string]int64
+	mins   map[string]float64
+	maxs   map[string]float64
+	sumsqs map[string]float64
+	// sketches 有百分位指标的字段的分位数草图
+	sketches map[string]*sketch.Sketch
+}
+
+// apply 将一批日志合并到聚合侧表，与日志写入处于同一事务
+func (cq *continuousQueries) apply(ctx context.Context, tx *sql.Tx, schema *models.Schema, logs []*models.LogEntry) error {
+	for _, agg := range schema.Aggregates {
+		if err := cq.merge(ctx, tx, cq.table(schema, agg.Name), agg, logs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge 按 agg 的时间桶与分组计算一批日志的部分聚合结果，并合并到 tableName
+func (cq *continuousQueries) merge(ctx context.Context, tx *sql.Tx, tableName string, agg *models.Aggregate, logs []*models.LogEntry) error {
+	size, err := agg.BucketSize()
+	if err != nil {
+		return err
+	}
+
+	sketched := make(map[string]bool)
+	for _, metric := range agg.Metrics {
+		if _, ok := metric.Func.Quantile(); ok {
+			sketched[metric.Field] = true
+		}
+	}
+
+	partials := make(map[string]*partial)
+	var order []string
+	for _, log := range logs {
+		bucket := log.Timestamp.UTC().Truncate(size)
+		groups := make([]string, len(agg.GroupBy))
+		for i, name := range agg.GroupBy {
+			groups[i] = groupValue(log, name)
+		}
+
+		key := bucket.Format(time.RFC3339Nano) + "\x00" + strings.Join(groups, "\x00")
+		p, ok := partials[key]
+		if !ok {
+			p = &partial{
+				bucket:   bucket,
+				groups:   groups,
+				sums:     make(map[string]float64),
+				counts:   make(map[string]int64),
+				mins:     make(map[string]float64),
+				maxs:     make(map[string]float64),
+				sumsqs:   make(map[string]float64),
+				sketches: make(map[string]*sketch.Sketch),
+			}
+			partials[key] = p
+			order = append(order, key)
+		}
+		p.count++
+
+		// 同一字段可能有多个指标，每条日志只累计一次
+		seen := make(map[string]bool)
+		for _, metric := range agg.Metrics {
+			if metric.Func == models.AggregateCount || seen[metric.Field] {
+				continue
+			}
+			seen[metric.Field] = true
+			v, ok := numericValue(log.Fields[metric.Field])
+			if !ok {
+				continue
+			}
+			p.sums[metric.Field] += v
+			p.sumsqs[metric.Field] += v * v
+			p.counts[metric.Field]++
+			if sketched[metric.Field] {
+				if p.sketches[metric.Field] == nil {
+					p.sketches[metric.Field] = sketch.New()
+				}
+				p.sketches[metric.Field].Add(v)
+			}
+			if cur, ok := p.mins[metric.Field]; !ok || v < cur {
+				p.mins[metric.Field] = v
+			}
+			if cur, ok := p.maxs[metric.Field]; !ok || v > cur {
+				p.maxs[metric.Field] = v
+			}
+		}
+	}
+
+	for _, key := range order {
+		if err := cq.upsert(ctx, tx, tableName, agg, partials[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upsert 合并单个时间桶的部分聚合结果
+func (cq *continuousQueries) upsert(ctx context.Context, tx *sql.Tx, tableName string, agg *models.Aggregate, p *partial) error {
+	columns := []string{"bucket"}
+	values := []interface{}{p.bucket}
+	for i, name := range agg.GroupBy {
+		columns = append(columns, quoteIdent(cq.dialect, name))
+		values = append(values, p.groups[i])
+	}
+	columns = append(columns, "count")
+	values = append(values, p.count)
+
+	var updates []string
+	updates = append(updates, cq.mergeExpr("count", "%[1]s + %[2]s"))
+
+	seen := map[string]bool{"count": true}
+	add := func(column string, value interface{}, expr string) {
+		if seen[column] {
+			return
+		}
+		seen[column] = true
+		columns = append(columns, column)
+		values = append(values, value)
+		updates = append(updates, cq.mergeExpr(column, expr))
+	}
+
+	for _, metric := range agg.Metrics {
+		field := metric.Field
+		if metric.Func == models.AggregateCount || p.counts[field] == 0 {
+			continue
+		}
+		switch metric.Func {
+		case models.AggregateSum:
+			add(metric.Column(), p.sums[field], "COALESCE(%[1]s, 0) + %[2]s")
+		case models.AggregateAvg:
+			add("sum_"+field, p.sums[field], "COALESCE(%[1]s, 0) + %[2]s")
+			add("count_"+field, p.counts[field], "%[1]s + %[2]s")
+		case models.AggregateMin:
+			add(metric.Column(), p.mins[field], cq.least()+"(COALESCE(%[1]s, %[2]s), %[2]s)")
+		case models.AggregateMax:
+			add(metric.Column(), p.maxs[field], cq.greatest()+"(COALESCE(%[1]s, %[2]s), %[2]s)")
+		case models.AggregateStddev:
+			add("sum_"+field, p.sums[field], "COALESCE(%[1]s, 0) + %[2]s")
+			add("count_"+field, p.counts[field], "%[1]s + %[2]s")
+			add("sumsq_"+field, p.sumsqs[field], "COALESCE(%[1]s, 0) + %[2]s")
+		}
+	}
+
+	placeholders := make([]string, len(columns))
+	for i := range placeholders {
+		placeholders[i] = "?"
+	}
+
+	var query string
+	if cq.dialect == "mysql" {
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+			tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", "), strings.Join(updates, ", "))
+	} else {
+		keys := "bucket"
+		if len(agg.GroupBy) > 0 {
+			keys += ", " + quoteIdents(cq.dialect, agg.GroupBy)
+		}
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT(%s) DO UPDATE SET %s",
+			tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", "),
+			keys, strings.Join(updates, ", "))
+	}
+
+	if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("更新聚合表失败: %w", err)
+	}
+	return cq.mergeSketches(ctx, tx, tableName, agg, p)
+}
+
+// mergeSketches 将部分结果的分位数草图合并到时间桶已保存的草图。草图不能在 SQL 中合并，
+// upsert 已锁定该行，读取后在 Go 中合并再写回
+func (cq *continuousQueries) mergeSketches(ctx context.Context, tx *sql.Tx, tableName string, agg *models.Aggregate, p *partial) error {
+	if len(p.sketches) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(p.sketches))
+	for field := range p.sketches {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	conditions := []string{"bucket = ?"}
+	args := []interface{}{p.bucket}
+	for i, name := range agg.GroupBy {
+		conditions = append(conditions, quoteIdent(cq.dialect, name)+" = ?")
+		args = append(args, p.groups[i])
+	}
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = "sketch_" + field
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(columns, ", "), tableName, strings.Join(conditions, " AND "))
+	if cq.dialect == "mysql" {
+		query += " FOR UPDATE"
+	}
+	stored := make([]sql.NullString, len(fields))
+	dest := make([]interface{}, len(fields))
+	for i := range stored {
+		dest[i] = &stored[i]
+	}
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(dest...); err != nil {
+		return fmt.Errorf("读取分位数草图失败: %w", err)
+	}
+
+	sets := make([]string, len(fields))
+	values := make([]interface{}, 0, len(fields)+len(args))
+	for i, field := range fields {
+		merged, err := decodeSketch(stored[i])
+		if err != nil {
+			return err
+		}
+		merged.Merge(p.sketches[field])
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return err
+		}
+		sets[i] = columns[i] + " = ?"
+		values = append(values, string(data))
+	}
+	values = append(values, args...)
+	query = fmt.Sprintf("UPDATE %s SET %s WHERE %s", tableName, strings.Join(sets, ", "), strings.Join(conditions, " AND "))
+	if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("更新分位数草图失败: %w", err)
+	}
+	return nil
+}
+
+// decodeSketch 解析侧表保存的分位数草图，NULL 或空字符串返回空草图
+func decodeSketch(stored sql.NullString) (*sketch.Sketch, error) {
+	s := sketch.New()
+	if stored.Valid && stored.String != "" {
+		if err := json.Unmarshal([]byte(stored.String), s); err != nil {
+			return nil, fmt.Errorf("解析分位数草图失败: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// isSummaryTable 判断表名是否为持续聚合侧表或 rollup 汇总表
+func isSummaryTable(name string) bool {
+	return strings.HasPrefix(name, "cq_") || strings.HasPrefix(name, "rollup_")
+}
+
+// summaryMergeExpr 按列名返回合并两行汇总结果的表达式，%[1]s 为现有值，%[2]s 为另一行的值，可能为 NULL。
+// count、sum_、count_、sumsq_ 相加，min_、max_ 取极值，其他列（分组列、分位数草图）返回空字符串
+func (cq *continuousQueries) summaryMergeExpr(column string) string {
+	switch {
+	case column == "count", strings.HasPrefix(column, "count_"):
+		return "%[1]s + %[2]s"
+	case strings.HasPrefix(column, "sum_"), strings.HasPrefix(column, "sumsq_"):
+		return "COALESCE(%[1]s + %[2]s, %[1]s, %[2]s)"
+	case strings.HasPrefix(column, "min_"):
+		return "COALESCE(" + cq.least() + "(%[1]s, %[2]s), %[1]s, %[2]s)"
+	case strings.HasPrefix(column, "max_"):
+		return "COALESCE(" + cq.greatest() + "(%[1]s, %[2]s), %[1]s, %[2]s)"
+	}
+	return ""
+}
+
+// mergeExpr 生成 upsert 的合并表达式，%[1]s 为现有值，%[2]s 为新值
+func (cq *continuousQueries) mergeExpr(column, expr string) string {
+	incoming := "excluded." + column
+	if cq.dialect == "mysql" {
+		incoming = "VALUES(" + column + ")"
+	}
+	return fmt.Sprintf("%s = %s", column, fmt.Sprintf(expr, column, incoming))
+}
+
+func (cq *continuousQueries) least() string {
+	if cq.dialect == "mysql" {
+		return "LEAST"
+	}
+	return "MIN"
+}
+
+func (cq *continuousQueries) greatest() string {
+	if cq.dialect == "mysql" {
+		return "GREATEST"
+	}
+	return "MAX"
+}
+
+// query 在 db 上读取聚合结果（可以是只读副本），avg 在读取时由 sum/count 计算
+func (cq *continuousQueries) query(ctx context.Context, db *sql.DB, schema *models.Schema, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	agg, ok := schema.GetAggregate(name)
+	if !ok {
+		return nil, fmt.Errorf("aggregate not found: %s", name)
+	}
+	return cq.queryTable(ctx, db, cq.table(schema, name), agg, from, to)
+}
+
+// queryRollup 在 db 上读取 rollup 汇总结果
+func (cq *continuousQueries) queryRollup(ctx context.Context, db *sql.DB, schema *models.Schema, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	rollup, ok := schema.GetRollup(name)
+	if !ok {
+		return nil, fmt.Errorf("rollup not found: %s", name)
+	}
+	return cq.queryTable(ctx, db, cq.rollupTable(schema, name), rollup, from, to)
+}
+
+// queryTable 读取 tableName 中 bucket 位于 [from, to) 的聚合结果，零值表示不限制
+func (cq *continuousQueries) queryTable(ctx context.Context, db *sql.DB, tableName string, agg *models.Aggregate, from, to time.Time) ([]map[string]interface{}, error) {
+	selects := []string{"bucket"}
+	for _, name := range agg.GroupBy {
+		selects = append(selects, quoteIdent(cq.dialect, name))
+	}
+	selects = append(selects, "count")
+	for _, metric := range agg.Metrics {
+		switch metric.Func {
+		case models.AggregateCount:
+		case models.AggregateAvg:
+			selects = append(selects, fmt.Sprintf("CASE WHEN count_%[1]s > 0 THEN sum_%[1]s / count_%[1]s END AS avg_%[1]s", metric.Field))
+		case models.AggregateStddev:
+			selects = append(selects, "sum_"+metric.Field, "count_"+metric.Field, "sumsq_"+metric.Field)
+		default:
+			if _, ok := metric.Func.Quantile(); ok {
+				selects = append(selects, "sketch_"+metric.Field)
+				continue
+			}
+			selects = append(selects, metric.Column())
+		}
+	}
+
+	var conditions []string
+	var args []interface{}
+	if !from.IsZero() {
+		conditions = append(conditions, "bucket >= ?")
+		args = append(args, from.UTC())
+	}
+	if !to.IsZero() {
+		conditions = append(conditions, "bucket < ?")
+		args = append(args, to.UTC())
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(dedupColumns(selects), ", "), tableName)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY bucket"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询聚合失败: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := finishSummaryRows(agg, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// finishSummaryRows 由侧表保存的中间值计算标准差与百分位数，并移除未作为指标查询的中间列
+func finishSummaryRows(agg *models.Aggregate, rows []map[string]interface{}) error {
+	keep := map[string]bool{"bucket": true, "count": true}
+	for _, name := range agg.GroupBy {
+		keep[name] = true
+	}
+	for _, metric := range agg.Metrics {
+		keep[metric.Column()] = true
+	}
+
+	for _, row := range rows {
+		sketches := make(map[string]*sketch.Sketch)
+		for _, metric := range agg.Metrics {
+			field := metric.Field
+			switch metric.Func {
+			case models.AggregateStddev:
+				sum, _ := numericValue(row["sum_"+field])
+				count, _ := numericValue(row["count_"+field])
+				sumsq, _ := numericValue(row["sumsq_"+field])
+				if count > 1 {
+					// 浮点误差可能使方差略小于 0
+					row[metric.Column()] = math.Sqrt(math.Max((sumsq-sum*sum/count)/(count-1), 0))
+				}
+			default:
+				q, ok := metric.Func.Quantile()
+				if !ok {
+					continue
+				}
+				s, ok := sketches[field]
+				if !ok {
+					s = sketch.New()
+					if data, _ := row["sketch_"+field].(string); data != "" {
+						if err := json.Unmarshal([]byte(data), s); err != nil {
+							return err
+						}
+					}
+					sketches[field] = s
+				}
+				if value, ok := s.Quantile(q); ok {
+					row[metric.Column()] = value
+				}
+			}
+		}
+		for column := range row {
+			if !keep[column] {
+				delete(row, column)
+			}
+		}
+	}
+	return nil
+}
+
+// Rebucket 将持续聚合或 rollup 的查询结果按 loc 的当地时间合并到 size 大小的时间桶，size 需为 agg 时间桶的整数倍。
+// count、sum 相加，min、max 取极值，avg 按各桶的 count 加权；stddev 与百分位数无法合并，
+// 新时间桶包含多个原时间桶时返回 ErrValidation。原时间桶跨越两个新时间桶时也返回 ErrValidation
+func Rebucket(rows []map[string]interface{}, agg *models.Aggregate, size time.Duration, loc *time.Location) ([]map[string]interface{}, error) {
+	stored, err := agg.BucketSize()
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 || size%stored != 0 {
+		return nil, fmt.Errorf("%w: interval %s is not a multiple of the %s buckets of %s", models.ErrValidation, size, agg.Interval, agg.Name)
+	}
+
+	var unmergeable string
+	for _, metric := range agg.Metrics {
+		if !metric.Func.Mergeable() {
+			unmergeable = metric.Column()
+			break
+		}
+	}
+
+	type merged struct {
+		row     map[string]interface{}
+		sources int // 合并的原时间桶行数
+		count   float64
+		values  map[string]float64
+		weights map[string]float64 // avg 的权重，即参与平均的各桶 count 之和
+	}
+	var order []*merged
+	groups := make(map[string]*merged)
+	for _, row := range rows {
+		bucket, err := bucketTime(row["bucket"])
+		if err != nil {
+			return nil, err
+		}
+		start := models.BucketStart(bucket, size, loc)
+		if bucket.Add(stored).After(start.Add(size)) {
+			return nil, fmt.Errorf("%w: the %s buckets of %s cannot be aligned to %s in %s",
+				models.ErrValidation, agg.Interval, agg.Name, size, loc)
+		}
+
+		key := start.UTC().Format(time.RFC3339Nano)
+		for _, name := range agg.GroupBy {
+			key += "\x00" + fmt.Sprint(row[name])
+		}
+		m, ok := groups[key]
+		if !ok {
+			m = &merged{
+				row:     map[string]interface{}{"bucket": start},
+				values:  make(map[string]float64),
+				weights: make(map[string]float64),
+			}
+			for _, name := range agg.GroupBy {
+				m.row[name] = row[name]
+			}
+			groups[key] = m
+			order = append(order, m)
+		}
+
+		if m.sources++; m.sources > 1 && unmergeable != "" {
+			return nil, fmt.Errorf("%w: %s of %s cannot be merged into %s buckets, query without interval",
+				models.ErrValidation, unmergeable, agg.Name, size)
+		}
+		count, _ := numericValue(row["count"])
+		m.count += count
+		seen := make(map[string]bool)
+		for _, metric := range agg.Metrics {
+			column := metric.Column()
+			if metric.Func == models.AggregateCount || seen[column] {
+				continue
+			}
+			seen[column] = true
+			value, ok := numericValue(row[column])
+			if !ok {
+				continue
+			}
+			current, exists := m.values[column]
+			if !metric.Func.Mergeable() {
+				m.values[column] = value
+				continue
+			}
+			switch metric.Func {
+			case models.AggregateSum:
+				m.values[column] = current + value
+			case models.AggregateMin:
+				if !exists || value < current {
+					m.values[column] = value
+				}
+			case models.AggregateMax:
+				if !exists || value > current {
+					m.values[column] = value
+				}
+			case models.AggregateAvg:
+				m.values[column] = current + value*count
+				m.weights[column] += count
+			}
+		}
+	}
+
+	result := make([]map[string]interface{}, 0, len(order))
+	for _, m := range order {
+		m.row["count"] = int64(m.count)
+		for column, value := range m.values {
+			if weight, ok := m.weights[column]; ok {
+				if weight == 0 {
+					continue
+				}
+				value /= weight
+			}
+			m.row[column] = value
+		}
+		result = append(result, m.row)
+	}
+	return result, nil
+}
+
+// ShiftBuckets 将持续聚合或 rollup 结果各行的时间桶按 shift 平移，用于将对比窗口的结果与当前窗口对齐
+func ShiftBuckets(rows []map[string]interface{}, shift func(time.Time) time.Time) error {
+	for _, row := range rows {
+		bucket, err := bucketTime(row["bucket"])
+		if err != nil {
+			return err
+		}
+		row["bucket"] = shift(bucket)
+	}
+	return nil
+}
+
+// bucketTime 解析查询结果中的 bucket 列，部分驱动以文本返回时间
+func bucketTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间桶: %v", value)
+}
+
+// scanRows 将查询结果转换为 map 列表
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("获取列名失败: %w", err)
+	}
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("扫描行失败: %w", err)
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[col] = string(b)
+			} else if values[i] != nil {
+				row[col] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %w", err)
+	}
+	return result, nil
+}
+
+// dedupColumns 去除重复的列定义，保持原有顺序
+func dedupColumns(columns []string) []string {
+	seen := make(map[string]bool, len(columns))
+	result := make([]string, 0, len(columns))
+	for _, col := range columns {
+		name := strings.Fields(col)[0]
+		if strings.HasPrefix(col, "PRIMARY KEY") || strings.HasPrefix(col, "CASE ") {
+			name = col
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, col)
+	}
+	return result
+}
+
+// groupValue 获取分组字段的值
+func groupValue(log *models.LogEntry, name string) string {
+	if name == "level" && log.Level != "" {
+		return log.Level
+	}
+	if v, ok := log.Fields[name]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// numericValue 将字段值转换为 float64
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case time.Duration:
+		return float64(v), true
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return float64(d), true
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestSQLiteContinuousQueries(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "path", Type: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeFloat},
+		},
+		SchemaOptions: models.SchemaOptions{
+			Aggregates: []*models.Aggregate{
+				{
+					Name:     "per_minute",
+					Interval: "1m",
+					GroupBy:  []string{"path"},
+					Metrics: []*models.AggregateMetric{
+						{Func: models.AggregateCount},
+						{Func: models.AggregateAvg, Field: "latency"},
+						{Func: models.AggregateMax, Field: "latency"},
+					},
+				},
+			},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	loaded, err := store.GetSchema(ctx, "app", "requests")
+	require.NoError(t, err)
+	require.Len(t, loaded.Aggregates, 1)
+
+	base := time.Date(2024, 3, 14, 10, 0, 0, 0, time.UTC)
+	newLog := func(offset time.Duration, path string, latency float64) *models.LogEntry {
+		return &models.LogEntry{
+			Project:   "app",
+			Table:     "requests",
+			Level:     "info",
+			Message:   "request",
+			Timestamp: base.Add(offset),
+			Fields:    map[string]interface{}{"path": path, "latency": latency},
+		}
+	}
+
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", []*models.LogEntry{
+		newLog(time.Second, "/a", 10),
+		newLog(2*time.Second, "/a", 30),
+		newLog(3*time.Second, "/b", 5),
+	}))
+	// 第二批写入同一个时间桶，验证增量合并
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", []*models.LogEntry{
+		newLog(4*time.Second, "/a", 50),
+		newLog(time.Minute+time.Second, "/a", 1),
+	}))
+
+	rows, err := store.QueryAggregate(ctx, "app", "requests", "per_minute", base, base.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	byPath := make(map[string]map[string]interface{})
+	for _, row := range rows {
+		byPath[row["path"].(string)] = row
+	}
+	assert.EqualValues(t, 3, byPath["/a"]["count"])
+	assert.InDelta(t, 30.0, byPath["/a"]["avg_latency"], 0.0001)
+	assert.InDelta(t, 50.0, byPath["/a"]["max_latency"], 0.0001)
+	assert.EqualValues(t, 1, byPath["/b"]["count"])
+
+	all, err := store.QueryAggregate(ctx, "app", "requests", "per_minute", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+}
+
+func TestRebucket(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	agg := &models.Aggregate{
+		Name:     "hourly",
+		Interval: "1h",
+		GroupBy:  []string{"path"},
+		Metrics: []*models.AggregateMetric{
+			{Func: models.AggregateSum, Field: "latency"},
+			{Func: models.AggregateMin, Field: "latency"},
+			{Func: models.AggregateAvg, Field: "latency"},
+		},
+	}
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "path", Type: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeFloat},
+		},
+		SchemaOptions: models.SchemaOptions{Aggregates: []*models.Aggregate{agg}},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	// 客户端以上海时间发送，存储统一为 UTC
+	shanghai := time.FixedZone("CST", 8*3600)
+	newLog := func(at time.Time, latency float64) *models.LogEntry {
+		return &models.LogEntry{
+			Project: "app", Table: "requests", Level: "info", Message: "request", Timestamp: at,
+			Fields: map[string]interface{}{"path": "/a", "latency": latency},
+		}
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", []*models.LogEntry{
+		newLog(time.Date(2024, 3, 14, 9, 0, 0, 0, shanghai), 10),
+		newLog(time.Date(2024, 3, 14, 23, 30, 0, 0, shanghai), 20),
+		newLog(time.Date(2024, 3, 14, 23, 40, 0, 0, shanghai), 60),
+		newLog(time.Date(2024, 3, 15, 0, 30, 0, 0, shanghai), 40),
+	}))
+
+	from := time.Date(2024, 3, 14, 15, 0, 0, 0, time.UTC)
+	logs, err := store.SearchLogs(ctx, "app", "requests", &models.Query{From: &from, Fields: []string{"latency"}})
+	require.NoError(t, err)
+	assert.Len(t, logs, 3, "time ranges compare UTC values")
+
+	rows, err := store.QueryAggregate(ctx, "app", "requests", "hourly", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	// UTC 下四条日志都在 3 月 14 日，上海时间则分属两天
+	days, err := Rebucket(rows, agg, 24*time.Hour, time.UTC)
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	assert.EqualValues(t, 4, days[0]["count"])
+
+	days, err = Rebucket(rows, agg, 24*time.Hour, shanghai)
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+	assert.Equal(t, time.Date(2024, 3, 14, 0, 0, 0, 0, shanghai), days[0]["bucket"])
+	assert.Equal(t, "/a", days[0]["path"])
+	assert.EqualValues(t, 3, days[0]["count"])
+	assert.InDelta(t, 90, days[0]["sum_latency"], 1e-9)
+	assert.InDelta(t, 10, days[0]["min_latency"], 1e-9)
+	assert.InDelta(t, 30, days[0]["avg_latency"], 1e-9)
+	assert.EqualValues(t, 1, days[1]["count"])
+	assert.InDelta(t, 40, days[1]["avg_latency"], 1e-9)
+
+	_, err = Rebucket(rows, agg, time.Hour, time.FixedZone("IST", 5*3600+1800))
+	assert.ErrorIs(t, err, models.ErrValidation, "hourly buckets straddle half-hour offsets")
+	_, err = Rebucket(rows, agg, 90*time.Minute, time.UTC)
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestSQLiteStatisticalAggregates(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	agg := &models.Aggregate{
+		Name:     "latency",
+		Interval: "1h",
+		Metrics: []*models.AggregateMetric{
+			{Func: models.AggregateP50, Field: "latency"},
+			{Func: models.AggregateP99, Field: "latency"},
+			{Func: models.AggregateStddev, Field: "latency"},
+			{Func: models.AggregateMax, Field: "latency"},
+		},
+	}
+	schema := &models.Schema{
+		Project:       "app",
+		Table:         "requests",
+		Fields:        []*models.Field{{Name: "latency", Type: models.FieldTypeDuration}},
+		SchemaOptions: models.SchemaOptions{Aggregates: []*models.Aggregate{agg}},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	base := time.Date(2024, 3, 14, 10, 0, 0, 0, time.UTC)
+	// 1ms 到 100ms 分两批写入同一时间桶，验证草图的增量合并
+	for batch := 0; batch < 2; batch++ {
+		var logs []*models.LogEntry
+		for i := 1 + batch; i <= 100; i += 2 {
+			logs = append(logs, &models.LogEntry{
+				Project: "app", Table: "requests", Level: "info", Message: "request",
+				Timestamp: base.Add(time.Duration(i) * time.Second),
+				Fields:    map[string]interface{}{"latency": time.Duration(i) * time.Millisecond},
+			})
+		}
+		require.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", logs))
+	}
+	require.NoError(t, store.InsertLog(ctx, "app", "requests", &models.LogEntry{
+		Project: "app", Table: "requests", Level: "info", Message: "request",
+		Timestamp: base.Add(time.Hour), Fields: map[string]interface{}{"latency": time.Millisecond},
+	}))
+
+	rows, err := store.QueryAggregate(ctx, "app", "requests", "latency", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	row := rows[0]
+	assert.EqualValues(t, 100, row["count"])
+	ms := float64(time.Millisecond)
+	assert.InEpsilon(t, 50.5*ms, row["p50_latency"], 0.02)
+	assert.InEpsilon(t, 99*ms, row["p99_latency"], 0.02)
+	assert.InDelta(t, 29.011*ms, row["stddev_latency"], 0.001*ms, "sample standard deviation")
+	assert.InDelta(t, 100*ms, row["max_latency"], 1e-6)
+	for _, column := range []string{"sketch_latency", "sum_latency", "count_latency", "sumsq_latency"} {
+		assert.NotContains(t, row, column, "intermediate columns are not returned")
+	}
+	// 只有一条日志的时间桶没有样本标准差
+	assert.NotContains(t, rows[1], "stddev_latency")
+	assert.InEpsilon(t, ms, rows[1]["p50_latency"], 0.02)
+
+	// 标准差与百分位数只能原样按时区重新对齐，不能合并多个时间桶
+	same, err := Rebucket(rows, agg, time.Hour, time.FixedZone("CST", 8*3600))
+	require.NoError(t, err)
+	assert.Equal(t, row["p99_latency"], same[0]["p99_latency"])
+	_, err = Rebucket(rows, agg, 2*time.Hour, time.UTC)
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestPostgresAggregateQuery(t *testing.T) {
+	agg := &models.Aggregate{
+		Name:     "latency",
+		Interval: "1m",
+		GroupBy:  []string{"path", "level"},
+		Metrics: []*models.AggregateMetric{
+			{Func: models.AggregateCount},
+			{Func: models.AggregateAvg, Field: "latency"},
+			{Func: models.AggregateP90, Field: "latency"},
+			{Func: models.AggregateStddev, Field: "latency"},
+		},
+	}
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "path", Type: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeFloat},
+		},
+		SchemaOptions: models.SchemaOptions{Aggregates: []*models.Aggregate{agg}},
+	}
+	require.NoError(t, schema.Validate())
+
+	from := time.Date(2024, 3, 14, 10, 0, 30, 0, time.UTC)
+	to := time.Date(2024, 3, 14, 11, 0, 0, 0, time.UTC)
+	query, args, err := postgresAggregateQuery(`"logs"."app_requests"`, schema, agg, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT to_timestamp(floor((CAST(extract(epoch FROM timestamp) AS NUMERIC) + 62135596800) / 60) * 60 - 62135596800) AS bucket, "+
+		`COALESCE(CAST("path" AS TEXT), '') AS "path", '' AS "level", COUNT(*) AS count, `+
+		`AVG(CAST("latency" AS DOUBLE PRECISION)) AS "avg_latency", `+
+		`percentile_cont(0.9) WITHIN GROUP (ORDER BY CAST("latency" AS DOUBLE PRECISION)) AS "p90_latency", `+
+		`stddev_samp(CAST("latency" AS DOUBLE PRECISION)) AS "stddev_latency" `+
+		`FROM "logs"."app_requests" WHERE timestamp >= $1 AND timestamp < $2 GROUP BY 1, 2, 3 ORDER BY 1, 2, 3`, query)
+	// 与侧表一样按时间桶起点筛选，from 所在的时间桶不完整，不计入
+	assert.Equal(t, []interface{}{from.Truncate(time.Minute).Add(time.Minute), to}, args)
+}
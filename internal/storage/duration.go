@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// convertDurationColumn 将旧版本以文本保存的时长（Go 时长字符串或纳秒数字）逐行转换为纳秒写入 target 列，
+// source 与 target 可以是同一列。无法解析的值返回错误，不做部分迁移
+func convertDurationColumn(ctx context.Context, tx *sql.Tx, dialect, tableName, source, target string) error {
+	src := quoteIdent(dialect, source)
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT id, %s FROM %s WHERE %s IS NOT NULL", src, tableName, src))
+	if err != nil {
+		return fmt.Errorf("查询 duration 字段 %s 失败: %w", source, err)
+	}
+	type converted struct {
+		id    string
+		nanos int64
+	}
+	var values []converted
+	for rows.Next() {
+		var id, text string
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return fmt.Errorf("扫描 duration 字段 %s 失败: %w", source, err)
+		}
+		nanos, err := models.DurationNanos(text)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("日志 %s 的 duration 字段 %s 无法转换: %w", id, source, err)
+		}
+		values = append(values, converted{id, nanos})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("遍历结果失败: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("UPDATE %s SET %s = %s WHERE id = %s",
+		tableName, quoteIdent(dialect, target), placeholder(dialect, 1), placeholder(dialect, 2)))
+	if err != nil {
+		return fmt.Errorf("准备语句失败: %w", err)
+	}
+	defer stmt.Close()
+	for _, v := range values {
+		if _, err := stmt.ExecContext(ctx, v.nanos, v.id); err != nil {
+			return fmt.Errorf("转换 duration 字段 %s 失败: %w", source, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestSQLiteDurationNanos(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "seq", Type: models.FieldTypeInt},
+			{Name: "latency", Type: models.FieldTypeDuration},
+		},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var logs []*models.LogEntry
+	for i, latency := range []interface{}{1500 * time.Millisecond, "250ms", int64(time.Second)} {
+		logs = append(logs, &models.LogEntry{
+			Project: "app", Table: "requests", Level: "info", Message: "done", Timestamp: base,
+			Fields: map[string]interface{}{"seq": i, "latency": latency},
+		})
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", logs))
+
+	rows, err := store.SearchLogs(ctx, "app", "requests", &models.Query{Fields: []string{"latency"}, Sort: []string{"latency"}})
+	require.NoError(t, err)
+	var latencies []interface{}
+	for _, row := range rows {
+		latencies = append(latencies, row["latency"])
+	}
+	assert.Equal(t, []interface{}{int64(250 * time.Millisecond), int64(time.Second), int64(1500 * time.Millisecond)}, latencies)
+
+	// 过滤值可以是时长字符串
+	rows, err = store.SearchLogs(ctx, "app", "requests", &models.Query{Filter: map[string]interface{}{"latency": "1.5s"}, Fields: []string{"seq"}})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.EqualValues(t, 0, rows[0]["seq"])
+
+	_, err = store.SearchLogs(ctx, "app", "requests", &models.Query{Filter: map[string]interface{}{"latency": "soon"}})
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestSQLiteMigrateDurationColumns(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	// 旧版本以 TEXT 保存时长：API 写入的是纳秒数字，hook 写入的是时长字符串
+	for _, query := range []string{
+		`CREATE TABLE logs_app_requests (id TEXT PRIMARY KEY, project TEXT, table_name TEXT, timestamp TIMESTAMP, latency TEXT)`,
+		`CREATE INDEX idx_logs_app_requests_latency ON logs_app_requests (latency)`,
+		`INSERT INTO logs_app_requests (id, project, table_name, timestamp, latency) VALUES
+			('a', 'app', 'requests', '2024-01-02 03:04:05', '1500000000'),
+			('b', 'app', 'requests', '2024-01-02 03:04:06', '250ms'),
+			('c', 'app', 'requests', '2024-01-02 03:04:07', NULL)`,
+	} {
+		_, err := store.db.ExecContext(ctx, query)
+		require.NoError(t, err)
+	}
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "latency", Type: models.FieldTypeDuration, Indexed: true}},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	var columnType string
+	require.NoError(t, store.db.QueryRowContext(ctx, `SELECT type FROM pragma_table_info('logs_app_requests') WHERE name = 'latency'`).Scan(&columnType))
+	assert.Equal(t, "INTEGER", columnType)
+	var index int
+	require.NoError(t, store.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_logs_app_requests_latency'`).Scan(&index))
+	assert.Equal(t, 1, index)
+
+	rows, err := store.SearchLogs(ctx, "app", "requests", &models.Query{Fields: []string{"id", "latency"}, Sort: []string{"id"}})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, int64(1500*time.Millisecond), rows[0]["latency"])
+	assert.Equal(t, int64(250*time.Millisecond), rows[1]["latency"])
+	assert.Nil(t, rows[2]["latency"])
+
+	// 已迁移的表再次创建时不做任何事
+	require.NoError(t, store.CreateSchema(ctx, schema))
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrBackendUnavailable is returned when the storage backend cannot be reached
+// or is temporarily unable to serve the request
+var ErrBackendUnavailable = errors.New("storage backend unavailable")
+
+// unavailable 将连接类错误标记为 ErrBackendUnavailable，其余错误原样返回
+func unavailable(err error) error {
+	if err == nil || errors.Is(err, ErrBackendUnavailable) || !isConnectionError(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrBackendUnavailable, err)
+}
+
+// isConnectionError 判断错误是否由连接中断、拒绝连接、超时、连接池已关闭或数据库繁忙引起
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	// database/sql 未导出连接池关闭错误，只能按信息匹配
+	if strings.Contains(err.Error(), "sql: database is closed") {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code {
+		case sqlite3.ErrBusy, sqlite3.ErrLocked, sqlite3.ErrCantOpen:
+			return true
+		}
+	}
+	return false
+}
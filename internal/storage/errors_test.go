@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestUnavailable(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	err := unavailable(fmt.Errorf("dial: %w", refused))
+	assert.ErrorIs(t, err, ErrBackendUnavailable)
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+
+	plain := errors.New("syntax error")
+	assert.Same(t, plain, unavailable(plain))
+	assert.NoError(t, unavailable(nil))
+}
+
+func TestSQLiteTypedErrors(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+
+	_, err := store.GetSchema(ctx, "app", "missing")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+	assert.ErrorIs(t, store.DeleteSchema(ctx, "app", "missing"), models.ErrSchemaNotFound)
+	assert.ErrorIs(t, store.InsertLog(ctx, "app", "missing", &models.LogEntry{}), models.ErrSchemaNotFound)
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "service", Type: models.FieldTypeString, Required: true}},
+	}
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	err = store.InsertLog(ctx, "app", "requests", &models.LogEntry{
+		Project:   "app",
+		Table:     "requests",
+		Level:     "info",
+		Message:   "missing service",
+		Timestamp: time.Now(),
+		Fields:    map[string]interface{}{},
+	})
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.Contains(t, err.Error(), "缺少必填字段: service")
+
+	require.NoError(t, store.Close())
+	assert.ErrorIs(t, store.Ping(ctx), ErrBackendUnavailable)
+	_, err = store.GetSchema(ctx, "app", "requests")
+	assert.ErrorIs(t, err, ErrBackendUnavailable)
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// QueryExplainer 返回日志查询执行计划的可选能力，用于在不直接访问数据库的情况下排查慢查询
+type QueryExplainer interface {
+	// ExplainQuery 返回 SearchLogs 执行 query 时使用的 SQL 与执行计划，不返回日志。
+	// analyze 为 true 时实际执行查询并在计划中给出耗时与行数，不支持的后端返回校验错误
+	ExplainQuery(ctx context.Context, project, table string, query *models.Query, analyze bool) (*models.QueryPlan, error)
+}
+
+// explainPrefix 返回方言的 EXPLAIN 语句前缀
+func explainPrefix(dialect string, analyze bool) (string, error) {
+	switch dialect {
+	case "postgres":
+		if analyze {
+			return "EXPLAIN (ANALYZE, BUFFERS)", nil
+		}
+		return "EXPLAIN", nil
+	case "mysql":
+		if analyze {
+			return "EXPLAIN ANALYZE", nil
+		}
+		return "EXPLAIN FORMAT=TREE", nil
+	case "sqlite":
+		if !analyze {
+			return "EXPLAIN QUERY PLAN", nil
+		}
+	case "clickhouse":
+		if !analyze {
+			return "EXPLAIN indexes = 1", nil
+		}
+	}
+	return "", fmt.Errorf("%w: analyze is not supported by %s", models.ErrValidation, dialect)
+}
+
+// explainQuery 以 EXPLAIN 执行 searchLogs 使用的 SQL，返回执行计划
+func explainQuery(ctx context.Context, db *sql.DB, dialect, tableName string, schema *models.Schema, q *models.Query, analyze bool) (*models.QueryPlan, error) {
+	prefix, err := explainPrefix(dialect, analyze)
+	if err != nil {
+		return nil, err
+	}
+	query, values, err := searchQuery(dialect, tableName, schema, q)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = []interface{}{}
+	}
+
+	rows, err := db.QueryContext(ctx, prefix+" "+query, values...)
+	if err != nil {
+		return nil, fmt.Errorf("获取执行计划失败: %w", unavailable(err))
+	}
+	defer rows.Close()
+
+	var plan []string
+	if dialect == "sqlite" {
+		plan, err = sqlitePlan(rows)
+	} else {
+		plan, err = textPlan(rows)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.QueryPlan{
+		Project:  schema.Project,
+		Table:    schema.Table,
+		Dialect:  dialect,
+		SQL:      query,
+		Args:     values,
+		Explain:  prefix,
+		Plan:     plan,
+		Analyzed: analyze,
+	}, nil
+}
+
+// textPlan 读取每行一列文本的执行计划（PostgreSQL、ClickHouse、MySQL 的 TREE 格式），多行文本按换行拆分
+func textPlan(rows *sql.Rows) ([]string, error) {
+	plan := []string{}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("读取执行计划失败: %w", err)
+		}
+		plan = append(plan, strings.Split(strings.TrimRight(line, "\n"), "\n")...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取执行计划失败: %w", unavailable(err))
+	}
+	return plan, nil
+}
+
+// sqlitePlan 读取 EXPLAIN QUERY PLAN 的 id、parent、notused、detail 列，按 parent 缩进 detail
+func sqlitePlan(rows *sql.Rows) ([]string, error) {
+	plan := []string{}
+	depth := make(map[int64]int)
+	for rows.Next() {
+		var id, parent, notUsed int64
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return nil, fmt.Errorf("读取执行计划失败: %w", err)
+		}
+		level := 0
+		if d, ok := depth[parent]; ok {
+			level = d + 1
+		}
+		depth[id] = level
+		plan = append(plan, strings.Repeat("  ", level)+detail)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取执行计划失败: %w", unavailable(err))
+	}
+	return plan, nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestSQLiteExplainQuery(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "trace_id", Type: models.FieldTypeString, Indexed: true},
+			{Name: "path", Type: models.FieldTypeString},
+		},
+	}))
+
+	// 经过缓存包装后仍可通过 As 获取
+	explainer, ok := As[QueryExplainer](WithSchemaCache(store, models.NewSchemaRegistry()))
+	require.True(t, ok)
+
+	from := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	plan, err := explainer.ExplainQuery(ctx, "app", "requests", &models.Query{
+		Filter: map[string]interface{}{"trace_id": "t1"},
+		From:   &from,
+		Limit:  10,
+	}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "sqlite", plan.Dialect)
+	assert.Equal(t, "EXPLAIN QUERY PLAN", plan.Explain)
+	assert.Contains(t, plan.SQL, "LIMIT 10 OFFSET 0")
+	assert.Equal(t, []interface{}{"t1", from}, plan.Args)
+	require.NotEmpty(t, plan.Plan)
+	assert.Contains(t, strings.Join(plan.Plan, "\n"), "USING INDEX", "索引字段的过滤应使用索引")
+
+	// 未建索引的字段需扫描全表
+	plan, err = explainer.ExplainQuery(ctx, "app", "requests", &models.Query{
+		Filter: map[string]interface{}{"path": "/api"},
+	}, false)
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(plan.Plan, "\n"), "SCAN")
+
+	_, err = explainer.ExplainQuery(ctx, "app", "requests", &models.Query{}, true)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = explainer.ExplainQuery(ctx, "app", "missing", &models.Query{}, false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// DefaultFieldValuesLimit 未指定 limit 时返回的取值个数
+const DefaultFieldValuesLimit = 10
+
+// FieldValuesQuerier 统计字段取值分布的可选能力，用于构建过滤下拉框
+type FieldValuesQuerier interface {
+	// FieldValues 返回匹配 query 的日志中 field 出现最多的 query.Limit 个取值及次数，忽略空值；
+	// query 的 Fields、Sort 与 Offset 不生效。字段需事先通过 Schema.FacetField 校验
+	FieldValues(ctx context.Context, project, table, field string, query *models.Query) (*models.FieldValues, error)
+}
+
+// distinctExpr 返回统计不同取值个数的表达式，ClickHouse 使用近似的 uniqCombined
+func distinctExpr(dialect, column string) (string, bool) {
+	if dialect == "clickhouse" {
+		return fmt.Sprintf("uniqCombined(%s)", column), true
+	}
+	return fmt.Sprintf("COUNT(DISTINCT %s)", column), false
+}
+
+// topFieldValues 按字段分组统计匹配日志的取值，先统计总数与不同取值个数，再取出现最多的取值
+func topFieldValues(ctx context.Context, db *sql.DB, dialect, tableName string, schema *models.Schema, field string, q *models.Query) (*models.FieldValues, error) {
+	conditions, values, err := queryConditions(dialect, schema, q)
+	if err != nil {
+		return nil, err
+	}
+	column := quoteIdent(dialect, field)
+	conditions = append(conditions, column+" IS NOT NULL")
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	result := &models.FieldValues{Project: schema.Project, Table: schema.Table, Field: field, Values: make([]models.FieldValue, 0)}
+	distinct, approximate := distinctExpr(dialect, column)
+	result.Approximate = approximate
+	query := fmt.Sprintf("SELECT COUNT(*), %s FROM %s%s", distinct, tableName, where)
+	if err := db.QueryRowContext(ctx, query, values...).Scan(&result.Total, &result.Distinct); err != nil {
+		return nil, fmt.Errorf("统计字段取值失败: %w", unavailable(err))
+	}
+	if result.Total == 0 {
+		return result, nil
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultFieldValuesLimit
+	}
+	query = fmt.Sprintf("SELECT %s, COUNT(*) AS value_count FROM %s%s GROUP BY %s ORDER BY value_count DESC, %s ASC LIMIT %d",
+		column, tableName, where, column, column, limit)
+	rows, err := db.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, fmt.Errorf("统计字段取值失败: %w", unavailable(err))
+	}
+	defer rows.Close()
+
+	var decoded []map[string]interface{}
+	for rows.Next() {
+		var value interface{}
+		var count int64
+		if err := rows.Scan(&value, &count); err != nil {
+			return nil, fmt.Errorf("扫描行失败: %w", err)
+		}
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		result.Values = append(result.Values, models.FieldValue{Count: count})
+		decoded = append(decoded, map[string]interface{}{field: value})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %w", err)
+	}
+	// IP 字段在 MySQL 与 SQLite 上以二进制保存
+	decodeIPs(dialect, schema, decoded)
+	for i, row := range decoded {
+		result.Values[i].Value = row[field]
+	}
+	return result, nil
+}
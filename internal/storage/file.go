@@ -0,0 +1,988 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+const (
+	// defaultSegmentSize 单个段文件的默认大小上限
+	defaultSegmentSize = 64 << 20
+	// maxFileLine 段文件中单行的最大长度
+	maxFileLine = 16 << 20
+)
+
+// RangeQuerier 按时间范围读取日志的可选能力，from 与 to 为零值时不限制，范围为 [from, to)
+type RangeQuerier interface {
+	QueryRange(ctx context.Context, project, table string, from, to time.Time, limit, offset int) ([]map[string]interface{}, error)
+}
+
+// FileStorage 文件存储实现。每张表一个目录，日志以 JSONL 追加写入段文件，
+// index.json 记录每个段的条数、大小与时间范围，按时间查询时跳过不相交的段
+//
+//	<dir>/<project>/<table>/schema.json
+//	<dir>/<project>/<table>/index.json
+//	<dir>/<project>/<table>/00000001.jsonl
+type FileStorage struct {
+	config Config
+	ids    models.IDGenerator
+
+	mu     sync.RWMutex
+	tables map[string]*fileTable // key: project/table
+}
+
+// fileTable 一张表的 schema、段索引与当前写入的段文件
+type fileTable struct {
+	mu       sync.RWMutex
+	dir      string
+	schema   *models.Schema
+	segments []*fileSegment
+	active   *os.File
+}
+
+// fileSegment 段文件索引项，Size 之后的内容视为未提交
+type fileSegment struct {
+	Name    string    `json:"name"`
+	Count   int       `json:"count"`
+	Size    int64     `json:"size"`
+	MinTime time.Time `json:"min_time"`
+	MaxTime time.Time `json:"max_time"`
+}
+
+// overlaps 段的时间范围是否与 [from, to) 相交
+func (seg *fileSegment) overlaps(from, to time.Time) bool {
+	if seg.Count == 0 {
+		return false
+	}
+	return (from.IsZero() || !seg.MaxTime.Before(from)) && (to.IsZero() || seg.MinTime.Before(to))
+}
+
+// NewFileStorage 创建文件存储实例
+func NewFileStorage(config Config) *FileStorage {
+	return &FileStorage{
+		config: config,
+		tables: make(map[string]*fileTable),
+	}
+}
+
+// Initialize 创建数据目录并加载已有的表，未提交的尾部数据被截断
+func (s *FileStorage) Initialize(ctx context.Context) error {
+	ids, err := newIDGenerator(s.config)
+	if err != nil {
+		return err
+	}
+	s.ids = ids
+
+	if s.config.File.Dir == "" {
+		return fmt.Errorf("%w: file storage dir is required", models.ErrValidation)
+	}
+	if err := os.MkdirAll(s.config.File.Dir, 0o755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %w", unavailable(err))
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.config.File.Dir, "*", "*", "schema.json"))
+	if err != nil {
+		return fmt.Errorf("查找 schema 失败: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, path := range paths {
+		t, err := loadFileTable(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		s.tables[t.schema.Project+"/"+t.schema.Table] = t
+	}
+	return nil
+}
+
+// loadFileTable 读取表目录中的 schema 与段索引，并与磁盘上的段文件核对
+func loadFileTable(dir string) (*fileTable, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "schema.json"))
+	if err != nil {
+		return nil, fmt.Errorf("读取 schema 失败: %w", err)
+	}
+	var schema models.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("解析 schema %s 失败: %w", dir, err)
+	}
+
+	indexed := make(map[string]*fileSegment)
+	if data, err := os.ReadFile(filepath.Join(dir, "index.json")); err == nil {
+		var segments []*fileSegment
+		if err := json.Unmarshal(data, &segments); err != nil {
+			return nil, fmt.Errorf("解析段索引 %s 失败: %w", dir, err)
+		}
+		for _, seg := range segments {
+			indexed[seg.Name] = seg
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("读取段索引失败: %w", err)
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("查找段文件失败: %w", err)
+	}
+	sort.Strings(names)
+
+	t := &fileTable{dir: dir, schema: &schema}
+	rebuilt := false
+	for _, path := range names {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取段文件失败: %w", err)
+		}
+		seg := indexed[filepath.Base(path)]
+		if seg == nil || seg.Size != info.Size() {
+			// 写入后索引未更新（如进程崩溃），重新扫描该段并截断不完整的尾行
+			if seg, err = rebuildSegment(path); err != nil {
+				return nil, err
+			}
+			rebuilt = true
+		}
+		t.segments = append(t.segments, seg)
+	}
+	if rebuilt {
+		if err := t.writeIndex(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// rebuildSegment 扫描段文件重建索引项，只保留以换行结尾的完整行
+func rebuildSegment(path string) (*fileSegment, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("打开段文件失败: %w", err)
+	}
+	defer f.Close()
+
+	seg := &fileSegment{Name: filepath.Base(path)}
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("读取段文件失败: %w", err)
+			}
+			break
+		}
+		var row struct {
+			Timestamp time.Time `json:"timestamp"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			break
+		}
+		seg.add(row.Timestamp, int64(len(line)))
+	}
+	if err := f.Truncate(seg.Size); err != nil {
+		return nil, fmt.Errorf("截断段文件失败: %w", err)
+	}
+	return seg, nil
+}
+
+// add 将一行计入段索引
+func (seg *fileSegment) add(ts time.Time, size int64) {
+	if seg.Count == 0 || ts.Before(seg.MinTime) {
+		seg.MinTime = ts
+	}
+	if seg.Count == 0 || ts.After(seg.MaxTime) {
+		seg.MaxTime = ts
+	}
+	seg.Count++
+	seg.Size += size
+}
+
+// writeIndex 原子地写入段索引
+func (t *fileTable) writeIndex() error {
+	return writeFileAtomic(filepath.Join(t.dir, "index.json"), t.segments)
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免读到写了一半的文件
+func writeFileAtomic(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 %s 失败: %w", filepath.Base(path), err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", filepath.Base(path), unavailable(err))
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", filepath.Base(path), unavailable(err))
+	}
+	return nil
+}
+
+// table 返回已加载的表
+func (s *FileStorage) table(project, table string) (*fileTable, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.tables[project+"/"+table]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s_%s", models.ErrSchemaNotFound, project, table)
+	}
+	return t, nil
+}
+
+// tableDir 返回表目录，项目与表名先经过标识符校验，不会逃出数据目录
+func (s *FileStorage) tableDir(project, table string) (string, error) {
+	if err := models.ValidateIdentifier("project", project); err != nil {
+		return "", err
+	}
+	if err := models.ValidateIdentifier("table", table); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.config.File.Dir, project, table), nil
+}
+
+// CreateSchema 创建或更新 schema，已有的日志保持不变
+func (s *FileStorage) CreateSchema(ctx context.Context, schema *models.Schema) error {
+	dir, err := s.tableDir(schema.Project, schema.Table)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := schema.Project + "/" + schema.Table
+	t, ok := s.tables[key]
+	if !ok {
+		t = &fileTable{dir: dir}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("创建表目录失败: %w", unavailable(err))
+	}
+	stored, err := cloneSchema(schema)
+	if err != nil {
+		return err
+	}
+	if t.schema != nil && !t.schema.CreatedAt.IsZero() {
+		stored.CreatedAt = t.schema.CreatedAt
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "schema.json"), stored); err != nil {
+		return err
+	}
+	// 软删除后重新创建时接管目录中保留的日志段
+	if !ok {
+		loaded, err := loadFileTable(dir)
+		if err != nil {
+			return err
+		}
+		t.segments = loaded.segments
+	}
+	t.schema = stored
+	s.tables[key] = t
+	return nil
+}
+
+// UpdateSchema 更新 schema
+func (s *FileStorage) UpdateSchema(ctx context.Context, schema *models.Schema) error {
+	return s.CreateSchema(ctx, schema)
+}
+
+// DeleteSchema 删除 schema 及表目录中的全部日志
+func (s *FileStorage) DeleteSchema(ctx context.Context, project, table string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := project + "/" + table
+	t, ok := s.tables[key]
+	if !ok {
+		return fmt.Errorf("%w: %s_%s", models.ErrSchemaNotFound, project, table)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closeActive()
+	if err := os.RemoveAll(t.dir); err != nil {
+		return fmt.Errorf("删除表目录失败: %w", unavailable(err))
+	}
+	t.schema = nil
+	t.segments = nil
+	delete(s.tables, key)
+	return nil
+}
+
+// DeleteSchemaRecord 只删除 schema.json，保留表目录中的日志段
+func (s *FileStorage) DeleteSchemaRecord(ctx context.Context, project, table string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := project + "/" + table
+	t, ok := s.tables[key]
+	if !ok {
+		return fmt.Errorf("%w: %s_%s", models.ErrSchemaNotFound, project, table)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closeActive()
+	if err := os.Remove(filepath.Join(t.dir, "schema.json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("删除 schema 失败: %w", unavailable(err))
+	}
+	t.schema = nil
+	delete(s.tables, key)
+	return nil
+}
+
+// GetSchema 获取指定的 schema，返回副本
+func (s *FileStorage) GetSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	s.mu.RLock()
+	t, ok := s.tables[project+"/"+table]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, models.ErrSchemaNotFound
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.schema == nil {
+		return nil, models.ErrSchemaNotFound
+	}
+	return cloneSchema(t.schema)
+}
+
+// ListSchemas 列出所有 schemas，按项目与表名排序
+func (s *FileStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error) {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.tables))
+	for key := range s.tables {
+		keys = append(keys, key)
+	}
+	s.mu.RUnlock()
+	sort.Strings(keys)
+
+	schemas := make([]*models.Schema, 0, len(keys))
+	for _, key := range keys {
+		project, table, _ := strings.Cut(key, "/")
+		schema, err := s.GetSchema(ctx, project, table)
+		if errors.Is(err, models.ErrSchemaNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+// cloneSchema 通过 JSON 复制 schema，调用方修改返回值不影响已保存的定义
+func cloneSchema(schema *models.Schema) (*models.Schema, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("序列化 schema 失败: %w", err)
+	}
+	var clone models.Schema
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, fmt.Errorf("解析 schema 失败: %w", err)
+	}
+	return &clone, nil
+}
+
+// InsertLog 插入单条日志
+func (s *FileStorage) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
+	return s.BatchInsertLogs(ctx, project, table, []*models.LogEntry{log})
+}
+
+// BatchInsertLogs 校验全部日志后一次追加到当前段文件，段文件超过大小上限时切换到新段
+func (s *FileStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
+	if len(logs) == 0 {
+		return nil
+	}
+	t, err := s.table(project, table)
+	if err != nil {
+		return fmt.Errorf("获取 schema 失败: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.schema == nil {
+		return fmt.Errorf("获取 schema 失败: %w: %s_%s", models.ErrSchemaNotFound, project, table)
+	}
+
+	assignIDs(s.ids, logs)
+	lines := make([][]byte, 0, len(logs))
+	for _, log := range logs {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("批量写入已中止: %w", err)
+		}
+		if err := t.schema.ValidateLogEntry(log); err != nil {
+			return fmt.Errorf("日志数据验证失败: %w", err)
+		}
+		line, err := fileRow(t.schema, log)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+	}
+
+	segmentSize := s.config.File.SegmentSize
+	if segmentSize <= 0 {
+		segmentSize = defaultSegmentSize
+	}
+	for i := 0; i < len(lines); {
+		seg, err := t.activeSegment(segmentSize)
+		if err != nil {
+			return err
+		}
+		// 一次写入当前段能容纳的行，至少写入一行
+		var buf bytes.Buffer
+		start := i
+		for i < len(lines) && (i == start || seg.Size+int64(buf.Len()+len(lines[i])) <= segmentSize) {
+			buf.Write(lines[i])
+			i++
+		}
+		if _, err := t.active.Write(buf.Bytes()); err != nil {
+			// 未计入索引的部分在下次加载时被截断
+			t.closeActive()
+			return fmt.Errorf("写入段文件失败: %w", unavailable(err))
+		}
+		if s.config.File.Sync {
+			if err := t.active.Sync(); err != nil {
+				return fmt.Errorf("同步段文件失败: %w", unavailable(err))
+			}
+		}
+		for j := start; j < i; j++ {
+			seg.add(logs[j].Timestamp, int64(len(lines[j])))
+		}
+	}
+	return t.writeIndex()
+}
+
+// activeSegment 返回可写入的最后一个段，没有段或最后一个段已满时创建新段
+func (t *fileTable) activeSegment(segmentSize int64) (*fileSegment, error) {
+	var seg *fileSegment
+	if n := len(t.segments); n > 0 && t.segments[n-1].Size < segmentSize {
+		seg = t.segments[n-1]
+	} else {
+		t.closeActive()
+		seg = &fileSegment{Name: fmt.Sprintf("%08d.jsonl", len(t.segments)+1)}
+		t.segments = append(t.segments, seg)
+	}
+	if t.active == nil || filepath.Base(t.active.Name()) != seg.Name {
+		t.closeActive()
+		f, err := os.OpenFile(filepath.Join(t.dir, seg.Name), os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("打开段文件失败: %w", unavailable(err))
+		}
+		// 从索引记录的位置继续写入，覆盖上次失败留下的残余数据
+		if err := f.Truncate(seg.Size); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("截断段文件失败: %w", err)
+		}
+		if _, err := f.Seek(seg.Size, io.SeekStart); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("定位段文件失败: %w", err)
+		}
+		t.active = f
+	}
+	return seg, nil
+}
+
+// closeActive 关闭当前写入的段文件
+func (t *fileTable) closeActive() {
+	if t.active != nil {
+		t.active.Close()
+		t.active = nil
+	}
+}
+
+// fileRow 将日志编码为一行 JSON，包含内置列、tags、ingest_time 与 schema 中定义的字段
+func fileRow(schema *models.Schema, log *models.LogEntry) ([]byte, error) {
+	row := make(map[string]interface{}, len(schema.Fields)+5)
+	for _, field := range schema.Fields {
+		value, ok := entryField(log, field.Name)
+		if !ok {
+			continue
+		}
+		if field.Type == models.FieldTypeDuration && value != nil {
+			nanos, err := models.DurationNanos(value)
+			if err != nil {
+				return nil, fmt.Errorf("转换字段 %s 失败: %w", field.Name, err)
+			}
+			value = nanos
+		}
+		row[field.Name] = value
+	}
+	row["id"] = log.ID
+	row["project"] = log.Project
+	row["table_name"] = log.Table
+	row["timestamp"] = log.Timestamp.UTC()
+	if schema.StoresTags() && len(log.Tags) > 0 {
+		row[models.TagsColumn] = log.Tags
+	}
+	if schema.StoresIngestTime() && !log.IngestTime.IsZero() {
+		row[models.IngestTimeColumn] = log.IngestTime.UTC()
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		return nil, fmt.Errorf("序列化日志失败: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// scan 依次读取与 [from, to) 相交的段中已提交的行，fn 返回 false 时停止
+func (t *fileTable) scan(ctx context.Context, from, to time.Time, fn func(row map[string]interface{}) bool) error {
+	t.mu.RLock()
+	segments := make([]fileSegment, len(t.segments))
+	for i, seg := range t.segments {
+		segments[i] = *seg
+	}
+	t.mu.RUnlock()
+
+	for _, seg := range segments {
+		if !seg.overlaps(from, to) {
+			continue
+		}
+		more, err := t.scanSegment(ctx, &seg, fn)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanSegment 读取单个段，只读取索引记录的大小，不会读到正在写入的行
+func (t *fileTable) scanSegment(ctx context.Context, seg *fileSegment, fn func(row map[string]interface{}) bool) (bool, error) {
+	f, err := os.Open(filepath.Join(t.dir, seg.Name))
+	if errors.Is(err, fs.ErrNotExist) {
+		// 表在读取期间被删除
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("打开段文件失败: %w", unavailable(err))
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(io.LimitReader(f, seg.Size))
+	scanner.Buffer(make([]byte, 64<<10), maxFileLine)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return false, fmt.Errorf("解析段文件 %s 失败: %w", seg.Name, err)
+		}
+		if ts, ok := row["timestamp"].(string); ok {
+			if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				row["timestamp"] = parsed
+			}
+		}
+		if !fn(row) {
+			return false, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("读取段文件 %s 失败: %w", seg.Name, err)
+	}
+	return true, nil
+}
+
+// QueryRange 按时间范围读取日志，只扫描索引中时间范围相交的段，结果按写入顺序返回
+func (s *FileStorage) QueryRange(ctx context.Context, project, table string, from, to time.Time, limit, offset int) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	t, err := s.table(project, table)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+
+	var results []map[string]interface{}
+	err = t.scan(ctx, from, to, func(row map[string]interface{}) bool {
+		ts, _ := row["timestamp"].(time.Time)
+		if (!from.IsZero() && ts.Before(from)) || (!to.IsZero() && !ts.Before(to)) {
+			return true
+		}
+		if offset > 0 {
+			offset--
+			return true
+		}
+		results = append(results, row)
+		return len(results) < limit
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询日志失败: %w", err)
+	}
+	return results, nil
+}
+
+// QueryLogs 按列等值条件查询日志
+func (s *FileStorage) QueryLogs(ctx context.Context, project, table string, query map[string]interface{}, limit, offset int) ([]map[string]interface{}, error) {
+	return s.SearchLogs(ctx, project, table, &models.Query{Filter: query, Sort: []string{"timestamp"}, Limit: limit, Offset: offset})
+}
+
+// SearchLogs 执行带字段选择与排序的查询。过滤条件支持嵌套路径、数组包含与 IP 网段，
+// 时间范围只扫描相交的段，读取全部匹配行后在内存中排序，未指定排序时按 timestamp 降序
+func (s *FileStorage) SearchLogs(ctx context.Context, project, table string, query *models.Query) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	t, err := s.table(project, table)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.RLock()
+	schema := t.schema
+	t.mu.RUnlock()
+	if schema == nil {
+		return nil, models.ErrSchemaNotFound
+	}
+
+	match, err := fileMatcher(schema, query)
+	if err != nil {
+		return nil, err
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	keys := query.SortKeys()
+
+	var from, to time.Time
+	if query.From != nil {
+		from = *query.From
+	}
+	if query.To != nil {
+		to = *query.To
+	}
+
+	var results []map[string]interface{}
+	err = t.scan(ctx, from, to, func(row map[string]interface{}) bool {
+		if !match(row) {
+			return true
+		}
+		if ts, _ := row["timestamp"].(time.Time); (!from.IsZero() && ts.Before(from)) || (!to.IsZero() && !ts.Before(to)) {
+			return true
+		}
+		results = append(results, row)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询日志失败: %w", err)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		for _, key := range keys {
+			if c := compareFileValues(results[i][key.Column], results[j][key.Column]); c != 0 {
+				return (c < 0) != key.Desc
+			}
+		}
+		return false
+	})
+	if query.Offset >= len(results) {
+		results = nil
+	} else {
+		results = results[query.Offset:min(len(results), query.Offset+limit)]
+	}
+
+	if len(query.Fields) > 0 {
+		for i, row := range results {
+			projected := make(map[string]interface{}, len(query.Fields))
+			for _, field := range query.Fields {
+				if value, ok := row[field]; ok {
+					projected[field] = value
+				}
+			}
+			results[i] = projected
+		}
+	}
+	return results, nil
+}
+
+// FieldValues 扫描时间范围内匹配的日志，统计字段出现最多的取值
+func (s *FileStorage) FieldValues(ctx context.Context, project, table, field string, query *models.Query) (*models.FieldValues, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	t, err := s.table(project, table)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.RLock()
+	schema := t.schema
+	t.mu.RUnlock()
+	if schema == nil {
+		return nil, models.ErrSchemaNotFound
+	}
+	match, err := fileMatcher(schema, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var from, to time.Time
+	if query.From != nil {
+		from = *query.From
+	}
+	if query.To != nil {
+		to = *query.To
+	}
+	counts := make(map[interface{}]int64)
+	result := &models.FieldValues{Project: project, Table: table, Field: field, Values: make([]models.FieldValue, 0)}
+	err = t.scan(ctx, from, to, func(row map[string]interface{}) bool {
+		if !match(row) {
+			return true
+		}
+		if ts, _ := row["timestamp"].(time.Time); (!from.IsZero() && ts.Before(from)) || (!to.IsZero() && !ts.Before(to)) {
+			return true
+		}
+		if value, ok := row[field]; ok && value != nil {
+			counts[value]++
+			result.Total++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("统计字段取值失败: %w", err)
+	}
+
+	result.Distinct = int64(len(counts))
+	for value, count := range counts {
+		result.Values = append(result.Values, models.FieldValue{Value: value, Count: count})
+	}
+	sort.Slice(result.Values, func(i, j int) bool {
+		a, b := result.Values[i], result.Values[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return compareFileValues(a.Value, b.Value) < 0
+	})
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultFieldValuesLimit
+	}
+	if len(result.Values) > limit {
+		result.Values = result.Values[:limit]
+	}
+	return result, nil
+}
+
+// fileMatcher 将过滤条件编译为行匹配函数
+func fileMatcher(schema *models.Schema, query *models.Query) (func(row map[string]interface{}) bool, error) {
+	type condition struct {
+		path  []string
+		match func(value interface{}) bool
+	}
+	conditions := make([]condition, 0, len(query.Filter)+len(query.Tags))
+
+	for key, want := range query.Filter {
+		cond := condition{path: []string{key}}
+		path, err := schema.ResolvePath(key)
+		switch {
+		case err == nil && path.Nested():
+			cond.path = append([]string{path.Field.Name}, path.Path...)
+			cond.match = func(value interface{}) bool { return fileValueEqual(value, want) }
+		case err == nil && path.Field.Type == models.FieldTypeIP:
+			prefix, err := models.ParseIPFilter(want)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", models.ErrValidation, err)
+			}
+			cond.match = func(value interface{}) bool {
+				addr, err := models.ParseIP(value)
+				return err == nil && prefix.Contains(addr)
+			}
+		case err == nil && path.Field.Type == models.FieldTypeDuration:
+			nanos, err := models.DurationNanos(want)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", models.ErrValidation, err)
+			}
+			cond.match = func(value interface{}) bool { return fileValueEqual(value, nanos) }
+		case key == "timestamp":
+			cond.match = func(value interface{}) bool { return compareFileValues(value, want) == 0 }
+		default:
+			cond.match = func(value interface{}) bool { return fileValueEqual(value, want) }
+		}
+		conditions = append(conditions, cond)
+	}
+	for key, want := range query.Tags {
+		conditions = append(conditions, condition{
+			path:  []string{models.TagsColumn, key},
+			match: func(value interface{}) bool { return value == want },
+		})
+	}
+
+	return func(row map[string]interface{}) bool {
+		for _, cond := range conditions {
+			matched := false
+			for _, value := range lookupFilePath(row, cond.path) {
+				if cond.match(value) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// lookupFilePath 沿路径取值，经过数组时展开为每个元素，末端为数组时同样展开，用于实现数组包含
+func lookupFilePath(value interface{}, path []string) []interface{} {
+	if array, ok := value.([]interface{}); ok {
+		var values []interface{}
+		for _, item := range array {
+			values = append(values, lookupFilePath(item, path)...)
+		}
+		if len(path) == 0 {
+			values = append(values, array)
+		}
+		return values
+	}
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	next, ok := object[path[0]]
+	if !ok {
+		return nil
+	}
+	return lookupFilePath(next, path[1:])
+}
+
+// fileValueEqual 比较 JSON 解码后的值与过滤值，数字与字符串按文本形式比较
+func fileValueEqual(value, want interface{}) bool {
+	if data, err := json.Marshal(want); err == nil {
+		var normalized interface{}
+		if json.Unmarshal(data, &normalized) == nil && reflect.DeepEqual(value, normalized) {
+			return true
+		}
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}, nil:
+		return false
+	}
+	return fmt.Sprint(value) == fmt.Sprint(want)
+}
+
+// compareFileValues 比较两个值用于排序：nil 最小，数字、时间、字符串与布尔按自身顺序，其他按文本比较
+func compareFileValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if at, ok := fileTime(a); ok {
+		if bt, ok := fileTime(b); ok {
+			return at.Compare(bt)
+		}
+	}
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0
+			case !av:
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// fileTime 将时间或 RFC3339 文本转换为时间
+func fileTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		return parsed, err == nil
+	}
+	return time.Time{}, false
+}
+
+// Ping 检查数据目录是否可访问
+func (s *FileStorage) Ping(ctx context.Context) error {
+	if _, err := os.Stat(s.config.File.Dir); err != nil {
+		return fmt.Errorf("%w: %w", ErrBackendUnavailable, err)
+	}
+	return nil
+}
+
+// Close 关闭所有写入中的段文件
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tables {
+		t.mu.Lock()
+		t.closeActive()
+		t.mu.Unlock()
+	}
+	return nil
+}
+
+// TableSizes 返回段索引中各表已提交的条数与段文件大小
+func (s *FileStorage) TableSizes(ctx context.Context) ([]*models.TableSize, error) {
+	return tableSizes(ctx, s, "segments", func(ctx context.Context, schema *models.Schema) (*models.TableSize, error) {
+		t, err := s.table(schema.Project, schema.Table)
+		if err != nil {
+			return nil, err
+		}
+		t.mu.RLock()
+		defer t.mu.RUnlock()
+		size := &models.TableSize{}
+		for _, seg := range t.segments {
+			size.Rows += int64(seg.Count)
+			size.DataBytes += seg.Size
+		}
+		size.TotalBytes = size.DataBytes
+		return size, nil
+	})
+}
+
+var (
+	_ Storage      = (*FileStorage)(nil)
+	_ LogQuerier   = (*FileStorage)(nil)
+	_ RangeQuerier = (*FileStorage)(nil)
+	_ SizeReporter = (*FileStorage)(nil)
+)
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func newTestFileStorage(t *testing.T, dir string, segmentSize int64) *FileStorage {
+	store := NewFileStorage(Config{Type: "file", File: FileConfig{Dir: dir, SegmentSize: segmentSize}})
+	require.NoError(t, store.Initialize(context.Background()))
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func fileTestSchema() *models.Schema {
+	return &models.Schema{
+		Project: "edge",
+		Table:   "events",
+		Fields: []*models.Field{
+			{Name: "service", Type: models.FieldTypeString},
+			{Name: "status", Type: models.FieldTypeInt},
+			{Name: "client", Type: models.FieldTypeIP},
+			{Name: "labels", Type: models.FieldTypeArray, ItemType: models.FieldTypeString},
+		},
+	}
+}
+
+func fileTestLogs(start time.Time, n int) []*models.LogEntry {
+	logs := make([]*models.LogEntry, n)
+	for i := range logs {
+		service := "api"
+		if i%2 == 1 {
+			service = "worker"
+		}
+		logs[i] = &models.LogEntry{
+			Project: "edge", Table: "events", Level: "info", Message: "m",
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Fields: map[string]interface{}{
+				"service": service,
+				"status":  int64(200 + i),
+				"client":  "10.0.0." + string(rune('1'+i%9)),
+				"labels":  []interface{}{"edge", service},
+			},
+		}
+	}
+	return logs
+}
+
+func TestFileStorage(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := newTestFileStorage(t, dir, 1024)
+
+	schema := fileTestSchema()
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+	got, err := store.GetSchema(ctx, "edge", "events")
+	require.NoError(t, err)
+	assert.Len(t, got.Fields, 4)
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	logs := fileTestLogs(start, 20)
+	require.NoError(t, store.BatchInsertLogs(ctx, "edge", "events", logs[:10]))
+	require.NoError(t, store.BatchInsertLogs(ctx, "edge", "events", logs[10:]))
+
+	// 段大小很小，日志被切分到多个段
+	segments, _ := filepath.Glob(filepath.Join(dir, "edge", "events", "*.jsonl"))
+	assert.Greater(t, len(segments), 2)
+
+	rows, err := store.QueryRange(ctx, "edge", "events", start.Add(5*time.Minute), start.Add(8*time.Minute), 0, 0)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, start.Add(5*time.Minute), rows[0]["timestamp"])
+	assert.EqualValues(t, 205, rows[0]["status"])
+
+	rows, err = store.QueryRange(ctx, "edge", "events", start.Add(15*time.Minute), time.Time{}, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.EqualValues(t, 216, rows[0]["status"])
+
+	query := &models.Query{Filter: map[string]interface{}{"service": "worker"}, Sort: []string{"-status"}, Fields: []string{"status"}, Limit: 3}
+	require.NoError(t, query.Validate(schema, models.BaseColumns))
+	rows, err = store.SearchLogs(ctx, "edge", "events", query)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, map[string]interface{}{"status": float64(219)}, rows[0])
+
+	query = &models.Query{Filter: map[string]interface{}{"client": "10.0.0.0/30", "labels": "api"}}
+	require.NoError(t, query.Validate(schema, models.BaseColumns))
+	rows, err = store.SearchLogs(ctx, "edge", "events", query)
+	require.NoError(t, err)
+	for _, row := range rows {
+		assert.Equal(t, "api", row["service"])
+	}
+	assert.Len(t, rows, 4)
+
+	from, to := start.Add(5*time.Minute), start.Add(8*time.Minute)
+	query = &models.Query{From: &from, To: &to, Sort: []string{"timestamp"}}
+	require.NoError(t, query.Validate(schema, models.BaseColumns))
+	rows, err = store.SearchLogs(ctx, "edge", "events", query)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.EqualValues(t, 205, rows[0]["status"])
+
+	rows, err = store.QueryLogs(ctx, "edge", "events", map[string]interface{}{"status": 203}, 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, rows, 1)
+
+	// 无效的日志不会写入任何行
+	bad := fileTestLogs(start, 2)
+	bad[1].Fields["status"] = "not a number"
+	assert.ErrorIs(t, store.BatchInsertLogs(ctx, "edge", "events", bad), models.ErrValidation)
+	all, err := store.QueryRange(ctx, "edge", "events", time.Time{}, time.Time{}, 1000, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 20)
+
+	// 重新打开后数据与索引保持一致
+	require.NoError(t, store.Close())
+	store = newTestFileStorage(t, dir, 1024)
+	schemas, err := store.ListSchemas(ctx)
+	require.NoError(t, err)
+	require.Len(t, schemas, 1)
+	all, err = store.QueryRange(ctx, "edge", "events", time.Time{}, time.Time{}, 1000, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 20)
+
+	require.NoError(t, store.DeleteSchema(ctx, "edge", "events"))
+	_, err = store.GetSchema(ctx, "edge", "events")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+	assert.ErrorIs(t, store.InsertLog(ctx, "edge", "events", logs[0]), models.ErrSchemaNotFound)
+	assert.NoDirExists(t, filepath.Join(dir, "edge", "events"))
+}
+
+func TestFileStorageRecoversTornWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := newTestFileStorage(t, dir, 0)
+	require.NoError(t, store.CreateSchema(ctx, fileTestSchema()))
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, store.BatchInsertLogs(ctx, "edge", "events", fileTestLogs(start, 3)))
+	require.NoError(t, store.Close())
+
+	// 模拟写入一半时崩溃：段文件末尾有未完成的行，索引未更新
+	segment := filepath.Join(dir, "edge", "events", "00000001.jsonl")
+	f, err := os.OpenFile(segment, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"id":"x","timestamp":"2024-05-01T01:00:00Z"}` + "\n" + `{"id":"torn","times`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	store = newTestFileStorage(t, dir, 0)
+	rows, err := store.QueryRange(ctx, "edge", "events", time.Time{}, time.Time{}, 100, 0)
+	require.NoError(t, err)
+	assert.Len(t, rows, 4, "complete lines are kept, the torn line is dropped")
+
+	require.NoError(t, store.BatchInsertLogs(ctx, "edge", "events", fileTestLogs(start, 1)))
+	rows, err = store.QueryRange(ctx, "edge", "events", time.Time{}, time.Time{}, 100, 0)
+	require.NoError(t, err)
+	assert.Len(t, rows, 5)
+}
+
+func TestFileStorageRejectsPathNames(t *testing.T) {
+	store := newTestFileStorage(t, t.TempDir(), 0)
+	err := store.CreateSchema(context.Background(), &models.Schema{Project: "..", Table: "etc"})
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	_, ok := As[RangeQuerier](WithRetry(store, RetryConfig{Enabled: true}, nil, nil))
+	assert.True(t, ok)
+}
+
+func TestFileStorageDeleteSchemaRecord(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := newTestFileStorage(t, dir, 1024)
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, store.CreateSchema(ctx, fileTestSchema()))
+	require.NoError(t, store.BatchInsertLogs(ctx, "edge", "events", fileTestLogs(start, 5)))
+
+	require.NoError(t, store.DeleteSchemaRecord(ctx, "edge", "events"))
+	_, err := store.GetSchema(ctx, "edge", "events")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+	assert.ErrorIs(t, store.DeleteSchemaRecord(ctx, "edge", "events"), models.ErrSchemaNotFound)
+	assert.DirExists(t, filepath.Join(dir, "edge", "events"))
+
+	// 重新创建 schema 后保留的日志仍可查询，新日志继续追加
+	require.NoError(t, store.CreateSchema(ctx, fileTestSchema()))
+	require.NoError(t, store.BatchInsertLogs(ctx, "edge", "events", fileTestLogs(start.Add(time.Hour), 2)))
+	all, err := store.QueryRange(ctx, "edge", "events", time.Time{}, time.Time{}, 100, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 7)
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// quoteIdent 按方言引用标识符：MySQL 与 ClickHouse 使用反引号，其余使用双引号，
+// 内部的引号加倍转义（ClickHouse 还需转义反斜杠）。名称已在创建 schema 时校验，
+// 引用用于防止来自请求路径等未校验输入的注入
+func quoteIdent(dialect, name string) string {
+	quote := `"`
+	if dialect == "mysql" || dialect == "clickhouse" {
+		quote = "`"
+	}
+	if dialect == "clickhouse" {
+		name = strings.ReplaceAll(name, `\`, `\\`)
+	}
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+}
+
+// quoteIdents 引用多个标识符并以逗号连接
+func quoteIdents(dialect string, names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdent(dialect, name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// logTableName 返回日志表名 logs_<project>_<table>（未引用）
+func logTableName(project, table string) string {
+	return fmt.Sprintf("logs_%s_%s", project, table)
+}
+
+// logTable 返回日志表的引用标识符
+func logTable(dialect, project, table string) string {
+	return quoteIdent(dialect, logTableName(project, table))
+}
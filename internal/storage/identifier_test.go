@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestQuoteIdent(t *testing.T) {
+	assert.Equal(t, `"service"`, quoteIdent("sqlite", "service"))
+	assert.Equal(t, `"a""; DROP TABLE schemas; --"`, quoteIdent("postgres", `a"; DROP TABLE schemas; --`))
+	assert.Equal(t, "`a``b`", quoteIdent("mysql", "a`b"))
+	assert.Equal(t, "`a\\\\``b`", quoteIdent("clickhouse", "a\\`b"))
+	assert.Equal(t, `"id", "tags"`, quoteIdents("sqlite", []string{"id", "tags"}))
+	assert.Equal(t, "`logs_app_requests`", logTable("mysql", "app", "requests"))
+	assert.Equal(t, `"public"."app_requests"`, (&PostgresStorage{schema: "public"}).logTable("app", "requests"))
+}
+
+func TestSQLiteHostileIdentifiers(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "service", Type: models.FieldTypeString}},
+	}
+	require.NoError(t, store.CreateSchema(ctx, schema))
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", []*models.LogEntry{{
+		Project: "app", Table: "requests", Level: "info", Message: "m", Timestamp: time.Now(),
+		Fields: map[string]interface{}{"service": "api"},
+	}}))
+
+	// 表名与列名中的注入片段被引用为普通标识符，不会改变语句结构
+	_, err := store.QueryLogs(ctx, "app", "requests_x; DROP TABLE schemas; --", nil, 10, 0)
+	assert.Error(t, err)
+	rows, err := store.QueryLogs(ctx, "app", "requests", map[string]interface{}{"1 = 1 OR service": "x"}, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+	count, err := store.CountLogs(ctx, "app", "requests", map[string]interface{}{`service" = 'api' OR "service`: "x"})
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	rows, err = store.QueryLogs(ctx, "app", "requests", map[string]interface{}{"service": "api"}, 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, rows, 1)
+	_, err = store.GetSchema(ctx, "app", "requests")
+	assert.NoError(t, err, "schemas table must survive")
+}
+
+func TestSQLitePerProjectRejectsPathNames(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(dir, "logs.db"), PerProject: true},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	_, err := store.QueryLogs(ctx, "../escape", "requests", nil, 10, 0)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.db"))
+	assert.Equal(t, []string{filepath.Join(dir, "logs.db")}, matches)
+}
+
+func TestSQLiteReservedWordFields(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "shop",
+		Table:   "orders",
+		Fields: []*models.Field{
+			{Name: "order", Type: models.FieldTypeString, Indexed: true},
+			{Name: "group", Type: models.FieldTypeInt},
+		},
+		SchemaOptions: models.SchemaOptions{Aggregates: []*models.Aggregate{{
+			Name:     "per_minute",
+			Interval: "1m",
+			GroupBy:  []string{"order"},
+			Metrics:  []*models.AggregateMetric{{Func: models.AggregateCount}, {Func: models.AggregateSum, Field: "group"}},
+		}}},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	now := time.Now()
+	var logs []*models.LogEntry
+	for i, order := range []string{"a", "b", "a"} {
+		logs = append(logs, &models.LogEntry{
+			Project: "shop", Table: "orders", Level: "info", Message: "m", Timestamp: now,
+			Fields: map[string]interface{}{"order": order, "group": int64(i + 1)},
+		})
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, "shop", "orders", logs))
+
+	query := &models.Query{Filter: map[string]interface{}{"order": "a"}, Fields: []string{"order", "group"}, Sort: []string{"-group"}}
+	require.NoError(t, query.Validate(schema, models.BaseColumns))
+	rows, err := store.SearchLogs(ctx, "shop", "orders", query)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.EqualValues(t, 3, rows[0]["group"])
+
+	aggregates, err := store.QueryAggregate(ctx, "shop", "orders", "per_minute", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, aggregates, 2)
+}
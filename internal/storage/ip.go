@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// encodeIP 将 IP 字段的值转换为写入参数：PostgreSQL 与 ClickHouse 使用地址文本，
+// MySQL 与 SQLite 使用与 INET6_ATON 相同的二进制编码（IPv4 4 字节，IPv6 16 字节）
+func encodeIP(dialect string, value interface{}) (interface{}, error) {
+	addr, err := models.ParseIP(value)
+	if err != nil {
+		return nil, err
+	}
+	switch dialect {
+	case "mysql", "sqlite":
+		return addr.AsSlice(), nil
+	default:
+		return addr.String(), nil
+	}
+}
+
+// ipRange 返回网段的首地址与末地址
+func ipRange(prefix netip.Prefix) (netip.Addr, netip.Addr) {
+	first := prefix.Masked().Addr()
+	bytes := first.AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 1 << (7 - bit%8)
+	}
+	last, _ := netip.AddrFromSlice(bytes)
+	return first, last
+}
+
+// ipCondition 追加 IP 字段的过滤条件，值为单个地址时按相等匹配，为 CIDR 网段时按范围匹配
+func ipCondition(dialect, column string, value interface{}, conditions []string, values []interface{}) ([]string, []interface{}, error) {
+	prefix, err := models.ParseIPFilter(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", models.ErrValidation, err)
+	}
+	single := prefix.Bits() == prefix.Addr().BitLen()
+	first, last := ipRange(prefix)
+
+	var condition string
+	switch dialect {
+	case "postgres":
+		values = append(values, prefix.String())
+		if single {
+			condition = fmt.Sprintf("%s = %s::inet", column, placeholder(dialect, len(values)))
+		} else {
+			condition = fmt.Sprintf("%s <<= %s::inet", column, placeholder(dialect, len(values)))
+		}
+	case "clickhouse":
+		if single {
+			values = append(values, first.String())
+			condition = fmt.Sprintf("%s = toIPv6(?)", column)
+		} else {
+			values = append(values, first.String(), last.String())
+			condition = fmt.Sprintf("%s BETWEEN toIPv6(?) AND toIPv6(?)", column)
+		}
+	default:
+		if single {
+			values = append(values, first.AsSlice())
+			condition = fmt.Sprintf("%s = ?", column)
+		} else {
+			// IPv4 与 IPv6 编码长度不同，按长度区分避免跨族的字节序比较
+			values = append(values, first.AsSlice(), last.AsSlice())
+			condition = fmt.Sprintf("(LENGTH(%s) = %d AND %s BETWEEN ? AND ?)", column, first.BitLen()/8, column)
+		}
+	}
+	return append(conditions, condition), values, nil
+}
+
+// decodeIPs 将查询结果中 IP 字段的二进制编码或 net.IP 还原为地址文本
+func decodeIPs(dialect string, schema *models.Schema, rows []map[string]interface{}) {
+	for _, field := range schema.Fields {
+		if field.Type != models.FieldTypeIP {
+			continue
+		}
+		for _, row := range rows {
+			switch v := row[field.Name].(type) {
+			case net.IP:
+				row[field.Name] = v.String()
+			case string:
+				// MySQL 与 SQLite 返回的二进制编码已由 scanRows 转换为字符串
+				if dialect != "mysql" && dialect != "sqlite" {
+					continue
+				}
+				if addr, ok := netip.AddrFromSlice([]byte(v)); ok {
+					row[field.Name] = addr.String()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestIPCondition(t *testing.T) {
+	first, last := ipRange(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.Equal(t, "10.0.0.0", first.String())
+	assert.Equal(t, "10.255.255.255", last.String())
+
+	conditions, values, err := ipCondition("postgres", "client_ip", "10.0.0.0/8", nil, []interface{}{"x"})
+	require.NoError(t, err)
+	assert.Equal(t, "client_ip <<= $2::inet", conditions[0])
+	assert.Equal(t, "10.0.0.0/8", values[1])
+
+	conditions, values, err = ipCondition("clickhouse", "client_ip", "2001:db8::/32", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "client_ip BETWEEN toIPv6(?) AND toIPv6(?)", conditions[0])
+	assert.Equal(t, []interface{}{"2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"}, values)
+
+	conditions, _, err = ipCondition("mysql", "client_ip", "192.168.0.0/16", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "(LENGTH(client_ip) = 4 AND client_ip BETWEEN ? AND ?)", conditions[0])
+
+	_, _, err = ipCondition("sqlite", "client_ip", "bogus", nil, nil)
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestSQLiteIPField(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "edge",
+		Table:   "requests",
+		Fields:  []*models.Field{{Name: "client_ip", Type: models.FieldTypeIP}},
+	}
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	var logs []*models.LogEntry
+	for _, ip := range []string{"10.1.2.3", "10.200.0.1", "192.168.1.1", "2001:db8::1", "::ffff:10.9.9.9"} {
+		logs = append(logs, &models.LogEntry{
+			Project:   "edge",
+			Table:     "requests",
+			Level:     "info",
+			Message:   "request",
+			Timestamp: time.Now(),
+			Fields:    map[string]interface{}{"client_ip": ip},
+		})
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, "edge", "requests", logs))
+
+	search := func(filter string) []string {
+		rows, err := store.SearchLogs(ctx, "edge", "requests", &models.Query{
+			Filter: map[string]interface{}{"client_ip": filter},
+			Fields: []string{"client_ip"},
+			Sort:   []string{"client_ip"},
+		})
+		require.NoError(t, err)
+		ips := make([]string, 0, len(rows))
+		for _, row := range rows {
+			ips = append(ips, row["client_ip"].(string))
+		}
+		return ips
+	}
+
+	assert.Equal(t, []string{"10.1.2.3", "10.9.9.9", "10.200.0.1"}, search("10.0.0.0/8"))
+	assert.Equal(t, []string{"192.168.1.1"}, search("192.168.1.1"))
+	assert.Equal(t, []string{"2001:db8::1"}, search("2001:db8::/32"))
+	assert.Empty(t, search("172.16.0.0/12"))
+}
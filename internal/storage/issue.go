@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// IssueStore 错误归并问题的可选能力
+type IssueStore interface {
+	// RecordIssues 累计问题的出现次数，不存在时创建；已解决的问题再次出现时重新打开
+	RecordIssues(ctx context.Context, issues []*models.Issue) error
+	GetIssue(ctx context.Context, project, table, fingerprint string) (*models.Issue, error)
+	ListIssues(ctx context.Context, filter *models.IssueFilter) ([]*models.Issue, error)
+	SetIssueStatus(ctx context.Context, project, table, fingerprint string, status models.IssueStatus) error
+	DeleteIssue(ctx context.Context, project, table, fingerprint string) error
+}
+
+// issueColumns 问题表的列，与 scanIssues 的扫描顺序一致
+const issueColumns = `project, table_name, fingerprint, title, culprit, level, message, occurrences, status, first_seen, last_seen`
+
+// createIssueTable 创建问题表
+func (sq *savedQueries) createIssueTable(ctx context.Context) error {
+	ts := "TIMESTAMP"
+	switch sq.dialect {
+	case "mysql":
+		ts = "DATETIME(6)"
+	case "postgres":
+		ts = "TIMESTAMP WITH TIME ZONE"
+	}
+
+	query := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS issues (
+		project VARCHAR(255),
+		table_name VARCHAR(255),
+		fingerprint VARCHAR(64),
+		title TEXT,
+		culprit TEXT,
+		level VARCHAR(32),
+		message TEXT,
+		occurrences BIGINT,
+		status VARCHAR(32),
+		first_seen %s,
+		last_seen %s,
+		PRIMARY KEY (project, table_name, fingerprint)
+	)`, ts, ts)
+
+	if _, err := sq.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("创建问题表失败: %w", err)
+	}
+	return nil
+}
+
+// recordIssues 在一个事务中累计问题的出现次数，首次与最近出现时间取两者的较早与较晚值
+func (sq *savedQueries) recordIssues(ctx context.Context, issues []*models.Issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+
+	p := func(n int) string { return placeholder(sq.dialect, n) }
+	query := fmt.Sprintf(`INSERT INTO issues (%s) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)`,
+		issueColumns, p(1), p(2), p(3), p(4), p(5), p(6), p(7), p(8), p(9), p(10), p(11))
+	reopen := fmt.Sprintf("CASE WHEN issues.status = '%s' THEN '%s' ELSE issues.status END", models.IssueResolved, models.IssueUnresolved)
+	switch sq.dialect {
+	case "mysql":
+		query += ` ON DUPLICATE KEY UPDATE occurrences = occurrences + VALUES(occurrences),
+		level = VALUES(level), message = VALUES(message), status = ` + reopen + `,
+		first_seen = LEAST(first_seen, VALUES(first_seen)), last_seen = GREATEST(last_seen, VALUES(last_seen))`
+	default:
+		least, greatest := "LEAST", "GREATEST"
+		if sq.dialect == "sqlite" {
+			least, greatest = "MIN", "MAX"
+		}
+		query += fmt.Sprintf(` ON CONFLICT (project, table_name, fingerprint) DO UPDATE SET
+		occurrences = issues.occurrences + excluded.occurrences,
+		level = excluded.level, message = excluded.message, status = %s,
+		first_seen = %s(issues.first_seen, excluded.first_seen), last_seen = %s(issues.last_seen, excluded.last_seen)`,
+			reopen, least, greatest)
+	}
+
+	tx, err := sq.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("准备语句失败: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, issue := range issues {
+		status := issue.Status
+		if status == "" {
+			status = models.IssueUnresolved
+		}
+		if _, err := stmt.ExecContext(ctx, issue.Project, issue.Table, issue.Fingerprint, issue.Title, issue.Culprit,
+			issue.Level, issue.Message, issue.Count, string(status), issue.FirstSeen.UTC(), issue.LastSeen.UTC()); err != nil {
+			return fmt.Errorf("记录问题失败: %w", unavailable(err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
+	}
+	return nil
+}
+
+// getIssue 获取问题
+func (sq *savedQueries) getIssue(ctx context.Context, project, table, fingerprint string) (*models.Issue, error) {
+	p := func(n int) string { return placeholder(sq.dialect, n) }
+	query := fmt.Sprintf(`SELECT %s FROM issues WHERE project = %s AND table_name = %s AND fingerprint = %s`,
+		issueColumns, p(1), p(2), p(3))
+
+	issues, err := sq.scanIssues(sq.db.QueryContext(ctx, query, project, table, fingerprint))
+	if err != nil {
+		return nil, err
+	}
+	if len(issues) == 0 {
+		return nil, models.ErrIssueNotFound
+	}
+	return issues[0], nil
+}
+
+// listIssues 按最近出现时间倒序列出问题
+func (sq *savedQueries) listIssues(ctx context.Context, filter *models.IssueFilter) ([]*models.Issue, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(column, value string) {
+		if value == "" {
+			return
+		}
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = %s", column, placeholder(sq.dialect, len(args))))
+	}
+	add("project", filter.Project)
+	add("table_name", filter.Table)
+	add("status", string(filter.Status))
+
+	query := fmt.Sprintf(`SELECT %s FROM issues`, issueColumns)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	query += fmt.Sprintf(" ORDER BY last_seen DESC, project, table_name, fingerprint LIMIT %d", limit)
+	if filter.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", filter.Offset)
+	}
+	return sq.scanIssues(sq.db.QueryContext(ctx, query, args...))
+}
+
+// setIssueStatus 更新问题状态
+func (sq *savedQueries) setIssueStatus(ctx context.Context, project, table, fingerprint string, status models.IssueStatus) error {
+	p := func(n int) string { return placeholder(sq.dialect, n) }
+	query := fmt.Sprintf(`UPDATE issues SET status = %s WHERE project = %s AND table_name = %s AND fingerprint = %s`,
+		p(1), p(2), p(3), p(4))
+
+	result, err := sq.db.ExecContext(ctx, query, string(status), project, table, fingerprint)
+	if err != nil {
+		return fmt.Errorf("更新问题状态失败: %w", unavailable(err))
+	}
+	// MySQL 的 RowsAffected 不计入值未变化的行，以查询确认问题是否存在
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		_, err := sq.getIssue(ctx, project, table, fingerprint)
+		return err
+	}
+	return nil
+}
+
+// deleteIssue 删除问题，之后再次出现的同类错误会作为新问题记录
+func (sq *savedQueries) deleteIssue(ctx context.Context, project, table, fingerprint string) error {
+	p := func(n int) string { return placeholder(sq.dialect, n) }
+	query := fmt.Sprintf(`DELETE FROM issues WHERE project = %s AND table_name = %s AND fingerprint = %s`, p(1), p(2), p(3))
+
+	result, err := sq.db.ExecContext(ctx, query, project, table, fingerprint)
+	if err != nil {
+		return fmt.Errorf("删除问题失败: %w", unavailable(err))
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return models.ErrIssueNotFound
+	}
+	return nil
+}
+
+// scanIssues 解析问题的结果集
+func (sq *savedQueries) scanIssues(rows *sql.Rows, err error) ([]*models.Issue, error) {
+	if err != nil {
+		return nil, fmt.Errorf("查询问题失败: %w", unavailable(err))
+	}
+	defer rows.Close()
+
+	issues := make([]*models.Issue, 0)
+	for rows.Next() {
+		var (
+			issue               models.Issue
+			culprit             sql.NullString
+			status              string
+			firstSeen, lastSeen time.Time
+		)
+		if err := rows.Scan(&issue.Project, &issue.Table, &issue.Fingerprint, &issue.Title, &culprit, &issue.Level,
+			&issue.Message, &issue.Count, &status, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("扫描问题失败: %w", err)
+		}
+		issue.Culprit = culprit.String
+		issue.Status = models.IssueStatus(status)
+		issue.FirstSeen, issue.LastSeen = firstSeen, lastSeen
+		issues = append(issues, &issue)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %w", err)
+	}
+	return issues, nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestSQLiteIssues(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	issue := func(fingerprint, message string, count int64, first, last time.Time) *models.Issue {
+		return &models.Issue{
+			Project: "app", Table: "events", Fingerprint: fingerprint, Title: "timeout after <num>ms",
+			Level: "error", Message: message, Count: count, FirstSeen: first, LastSeen: last,
+		}
+	}
+	require.NoError(t, store.RecordIssues(ctx, []*models.Issue{
+		issue("a", "timeout after 30ms", 2, base, base.Add(time.Second)),
+		issue("b", "other", 1, base, base),
+	}))
+	// 较早写入的日志不改变最近出现时间
+	require.NoError(t, store.RecordIssues(ctx, []*models.Issue{
+		issue("a", "timeout after 45ms", 3, base.Add(-time.Hour), base.Add(time.Millisecond)),
+	}))
+
+	got, err := store.GetIssue(ctx, "app", "events", "a")
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, got.Count)
+	assert.Equal(t, "timeout after 45ms", got.Message)
+	assert.Equal(t, models.IssueUnresolved, got.Status)
+	assert.True(t, got.FirstSeen.Equal(base.Add(-time.Hour)), got.FirstSeen)
+	assert.True(t, got.LastSeen.Equal(base.Add(time.Second)), got.LastSeen)
+
+	// 已解决的问题再次出现时重新打开，已忽略的保持忽略
+	require.NoError(t, store.SetIssueStatus(ctx, "app", "events", "a", models.IssueResolved))
+	require.NoError(t, store.SetIssueStatus(ctx, "app", "events", "b", models.IssueIgnored))
+	require.NoError(t, store.RecordIssues(ctx, []*models.Issue{
+		issue("a", "timeout after 1ms", 1, base.Add(time.Minute), base.Add(time.Minute)),
+		issue("b", "other", 1, base.Add(time.Minute), base.Add(time.Minute)),
+	}))
+	got, err = store.GetIssue(ctx, "app", "events", "a")
+	require.NoError(t, err)
+	assert.Equal(t, models.IssueUnresolved, got.Status)
+	got, err = store.GetIssue(ctx, "app", "events", "b")
+	require.NoError(t, err)
+	assert.Equal(t, models.IssueIgnored, got.Status)
+	assert.EqualValues(t, 2, got.Count)
+
+	issues, err := store.ListIssues(ctx, &models.IssueFilter{Project: "app", Status: models.IssueUnresolved})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "a", issues[0].Fingerprint)
+	issues, err = store.ListIssues(ctx, &models.IssueFilter{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	issues, err = store.ListIssues(ctx, &models.IssueFilter{Project: "other"})
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+
+	assert.ErrorIs(t, store.SetIssueStatus(ctx, "app", "events", "missing", models.IssueResolved), models.ErrIssueNotFound)
+	require.NoError(t, store.DeleteIssue(ctx, "app", "events", "a"))
+	_, err = store.GetIssue(ctx, "app", "events", "a")
+	assert.ErrorIs(t, err, models.ErrIssueNotFound)
+	assert.ErrorIs(t, store.DeleteIssue(ctx, "app", "events", "a"), models.ErrIssueNotFound)
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// LogMutator 按过滤条件删除或修改已写入日志的可选能力，用于事后清除敏感数据。
+// 过滤条件与修改的字段需事先通过 LogFilter.Validate、LogUpdate.Validate 校验；持续聚合结果不会随之调整
+type LogMutator interface {
+	// CountMatching 统计匹配过滤条件的日志数，过滤条件为空时统计全部日志
+	CountMatching(ctx context.Context, project, table string, filter *models.LogFilter) (int64, error)
+	// DeleteLogs 删除匹配的日志并返回删除条数。匹配数超过 limit 时返回 ErrMutationLimit 且不做修改，limit 为 0 时不限制
+	DeleteLogs(ctx context.Context, project, table string, filter *models.LogFilter, limit int64) (int64, error)
+	// UpdateLogs 将匹配日志的字段改为 set 中的值并返回修改条数，limit 的含义与 DeleteLogs 相同
+	UpdateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error)
+}
+
+// mutationConn 执行删除或修改的连接：支持事务的存储为事务，ClickHouse 为数据库
+type mutationConn interface {
+	execer
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// countMatching 统计匹配过滤条件的日志数
+func countMatching(ctx context.Context, db mutationConn, dialect, tableName string, schema *models.Schema, filter *models.LogFilter) (int64, error) {
+	conditions, values, err := filterConditions(dialect, schema, filter.Filter, filter.Tags, nil)
+	if err != nil {
+		return 0, err
+	}
+	query := "SELECT COUNT(*) FROM " + tableName
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	var count int64
+	if err := db.QueryRowContext(ctx, query, values...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("统计日志失败: %w", unavailable(err))
+	}
+	return count, nil
+}
+
+// mutateLogs 删除（set 为 nil）或修改匹配的日志。除 ClickHouse 外在一个事务中先统计匹配数，
+// 超过 limit 时不做修改；ClickHouse 的 DELETE 与 ALTER TABLE UPDATE 为异步变更，返回的是提交前的匹配数
+func mutateLogs(ctx context.Context, db *sql.DB, dialect, tableName string, schema *models.Schema,
+	filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	if len(filter.Filter) == 0 && len(filter.Tags) == 0 {
+		return 0, fmt.Errorf("%w: filter or tags is required", models.ErrValidation)
+	}
+
+	var conn mutationConn = db
+	var tx *sql.Tx
+	if dialect != "clickhouse" {
+		var err error
+		if tx, err = db.BeginTx(ctx, nil); err != nil {
+			return 0, fmt.Errorf("开始事务失败: %w", unavailable(err))
+		}
+		defer tx.Rollback()
+		conn = tx
+	}
+
+	count, err := countMatching(ctx, conn, dialect, tableName, schema, filter)
+	if err != nil {
+		return 0, err
+	}
+	if limit > 0 && count > limit {
+		return 0, fmt.Errorf("%w: %d entries match, the limit is %d", models.ErrMutationLimit, count, limit)
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	// 先放入 SET 的参数，过滤条件的占位符编号接在其后
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assignments := make([]string, 0, len(names))
+	values := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		value := set[name]
+		if field := schema.GetField(name); field != nil {
+			if value, err = encodeFieldValue(dialect, field, value); err != nil {
+				return 0, err
+			}
+		}
+		values = append(values, value)
+		assignments = append(assignments, fmt.Sprintf("%s = %s", quoteIdent(dialect, name), placeholder(dialect, len(values))))
+	}
+	conditions, values, err := filterConditions(dialect, schema, filter.Filter, filter.Tags, values)
+	if err != nil {
+		return 0, err
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var query string
+	switch {
+	case set == nil:
+		query = fmt.Sprintf("DELETE FROM %s WHERE %s", tableName, where)
+	case dialect == "clickhouse":
+		query = fmt.Sprintf("ALTER TABLE %s UPDATE %s WHERE %s", tableName, strings.Join(assignments, ", "), where)
+	default:
+		query = fmt.Sprintf("UPDATE %s SET %s WHERE %s", tableName, strings.Join(assignments, ", "), where)
+	}
+	result, err := conn.ExecContext(ctx, query, values...)
+	if err != nil {
+		return 0, fmt.Errorf("修改日志失败: %w", unavailable(err))
+	}
+	if tx == nil {
+		return count, nil
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	// 统计后新写入的日志也可能被匹配，超过上限时回滚
+	if limit > 0 && affected > limit {
+		return 0, fmt.Errorf("%w: %d entries match, the limit is %d", models.ErrMutationLimit, affected, limit)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("提交事务失败: %w", unavailable(err))
+	}
+	return affected, nil
+}
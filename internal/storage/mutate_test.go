@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestSQLiteMutateLogs(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields: []*models.Field{
+			{Name: "user", Type: models.FieldTypeString},
+			{Name: "email", Type: models.FieldTypeString},
+			{Name: "note", Type: models.FieldTypeString},
+		},
+	}
+	require.NoError(t, store.CreateSchema(ctx, schema))
+	for i, user := range []string{"alice", "alice", "alice", "bob"} {
+		require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+			Project: "app", Table: "events", Level: "info", Message: "login",
+			Timestamp: time.Now().Add(time.Duration(i) * time.Second),
+			Tags:      map[string]string{"env": "prod"},
+			Fields:    map[string]interface{}{"user": user, "email": user + "@example.com", "note": "ok"},
+		}))
+	}
+
+	alice := &models.LogFilter{Filter: map[string]interface{}{"user": "alice"}}
+	count, err := store.CountMatching(ctx, "app", "events", alice)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, count)
+
+	// 超过上限时不做修改
+	_, err = store.UpdateLogs(ctx, "app", "events", alice, map[string]interface{}{"email": nil}, 2)
+	assert.ErrorIs(t, err, models.ErrMutationLimit)
+	rows, err := store.QueryLogs(ctx, "app", "events", map[string]interface{}{"user": "alice"}, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", rows[0]["email"])
+
+	updated, err := store.UpdateLogs(ctx, "app", "events", alice, map[string]interface{}{"email": nil, "note": "[redacted]"}, 3)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, updated)
+	rows, err = store.QueryLogs(ctx, "app", "events", map[string]interface{}{"user": "alice"}, 10, 0)
+	require.NoError(t, err)
+	for _, row := range rows {
+		assert.Nil(t, row["email"])
+		assert.Equal(t, "[redacted]", row["note"])
+	}
+
+	deleted, err := store.DeleteLogs(ctx, "app", "events", &models.LogFilter{Tags: map[string]string{"env": "prod"}, Filter: map[string]interface{}{"user": "bob"}}, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, deleted)
+	total, err := store.CountLogs(ctx, "app", "events", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+
+	_, err = store.DeleteLogs(ctx, "app", "events", &models.LogFilter{}, 0)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	deleted, err = store.DeleteLogs(ctx, "app", "events", &models.LogFilter{Filter: map[string]interface{}{"user": "carol"}}, 0)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
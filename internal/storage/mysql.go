@@ -5,17 +5,25 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"pkg.blksails.net/logs/internal/logging"
 	"pkg.blksails.net/logs/internal/models"
 )
 
 // MySQLStorage MySQL 存储实现
 type MySQLStorage struct {
-	db     *sql.DB
-	config Config
+	db       *sql.DB
+	config   Config
+	ids      models.IDGenerator
+	cq       *continuousQueries
+	sq       *savedQueries
+	archives *schemaArchives
+	reads    *replicaSet
 }
 
 // NewMySQLStorage 创建 MySQL 存储实例
@@ -25,30 +33,85 @@ func NewMySQLStorage(config Config) *MySQLStorage {
 	}
 }
 
+// mysqlDSN 构建主库连接字符串
+func mysqlDSN(config MySQLConfig) string {
+	cfg := mysql.NewConfig()
+	cfg.User = config.Username
+	cfg.Passwd = config.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	cfg.DBName = config.Database
+	useUTC(cfg)
+	return cfg.FormatDSN()
+}
+
+// mysqlReplicaDSN 为配置的副本连接字符串设置与主库相同的时间处理参数
+func mysqlReplicaDSN(dsn string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("无效的副本连接字符串: %w", err)
+	}
+	useUTC(cfg)
+	return cfg.FormatDSN(), nil
+}
+
+// useUTC 将会话时区固定为 UTC 并按 UTC 解析读出的时间。TIMESTAMP 列按会话时区换算，
+// 两者一致时写入与读出的都是 UTC 时间，不受服务器时区影响
+func useUTC(cfg *mysql.Config) {
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	if cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+	cfg.Params["time_zone"] = "'+00:00'"
+}
+
 // Initialize 初始化 MySQL 连接和表结构
 func (s *MySQLStorage) Initialize(ctx context.Context) error {
-	// 构建连接字符串
-	connStr := fmt.Sprintf(
-		"%s:%s@tcp(%s:%d)/%s?parseTime=true",
-		s.config.MySQL.Username,
-		s.config.MySQL.Password,
-		s.config.MySQL.Host,
-		s.config.MySQL.Port,
-		s.config.MySQL.Database,
-	)
+	ids, err := newIDGenerator(s.config)
+	if err != nil {
+		return err
+	}
+	s.ids = ids
 
 	// 连接数据库
-	db, err := sql.Open("mysql", connStr)
+	db, err := sql.Open("mysql", mysqlDSN(s.config.MySQL))
 	if err != nil {
-		return fmt.Errorf("连接数据库失败: %w", err)
+		return fmt.Errorf("连接数据库失败: %w", unavailable(err))
 	}
 	s.db = db
+	s.cq = newContinuousQueries(db, "mysql")
+	s.sq = newSavedQueries(db, "mysql")
+	s.archives = newSchemaArchives(db, "mysql")
+
+	// 连接只读副本
+	replicas := make([]string, len(s.config.MySQL.Replicas))
+	for i, dsn := range s.config.MySQL.Replicas {
+		if replicas[i], err = mysqlReplicaDSN(dsn); err != nil {
+			return err
+		}
+	}
+	reads, err := openReplicas(ctx, "mysql", replicas, s.config.MySQL.ReplicaCheckInterval, db, logging.Component(s.config.Logger, "storage"))
+	if err != nil {
+		return err
+	}
+	s.reads = reads
 
 	// 创建 schema 表
 	if err := s.createSchemaTable(ctx); err != nil {
 		return err
 	}
 
+	// 创建保存查询表
+	if err := s.sq.createTable(ctx); err != nil {
+		return err
+	}
+
+	// 创建 schema 归档表
+	if err := s.archives.createTable(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -62,11 +125,26 @@ func (s *MySQLStorage) createSchemaTable(ctx context.Context) error {
 		fields JSON,
 		created_at TIMESTAMP,
 		updated_at TIMESTAMP,
+		options JSON,
 		PRIMARY KEY (project, table_name)
 	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
 
 	if _, err := s.db.ExecContext(ctx, query); err != nil {
-		return fmt.Errorf("创建 schema 表失败: %w", err)
+		return fmt.Errorf("创建 schema 表失败: %w", unavailable(err))
+	}
+
+	// 兼容旧版本创建的 schema 表
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+	SELECT COUNT(*) FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'schemas' AND COLUMN_NAME = 'options'`).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("检查 schema 表失败: %w", err)
+	}
+	if count == 0 {
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE schemas ADD COLUMN options JSON`); err != nil {
+			return fmt.Errorf("升级 schema 表失败: %w", err)
+		}
 	}
 
 	return nil
@@ -74,24 +152,38 @@ func (s *MySQLStorage) createSchemaTable(ctx context.Context) error {
 
 // CreateSchema 创建或更新 schema
 func (s *MySQLStorage) CreateSchema(ctx context.Context, schema *models.Schema) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	// 将字段转换为 JSON
 	fieldsJSON, err := json.Marshal(schema.Fields)
 	if err != nil {
 		return fmt.Errorf("序列化字段失败: %w", err)
 	}
 
+	optionsJSON, err := marshalSchemaOptions(schema)
+	if err != nil {
+		return err
+	}
+
 	// 创建日志表
 	if err := s.createLogTable(ctx, schema); err != nil {
 		return err
 	}
 
+	// 创建持续聚合表
+	if err := s.cq.createTables(ctx, schema); err != nil {
+		return err
+	}
+
 	// 保存 schema
 	query := `
-	INSERT INTO schemas (project, table_name, description, fields, created_at, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?)
+	INSERT INTO schemas (project, table_name, description, fields, options, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
 	ON DUPLICATE KEY UPDATE
 		description = VALUES(description),
 		fields = VALUES(fields),
+		options = VALUES(options),
 		updated_at = VALUES(updated_at)`
 
 	_, err = s.db.ExecContext(ctx, query,
@@ -99,11 +191,12 @@ func (s *MySQLStorage) CreateSchema(ctx context.Context, schema *models.Schema)
 		schema.Table,
 		schema.Description,
 		fieldsJSON,
+		optionsJSON,
 		schema.CreatedAt,
 		schema.UpdatedAt,
 	)
 	if err != nil {
-		return fmt.Errorf("保存 schema 失败: %w", err)
+		return fmt.Errorf("保存 schema 失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -111,14 +204,18 @@ func (s *MySQLStorage) CreateSchema(ctx context.Context, schema *models.Schema)
 
 // GetSchema 获取指定的 schema
 func (s *MySQLStorage) GetSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	query := `
-	SELECT description, fields, created_at, updated_at
+	SELECT description, fields, options, created_at, updated_at
 	FROM schemas
 	WHERE project = ? AND table_name = ?`
 
 	var (
 		description string
 		fieldsJSON  []byte
+		optionsJSON []byte
 		createdAt   time.Time
 		updatedAt   time.Time
 	)
@@ -126,14 +223,15 @@ func (s *MySQLStorage) GetSchema(ctx context.Context, project, table string) (*m
 	err := s.db.QueryRowContext(ctx, query, project, table).Scan(
 		&description,
 		&fieldsJSON,
+		&optionsJSON,
 		&createdAt,
 		&updatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("schema not found")
+		return nil, models.ErrSchemaNotFound
 	}
 	if err != nil {
-		return nil, fmt.Errorf("查询 schema 失败: %w", err)
+		return nil, fmt.Errorf("查询 schema 失败: %w", unavailable(err))
 	}
 
 	var fields []*models.Field
@@ -141,35 +239,48 @@ func (s *MySQLStorage) GetSchema(ctx context.Context, project, table string) (*m
 		return nil, fmt.Errorf("解析字段失败: %w", err)
 	}
 
-	return &models.Schema{
+	schema := &models.Schema{
 		Project:     project,
 		Table:       table,
 		Description: description,
 		Fields:      fields,
 		CreatedAt:   createdAt,
 		UpdatedAt:   updatedAt,
-	}, nil
+	}
+	if err := unmarshalSchemaOptions(optionsJSON, schema); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
 }
 
 // createLogTable 创建日志表
 func (s *MySQLStorage) createLogTable(ctx context.Context, schema *models.Schema) error {
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", schema.Project, schema.Table)
+	tableName := logTable("mysql", schema.Project, schema.Table)
 
 	// 构建字段定义
 	columns := []string{
 		"id VARCHAR(255) PRIMARY KEY",
 		"project VARCHAR(255)",
 		"table_name VARCHAR(255)",
-		"timestamp TIMESTAMP",
+		"timestamp " + mysqlTimestampType(timestampDigits("mysql", schema)),
+	}
+
+	// 内置 tags 列保存标签，按键过滤通过 JSON_EXTRACT 完成
+	if schema.StoresTags() {
+		columns = append(columns, models.TagsColumn+" JSON")
+	}
+	if schema.StoresIngestTime() {
+		columns = append(columns, models.IngestTimeColumn+" DATETIME(6) NULL")
 	}
 
 	// 添加自定义字段
 	for _, field := range schema.Fields {
-		colType := s.getMySQLType(field.Type)
-		colDef := fmt.Sprintf("%s %s", field.Name, colType)
-		if field.Indexed {
-			colDef += ", INDEX idx_" + field.Name + " (" + field.Name + ")"
+		colType := columnType("mysql", field, s.getMySQLType)
+		colDef := fmt.Sprintf("%s %s%s", quoteIdent("mysql", field.Name), colType, columnConstraints("mysql", field, false))
+		if field.IndexedOn("mysql") {
+			colDef += fmt.Sprintf(", INDEX %s (%s)", quoteIdent("mysql", "idx_"+field.Name), quoteIdent("mysql", field.Name))
 		}
 		columns = append(columns, colDef)
 	}
@@ -187,6 +298,108 @@ func (s *MySQLStorage) createLogTable(ctx context.Context, schema *models.Schema
 		return fmt.Errorf("创建日志表失败: %w", err)
 	}
 
+	return s.syncColumns(ctx, schema)
+}
+
+// mysqlTimestampType 返回 timestamp 列的类型，显式允许 NULL，避免旧版本 MySQL 为其添加 ON UPDATE CURRENT_TIMESTAMP
+func mysqlTimestampType(digits int) string {
+	return fmt.Sprintf("TIMESTAMP(%d) NULL", digits)
+}
+
+// syncColumns 为已存在的表补充新增字段和索引
+func (s *MySQLStorage) syncColumns(ctx context.Context, schema *models.Schema) error {
+	rawName := logTableName(schema.Project, schema.Table)
+	tableName := quoteIdent("mysql", rawName)
+	columns, err := queryNames(ctx, s.db, `
+	SELECT COLUMN_NAME FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`, rawName)
+	if err != nil {
+		return fmt.Errorf("查询表字段失败: %w", err)
+	}
+	indexes, err := queryNames(ctx, s.db, `
+	SELECT DISTINCT INDEX_NAME FROM information_schema.STATISTICS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`, rawName)
+	if err != nil {
+		return fmt.Errorf("查询表索引失败: %w", err)
+	}
+
+	for _, field := range schema.Fields {
+		if !columns[field.Name] {
+			alterQuery := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s%s", tableName, quoteIdent("mysql", field.Name), columnType("mysql", field, s.getMySQLType),
+				columnConstraints("mysql", field, true))
+			if _, err := s.db.ExecContext(ctx, alterQuery); err != nil {
+				return fmt.Errorf("添加字段失败: %w", err)
+			}
+		}
+		if field.IndexedOn("mysql") && !indexes["idx_"+field.Name] {
+			alterQuery := fmt.Sprintf("ALTER TABLE %s ADD INDEX %s (%s)", tableName,
+				quoteIdent("mysql", "idx_"+field.Name), quoteIdent("mysql", field.Name))
+			if _, err := s.db.ExecContext(ctx, alterQuery); err != nil {
+				return fmt.Errorf("创建索引失败: %w", err)
+			}
+		}
+	}
+	if schema.StoresTags() && !columns[models.TagsColumn] {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s JSON", tableName, models.TagsColumn)); err != nil {
+			return fmt.Errorf("添加 tags 字段失败: %w", err)
+		}
+	}
+	if schema.StoresIngestTime() && !columns[models.IngestTimeColumn] {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s DATETIME(6) NULL", tableName, models.IngestTimeColumn)); err != nil {
+			return fmt.Errorf("添加 ingest_time 字段失败: %w", err)
+		}
+	}
+
+	if err := s.migrateDurationColumns(ctx, tableName, rawName, schema); err != nil {
+		return err
+	}
+
+	// 早期版本的 timestamp 列只精确到秒，按 schema 的精度修改
+	var precision sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `
+	SELECT DATETIME_PRECISION FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = 'timestamp'`, rawName).Scan(&precision); err != nil {
+		return fmt.Errorf("查询 timestamp 字段精度失败: %w", err)
+	}
+	if digits := timestampDigits("mysql", schema); precision.Valid && int(precision.Int64) != digits {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN timestamp %s", tableName, mysqlTimestampType(digits))); err != nil {
+			return fmt.Errorf("修改 timestamp 字段精度失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateDurationColumns 将旧版本以 VARCHAR 文本保存的 duration 字段转换为纳秒后修改为 BIGINT
+func (s *MySQLStorage) migrateDurationColumns(ctx context.Context, tableName, rawName string, schema *models.Schema) error {
+	legacy, err := queryNames(ctx, s.db, `
+	SELECT COLUMN_NAME FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND DATA_TYPE = 'varchar'`, rawName)
+	if err != nil {
+		return fmt.Errorf("查询 duration 字段类型失败: %w", err)
+	}
+	for _, field := range schema.Fields {
+		if field.Type != models.FieldTypeDuration || !legacy[field.Name] {
+			continue
+		}
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("开始事务失败: %w", unavailable(err))
+		}
+		if err := convertDurationColumn(ctx, tx, "mysql", tableName, field.Name, field.Name); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("提交事务失败: %w", unavailable(err))
+		}
+		// 此时列中都是纳秒数字，修改类型时直接转换
+		alterQuery := fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s%s", tableName, quoteIdent("mysql", field.Name),
+			columnType("mysql", field, s.getMySQLType), columnConstraints("mysql", field, true))
+		if _, err := s.db.ExecContext(ctx, alterQuery); err != nil {
+			return fmt.Errorf("迁移 duration 字段 %s 失败: %w", field.Name, err)
+		}
+	}
 	return nil
 }
 
@@ -206,7 +419,9 @@ func (s *MySQLStorage) getMySQLType(fieldType models.FieldType) string {
 	case models.FieldTypeTime:
 		return "TIME"
 	case models.FieldTypeDuration:
-		return "VARCHAR(100)"
+		return "BIGINT" // 存储为纳秒
+	case models.FieldTypeIP:
+		return "VARBINARY(16)" // 与 INET6_ATON 相同的编码，IPv4 为 4 字节
 	case models.FieldTypeJSON:
 		return "JSON"
 	default:
@@ -216,6 +431,9 @@ func (s *MySQLStorage) getMySQLType(fieldType models.FieldType) string {
 
 // Store 存储单条日志
 func (s *MySQLStorage) Store(ctx context.Context, log *models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
 	// 获取 schema
 	schema, err := s.GetSchema(ctx, log.Project, log.Table)
 	if err != nil {
@@ -228,31 +446,32 @@ func (s *MySQLStorage) Store(ctx context.Context, log *models.LogEntry) error {
 	}
 
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", log.Project, log.Table)
+	tableName := logTable("mysql", log.Project, log.Table)
 
 	// 构建插入语句
+	assignIDs(s.ids, []*models.LogEntry{log})
 	columns := []string{"id", "project", "table_name", "timestamp"}
-	values := []interface{}{log.ID, log.Project, log.Table, log.Timestamp}
+	values := []interface{}{log.ID, log.Project, log.Table, log.Timestamp.UTC()}
 	placeholders := []string{"?", "?", "?", "?"}
 
+	// 写入全部 schema 字段，缺失的字段以 NULL 占位
 	for _, field := range schema.Fields {
-		if value, ok := log.Fields[field.Name]; ok {
-			columns = append(columns, field.Name)
-			values = append(values, value)
-			placeholders = append(placeholders, "?")
-		}
+		value, _ := entryField(log, field.Name)
+		columns = append(columns, field.Name)
+		values = append(values, value)
+		placeholders = append(placeholders, "?")
 	}
 
 	query := fmt.Sprintf(`
 	INSERT INTO %s (%s)
 	VALUES (%s)`,
 		tableName,
-		strings.Join(columns, ", "),
+		quoteIdents("mysql", columns),
 		strings.Join(placeholders, ", "),
 	)
 
 	if _, err := s.db.ExecContext(ctx, query, values...); err != nil {
-		return fmt.Errorf("插入日志失败: %w", err)
+		return fmt.Errorf("插入日志失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -260,10 +479,13 @@ func (s *MySQLStorage) Store(ctx context.Context, log *models.LogEntry) error {
 
 // BatchStore 批量存储日志
 func (s *MySQLStorage) BatchStore(ctx context.Context, logs []*models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
 	// 使用事务批量插入
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
@@ -274,7 +496,7 @@ func (s *MySQLStorage) BatchStore(ctx context.Context, logs []*models.LogEntry)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -282,6 +504,9 @@ func (s *MySQLStorage) BatchStore(ctx context.Context, logs []*models.LogEntry)
 
 // Close 关闭数据库连接
 func (s *MySQLStorage) Close() error {
+	if err := s.reads.Close(); err != nil {
+		return err
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -290,6 +515,9 @@ func (s *MySQLStorage) Close() error {
 
 // BatchInsertLogs 批量插入日志
 func (s *MySQLStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
 	if len(logs) == 0 {
 		return nil
 	}
@@ -303,47 +531,73 @@ func (s *MySQLStorage) BatchInsertLogs(ctx context.Context, project, table strin
 	// 使用事务批量插入
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("mysql", project, table)
 
-	// 准备字段列表
-	var columns []string
-	for _, field := range schema.Fields {
-		columns = append(columns, field.Name)
+	// 列顺序固定为基础列加 schema 字段，整批日志共用一条预编译语句
+	columns := []string{"id", "timestamp"}
+	if schema.StoresTags() {
+		columns = append(columns, models.TagsColumn)
 	}
+	if schema.StoresIngestTime() {
+		columns = append(columns, models.IngestTimeColumn)
+	}
+	columns = append(columns, fieldColumns(schema)...)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", tableName, quoteIdents("mysql", columns), placeholders)
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("准备语句失败: %w", unavailable(err))
+	}
+	defer stmt.Close()
 
 	// 批量插入
+	assignIDs(s.ids, logs)
 	for _, log := range logs {
+		// 取消或超时后不再继续写入，事务由 defer 回滚
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("批量写入已中止: %w", err)
+		}
+
 		// 验证日志数据
 		if err := schema.ValidateLogEntry(log); err != nil {
 			return fmt.Errorf("日志数据验证失败: %w", err)
 		}
 
-		values := make([]interface{}, 0, len(columns))
-		placeholders := make([]string, 0, len(columns))
-		for _, col := range columns {
-			if value, ok := log.Fields[col]; ok {
-				values = append(values, value)
-				placeholders = append(placeholders, "?")
+		values := []interface{}{log.ID, timestampValue(log.Timestamp)}
+		if schema.StoresTags() {
+			tags, err := tagsValue(log.Tags)
+			if err != nil {
+				return err
 			}
+			values = append(values, tags)
 		}
+		if schema.StoresIngestTime() {
+			values = append(values, timestampValue(log.IngestTime))
+		}
+		fields, err := fieldValues("mysql", schema, log)
+		if err != nil {
+			return err
+		}
+		values = append(values, fields...)
 
-		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-			tableName,
-			strings.Join(columns, ", "),
-			strings.Join(placeholders, ", "))
-
-		if _, err := tx.ExecContext(ctx, query, values...); err != nil {
-			return fmt.Errorf("插入日志失败: %w", err)
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return fmt.Errorf("插入日志失败: %w", unavailable(err))
 		}
 	}
 
+	// 增量更新持续聚合
+	if err := s.cq.apply(ctx, tx, schema, logs); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -351,8 +605,11 @@ func (s *MySQLStorage) BatchInsertLogs(ctx context.Context, project, table strin
 
 // CountLogs 统计日志数量
 func (s *MySQLStorage) CountLogs(ctx context.Context, project, table string, query map[string]interface{}) (int64, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("mysql", project, table)
 
 	// 构建查询条件
 	conditions := make([]string, 0, len(query))
@@ -360,22 +617,27 @@ func (s *MySQLStorage) CountLogs(ctx context.Context, project, table string, que
 	paramCount := 1
 
 	for key, value := range query {
-		conditions = append(conditions, fmt.Sprintf("%s = ?", key))
+		conditions = append(conditions, fmt.Sprintf("%s = ?", quoteIdent("mysql", key)))
 		values = append(values, value)
 		paramCount++
 	}
 
 	// 构建 SQL 语句
-	sql := fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)
+	statement := fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)
 	if len(conditions) > 0 {
-		sql += " WHERE " + strings.Join(conditions, " AND ")
+		statement += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
 	// 执行查询
 	var count int64
-	err := s.db.QueryRowContext(ctx, sql, values...).Scan(&count)
+	err := s.reads.read(ctx, func(db *sql.DB) error {
+		if err := db.QueryRowContext(ctx, statement, values...).Scan(&count); err != nil {
+			return fmt.Errorf("统计日志失败: %w", unavailable(err))
+		}
+		return nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("统计日志失败: %w", err)
+		return 0, err
 	}
 
 	return count, nil
@@ -383,18 +645,29 @@ func (s *MySQLStorage) CountLogs(ctx context.Context, project, table string, que
 
 // DeleteSchema 删除 schema
 func (s *MySQLStorage) DeleteSchema(ctx context.Context, project, table string) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	// 开启事务
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
+	// 删除前读取 schema 以便清理聚合表
+	schema, err := s.GetSchema(ctx, project, table)
+	if err == nil {
+		if err := s.cq.dropTables(ctx, tx, schema); err != nil {
+			return err
+		}
+	}
+
 	// 删除 schema 元数据
 	query := `DELETE FROM schemas WHERE project = ? AND table_name = ?`
 	result, err := tx.ExecContext(ctx, query, project, table)
 	if err != nil {
-		return fmt.Errorf("删除 schema 失败: %w", err)
+		return fmt.Errorf("删除 schema 失败: %w", unavailable(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -402,11 +675,11 @@ func (s *MySQLStorage) DeleteSchema(ctx context.Context, project, table string)
 		return fmt.Errorf("获取影响行数失败: %w", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("schema not found: %s_%s", project, table)
+		return fmt.Errorf("%w: %s_%s", models.ErrSchemaNotFound, project, table)
 	}
 
 	// 删除日志表
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("mysql", project, table)
 	dropQuery := fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName)
 	if _, err := tx.ExecContext(ctx, dropQuery); err != nil {
 		return fmt.Errorf("删除日志表失败: %w", err)
@@ -414,12 +687,77 @@ func (s *MySQLStorage) DeleteSchema(ctx context.Context, project, table string)
 
 	// 提交事务
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
 }
 
+// DeleteSchemaRecord 只删除 schema 记录，保留日志表与持续聚合表
+func (s *MySQLStorage) DeleteSchemaRecord(ctx context.Context, project, table string) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return deleteSchemaRecord(ctx, s.db, "mysql", project, table)
+}
+
+// ArchiveSchema 删除 schema 记录，将日志表重命名为归档表并删除持续聚合表
+func (s *MySQLStorage) ArchiveSchema(ctx context.Context, project, table string) (*models.ArchivedSchema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return s.archives.archive(ctx, s, project, table, logTableName(project, table), s.config.Clock,
+		func(tx *sql.Tx, archived *models.ArchivedSchema) error {
+			if err := s.cq.dropTables(ctx, tx, archived.Schema); err != nil {
+				return err
+			}
+			return renameTable(ctx, tx, "mysql", logTable("mysql", project, table), archived.ArchiveTable)
+		})
+}
+
+// ListArchivedSchemas 列出全部归档
+func (s *MySQLStorage) ListArchivedSchemas(ctx context.Context) ([]*models.ArchivedSchema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return s.archives.list(ctx)
+}
+
+// RestoreSchema 恢复最近一次归档的日志表与 schema
+func (s *MySQLStorage) RestoreSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return s.archives.restore(ctx, s, project, table, func(archived *models.ArchivedSchema) error {
+		return renameTable(ctx, s.db, "mysql", quoteIdent("mysql", archived.ArchiveTable), logTableName(project, table))
+	})
+}
+
+// PurgeArchivedSchemas 删除 before 之前归档的日志表
+func (s *MySQLStorage) PurgeArchivedSchemas(ctx context.Context, before time.Time) ([]*models.ArchivedSchema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return s.archives.purge(ctx, before, func(archived *models.ArchivedSchema) error {
+		_, err := s.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+quoteIdent("mysql", archived.ArchiveTable))
+		return err
+	})
+}
+
+// RenameSchema 重命名 schema 及其日志表与持续聚合表，索引名不含表名无需调整。
+// MySQL 的 DDL 会隐式提交，表重命名无法与 schema 记录的更新一起回滚
+func (s *MySQLStorage) RenameSchema(ctx context.Context, project, table, newProject, newTable string) (*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return renameSchema(ctx, s.db, "mysql", s, project, table, newProject, newTable, func(tx *sql.Tx, from, to *models.Schema) error {
+		if err := renameTable(ctx, tx, "mysql", logTable("mysql", project, table), logTableName(newProject, newTable)); err != nil {
+			return err
+		}
+		return renameAggregateTables(ctx, tx, "mysql", from, to)
+	})
+}
+
 // InsertLog 插入单条日志
 func (s *MySQLStorage) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
 	return s.BatchInsertLogs(ctx, project, table, []*models.LogEntry{log})
@@ -427,22 +765,26 @@ func (s *MySQLStorage) InsertLog(ctx context.Context, project, table string, log
 
 // ListSchemas 列出所有 schemas
 func (s *MySQLStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error) {
-	query := `SELECT project, table_name, description, fields, created_at, updated_at FROM schemas`
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	query := `SELECT project, table_name, description, fields, options, created_at, updated_at FROM schemas`
 	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("查询 schemas 失败: %w", err)
+		return nil, fmt.Errorf("查询 schemas 失败: %w", unavailable(err))
 	}
 	defer rows.Close()
 
 	var schemas []*models.Schema
 	for rows.Next() {
 		var schema models.Schema
-		var fieldsJSON []byte
+		var fieldsJSON, optionsJSON []byte
 		err := rows.Scan(
 			&schema.Project,
 			&schema.Table,
 			&schema.Description,
 			&fieldsJSON,
+			&optionsJSON,
 			&schema.CreatedAt,
 			&schema.UpdatedAt,
 		)
@@ -455,6 +797,9 @@ func (s *MySQLStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error
 			return nil, fmt.Errorf("解析字段失败: %w", err)
 		}
 		schema.Fields = fields
+		if err := unmarshalSchemaOptions(optionsJSON, &schema); err != nil {
+			return nil, err
+		}
 		schemas = append(schemas, &schema)
 	}
 
@@ -463,13 +808,19 @@ func (s *MySQLStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error
 
 // Ping 测试数据库连接
 func (s *MySQLStorage) Ping(ctx context.Context) error {
-	return s.db.PingContext(ctx)
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%w: %w", ErrBackendUnavailable, err)
+	}
+	return nil
 }
 
 // QueryLogs 查询日志
 func (s *MySQLStorage) QueryLogs(ctx context.Context, project, table string, query map[string]interface{}, limit, offset int) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("mysql", project, table)
 
 	// 构建查询条件
 	conditions := make([]string, 0, len(query))
@@ -477,25 +828,35 @@ func (s *MySQLStorage) QueryLogs(ctx context.Context, project, table string, que
 	paramCount := 1
 
 	for key, value := range query {
-		conditions = append(conditions, fmt.Sprintf("%s = ?", key))
+		conditions = append(conditions, fmt.Sprintf("%s = ?", quoteIdent("mysql", key)))
 		values = append(values, value)
 		paramCount++
 	}
 
 	// 构建 SQL 语句
-	sql := fmt.Sprintf("SELECT * FROM %s", tableName)
+	statement := fmt.Sprintf("SELECT * FROM %s", tableName)
 	if len(conditions) > 0 {
-		sql += " WHERE " + strings.Join(conditions, " AND ")
+		statement += " WHERE " + strings.Join(conditions, " AND ")
 	}
-	sql += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	statement += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 
 	// 执行查询
-	rows, err := s.db.QueryContext(ctx, sql, values...)
-	if err != nil {
-		return nil, fmt.Errorf("查询日志失败: %w", err)
-	}
-	defer rows.Close()
+	var result []map[string]interface{}
+	err := s.reads.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, statement, values...)
+		if err != nil {
+			return fmt.Errorf("查询日志失败: %w", unavailable(err))
+		}
+		defer rows.Close()
 
+		result, err = scanMySQLRows(rows)
+		return err
+	})
+	return result, err
+}
+
+// scanMySQLRows 将查询结果转换为列名到值的映射，NULL 值的列不出现在结果中
+func scanMySQLRows(rows *sql.Rows) ([]map[string]interface{}, error) {
 	// 获取列名
 	columns, err := rows.Columns()
 	if err != nil {
@@ -539,4 +900,251 @@ func (s *MySQLStorage) UpdateSchema(ctx context.Context, schema *models.Schema)
 	return s.CreateSchema(ctx, schema)
 }
 
-var _ Storage = (*MySQLStorage)(nil)
+// QueryAggregate 查询持续聚合结果
+func (s *MySQLStorage) QueryAggregate(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]interface{}
+	err = s.reads.read(ctx, func(db *sql.DB) error {
+		result, err = s.cq.query(ctx, db, schema, name, from, to)
+		return err
+	})
+	return result, err
+}
+
+// SearchLogs 执行带字段选择与排序的日志查询
+func (s *MySQLStorage) SearchLogs(ctx context.Context, project, table string, query *models.Query) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	err = s.reads.read(ctx, func(db *sql.DB) error {
+		result, err = searchLogs(ctx, db, "mysql", logTable("mysql", project, table), schema, query)
+		return err
+	})
+	return result, err
+}
+
+// ExplainQuery 返回日志查询的执行计划
+func (s *MySQLStorage) ExplainQuery(ctx context.Context, project, table string, query *models.Query, analyze bool) (*models.QueryPlan, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan *models.QueryPlan
+	err = s.reads.read(ctx, func(db *sql.DB) error {
+		plan, err = explainQuery(ctx, db, "mysql", logTable("mysql", project, table), schema, query, analyze)
+		return err
+	})
+	return plan, err
+}
+
+// FieldValues 统计字段出现最多的取值
+func (s *MySQLStorage) FieldValues(ctx context.Context, project, table, field string, query *models.Query) (*models.FieldValues, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var values *models.FieldValues
+	err = s.reads.read(ctx, func(db *sql.DB) error {
+		values, err = topFieldValues(ctx, db, "mysql", logTable("mysql", project, table), schema, field, query)
+		return err
+	})
+	return values, err
+}
+
+// SaveQuery 保存查询
+func (s *MySQLStorage) SaveQuery(ctx context.Context, query *models.SavedQuery) error {
+	return s.sq.save(ctx, query)
+}
+
+// GetSavedQuery 获取保存的查询
+func (s *MySQLStorage) GetSavedQuery(ctx context.Context, owner, name string) (*models.SavedQuery, error) {
+	return s.sq.get(ctx, owner, name)
+}
+
+// ListSavedQueries 列出保存的查询
+func (s *MySQLStorage) ListSavedQueries(ctx context.Context, owner string) ([]*models.SavedQuery, error) {
+	return s.sq.list(ctx, owner)
+}
+
+// DeleteSavedQuery 删除保存的查询
+func (s *MySQLStorage) DeleteSavedQuery(ctx context.Context, owner, name string) error {
+	return s.sq.delete(ctx, owner, name)
+}
+
+// SaveReport 保存定时报表
+func (s *MySQLStorage) SaveReport(ctx context.Context, report *models.Report) error {
+	return s.sq.saveReport(ctx, report)
+}
+
+// GetReport 获取定时报表
+func (s *MySQLStorage) GetReport(ctx context.Context, owner, name string) (*models.Report, error) {
+	return s.sq.getReport(ctx, owner, name)
+}
+
+// ListReports 列出定时报表
+func (s *MySQLStorage) ListReports(ctx context.Context, owner string) ([]*models.Report, error) {
+	return s.sq.listReports(ctx, owner)
+}
+
+// DeleteReport 删除定时报表
+func (s *MySQLStorage) DeleteReport(ctx context.Context, owner, name string) error {
+	return s.sq.deleteReport(ctx, owner, name)
+}
+
+// RecordIssues 累计错误归并问题的出现次数
+func (s *MySQLStorage) RecordIssues(ctx context.Context, issues []*models.Issue) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+	return s.sq.recordIssues(ctx, issues)
+}
+
+// GetIssue 获取问题
+func (s *MySQLStorage) GetIssue(ctx context.Context, project, table, fingerprint string) (*models.Issue, error) {
+	return s.sq.getIssue(ctx, project, table, fingerprint)
+}
+
+// ListIssues 列出问题
+func (s *MySQLStorage) ListIssues(ctx context.Context, filter *models.IssueFilter) ([]*models.Issue, error) {
+	return s.sq.listIssues(ctx, filter)
+}
+
+// SetIssueStatus 更新问题状态
+func (s *MySQLStorage) SetIssueStatus(ctx context.Context, project, table, fingerprint string, status models.IssueStatus) error {
+	return s.sq.setIssueStatus(ctx, project, table, fingerprint, status)
+}
+
+// DeleteIssue 删除问题
+func (s *MySQLStorage) DeleteIssue(ctx context.Context, project, table, fingerprint string) error {
+	return s.sq.deleteIssue(ctx, project, table, fingerprint)
+}
+
+// RecordSlowQuery 记录慢查询
+func (s *MySQLStorage) RecordSlowQuery(ctx context.Context, query *models.SlowQuery) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+	return s.sq.recordSlowQuery(ctx, query)
+}
+
+// ListSlowQueries 列出慢查询
+func (s *MySQLStorage) ListSlowQueries(ctx context.Context, filter *models.SlowQueryFilter) ([]*models.SlowQuery, error) {
+	return s.sq.listSlowQueries(ctx, filter)
+}
+
+// PurgeSlowQueries 删除过期的慢查询记录
+func (s *MySQLStorage) PurgeSlowQueries(ctx context.Context, before time.Time) (int64, error) {
+	return s.sq.purgeSlowQueries(ctx, before)
+}
+
+// CountMatching 统计匹配过滤条件的日志数
+func (s *MySQLStorage) CountMatching(ctx context.Context, project, table string, filter *models.LogFilter) (int64, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return 0, err
+	}
+
+	return countMatching(ctx, s.db, "mysql", logTable("mysql", project, table), schema, filter)
+}
+
+// DeleteLogs 删除匹配过滤条件的日志
+func (s *MySQLStorage) DeleteLogs(ctx context.Context, project, table string, filter *models.LogFilter, limit int64) (int64, error) {
+	return s.mutateLogs(ctx, project, table, filter, nil, limit)
+}
+
+// UpdateLogs 修改匹配过滤条件的日志字段
+func (s *MySQLStorage) UpdateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	return s.mutateLogs(ctx, project, table, filter, set, limit)
+}
+
+// mutateLogs 删除或修改匹配的日志
+func (s *MySQLStorage) mutateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return 0, err
+	}
+
+	return mutateLogs(ctx, s.db, "mysql", logTable("mysql", project, table), schema, filter, set, limit)
+}
+
+// RollupLogs 将早于 before 的日志汇总到 rollup 表并删除
+func (s *MySQLStorage) RollupLogs(ctx context.Context, project, table string, before time.Time) (int64, error) {
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return 0, err
+	}
+	return rollupLogs(ctx, s.db, s.cq, s.config.Timeouts, schema, before)
+}
+
+// QueryRollup 查询 rollup 汇总结果
+func (s *MySQLStorage) QueryRollup(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]interface{}
+	err = s.reads.read(ctx, func(db *sql.DB) error {
+		result, err = s.cq.queryRollup(ctx, db, schema, name, from, to)
+		return err
+	})
+	return result, err
+}
+
+// TableSizes 返回 information_schema.TABLES 中各日志表的行数与数据、索引大小。
+// InnoDB 的行数为估算值，MySQL 8 默认缓存这些统计信息（information_schema_stats_expiry）
+func (s *MySQLStorage) TableSizes(ctx context.Context) ([]*models.TableSize, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	return tableSizes(ctx, s, "information_schema", func(ctx context.Context, schema *models.Schema) (*models.TableSize, error) {
+		size := &models.TableSize{}
+		err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(TABLE_ROWS, 0), COALESCE(DATA_LENGTH, 0), COALESCE(INDEX_LENGTH, 0)
+		FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`,
+			logTableName(schema.Project, schema.Table)).Scan(&size.Rows, &size.DataBytes, &size.IndexBytes)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, unavailable(err)
+		}
+		size.TotalBytes = size.DataBytes + size.IndexBytes
+		return size, nil
+	})
+}
+
+var (
+	_ Storage           = (*MySQLStorage)(nil)
+	_ ContinuousQuerier = (*MySQLStorage)(nil)
+	_ LogQuerier        = (*MySQLStorage)(nil)
+	_ SavedQueryStore   = (*MySQLStorage)(nil)
+	_ ReportStore       = (*MySQLStorage)(nil)
+	_ IssueStore        = (*MySQLStorage)(nil)
+	_ LogMutator        = (*MySQLStorage)(nil)
+	_ Roller            = (*MySQLStorage)(nil)
+	_ SizeReporter      = (*MySQLStorage)(nil)
+)
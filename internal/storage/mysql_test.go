@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMySQLDSN(t *testing.T) {
+	dsn := mysqlDSN(MySQLConfig{Host: "db", Port: 3306, Database: "logs", Username: "app", Password: "p@ss/word"})
+	cfg, err := mysql.ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "db:3306", cfg.Addr)
+	assert.Equal(t, "p@ss/word", cfg.Passwd)
+	assert.True(t, cfg.ParseTime)
+	assert.Equal(t, time.UTC, cfg.Loc)
+	assert.Equal(t, "'+00:00'", cfg.Params["time_zone"])
+
+	replica, err := mysqlReplicaDSN("app:secret@tcp(replica:3306)/logs?parseTime=true&loc=Local")
+	require.NoError(t, err)
+	cfg, err = mysql.ParseDSN(replica)
+	require.NoError(t, err)
+	assert.Equal(t, "replica:3306", cfg.Addr)
+	assert.Equal(t, time.UTC, cfg.Loc)
+	assert.Equal(t, "'+00:00'", cfg.Params["time_zone"])
+
+	_, err = mysqlReplicaDSN("not a dsn")
+	assert.Error(t, err)
+}
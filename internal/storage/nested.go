@@ -0,0 +1,522 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// scalarItem 数组元素是否为可映射为原生数组的基本类型
+func scalarItem(itemType models.FieldType) bool {
+	switch itemType {
+	case models.FieldTypeString, models.FieldTypeInt, models.FieldTypeFloat,
+		models.FieldTypeBool, models.FieldTypeDateTime:
+		return true
+	}
+	return false
+}
+
+// columnType 返回字段的列类型。对象字段以 JSON 存储；基本类型数组在 PostgreSQL 与
+// ClickHouse 中使用原生数组，ClickHouse 的对象数组使用 Nested，其余以 JSON 存储。
+// 非嵌套字段由 scalar 按类型映射
+func columnType(dialect string, field *models.Field, scalar func(models.FieldType) string) string {
+	if !field.IsNested() {
+		return scalar(field.Type)
+	}
+
+	switch dialect {
+	case "postgres":
+		if field.Type == models.FieldTypeArray && scalarItem(field.ItemType) {
+			return scalar(field.ItemType) + "[]"
+		}
+		return "JSONB"
+	case "mysql":
+		return "JSON"
+	case "clickhouse":
+		if field.Type == models.FieldTypeObject {
+			return "String"
+		}
+		switch {
+		case scalarItem(field.ItemType):
+			return fmt.Sprintf("Array(%s)", scalar(field.ItemType))
+		case field.ItemType == models.FieldTypeObject:
+			subColumns := make([]string, 0, len(field.Fields))
+			for _, sub := range field.Fields {
+				subType := "String" // 嵌套的对象、数组与 JSON 以 JSON 文本存储
+				if !sub.IsNested() && sub.Type != models.FieldTypeJSON && sub.Type != models.FieldTypeRest {
+					subType = scalar(sub.Type)
+				}
+				subColumns = append(subColumns, sub.Name+" "+subType)
+			}
+			return fmt.Sprintf("Nested(%s)", strings.Join(subColumns, ", "))
+		default:
+			return "Array(String)"
+		}
+	default:
+		return "TEXT"
+	}
+}
+
+// encodeFieldValue 将字段值转换为写入驱动的参数：对象与无法映射为原生数组的数组序列化为 JSON，
+// 原生数组转换为对应元素类型的切片，IP 按 encodeIP 编码，时长转换为纳秒。ClickHouse 对象数组由 clickhouseNestedColumns 处理
+func encodeFieldValue(dialect string, field *models.Field, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	// SQLite 的 json 与 rest 字段总是保存为 JSON 文本，字符串等非对象值也能由 json_extract 按路径读取
+	if dialect == "sqlite" && (field.Type == models.FieldTypeJSON || field.Type == models.FieldTypeRest) {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("序列化字段 %s 失败: %w", field.Name, err)
+		}
+		return string(data), nil
+	}
+
+	// 时间统一以 UTC 保存，避免按文本比较时间的后端因时区不同而排序错误
+	if t, ok := value.(time.Time); ok {
+		return t.UTC(), nil
+	}
+
+	if field.Type == models.FieldTypeIP {
+		return encodeIP(dialect, value)
+	}
+
+	// 时长在所有后端都以 int64 纳秒保存，可以跨后端比较与聚合
+	if field.Type == models.FieldTypeDuration {
+		nanos, err := models.DurationNanos(value)
+		if err != nil {
+			return nil, fmt.Errorf("转换字段 %s 失败: %w", field.Name, err)
+		}
+		return nanos, nil
+	}
+
+	if field.Type == models.FieldTypeArray && scalarItem(field.ItemType) && (dialect == "postgres" || dialect == "clickhouse") {
+		items, ok := arrayItems(value)
+		if !ok {
+			return nil, fmt.Errorf("字段 %s 不是数组", field.Name)
+		}
+		slice, err := typedSlice(field.ItemType, items)
+		if err != nil {
+			return nil, fmt.Errorf("转换字段 %s 失败: %w", field.Name, err)
+		}
+		if dialect == "clickhouse" {
+			return slice, nil
+		}
+		// PostgreSQL 的时间数组以 RFC3339 文本传入，由数据库转换
+		if times, ok := slice.([]time.Time); ok {
+			texts := make([]string, len(times))
+			for i, t := range times {
+				texts[i] = t.Format(time.RFC3339Nano)
+			}
+			slice = texts
+		}
+		return pq.Array(slice), nil
+	}
+
+	if field.Type == models.FieldTypeArray && dialect == "clickhouse" {
+		items, ok := arrayItems(value)
+		if !ok {
+			return nil, fmt.Errorf("字段 %s 不是数组", field.Name)
+		}
+		return jsonStrings(items), nil
+	}
+
+	_, isMap := value.(map[string]interface{})
+	if field.IsNested() || isMap {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("序列化字段 %s 失败: %w", field.Name, err)
+		}
+		return string(data), nil
+	}
+	return value, nil
+}
+
+// clickhouseNestedColumns 将对象数组拆分为 Nested 的子列 name.sub 及其数组值
+func clickhouseNestedColumns(field *models.Field, value interface{}) ([]string, []interface{}, error) {
+	var items []interface{}
+	if value != nil {
+		var ok bool
+		if items, ok = arrayItems(value); !ok {
+			return nil, nil, fmt.Errorf("字段 %s 不是数组", field.Name)
+		}
+	}
+
+	columns := make([]string, 0, len(field.Fields))
+	values := make([]interface{}, 0, len(field.Fields))
+	for _, sub := range field.Fields {
+		subItems := make([]interface{}, len(items))
+		for i, item := range items {
+			if obj, ok := item.(map[string]interface{}); ok {
+				subItems[i] = obj[sub.Name]
+			}
+		}
+
+		var (
+			column interface{}
+			err    error
+		)
+		if sub.IsNested() || sub.Type == models.FieldTypeJSON || sub.Type == models.FieldTypeRest {
+			column = jsonStrings(subItems)
+		} else {
+			column, err = typedSlice(sub.Type, subItems)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("转换字段 %s.%s 失败: %w", field.Name, sub.Name, err)
+		}
+		columns = append(columns, field.Name+"."+sub.Name)
+		values = append(values, column)
+	}
+	return columns, values, nil
+}
+
+// arrayItems 将任意切片展开为元素列表
+func arrayItems(value interface{}) ([]interface{}, bool) {
+	if items, ok := value.([]interface{}); ok {
+		return items, true
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
+
+// jsonStrings 将元素序列化为 JSON 文本
+func jsonStrings(items []interface{}) []string {
+	result := make([]string, len(items))
+	for i, item := range items {
+		data, _ := json.Marshal(item)
+		result[i] = string(data)
+	}
+	return result
+}
+
+// typedSlice 按元素类型转换为 []string、[]int64、[]float64、[]bool 或 []time.Time，
+// 缺失的元素使用零值
+func typedSlice(itemType models.FieldType, items []interface{}) (interface{}, error) {
+	switch itemType {
+	case models.FieldTypeDuration:
+		result := make([]int64, len(items))
+		for i, item := range items {
+			if item == nil {
+				continue
+			}
+			nanos, err := models.DurationNanos(item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = nanos
+		}
+		return result, nil
+	case models.FieldTypeInt:
+		result := make([]int64, len(items))
+		for i, item := range items {
+			switch v := item.(type) {
+			case nil:
+			case int:
+				result[i] = int64(v)
+			case int32:
+				result[i] = int64(v)
+			case int64:
+				result[i] = v
+			case float64:
+				result[i] = int64(v)
+			default:
+				return nil, fmt.Errorf("期望 int 类型，实际为 %T", item)
+			}
+		}
+		return result, nil
+	case models.FieldTypeFloat:
+		result := make([]float64, len(items))
+		for i, item := range items {
+			switch v := item.(type) {
+			case nil:
+			case float32:
+				result[i] = float64(v)
+			case float64:
+				result[i] = v
+			case int:
+				result[i] = float64(v)
+			case int64:
+				result[i] = float64(v)
+			default:
+				return nil, fmt.Errorf("期望 float 类型，实际为 %T", item)
+			}
+		}
+		return result, nil
+	case models.FieldTypeBool:
+		result := make([]bool, len(items))
+		for i, item := range items {
+			if v, ok := item.(bool); ok {
+				result[i] = v
+			} else if item != nil {
+				return nil, fmt.Errorf("期望 bool 类型，实际为 %T", item)
+			}
+		}
+		return result, nil
+	case models.FieldTypeDateTime:
+		result := make([]time.Time, len(items))
+		for i, item := range items {
+			switch v := item.(type) {
+			case nil:
+			case time.Time:
+				result[i] = v
+			case string:
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					return nil, fmt.Errorf("无效的日期时间格式: %w", err)
+				}
+				result[i] = t
+			default:
+				return nil, fmt.Errorf("期望 datetime 类型，实际为 %T", item)
+			}
+		}
+		return result, nil
+	default:
+		result := make([]string, len(items))
+		for i, item := range items {
+			if item != nil {
+				result[i] = fmt.Sprint(item)
+			}
+		}
+		return result, nil
+	}
+}
+
+// nestedCondition 追加嵌套路径的过滤条件：对象路径按值匹配，数组判断是否包含该值，
+// 对象数组判断是否存在子字段等于该值的元素。路径已由 Query.Validate 校验
+func nestedCondition(dialect string, path *models.FieldPath, value interface{}, conditions []string, values []interface{}) ([]string, []interface{}, error) {
+	column := quoteIdent(dialect, path.Field.Name)
+	isArray := path.Field.Type == models.FieldTypeArray
+
+	// 按路径包装出用于包含判断的 JSON 文档，如 {"headers": {"host": "example.com"}}
+	var doc interface{} = value
+	for i := len(path.Path) - 1; i >= 0; i-- {
+		doc = map[string]interface{}{path.Path[i]: doc}
+	}
+
+	var condition string
+	switch dialect {
+	case "postgres":
+		if isArray && scalarItem(path.Field.ItemType) {
+			values = append(values, value)
+			condition = fmt.Sprintf("%s = ANY(%s)", placeholder(dialect, len(values)), column)
+			break
+		}
+		if isArray {
+			doc = []interface{}{doc}
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("序列化过滤条件失败: %w", err)
+		}
+		values = append(values, string(data))
+		condition = fmt.Sprintf("%s @> %s::jsonb", column, placeholder(dialect, len(values)))
+	case "mysql":
+		// 非数组候选值包含于数组中的任一元素即匹配，对象按部分包含判断
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("序列化过滤条件失败: %w", err)
+		}
+		values = append(values, string(data))
+		condition = fmt.Sprintf("JSON_CONTAINS(%s, ?)", column)
+	case "clickhouse":
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("序列化过滤条件失败: %w", err)
+		}
+		switch {
+		case !isArray:
+			// 对象以 JSON 文本存储，比较路径处的原始 JSON
+			keys := make([]string, len(path.Path))
+			for i, key := range path.Path {
+				values = append(values, key)
+				keys[i] = "?"
+			}
+			values = append(values, string(data))
+			condition = fmt.Sprintf("JSONExtractRaw(%s, %s) = ?", column, strings.Join(keys, ", "))
+		case path.Field.ItemType == models.FieldTypeObject:
+			sub := quoteIdent(dialect, path.Field.Name+"."+path.Path[0])
+			leaf := path.Leaf()
+			if len(path.Path) == 1 && leaf != nil && !leaf.IsNested() &&
+				leaf.Type != models.FieldTypeJSON && leaf.Type != models.FieldTypeRest {
+				values = append(values, value)
+				condition = fmt.Sprintf("has(%s, ?)", sub)
+				break
+			}
+			keys := make([]string, len(path.Path)-1)
+			for i, key := range path.Path[1:] {
+				values = append(values, key)
+				keys[i] = ", ?"
+			}
+			values = append(values, string(data))
+			condition = fmt.Sprintf("arrayExists(x -> JSONExtractRaw(x%s) = ?, %s)", strings.Join(keys, ""), sub)
+		case scalarItem(path.Field.ItemType):
+			values = append(values, value)
+			condition = fmt.Sprintf("has(%s, ?)", column)
+		case len(path.Path) == 0:
+			values = append(values, string(data))
+			condition = fmt.Sprintf("has(%s, ?)", column)
+		default:
+			keys := make([]string, len(path.Path))
+			for i, key := range path.Path {
+				values = append(values, key)
+				keys[i] = ", ?"
+			}
+			values = append(values, string(data))
+			condition = fmt.Sprintf("arrayExists(x -> JSONExtractRaw(x%s) = ?, %s)", strings.Join(keys, ""), column)
+		}
+	default:
+		jsonPath := models.SQLiteJSONPath(path.Path...)
+		switch {
+		case !isArray:
+			// 路径直接写入 SQL，与 json_extract 表达式索引一致才能命中索引
+			values = append(values, value)
+			condition = fmt.Sprintf("json_extract(%s, '%s') = ?", column, jsonPath)
+		case len(path.Path) == 0:
+			values = append(values, value)
+			condition = fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE json_each.value = ?)", column)
+		default:
+			values = append(values, jsonPath, value)
+			condition = fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE json_extract(json_each.value, ?) = ?)", column)
+		}
+	}
+	return append(conditions, condition), values, nil
+}
+
+// decodeNested 将查询结果中的对象与数组字段还原为 map 与切片：JSON 文本反序列化，
+// PostgreSQL 数组按元素类型解析，ClickHouse 的 Nested 子列合并为对象数组
+func decodeNested(dialect string, schema *models.Schema, rows []map[string]interface{}) {
+	for _, field := range schema.Fields {
+		if !field.IsNested() {
+			continue
+		}
+		for _, row := range rows {
+			if dialect == "clickhouse" && field.Type == models.FieldTypeArray && field.ItemType == models.FieldTypeObject {
+				mergeNestedColumns(field, row)
+				continue
+			}
+
+			value, ok := row[field.Name]
+			if !ok {
+				continue
+			}
+			switch {
+			case dialect == "postgres" && scalarItem(field.ItemType) && field.Type == models.FieldTypeArray:
+				if text, ok := value.(string); ok {
+					row[field.Name] = decodePostgresArray(field.ItemType, text)
+				}
+			case dialect == "clickhouse" && field.Type == models.FieldTypeArray:
+				switch v := value.(type) {
+				case []string:
+					if !scalarItem(field.ItemType) {
+						row[field.Name] = decodeJSONStrings(v)
+					}
+				case []uint8:
+					// 布尔数组以 Array(UInt8) 存储
+					bools := make([]bool, len(v))
+					for i, b := range v {
+						bools[i] = b != 0
+					}
+					row[field.Name] = bools
+				}
+			default:
+				if text, ok := value.(string); ok {
+					var decoded interface{}
+					if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+						row[field.Name] = decoded
+					}
+				}
+			}
+		}
+	}
+}
+
+// mergeNestedColumns 将 ClickHouse 返回的 name.sub 子列合并为对象数组
+func mergeNestedColumns(field *models.Field, row map[string]interface{}) {
+	var items []map[string]interface{}
+	found := false
+	for _, sub := range field.Fields {
+		column := field.Name + "." + sub.Name
+		value, ok := row[column]
+		if !ok {
+			continue
+		}
+		found = true
+		delete(row, column)
+
+		subItems, _ := arrayItems(value)
+		for len(items) < len(subItems) {
+			items = append(items, map[string]interface{}{})
+		}
+		for i, item := range subItems {
+			if text, ok := item.(string); ok && (sub.IsNested() || sub.Type == models.FieldTypeJSON || sub.Type == models.FieldTypeRest) {
+				var decoded interface{}
+				if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+					item = decoded
+				}
+			}
+			items[i][sub.Name] = item
+		}
+	}
+	if found {
+		if items == nil {
+			items = []map[string]interface{}{}
+		}
+		row[field.Name] = items
+	}
+}
+
+// decodeJSONStrings 反序列化以 JSON 文本存储的数组元素
+func decodeJSONStrings(texts []string) []interface{} {
+	result := make([]interface{}, len(texts))
+	for i, text := range texts {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+			decoded = text
+		}
+		result[i] = decoded
+	}
+	return result
+}
+
+// decodePostgresArray 解析 PostgreSQL 数组的文本形式，如 {1,2,3}
+func decodePostgresArray(itemType models.FieldType, text string) interface{} {
+	var texts pq.StringArray
+	if err := texts.Scan([]byte(text)); err != nil {
+		return text
+	}
+
+	result := make([]interface{}, len(texts))
+	for i, item := range texts {
+		var (
+			value interface{} = item
+			err   error
+		)
+		switch itemType {
+		case models.FieldTypeInt:
+			value, err = strconv.ParseInt(item, 10, 64)
+		case models.FieldTypeFloat:
+			value, err = strconv.ParseFloat(item, 64)
+		case models.FieldTypeBool:
+			value, err = strconv.ParseBool(item)
+		}
+		if err != nil {
+			value = item
+		}
+		result[i] = value
+	}
+	return result
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestNestedColumnType(t *testing.T) {
+	labels := &models.Field{Name: "labels", Type: models.FieldTypeArray, ItemType: models.FieldTypeInt}
+	items := &models.Field{Name: "items", Type: models.FieldTypeArray, ItemType: models.FieldTypeObject, Fields: []*models.Field{
+		{Name: "sku", Type: models.FieldTypeString},
+		{Name: "meta", Type: models.FieldTypeJSON},
+	}}
+	request := &models.Field{Name: "request", Type: models.FieldTypeObject, Fields: []*models.Field{{Name: "method", Type: models.FieldTypeString}}}
+
+	pg := (&PostgresStorage{}).getPostgresType
+	assert.Equal(t, "BIGINT[]", columnType("postgres", labels, pg))
+	assert.Equal(t, "JSONB", columnType("postgres", items, pg))
+	assert.Equal(t, "JSONB", columnType("postgres", request, pg))
+
+	ch := (&ClickHouseStorage{}).getClickHouseType
+	assert.Equal(t, "Array(Int64)", columnType("clickhouse", labels, ch))
+	assert.Equal(t, "Nested(sku String, meta String)", columnType("clickhouse", items, ch))
+	assert.Equal(t, "String", columnType("clickhouse", request, ch))
+
+	assert.Equal(t, "JSON", columnType("mysql", items, (&MySQLStorage{}).getMySQLType))
+	assert.Equal(t, "TEXT", columnType("sqlite", labels, (&SQLiteStorage{}).getSQLiteType))
+}
+
+func TestNestedCondition(t *testing.T) {
+	schema := &models.Schema{Fields: []*models.Field{
+		{Name: "request", Type: models.FieldTypeObject, Fields: []*models.Field{{Name: "method", Type: models.FieldTypeString}}},
+		{Name: "labels", Type: models.FieldTypeArray, ItemType: models.FieldTypeString},
+		{Name: "items", Type: models.FieldTypeArray, ItemType: models.FieldTypeObject, Fields: []*models.Field{{Name: "sku", Type: models.FieldTypeString}}},
+	}}
+	condition := func(dialect, key string, value interface{}) (string, []interface{}) {
+		path, err := schema.ResolvePath(key)
+		require.NoError(t, err)
+		conditions, values, err := nestedCondition(dialect, path, value, nil, nil)
+		require.NoError(t, err)
+		return conditions[0], values
+	}
+
+	sql, values := condition("postgres", "request.method", "GET")
+	assert.Equal(t, `"request" @> $1::jsonb`, sql)
+	assert.Equal(t, []interface{}{`{"method":"GET"}`}, values)
+	sql, _ = condition("postgres", "labels", "a")
+	assert.Equal(t, `$1 = ANY("labels")`, sql)
+	_, values = condition("postgres", "items.sku", "A-1")
+	assert.Equal(t, []interface{}{`[{"sku":"A-1"}]`}, values)
+
+	sql, values = condition("mysql", "items.sku", "A-1")
+	assert.Equal(t, "JSON_CONTAINS(`items`, ?)", sql)
+	assert.Equal(t, []interface{}{`{"sku":"A-1"}`}, values)
+
+	sql, _ = condition("clickhouse", "items.sku", "A-1")
+	assert.Equal(t, "has(`items.sku`, ?)", sql)
+	sql, values = condition("clickhouse", "request.method", "GET")
+	assert.Equal(t, "JSONExtractRaw(`request`, ?) = ?", sql)
+	assert.Equal(t, []interface{}{"method", `"GET"`}, values)
+
+	sql, values = condition("sqlite", "items.sku", "A-1")
+	assert.Equal(t, `EXISTS (SELECT 1 FROM json_each("items") WHERE json_extract(json_each.value, ?) = ?)`, sql)
+	assert.Equal(t, []interface{}{`$."sku"`, "A-1"}, values)
+	sql, values = condition("sqlite", "request.method", "GET")
+	assert.Equal(t, `json_extract("request", '$."method"') = ?`, sql)
+	assert.Equal(t, []interface{}{"GET"}, values)
+}
+
+func TestSQLiteJSONFields(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields: []*models.Field{
+			{Name: "payload", Type: models.FieldTypeJSON, Index: &models.IndexSpec{Expression: "payload->>'user'"}},
+			{Name: "extra", Type: models.FieldTypeRest},
+		},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := func(payload interface{}, extra string) *models.LogEntry {
+		return &models.LogEntry{
+			Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: base,
+			Fields: map[string]interface{}{"payload": payload, "region": extra},
+		}
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "events", []*models.LogEntry{
+		entry(map[string]interface{}{"user": "bob", "n": 3}, "eu"),
+		entry("plain", "us"),
+	}))
+	require.NoError(t, store.Store(ctx, entry(map[string]interface{}{"user": "alice"}, "eu")))
+
+	// 非对象的值同样保存为 JSON 文本，按路径过滤时不会出现 malformed JSON
+	rows, err := store.SearchLogs(ctx, "app", "events", &models.Query{Filter: map[string]interface{}{"payload.user": "bob"}})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.JSONEq(t, `{"user":"bob","n":3}`, rows[0]["payload"].(string))
+	rows, err = store.SearchLogs(ctx, "app", "events", &models.Query{Filter: map[string]interface{}{"extra.region": "eu"}})
+	require.NoError(t, err)
+	assert.Len(t, rows, 2)
+
+	// 路径过滤使用与表达式索引相同的 json_extract 写法
+	path, err := schema.ResolvePath("payload.user")
+	require.NoError(t, err)
+	conditions, values, err := nestedCondition("sqlite", path, "bob", nil, nil)
+	require.NoError(t, err)
+	var plan []string
+	explain, err := store.db.QueryContext(ctx, "EXPLAIN QUERY PLAN SELECT * FROM logs_app_events WHERE "+conditions[0], values...)
+	require.NoError(t, err)
+	defer explain.Close()
+	for explain.Next() {
+		var id, parent, unused int
+		var detail string
+		require.NoError(t, explain.Scan(&id, &parent, &unused, &detail))
+		plan = append(plan, detail)
+	}
+	assert.Contains(t, strings.Join(plan, "\n"), "idx_logs_app_events_payload")
+}
+
+func TestSQLiteNestedFields(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "shop",
+		Table:   "orders",
+		Fields: []*models.Field{
+			{Name: "request", Type: models.FieldTypeObject, Fields: []*models.Field{
+				{Name: "method", Type: models.FieldTypeString},
+				{Name: "status", Type: models.FieldTypeInt},
+			}},
+			{Name: "labels", Type: models.FieldTypeArray, ItemType: models.FieldTypeString},
+			{Name: "items", Type: models.FieldTypeArray, ItemType: models.FieldTypeObject, Fields: []*models.Field{
+				{Name: "sku", Type: models.FieldTypeString},
+				{Name: "qty", Type: models.FieldTypeInt},
+			}},
+		},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	var logs []*models.LogEntry
+	for i, sku := range []string{"A-1", "B-2"} {
+		logs = append(logs, &models.LogEntry{
+			Project:   "shop",
+			Table:     "orders",
+			Level:     "info",
+			Message:   "order",
+			Timestamp: time.Now(),
+			Fields: map[string]interface{}{
+				"request": map[string]interface{}{"method": []string{"GET", "POST"}[i], "status": 200.0},
+				"labels":  []interface{}{"web", sku},
+				"items":   []interface{}{map[string]interface{}{"sku": sku, "qty": float64(i + 1)}},
+			},
+		})
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, "shop", "orders", logs))
+
+	search := func(filter map[string]interface{}) []map[string]interface{} {
+		query := &models.Query{Filter: filter, Fields: []string{"request", "labels", "items"}}
+		require.NoError(t, query.Validate(schema, models.BaseColumns))
+		rows, err := store.SearchLogs(ctx, "shop", "orders", query)
+		require.NoError(t, err)
+		return rows
+	}
+
+	rows := search(map[string]interface{}{"request.method": "POST"})
+	require.Len(t, rows, 1)
+	assert.Equal(t, map[string]interface{}{"method": "POST", "status": 200.0}, rows[0]["request"])
+	assert.Equal(t, []interface{}{"web", "B-2"}, rows[0]["labels"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"sku": "B-2", "qty": 2.0}}, rows[0]["items"])
+
+	assert.Len(t, search(map[string]interface{}{"items.sku": "A-1"}), 1)
+	assert.Len(t, search(map[string]interface{}{"labels": "web"}), 2)
+	assert.Len(t, search(map[string]interface{}{"labels": "web", "request.status": 200}), 2)
+	assert.Empty(t, search(map[string]interface{}{"items.sku": "C-3"}))
+}
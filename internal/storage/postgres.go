@@ -5,11 +5,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/logging"
 	"pkg.blksails.net/logs/internal/models"
 
 	_ "github.com/lib/pq"
@@ -17,26 +17,35 @@ import (
 
 // PostgresStorage PostgreSQL 存储实现
 type PostgresStorage struct {
-	db     *sql.DB
-	config Config
-	schema string
-	logger *zap.Logger
+	db       *sql.DB
+	config   Config
+	ids      models.IDGenerator
+	schema   string
+	logger   *zap.Logger
+	sq       *savedQueries
+	archives *schemaArchives
+	reads    *replicaSet
+
+	// timescale 日志表是否创建为 TimescaleDB hypertable
+	timescale bool
 }
 
 // NewPostgresStorage 创建 PostgreSQL 存储实例
 func NewPostgresStorage(config Config) *PostgresStorage {
-	logger := config.Logger
-	if logger == nil {
-		logger = zap.L()
-	}
 	return &PostgresStorage{
 		config: config,
-		logger: logger,
+		logger: logging.Component(config.Logger, "storage"),
 	}
 }
 
 // Initialize 初始化 PostgreSQL 连接和表结构
 func (s *PostgresStorage) Initialize(ctx context.Context) error {
+	ids, err := newIDGenerator(s.config)
+	if err != nil {
+		return err
+	}
+	s.ids = ids
+
 	// 构建连接字符串
 	schema := s.config.Postgres.Schema
 	if schema == "" {
@@ -55,10 +64,19 @@ func (s *PostgresStorage) Initialize(ctx context.Context) error {
 	// 连接数据库
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
-		return fmt.Errorf("连接数据库失败: %w", err)
+		return fmt.Errorf("连接数据库失败: %w", unavailable(err))
 	}
 	s.db = db
 	s.schema = schema
+	s.sq = newSavedQueries(db, "postgres")
+	s.archives = newSchemaArchives(db, "postgres")
+
+	// 连接只读副本
+	reads, err := openReplicas(ctx, "postgres", s.config.Postgres.Replicas, s.config.Postgres.ReplicaCheckInterval, db, s.logger)
+	if err != nil {
+		return err
+	}
+	s.reads = reads
 
 	// 创建 logs schema
 	if err := s.createLogsSchema(ctx); err != nil {
@@ -75,6 +93,18 @@ func (s *PostgresStorage) Initialize(ctx context.Context) error {
 		return err
 	}
 
+	s.timescale = s.enableTimescale(ctx)
+
+	// 创建保存查询表
+	if err := s.sq.createTable(ctx); err != nil {
+		return err
+	}
+
+	// 创建 schema 归档表
+	if err := s.archives.createTable(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -106,11 +136,16 @@ func (s *PostgresStorage) createSchemaTable(ctx context.Context) error {
 		fields JSONB,
 		created_at TIMESTAMP WITH TIME ZONE,
 		updated_at TIMESTAMP WITH TIME ZONE,
+		options JSONB,
 		PRIMARY KEY (project, table_name)
 	)`
 
 	if _, err := s.db.ExecContext(ctx, query); err != nil {
-		return fmt.Errorf("创建 schema 表失败: %w", err)
+		return fmt.Errorf("创建 schema 表失败: %w", unavailable(err))
+	}
+
+	if _, err := s.db.ExecContext(ctx, `ALTER TABLE schemas ADD COLUMN IF NOT EXISTS options JSONB`); err != nil {
+		return fmt.Errorf("升级 schema 表失败: %w", err)
 	}
 
 	return nil
@@ -118,12 +153,20 @@ func (s *PostgresStorage) createSchemaTable(ctx context.Context) error {
 
 // CreateSchema 创建或更新 schema
 func (s *PostgresStorage) CreateSchema(ctx context.Context, schema *models.Schema) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	// 将字段转换为 JSON
 	fieldsJSON, err := json.Marshal(schema.Fields)
 	if err != nil {
 		return fmt.Errorf("序列化字段失败: %w", err)
 	}
 
+	optionsJSON, err := marshalSchemaOptions(schema)
+	if err != nil {
+		return err
+	}
+
 	// 创建日志表
 	if err := s.createLogTable(ctx, schema); err != nil {
 		return err
@@ -131,11 +174,12 @@ func (s *PostgresStorage) CreateSchema(ctx context.Context, schema *models.Schem
 
 	// 保存 schema
 	query := `
-	INSERT INTO schemas (project, table_name, description, fields, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6)
+	INSERT INTO schemas (project, table_name, description, fields, options, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
 	ON CONFLICT (project, table_name) DO UPDATE
 	SET description = EXCLUDED.description,
 		fields = EXCLUDED.fields,
+		options = EXCLUDED.options,
 		updated_at = EXCLUDED.updated_at`
 
 	_, err = s.db.ExecContext(ctx, query,
@@ -143,11 +187,12 @@ func (s *PostgresStorage) CreateSchema(ctx context.Context, schema *models.Schem
 		schema.Table,
 		schema.Description,
 		fieldsJSON,
+		optionsJSON,
 		schema.CreatedAt,
 		schema.UpdatedAt,
 	)
 	if err != nil {
-		return fmt.Errorf("保存 schema 失败: %w", err)
+		return fmt.Errorf("保存 schema 失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -155,14 +200,18 @@ func (s *PostgresStorage) CreateSchema(ctx context.Context, schema *models.Schem
 
 // GetSchema 获取指定的 schema
 func (s *PostgresStorage) GetSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	query := `
-	SELECT description, fields, created_at, updated_at
+	SELECT description, fields, options, created_at, updated_at
 	FROM schemas
 	WHERE project = $1 AND table_name = $2`
 
 	var (
 		description string
 		fieldsJSON  []byte
+		optionsJSON []byte
 		createdAt   time.Time
 		updatedAt   time.Time
 	)
@@ -170,14 +219,15 @@ func (s *PostgresStorage) GetSchema(ctx context.Context, project, table string)
 	err := s.db.QueryRowContext(ctx, query, project, table).Scan(
 		&description,
 		&fieldsJSON,
+		&optionsJSON,
 		&createdAt,
 		&updatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("schema not found")
+		return nil, models.ErrSchemaNotFound
 	}
 	if err != nil {
-		return nil, fmt.Errorf("查询 schema 失败: %w", err)
+		return nil, fmt.Errorf("查询 schema 失败: %w", unavailable(err))
 	}
 
 	var fields []models.Field
@@ -191,27 +241,35 @@ func (s *PostgresStorage) GetSchema(ctx context.Context, project, table string)
 		fieldPtrs[i] = &fields[i]
 	}
 
-	return &models.Schema{
+	schema := &models.Schema{
 		Project:     project,
 		Table:       table,
 		Description: description,
 		Fields:      fieldPtrs,
 		CreatedAt:   createdAt,
 		UpdatedAt:   updatedAt,
-	}, nil
+	}
+	if err := unmarshalSchemaOptions(optionsJSON, schema); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
 }
 
 // createLogTable 创建日志表
 func (s *PostgresStorage) createLogTable(ctx context.Context, schema *models.Schema) error {
 	// 构建表名
-	tableName := fmt.Sprintf("%s.%s_%s", quote(s.schema), schema.Project, schema.Table)
+	tableName := s.logTable(schema.Project, schema.Table)
 
 	// 构建基础字段定义
-	columns := []string{
-		"id SERIAL PRIMARY KEY",
-		"project VARCHAR(255)",
-		"table_name VARCHAR(255)",
-		"timestamp TIMESTAMP WITH TIME ZONE",
+	columns := postgresKeyColumns(s.timescale, postgresTimestampType(timestampDigits("postgres", schema)))
+
+	// 内置 tags 列保存标签，并建立 GIN 索引
+	if schema.StoresTags() {
+		columns = append(columns, models.TagsColumn+" JSONB")
+	}
+	if schema.StoresIngestTime() {
+		columns = append(columns, models.IngestTimeColumn+" TIMESTAMP WITH TIME ZONE")
 	}
 
 	// 默认字段列表
@@ -236,8 +294,8 @@ func (s *PostgresStorage) createLogTable(ctx context.Context, schema *models.Sch
 
 	// 添加自定义字段
 	for _, field := range schema.Fields {
-		colType := s.getPostgresType(field.Type)
-		colDef := fmt.Sprintf("%s %s", field.Name, colType)
+		colType := columnType("postgres", field, s.getPostgresType)
+		colDef := fmt.Sprintf("%s %s%s", quote(field.Name), colType, columnConstraints("postgres", field, false))
 		columns = append(columns, colDef)
 	}
 
@@ -251,14 +309,59 @@ func (s *PostgresStorage) createLogTable(ctx context.Context, schema *models.Sch
 		return fmt.Errorf("创建日志表失败: %w", err)
 	}
 
-	pureTableName := fmt.Sprintf("%s_%s", schema.Project, schema.Table)
+	if err := s.migrateIDColumn(ctx, tableName, schema); err != nil {
+		return err
+	}
+	if err := s.migrateTimestampPrecision(ctx, tableName, schema); err != nil {
+		return err
+	}
+	if err := s.migrateDurationColumns(ctx, tableName, schema); err != nil {
+		return err
+	}
+
+	if s.timescale {
+		if err := s.createHypertable(ctx, tableName, schema); err != nil {
+			return err
+		}
+	}
+
+	// 为已存在的表补充新增字段
+	for _, field := range schema.Fields {
+		alterQuery := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s%s",
+			tableName, quote(field.Name), columnType("postgres", field, s.getPostgresType), columnConstraints("postgres", field, true))
+		if _, err := s.db.ExecContext(ctx, alterQuery); err != nil {
+			return fmt.Errorf("添加字段失败: %w", err)
+		}
+	}
+
+	pureTableName := postgresTableName(schema.Project, schema.Table)
+
+	if schema.StoresTags() {
+		for _, query := range []string{
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s JSONB", tableName, models.TagsColumn),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (%s)", quote("idx_"+pureTableName+"_tags"), tableName, models.TagsColumn),
+		} {
+			if _, err := s.db.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("添加 tags 字段失败: %w", err)
+			}
+		}
+	}
+	if schema.StoresIngestTime() {
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s TIMESTAMP WITH TIME ZONE", tableName, models.IngestTimeColumn)
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("添加 ingest_time 字段失败: %w", err)
+		}
+	}
 
 	// 为索引字段创建索引
 	for _, field := range schema.Fields {
-		if field.Indexed {
+		if field.IndexedOn("postgres") {
 			indexName := fmt.Sprintf("idx_%s_%s", pureTableName, field.Name)
-			indexQuery := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
-				indexName, pureTableName, field.Name)
+			if err := s.dropChangedIndex(ctx, indexName, field); err != nil {
+				return err
+			}
+			indexQuery := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s %s",
+				quote(indexName), tableName, field.PostgresIndexTarget())
 			if _, err := s.db.ExecContext(ctx, indexQuery); err != nil {
 				return fmt.Errorf("创建索引失败: %w", err)
 			}
@@ -268,6 +371,98 @@ func (s *PostgresStorage) createLogTable(ctx context.Context, schema *models.Sch
 	return nil
 }
 
+// dropChangedIndex 索引类型与字段定义不一致时删除已有索引，由调用方按新定义重建。
+// 只比较索引方法，修改表达式需要手动删除索引
+func (s *PostgresStorage) dropChangedIndex(ctx context.Context, indexName string, field *models.Field) error {
+	var definition string
+	err := s.db.QueryRowContext(ctx, `SELECT indexdef FROM pg_indexes WHERE schemaname = $1 AND indexname = $2`,
+		s.schema, indexName).Scan(&definition)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询索引失败: %w", err)
+	}
+	if strings.Contains(definition, fmt.Sprintf(" USING %s ", field.IndexType())) {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("DROP INDEX IF EXISTS %s.%s", quote(s.schema), quote(indexName))); err != nil {
+		return fmt.Errorf("删除索引失败: %w", err)
+	}
+	return nil
+}
+
+// migrateIDColumn 将旧版本 SERIAL 自增 id 列迁移为字符串，已有 ID 保留为十进制字符串
+func (s *PostgresStorage) migrateIDColumn(ctx context.Context, tableName string, schema *models.Schema) error {
+	var dataType string
+	err := s.db.QueryRowContext(ctx, `
+	SELECT data_type FROM information_schema.columns
+	WHERE table_schema = $1 AND table_name = $2 AND column_name = 'id'`,
+		s.schema, postgresTableName(schema.Project, schema.Table),
+	).Scan(&dataType)
+	if err == sql.ErrNoRows || dataType == "character varying" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询 id 字段类型失败: %w", err)
+	}
+
+	query := fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN id DROP DEFAULT, ALTER COLUMN id TYPE VARCHAR(64) USING id::text`, tableName)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("迁移 id 字段失败: %w", err)
+	}
+	return nil
+}
+
+// migrateTimestampPrecision 已存在的表的 timestamp 列精度与 schema 不同时修改列类型
+func (s *PostgresStorage) migrateTimestampPrecision(ctx context.Context, tableName string, schema *models.Schema) error {
+	var precision sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+	SELECT datetime_precision FROM information_schema.columns
+	WHERE table_schema = $1 AND table_name = $2 AND column_name = 'timestamp'`,
+		s.schema, postgresTableName(schema.Project, schema.Table),
+	).Scan(&precision)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询 timestamp 字段精度失败: %w", err)
+	}
+	digits := timestampDigits("postgres", schema)
+	if !precision.Valid || int(precision.Int64) == digits {
+		return nil
+	}
+
+	query := fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN timestamp TYPE %s`, tableName, postgresTimestampType(digits))
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("修改 timestamp 字段精度失败: %w", err)
+	}
+	return nil
+}
+
+// migrateDurationColumns 将旧版本以 INTERVAL 保存的 duration 字段迁移为纳秒 BIGINT
+func (s *PostgresStorage) migrateDurationColumns(ctx context.Context, tableName string, schema *models.Schema) error {
+	legacy, err := queryNames(ctx, s.db, `
+	SELECT column_name FROM information_schema.columns
+	WHERE table_schema = $1 AND table_name = $2 AND data_type = 'interval'`,
+		s.schema, postgresTableName(schema.Project, schema.Table))
+	if err != nil {
+		return fmt.Errorf("查询 duration 字段类型失败: %w", err)
+	}
+	for _, field := range schema.Fields {
+		if field.Type != models.FieldTypeDuration || !legacy[field.Name] {
+			continue
+		}
+		column := quote(field.Name)
+		query := fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s TYPE BIGINT USING (EXTRACT(EPOCH FROM %s) * 1000000000)::BIGINT`,
+			tableName, column, column)
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("迁移 duration 字段 %s 失败: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
 // getPostgresType 获取 PostgreSQL 字段类型
 func (s *PostgresStorage) getPostgresType(fieldType models.FieldType) string {
 	switch fieldType {
@@ -284,7 +479,9 @@ func (s *PostgresStorage) getPostgresType(fieldType models.FieldType) string {
 	case models.FieldTypeTime:
 		return "TIME"
 	case models.FieldTypeDuration:
-		return "INTERVAL"
+		return "BIGINT" // 存储为纳秒
+	case models.FieldTypeIP:
+		return "INET"
 	case models.FieldTypeJSON, models.FieldTypeRest:
 		return "JSONB"
 	default:
@@ -292,6 +489,14 @@ func (s *PostgresStorage) getPostgresType(fieldType models.FieldType) string {
 	}
 }
 
+// DeleteSchemaRecord 只删除 schema 记录，保留日志表与持续聚合表
+func (s *PostgresStorage) DeleteSchemaRecord(ctx context.Context, project, table string) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return deleteSchemaRecord(ctx, s.db, "postgres", project, table)
+}
+
 // InsertLog 插入单条日志
 func (s *PostgresStorage) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
 	return s.BatchInsertLogs(ctx, project, table, []*models.LogEntry{log})
@@ -299,6 +504,9 @@ func (s *PostgresStorage) InsertLog(ctx context.Context, project, table string,
 
 // Close 关闭数据库连接
 func (s *PostgresStorage) Close() error {
+	if err := s.reads.Close(); err != nil {
+		return err
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -307,7 +515,10 @@ func (s *PostgresStorage) Close() error {
 
 // Ping 测试数据库连接
 func (s *PostgresStorage) Ping(ctx context.Context) error {
-	return s.db.PingContext(ctx)
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%w: %w", ErrBackendUnavailable, err)
+	}
+	return nil
 }
 
 // UpdateSchema 更新 schema
@@ -317,22 +528,26 @@ func (s *PostgresStorage) UpdateSchema(ctx context.Context, schema *models.Schem
 
 // ListSchemas 列出所有 schemas
 func (s *PostgresStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error) {
-	query := `SELECT project, table_name, description, fields, created_at, updated_at FROM schemas`
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	query := `SELECT project, table_name, description, fields, options, created_at, updated_at FROM schemas`
 	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("查询 schemas 失败: %w", err)
+		return nil, fmt.Errorf("查询 schemas 失败: %w", unavailable(err))
 	}
 	defer rows.Close()
 
 	var schemas []*models.Schema
 	for rows.Next() {
 		var schema models.Schema
-		var fieldsJSON []byte
+		var fieldsJSON, optionsJSON []byte
 		err := rows.Scan(
 			&schema.Project,
 			&schema.Table,
 			&schema.Description,
 			&fieldsJSON,
+			&optionsJSON,
 			&schema.CreatedAt,
 			&schema.UpdatedAt,
 		)
@@ -345,6 +560,9 @@ func (s *PostgresStorage) ListSchemas(ctx context.Context) ([]*models.Schema, er
 			return nil, fmt.Errorf("解析字段失败: %w", err)
 		}
 		schema.Fields = fields
+		if err := unmarshalSchemaOptions(optionsJSON, &schema); err != nil {
+			return nil, err
+		}
 		schemas = append(schemas, &schema)
 	}
 
@@ -353,6 +571,9 @@ func (s *PostgresStorage) ListSchemas(ctx context.Context) ([]*models.Schema, er
 
 // BatchInsertLogs 批量插入日志
 func (s *PostgresStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
 	if len(logs) == 0 {
 		return nil
 	}
@@ -375,33 +596,41 @@ func (s *PostgresStorage) BatchInsertLogs(ctx context.Context, project, table st
 	// 使用事务批量插入
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
 	// 构建表名
-	tableName := fmt.Sprintf("%s.%s_%s", quote(s.schema), project, table)
+	tableName := s.logTable(project, table)
 
 	// 准备字段列表
 	var columns []string
 	// 添加基础字段
-	columns = append(columns, "project", "table_name", "timestamp")
-
-	// 默认字段列表
-	defaultFieldNames := []string{"level", "message", "ip"}
+	columns = append(columns, "id", "project", "table_name", "timestamp")
 
 	// 检查schema中是否已定义默认字段
-	schemaFieldNames := make(map[string]bool)
+	schemaFields := make(map[string]*models.Field)
 	for _, field := range schema.Fields {
-		schemaFieldNames[field.Name] = true
+		schemaFields[field.Name] = field
 	}
 
 	// 添加未在schema中定义的默认字段
-	for _, fieldName := range defaultFieldNames {
-		if !schemaFieldNames[fieldName] {
+	for _, fieldName := range postgresDefaultColumns {
+		if schemaFields[fieldName] == nil {
 			columns = append(columns, fieldName)
 		}
 	}
+	// schema 自定义了 tags 字段时按普通字段处理
+	tagsColumn := ""
+	if schema.StoresTags() {
+		tagsColumn = models.TagsColumn
+		columns = append(columns, tagsColumn)
+	}
+	ingestColumn := ""
+	if schema.StoresIngestTime() {
+		ingestColumn = models.IngestTimeColumn
+		columns = append(columns, ingestColumn)
+	}
 
 	// 添加自定义字段
 	for _, field := range schema.Fields {
@@ -416,7 +645,13 @@ func (s *PostgresStorage) BatchInsertLogs(ctx context.Context, project, table st
 	}
 
 	// 批量插入
+	assignIDs(s.ids, logs)
 	for _, log := range logs {
+		// 取消或超时后不再继续写入，事务由 defer 回滚
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("批量写入已中止: %w", err)
+		}
+
 		// 验证日志数据
 		if err := schema.ValidateLogEntry(log); err != nil {
 			return fmt.Errorf("日志数据验证失败: %w", err)
@@ -433,18 +668,38 @@ func (s *PostgresStorage) BatchInsertLogs(ctx context.Context, project, table st
 
 			// 根据字段名获取对应的值
 			switch col {
+			case "id":
+				value = log.ID
 			case "project":
 				value = log.Project
 			case "table_name":
 				value = log.Table
 			case "timestamp":
-				value = log.Timestamp
+				value = log.Timestamp.UTC()
 			case "level":
 				value = log.Level
 			case "message":
 				value = log.Message
 			case "ip":
 				value = log.IP
+				// schema 定义的 ip 字段优先使用字段值，INET 列不接受空字符串
+				if field := schemaFields["ip"]; field != nil {
+					fieldValue, ok := log.Fields["ip"]
+					if !ok && log.IP != "" {
+						fieldValue = log.IP
+					}
+					if value, err = encodeFieldValue("postgres", field, fieldValue); err != nil {
+						return err
+					}
+				}
+			case tagsColumn:
+				tags, err := tagsValue(log.Tags)
+				if err != nil {
+					return err
+				}
+				value = tags
+			case ingestColumn:
+				value = timestampValue(log.IngestTime)
 			default:
 				// 处理自定义字段
 				if restField != nil && col == restField.Name {
@@ -460,15 +715,10 @@ func (s *PostgresStorage) BatchInsertLogs(ctx context.Context, project, table st
 						value = "{}"
 					}
 				} else if fieldValue, ok := log.Fields[col]; ok {
-					// 如果是 map 类型，转换为 JSON 字符串
-					if m, ok := fieldValue.(map[string]interface{}); ok {
-						jsonBytes, err := json.Marshal(m)
-						if err != nil {
-							return fmt.Errorf("序列化字段 %s 失败: %w", col, err)
-						}
-						value = string(jsonBytes)
-					} else {
-						value = fieldValue
+					// 对象与 map 转换为 JSON 字符串，基本类型数组转换为 PostgreSQL 数组
+					value, err = encodeFieldValue("postgres", schemaFields[col], fieldValue)
+					if err != nil {
+						return err
 					}
 				} else {
 					value = nil
@@ -482,26 +732,21 @@ func (s *PostgresStorage) BatchInsertLogs(ctx context.Context, project, table st
 
 		query := fmt.Sprintf(`
 		INSERT INTO %s (%s)
-		VALUES (%s)
-		RETURNING id`,
+		VALUES (%s)`,
 			tableName,
-			strings.Join(columns, ", "),
+			quoteIdents("postgres", columns),
 			strings.Join(placeholders, ", "),
 		)
 
-		s.logger.Info("insert log", zap.String("query", query), zap.Any("values", values))
+		s.logger.Debug("insert log", zap.String("table", tableName), zap.Int("columns", len(columns)))
 
-		var id int64
-		if err := tx.QueryRowContext(ctx, query, values...).Scan(&id); err != nil {
-			return fmt.Errorf("插入日志失败: %w", err)
+		if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+			return fmt.Errorf("插入日志失败: %w", unavailable(err))
 		}
-
-		// 更新日志ID
-		log.ID = int(id)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -509,10 +754,13 @@ func (s *PostgresStorage) BatchInsertLogs(ctx context.Context, project, table st
 
 // DeleteSchema 删除 schema
 func (s *PostgresStorage) DeleteSchema(ctx context.Context, project, table string) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	// 开启事务
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
@@ -523,7 +771,7 @@ func (s *PostgresStorage) DeleteSchema(ctx context.Context, project, table strin
 
 	result, err := tx.ExecContext(ctx, query, project, table)
 	if err != nil {
-		return fmt.Errorf("删除 schema 失败: %w", err)
+		return fmt.Errorf("删除 schema 失败: %w", unavailable(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -531,11 +779,11 @@ func (s *PostgresStorage) DeleteSchema(ctx context.Context, project, table strin
 		return fmt.Errorf("获取影响行数失败: %w", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("schema not found: %s_%s", project, table)
+		return fmt.Errorf("%w: %s_%s", models.ErrSchemaNotFound, project, table)
 	}
 
 	// 删除日志表
-	tableName := fmt.Sprintf("%s.%s_%s", quote(s.schema), project, table)
+	tableName := s.logTable(project, table)
 	dropQuery := fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName)
 	if _, err := tx.ExecContext(ctx, dropQuery); err != nil {
 		return fmt.Errorf("删除日志表失败: %w", err)
@@ -543,14 +791,359 @@ func (s *PostgresStorage) DeleteSchema(ctx context.Context, project, table strin
 
 	// 提交事务
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
 }
 
-var _ Storage = (*PostgresStorage)(nil)
+// ArchiveSchema 删除 schema 记录并将日志表重命名为归档表
+func (s *PostgresStorage) ArchiveSchema(ctx context.Context, project, table string) (*models.ArchivedSchema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return s.archives.archive(ctx, s, project, table, postgresTableName(project, table), s.config.Clock,
+		func(tx *sql.Tx, archived *models.ArchivedSchema) error {
+			return renameTable(ctx, tx, "postgres", s.logTable(project, table), archived.ArchiveTable)
+		})
+}
+
+// ListArchivedSchemas 列出全部归档
+func (s *PostgresStorage) ListArchivedSchemas(ctx context.Context) ([]*models.ArchivedSchema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return s.archives.list(ctx)
+}
+
+// RestoreSchema 恢复最近一次归档的日志表与 schema
+func (s *PostgresStorage) RestoreSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return s.archives.restore(ctx, s, project, table, func(archived *models.ArchivedSchema) error {
+		return renameTable(ctx, s.db, "postgres", quote(s.schema)+"."+quote(archived.ArchiveTable), postgresTableName(project, table))
+	})
+}
+
+// PurgeArchivedSchemas 删除 before 之前归档的日志表
+func (s *PostgresStorage) PurgeArchivedSchemas(ctx context.Context, before time.Time) ([]*models.ArchivedSchema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return s.archives.purge(ctx, before, func(archived *models.ArchivedSchema) error {
+		_, err := s.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+quote(s.schema)+"."+quote(archived.ArchiveTable))
+		return err
+	})
+}
+
+// RenameSchema 重命名 schema 及其日志表，idx_<表名>_ 开头的索引一并重命名
+func (s *PostgresStorage) RenameSchema(ctx context.Context, project, table, newProject, newTable string) (*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return renameSchema(ctx, s.db, "postgres", s, project, table, newProject, newTable, func(tx *sql.Tx, from, to *models.Schema) error {
+		oldName, newName := postgresTableName(project, table), postgresTableName(newProject, newTable)
+		if err := renameTable(ctx, tx, "postgres", s.logTable(project, table), newName); err != nil {
+			return err
+		}
+		indexes, err := columnValues(ctx, tx, `SELECT indexname FROM pg_indexes WHERE schemaname = $1 AND tablename = $2`, s.schema, newName)
+		if err != nil {
+			return fmt.Errorf("查询索引失败: %w", err)
+		}
+		for _, index := range indexes {
+			renamed, ok := renamedIndex(index, oldName, newName)
+			if !ok {
+				continue
+			}
+			query := fmt.Sprintf("ALTER INDEX %s.%s RENAME TO %s", quote(s.schema), quote(index), quote(renamed))
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("重命名索引失败: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// QueryLogs 查询日志，按时间戳升序返回
+func (s *PostgresStorage) QueryLogs(ctx context.Context, project, table string, query map[string]interface{}, limit, offset int) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	// 构建表名
+	tableName := s.logTable(project, table)
+
+	// 构建查询条件
+	conditions := make([]string, 0, len(query))
+	values := make([]interface{}, 0, len(query))
+	paramCount := 1
+
+	for key, value := range query {
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", quote(key), paramCount))
+		values = append(values, value)
+		paramCount++
+	}
+
+	// 构建 SQL 语句
+	statement := fmt.Sprintf("SELECT * FROM %s", tableName)
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += fmt.Sprintf(" ORDER BY timestamp LIMIT %d OFFSET %d", limit, offset)
+
+	var result []map[string]interface{}
+	err := s.reads.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, statement, values...)
+		if err != nil {
+			return fmt.Errorf("查询日志失败: %w", unavailable(err))
+		}
+		defer rows.Close()
+
+		result, err = scanRows(rows)
+		return err
+	})
+	return result, err
+}
+
+// SearchLogs 执行带字段选择与排序的日志查询
+func (s *PostgresStorage) SearchLogs(ctx context.Context, project, table string, query *models.Query) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	err = s.reads.read(ctx, func(db *sql.DB) error {
+		result, err = searchLogs(ctx, db, "postgres", s.logTable(project, table), schema, query)
+		return err
+	})
+	return result, err
+}
+
+// ExplainQuery 返回日志查询的执行计划
+func (s *PostgresStorage) ExplainQuery(ctx context.Context, project, table string, query *models.Query, analyze bool) (*models.QueryPlan, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan *models.QueryPlan
+	err = s.reads.read(ctx, func(db *sql.DB) error {
+		plan, err = explainQuery(ctx, db, "postgres", s.logTable(project, table), schema, query, analyze)
+		return err
+	})
+	return plan, err
+}
+
+// FieldValues 统计字段出现最多的取值
+func (s *PostgresStorage) FieldValues(ctx context.Context, project, table, field string, query *models.Query) (*models.FieldValues, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var values *models.FieldValues
+	err = s.reads.read(ctx, func(db *sql.DB) error {
+		values, err = topFieldValues(ctx, db, "postgres", s.logTable(project, table), schema, field, query)
+		return err
+	})
+	return values, err
+}
+
+// SaveQuery 保存查询
+func (s *PostgresStorage) SaveQuery(ctx context.Context, query *models.SavedQuery) error {
+	return s.sq.save(ctx, query)
+}
+
+// GetSavedQuery 获取保存的查询
+func (s *PostgresStorage) GetSavedQuery(ctx context.Context, owner, name string) (*models.SavedQuery, error) {
+	return s.sq.get(ctx, owner, name)
+}
+
+// ListSavedQueries 列出保存的查询
+func (s *PostgresStorage) ListSavedQueries(ctx context.Context, owner string) ([]*models.SavedQuery, error) {
+	return s.sq.list(ctx, owner)
+}
+
+// DeleteSavedQuery 删除保存的查询
+func (s *PostgresStorage) DeleteSavedQuery(ctx context.Context, owner, name string) error {
+	return s.sq.delete(ctx, owner, name)
+}
+
+// SaveReport 保存定时报表
+func (s *PostgresStorage) SaveReport(ctx context.Context, report *models.Report) error {
+	return s.sq.saveReport(ctx, report)
+}
+
+// GetReport 获取定时报表
+func (s *PostgresStorage) GetReport(ctx context.Context, owner, name string) (*models.Report, error) {
+	return s.sq.getReport(ctx, owner, name)
+}
+
+// ListReports 列出定时报表
+func (s *PostgresStorage) ListReports(ctx context.Context, owner string) ([]*models.Report, error) {
+	return s.sq.listReports(ctx, owner)
+}
+
+// DeleteReport 删除定时报表
+func (s *PostgresStorage) DeleteReport(ctx context.Context, owner, name string) error {
+	return s.sq.deleteReport(ctx, owner, name)
+}
+
+// RecordIssues 累计错误归并问题的出现次数
+func (s *PostgresStorage) RecordIssues(ctx context.Context, issues []*models.Issue) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+	return s.sq.recordIssues(ctx, issues)
+}
+
+// GetIssue 获取问题
+func (s *PostgresStorage) GetIssue(ctx context.Context, project, table, fingerprint string) (*models.Issue, error) {
+	return s.sq.getIssue(ctx, project, table, fingerprint)
+}
+
+// ListIssues 列出问题
+func (s *PostgresStorage) ListIssues(ctx context.Context, filter *models.IssueFilter) ([]*models.Issue, error) {
+	return s.sq.listIssues(ctx, filter)
+}
+
+// SetIssueStatus 更新问题状态
+func (s *PostgresStorage) SetIssueStatus(ctx context.Context, project, table, fingerprint string, status models.IssueStatus) error {
+	return s.sq.setIssueStatus(ctx, project, table, fingerprint, status)
+}
+
+// DeleteIssue 删除问题
+func (s *PostgresStorage) DeleteIssue(ctx context.Context, project, table, fingerprint string) error {
+	return s.sq.deleteIssue(ctx, project, table, fingerprint)
+}
+
+// RecordSlowQuery 记录慢查询
+func (s *PostgresStorage) RecordSlowQuery(ctx context.Context, query *models.SlowQuery) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+	return s.sq.recordSlowQuery(ctx, query)
+}
+
+// ListSlowQueries 列出慢查询
+func (s *PostgresStorage) ListSlowQueries(ctx context.Context, filter *models.SlowQueryFilter) ([]*models.SlowQuery, error) {
+	return s.sq.listSlowQueries(ctx, filter)
+}
+
+// PurgeSlowQueries 删除过期的慢查询记录
+func (s *PostgresStorage) PurgeSlowQueries(ctx context.Context, before time.Time) (int64, error) {
+	return s.sq.purgeSlowQueries(ctx, before)
+}
+
+// CountMatching 统计匹配过滤条件的日志数
+func (s *PostgresStorage) CountMatching(ctx context.Context, project, table string, filter *models.LogFilter) (int64, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return 0, err
+	}
+
+	return countMatching(ctx, s.db, "postgres", s.logTable(project, table), schema, filter)
+}
+
+// DeleteLogs 删除匹配过滤条件的日志
+func (s *PostgresStorage) DeleteLogs(ctx context.Context, project, table string, filter *models.LogFilter, limit int64) (int64, error) {
+	return s.mutateLogs(ctx, project, table, filter, nil, limit)
+}
+
+// UpdateLogs 修改匹配过滤条件的日志字段
+func (s *PostgresStorage) UpdateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	return s.mutateLogs(ctx, project, table, filter, set, limit)
+}
+
+// mutateLogs 删除或修改匹配的日志
+func (s *PostgresStorage) mutateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return 0, err
+	}
+
+	return mutateLogs(ctx, s.db, "postgres", s.logTable(project, table), schema, filter, set, limit)
+}
+
+// TableSizes 返回各日志表的大小：普通表来自 pg_total_relation_size 与 pg_class.reltuples，
+// hypertable 来自 hypertable_detailed_size 与 approximate_row_count，包含全部 chunk。
+// 行数在 ANALYZE 之前可能为 0
+func (s *PostgresStorage) TableSizes(ctx context.Context) ([]*models.TableSize, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	query := `
+	SELECT GREATEST(c.reltuples, 0)::bigint, pg_table_size(c.oid), pg_indexes_size(c.oid), pg_total_relation_size(c.oid)
+	FROM pg_class c WHERE c.oid = to_regclass($1)`
+	source := "pg_total_relation_size"
+	if s.timescale {
+		query = `
+		SELECT approximate_row_count($1::regclass), COALESCE(table_bytes + toast_bytes, 0), COALESCE(index_bytes, 0), COALESCE(total_bytes, 0)
+		FROM hypertable_detailed_size($1::regclass)`
+		source = "hypertable_detailed_size"
+	}
+	return tableSizes(ctx, s, source, func(ctx context.Context, schema *models.Schema) (*models.TableSize, error) {
+		size := &models.TableSize{}
+		err := s.db.QueryRowContext(ctx, query, s.logTable(schema.Project, schema.Table)).Scan(
+			&size.Rows, &size.DataBytes, &size.IndexBytes, &size.TotalBytes)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, unavailable(err)
+		}
+		return size, nil
+	})
+}
+
+var (
+	_ Storage           = (*PostgresStorage)(nil)
+	_ LogQuerier        = (*PostgresStorage)(nil)
+	_ SavedQueryStore   = (*PostgresStorage)(nil)
+	_ ReportStore       = (*PostgresStorage)(nil)
+	_ IssueStore        = (*PostgresStorage)(nil)
+	_ LogMutator        = (*PostgresStorage)(nil)
+	_ SizeReporter      = (*PostgresStorage)(nil)
+	_ ContinuousQuerier = (*PostgresStorage)(nil)
+	_ BaseColumnLister  = (*PostgresStorage)(nil)
+)
+
+// postgresDefaultColumns PostgreSQL 日志表在 schema 未定义同名字段时额外创建的列
+var postgresDefaultColumns = []string{"level", "message", "ip"}
+
+// BaseColumns 返回日志表的基础列：models.BaseColumns 与未由 schema 定义的 level、message、ip
+func (s *PostgresStorage) BaseColumns(schema *models.Schema) []string {
+	columns := append([]string(nil), models.BaseColumns...)
+	for _, name := range postgresDefaultColumns {
+		if schema.GetField(name) == nil {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// logTable 返回日志表 <schema>.<project>_<table> 的引用标识符
+func (s *PostgresStorage) logTable(project, table string) string {
+	return quote(s.schema) + "." + quote(postgresTableName(project, table))
+}
+
+// postgresTableName 返回日志表名 <project>_<table>（未引用）
+func postgresTableName(project, table string) string {
+	return fmt.Sprintf("%s_%s", project, table)
+}
 
+// quote 引用 PostgreSQL 标识符
 func quote(s string) string {
-	return strconv.Quote(s)
+	return quoteIdent("postgres", s)
 }
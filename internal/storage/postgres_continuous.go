@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// zeroTimeEpoch time.Time 零值（公元 1 年 1 月 1 日）到 Unix 纪元的秒数。时间桶从零值起对齐，
+// 与其他后端使用的 time.Truncate 相同，多天的时间桶也落在同一起点
+const zeroTimeEpoch = 62135596800
+
+// ceilBucket 返回不早于 t 的第一个时间桶起点，bucket >= t 等价于 timestamp >= ceilBucket(t)
+func ceilBucket(t time.Time, size time.Duration) time.Time {
+	start := t.UTC().Truncate(size)
+	if start.Before(t) {
+		start = start.Add(size)
+	}
+	return start
+}
+
+// postgresAggregateQuery 返回从原始日志计算 bucket 位于 [from, to) 的聚合结果的查询，零值表示不限制。
+// 列名与 SQLite/MySQL 侧表的查询结果相同，百分位数使用 percentile_cont，标准差使用 stddev_samp
+func postgresAggregateQuery(tableName string, schema *models.Schema, agg *models.Aggregate, from, to time.Time) (string, []interface{}, error) {
+	size, err := agg.BucketSize()
+	if err != nil {
+		return "", nil, err
+	}
+	seconds := strconv.FormatFloat(size.Seconds(), 'f', -1, 64)
+	bucket := fmt.Sprintf("to_timestamp(floor((CAST(extract(epoch FROM timestamp) AS NUMERIC) + %[2]d) / %[1]s) * %[1]s - %[2]d)",
+		seconds, zeroTimeEpoch)
+
+	selects := []string{bucket + " AS bucket"}
+	keys := []string{"1"}
+	for i, name := range agg.GroupBy {
+		// 与侧表一致，分组值为字符串，缺失时为空字符串；未定义 level 字段时 level 分组为空
+		expr := "''"
+		if schema.GetField(name) != nil {
+			expr = fmt.Sprintf("COALESCE(CAST(%s AS TEXT), '')", quote(name))
+		}
+		selects = append(selects, expr+" AS "+quote(name))
+		keys = append(keys, strconv.Itoa(i+2))
+	}
+	selects = append(selects, "COUNT(*) AS count")
+
+	seen := make(map[string]bool)
+	for _, metric := range agg.Metrics {
+		if metric.Func == models.AggregateCount || seen[metric.Column()] {
+			continue
+		}
+		seen[metric.Column()] = true
+		value := fmt.Sprintf("CAST(%s AS DOUBLE PRECISION)", quote(metric.Field))
+		var expr string
+		switch metric.Func {
+		case models.AggregateSum, models.AggregateMin, models.AggregateMax, models.AggregateAvg:
+			expr = fmt.Sprintf("%s(%s)", strings.ToUpper(string(metric.Func)), value)
+		case models.AggregateStddev:
+			expr = fmt.Sprintf("stddev_samp(%s)", value)
+		default:
+			q, ok := metric.Func.Quantile()
+			if !ok {
+				return "", nil, fmt.Errorf("%w: unsupported aggregate function: %s", models.ErrValidation, metric.Func)
+			}
+			expr = fmt.Sprintf("percentile_cont(%g) WITHIN GROUP (ORDER BY %s)", q, value)
+		}
+		selects = append(selects, expr+" AS "+quote(metric.Column()))
+	}
+
+	var conditions []string
+	var args []interface{}
+	if !from.IsZero() {
+		args = append(args, ceilBucket(from, size))
+		conditions = append(conditions, fmt.Sprintf("timestamp >= $%d", len(args)))
+	}
+	if !to.IsZero() {
+		args = append(args, ceilBucket(to, size))
+		conditions = append(conditions, fmt.Sprintf("timestamp < $%d", len(args)))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), tableName)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" GROUP BY %s ORDER BY %s", strings.Join(keys, ", "), strings.Join(keys, ", "))
+	return query, args, nil
+}
+
+// QueryAggregate 从原始日志计算持续聚合结果。PostgreSQL 不维护聚合表，
+// 结果覆盖保留期内的全部日志，也包括定义聚合之前写入的日志
+func (s *PostgresStorage) QueryAggregate(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+	agg, ok := schema.GetAggregate(name)
+	if !ok {
+		return nil, fmt.Errorf("aggregate not found: %s", name)
+	}
+	query, args, err := postgresAggregateQuery(s.logTable(project, table), schema, agg, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	err = s.reads.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("查询聚合失败: %w", unavailable(err))
+		}
+		defer rows.Close()
+		result, err = scanRows(rows)
+		return err
+	})
+	return result, err
+}
@@ -127,6 +127,14 @@ func TestPostgresStorage_NewPostgresStorage(t *testing.T) {
 	assert.NotNil(t, storage.logger)
 }
 
+func TestPostgresStorage_BaseColumns(t *testing.T) {
+	schema := &models.Schema{Project: "app", Table: "requests", Fields: []*models.Field{{Name: "message", Type: models.FieldTypeString}}}
+	store := WithRetry(NewPostgresStorage(testConfig), RetryConfig{Enabled: true}, nil, nil)
+	// 未由 schema 定义的 level 与 ip 是 PostgreSQL 日志表的基础列，message 是普通字段
+	assert.Equal(t, append(append([]string(nil), models.BaseColumns...), "level", "ip"), BaseColumns(store, schema))
+	assert.Equal(t, models.BaseColumns, BaseColumns(NewSQLiteStorage(Config{}), schema))
+}
+
 func TestPostgresStorage_Initialize(t *testing.T) {
 	storage := NewPostgresStorage(testConfig)
 
@@ -186,6 +194,7 @@ func TestPostgresStorage_GetSchema(t *testing.T) {
 	_, err = storage.GetSchema(context.Background(), "nonexistent", "table")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "schema not found")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
 }
 
 func TestPostgresStorage_InsertLog(t *testing.T) {
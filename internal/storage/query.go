@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// defaultQueryLimit 未指定 limit 时返回的最大条数
+const defaultQueryLimit = 100
+
+// SavedQueryStore 保存查询的可选能力
+type SavedQueryStore interface {
+	SaveQuery(ctx context.Context, query *models.SavedQuery) error
+	GetSavedQuery(ctx context.Context, owner, name string) (*models.SavedQuery, error)
+	ListSavedQueries(ctx context.Context, owner string) ([]*models.SavedQuery, error)
+	DeleteSavedQuery(ctx context.Context, owner, name string) error
+}
+
+// placeholder 返回第 n 个（从 1 开始）参数占位符
+func placeholder(dialect string, n int) string {
+	if dialect == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// searchLogs 执行 models.Query，列名与嵌套路径需事先通过 Query.Validate 校验
+func searchLogs(ctx context.Context, db *sql.DB, dialect, tableName string, schema *models.Schema, q *models.Query) ([]map[string]interface{}, error) {
+	query, values, err := searchQuery(dialect, tableName, schema, q)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, fmt.Errorf("查询日志失败: %w", unavailable(err))
+	}
+	defer rows.Close()
+
+	results, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	decodeTags(results)
+	decodeNested(dialect, schema, results)
+	decodeIPs(dialect, schema, results)
+	return results, nil
+}
+
+// searchQuery 构建 models.Query 对应的 SQL 与参数
+func searchQuery(dialect, tableName string, schema *models.Schema, q *models.Query) (string, []interface{}, error) {
+	columns := "*"
+	if len(q.Fields) > 0 {
+		columns = quoteIdents(dialect, q.Fields)
+	}
+
+	conditions, values, err := queryConditions(dialect, schema, q)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", columns, tableName)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// 未指定排序时按 timestamp 降序，避免 LIMIT/OFFSET 分页结果不稳定
+	keys := q.SortKeys()
+	order := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key.Desc {
+			order = append(order, quoteIdent(dialect, key.Column)+" DESC")
+		} else {
+			order = append(order, quoteIdent(dialect, key.Column)+" ASC")
+		}
+	}
+	query += " ORDER BY " + strings.Join(order, ", ")
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, q.Offset)
+	return query, values, nil
+}
+
+// queryConditions 构建 models.Query 的过滤、标签与时间范围条件
+func queryConditions(dialect string, schema *models.Schema, q *models.Query) ([]string, []interface{}, error) {
+	conditions, values, err := filterConditions(dialect, schema, q.Filter, q.Tags, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if q.From != nil {
+		values = append(values, q.From.UTC())
+		conditions = append(conditions, fmt.Sprintf("%s >= %s", quoteIdent(dialect, "timestamp"), placeholder(dialect, len(values))))
+	}
+	if q.To != nil {
+		values = append(values, q.To.UTC())
+		conditions = append(conditions, fmt.Sprintf("%s < %s", quoteIdent(dialect, "timestamp"), placeholder(dialect, len(values))))
+	}
+	return conditions, values, nil
+}
+
+// filterConditions 构建等值过滤与标签过滤条件，占位符编号接在 values 已有的参数之后
+func filterConditions(dialect string, schema *models.Schema, filter map[string]interface{}, tags map[string]string, values []interface{}) ([]string, []interface{}, error) {
+	conditions := make([]string, 0, len(filter)+len(tags))
+	for key, value := range filter {
+		path, err := schema.ResolvePath(key)
+		switch {
+		case err == nil && path.Nested():
+			// 对象内部的键与数组字段按嵌套方式过滤
+			conditions, values, err = nestedCondition(dialect, path, value, conditions, values)
+		case err == nil && path.Field.Type == models.FieldTypeIP:
+			// IP 字段支持 CIDR 网段过滤
+			conditions, values, err = ipCondition(dialect, quoteIdent(dialect, key), value, conditions, values)
+		case err == nil && path.Field.Type == models.FieldTypeDuration:
+			// 时长字段以纳秒比较，过滤值可以是 1.5s 这样的时长字符串
+			var nanos int64
+			if nanos, err = models.DurationNanos(value); err != nil {
+				return nil, nil, fmt.Errorf("%w: %w", models.ErrValidation, err)
+			}
+			values = append(values, nanos)
+			conditions = append(conditions, fmt.Sprintf("%s = %s", quoteIdent(dialect, key), placeholder(dialect, len(values))))
+		default:
+			values = append(values, value)
+			conditions = append(conditions, fmt.Sprintf("%s = %s", quoteIdent(dialect, key), placeholder(dialect, len(values))))
+			err = nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return tagConditions(dialect, tags, conditions, values)
+}
+
+// tagConditions 追加标签过滤条件，键已由 Query.Validate 校验
+func tagConditions(dialect string, tags map[string]string, conditions []string, values []interface{}) ([]string, []interface{}, error) {
+	if len(tags) == 0 {
+		return conditions, values, nil
+	}
+
+	// PostgreSQL 使用 @> 包含判断，可命中 tags 列的 GIN 索引
+	if dialect == "postgres" {
+		data, err := json.Marshal(tags)
+		if err != nil {
+			return nil, nil, fmt.Errorf("序列化标签失败: %w", err)
+		}
+		values = append(values, string(data))
+		conditions = append(conditions, fmt.Sprintf("%s @> %s::jsonb", models.TagsColumn, placeholder(dialect, len(values))))
+		return conditions, values, nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var condition string
+		switch dialect {
+		case "clickhouse":
+			values = append(values, key, tags[key])
+			condition = fmt.Sprintf("%s[?] = ?", models.TagsColumn)
+		case "mysql":
+			values = append(values, `$."`+key+`"`, tags[key])
+			condition = fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, ?)) = ?", models.TagsColumn)
+		default:
+			values = append(values, `$."`+key+`"`, tags[key])
+			condition = fmt.Sprintf("json_extract(%s, ?) = ?", models.TagsColumn)
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, values, nil
+}
+
+// decodeTags 将以 JSON 文本返回的 tags 列解析为键值对
+func decodeTags(rows []map[string]interface{}) {
+	for _, row := range rows {
+		text, ok := row[models.TagsColumn].(string)
+		if !ok {
+			continue
+		}
+		var tags map[string]string
+		if err := json.Unmarshal([]byte(text), &tags); err == nil {
+			row[models.TagsColumn] = tags
+		}
+	}
+}
+
+// savedQueries 基于 SQL 的保存查询存储，支持 sqlite、mysql 与 postgres
+type savedQueries struct {
+	db      *sql.DB
+	dialect string
+}
+
+// newSavedQueries 创建保存查询存储
+func newSavedQueries(db *sql.DB, dialect string) *savedQueries {
+	return &savedQueries{db: db, dialect: dialect}
+}
+
+// createTable 创建保存查询表、定时报表表、问题表及慢查询表
+func (sq *savedQueries) createTable(ctx context.Context) error {
+	text, ts := "TEXT", "TIMESTAMP"
+	switch sq.dialect {
+	case "mysql":
+		ts = "DATETIME(6)"
+	case "postgres":
+		text, ts = "JSONB", "TIMESTAMP WITH TIME ZONE"
+	}
+
+	query := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS saved_queries (
+		owner VARCHAR(255),
+		name VARCHAR(255),
+		project VARCHAR(255),
+		table_name VARCHAR(255),
+		description TEXT,
+		query %s,
+		created_at %s,
+		updated_at %s,
+		PRIMARY KEY (owner, name)
+	)`, text, ts, ts)
+
+	if _, err := sq.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("创建保存查询表失败: %w", err)
+	}
+	if err := sq.createReportTable(ctx); err != nil {
+		return err
+	}
+	if err := sq.createIssueTable(ctx); err != nil {
+		return err
+	}
+	return sq.createSlowQueryTable(ctx)
+}
+
+// save 创建或更新保存的查询，保留原创建时间
+func (sq *savedQueries) save(ctx context.Context, q *models.SavedQuery) error {
+	data, err := json.Marshal(q.Query)
+	if err != nil {
+		return fmt.Errorf("序列化查询失败: %w", err)
+	}
+
+	p := func(n int) string { return placeholder(sq.dialect, n) }
+	query := fmt.Sprintf(`INSERT INTO saved_queries (owner, name, project, table_name, description, query, created_at, updated_at)
+	VALUES (%s, %s, %s, %s, %s, %s, %s, %s)`, p(1), p(2), p(3), p(4), p(5), p(6), p(7), p(8))
+	if sq.dialect == "mysql" {
+		query += ` ON DUPLICATE KEY UPDATE project = VALUES(project), table_name = VALUES(table_name),
+		description = VALUES(description), query = VALUES(query), updated_at = VALUES(updated_at)`
+	} else {
+		query += ` ON CONFLICT (owner, name) DO UPDATE SET project = excluded.project, table_name = excluded.table_name,
+		description = excluded.description, query = excluded.query, updated_at = excluded.updated_at`
+	}
+
+	_, err = sq.db.ExecContext(ctx, query, q.Owner, q.Name, q.Project, q.Table, q.Description, string(data), q.CreatedAt, q.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("保存查询失败: %w", unavailable(err))
+	}
+	return nil
+}
+
+// get 获取保存的查询
+func (sq *savedQueries) get(ctx context.Context, owner, name string) (*models.SavedQuery, error) {
+	query := fmt.Sprintf(`SELECT owner, name, project, table_name, description, query, created_at, updated_at
+	FROM saved_queries WHERE owner = %s AND name = %s`, placeholder(sq.dialect, 1), placeholder(sq.dialect, 2))
+
+	queries, err := sq.scan(sq.db.QueryContext(ctx, query, owner, name))
+	if err != nil {
+		return nil, err
+	}
+	if len(queries) == 0 {
+		return nil, models.ErrSavedQueryNotFound
+	}
+	return queries[0], nil
+}
+
+// list 列出指定所有者的保存查询
+func (sq *savedQueries) list(ctx context.Context, owner string) ([]*models.SavedQuery, error) {
+	query := fmt.Sprintf(`SELECT owner, name, project, table_name, description, query, created_at, updated_at
+	FROM saved_queries WHERE owner = %s ORDER BY name`, placeholder(sq.dialect, 1))
+
+	return sq.scan(sq.db.QueryContext(ctx, query, owner))
+}
+
+// delete 删除保存的查询
+func (sq *savedQueries) delete(ctx context.Context, owner, name string) error {
+	query := fmt.Sprintf(`DELETE FROM saved_queries WHERE owner = %s AND name = %s`,
+		placeholder(sq.dialect, 1), placeholder(sq.dialect, 2))
+
+	result, err := sq.db.ExecContext(ctx, query, owner, name)
+	if err != nil {
+		return fmt.Errorf("删除保存查询失败: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return models.ErrSavedQueryNotFound
+	}
+	return nil
+}
+
+// scan 解析保存查询的结果集
+func (sq *savedQueries) scan(rows *sql.Rows, err error) ([]*models.SavedQuery, error) {
+	if err != nil {
+		return nil, fmt.Errorf("查询保存查询失败: %w", unavailable(err))
+	}
+	defer rows.Close()
+
+	queries := make([]*models.SavedQuery, 0)
+	for rows.Next() {
+		var (
+			q           models.SavedQuery
+			description sql.NullString
+			data        []byte
+			createdAt   time.Time
+			updatedAt   time.Time
+		)
+		if err := rows.Scan(&q.Owner, &q.Name, &q.Project, &q.Table, &description, &data, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("扫描保存查询失败: %w", err)
+		}
+		if err := json.Unmarshal(data, &q.Query); err != nil {
+			return nil, fmt.Errorf("解析查询失败: %w", err)
+		}
+		q.Description = description.String
+		q.CreatedAt = createdAt
+		q.UpdatedAt = updatedAt
+		queries = append(queries, &q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %w", err)
+	}
+	return queries, nil
+}
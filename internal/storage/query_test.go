@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestSQLiteSavedQueries(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "service", Type: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeInt},
+		},
+	}
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	logs := make([]*models.LogEntry, 0, 3)
+	for i, service := range []string{"api", "web", "api"} {
+		logs = append(logs, &models.LogEntry{
+			Project:   "app",
+			Table:     "requests",
+			Level:     "info",
+			Message:   "request",
+			Timestamp: time.Now(),
+			Fields:    map[string]interface{}{"service": service, "latency": int64(10 * (i + 1))},
+			Tags:      map[string]string{"env": []string{"prod", "prod", "staging"}[i]},
+		})
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", logs))
+	for _, log := range logs {
+		assert.Len(t, log.ID, 26, "ULID should be assigned on insert")
+	}
+
+	saved := &models.SavedQuery{
+		Name:    "slow_api",
+		Owner:   "user:alice",
+		Project: "app",
+		Table:   "requests",
+		Query: models.Query{
+			Filter: map[string]interface{}{"service": "${service}"},
+			Fields: []string{"service", "latency"},
+			Sort:   []string{"-latency"},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	require.NoError(t, saved.Validate(schema, models.BaseColumns))
+	require.NoError(t, store.SaveQuery(ctx, saved))
+
+	got, err := store.GetSavedQuery(ctx, "user:alice", "slow_api")
+	require.NoError(t, err)
+	assert.Equal(t, saved.Query, got.Query)
+
+	_, err = store.GetSavedQuery(ctx, "user:bob", "slow_api")
+	assert.ErrorIs(t, err, models.ErrSavedQueryNotFound)
+
+	list, err := store.ListSavedQueries(ctx, "user:alice")
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	query, err := got.Query.Bind(map[string]string{"service": "api"})
+	require.NoError(t, err)
+	rows, err := store.SearchLogs(ctx, "app", "requests", query)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, map[string]interface{}{"service": "api", "latency": int64(30)}, rows[0])
+	assert.Equal(t, int64(10), rows[1]["latency"])
+
+	// 标签过滤，tags 列以键值对返回
+	rows, err = store.SearchLogs(ctx, "app", "requests", &models.Query{
+		Tags:   map[string]string{"env": "prod"},
+		Fields: []string{"latency", "tags"},
+		Sort:   []string{"latency"},
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, map[string]interface{}{"latency": int64(10), "tags": map[string]string{"env": "prod"}}, rows[0])
+	assert.Equal(t, int64(20), rows[1]["latency"])
+
+	require.NoError(t, store.DeleteSavedQuery(ctx, "user:alice", "slow_api"))
+	assert.ErrorIs(t, store.DeleteSavedQuery(ctx, "user:alice", "slow_api"), models.ErrSavedQueryNotFound)
+}
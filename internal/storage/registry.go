@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"pkg.blksails.net/logs/internal/logging"
+)
+
+// Factory 根据配置创建存储后端，返回的存储尚未初始化
+type Factory func(config Config) (Storage, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+func init() {
+	Register("postgres", func(config Config) (Storage, error) { return NewPostgresStorage(config), nil })
+	Register("mysql", func(config Config) (Storage, error) { return NewMySQLStorage(config), nil })
+	Register("sqlite", func(config Config) (Storage, error) { return NewSQLiteStorage(config), nil })
+	Register("clickhouse", func(config Config) (Storage, error) { return NewClickHouseStorage(config), nil })
+	Register("file", func(config Config) (Storage, error) { return NewFileStorage(config), nil })
+}
+
+// Register 注册名为 name 的存储后端，通常在后端所在包的 init 中调用。
+// 名称为空、factory 为 nil 或重复注册时 panic
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if name == "" {
+		panic("storage: Register with empty name")
+	}
+	if factory == nil {
+		panic("storage: Register factory is nil for " + name)
+	}
+	if _, dup := factories[name]; dup {
+		panic("storage: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// Registered 返回已注册的存储后端名称，按字母排序
+func Registered() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New 按 config.Type 创建并初始化存储后端，配置了重试时返回 WithRetry 包装后的存储
+func New(ctx context.Context, config Config) (Storage, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[config.Type]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage type %q (available: %s)", config.Type, strings.Join(Registered(), ", "))
+	}
+
+	store, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("create %s storage: %w", config.Type, err)
+	}
+	if err := store.Initialize(ctx); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("initialize %s storage: %w", config.Type, err)
+	}
+	return WithRetry(store, config.Retry(), logging.Component(config.Logger, "storage"), config.Clock), nil
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// initStorage 记录 Initialize 与 Close 调用的存储，只实现 Storage 接口
+type initStorage struct {
+	Storage
+	options     map[string]interface{}
+	initErr     error
+	initialized bool
+	closed      bool
+}
+
+func (s *initStorage) Initialize(ctx context.Context) error {
+	s.initialized = true
+	return s.initErr
+}
+
+func (s *initStorage) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestRegistered(t *testing.T) {
+	names := Registered()
+	for _, name := range []string{"clickhouse", "file", "mysql", "postgres", "sqlite"} {
+		assert.Contains(t, names, name)
+	}
+	assert.IsIncreasing(t, names)
+
+	assert.Panics(t, func() { Register("sqlite", func(Config) (Storage, error) { return nil, nil }) })
+	assert.Panics(t, func() { Register("", func(Config) (Storage, error) { return nil, nil }) })
+	assert.Panics(t, func() { Register("registry-nil", nil) })
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := New(ctx, Config{Type: "sqlite", SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")}})
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Ping(ctx))
+	assert.IsType(t, &SQLiteStorage{}, store)
+
+	store, err = New(ctx, Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db"), Retry: RetryConfig{Enabled: true}},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	assert.IsType(t, &RetryStorage{}, store)
+
+	_, err = New(ctx, Config{Type: "cassandra"})
+	assert.ErrorContains(t, err, `unsupported storage type "cassandra"`)
+	assert.ErrorContains(t, err, "available: clickhouse, file")
+}
+
+func TestNewCustomBackend(t *testing.T) {
+	ctx := context.Background()
+	registerOnce.Do(func() { Register("registry-test", newRegistryTestStorage) })
+	assert.Contains(t, Registered(), "registry-test")
+
+	store, err := New(ctx, Config{Type: "registry-test", Options: map[string]interface{}{"endpoint": "memory://"}})
+	require.NoError(t, err)
+	created := store.(*initStorage)
+	assert.True(t, created.initialized)
+	assert.Equal(t, "memory://", created.options["endpoint"])
+
+	_, err = New(ctx, Config{Type: "registry-test", Options: map[string]interface{}{"fail": true}})
+	assert.EqualError(t, err, "create registry-test storage: bad options")
+
+	_, err = New(ctx, Config{Type: "registry-test", Options: map[string]interface{}{"init_error": true}})
+	assert.EqualError(t, err, "initialize registry-test storage: unreachable")
+	require.NotNil(t, lastRegistryTestStorage)
+	assert.True(t, lastRegistryTestStorage.closed, "failed backends are closed")
+}
+
+var (
+	registerOnce            sync.Once
+	lastRegistryTestStorage *initStorage
+)
+
+// newRegistryTestStorage 测试用的自定义后端
+func newRegistryTestStorage(config Config) (Storage, error) {
+	if config.Options["fail"] == true {
+		return nil, errors.New("bad options")
+	}
+	store := &initStorage{options: config.Options}
+	if config.Options["init_error"] == true {
+		store.initErr = errors.New("unreachable")
+	}
+	lastRegistryTestStorage = store
+	return store, nil
+}
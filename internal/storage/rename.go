@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// SchemaRenamer 重命名 schema 的可选能力：更新 schema 记录并重命名日志表、索引与持续聚合表，
+// 已写入的日志随表一起保留
+type SchemaRenamer interface {
+	// RenameSchema 将 project/table 重命名为 newProject/newTable，目标已存在时返回 ErrSchemaExists
+	RenameSchema(ctx context.Context, project, table, newProject, newTable string) (*models.Schema, error)
+}
+
+// renameSchema 校验新名称后在一个事务中更新 schema 记录，rename 在同一事务提交前重命名日志表。
+// 返回使用新名称的 schema
+func renameSchema(ctx context.Context, db *sql.DB, dialect string, store Storage, project, table, newProject, newTable string,
+	rename func(tx *sql.Tx, from, to *models.Schema) error) (*models.Schema, error) {
+	from, err := store.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+	to := *from
+	to.Project, to.Table = newProject, newTable
+	if err := to.Validate(); err != nil {
+		return nil, err
+	}
+	if project == newProject && table == newTable {
+		return nil, fmt.Errorf("%w: new name is the same as the current name", models.ErrValidation)
+	}
+	if _, err := store.GetSchema(ctx, newProject, newTable); err == nil {
+		return nil, fmt.Errorf("%w: %s_%s", models.ErrSchemaExists, newProject, newTable)
+	} else if !errors.Is(err, models.ErrSchemaNotFound) {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开始事务失败: %w", unavailable(err))
+	}
+	defer tx.Rollback()
+
+	p := func(n int) string { return placeholder(dialect, n) }
+	query := fmt.Sprintf(`UPDATE schemas SET project = %s, table_name = %s WHERE project = %s AND table_name = %s`, p(1), p(2), p(3), p(4))
+	result, err := tx.ExecContext(ctx, query, newProject, newTable, project, table)
+	if err != nil {
+		return nil, fmt.Errorf("更新 schema 失败: %w", unavailable(err))
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("获取影响行数失败: %w", err)
+	} else if rows == 0 {
+		return nil, fmt.Errorf("%w: %s_%s", models.ErrSchemaNotFound, project, table)
+	}
+	if err := rename(tx, from, &to); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", unavailable(err))
+	}
+	return &to, nil
+}
+
+// renameAggregateTables 重命名 schema 的持续聚合表 cq_<project>_<table>_<name> 与 rollup 汇总表
+func renameAggregateTables(ctx context.Context, db execer, dialect string, from, to *models.Schema) error {
+	fromTables, toTables := summaryTables(from), summaryTables(to)
+	for i, summary := range fromTables {
+		if err := renameTable(ctx, db, dialect, quoteIdent(dialect, summary.name), toTables[i].name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renamedIndex 将以 idx_<from>_ 开头的索引名改为以 idx_<to>_ 开头，其他索引返回 false
+func renamedIndex(index, from, to string) (string, bool) {
+	prefix := "idx_" + from + "_"
+	if !strings.HasPrefix(index, prefix) {
+		return "", false
+	}
+	return "idx_" + to + "_" + strings.TrimPrefix(index, prefix), true
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestSQLiteRenameSchema(t *testing.T) {
+	for _, perProject := range []bool{false, true} {
+		t.Run(map[bool]string{false: "shared", true: "per_project"}[perProject], func(t *testing.T) {
+			ctx := context.Background()
+			store := NewSQLiteStorage(Config{
+				Type:   "sqlite",
+				SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db"), PerProject: perProject},
+			})
+			require.NoError(t, store.Initialize(ctx))
+			defer store.Close()
+
+			schema := &models.Schema{
+				Project: "app",
+				Table:   "events",
+				Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString, Indexed: true}},
+				SchemaOptions: models.SchemaOptions{
+					Aggregates: []*models.Aggregate{{
+						Name: "per_minute", Interval: "1m", GroupBy: []string{"name"},
+						Metrics: []*models.AggregateMetric{{Func: models.AggregateCount}},
+					}},
+				},
+			}
+			require.NoError(t, store.CreateSchema(ctx, schema))
+			at := time.Date(2024, 3, 14, 10, 0, 0, 0, time.UTC)
+			require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+				Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: at,
+				Fields: map[string]interface{}{"name": "kept"},
+			}))
+
+			renamed, err := store.RenameSchema(ctx, "app", "events", "app", "audit")
+			require.NoError(t, err)
+			assert.Equal(t, "audit", renamed.Table)
+			_, err = store.GetSchema(ctx, "app", "events")
+			assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+
+			rows, err := store.QueryLogs(ctx, "app", "audit", nil, 10, 0)
+			require.NoError(t, err)
+			require.Len(t, rows, 1)
+			assert.Equal(t, "kept", rows[0]["name"])
+			aggregates, err := store.QueryAggregate(ctx, "app", "audit", "per_minute", time.Time{}, time.Time{})
+			require.NoError(t, err)
+			require.Len(t, aggregates, 1)
+			assert.EqualValues(t, 1, aggregates[0]["count"])
+
+			// 索引随表名一起更新
+			ldb, release, err := store.logDB("app")
+			require.NoError(t, err)
+			indexes, err := columnValues(ctx, ldb.db, `SELECT name FROM sqlite_master WHERE type = 'index' AND name LIKE 'idx_logs_%'`)
+			release()
+			require.NoError(t, err)
+			assert.Equal(t, []string{"idx_logs_app_audit_name"}, indexes)
+
+			// 原名称可以重新创建
+			require.NoError(t, store.CreateSchema(ctx, schema))
+			_, err = store.RenameSchema(ctx, "app", "events", "app", "audit")
+			assert.ErrorIs(t, err, models.ErrSchemaExists)
+			_, err = store.RenameSchema(ctx, "app", "missing", "app", "other")
+			assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+			_, err = store.RenameSchema(ctx, "app", "audit", "app", "Bad-Name")
+			assert.ErrorIs(t, err, models.ErrValidation)
+
+			_, err = store.RenameSchema(ctx, "app", "audit", "web", "audit")
+			if perProject {
+				assert.ErrorIs(t, err, models.ErrValidation)
+				return
+			}
+			require.NoError(t, err)
+			rows, err = store.QueryLogs(ctx, "web", "audit", nil, 10, 0)
+			require.NoError(t, err)
+			assert.Len(t, rows, 1)
+		})
+	}
+}
+
+func TestRenameRetryStorage(t *testing.T) {
+	ctx := context.Background()
+	store, err := New(ctx, Config{Type: "sqlite", SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")}})
+	require.NoError(t, err)
+	defer store.Close()
+
+	renamer, ok := As[SchemaRenamer](store)
+	require.True(t, ok)
+	_, err = renamer.RenameSchema(ctx, "app", "missing", "app", "other")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+
+	_, ok = As[SchemaRenamer](WithRetry(NewFileStorage(Config{}), RetryConfig{}, nil, nil))
+	assert.False(t, ok)
+}
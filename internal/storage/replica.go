@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultReplicaCheckInterval 只读副本默认健康检查间隔
+const defaultReplicaCheckInterval = 10 * time.Second
+
+// replica 单个只读副本
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaSet 读写分离：日志查询、计数与聚合轮询分发到健康的只读副本，
+// 副本全部不可用或查询失败时回退到主库；写入与 schema 读写始终使用主库
+type replicaSet struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+	logger   *zap.Logger
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// openReplicas 连接只读副本并启动健康检查。未配置副本时所有读取使用主库
+func openReplicas(ctx context.Context, driver string, dsns []string, interval time.Duration, primary *sql.DB, logger *zap.Logger) (*replicaSet, error) {
+	if logger == nil {
+		logger = zap.L()
+	}
+	r := &replicaSet{primary: primary, logger: logger, stop: make(chan struct{})}
+	for i, dsn := range dsns {
+		db, err := sql.Open(driver, dsn)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("连接只读副本 %d 失败: %w", i, err)
+		}
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+	if len(r.replicas) == 0 {
+		return r, nil
+	}
+
+	// 启动时不可用的副本在之后的健康检查中恢复
+	r.check(ctx)
+	if interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+	r.wg.Add(1)
+	go r.run(interval)
+	return r, nil
+}
+
+// run 定期检查副本健康状态
+func (r *replicaSet) run(interval time.Duration) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			r.check(ctx)
+			cancel()
+		}
+	}
+}
+
+// check 逐个 ping 副本并更新健康状态
+func (r *replicaSet) check(ctx context.Context) {
+	for i, rep := range r.replicas {
+		healthy := rep.db.PingContext(ctx) == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			r.logger.Info("read replica health changed", zap.Int("replica", i), zap.Bool("healthy", healthy))
+		}
+	}
+}
+
+// reader 轮询选择健康的副本，没有可用副本时返回主库与 nil
+func (r *replicaSet) reader() (*sql.DB, *replica) {
+	n := len(r.replicas)
+	if n == 0 {
+		return r.primary, nil
+	}
+	start := int(r.next.Add(1) % uint64(n))
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+i)%n]
+		if rep.healthy.Load() {
+			return rep.db, rep
+		}
+	}
+	return r.primary, nil
+}
+
+// read 在副本上执行只读操作。副本查询失败时（连接中断，或复制延迟导致新建的表尚未同步）
+// 在主库上重试，连接类错误同时将副本标记为不健康，直到下一次健康检查恢复
+func (r *replicaSet) read(ctx context.Context, fn func(db *sql.DB) error) error {
+	db, rep := r.reader()
+	err := fn(db)
+	if err == nil || rep == nil || ctx.Err() != nil {
+		return err
+	}
+	if isConnectionError(err) {
+		rep.healthy.Store(false)
+	}
+	r.logger.Warn("read replica query failed, falling back to primary", zap.Error(err))
+	return fn(r.primary)
+}
+
+// Close 停止健康检查并关闭副本连接，主库连接由调用方关闭
+func (r *replicaSet) Close() error {
+	if r == nil {
+		return nil
+	}
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	r.wg.Wait()
+	var firstErr error
+	for _, rep := range r.replicas {
+		if err := rep.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReplicaSetReadFallback(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	open := func(name string, statements ...string) *sql.DB {
+		db, err := sql.Open("sqlite3", filepath.Join(dir, name))
+		require.NoError(t, err)
+		for _, statement := range statements {
+			_, err := db.ExecContext(ctx, statement)
+			require.NoError(t, err)
+		}
+		return db
+	}
+	primary := open("primary.db",
+		"CREATE TABLE t (v TEXT)", "INSERT INTO t VALUES ('primary')",
+		"CREATE TABLE fresh (v TEXT)", "INSERT INTO fresh VALUES ('primary')")
+	defer primary.Close()
+	open("replica.db", "CREATE TABLE t (v TEXT)", "INSERT INTO t VALUES ('replica')").Close()
+
+	reads, err := openReplicas(ctx, "sqlite3", []string{filepath.Join(dir, "replica.db")}, time.Hour, primary, zap.NewNop())
+	require.NoError(t, err)
+	defer reads.Close()
+
+	value := func(table string) string {
+		var v string
+		require.NoError(t, reads.read(ctx, func(db *sql.DB) error {
+			return db.QueryRowContext(ctx, "SELECT v FROM "+table).Scan(&v)
+		}))
+		return v
+	}
+
+	assert.Equal(t, "replica", value("t"))
+	// 副本尚未同步的表回退到主库，副本保持健康
+	assert.Equal(t, "primary", value("fresh"))
+	assert.True(t, reads.replicas[0].healthy.Load())
+
+	// 连接失败的副本被标记为不健康，之后的读取直接使用主库
+	require.NoError(t, reads.replicas[0].db.Close())
+	assert.Equal(t, "primary", value("t"))
+	assert.False(t, reads.replicas[0].healthy.Load())
+	db, rep := reads.reader()
+	assert.Same(t, primary, db)
+	assert.Nil(t, rep)
+}
+
+func TestReplicaSetWithoutReplicas(t *testing.T) {
+	primary, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "logs.db"))
+	require.NoError(t, err)
+	defer primary.Close()
+
+	reads, err := openReplicas(context.Background(), "sqlite3", nil, 0, primary, nil)
+	require.NoError(t, err)
+	db, _ := reads.reader()
+	assert.Same(t, primary, db)
+	assert.NoError(t, reads.Close())
+}
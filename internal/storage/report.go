@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// ReportStore 定时报表的可选能力
+type ReportStore interface {
+	SaveReport(ctx context.Context, report *models.Report) error
+	GetReport(ctx context.Context, owner, name string) (*models.Report, error)
+	ListReports(ctx context.Context, owner string) ([]*models.Report, error) // owner 为空时返回全部
+	DeleteReport(ctx context.Context, owner, name string) error
+}
+
+// createReportTable 创建定时报表表，报表定义以 JSON 保存
+func (sq *savedQueries) createReportTable(ctx context.Context) error {
+	text := "TEXT"
+	if sq.dialect == "postgres" {
+		text = "JSONB"
+	}
+
+	query := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS reports (
+		owner VARCHAR(255),
+		name VARCHAR(255),
+		definition %s,
+		PRIMARY KEY (owner, name)
+	)`, text)
+
+	if _, err := sq.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("创建报表表失败: %w", err)
+	}
+	return nil
+}
+
+// saveReport 创建或更新定时报表
+func (sq *savedQueries) saveReport(ctx context.Context, r *models.Report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("序列化报表失败: %w", err)
+	}
+
+	p := func(n int) string { return placeholder(sq.dialect, n) }
+	query := fmt.Sprintf(`INSERT INTO reports (owner, name, definition) VALUES (%s, %s, %s)`, p(1), p(2), p(3))
+	if sq.dialect == "mysql" {
+		query += ` ON DUPLICATE KEY UPDATE definition = VALUES(definition)`
+	} else {
+		query += ` ON CONFLICT (owner, name) DO UPDATE SET definition = excluded.definition`
+	}
+
+	if _, err := sq.db.ExecContext(ctx, query, r.Owner, r.Name, string(data)); err != nil {
+		return fmt.Errorf("保存报表失败: %w", unavailable(err))
+	}
+	return nil
+}
+
+// getReport 获取定时报表
+func (sq *savedQueries) getReport(ctx context.Context, owner, name string) (*models.Report, error) {
+	query := fmt.Sprintf(`SELECT definition FROM reports WHERE owner = %s AND name = %s`,
+		placeholder(sq.dialect, 1), placeholder(sq.dialect, 2))
+
+	reports, err := sq.scanReports(sq.db.QueryContext(ctx, query, owner, name))
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, models.ErrReportNotFound
+	}
+	return reports[0], nil
+}
+
+// listReports 列出指定所有者的定时报表，owner 为空时列出全部
+func (sq *savedQueries) listReports(ctx context.Context, owner string) ([]*models.Report, error) {
+	if owner == "" {
+		return sq.scanReports(sq.db.QueryContext(ctx, `SELECT definition FROM reports ORDER BY owner, name`))
+	}
+	query := fmt.Sprintf(`SELECT definition FROM reports WHERE owner = %s ORDER BY name`, placeholder(sq.dialect, 1))
+	return sq.scanReports(sq.db.QueryContext(ctx, query, owner))
+}
+
+// deleteReport 删除定时报表
+func (sq *savedQueries) deleteReport(ctx context.Context, owner, name string) error {
+	query := fmt.Sprintf(`DELETE FROM reports WHERE owner = %s AND name = %s`,
+		placeholder(sq.dialect, 1), placeholder(sq.dialect, 2))
+
+	result, err := sq.db.ExecContext(ctx, query, owner, name)
+	if err != nil {
+		return fmt.Errorf("删除报表失败: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return models.ErrReportNotFound
+	}
+	return nil
+}
+
+// scanReports 解析定时报表的结果集
+func (sq *savedQueries) scanReports(rows *sql.Rows, err error) ([]*models.Report, error) {
+	if err != nil {
+		return nil, fmt.Errorf("查询报表失败: %w", unavailable(err))
+	}
+	defer rows.Close()
+
+	reports := make([]*models.Report, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("扫描报表失败: %w", err)
+		}
+		var r models.Report
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("解析报表失败: %w", err)
+		}
+		reports = append(reports, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %w", err)
+	}
+	return reports, nil
+}
@@ -0,0 +1,637 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+// ErrCircuitOpen is returned without calling the backend while the circuit
+// breaker is open. It is always wrapped together with ErrBackendUnavailable
+var ErrCircuitOpen = errors.New("storage circuit breaker open")
+
+// RetryConfig 瞬时错误重试与熔断配置
+type RetryConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxAttempts 包含首次调用在内的最大尝试次数，默认 3
+	MaxAttempts int `yaml:"max_attempts,omitempty"`
+	// InitialBackoff 首次重试前的退避上限，之后每次翻倍，实际等待时间在上限的一半到上限之间随机，默认 100ms
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty"`
+	// MaxBackoff 退避上限，默认 2s
+	MaxBackoff time.Duration `yaml:"max_backoff,omitempty"`
+	// FailureThreshold 连续多少次瞬时错误后熔断，默认 5
+	FailureThreshold int `yaml:"failure_threshold,omitempty"`
+	// OpenTimeout 熔断持续时间，到期后放行一次探测请求，默认 30s
+	OpenTimeout time.Duration `yaml:"open_timeout,omitempty"`
+}
+
+// withDefaults 填充未设置的配置项
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 3
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = 100 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 2 * time.Second
+	}
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = 5
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	return c
+}
+
+// Retry 返回当前存储类型的重试配置
+func (c Config) Retry() RetryConfig {
+	switch c.Type {
+	case "postgres":
+		return c.Postgres.Retry
+	case "mysql":
+		return c.MySQL.Retry
+	case "sqlite":
+		return c.SQLite.Retry
+	case "clickhouse":
+		return c.ClickHouse.Retry
+	case "file":
+		return c.File.Retry
+	}
+	return RetryConfig{}
+}
+
+// isTransient 判断错误是否值得重试：连接中断、数据库繁忙、死锁、锁等待超时、
+// 序列化失败与连接数过多。context 取消或超时的请求不再重试
+func isTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	if isConnectionError(err) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1040, 1205, 1213: // too many connections、lock wait timeout、deadlock
+			return true
+		}
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40001", "40P01", "53300", "57P03": // serialization_failure、deadlock_detected、too_many_connections、cannot_connect_now
+			return true
+		}
+	}
+	return false
+}
+
+// breakerState 熔断器状态
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker 熔断器：连续瞬时错误达到阈值后拒绝请求，OpenTimeout 后放行一次探测，
+// 探测成功恢复，失败继续熔断
+type breaker struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	threshold int
+	timeout   time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+}
+
+// allow 判断是否放行请求
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.clock.Now().Sub(b.openedAt) < b.timeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// 探测请求返回前拒绝其他请求
+		return false
+	}
+	return true
+}
+
+// record 记录请求结果。非瞬时错误说明后端可达，与成功同样处理；
+// 调用方取消的请求不计入结果
+func (b *breaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case ctx.Err() != nil:
+		if b.state == breakerHalfOpen {
+			// 允许下一个请求立即探测
+			b.state = breakerOpen
+		}
+	case !isTransient(err):
+		b.state = breakerClosed
+		b.failures = 0
+	default:
+		b.failures++
+		if b.state == breakerHalfOpen || b.failures >= b.threshold {
+			b.state = breakerOpen
+			b.openedAt = b.clock.Now()
+		}
+	}
+}
+
+// RetryStorage 为存储添加瞬时错误重试与熔断。可选能力（LogQuerier、RangeQuerier、ContinuousQuerier、
+// SavedQueryStore、ReportStore、IssueStore、SchemaArchiver、SchemaRenamer、LogMutator、Roller、SizeReporter、
+// QueryExplainer、SlowQueryStore、FieldValuesQuerier、BaseColumnLister）的方法总是存在，判断被包装的存储是否支持需使用 As
+type RetryStorage struct {
+	store   Storage
+	config  RetryConfig
+	breaker *breaker
+	clock   clock.Clock
+	logger  *zap.Logger
+}
+
+// WithRetry 包装存储，config.Enabled 为 false 时原样返回
+func WithRetry(store Storage, config RetryConfig, logger *zap.Logger, c clock.Clock) Storage {
+	if !config.Enabled {
+		return store
+	}
+	if logger == nil {
+		logger = zap.L()
+	}
+	config = config.withDefaults()
+	c = clock.OrReal(c)
+	return &RetryStorage{
+		store:   store,
+		config:  config,
+		breaker: &breaker{clock: c, threshold: config.FailureThreshold, timeout: config.OpenTimeout},
+		clock:   c,
+		logger:  logger,
+	}
+}
+
+// Unwrap 返回被包装的存储
+func (r *RetryStorage) Unwrap() Storage {
+	return r.store
+}
+
+// do 执行操作，瞬时错误按指数退避加随机抖动重试
+func (r *RetryStorage) do(ctx context.Context, op string, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < r.config.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			if sleepErr := r.sleep(ctx, r.backoff(attempt)); sleepErr != nil {
+				return err
+			}
+		}
+		if !r.breaker.allow() {
+			return fmt.Errorf("%w: %w", ErrBackendUnavailable, ErrCircuitOpen)
+		}
+		err = fn()
+		r.breaker.record(ctx, err)
+		if !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+		r.logger.Debug("transient storage error", zap.String("op", op), zap.Int("attempt", attempt+1), zap.Error(err))
+	}
+	return err
+}
+
+// backoff 返回第 attempt 次重试前的等待时间，在退避上限的一半到上限之间随机
+func (r *RetryStorage) backoff(attempt int) time.Duration {
+	d := r.config.InitialBackoff << (attempt - 1)
+	if d <= 0 || d > r.config.MaxBackoff {
+		d = r.config.MaxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// sleep 等待 d，context 结束时提前返回
+func (r *RetryStorage) sleep(ctx context.Context, d time.Duration) error {
+	done := make(chan struct{})
+	timer := r.clock.AfterFunc(d, func() { close(done) })
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	}
+}
+
+// retryValue 执行有返回值的操作
+func retryValue[T any](ctx context.Context, r *RetryStorage, op string, fn func() (T, error)) (T, error) {
+	var result T
+	err := r.do(ctx, op, func() error {
+		var err error
+		result, err = fn()
+		return err
+	})
+	return result, err
+}
+
+// errNotSupported 被包装的存储不支持可选能力
+func errNotSupported(capability string) error {
+	return fmt.Errorf("storage does not support %s", capability)
+}
+
+// Initialize 初始化被包装的存储，不重试
+func (r *RetryStorage) Initialize(ctx context.Context) error {
+	return r.store.Initialize(ctx)
+}
+
+// CreateSchema 创建 schema
+func (r *RetryStorage) CreateSchema(ctx context.Context, schema *models.Schema) error {
+	return r.do(ctx, "CreateSchema", func() error { return r.store.CreateSchema(ctx, schema) })
+}
+
+// UpdateSchema 更新 schema
+func (r *RetryStorage) UpdateSchema(ctx context.Context, schema *models.Schema) error {
+	return r.do(ctx, "UpdateSchema", func() error { return r.store.UpdateSchema(ctx, schema) })
+}
+
+// DeleteSchema 删除 schema
+func (r *RetryStorage) DeleteSchema(ctx context.Context, project, table string) error {
+	return r.do(ctx, "DeleteSchema", func() error { return r.store.DeleteSchema(ctx, project, table) })
+}
+
+// DeleteSchemaRecord 只删除 schema 记录，保留日志表
+func (r *RetryStorage) DeleteSchemaRecord(ctx context.Context, project, table string) error {
+	deleter, ok := r.store.(SchemaRecordDeleter)
+	if !ok {
+		return errNotSupported("schema record deletion")
+	}
+	return r.do(ctx, "DeleteSchemaRecord", func() error { return deleter.DeleteSchemaRecord(ctx, project, table) })
+}
+
+// GetSchema 获取 schema
+func (r *RetryStorage) GetSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	return retryValue(ctx, r, "GetSchema", func() (*models.Schema, error) { return r.store.GetSchema(ctx, project, table) })
+}
+
+// ListSchemas 列出所有 schema
+func (r *RetryStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error) {
+	return retryValue(ctx, r, "ListSchemas", func() ([]*models.Schema, error) { return r.store.ListSchemas(ctx) })
+}
+
+// InsertLog 写入单条日志
+func (r *RetryStorage) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
+	return r.do(ctx, "InsertLog", func() error { return r.store.InsertLog(ctx, project, table, log) })
+}
+
+// BatchInsertLogs 批量写入日志。日志 ID 在首次尝试时分配，重试时保持不变
+func (r *RetryStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	return r.do(ctx, "BatchInsertLogs", func() error { return r.store.BatchInsertLogs(ctx, project, table, logs) })
+}
+
+// Close 关闭被包装的存储
+func (r *RetryStorage) Close() error {
+	return r.store.Close()
+}
+
+// Ping 检查被包装的存储，不重试也不经过熔断器，以便健康检查反映后端的真实状态
+func (r *RetryStorage) Ping(ctx context.Context) error {
+	return r.store.Ping(ctx)
+}
+
+// QueryLogs 查询日志
+func (r *RetryStorage) QueryLogs(ctx context.Context, project, table string, query map[string]interface{}, limit, offset int) ([]map[string]interface{}, error) {
+	querier, ok := r.store.(LogQuerier)
+	if !ok {
+		return nil, errNotSupported("log queries")
+	}
+	return retryValue(ctx, r, "QueryLogs", func() ([]map[string]interface{}, error) {
+		return querier.QueryLogs(ctx, project, table, query, limit, offset)
+	})
+}
+
+// SearchLogs 执行带字段选择与排序的日志查询
+func (r *RetryStorage) SearchLogs(ctx context.Context, project, table string, query *models.Query) ([]map[string]interface{}, error) {
+	querier, ok := r.store.(LogQuerier)
+	if !ok {
+		return nil, errNotSupported("log queries")
+	}
+	return retryValue(ctx, r, "SearchLogs", func() ([]map[string]interface{}, error) {
+		return querier.SearchLogs(ctx, project, table, query)
+	})
+}
+
+// QueryRange 按时间范围读取日志
+func (r *RetryStorage) QueryRange(ctx context.Context, project, table string, from, to time.Time, limit, offset int) ([]map[string]interface{}, error) {
+	querier, ok := r.store.(RangeQuerier)
+	if !ok {
+		return nil, errNotSupported("range queries")
+	}
+	return retryValue(ctx, r, "QueryRange", func() ([]map[string]interface{}, error) {
+		return querier.QueryRange(ctx, project, table, from, to, limit, offset)
+	})
+}
+
+// QueryAggregate 查询持续聚合结果
+func (r *RetryStorage) QueryAggregate(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	querier, ok := r.store.(ContinuousQuerier)
+	if !ok {
+		return nil, errNotSupported("continuous aggregates")
+	}
+	return retryValue(ctx, r, "QueryAggregate", func() ([]map[string]interface{}, error) {
+		return querier.QueryAggregate(ctx, project, table, name, from, to)
+	})
+}
+
+// SaveQuery 保存查询
+func (r *RetryStorage) SaveQuery(ctx context.Context, query *models.SavedQuery) error {
+	store, ok := r.store.(SavedQueryStore)
+	if !ok {
+		return errNotSupported("saved queries")
+	}
+	return r.do(ctx, "SaveQuery", func() error { return store.SaveQuery(ctx, query) })
+}
+
+// GetSavedQuery 获取保存的查询
+func (r *RetryStorage) GetSavedQuery(ctx context.Context, owner, name string) (*models.SavedQuery, error) {
+	store, ok := r.store.(SavedQueryStore)
+	if !ok {
+		return nil, errNotSupported("saved queries")
+	}
+	return retryValue(ctx, r, "GetSavedQuery", func() (*models.SavedQuery, error) { return store.GetSavedQuery(ctx, owner, name) })
+}
+
+// ListSavedQueries 列出保存的查询
+func (r *RetryStorage) ListSavedQueries(ctx context.Context, owner string) ([]*models.SavedQuery, error) {
+	store, ok := r.store.(SavedQueryStore)
+	if !ok {
+		return nil, errNotSupported("saved queries")
+	}
+	return retryValue(ctx, r, "ListSavedQueries", func() ([]*models.SavedQuery, error) { return store.ListSavedQueries(ctx, owner) })
+}
+
+// DeleteSavedQuery 删除保存的查询
+func (r *RetryStorage) DeleteSavedQuery(ctx context.Context, owner, name string) error {
+	store, ok := r.store.(SavedQueryStore)
+	if !ok {
+		return errNotSupported("saved queries")
+	}
+	return r.do(ctx, "DeleteSavedQuery", func() error { return store.DeleteSavedQuery(ctx, owner, name) })
+}
+
+// SaveReport 保存报表
+func (r *RetryStorage) SaveReport(ctx context.Context, report *models.Report) error {
+	store, ok := r.store.(ReportStore)
+	if !ok {
+		return errNotSupported("reports")
+	}
+	return r.do(ctx, "SaveReport", func() error { return store.SaveReport(ctx, report) })
+}
+
+// GetReport 获取报表
+func (r *RetryStorage) GetReport(ctx context.Context, owner, name string) (*models.Report, error) {
+	store, ok := r.store.(ReportStore)
+	if !ok {
+		return nil, errNotSupported("reports")
+	}
+	return retryValue(ctx, r, "GetReport", func() (*models.Report, error) { return store.GetReport(ctx, owner, name) })
+}
+
+// ListReports 列出报表
+func (r *RetryStorage) ListReports(ctx context.Context, owner string) ([]*models.Report, error) {
+	store, ok := r.store.(ReportStore)
+	if !ok {
+		return nil, errNotSupported("reports")
+	}
+	return retryValue(ctx, r, "ListReports", func() ([]*models.Report, error) { return store.ListReports(ctx, owner) })
+}
+
+// DeleteReport 删除报表
+func (r *RetryStorage) DeleteReport(ctx context.Context, owner, name string) error {
+	store, ok := r.store.(ReportStore)
+	if !ok {
+		return errNotSupported("reports")
+	}
+	return r.do(ctx, "DeleteReport", func() error { return store.DeleteReport(ctx, owner, name) })
+}
+
+// RecordIssues 记录问题
+func (r *RetryStorage) RecordIssues(ctx context.Context, issues []*models.Issue) error {
+	store, ok := r.store.(IssueStore)
+	if !ok {
+		return errNotSupported("issues")
+	}
+	return r.do(ctx, "RecordIssues", func() error { return store.RecordIssues(ctx, issues) })
+}
+
+// GetIssue 获取问题
+func (r *RetryStorage) GetIssue(ctx context.Context, project, table, fingerprint string) (*models.Issue, error) {
+	store, ok := r.store.(IssueStore)
+	if !ok {
+		return nil, errNotSupported("issues")
+	}
+	return retryValue(ctx, r, "GetIssue", func() (*models.Issue, error) { return store.GetIssue(ctx, project, table, fingerprint) })
+}
+
+// ListIssues 列出问题
+func (r *RetryStorage) ListIssues(ctx context.Context, filter *models.IssueFilter) ([]*models.Issue, error) {
+	store, ok := r.store.(IssueStore)
+	if !ok {
+		return nil, errNotSupported("issues")
+	}
+	return retryValue(ctx, r, "ListIssues", func() ([]*models.Issue, error) { return store.ListIssues(ctx, filter) })
+}
+
+// SetIssueStatus 更新问题状态
+func (r *RetryStorage) SetIssueStatus(ctx context.Context, project, table, fingerprint string, status models.IssueStatus) error {
+	store, ok := r.store.(IssueStore)
+	if !ok {
+		return errNotSupported("issues")
+	}
+	return r.do(ctx, "SetIssueStatus", func() error { return store.SetIssueStatus(ctx, project, table, fingerprint, status) })
+}
+
+// DeleteIssue 删除问题
+func (r *RetryStorage) DeleteIssue(ctx context.Context, project, table, fingerprint string) error {
+	store, ok := r.store.(IssueStore)
+	if !ok {
+		return errNotSupported("issues")
+	}
+	return r.do(ctx, "DeleteIssue", func() error { return store.DeleteIssue(ctx, project, table, fingerprint) })
+}
+
+// ArchiveSchema 归档 schema
+func (r *RetryStorage) ArchiveSchema(ctx context.Context, project, table string) (*models.ArchivedSchema, error) {
+	archiver, ok := r.store.(SchemaArchiver)
+	if !ok {
+		return nil, errNotSupported("schema archiving")
+	}
+	return retryValue(ctx, r, "ArchiveSchema", func() (*models.ArchivedSchema, error) { return archiver.ArchiveSchema(ctx, project, table) })
+}
+
+// ListArchivedSchemas 列出归档
+func (r *RetryStorage) ListArchivedSchemas(ctx context.Context) ([]*models.ArchivedSchema, error) {
+	archiver, ok := r.store.(SchemaArchiver)
+	if !ok {
+		return nil, errNotSupported("schema archiving")
+	}
+	return retryValue(ctx, r, "ListArchivedSchemas", func() ([]*models.ArchivedSchema, error) { return archiver.ListArchivedSchemas(ctx) })
+}
+
+// RestoreSchema 恢复归档的 schema
+func (r *RetryStorage) RestoreSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	archiver, ok := r.store.(SchemaArchiver)
+	if !ok {
+		return nil, errNotSupported("schema archiving")
+	}
+	return retryValue(ctx, r, "RestoreSchema", func() (*models.Schema, error) { return archiver.RestoreSchema(ctx, project, table) })
+}
+
+// PurgeArchivedSchemas 永久删除过期的归档
+func (r *RetryStorage) PurgeArchivedSchemas(ctx context.Context, before time.Time) ([]*models.ArchivedSchema, error) {
+	archiver, ok := r.store.(SchemaArchiver)
+	if !ok {
+		return nil, errNotSupported("schema archiving")
+	}
+	return retryValue(ctx, r, "PurgeArchivedSchemas", func() ([]*models.ArchivedSchema, error) {
+		return archiver.PurgeArchivedSchemas(ctx, before)
+	})
+}
+
+// RenameSchema 重命名 schema
+func (r *RetryStorage) RenameSchema(ctx context.Context, project, table, newProject, newTable string) (*models.Schema, error) {
+	renamer, ok := r.store.(SchemaRenamer)
+	if !ok {
+		return nil, errNotSupported("schema renaming")
+	}
+	return retryValue(ctx, r, "RenameSchema", func() (*models.Schema, error) {
+		return renamer.RenameSchema(ctx, project, table, newProject, newTable)
+	})
+}
+
+// CountMatching 统计匹配过滤条件的日志数
+func (r *RetryStorage) CountMatching(ctx context.Context, project, table string, filter *models.LogFilter) (int64, error) {
+	mutator, ok := r.store.(LogMutator)
+	if !ok {
+		return 0, errNotSupported("log mutations")
+	}
+	return retryValue(ctx, r, "CountMatching", func() (int64, error) { return mutator.CountMatching(ctx, project, table, filter) })
+}
+
+// DeleteLogs 删除匹配过滤条件的日志
+func (r *RetryStorage) DeleteLogs(ctx context.Context, project, table string, filter *models.LogFilter, limit int64) (int64, error) {
+	mutator, ok := r.store.(LogMutator)
+	if !ok {
+		return 0, errNotSupported("log mutations")
+	}
+	return retryValue(ctx, r, "DeleteLogs", func() (int64, error) { return mutator.DeleteLogs(ctx, project, table, filter, limit) })
+}
+
+// UpdateLogs 修改匹配过滤条件的日志字段
+func (r *RetryStorage) UpdateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	mutator, ok := r.store.(LogMutator)
+	if !ok {
+		return 0, errNotSupported("log mutations")
+	}
+	return retryValue(ctx, r, "UpdateLogs", func() (int64, error) {
+		return mutator.UpdateLogs(ctx, project, table, filter, set, limit)
+	})
+}
+
+// RollupLogs 汇总并删除早于 before 的日志，已提交的批次不会重复汇总，可以安全重试
+func (r *RetryStorage) RollupLogs(ctx context.Context, project, table string, before time.Time) (int64, error) {
+	roller, ok := r.store.(Roller)
+	if !ok {
+		return 0, errNotSupported("rollups")
+	}
+	return retryValue(ctx, r, "RollupLogs", func() (int64, error) { return roller.RollupLogs(ctx, project, table, before) })
+}
+
+// QueryRollup 查询 rollup 汇总结果
+func (r *RetryStorage) QueryRollup(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	roller, ok := r.store.(Roller)
+	if !ok {
+		return nil, errNotSupported("rollups")
+	}
+	return retryValue(ctx, r, "QueryRollup", func() ([]map[string]interface{}, error) {
+		return roller.QueryRollup(ctx, project, table, name, from, to)
+	})
+}
+
+// TableSizes 返回各日志表的大小
+func (r *RetryStorage) TableSizes(ctx context.Context) ([]*models.TableSize, error) {
+	reporter, ok := r.store.(SizeReporter)
+	if !ok {
+		return nil, errNotSupported("table sizes")
+	}
+	return retryValue(ctx, r, "TableSizes", func() ([]*models.TableSize, error) { return reporter.TableSizes(ctx) })
+}
+
+// ExplainQuery 返回日志查询的执行计划
+func (r *RetryStorage) ExplainQuery(ctx context.Context, project, table string, query *models.Query, analyze bool) (*models.QueryPlan, error) {
+	explainer, ok := r.store.(QueryExplainer)
+	if !ok {
+		return nil, errNotSupported("query plans")
+	}
+	return retryValue(ctx, r, "ExplainQuery", func() (*models.QueryPlan, error) {
+		return explainer.ExplainQuery(ctx, project, table, query, analyze)
+	})
+}
+
+// FieldValues 统计字段出现最多的取值
+func (r *RetryStorage) FieldValues(ctx context.Context, project, table, field string, query *models.Query) (*models.FieldValues, error) {
+	querier, ok := r.store.(FieldValuesQuerier)
+	if !ok {
+		return nil, errNotSupported("field values")
+	}
+	return retryValue(ctx, r, "FieldValues", func() (*models.FieldValues, error) {
+		return querier.FieldValues(ctx, project, table, field, query)
+	})
+}
+
+// BaseColumns 返回被包装存储的基础列
+func (r *RetryStorage) BaseColumns(schema *models.Schema) []string {
+	return BaseColumns(r.store, schema)
+}
+
+// RecordSlowQuery 记录慢查询
+func (r *RetryStorage) RecordSlowQuery(ctx context.Context, query *models.SlowQuery) error {
+	store, ok := r.store.(SlowQueryStore)
+	if !ok {
+		return errNotSupported("slow queries")
+	}
+	return r.do(ctx, "RecordSlowQuery", func() error { return store.RecordSlowQuery(ctx, query) })
+}
+
+// ListSlowQueries 列出慢查询
+func (r *RetryStorage) ListSlowQueries(ctx context.Context, filter *models.SlowQueryFilter) ([]*models.SlowQuery, error) {
+	store, ok := r.store.(SlowQueryStore)
+	if !ok {
+		return nil, errNotSupported("slow queries")
+	}
+	return retryValue(ctx, r, "ListSlowQueries", func() ([]*models.SlowQuery, error) { return store.ListSlowQueries(ctx, filter) })
+}
+
+// PurgeSlowQueries 删除过期的慢查询记录
+func (r *RetryStorage) PurgeSlowQueries(ctx context.Context, before time.Time) (int64, error) {
+	store, ok := r.store.(SlowQueryStore)
+	if !ok {
+		return 0, errNotSupported("slow queries")
+	}
+	return retryValue(ctx, r, "PurgeSlowQueries", func() (int64, error) { return store.PurgeSlowQueries(ctx, before) })
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+// flakyStorage 按顺序返回预设错误的存储，只实现 Storage 接口
+type flakyStorage struct {
+	Storage
+	errs  []error
+	calls int
+}
+
+func (f *flakyStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(syscall.ECONNRESET))
+	assert.True(t, isTransient(&mysql.MySQLError{Number: 1213}))
+	assert.True(t, isTransient(&mysql.MySQLError{Number: 1040}))
+	assert.True(t, isTransient(&pq.Error{Code: "40P01"}))
+	assert.False(t, isTransient(&mysql.MySQLError{Number: 1062}))
+	assert.False(t, isTransient(context.Canceled))
+	assert.False(t, isTransient(models.ErrValidation))
+	assert.False(t, isTransient(nil))
+}
+
+func TestRetryStorageRetriesTransientErrors(t *testing.T) {
+	ctx := context.Background()
+	config := RetryConfig{Enabled: true, InitialBackoff: time.Nanosecond}
+
+	flaky := &flakyStorage{errs: []error{syscall.ECONNRESET, &mysql.MySQLError{Number: 1213}}}
+	store := WithRetry(flaky, config, zap.NewNop(), nil)
+	assert.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", nil))
+	assert.Equal(t, 3, flaky.calls)
+
+	// 非瞬时错误不重试
+	flaky = &flakyStorage{errs: []error{models.ErrValidation}}
+	store = WithRetry(flaky, config, zap.NewNop(), nil)
+	assert.ErrorIs(t, store.BatchInsertLogs(ctx, "app", "requests", nil), models.ErrValidation)
+	assert.Equal(t, 1, flaky.calls)
+
+	// 超过最大次数后返回最后一次错误
+	flaky = &flakyStorage{errs: []error{syscall.ECONNRESET, syscall.ECONNRESET, syscall.ECONNREFUSED, nil}}
+	store = WithRetry(flaky, config, zap.NewNop(), nil)
+	assert.ErrorIs(t, store.BatchInsertLogs(ctx, "app", "requests", nil), syscall.ECONNREFUSED)
+	assert.Equal(t, 3, flaky.calls)
+
+	// 未启用时原样返回
+	assert.Same(t, Storage(flaky), WithRetry(flaky, RetryConfig{}, nil, nil))
+}
+
+func TestRetryStorageStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	flaky := &flakyStorage{errs: []error{syscall.ECONNRESET}}
+	store := WithRetry(flaky, RetryConfig{Enabled: true, InitialBackoff: time.Hour, MaxBackoff: time.Hour}, zap.NewNop(), nil)
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	assert.ErrorIs(t, store.BatchInsertLogs(ctx, "app", "requests", nil), syscall.ECONNRESET)
+	assert.Equal(t, 1, flaky.calls)
+}
+
+func TestRetryStorageCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	mock := clock.NewMock(time.Now())
+	flaky := &flakyStorage{errs: []error{syscall.ECONNRESET, syscall.ECONNRESET, syscall.ECONNRESET}}
+	store := WithRetry(flaky, RetryConfig{Enabled: true, MaxAttempts: 1, FailureThreshold: 2, OpenTimeout: time.Minute}, zap.NewNop(), mock)
+
+	assert.Error(t, store.BatchInsertLogs(ctx, "app", "requests", nil))
+	assert.Error(t, store.BatchInsertLogs(ctx, "app", "requests", nil))
+
+	// 熔断期间不调用后端
+	err := store.BatchInsertLogs(ctx, "app", "requests", nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, err, ErrBackendUnavailable)
+	assert.Equal(t, 2, flaky.calls)
+
+	// 到期后放行一次探测，失败继续熔断
+	mock.Add(time.Minute)
+	assert.ErrorIs(t, store.BatchInsertLogs(ctx, "app", "requests", nil), syscall.ECONNRESET)
+	assert.ErrorIs(t, store.BatchInsertLogs(ctx, "app", "requests", nil), ErrCircuitOpen)
+
+	// 探测成功后恢复
+	mock.Add(time.Minute)
+	assert.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", nil))
+	assert.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", nil))
+	assert.Equal(t, 5, flaky.calls)
+}
+
+func TestAsUnwrapsRetryStorage(t *testing.T) {
+	store := WithRetry(&SQLiteStorage{}, RetryConfig{Enabled: true}, nil, nil)
+	_, ok := As[ContinuousQuerier](store)
+	assert.True(t, ok)
+	querier, ok := As[LogQuerier](store)
+	require.True(t, ok)
+	assert.IsType(t, &RetryStorage{}, querier, "capabilities are called through the wrapper")
+
+	store = WithRetry(&ClickHouseStorage{}, RetryConfig{Enabled: true}, nil, nil)
+	_, ok = As[ContinuousQuerier](store)
+	assert.True(t, ok)
+	_, ok = As[Roller](store)
+	assert.False(t, ok)
+	_, ok = As[ReportStore](&flakyStorage{})
+	assert.False(t, ok)
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// rollupBatchSize 每个事务汇总并删除的原始日志条数
+const rollupBatchSize = 1000
+
+// Roller 将过期原始日志汇总到 rollup 表后删除的可选能力，rollup 定义见 Schema.Rollups
+type Roller interface {
+	// RollupLogs 将 timestamp 早于 before 的日志合并到 schema 的所有 rollup 表并删除，返回汇总的条数。
+	// 未定义 rollup 时不做任何修改
+	RollupLogs(ctx context.Context, project, table string, before time.Time) (int64, error)
+	// QueryRollup 查询 bucket 位于 [from, to) 的 rollup 汇总结果，零值表示不限制
+	QueryRollup(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error)
+}
+
+// rollupLogs 分批汇总并删除早于 before 的日志，每批的合并与删除在同一事务中完成，
+// 中途失败时已提交的批次保持汇总状态，不会重复计数
+func rollupLogs(ctx context.Context, db *sql.DB, cq *continuousQueries, timeouts TimeoutConfig,
+	schema *models.Schema, before time.Time) (int64, error) {
+	if len(schema.Rollups) == 0 {
+		return 0, nil
+	}
+
+	tableName := logTable(cq.dialect, schema.Project, schema.Table)
+	columns := []string{"id", "timestamp"}
+	seen := map[string]bool{"id": true, "timestamp": true}
+	for _, rollup := range schema.Rollups {
+		names := append([]string(nil), rollup.GroupBy...)
+		for _, metric := range rollup.Metrics {
+			names = append(names, metric.Field)
+		}
+		for _, name := range names {
+			if seen[name] || schema.GetField(name) == nil {
+				continue
+			}
+			seen[name] = true
+			columns = append(columns, quoteIdent(cq.dialect, name))
+		}
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE timestamp < ? ORDER BY timestamp LIMIT %d",
+		strings.Join(columns, ", "), tableName, rollupBatchSize)
+
+	var total int64
+	for {
+		n, err := rollupBatch(ctx, db, cq, timeouts, schema, tableName, query, before.UTC())
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < rollupBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// rollupBatch 在一个事务中汇总并删除一批日志，返回该批条数
+func rollupBatch(ctx context.Context, db *sql.DB, cq *continuousQueries, timeouts TimeoutConfig,
+	schema *models.Schema, tableName, query string, before time.Time) (int64, error) {
+	ctx, cancel := timeouts.write(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("开始事务失败: %w", unavailable(err))
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("查询待汇总日志失败: %w", unavailable(err))
+	}
+	result, err := scanRows(rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+
+	logs := make([]*models.LogEntry, len(result))
+	ids := make([]interface{}, len(result))
+	for i, row := range result {
+		ts, ok := row["timestamp"].(time.Time)
+		if !ok {
+			return 0, fmt.Errorf("日志时间格式无效: %v", row["timestamp"])
+		}
+		logs[i] = &models.LogEntry{ID: fmt.Sprint(row["id"]), Timestamp: ts, Fields: row}
+		ids[i] = row["id"]
+	}
+
+	for _, rollup := range schema.Rollups {
+		if err := cq.merge(ctx, tx, cq.rollupTable(schema, rollup.Name), rollup, logs); err != nil {
+			return 0, err
+		}
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", tableName, placeholders), ids...); err != nil {
+		return 0, fmt.Errorf("删除已汇总日志失败: %w", unavailable(err))
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("提交事务失败: %w", unavailable(err))
+	}
+	return int64(len(logs)), nil
+}
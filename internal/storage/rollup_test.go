@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestSQLiteRollupLogs(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "requests",
+		Fields: []*models.Field{
+			{Name: "level", Type: models.FieldTypeString},
+			{Name: "latency", Type: models.FieldTypeFloat},
+		},
+		SchemaOptions: models.SchemaOptions{
+			RollupAfter: "30d",
+			Rollups: []*models.Aggregate{{
+				Name:     "hourly",
+				Interval: "1h",
+				GroupBy:  []string{"level"},
+				Metrics:  []*models.AggregateMetric{{Func: models.AggregateAvg, Field: "latency"}},
+			}},
+		},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	base := time.Date(2024, 3, 14, 10, 0, 0, 0, time.UTC)
+	insert := func(offset time.Duration, level string, latency float64) {
+		require.NoError(t, store.InsertLog(ctx, "app", "requests", &models.LogEntry{
+			Project: "app", Table: "requests", Level: level, Message: "request", Timestamp: base.Add(offset),
+			Fields: map[string]interface{}{"level": level, "latency": latency},
+		}))
+	}
+	insert(time.Minute, "info", 10)
+	insert(2*time.Minute, "info", 30)
+	insert(3*time.Minute, "error", 5)
+	insert(2*time.Hour, "info", 7)
+
+	rolled, err := store.RollupLogs(ctx, "app", "requests", base.Add(time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, rolled)
+	count, err := store.CountLogs(ctx, "app", "requests", nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	// 再次汇总同一时间桶的日志时与已有结果合并
+	insert(4*time.Minute, "info", 50)
+	rolled, err = store.RollupLogs(ctx, "app", "requests", base.Add(time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, rolled)
+
+	rows, err := store.QueryRollup(ctx, "app", "requests", "hourly", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	byLevel := make(map[string]map[string]interface{})
+	for _, row := range rows {
+		byLevel[row["level"].(string)] = row
+	}
+	assert.EqualValues(t, 3, byLevel["info"]["count"])
+	assert.InDelta(t, 30.0, byLevel["info"]["avg_latency"], 0.0001)
+	assert.EqualValues(t, 1, byLevel["error"]["count"])
+
+	_, err = store.QueryRollup(ctx, "app", "requests", "daily", time.Time{}, time.Time{})
+	assert.Error(t, err)
+
+	// 重命名时汇总表随之重命名
+	_, err = store.RenameSchema(ctx, "app", "requests", "app", "http")
+	require.NoError(t, err)
+	rows, err = store.QueryRollup(ctx, "app", "http", "hourly", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, rows, 2)
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// sizeSampleRows SQLite 估算表大小时抽样的行数
+const sizeSampleRows = 1000
+
+// SizeReporter 报告日志表占用存储空间的可选能力，用于查看各项目的存储用量与规划保留期。
+// 只统计日志表本身，持续聚合与 rollup 汇总表不计入
+type SizeReporter interface {
+	// TableSizes 返回全部 schema 的日志表大小，按项目与表名排序
+	TableSizes(ctx context.Context) ([]*models.TableSize, error)
+}
+
+// tableSizes 依次统计每个 schema 的日志表大小，size 只需填写行数与字节数
+func tableSizes(ctx context.Context, store Storage, source string,
+	size func(ctx context.Context, schema *models.Schema) (*models.TableSize, error)) ([]*models.TableSize, error) {
+	schemas, err := store.ListSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make([]*models.TableSize, 0, len(schemas))
+	for _, schema := range schemas {
+		ts, err := size(ctx, schema)
+		if err != nil {
+			return nil, fmt.Errorf("统计表 %s_%s 大小失败: %w", schema.Project, schema.Table, err)
+		}
+		ts.Project, ts.Table, ts.Source = schema.Project, schema.Table, source
+		sizes = append(sizes, ts)
+	}
+	return sizes, nil
+}
+
+// sampleTableSize 按最近写入的 sizeSampleRows 行的平均大小乘以总行数估算 SQLite 日志表的数据大小。
+// SQLite 未编译 dbstat 时无法获取单表的页数，索引大小不计入
+func sampleTableSize(ctx context.Context, db *sql.DB, tableName string) (*models.TableSize, error) {
+	var rows int64
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+tableName).Scan(&rows); err != nil {
+		return nil, fmt.Errorf("统计日志失败: %w", unavailable(err))
+	}
+	if rows == 0 {
+		return &models.TableSize{}, nil
+	}
+
+	result, err := db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s ORDER BY rowid DESC LIMIT %d", tableName, sizeSampleRows))
+	if err != nil {
+		return nil, fmt.Errorf("抽样日志失败: %w", unavailable(err))
+	}
+	defer result.Close()
+	columns, err := result.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("获取列失败: %w", err)
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var sampled, bytes int64
+	for result.Next() {
+		if err := result.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("读取日志失败: %w", err)
+		}
+		for _, value := range values {
+			bytes += int64(len(value))
+		}
+		sampled++
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("读取日志失败: %w", unavailable(err))
+	}
+
+	size := &models.TableSize{Rows: rows}
+	if sampled > 0 {
+		size.DataBytes = bytes * rows / sampled
+		size.TotalBytes = size.DataBytes
+	}
+	return size, nil
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestFileStorageTableSizes(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := newTestFileStorage(t, dir, 512)
+	require.NoError(t, store.CreateSchema(ctx, fileTestSchema()))
+	require.NoError(t, store.BatchInsertLogs(ctx, "edge", "events", fileTestLogs(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), 20)))
+
+	sizes, err := store.TableSizes(ctx)
+	require.NoError(t, err)
+	require.Len(t, sizes, 1)
+	assert.Equal(t, "edge", sizes[0].Project)
+	assert.Equal(t, "segments", sizes[0].Source)
+	assert.Equal(t, int64(20), sizes[0].Rows)
+
+	// 段索引中的大小即段文件的大小
+	paths, err := filepath.Glob(filepath.Join(dir, "edge", "events", "*.jsonl"))
+	require.NoError(t, err)
+	require.Greater(t, len(paths), 1)
+	var bytes int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		bytes += info.Size()
+	}
+	assert.Equal(t, bytes, sizes[0].DataBytes)
+	assert.Equal(t, bytes, sizes[0].TotalBytes)
+}
+
+func TestSQLiteTableSizes(t *testing.T) {
+	for _, perProject := range []bool{false, true} {
+		ctx := context.Background()
+		store := NewSQLiteStorage(Config{
+			Type:   "sqlite",
+			SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db"), PerProject: perProject},
+		})
+		require.NoError(t, store.Initialize(ctx))
+		defer store.Close()
+
+		for _, table := range []string{"empty", "requests"} {
+			require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+				Project: "app",
+				Table:   table,
+				Fields:  []*models.Field{{Name: "path", Type: models.FieldTypeString}},
+			}))
+		}
+		var logs []*models.LogEntry
+		for i := 0; i < 2*sizeSampleRows; i++ {
+			logs = append(logs, &models.LogEntry{
+				Project: "app", Table: "requests", Level: "info", Message: "request",
+				Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Add(time.Duration(i) * time.Second),
+				Fields:    map[string]interface{}{"path": "/api/items"},
+			})
+		}
+		require.NoError(t, store.BatchInsertLogs(ctx, "app", "requests", logs))
+
+		// 经过缓存包装后仍可通过 As 获取
+		reporter, ok := As[SizeReporter](WithSchemaCache(store, models.NewSchemaRegistry()))
+		require.True(t, ok)
+		sizes, err := reporter.TableSizes(ctx)
+		require.NoError(t, err)
+		require.Len(t, sizes, 2)
+		assert.Equal(t, &models.TableSize{Project: "app", Table: "empty", Source: "sample"}, sizes[0])
+		requests := sizes[1]
+		assert.Equal(t, int64(2*sizeSampleRows), requests.Rows, "per project: %v", perProject)
+		// 每行至少包含 ID、时间、级别、消息与 path 的内容
+		assert.Greater(t, requests.DataBytes, int64(2*sizeSampleRows*len("infarequest/api/items")))
+		assert.Equal(t, requests.DataBytes, requests.TotalBytes)
+		assert.Zero(t, requests.IndexBytes)
+	}
+}
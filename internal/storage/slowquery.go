@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// SlowQueryStore 保存慢查询记录的可选能力，用于找出需要建索引的字段
+type SlowQueryStore interface {
+	RecordSlowQuery(ctx context.Context, query *models.SlowQuery) error
+	ListSlowQueries(ctx context.Context, filter *models.SlowQueryFilter) ([]*models.SlowQuery, error)
+	// PurgeSlowQueries 删除 before 之前的记录，返回删除的条数
+	PurgeSlowQueries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// slowQueryColumns 慢查询表的列，与 scanSlowQueries 的扫描顺序一致
+const slowQueryColumns = `recorded_at, project, table_name, endpoint, query, duration_us, row_count, caller, client_ip, backend, error`
+
+// createSlowQueryTable 创建慢查询表及按记录时间的索引，用于按时间列出与清理
+func (sq *savedQueries) createSlowQueryTable(ctx context.Context) error {
+	ts, index := "TIMESTAMP", ""
+	switch sq.dialect {
+	case "mysql":
+		// MySQL 不支持 CREATE INDEX IF NOT EXISTS，在建表时创建索引
+		ts, index = "DATETIME(6)", ",\n\t\tINDEX idx_slow_queries_recorded_at (recorded_at)"
+	case "postgres":
+		ts = "TIMESTAMP WITH TIME ZONE"
+	}
+
+	query := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS slow_queries (
+		recorded_at %s,
+		project VARCHAR(255),
+		table_name VARCHAR(255),
+		endpoint VARCHAR(255),
+		query TEXT,
+		duration_us BIGINT,
+		row_count BIGINT,
+		caller VARCHAR(255),
+		client_ip VARCHAR(64),
+		backend VARCHAR(32),
+		error TEXT%s
+	)`, ts, index)
+
+	if _, err := sq.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("创建慢查询表失败: %w", err)
+	}
+	if sq.dialect != "mysql" {
+		if _, err := sq.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_slow_queries_recorded_at ON slow_queries (recorded_at)`); err != nil {
+			return fmt.Errorf("创建慢查询索引失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordSlowQuery 记录一次慢查询
+func (sq *savedQueries) recordSlowQuery(ctx context.Context, q *models.SlowQuery) error {
+	p := func(n int) string { return placeholder(sq.dialect, n) }
+	query := fmt.Sprintf(`INSERT INTO slow_queries (%s) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)`,
+		slowQueryColumns, p(1), p(2), p(3), p(4), p(5), p(6), p(7), p(8), p(9), p(10), p(11))
+
+	duration := int64(q.DurationMS * float64(time.Millisecond/time.Microsecond))
+	if _, err := sq.db.ExecContext(ctx, query, q.Time.UTC(), q.Project, q.Table, q.Endpoint, q.Query, duration,
+		q.Rows, q.Caller, q.ClientIP, q.Backend, q.Error); err != nil {
+		return fmt.Errorf("记录慢查询失败: %w", unavailable(err))
+	}
+	return nil
+}
+
+// listSlowQueries 按时间倒序或耗时降序列出慢查询
+func (sq *savedQueries) listSlowQueries(ctx context.Context, filter *models.SlowQueryFilter) ([]*models.SlowQuery, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, placeholder(sq.dialect, len(args))))
+	}
+	if filter.Project != "" {
+		add("project = %s", filter.Project)
+	}
+	if filter.Table != "" {
+		add("table_name = %s", filter.Table)
+	}
+	if !filter.From.IsZero() {
+		add("recorded_at >= %s", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		add("recorded_at < %s", filter.To.UTC())
+	}
+	if filter.MinDuration > 0 {
+		add("duration_us >= %s", filter.MinDuration.Microseconds())
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM slow_queries`, slowQueryColumns)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	order := "recorded_at DESC"
+	if filter.Sort == models.SlowQueryByDuration {
+		order = "duration_us DESC, recorded_at DESC"
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", order, limit)
+	if filter.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", filter.Offset)
+	}
+	return sq.scanSlowQueries(sq.db.QueryContext(ctx, query, args...))
+}
+
+// purgeSlowQueries 删除 before 之前的慢查询记录
+func (sq *savedQueries) purgeSlowQueries(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM slow_queries WHERE recorded_at < %s`, placeholder(sq.dialect, 1))
+	result, err := sq.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("清理慢查询失败: %w", unavailable(err))
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
+
+// scanSlowQueries 解析慢查询的结果集
+func (sq *savedQueries) scanSlowQueries(rows *sql.Rows, err error) ([]*models.SlowQuery, error) {
+	if err != nil {
+		return nil, fmt.Errorf("查询慢查询失败: %w", unavailable(err))
+	}
+	defer rows.Close()
+
+	queries := make([]*models.SlowQuery, 0)
+	for rows.Next() {
+		var (
+			q                          models.SlowQuery
+			duration, count            int64
+			caller, clientIP, errorMsg sql.NullString
+		)
+		if err := rows.Scan(&q.Time, &q.Project, &q.Table, &q.Endpoint, &q.Query, &duration, &count,
+			&caller, &clientIP, &q.Backend, &errorMsg); err != nil {
+			return nil, fmt.Errorf("扫描慢查询失败: %w", err)
+		}
+		q.DurationMS = float64(duration) / float64(time.Millisecond/time.Microsecond)
+		q.Rows = int(count)
+		q.Caller, q.ClientIP, q.Error = caller.String, clientIP.String, errorMsg.String
+		queries = append(queries, &q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %w", err)
+	}
+	return queries, nil
+}
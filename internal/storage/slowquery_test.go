@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestSQLiteSlowQueries(t *testing.T) {
+	ctx := context.Background()
+	sqlite := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, sqlite.Initialize(ctx))
+	defer sqlite.Close()
+
+	// 经过缓存包装后仍可通过 As 获取
+	store, ok := As[SlowQueryStore](WithSchemaCache(sqlite, models.NewSchemaRegistry()))
+	require.True(t, ok)
+
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, q := range []*models.SlowQuery{
+		{Project: "app", Table: "requests", DurationMS: 1500, Rows: 10},
+		{Project: "app", Table: "events", DurationMS: 12000.5, Error: "context deadline exceeded"},
+		{Project: "web", Table: "requests", DurationMS: 3000, Caller: "user:alice", ClientIP: "10.0.0.1"},
+	} {
+		q.Time = base.Add(time.Duration(i) * time.Hour)
+		q.Endpoint = "POST /api/v1/logs/:project/:table/search"
+		q.Query = `{"filter":{"path":"/api"}}`
+		q.Backend = "sqlite"
+		require.NoError(t, store.RecordSlowQuery(ctx, q))
+	}
+
+	// 默认最近的在前
+	queries, err := store.ListSlowQueries(ctx, &models.SlowQueryFilter{})
+	require.NoError(t, err)
+	require.Len(t, queries, 3)
+	assert.Equal(t, "web", queries[0].Project)
+	assert.Equal(t, "user:alice", queries[0].Caller)
+	assert.Equal(t, "10.0.0.1", queries[0].ClientIP)
+	assert.True(t, base.Add(2*time.Hour).Equal(queries[0].Time))
+	assert.Equal(t, `{"filter":{"path":"/api"}}`, queries[0].Query)
+	assert.Equal(t, 12000.5, queries[1].DurationMS)
+	assert.Equal(t, "context deadline exceeded", queries[1].Error)
+	assert.Equal(t, 10, queries[2].Rows)
+
+	queries, err = store.ListSlowQueries(ctx, &models.SlowQueryFilter{Sort: models.SlowQueryByDuration, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+	assert.Equal(t, "events", queries[0].Table)
+	assert.Equal(t, "web", queries[1].Project)
+
+	queries, err = store.ListSlowQueries(ctx, &models.SlowQueryFilter{Project: "app", MinDuration: 2 * time.Second})
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	assert.Equal(t, "events", queries[0].Table)
+
+	queries, err = store.ListSlowQueries(ctx, &models.SlowQueryFilter{From: base.Add(time.Hour), To: base.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	assert.Equal(t, "events", queries[0].Table)
+
+	n, err := store.PurgeSlowQueries(ctx, base.Add(90*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	queries, err = store.ListSlowQueries(ctx, &models.SlowQueryFilter{})
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	assert.Equal(t, "web", queries[0].Project)
+}
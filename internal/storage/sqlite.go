@@ -6,39 +6,79 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/logging"
 	"pkg.blksails.net/logs/internal/models"
 )
 
 // SQLiteStorage SQLite 存储实现
 type SQLiteStorage struct {
-	db     *sql.DB
-	config Config
+	db       *sql.DB
+	config   Config
+	ids      models.IDGenerator
+	cq       *continuousQueries
+	sq       *savedQueries
+	archives *schemaArchives
+
+	// PerProject 模式下每个项目的数据库文件
+	projects   map[string]*projectDB
+	projectsMu sync.Mutex
+	done       chan struct{}
+	logger     *zap.Logger
 }
 
 // NewSQLiteStorage 创建 SQLite 存储实例
 func NewSQLiteStorage(config Config) *SQLiteStorage {
 	return &SQLiteStorage{
-		config: config,
+		config:   config,
+		projects: make(map[string]*projectDB),
+		done:     make(chan struct{}),
+		logger:   logging.Component(config.Logger, "storage"),
 	}
 }
 
 // Initialize 初始化 SQLite 连接和表结构
 func (s *SQLiteStorage) Initialize(ctx context.Context) error {
+	ids, err := newIDGenerator(s.config)
+	if err != nil {
+		return err
+	}
+	s.ids = ids
+
 	// 连接数据库
 	db, err := sql.Open("sqlite3", s.config.SQLite.Path)
 	if err != nil {
-		return fmt.Errorf("连接数据库失败: %w", err)
+		return fmt.Errorf("连接数据库失败: %w", unavailable(err))
 	}
 	s.db = db
+	s.cq = newContinuousQueries(db, "sqlite")
+	s.sq = newSavedQueries(db, "sqlite")
+	s.archives = newSchemaArchives(db, "sqlite")
 
 	// 创建 schema 表
 	if err := s.createSchemaTable(ctx); err != nil {
 		return err
 	}
 
+	// 创建保存查询表
+	if err := s.sq.createTable(ctx); err != nil {
+		return err
+	}
+
+	// 创建 schema 归档表
+	if err := s.archives.createTable(ctx); err != nil {
+		return err
+	}
+
+	// 启动定期维护
+	if interval := s.config.SQLite.MaintenanceInterval; interval > 0 {
+		go s.maintenanceLoop(interval)
+	}
+
 	return nil
 }
 
@@ -52,11 +92,24 @@ func (s *SQLiteStorage) createSchemaTable(ctx context.Context) error {
 		fields TEXT,
 		created_at TIMESTAMP,
 		updated_at TIMESTAMP,
+		options TEXT,
 		PRIMARY KEY (project, table_name)
 	)`
 
 	if _, err := s.db.ExecContext(ctx, query); err != nil {
-		return fmt.Errorf("创建 schema 表失败: %w", err)
+		return fmt.Errorf("创建 schema 表失败: %w", unavailable(err))
+	}
+
+	// 兼容旧版本创建的 schema 表
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info('schemas') WHERE name = 'options'`).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("检查 schema 表失败: %w", err)
+	}
+	if count == 0 {
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE schemas ADD COLUMN options TEXT`); err != nil {
+			return fmt.Errorf("升级 schema 表失败: %w", err)
+		}
 	}
 
 	return nil
@@ -64,24 +117,44 @@ func (s *SQLiteStorage) createSchemaTable(ctx context.Context) error {
 
 // CreateSchema 创建或更新 schema
 func (s *SQLiteStorage) CreateSchema(ctx context.Context, schema *models.Schema) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	// 将字段转换为 JSON
 	fieldsJSON, err := json.Marshal(schema.Fields)
 	if err != nil {
 		return fmt.Errorf("序列化字段失败: %w", err)
 	}
 
+	optionsJSON, err := marshalSchemaOptions(schema)
+	if err != nil {
+		return err
+	}
+
+	ldb, release, err := s.logDB(schema.Project)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// 创建日志表
-	if err := s.createLogTable(ctx, schema); err != nil {
+	if err := s.createLogTable(ctx, ldb.db, schema); err != nil {
+		return err
+	}
+
+	// 创建持续聚合表
+	if err := ldb.cq.createTables(ctx, schema); err != nil {
 		return err
 	}
 
 	// 保存 schema
 	query := `
-	INSERT INTO schemas (project, table_name, description, fields, created_at, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?)
+	INSERT INTO schemas (project, table_name, description, fields, options, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
 	ON CONFLICT(project, table_name) DO UPDATE SET
 		description = excluded.description,
 		fields = excluded.fields,
+		options = excluded.options,
 		updated_at = excluded.updated_at`
 
 	_, err = s.db.ExecContext(ctx, query,
@@ -89,11 +162,12 @@ func (s *SQLiteStorage) CreateSchema(ctx context.Context, schema *models.Schema)
 		schema.Table,
 		schema.Description,
 		fieldsJSON,
+		optionsJSON,
 		schema.CreatedAt,
 		schema.UpdatedAt,
 	)
 	if err != nil {
-		return fmt.Errorf("保存 schema 失败: %w", err)
+		return fmt.Errorf("保存 schema 失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -101,14 +175,18 @@ func (s *SQLiteStorage) CreateSchema(ctx context.Context, schema *models.Schema)
 
 // GetSchema 获取指定的 schema
 func (s *SQLiteStorage) GetSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	query := `
-	SELECT description, fields, created_at, updated_at
+	SELECT description, fields, options, created_at, updated_at
 	FROM schemas
 	WHERE project = ? AND table_name = ?`
 
 	var (
 		description string
 		fieldsJSON  []byte
+		optionsJSON []byte
 		createdAt   time.Time
 		updatedAt   time.Time
 	)
@@ -116,14 +194,15 @@ func (s *SQLiteStorage) GetSchema(ctx context.Context, project, table string) (*
 	err := s.db.QueryRowContext(ctx, query, project, table).Scan(
 		&description,
 		&fieldsJSON,
+		&optionsJSON,
 		&createdAt,
 		&updatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("schema not found")
+		return nil, models.ErrSchemaNotFound
 	}
 	if err != nil {
-		return nil, fmt.Errorf("查询 schema 失败: %w", err)
+		return nil, fmt.Errorf("查询 schema 失败: %w", unavailable(err))
 	}
 
 	var fields []*models.Field
@@ -131,20 +210,26 @@ func (s *SQLiteStorage) GetSchema(ctx context.Context, project, table string) (*
 		return nil, fmt.Errorf("解析字段失败: %w", err)
 	}
 
-	return &models.Schema{
+	schema := &models.Schema{
 		Project:     project,
 		Table:       table,
 		Description: description,
 		Fields:      fields,
 		CreatedAt:   createdAt,
 		UpdatedAt:   updatedAt,
-	}, nil
+	}
+	if err := unmarshalSchemaOptions(optionsJSON, schema); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
 }
 
 // createLogTable 创建日志表
-func (s *SQLiteStorage) createLogTable(ctx context.Context, schema *models.Schema) error {
+func (s *SQLiteStorage) createLogTable(ctx context.Context, db *sql.DB, schema *models.Schema) error {
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", schema.Project, schema.Table)
+	rawName := logTableName(schema.Project, schema.Table)
+	tableName := quoteIdent("sqlite", rawName)
 
 	// 构建字段定义
 	columns := []string{
@@ -154,10 +239,18 @@ func (s *SQLiteStorage) createLogTable(ctx context.Context, schema *models.Schem
 		"timestamp TIMESTAMP",
 	}
 
+	// 内置 tags 列以 JSON 文本保存标签
+	if schema.StoresTags() {
+		columns = append(columns, models.TagsColumn+" TEXT")
+	}
+	if schema.StoresIngestTime() {
+		columns = append(columns, models.IngestTimeColumn+" TIMESTAMP")
+	}
+
 	// 添加自定义字段
 	for _, field := range schema.Fields {
-		colType := s.getSQLiteType(field.Type)
-		colDef := fmt.Sprintf("%s %s", field.Name, colType)
+		colType := columnType("sqlite", field, s.getSQLiteType)
+		colDef := fmt.Sprintf("%s %s%s", quoteIdent("sqlite", field.Name), colType, columnConstraints("sqlite", field, false))
 		columns = append(columns, colDef)
 	}
 
@@ -167,23 +260,104 @@ func (s *SQLiteStorage) createLogTable(ctx context.Context, schema *models.Schem
 		%s
 	)`, tableName, strings.Join(columns, ",\n"))
 
-	if _, err := s.db.ExecContext(ctx, query); err != nil {
+	if _, err := db.ExecContext(ctx, query); err != nil {
 		return fmt.Errorf("创建日志表失败: %w", err)
 	}
 
-	// 为索引字段创建索引
+	if err := s.migrateDurationColumns(ctx, db, tableName, rawName, schema); err != nil {
+		return err
+	}
+
+	// 为已存在的表补充新增字段
+	existing, err := queryNames(ctx, db, `SELECT name FROM pragma_table_info(?)`, rawName)
+	if err != nil {
+		return fmt.Errorf("查询表字段失败: %w", err)
+	}
+	for _, field := range schema.Fields {
+		if existing[field.Name] {
+			continue
+		}
+		alterQuery := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s%s", tableName, quoteIdent("sqlite", field.Name), columnType("sqlite", field, s.getSQLiteType),
+			columnConstraints("sqlite", field, true))
+		if _, err := db.ExecContext(ctx, alterQuery); err != nil {
+			return fmt.Errorf("添加字段失败: %w", err)
+		}
+	}
+	if schema.StoresTags() && !existing[models.TagsColumn] {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s TEXT", tableName, models.TagsColumn)); err != nil {
+			return fmt.Errorf("添加 tags 字段失败: %w", err)
+		}
+	}
+	if schema.StoresIngestTime() && !existing[models.IngestTimeColumn] {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s TIMESTAMP", tableName, models.IngestTimeColumn)); err != nil {
+			return fmt.Errorf("添加 ingest_time 字段失败: %w", err)
+		}
+	}
+
+	return createSQLiteIndexes(ctx, db, schema)
+}
+
+// migrateDurationColumns 将旧版本以 TEXT 保存的 duration 字段迁移为纳秒 INTEGER。
+// SQLite 不能修改列类型，先写入临时列，再删除旧列（及其索引）并改名，索引随后由 createSQLiteIndexes 重建
+func (s *SQLiteStorage) migrateDurationColumns(ctx context.Context, db *sql.DB, tableName, rawName string, schema *models.Schema) error {
+	legacy, err := queryNames(ctx, db, `SELECT name FROM pragma_table_info(?) WHERE type = 'TEXT'`, rawName)
+	if err != nil {
+		return fmt.Errorf("查询表字段失败: %w", err)
+	}
 	for _, field := range schema.Fields {
-		if field.Indexed {
+		if field.Type != models.FieldTypeDuration || !legacy[field.Name] {
+			continue
+		}
+		if err := s.migrateDurationColumn(ctx, db, tableName, rawName, field); err != nil {
+			return fmt.Errorf("迁移 duration 字段 %s 失败: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// migrateDurationColumn 在一个事务中迁移单个 duration 字段
+func (s *SQLiteStorage) migrateDurationColumn(ctx context.Context, db *sql.DB, tableName, rawName string, field *models.Field) error {
+	column := quoteIdent("sqlite", field.Name)
+	temp := field.Name + "__nanos"
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return unavailable(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s%s", tableName, quoteIdent("sqlite", temp),
+		columnType("sqlite", field, s.getSQLiteType), columnConstraints("sqlite", field, true))); err != nil {
+		return err
+	}
+	if err := convertDurationColumn(ctx, tx, "sqlite", tableName, field.Name, temp); err != nil {
+		return err
+	}
+	for _, query := range []string{
+		fmt.Sprintf("DROP INDEX IF EXISTS %s", quoteIdent("sqlite", "idx_"+rawName+"_"+field.Name)),
+		fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, column),
+		fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", tableName, quoteIdent("sqlite", temp), column),
+	} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// createSQLiteIndexes 为索引字段创建 idx_<日志表名>_<字段名> 索引，JSON 路径表达式建立 json_extract 表达式索引
+func createSQLiteIndexes(ctx context.Context, db execer, schema *models.Schema) error {
+	rawName := logTableName(schema.Project, schema.Table)
+	for _, field := range schema.Fields {
+		if field.IndexedOn("sqlite") {
 			indexQuery := fmt.Sprintf(`
-			CREATE INDEX IF NOT EXISTS idx_%s_%s ON %s (%s)`,
-				tableName, field.Name, tableName, field.Name,
+			CREATE INDEX IF NOT EXISTS %s ON %s %s`,
+				quoteIdent("sqlite", "idx_"+rawName+"_"+field.Name), quoteIdent("sqlite", rawName), field.SQLiteIndexTarget(),
 			)
-			if _, err := s.db.ExecContext(ctx, indexQuery); err != nil {
+			if _, err := db.ExecContext(ctx, indexQuery); err != nil {
 				return fmt.Errorf("创建索引失败: %w", err)
 			}
 		}
 	}
-
 	return nil
 }
 
@@ -203,7 +377,9 @@ func (s *SQLiteStorage) getSQLiteType(fieldType models.FieldType) string {
 	case models.FieldTypeTime:
 		return "TEXT"
 	case models.FieldTypeDuration:
-		return "TEXT"
+		return "INTEGER" // 存储为纳秒
+	case models.FieldTypeIP:
+		return "BLOB" // 与 MySQL 相同的二进制编码，便于按网段范围比较
 	case models.FieldTypeJSON:
 		return "TEXT"
 	default:
@@ -213,6 +389,9 @@ func (s *SQLiteStorage) getSQLiteType(fieldType models.FieldType) string {
 
 // Store 存储单条日志
 func (s *SQLiteStorage) Store(ctx context.Context, log *models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
 	// 获取 schema
 	schema, err := s.GetSchema(ctx, log.Project, log.Table)
 	if err != nil {
@@ -224,32 +403,42 @@ func (s *SQLiteStorage) Store(ctx context.Context, log *models.LogEntry) error {
 		return fmt.Errorf("日志数据验证失败: %w", err)
 	}
 
+	ldb, release, err := s.logDB(log.Project)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", log.Project, log.Table)
+	tableName := logTable("sqlite", log.Project, log.Table)
 
 	// 构建插入语句
+	assignIDs(s.ids, []*models.LogEntry{log})
 	columns := []string{"id", "project", "table_name", "timestamp"}
-	values := []interface{}{log.ID, log.Project, log.Table, log.Timestamp}
+	values := []interface{}{log.ID, log.Project, log.Table, log.Timestamp.UTC()}
 	placeholders := []string{"?", "?", "?", "?"}
 
-	for _, field := range schema.Fields {
-		if value, ok := log.Fields[field.Name]; ok {
-			columns = append(columns, field.Name)
-			values = append(values, value)
-			placeholders = append(placeholders, "?")
-		}
+	// 写入全部 schema 字段，缺失的字段以 NULL 占位
+	fields, err := fieldValues("sqlite", schema, log)
+	if err != nil {
+		return err
+	}
+	columns = append(columns, fieldColumns(schema)...)
+	values = append(values, fields...)
+	for range fields {
+		placeholders = append(placeholders, "?")
 	}
 
 	query := fmt.Sprintf(`
 	INSERT INTO %s (%s)
 	VALUES (%s)`,
 		tableName,
-		strings.Join(columns, ", "),
+		quoteIdents("sqlite", columns),
 		strings.Join(placeholders, ", "),
 	)
 
-	if _, err := s.db.ExecContext(ctx, query, values...); err != nil {
-		return fmt.Errorf("插入日志失败: %w", err)
+	if _, err := ldb.db.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("插入日志失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -257,10 +446,13 @@ func (s *SQLiteStorage) Store(ctx context.Context, log *models.LogEntry) error {
 
 // BatchStore 批量存储日志
 func (s *SQLiteStorage) BatchStore(ctx context.Context, logs []*models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
 	// 使用事务批量插入
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
@@ -271,7 +463,7 @@ func (s *SQLiteStorage) BatchStore(ctx context.Context, logs []*models.LogEntry)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -279,6 +471,14 @@ func (s *SQLiteStorage) BatchStore(ctx context.Context, logs []*models.LogEntry)
 
 // Close 关闭数据库连接
 func (s *SQLiteStorage) Close() error {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	if err := s.closeProjects(); err != nil {
+		return err
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -287,6 +487,9 @@ func (s *SQLiteStorage) Close() error {
 
 // BatchInsertLogs 批量插入日志
 func (s *SQLiteStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
 	if len(logs) == 0 {
 		return nil
 	}
@@ -297,50 +500,82 @@ func (s *SQLiteStorage) BatchInsertLogs(ctx context.Context, project, table stri
 		return fmt.Errorf("获取 schema 失败: %w", err)
 	}
 
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// 使用事务批量插入
-	tx, err := s.db.BeginTx(ctx, nil)
+	tx, err := ldb.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("sqlite", project, table)
 
-	// 准备字段列表
-	var columns []string
-	for _, field := range schema.Fields {
-		columns = append(columns, field.Name)
+	// 列顺序固定为基础列加 schema 字段，整批日志共用一条预编译语句
+	columns := []string{"id", "timestamp"}
+	if schema.StoresTags() {
+		columns = append(columns, models.TagsColumn)
 	}
+	if schema.StoresIngestTime() {
+		columns = append(columns, models.IngestTimeColumn)
+	}
+	columns = append(columns, fieldColumns(schema)...)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", tableName, quoteIdents("sqlite", columns), placeholders)
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("准备语句失败: %w", unavailable(err))
+	}
+	defer stmt.Close()
 
 	// 批量插入
+	assignIDs(s.ids, logs)
 	for _, log := range logs {
+		// 取消或超时后不再继续写入，事务由 defer 回滚
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("批量写入已中止: %w", err)
+		}
+
 		// 验证日志数据
 		if err := schema.ValidateLogEntry(log); err != nil {
 			return fmt.Errorf("日志数据验证失败: %w", err)
 		}
 
-		values := make([]interface{}, 0, len(columns))
-		placeholders := make([]string, 0, len(columns))
-		for _, col := range columns {
-			if value, ok := log.Fields[col]; ok {
-				values = append(values, value)
-				placeholders = append(placeholders, "?")
+		values := []interface{}{log.ID, timestampValue(log.Timestamp)}
+		if schema.StoresTags() {
+			tags, err := tagsValue(log.Tags)
+			if err != nil {
+				return err
 			}
+			values = append(values, tags)
 		}
+		if schema.StoresIngestTime() {
+			values = append(values, timestampValue(log.IngestTime))
+		}
+		fields, err := fieldValues("sqlite", schema, log)
+		if err != nil {
+			return err
+		}
+		values = append(values, fields...)
 
-		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-			tableName,
-			strings.Join(columns, ", "),
-			strings.Join(placeholders, ", "))
-
-		if _, err := tx.ExecContext(ctx, query, values...); err != nil {
-			return fmt.Errorf("插入日志失败: %w", err)
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return fmt.Errorf("插入日志失败: %w", unavailable(err))
 		}
 	}
 
+	// 增量更新持续聚合
+	if err := ldb.cq.apply(ctx, tx, schema, logs); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
@@ -348,8 +583,11 @@ func (s *SQLiteStorage) BatchInsertLogs(ctx context.Context, project, table stri
 
 // CountLogs 统计日志数量
 func (s *SQLiteStorage) CountLogs(ctx context.Context, project, table string, query map[string]interface{}) (int64, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("sqlite", project, table)
 
 	// 构建查询条件
 	conditions := make([]string, 0, len(query))
@@ -357,7 +595,7 @@ func (s *SQLiteStorage) CountLogs(ctx context.Context, project, table string, qu
 	paramCount := 1
 
 	for key, value := range query {
-		conditions = append(conditions, fmt.Sprintf("%s = ?", key))
+		conditions = append(conditions, fmt.Sprintf("%s = ?", quoteIdent("sqlite", key)))
 		values = append(values, value)
 		paramCount++
 	}
@@ -368,11 +606,17 @@ func (s *SQLiteStorage) CountLogs(ctx context.Context, project, table string, qu
 		sql += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
 	// 执行查询
 	var count int64
-	err := s.db.QueryRowContext(ctx, sql, values...).Scan(&count)
+	err = ldb.db.QueryRowContext(ctx, sql, values...).Scan(&count)
 	if err != nil {
-		return 0, fmt.Errorf("统计日志失败: %w", err)
+		return 0, fmt.Errorf("统计日志失败: %w", unavailable(err))
 	}
 
 	return count, nil
@@ -380,18 +624,44 @@ func (s *SQLiteStorage) CountLogs(ctx context.Context, project, table string, qu
 
 // DeleteSchema 删除 schema
 func (s *SQLiteStorage) DeleteSchema(ctx context.Context, project, table string) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
 	// 开启事务
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("开始事务失败: %w", err)
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
 	}
 	defer tx.Rollback()
 
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// 项目使用独立数据库文件时，日志表在单独的事务中删除
+	logTx := tx
+	if ldb.db != s.db {
+		if logTx, err = ldb.db.BeginTx(ctx, nil); err != nil {
+			return fmt.Errorf("开始事务失败: %w", unavailable(err))
+		}
+		defer logTx.Rollback()
+	}
+
+	// 删除前读取 schema 以便清理聚合表
+	schema, err := s.GetSchema(ctx, project, table)
+	if err == nil {
+		if err := ldb.cq.dropTables(ctx, logTx, schema); err != nil {
+			return err
+		}
+	}
+
 	// 删除 schema 元数据
 	query := `DELETE FROM schemas WHERE project = ? AND table_name = ?`
 	result, err := tx.ExecContext(ctx, query, project, table)
 	if err != nil {
-		return fmt.Errorf("删除 schema 失败: %w", err)
+		return fmt.Errorf("删除 schema 失败: %w", unavailable(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -399,24 +669,171 @@ func (s *SQLiteStorage) DeleteSchema(ctx context.Context, project, table string)
 		return fmt.Errorf("获取影响行数失败: %w", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("schema not found: %s_%s", project, table)
+		return fmt.Errorf("%w: %s_%s", models.ErrSchemaNotFound, project, table)
 	}
 
 	// 删除日志表
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("sqlite", project, table)
 	dropQuery := fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName)
-	if _, err := tx.ExecContext(ctx, dropQuery); err != nil {
+	if _, err := logTx.ExecContext(ctx, dropQuery); err != nil {
 		return fmt.Errorf("删除日志表失败: %w", err)
 	}
+	if logTx != tx {
+		if err := logTx.Commit(); err != nil {
+			return fmt.Errorf("提交事务失败: %w", unavailable(err))
+		}
+	}
 
 	// 提交事务
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
 	}
 
 	return nil
 }
 
+// DeleteSchemaRecord 只删除 schema 记录，保留日志表与持续聚合表
+func (s *SQLiteStorage) DeleteSchemaRecord(ctx context.Context, project, table string) error {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return deleteSchemaRecord(ctx, s.db, "sqlite", project, table)
+}
+
+// ArchiveSchema 删除 schema 记录，将日志表重命名为归档表并删除持续聚合表
+func (s *SQLiteStorage) ArchiveSchema(ctx context.Context, project, table string) (*models.ArchivedSchema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return s.archives.archive(ctx, s, project, table, logTableName(project, table), s.config.Clock,
+		func(tx *sql.Tx, archived *models.ArchivedSchema) error {
+			// 项目使用独立数据库文件时，日志表在单独的事务中重命名
+			logTx := tx
+			if ldb.db != s.db {
+				if logTx, err = ldb.db.BeginTx(ctx, nil); err != nil {
+					return fmt.Errorf("开始事务失败: %w", unavailable(err))
+				}
+				defer logTx.Rollback()
+			}
+			if err := ldb.cq.dropTables(ctx, logTx, archived.Schema); err != nil {
+				return err
+			}
+			if err := renameTable(ctx, logTx, "sqlite", logTable("sqlite", project, table), archived.ArchiveTable); err != nil {
+				return err
+			}
+			if logTx != tx {
+				if err := logTx.Commit(); err != nil {
+					return fmt.Errorf("提交事务失败: %w", unavailable(err))
+				}
+			}
+			return nil
+		})
+}
+
+// ListArchivedSchemas 列出全部归档
+func (s *SQLiteStorage) ListArchivedSchemas(ctx context.Context) ([]*models.ArchivedSchema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return s.archives.list(ctx)
+}
+
+// RestoreSchema 恢复最近一次归档的日志表与 schema
+func (s *SQLiteStorage) RestoreSchema(ctx context.Context, project, table string) (*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return s.archives.restore(ctx, s, project, table, func(archived *models.ArchivedSchema) error {
+		return renameTable(ctx, ldb.db, "sqlite", quoteIdent("sqlite", archived.ArchiveTable), logTableName(project, table))
+	})
+}
+
+// PurgeArchivedSchemas 删除 before 之前归档的日志表
+func (s *SQLiteStorage) PurgeArchivedSchemas(ctx context.Context, before time.Time) ([]*models.ArchivedSchema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	return s.archives.purge(ctx, before, func(archived *models.ArchivedSchema) error {
+		ldb, release, err := s.logDB(archived.Project)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		_, err = ldb.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+quoteIdent("sqlite", archived.ArchiveTable))
+		return err
+	})
+}
+
+// RenameSchema 重命名 schema 及其日志表、索引与持续聚合表。启用 PerProject 时日志表只能在
+// 同一项目内重命名，已滚动的归档文件保留原表名
+func (s *SQLiteStorage) RenameSchema(ctx context.Context, project, table, newProject, newTable string) (*models.Schema, error) {
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	if s.config.SQLite.PerProject && project != newProject {
+		return nil, fmt.Errorf("%w: tables cannot move between projects when sqlite per_project is enabled", models.ErrValidation)
+	}
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return renameSchema(ctx, s.db, "sqlite", s, project, table, newProject, newTable, func(tx *sql.Tx, from, to *models.Schema) error {
+		// 项目使用独立数据库文件时，日志表在单独的事务中重命名
+		logTx := tx
+		if ldb.db != s.db {
+			if logTx, err = ldb.db.BeginTx(ctx, nil); err != nil {
+				return fmt.Errorf("开始事务失败: %w", unavailable(err))
+			}
+			defer logTx.Rollback()
+		}
+
+		// SQLite 不支持重命名索引，删除旧索引后按新表名重建
+		oldName := logTableName(project, table)
+		indexes, err := columnValues(ctx, logTx, `SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ?`, oldName)
+		if err != nil {
+			return fmt.Errorf("查询索引失败: %w", err)
+		}
+		for _, index := range indexes {
+			if !strings.HasPrefix(index, "idx_"+oldName+"_") {
+				continue
+			}
+			if _, err := logTx.ExecContext(ctx, "DROP INDEX IF EXISTS "+quoteIdent("sqlite", index)); err != nil {
+				return fmt.Errorf("删除索引失败: %w", err)
+			}
+		}
+		if err := renameTable(ctx, logTx, "sqlite", logTable("sqlite", project, table), logTableName(newProject, newTable)); err != nil {
+			return err
+		}
+		if err := createSQLiteIndexes(ctx, logTx, to); err != nil {
+			return err
+		}
+		if err := renameAggregateTables(ctx, logTx, "sqlite", from, to); err != nil {
+			return err
+		}
+		if logTx != tx {
+			if err := logTx.Commit(); err != nil {
+				return fmt.Errorf("提交事务失败: %w", unavailable(err))
+			}
+		}
+		return nil
+	})
+}
+
 // InsertLog 插入单条日志
 func (s *SQLiteStorage) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
 	return s.BatchInsertLogs(ctx, project, table, []*models.LogEntry{log})
@@ -424,22 +841,26 @@ func (s *SQLiteStorage) InsertLog(ctx context.Context, project, table string, lo
 
 // ListSchemas 列出所有 schemas
 func (s *SQLiteStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error) {
-	query := `SELECT project, table_name, description, fields, created_at, updated_at FROM schemas`
+	ctx, cancel := s.config.Timeouts.schema(ctx)
+	defer cancel()
+
+	query := `SELECT project, table_name, description, fields, options, created_at, updated_at FROM schemas`
 	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("查询 schemas 失败: %w", err)
+		return nil, fmt.Errorf("查询 schemas 失败: %w", unavailable(err))
 	}
 	defer rows.Close()
 
 	var schemas []*models.Schema
 	for rows.Next() {
 		var schema models.Schema
-		var fieldsJSON []byte
+		var fieldsJSON, optionsJSON []byte
 		err := rows.Scan(
 			&schema.Project,
 			&schema.Table,
 			&schema.Description,
 			&fieldsJSON,
+			&optionsJSON,
 			&schema.CreatedAt,
 			&schema.UpdatedAt,
 		)
@@ -452,6 +873,9 @@ func (s *SQLiteStorage) ListSchemas(ctx context.Context) ([]*models.Schema, erro
 			return nil, fmt.Errorf("解析字段失败: %w", err)
 		}
 		schema.Fields = fields
+		if err := unmarshalSchemaOptions(optionsJSON, &schema); err != nil {
+			return nil, err
+		}
 		schemas = append(schemas, &schema)
 	}
 
@@ -460,13 +884,19 @@ func (s *SQLiteStorage) ListSchemas(ctx context.Context) ([]*models.Schema, erro
 
 // Ping 测试数据库连接
 func (s *SQLiteStorage) Ping(ctx context.Context) error {
-	return s.db.PingContext(ctx)
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%w: %w", ErrBackendUnavailable, err)
+	}
+	return nil
 }
 
 // QueryLogs 查询日志
 func (s *SQLiteStorage) QueryLogs(ctx context.Context, project, table string, query map[string]interface{}, limit, offset int) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
 	// 构建表名
-	tableName := fmt.Sprintf("logs_%s_%s", project, table)
+	tableName := logTable("sqlite", project, table)
 
 	// 构建查询条件
 	conditions := make([]string, 0, len(query))
@@ -474,7 +904,7 @@ func (s *SQLiteStorage) QueryLogs(ctx context.Context, project, table string, qu
 	paramCount := 1
 
 	for key, value := range query {
-		conditions = append(conditions, fmt.Sprintf("%s = ?", key))
+		conditions = append(conditions, fmt.Sprintf("%s = ?", quoteIdent("sqlite", key)))
 		values = append(values, value)
 		paramCount++
 	}
@@ -486,10 +916,16 @@ func (s *SQLiteStorage) QueryLogs(ctx context.Context, project, table string, qu
 	}
 	sql += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// 执行查询
-	rows, err := s.db.QueryContext(ctx, sql, values...)
+	rows, err := ldb.db.QueryContext(ctx, sql, values...)
 	if err != nil {
-		return nil, fmt.Errorf("查询日志失败: %w", err)
+		return nil, fmt.Errorf("查询日志失败: %w", unavailable(err))
 	}
 	defer rows.Close()
 
@@ -536,4 +972,272 @@ func (s *SQLiteStorage) UpdateSchema(ctx context.Context, schema *models.Schema)
 	return s.CreateSchema(ctx, schema)
 }
 
-var _ Storage = (*SQLiteStorage)(nil)
+// QueryAggregate 查询持续聚合结果
+func (s *SQLiteStorage) QueryAggregate(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return ldb.cq.query(ctx, ldb.db, schema, name, from, to)
+}
+
+// SearchLogs 执行带字段选择与排序的日志查询
+func (s *SQLiteStorage) SearchLogs(ctx context.Context, project, table string, query *models.Query) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return searchLogs(ctx, ldb.db, "sqlite", logTable("sqlite", project, table), schema, query)
+}
+
+// ExplainQuery 返回日志查询的执行计划
+func (s *SQLiteStorage) ExplainQuery(ctx context.Context, project, table string, query *models.Query, analyze bool) (*models.QueryPlan, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return explainQuery(ctx, ldb.db, "sqlite", logTable("sqlite", project, table), schema, query, analyze)
+}
+
+// FieldValues 统计字段出现最多的取值
+func (s *SQLiteStorage) FieldValues(ctx context.Context, project, table, field string, query *models.Query) (*models.FieldValues, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return topFieldValues(ctx, ldb.db, "sqlite", logTable("sqlite", project, table), schema, field, query)
+}
+
+// SaveQuery 保存查询
+func (s *SQLiteStorage) SaveQuery(ctx context.Context, query *models.SavedQuery) error {
+	return s.sq.save(ctx, query)
+}
+
+// GetSavedQuery 获取保存的查询
+func (s *SQLiteStorage) GetSavedQuery(ctx context.Context, owner, name string) (*models.SavedQuery, error) {
+	return s.sq.get(ctx, owner, name)
+}
+
+// ListSavedQueries 列出保存的查询
+func (s *SQLiteStorage) ListSavedQueries(ctx context.Context, owner string) ([]*models.SavedQuery, error) {
+	return s.sq.list(ctx, owner)
+}
+
+// DeleteSavedQuery 删除保存的查询
+func (s *SQLiteStorage) DeleteSavedQuery(ctx context.Context, owner, name string) error {
+	return s.sq.delete(ctx, owner, name)
+}
+
+// SaveReport 保存定时报表
+func (s *SQLiteStorage) SaveReport(ctx context.Context, report *models.Report) error {
+	return s.sq.saveReport(ctx, report)
+}
+
+// GetReport 获取定时报表
+func (s *SQLiteStorage) GetReport(ctx context.Context, owner, name string) (*models.Report, error) {
+	return s.sq.getReport(ctx, owner, name)
+}
+
+// ListReports 列出定时报表
+func (s *SQLiteStorage) ListReports(ctx context.Context, owner string) ([]*models.Report, error) {
+	return s.sq.listReports(ctx, owner)
+}
+
+// DeleteReport 删除定时报表
+func (s *SQLiteStorage) DeleteReport(ctx context.Context, owner, name string) error {
+	return s.sq.deleteReport(ctx, owner, name)
+}
+
+// RecordIssues 累计错误归并问题的出现次数
+func (s *SQLiteStorage) RecordIssues(ctx context.Context, issues []*models.Issue) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+	return s.sq.recordIssues(ctx, issues)
+}
+
+// GetIssue 获取问题
+func (s *SQLiteStorage) GetIssue(ctx context.Context, project, table, fingerprint string) (*models.Issue, error) {
+	return s.sq.getIssue(ctx, project, table, fingerprint)
+}
+
+// ListIssues 列出问题
+func (s *SQLiteStorage) ListIssues(ctx context.Context, filter *models.IssueFilter) ([]*models.Issue, error) {
+	return s.sq.listIssues(ctx, filter)
+}
+
+// SetIssueStatus 更新问题状态
+func (s *SQLiteStorage) SetIssueStatus(ctx context.Context, project, table, fingerprint string, status models.IssueStatus) error {
+	return s.sq.setIssueStatus(ctx, project, table, fingerprint, status)
+}
+
+// DeleteIssue 删除问题
+func (s *SQLiteStorage) DeleteIssue(ctx context.Context, project, table, fingerprint string) error {
+	return s.sq.deleteIssue(ctx, project, table, fingerprint)
+}
+
+// RecordSlowQuery 记录慢查询
+func (s *SQLiteStorage) RecordSlowQuery(ctx context.Context, query *models.SlowQuery) error {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+	return s.sq.recordSlowQuery(ctx, query)
+}
+
+// ListSlowQueries 列出慢查询
+func (s *SQLiteStorage) ListSlowQueries(ctx context.Context, filter *models.SlowQueryFilter) ([]*models.SlowQuery, error) {
+	return s.sq.listSlowQueries(ctx, filter)
+}
+
+// PurgeSlowQueries 删除过期的慢查询记录
+func (s *SQLiteStorage) PurgeSlowQueries(ctx context.Context, before time.Time) (int64, error) {
+	return s.sq.purgeSlowQueries(ctx, before)
+}
+
+// CountMatching 统计匹配过滤条件的日志数
+func (s *SQLiteStorage) CountMatching(ctx context.Context, project, table string, filter *models.LogFilter) (int64, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return 0, err
+	}
+
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return countMatching(ctx, ldb.db, "sqlite", logTable("sqlite", project, table), schema, filter)
+}
+
+// DeleteLogs 删除匹配过滤条件的日志
+func (s *SQLiteStorage) DeleteLogs(ctx context.Context, project, table string, filter *models.LogFilter, limit int64) (int64, error) {
+	return s.mutateLogs(ctx, project, table, filter, nil, limit)
+}
+
+// UpdateLogs 修改匹配过滤条件的日志字段
+func (s *SQLiteStorage) UpdateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	return s.mutateLogs(ctx, project, table, filter, set, limit)
+}
+
+// mutateLogs 删除或修改匹配的日志
+func (s *SQLiteStorage) mutateLogs(ctx context.Context, project, table string, filter *models.LogFilter, set map[string]interface{}, limit int64) (int64, error) {
+	ctx, cancel := s.config.Timeouts.write(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return 0, err
+	}
+
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return mutateLogs(ctx, ldb.db, "sqlite", logTable("sqlite", project, table), schema, filter, set, limit)
+}
+
+// RollupLogs 将早于 before 的日志汇总到 rollup 表并删除
+func (s *SQLiteStorage) RollupLogs(ctx context.Context, project, table string, before time.Time) (int64, error) {
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return 0, err
+	}
+
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return rollupLogs(ctx, ldb.db, ldb.cq, s.config.Timeouts, schema, before)
+}
+
+// QueryRollup 查询 rollup 汇总结果
+func (s *SQLiteStorage) QueryRollup(ctx context.Context, project, table, name string, from, to time.Time) ([]map[string]interface{}, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	schema, err := s.GetSchema(ctx, project, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ldb, release, err := s.logDB(project)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return ldb.cq.queryRollup(ctx, ldb.db, schema, name, from, to)
+}
+
+// TableSizes 按抽样行的平均大小估算各日志表的数据大小
+func (s *SQLiteStorage) TableSizes(ctx context.Context) ([]*models.TableSize, error) {
+	ctx, cancel := s.config.Timeouts.query(ctx)
+	defer cancel()
+
+	return tableSizes(ctx, s, "sample", func(ctx context.Context, schema *models.Schema) (*models.TableSize, error) {
+		ldb, release, err := s.logDB(schema.Project)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return sampleTableSize(ctx, ldb.db, logTable("sqlite", schema.Project, schema.Table))
+	})
+}
+
+var (
+	_ Storage           = (*SQLiteStorage)(nil)
+	_ ContinuousQuerier = (*SQLiteStorage)(nil)
+	_ LogQuerier        = (*SQLiteStorage)(nil)
+	_ SavedQueryStore   = (*SQLiteStorage)(nil)
+	_ ReportStore       = (*SQLiteStorage)(nil)
+	_ IssueStore        = (*SQLiteStorage)(nil)
+	_ LogMutator        = (*SQLiteStorage)(nil)
+	_ Roller            = (*SQLiteStorage)(nil)
+	_ SizeReporter      = (*SQLiteStorage)(nil)
+)
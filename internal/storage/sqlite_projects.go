@@ -0,0 +1,500 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+// segmentTimeFormat 滚动归档文件名中的时间格式
+const segmentTimeFormat = "20060102T150405.000000"
+
+// logDB 日志表所在的数据库及其持续聚合引擎
+type logDB struct {
+	db *sql.DB
+	cq *continuousQueries
+}
+
+// projectDB 项目独立的数据库文件，滚动时持有写锁
+type projectDB struct {
+	mu   sync.RWMutex
+	path string
+	logDB
+}
+
+// projectDir 返回项目数据库文件所在目录
+func (s *SQLiteStorage) projectDir() string {
+	if s.config.SQLite.Dir != "" {
+		return s.config.SQLite.Dir
+	}
+	return filepath.Join(filepath.Dir(s.config.SQLite.Path), "projects")
+}
+
+// logDB 返回项目日志表所在的数据库，调用方使用完毕后需调用 release
+func (s *SQLiteStorage) logDB(project string) (*logDB, func(), error) {
+	if !s.config.SQLite.PerProject {
+		return &logDB{db: s.db, cq: s.cq}, func() {}, nil
+	}
+
+	p, err := s.openProject(project)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.mu.RLock()
+	return &p.logDB, p.mu.RUnlock, nil
+}
+
+// openProject 获取或打开项目数据库文件
+func (s *SQLiteStorage) openProject(project string) (*projectDB, error) {
+	s.projectsMu.Lock()
+	defer s.projectsMu.Unlock()
+
+	if p, ok := s.projects[project]; ok {
+		return p, nil
+	}
+	// 项目名会拼接进文件路径，拒绝 ../ 等非法名称
+	if err := models.ValidateIdentifier("project", project); err != nil {
+		return nil, err
+	}
+
+	dir := s.projectDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建项目目录失败: %w", err)
+	}
+
+	path := filepath.Join(dir, project+".db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("打开项目数据库失败: %w", unavailable(err))
+	}
+
+	p := &projectDB{path: path, logDB: logDB{db: db, cq: newContinuousQueries(db, "sqlite")}}
+	s.projects[project] = p
+	return p, nil
+}
+
+// closeProjects 关闭全部项目数据库
+func (s *SQLiteStorage) closeProjects() error {
+	s.projectsMu.Lock()
+	defer s.projectsMu.Unlock()
+
+	var firstErr error
+	for name, p := range s.projects {
+		p.mu.Lock()
+		if err := p.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		p.mu.Unlock()
+		delete(s.projects, name)
+	}
+	return firstErr
+}
+
+// maintenanceLoop 定期执行项目数据库维护
+func (s *SQLiteStorage) maintenanceLoop(interval time.Duration) {
+	ticker := clock.OrReal(s.config.Clock).NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := s.Compact(ctx); err != nil {
+				s.logger.Error("failed to compact sqlite projects", zap.Error(err))
+			}
+			cancel()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Compact 对每个项目数据库执行一次维护：VACUUM 回收空间，超过 MaxSize 时滚动为归档文件，
+// 并将相邻的小归档文件合并，直到合并后的大小接近 MaxSize。未启用 PerProject 时仅 VACUUM 主库
+func (s *SQLiteStorage) Compact(ctx context.Context) error {
+	if !s.config.SQLite.PerProject {
+		if _, err := s.db.ExecContext(ctx, "VACUUM"); err != nil {
+			return fmt.Errorf("VACUUM 失败: %w", err)
+		}
+		return nil
+	}
+
+	projects, err := s.knownProjects(ctx)
+	if err != nil {
+		return err
+	}
+	for _, project := range projects {
+		if err := s.compactProject(ctx, project); err != nil {
+			return fmt.Errorf("维护项目 %s 失败: %w", project, err)
+		}
+	}
+	return nil
+}
+
+// knownProjects 返回已注册 schema 的项目
+func (s *SQLiteStorage) knownProjects(ctx context.Context) ([]string, error) {
+	names, err := queryNames(ctx, s.db, `SELECT DISTINCT project FROM schemas`)
+	if err != nil {
+		return nil, fmt.Errorf("查询项目失败: %w", err)
+	}
+	projects := make([]string, 0, len(names))
+	for name := range names {
+		projects = append(projects, name)
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// compactProject 维护单个项目：VACUUM、按大小滚动、合并小归档
+func (s *SQLiteStorage) compactProject(ctx context.Context, project string) error {
+	p, err := s.openProject(project)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := p.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("VACUUM 失败: %w", err)
+	}
+
+	maxSize := s.config.SQLite.MaxSize
+	if maxSize <= 0 {
+		return nil
+	}
+
+	if info, err := os.Stat(p.path); err == nil && info.Size() > maxSize {
+		if err := s.rollover(ctx, project, p); err != nil {
+			return err
+		}
+	}
+
+	return s.mergeSegments(ctx, project)
+}
+
+// rollover 将当前项目文件归档并创建新文件，重新建立该项目全部日志表
+func (s *SQLiteStorage) rollover(ctx context.Context, project string, p *projectDB) error {
+	if err := p.db.Close(); err != nil {
+		return fmt.Errorf("关闭项目数据库失败: %w", err)
+	}
+
+	archive := filepath.Join(filepath.Dir(p.path), fmt.Sprintf("%s-%s.db", project, clock.OrReal(s.config.Clock).Now().Format(segmentTimeFormat)))
+	if err := os.Rename(p.path, archive); err != nil {
+		return fmt.Errorf("归档项目数据库失败: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", p.path)
+	if err != nil {
+		return fmt.Errorf("打开项目数据库失败: %w", unavailable(err))
+	}
+	p.logDB = logDB{db: db, cq: newContinuousQueries(db, "sqlite")}
+
+	schemas, err := s.ListSchemas(ctx)
+	if err != nil {
+		return err
+	}
+	for _, schema := range schemas {
+		if schema.Project != project {
+			continue
+		}
+		if err := s.createLogTable(ctx, db, schema); err != nil {
+			return err
+		}
+		if err := p.cq.createTables(ctx, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segments 返回项目的归档文件，按时间从旧到新排序
+func (s *SQLiteStorage) segments(project string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.projectDir(), project+"-*.db"))
+	if err != nil {
+		return nil, err
+	}
+	segments := matches[:0]
+	for _, m := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), project+"-"), ".db")
+		if _, err := time.Parse(segmentTimeFormat, suffix); err == nil {
+			segments = append(segments, m)
+		}
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// mergeSegments 将相邻的小归档文件合并到较旧的文件中
+func (s *SQLiteStorage) mergeSegments(ctx context.Context, project string) error {
+	segments, err := s.segments(project)
+	if err != nil {
+		return fmt.Errorf("查找归档文件失败: %w", err)
+	}
+
+	for i := 0; i+1 < len(segments); {
+		older, newer := segments[i], segments[i+1]
+		olderInfo, err1 := os.Stat(older)
+		newerInfo, err2 := os.Stat(newer)
+		if err1 != nil || err2 != nil || olderInfo.Size()+newerInfo.Size() > s.config.SQLite.MaxSize {
+			i++
+			continue
+		}
+
+		if err := mergeSegment(ctx, older, newer); err != nil {
+			return err
+		}
+		if err := os.Remove(newer); err != nil {
+			return fmt.Errorf("删除已合并归档失败: %w", err)
+		}
+		segments = append(segments[:i+1], segments[i+2:]...)
+	}
+	return nil
+}
+
+// mergeSegment 将 src 中的全部表数据追加到 dst，列不一致时只复制共有列
+func mergeSegment(ctx context.Context, dst, src string) error {
+	db, err := sql.Open("sqlite3", dst)
+	if err != nil {
+		return fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	defer db.Close()
+
+	// ATTACH 只对当前连接有效，使用独立连接完成合并
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("获取连接失败: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS src`, src); err != nil {
+		return fmt.Errorf("挂载归档文件失败: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `DETACH DATABASE src`)
+
+	tables, err := columnValues(ctx, conn, `SELECT name FROM src.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return fmt.Errorf("查询归档表失败: %w", err)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", unavailable(err))
+	}
+	defer tx.Rollback()
+
+	for _, table := range tables {
+		dstColumns, err := columnValues(ctx, tx, `SELECT name FROM pragma_table_info(?, 'main')`, table)
+		if err != nil {
+			return fmt.Errorf("查询表字段失败: %w", err)
+		}
+		if len(dstColumns) == 0 {
+			if dstColumns, err = copyTableDefinition(ctx, tx, table); err != nil {
+				return err
+			}
+		}
+
+		srcColumns, err := columnValues(ctx, tx, `SELECT name FROM pragma_table_info(?, 'src')`, table)
+		if err != nil {
+			return fmt.Errorf("查询表字段失败: %w", err)
+		}
+		srcSet := make(map[string]bool, len(srcColumns))
+		for _, c := range srcColumns {
+			srcSet[c] = true
+		}
+		common := make([]string, 0, len(dstColumns))
+		for _, c := range dstColumns {
+			if srcSet[c] {
+				common = append(common, c)
+			}
+		}
+		if len(common) == 0 {
+			continue
+		}
+
+		if isSummaryTable(table) {
+			if err := mergeSummaryTable(ctx, tx, table, common); err != nil {
+				return err
+			}
+			continue
+		}
+		cols := quoteIdents("sqlite", common)
+		query := fmt.Sprintf(`INSERT OR IGNORE INTO main.%s (%s) SELECT %s FROM src.%s`, quoteIdent("sqlite", table), cols, cols, quoteIdent("sqlite", table))
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("合并表 %s 失败: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", unavailable(err))
+	}
+	return nil
+}
+
+// copyTableDefinition 按 src 中的建表语句在 main 中创建表及其索引，保留主键，返回新表的列
+func copyTableDefinition(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	statements, err := columnValues(ctx, tx, `SELECT sql FROM src.sqlite_master
+	WHERE tbl_name = ? AND type IN ('table', 'index') AND sql IS NOT NULL ORDER BY type = 'index'`, table)
+	if err != nil {
+		return nil, fmt.Errorf("读取表 %s 的定义失败: %w", table, err)
+	}
+	// 未指定库名的 CREATE 语句建在 main 中
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return nil, fmt.Errorf("复制表 %s 失败: %w", table, err)
+		}
+	}
+	return columnValues(ctx, tx, `SELECT name FROM pragma_table_info(?, 'main')`, table)
+}
+
+// mergeSummaryTable 将 src 中持续聚合侧表或 rollup 汇总表的行合并到 main：时间桶与分组相同的行
+// 与写入时的 upsert 一样 count、sum 相加，min、max 取极值，分位数草图在 Go 中合并
+func mergeSummaryTable(ctx context.Context, tx *sql.Tx, table string, columns []string) error {
+	keys, err := columnValues(ctx, tx, `SELECT name FROM pragma_table_info(?, 'main') WHERE pk > 0 ORDER BY pk`, table)
+	if err != nil {
+		return fmt.Errorf("查询表 %s 的主键失败: %w", table, err)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("聚合表 %s 没有主键，无法合并", table)
+	}
+
+	cq := &continuousQueries{dialect: "sqlite"}
+	var sketches, updates []string
+	for _, column := range columns {
+		if strings.HasPrefix(column, "sketch_") {
+			sketches = append(sketches, column)
+		} else if expr := cq.summaryMergeExpr(column); expr != "" {
+			updates = append(updates, cq.mergeExpr(quoteIdent("sqlite", column), expr))
+		}
+	}
+
+	quoted := quoteIdent("sqlite", table)
+	joins := make([]string, len(keys))
+	for i, key := range keys {
+		joins[i] = fmt.Sprintf("m.%[1]s = s.%[1]s", quoteIdent("sqlite", key))
+	}
+	// 先合并已有行的草图，upsert 不修改草图列，新插入的行直接使用 src 的草图
+	if len(sketches) > 0 {
+		if err := mergeSegmentSketches(ctx, tx, quoted, sketches, strings.Join(joins, " AND ")); err != nil {
+			return fmt.Errorf("合并表 %s 失败: %w", table, err)
+		}
+	}
+
+	cols := quoteIdents("sqlite", columns)
+	// SELECT 后接 ON CONFLICT 时需要 WHERE 子句消除语法歧义
+	query := fmt.Sprintf(`INSERT INTO main.%s (%s) SELECT %s FROM src.%s WHERE true ON CONFLICT(%s) DO `,
+		quoted, cols, cols, quoted, quoteIdents("sqlite", keys))
+	if len(updates) == 0 {
+		query += "NOTHING"
+	} else {
+		query += "UPDATE SET " + strings.Join(updates, ", ")
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("合并表 %s 失败: %w", table, err)
+	}
+	return nil
+}
+
+// mergeSegmentSketches 将 src 中与 main 时间桶、分组相同的行的分位数草图合并到 main
+func mergeSegmentSketches(ctx context.Context, tx *sql.Tx, table string, sketches []string, join string) error {
+	selects := []string{"m.rowid"}
+	for _, column := range sketches {
+		quoted := quoteIdent("sqlite", column)
+		selects = append(selects, "m."+quoted, "s."+quoted)
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM src.%s s JOIN main.%s m ON %s`,
+		strings.Join(selects, ", "), table, table, join))
+	if err != nil {
+		return err
+	}
+
+	type merged struct {
+		rowid  int64
+		values []interface{}
+	}
+	var pending []merged
+	for rows.Next() {
+		var rowid int64
+		stored := make([]sql.NullString, 2*len(sketches))
+		dest := []interface{}{&rowid}
+		for i := range stored {
+			dest = append(dest, &stored[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return err
+		}
+		row := merged{rowid: rowid}
+		for i := range sketches {
+			a, err := decodeSketch(stored[2*i])
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			b, err := decodeSketch(stored[2*i+1])
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			a.Merge(b)
+			data, err := json.Marshal(a)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			row.values = append(row.values, string(data))
+		}
+		pending = append(pending, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sets := make([]string, len(sketches))
+	for i, column := range sketches {
+		sets[i] = quoteIdent("sqlite", column) + " = ?"
+	}
+	update := fmt.Sprintf("UPDATE main.%s SET %s WHERE rowid = ?", table, strings.Join(sets, ", "))
+	for _, row := range pending {
+		if _, err := tx.ExecContext(ctx, update, append(row.values, row.rowid)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryer 可执行查询的连接或事务
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// columnValues 执行单列查询并按顺序返回结果
+func columnValues(ctx context.Context, q queryer, query string, args ...interface{}) ([]string, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+func TestSQLitePerProjectCompaction(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewSQLiteStorage(Config{
+		Type: "sqlite",
+		SQLite: SQLiteConfig{
+			Path:       filepath.Join(dir, "meta.db"),
+			PerProject: true,
+			MaxSize:    1,
+		},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString}},
+	}
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	insert := func(name string) {
+		require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+			Project:   "app",
+			Table:     "events",
+			Level:     "info",
+			Message:   "event",
+			Timestamp: time.Now(),
+			Fields:    map[string]interface{}{"name": name},
+		}))
+	}
+
+	insert("first")
+	assert.FileExists(t, filepath.Join(dir, "projects", "app.db"))
+	count, err := store.CountLogs(ctx, "app", "events", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// 超过 MaxSize 时滚动，新文件保留表结构
+	require.NoError(t, store.Compact(ctx))
+	insert("second")
+	require.NoError(t, store.Compact(ctx))
+	segments, err := store.segments("app")
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+
+	count, err = store.CountLogs(ctx, "app", "events", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	// 归档合并后不超过 MaxSize 时合并为一个文件
+	store.config.SQLite.MaxSize = 1 << 30
+	require.NoError(t, store.Compact(ctx))
+	segments, err = store.segments("app")
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	db, err := sql.Open("sqlite3", segments[0])
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM logs_app_events").Scan(&count))
+	assert.Equal(t, int64(2), count)
+
+	// 删除 schema 时同时删除项目文件中的日志表
+	require.NoError(t, store.DeleteSchema(ctx, "app", "events"))
+	_, err = os.Stat(filepath.Join(dir, "projects", "app.db"))
+	require.NoError(t, err)
+	_, err = store.CountLogs(ctx, "app", "events", nil)
+	assert.Error(t, err)
+}
+
+func TestSQLiteMaintenanceLoop(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	mock := clock.NewMock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewSQLiteStorage(Config{
+		Type:  "sqlite",
+		Clock: mock,
+		SQLite: SQLiteConfig{
+			Path:                filepath.Join(dir, "meta.db"),
+			PerProject:          true,
+			MaintenanceInterval: time.Hour,
+			MaxSize:             1,
+		},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	require.NoError(t, store.CreateSchema(ctx, &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString}},
+	}))
+
+	// 推进一个维护周期后，按模拟时间命名的归档文件出现
+	mock.BlockUntil(1)
+	mock.Add(time.Hour)
+	archive := filepath.Join(dir, "projects", "app-20240101T010000.000000.db")
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(archive)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSQLiteDeleteSchemaRecord(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{Type: "sqlite", SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")}})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields:  []*models.Field{{Name: "name", Type: models.FieldTypeString}},
+	}
+	require.NoError(t, store.CreateSchema(ctx, schema))
+	require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+		Project: "app", Table: "events", Level: "info", Message: "m", Timestamp: time.Now(),
+		Fields: map[string]interface{}{"name": "kept"},
+	}))
+
+	require.NoError(t, store.DeleteSchemaRecord(ctx, "app", "events"))
+	_, err := store.GetSchema(ctx, "app", "events")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+	assert.ErrorIs(t, store.DeleteSchemaRecord(ctx, "app", "events"), models.ErrSchemaNotFound)
+
+	require.NoError(t, store.CreateSchema(ctx, schema))
+	rows, err := store.QueryLogs(ctx, "app", "events", nil, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "kept", rows[0]["name"])
+}
+
+func TestSQLitePerProjectMergeAggregates(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewSQLiteStorage(Config{
+		Type: "sqlite",
+		SQLite: SQLiteConfig{
+			Path:       filepath.Join(dir, "meta.db"),
+			PerProject: true,
+			MaxSize:    1,
+		},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	agg := &models.Aggregate{
+		Name:     "hourly",
+		Interval: "1h",
+		GroupBy:  []string{"name"},
+		Metrics: []*models.AggregateMetric{
+			{Func: models.AggregateSum, Field: "size"},
+			{Func: models.AggregateMin, Field: "size"},
+			{Func: models.AggregateMax, Field: "size"},
+			{Func: models.AggregateAvg, Field: "size"},
+			{Func: models.AggregateP50, Field: "size"},
+		},
+	}
+	schema := &models.Schema{
+		Project: "app",
+		Table:   "events",
+		Fields: []*models.Field{
+			{Name: "name", Type: models.FieldTypeString},
+			{Name: "size", Type: models.FieldTypeInt},
+		},
+		SchemaOptions: models.SchemaOptions{Aggregates: []*models.Aggregate{agg}},
+	}
+	require.NoError(t, schema.Validate())
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	bucket := time.Date(2024, 3, 14, 10, 0, 0, 0, time.UTC)
+	insert := func(name string, sizes ...int) {
+		for i, size := range sizes {
+			require.NoError(t, store.InsertLog(ctx, "app", "events", &models.LogEntry{
+				Project: "app", Table: "events", Level: "info", Message: "event",
+				Timestamp: bucket.Add(time.Duration(i) * time.Minute),
+				Fields:    map[string]interface{}{"name": name, "size": size},
+			}))
+		}
+	}
+
+	// 两个归档文件中有同一时间桶、同一分组的聚合行
+	insert("upload", 10, 20, 30)
+	require.NoError(t, store.Compact(ctx))
+	insert("upload", 5, 100)
+	insert("download", 7)
+	// 滚动后新建的表只存在于较新的归档文件中
+	jobs := &models.Schema{Project: "app", Table: "jobs", Fields: schema.Fields,
+		SchemaOptions: models.SchemaOptions{Aggregates: []*models.Aggregate{agg}}}
+	require.NoError(t, store.CreateSchema(ctx, jobs))
+	require.NoError(t, store.InsertLog(ctx, "app", "jobs", &models.LogEntry{
+		Project: "app", Table: "jobs", Level: "info", Message: "job", Timestamp: bucket,
+		Fields: map[string]interface{}{"name": "cleanup", "size": 1},
+	}))
+	require.NoError(t, store.Compact(ctx))
+	segments, err := store.segments("app")
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+
+	// 查询只读取当前文件，滚动后的日志与聚合结果不再可见
+	count, err := store.CountLogs(ctx, "app", "events", nil)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	current, err := store.QueryAggregate(ctx, "app", "events", "hourly", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, current)
+
+	store.config.SQLite.MaxSize = 1 << 30
+	require.NoError(t, store.Compact(ctx))
+	segments, err = store.segments("app")
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	db, err := sql.Open("sqlite3", segments[0])
+	require.NoError(t, err)
+	defer db.Close()
+	cq := newContinuousQueries(db, "sqlite")
+	rows, err := cq.query(ctx, db, schema, "hourly", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	byName := map[interface{}]map[string]interface{}{}
+	for _, row := range rows {
+		byName[row["name"]] = row
+	}
+
+	upload := byName["upload"]
+	require.NotNil(t, upload)
+	assert.EqualValues(t, 5, upload["count"], "counts from both segments are added")
+	assert.EqualValues(t, 165, upload["sum_size"])
+	assert.EqualValues(t, 5, upload["min_size"])
+	assert.EqualValues(t, 100, upload["max_size"])
+	assert.InDelta(t, 33, upload["avg_size"], 1e-9)
+	assert.InEpsilon(t, 20, upload["p50_size"], 0.02, "quantile sketches are merged")
+
+	download := byName["download"]
+	require.NotNil(t, download)
+	assert.EqualValues(t, 1, download["count"])
+	assert.EqualValues(t, 7, download["min_size"])
+
+	// 复制到较旧文件中的表保留主键与索引，之后的合并仍按时间桶累加
+	keys, err := columnValues(ctx, db, `SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk`, aggregateTableName("app", "jobs", "hourly"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bucket", "name"}, keys)
+	keys, err = columnValues(ctx, db, `SELECT name FROM pragma_table_info(?) WHERE pk > 0`, logTableName("app", "jobs"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id"}, keys)
+	rows, err = cq.query(ctx, db, jobs, "hourly", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.EqualValues(t, 1, rows[0]["count"])
+}
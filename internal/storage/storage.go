@@ -2,9 +2,14 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
 )
 
 // Storage 定义存储接口
@@ -28,6 +33,51 @@ type Storage interface {
 	Ping(ctx context.Context) error
 }
 
+// LogQuerier 查询日志的可选能力，query 的键为列名
+type LogQuerier interface {
+	// QueryLogs 按等值条件查询，返回全部列；只需部分列时使用 SearchLogs 的 Fields
+	QueryLogs(ctx context.Context, project, table string, query map[string]interface{}, limit, offset int) ([]map[string]interface{}, error)
+	// SearchLogs 执行带字段选择与排序的查询，列名需事先通过 Query.Validate 校验
+	SearchLogs(ctx context.Context, project, table string, query *models.Query) ([]map[string]interface{}, error)
+}
+
+// BaseColumnLister 日志表中除 models.BaseColumns 外还有其他基础列的可选能力
+type BaseColumnLister interface {
+	// BaseColumns 返回 schema 对应日志表中除 schema 字段外可查询的基础列
+	BaseColumns(schema *models.Schema) []string
+}
+
+// BaseColumns 返回 store 中 schema 对应日志表的基础列，用于 Query.Validate；
+// 未实现 BaseColumnLister 的存储返回 models.BaseColumns
+func BaseColumns(store Storage, schema *models.Schema) []string {
+	if lister, ok := As[BaseColumnLister](store); ok {
+		return lister.BaseColumns(schema)
+	}
+	return models.BaseColumns
+}
+
+// SchemaRecordDeleter 只删除 schema 记录、保留日志表与数据的可选能力，
+// 重新创建同名 schema 后已有的日志仍可查询
+type SchemaRecordDeleter interface {
+	DeleteSchemaRecord(ctx context.Context, project, table string) error
+}
+
+// As 返回 store 的可选能力 T。包装器（如 WithRetry 返回的存储）总是实现所有可选能力，
+// 只有被包装的存储同样具备该能力时才返回 true
+func As[T any](store Storage) (T, bool) {
+	var zero T
+	if wrapper, ok := store.(interface{ Unwrap() Storage }); ok {
+		if _, ok := As[T](wrapper.Unwrap()); !ok {
+			return zero, false
+		}
+	}
+	capability, ok := store.(T)
+	if !ok {
+		return zero, false
+	}
+	return capability, true
+}
+
 // Config 存储配置
 type Config struct {
 	Type       string           `yaml:"type"`
@@ -35,7 +85,18 @@ type Config struct {
 	MySQL      MySQLConfig      `yaml:"mysql,omitempty"`
 	SQLite     SQLiteConfig     `yaml:"sqlite,omitempty"`
 	ClickHouse ClickHouseConfig `yaml:"clickhouse,omitempty"`
+	File       FileConfig       `yaml:"file,omitempty"`
 	Logger     *zap.Logger      `yaml:"logger,omitempty"`
+	Clock      clock.Clock      `yaml:"-"` // 后台维护使用的时间源，默认系统时间
+
+	// IDStrategy 日志 ID 生成策略：ulid（默认）、uuidv7、snowflake
+	IDStrategy string `yaml:"id_strategy,omitempty"`
+	// NodeID snowflake 策略的节点编号（0-1023），多实例部署时需各不相同
+	NodeID int64 `yaml:"node_id,omitempty"`
+	// Timeouts 各类存储操作的超时时间
+	Timeouts TimeoutConfig `yaml:"timeouts,omitempty"`
+	// Options 通过 Register 注册的自定义后端使用的配置，对应配置文件中的 storage.<type> 节点
+	Options map[string]interface{} `yaml:"options,omitempty"`
 }
 
 // PostgresConfig PostgreSQL 配置
@@ -46,6 +107,26 @@ type PostgresConfig struct {
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
 	Schema   string `yaml:"schema"`
+
+	// Timescale 将日志表创建为 TimescaleDB hypertable，扩展不可用时回退为普通表
+	Timescale TimescaleConfig `yaml:"timescale,omitempty"`
+
+	// Replicas 只读副本连接串，如 host=replica1 port=5432 user=... dbname=... sslmode=disable
+	Replicas []string `yaml:"replicas,omitempty"`
+	// ReplicaCheckInterval 副本健康检查间隔，默认 10s
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval,omitempty"`
+
+	// Retry 瞬时错误重试与熔断
+	Retry RetryConfig `yaml:"retry,omitempty"`
+}
+
+// TimescaleConfig TimescaleDB 配置
+type TimescaleConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// ChunkInterval 每个 chunk 覆盖的时间范围，默认 24h
+	ChunkInterval time.Duration `yaml:"chunk_interval,omitempty"`
+	// CompressAfter 超过该时间的 chunk 自动压缩，0 表示不压缩
+	CompressAfter time.Duration `yaml:"compress_after,omitempty"`
 }
 
 // MySQLConfig MySQL 配置
@@ -55,11 +136,31 @@ type MySQLConfig struct {
 	Database string `yaml:"database"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+
+	// Replicas 只读副本 DSN，如 user:pass@tcp(replica1:3306)/logs?parseTime=true
+	Replicas []string `yaml:"replicas,omitempty"`
+	// ReplicaCheckInterval 副本健康检查间隔，默认 10s
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval,omitempty"`
+
+	// Retry 瞬时错误重试与熔断
+	Retry RetryConfig `yaml:"retry,omitempty"`
 }
 
 // SQLiteConfig SQLite 配置
 type SQLiteConfig struct {
 	Path string `yaml:"path"`
+
+	// PerProject 为每个项目使用独立的数据库文件，Path 只保存 schema 等元数据
+	PerProject bool `yaml:"per_project,omitempty"`
+	// Dir 项目数据库文件目录，默认为 Path 所在目录下的 projects
+	Dir string `yaml:"dir,omitempty"`
+	// MaintenanceInterval 定期 VACUUM、滚动与合并的间隔，0 表示不启用
+	MaintenanceInterval time.Duration `yaml:"maintenance_interval,omitempty"`
+	// MaxSize 项目文件超过该大小（字节）时滚动为归档文件，相邻小归档合并后不超过该大小，0 表示不滚动
+	MaxSize int64 `yaml:"max_size,omitempty"`
+
+	// Retry 瞬时错误重试与熔断
+	Retry RetryConfig `yaml:"retry,omitempty"`
 }
 
 // ClickHouseConfig ClickHouse 配置
@@ -69,4 +170,180 @@ type ClickHouseConfig struct {
 	Database string `yaml:"database"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+
+	// AsyncInsert 开启服务端异步写入（async_insert），小批量写入由服务端合并后落盘
+	AsyncInsert bool `yaml:"async_insert,omitempty"`
+	// WaitForAsyncInsert 异步写入时是否等待数据落盘后再返回，未设置时等待；
+	// 关闭后写入延迟更低，但落盘失败不会返回给调用方
+	WaitForAsyncInsert *bool `yaml:"wait_for_async_insert,omitempty"`
+	// InsertQuorum 复制表写入成功所需的副本确认数，0 表示不启用
+	InsertQuorum int `yaml:"insert_quorum,omitempty"`
+	// Cluster 集群名称，设置后 DDL 使用 ON CLUSTER 执行，日志表由各分片上的
+	// ReplicatedMergeTree 本地表（<table>_local）与 Distributed 表组成
+	Cluster string `yaml:"cluster,omitempty"`
+
+	// Retry 瞬时错误重试与熔断
+	Retry RetryConfig `yaml:"retry,omitempty"`
+}
+
+// FileConfig 文件存储配置
+type FileConfig struct {
+	Dir string `yaml:"dir"`
+	// SegmentSize 段文件大小上限（字节），写满后切换到新段，默认 64MB
+	SegmentSize int64 `yaml:"segment_size,omitempty"`
+	// Sync 每批写入后调用 fsync，断电时不丢失已确认的日志，但写入更慢
+	Sync bool `yaml:"sync,omitempty"`
+
+	// Retry 瞬时错误重试与熔断
+	Retry RetryConfig `yaml:"retry,omitempty"`
+}
+
+// newIDGenerator 根据配置创建日志 ID 生成器
+func newIDGenerator(config Config) (models.IDGenerator, error) {
+	strategy, err := models.ParseIDStrategy(config.IDStrategy)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewIDGenerator(strategy, config.NodeID)
+}
+
+// assignIDs 为未设置 ID 的日志生成 ID，客户端提供的 ID 保持不变
+func assignIDs(ids models.IDGenerator, logs []*models.LogEntry) {
+	for _, log := range logs {
+		if log.ID == "" {
+			log.ID = ids.NewID()
+		}
+	}
+}
+
+// tagsValue 将标签序列化为 JSON 文本，没有标签时写入 NULL
+func tagsValue(tags map[string]string) (interface{}, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("序列化标签失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// entryField 返回日志中 schema 字段的值。level 与 message 不在日志字段中，
+// schema 声明了同名字段时写入日志的基本字段
+func entryField(log *models.LogEntry, name string) (interface{}, bool) {
+	if value, ok := log.Fields[name]; ok {
+		return value, true
+	}
+	switch name {
+	case "level":
+		return log.Level, log.Level != ""
+	case "message":
+		return log.Message, log.Message != ""
+	}
+	return nil, false
+}
+
+// fieldColumns 返回写入 schema 字段的列，顺序与 schema 声明一致。同一批日志共用该列表，
+// 避免按每行实际存在的字段拼接出列数与顺序不同的插入语句
+func fieldColumns(schema *models.Schema) []string {
+	columns := make([]string, len(schema.Fields))
+	for i, field := range schema.Fields {
+		columns[i] = field.Name
+	}
+	return columns
+}
+
+// fieldValues 按 fieldColumns 的顺序返回日志的字段值，日志中缺失的字段写入 NULL。
+// 字段默认值已由 Schema.ValidateLogEntry 填充
+func fieldValues(dialect string, schema *models.Schema, log *models.LogEntry) ([]interface{}, error) {
+	values := make([]interface{}, len(schema.Fields))
+	for i, field := range schema.Fields {
+		value, _ := entryField(log, field.Name)
+		encoded, err := encodeFieldValue(dialect, field, value)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = encoded
+	}
+	return values, nil
+}
+
+// maxTimestampDigits 各后端 timestamp 列支持的秒的最大小数位数，SQLite 以文本保存纳秒
+var maxTimestampDigits = map[string]int{"sqlite": 9, "mysql": 6, "postgres": 6, "clickhouse": 9}
+
+// timestampDigits 返回 schema 的 timestamp 列在 dialect 上保存的秒的小数位数
+func timestampDigits(dialect string, schema *models.Schema) int {
+	return schema.TimestampDigits(maxTimestampDigits[dialect])
+}
+
+// timestampValue 返回以 UTC 保存的日志时间，未设置时间时写入 NULL
+func timestampValue(ts time.Time) interface{} {
+	if ts.IsZero() {
+		return nil
+	}
+	return ts.UTC()
+}
+
+// marshalSchemaOptions 序列化 schema 的表级别配置
+func marshalSchemaOptions(schema *models.Schema) (string, error) {
+	data, err := json.Marshal(schema.SchemaOptions)
+	if err != nil {
+		return "", fmt.Errorf("序列化 schema 配置失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// unmarshalSchemaOptions 解析 schema 的表级别配置，兼容旧数据中的空值
+func unmarshalSchemaOptions(data []byte, schema *models.Schema) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, &schema.SchemaOptions); err != nil {
+		return fmt.Errorf("解析 schema 配置失败: %w", err)
+	}
+	return nil
+}
+
+// execer 可执行语句的连接或事务
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// deleteSchemaRecord 删除 schemas 表中的记录，不存在时返回 ErrSchemaNotFound
+func deleteSchemaRecord(ctx context.Context, db execer, dialect, project, table string) error {
+	query := `DELETE FROM schemas WHERE project = ? AND table_name = ?`
+	if dialect == "postgres" {
+		query = `DELETE FROM schemas WHERE project = $1 AND table_name = $2`
+	}
+	result, err := db.ExecContext(ctx, query, project, table)
+	if err != nil {
+		return fmt.Errorf("删除 schema 失败: %w", unavailable(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %s_%s", models.ErrSchemaNotFound, project, table)
+	}
+	return nil
+}
+
+// queryNames 执行返回单列名称的查询，结果以集合形式返回
+func queryNames(ctx context.Context, db *sql.DB, query string, args ...interface{}) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
 }
@@ -0,0 +1,32 @@
+package storagetest
+
+import "time"
+
+// Container 通过 testcontainers-go 启动的一次性数据库容器
+type Container struct {
+	Image string            // 镜像，如 postgres:16
+	Port  int               // 容器内的服务端口
+	Env   map[string]string // 环境变量
+}
+
+// Containers 内置后端的默认容器，用户名、密码与数据库名与 docker-compose.bench.yml 一致
+var Containers = map[string]Container{
+	"postgres": {Image: "postgres:16", Port: 5432,
+		Env: map[string]string{"POSTGRES_PASSWORD": "postgres", "POSTGRES_DB": "logs_test"}},
+	"mysql": {Image: "mysql:8.4", Port: 3306,
+		Env: map[string]string{"MYSQL_ROOT_PASSWORD": "root", "MYSQL_DATABASE": "logs_test"}},
+	"clickhouse": {Image: "clickhouse/clickhouse-server:24.8", Port: 9000,
+		Env: map[string]string{"CLICKHOUSE_DB": "logs_test", "CLICKHOUSE_DEFAULT_ACCESS_MANAGEMENT": "1"}},
+}
+
+// Retry 每秒调用一次 fn 直到成功或超过 timeout，用于等待容器中的数据库完成启动
+func Retry(timeout time.Duration, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := fn()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
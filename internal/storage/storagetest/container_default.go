@@ -0,0 +1,12 @@
+//go:build !testcontainers
+
+package storagetest
+
+import "testing"
+
+// Start 未启用 testcontainers 构建标签时无法启动容器，测试失败并提示启用方式
+func (c Container) Start(t *testing.T) (string, int) {
+	t.Helper()
+	t.Fatalf("starting %s requires building the tests with -tags testcontainers", c.Image)
+	return "", 0
+}
@@ -0,0 +1,47 @@
+//go:build testcontainers
+
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Start 以随机的主机端口启动容器并等待端口可以连接，测试结束时删除容器。
+// 返回映射到主机的地址与端口；端口可连接不代表数据库已就绪，调用方应重试初始化
+func (c Container) Start(t *testing.T) (string, int) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	port := fmt.Sprintf("%d/tcp", c.Port)
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        c.Image,
+			ExposedPorts: []string{port},
+			Env:          c.Env,
+			WaitingFor:   wait.ForListeningPort(port).WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+	// 启动失败时 container 可能非空，同样需要清理
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("start %s: %v", c.Image, err)
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("inspect %s: %v", c.Image, err)
+	}
+	mapped, err := container.MappedPort(ctx, port)
+	if err != nil {
+		t.Fatalf("inspect %s: %v", c.Image, err)
+	}
+	return host, mapped.Int()
+}
@@ -0,0 +1,583 @@
+// Package storagetest 提供所有 storage.Storage 实现都应通过的一致性测试，内置后端与通过 storage.Register
+// 注册的自定义后端使用同一套用例，保证 API 在不同后端上的行为一致
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// Project 一致性测试使用的项目，每个用例开始前删除其中同名的表
+const Project = "conformance"
+
+// Factory 为一个用例返回已初始化的存储，可以每次创建新的实例，也可以复用同一个实例；
+// 需要关闭的存储应通过 t.Cleanup 注册
+type Factory func(t *testing.T) storage.Storage
+
+// RunConformanceTests 执行一致性测试，每个用例通过 factory 获取存储。用例覆盖 schema 的增删改查、写入、查询、
+// 校验、Rest 字段与并发写入；可选能力（如 LogQuerier、LogMutator）未实现时跳过对应用例
+func RunConformanceTests(t *testing.T, factory Factory) {
+	for _, c := range []struct {
+		name string
+		fn   func(*testing.T, storage.Storage)
+	}{
+		{"Schemas", testSchemas},
+		{"InsertAndSearch", testInsertAndSearch},
+		{"FieldTypes", testFieldTypes},
+		{"Tags", testTags},
+		{"BatchValidation", testBatchValidation},
+		{"UpdateSchema", testUpdateSchema},
+		{"RestFields", testRestFields},
+		{"Concurrency", testConcurrency},
+		{"CountMatching", testCountMatching},
+		{"TableSizes", testTableSizes},
+		{"ExplainQuery", testExplainQuery},
+		{"FieldValues", testFieldValues},
+	} {
+		t.Run(c.name, func(t *testing.T) { c.fn(t, factory(t)) })
+	}
+}
+
+// Run 对已初始化的 store 执行一致性测试，所有用例共用 store
+func Run(t *testing.T, store storage.Storage) {
+	RunConformanceTests(t, func(*testing.T) storage.Storage { return store })
+}
+
+// base 测试日志的起始时间，精确到毫秒以兼容精度最低的后端
+var base = time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC)
+
+// createSchema 删除可能遗留的同名表后创建 schema，测试结束时删除
+func createSchema(t *testing.T, store storage.Storage, table string, fields ...*models.Field) *models.Schema {
+	t.Helper()
+	ctx := context.Background()
+	schema := &models.Schema{Project: Project, Table: table, Fields: fields}
+	require.NoError(t, schema.Validate())
+	store.DeleteSchema(ctx, Project, table)
+	require.NoError(t, store.CreateSchema(ctx, schema))
+	t.Cleanup(func() { store.DeleteSchema(context.Background(), Project, table) })
+	return schema
+}
+
+// entry 创建 schema 下的一条日志
+func entry(table string, offset time.Duration, fields map[string]interface{}) *models.LogEntry {
+	return &models.LogEntry{
+		Project: Project, Table: table, Level: "info", Message: "conformance",
+		Timestamp: base.Add(offset), Fields: fields,
+	}
+}
+
+// querier 返回 store 的查询能力，不支持时跳过用例
+func querier(t *testing.T, store storage.Storage) storage.LogQuerier {
+	t.Helper()
+	q, ok := storage.As[storage.LogQuerier](store)
+	if !ok {
+		t.Skip("storage does not implement LogQuerier")
+	}
+	return q
+}
+
+// search 执行查询
+func search(t *testing.T, store storage.Storage, table string, query *models.Query) []map[string]interface{} {
+	t.Helper()
+	rows, err := querier(t, store).SearchLogs(context.Background(), Project, table, query)
+	require.NoError(t, err)
+	return rows
+}
+
+func testSchemas(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	schema := createSchema(t, store, "schemas",
+		&models.Field{Name: "user_id", Type: models.FieldTypeString, Required: true, Indexed: true},
+		&models.Field{Name: "status", Type: models.FieldTypeInt},
+	)
+
+	got, err := store.GetSchema(ctx, Project, schema.Table)
+	require.NoError(t, err)
+	assert.Equal(t, Project, got.Project)
+	assert.Equal(t, schema.Table, got.Table)
+	require.Len(t, got.Fields, 2)
+	assert.Equal(t, "user_id", got.Fields[0].Name)
+	assert.Equal(t, models.FieldTypeString, got.Fields[0].Type)
+	assert.True(t, got.Fields[0].Required)
+	assert.Equal(t, models.FieldTypeInt, got.Fields[1].Type)
+
+	schemas, err := store.ListSchemas(ctx)
+	require.NoError(t, err)
+	found := false
+	for _, s := range schemas {
+		found = found || (s.Project == Project && s.Table == schema.Table)
+	}
+	assert.True(t, found, "ListSchemas includes the created schema")
+
+	_, err = store.GetSchema(ctx, Project, "missing")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+
+	require.NoError(t, store.DeleteSchema(ctx, Project, schema.Table))
+	_, err = store.GetSchema(ctx, Project, schema.Table)
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+	assert.ErrorIs(t, store.DeleteSchema(ctx, Project, schema.Table), models.ErrSchemaNotFound)
+}
+
+func testInsertAndSearch(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	schema := createSchema(t, store, "search",
+		&models.Field{Name: "user_id", Type: models.FieldTypeString},
+		&models.Field{Name: "status", Type: models.FieldTypeInt},
+	)
+
+	first := entry(schema.Table, 0, map[string]interface{}{"user_id": "u0", "status": 200})
+	first.ID = "conformance-first"
+	require.NoError(t, store.InsertLog(ctx, Project, schema.Table, first))
+	var batch []*models.LogEntry
+	for i := 1; i < 10; i++ {
+		batch = append(batch, entry(schema.Table, time.Duration(i)*time.Second,
+			map[string]interface{}{"user_id": fmt.Sprintf("u%d", i%3), "status": 200 + i}))
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, Project, schema.Table, batch))
+	require.NoError(t, store.BatchInsertLogs(ctx, Project, schema.Table, nil), "empty batches are a no-op")
+
+	rows := search(t, store, schema.Table, &models.Query{Sort: []string{"timestamp"}, Limit: 100})
+	require.Len(t, rows, 10)
+	assert.Equal(t, "conformance-first", rows[0]["id"], "client-provided IDs are kept")
+	ids := make(map[interface{}]bool)
+	for i, row := range rows {
+		assert.NotEmpty(t, row["id"])
+		ids[row["id"]] = true
+		assert.True(t, base.Add(time.Duration(i)*time.Second).Equal(asTime(t, row["timestamp"])), "row %d timestamp", i)
+		assert.Equal(t, int64(200+i), asInt(t, row["status"]))
+	}
+	assert.Len(t, ids, 10, "IDs are unique")
+
+	rows = search(t, store, schema.Table, &models.Query{Sort: []string{"-timestamp"}, Limit: 3, Offset: 2})
+	require.Len(t, rows, 3)
+	assert.Equal(t, int64(207), asInt(t, rows[0]["status"]), "descending sort with offset")
+
+	rows = search(t, store, schema.Table, &models.Query{Limit: 2})
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(209), asInt(t, rows[0]["status"]), "newest first without sort")
+	assert.Equal(t, int64(208), asInt(t, rows[1]["status"]))
+
+	rows = search(t, store, schema.Table, &models.Query{Sort: []string{"user_id desc", "status asc"}, Limit: 4})
+	require.Len(t, rows, 4)
+	for i, status := range []int64{202, 205, 208, 201} {
+		assert.Equal(t, status, asInt(t, rows[i]["status"]), "sort directions row %d", i)
+	}
+
+	rows = search(t, store, schema.Table, &models.Query{Filter: map[string]interface{}{"user_id": "u1"}, Sort: []string{"timestamp"}})
+	require.Len(t, rows, 3)
+	for _, row := range rows {
+		assert.Equal(t, "u1", row["user_id"])
+	}
+
+	from, to := base.Add(2*time.Second), base.Add(5*time.Second)
+	rows = search(t, store, schema.Table, &models.Query{From: &from, To: &to, Sort: []string{"timestamp"}})
+	require.Len(t, rows, 3, "from is inclusive and to is exclusive")
+	assert.Equal(t, int64(202), asInt(t, rows[0]["status"]))
+
+	rows = search(t, store, schema.Table, &models.Query{Fields: []string{"status"}, Filter: map[string]interface{}{"status": 209}})
+	require.Len(t, rows, 1)
+	assert.Equal(t, int64(209), asInt(t, rows[0]["status"]))
+	assert.NotContains(t, rows[0], "user_id", "only selected fields are returned")
+}
+
+func testFieldTypes(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	schema := createSchema(t, store, "types",
+		&models.Field{Name: "s", Type: models.FieldTypeString},
+		&models.Field{Name: "i", Type: models.FieldTypeInt},
+		&models.Field{Name: "f", Type: models.FieldTypeFloat},
+		&models.Field{Name: "b", Type: models.FieldTypeBool},
+		&models.Field{Name: "dt", Type: models.FieldTypeDateTime},
+		&models.Field{Name: "d", Type: models.FieldTypeDuration},
+		&models.Field{Name: "j", Type: models.FieldTypeJSON},
+	)
+	seen := base.Add(-time.Hour)
+	require.NoError(t, store.InsertLog(ctx, Project, schema.Table, entry(schema.Table, 0, map[string]interface{}{
+		"s": "text", "i": int64(1) << 40, "f": 2.5, "b": true, "dt": seen, "d": 1500 * time.Millisecond,
+		"j": map[string]interface{}{"k": "v", "n": 1},
+	})))
+	require.NoError(t, store.InsertLog(ctx, Project, schema.Table, entry(schema.Table, time.Second, map[string]interface{}{
+		"s": "other", "b": false,
+	})))
+
+	rows := search(t, store, schema.Table, &models.Query{Sort: []string{"timestamp"}})
+	require.Len(t, rows, 2)
+	row := rows[0]
+	assert.Equal(t, "text", row["s"])
+	assert.Equal(t, int64(1)<<40, asInt(t, row["i"]))
+	assert.InDelta(t, 2.5, asFloat(t, row["f"]), 1e-9)
+	assert.True(t, asBool(t, row["b"]))
+	assert.True(t, seen.Equal(asTime(t, row["dt"])), "datetime %v", row["dt"])
+	assert.Equal(t, int64(1500*time.Millisecond), asInt(t, row["d"]), "durations are stored as nanoseconds")
+	assert.Equal(t, map[string]interface{}{"k": "v", "n": float64(1)}, asJSON(t, row["j"]))
+	assert.False(t, asBool(t, rows[1]["b"]))
+}
+
+func testTags(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	schema := createSchema(t, store, "tags", &models.Field{Name: "n", Type: models.FieldTypeInt})
+	for i, region := range []string{"eu", "us", "eu"} {
+		log := entry(schema.Table, time.Duration(i)*time.Second, map[string]interface{}{"n": i})
+		log.Tags = map[string]string{"region": region, "env": "test"}
+		require.NoError(t, store.InsertLog(ctx, Project, schema.Table, log))
+	}
+
+	rows := search(t, store, schema.Table, &models.Query{Tags: map[string]string{"region": "eu"}, Sort: []string{"timestamp"}})
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(2), asInt(t, rows[1]["n"]))
+	assert.Equal(t, map[string]string{"region": "eu", "env": "test"}, asTags(t, rows[0][models.TagsColumn]))
+}
+
+func testBatchValidation(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	schema := createSchema(t, store, "validation", &models.Field{Name: "status", Type: models.FieldTypeInt, Required: true})
+
+	invalid := entry(schema.Table, time.Second, map[string]interface{}{"status": "not a number"})
+	err := store.BatchInsertLogs(ctx, Project, schema.Table, []*models.LogEntry{
+		entry(schema.Table, 0, map[string]interface{}{"status": 200}), invalid,
+	})
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	missing := entry(schema.Table, 0, nil)
+	assert.ErrorIs(t, store.InsertLog(ctx, Project, schema.Table, missing), models.ErrValidation)
+
+	assert.ErrorIs(t, store.InsertLog(ctx, Project, "missing", entry("missing", 0, nil)), models.ErrSchemaNotFound)
+
+	rows := search(t, store, schema.Table, &models.Query{})
+	assert.Empty(t, rows, "a batch with an invalid entry writes nothing")
+}
+
+func testUpdateSchema(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	schema := createSchema(t, store, "evolve", &models.Field{Name: "status", Type: models.FieldTypeInt})
+	require.NoError(t, store.InsertLog(ctx, Project, schema.Table, entry(schema.Table, 0, map[string]interface{}{"status": 200})))
+
+	schema.Fields = append(schema.Fields, &models.Field{Name: "path", Type: models.FieldTypeString})
+	require.NoError(t, store.UpdateSchema(ctx, schema))
+	got, err := store.GetSchema(ctx, Project, schema.Table)
+	require.NoError(t, err)
+	require.Len(t, got.Fields, 2)
+	assert.Equal(t, "path", got.Fields[1].Name)
+
+	require.NoError(t, store.InsertLog(ctx, Project, schema.Table, entry(schema.Table, time.Second,
+		map[string]interface{}{"status": 201, "path": "/new"})))
+	rows := search(t, store, schema.Table, &models.Query{Sort: []string{"timestamp"}})
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(200), asInt(t, rows[0]["status"]), "existing rows are kept")
+	assert.Equal(t, "/new", rows[1]["path"])
+}
+
+func testRestFields(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	schema := createSchema(t, store, "rest",
+		&models.Field{Name: "path", Type: models.FieldTypeString},
+		&models.Field{Name: "extra", Type: models.FieldTypeRest},
+	)
+	require.NoError(t, store.InsertLog(ctx, Project, schema.Table, entry(schema.Table, 0,
+		map[string]interface{}{"path": "/a", "user": "u1", "attempt": 2})))
+	require.NoError(t, store.InsertLog(ctx, Project, schema.Table, entry(schema.Table, time.Second,
+		map[string]interface{}{"path": "/b"})))
+
+	rows := search(t, store, schema.Table, &models.Query{Sort: []string{"timestamp"}})
+	require.Len(t, rows, 2)
+	assert.Equal(t, "/a", rows[0]["path"])
+	assert.Equal(t, map[string]interface{}{"user": "u1", "attempt": float64(2)}, asJSON(t, rows[0]["extra"]),
+		"undefined fields are collected into the rest field")
+	assert.NotContains(t, rows[0], "user")
+	assert.Equal(t, "/b", rows[1]["path"])
+	assert.Empty(t, rows[1]["extra"], "the rest field is empty when every field is defined")
+}
+
+func testConcurrency(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	schema := createSchema(t, store, "concurrency", &models.Field{Name: "worker", Type: models.FieldTypeInt})
+
+	const workers, perWorker = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*3)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			offset := time.Duration(w*perWorker) * time.Millisecond
+			var batch []*models.LogEntry
+			for i := 0; i < perWorker; i++ {
+				log := entry(schema.Table, offset+time.Duration(i)*time.Millisecond, map[string]interface{}{"worker": w})
+				if i%2 == 0 {
+					batch = append(batch, log)
+				} else if err := store.InsertLog(ctx, Project, schema.Table, log); err != nil {
+					errs <- err
+				}
+			}
+			if err := store.BatchInsertLogs(ctx, Project, schema.Table, batch); err != nil {
+				errs <- err
+			}
+			if _, err := store.GetSchema(ctx, Project, schema.Table); err != nil {
+				errs <- err
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	rows := search(t, store, schema.Table, &models.Query{Limit: workers * perWorker * 2})
+	require.Len(t, rows, workers*perWorker, "every concurrent write is stored exactly once")
+	ids := make(map[interface{}]bool)
+	perWorkerRows := make(map[int64]int)
+	for _, row := range rows {
+		ids[row["id"]] = true
+		perWorkerRows[asInt(t, row["worker"])]++
+	}
+	assert.Len(t, ids, workers*perWorker, "IDs are unique")
+	for w := 0; w < workers; w++ {
+		assert.Equal(t, perWorker, perWorkerRows[int64(w)], "worker %d", w)
+	}
+}
+
+func testCountMatching(t *testing.T, store storage.Storage) {
+	counter, ok := storage.As[storage.LogMutator](store)
+	if !ok {
+		t.Skip("storage does not implement LogMutator")
+	}
+	ctx := context.Background()
+	schema := createSchema(t, store, "counts", &models.Field{Name: "status", Type: models.FieldTypeInt})
+	var batch []*models.LogEntry
+	for i := 0; i < 5; i++ {
+		batch = append(batch, entry(schema.Table, time.Duration(i)*time.Second, map[string]interface{}{"status": 200 + i%2}))
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, Project, schema.Table, batch))
+
+	n, err := counter.CountMatching(ctx, Project, schema.Table, &models.LogFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	n, err = counter.CountMatching(ctx, Project, schema.Table, &models.LogFilter{Filter: map[string]interface{}{"status": 201}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
+
+func testTableSizes(t *testing.T, store storage.Storage) {
+	reporter, ok := storage.As[storage.SizeReporter](store)
+	if !ok {
+		t.Skip("storage does not implement SizeReporter")
+	}
+	ctx := context.Background()
+	schema := createSchema(t, store, "sizes", &models.Field{Name: "path", Type: models.FieldTypeString})
+	var batch []*models.LogEntry
+	for i := 0; i < 10; i++ {
+		batch = append(batch, entry(schema.Table, time.Duration(i)*time.Second, map[string]interface{}{"path": fmt.Sprintf("/api/items/%d", i)}))
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, Project, schema.Table, batch))
+
+	sizes, err := reporter.TableSizes(ctx)
+	require.NoError(t, err)
+	var size *models.TableSize
+	for _, s := range sizes {
+		if s.Project == Project && s.Table == schema.Table {
+			size = s
+		}
+	}
+	require.NotNil(t, size, "table is reported")
+	assert.NotEmpty(t, size.Source)
+	// 数据库统计信息可能尚未更新，只检查数值的一致性
+	assert.GreaterOrEqual(t, size.Rows, int64(0))
+	assert.GreaterOrEqual(t, size.DataBytes, int64(0))
+	assert.GreaterOrEqual(t, size.TotalBytes, size.DataBytes)
+}
+
+func testExplainQuery(t *testing.T, store storage.Storage) {
+	explainer, ok := storage.As[storage.QueryExplainer](store)
+	if !ok {
+		t.Skip("storage does not implement QueryExplainer")
+	}
+	ctx := context.Background()
+	schema := createSchema(t, store, "explain", &models.Field{Name: "status", Type: models.FieldTypeInt, Indexed: true})
+	require.NoError(t, store.InsertLog(ctx, Project, schema.Table, entry(schema.Table, 0, map[string]interface{}{"status": 200})))
+
+	plan, err := explainer.ExplainQuery(ctx, Project, schema.Table, &models.Query{
+		Filter: map[string]interface{}{"status": 200},
+		Limit:  10,
+	}, false)
+	require.NoError(t, err)
+	assert.Equal(t, Project, plan.Project)
+	assert.NotEmpty(t, plan.Dialect)
+	assert.Contains(t, plan.SQL, "LIMIT 10")
+	assert.Len(t, plan.Args, 1)
+	assert.NotEmpty(t, plan.Plan)
+	assert.False(t, plan.Analyzed)
+}
+
+func testFieldValues(t *testing.T, store storage.Storage) {
+	querier, ok := storage.As[storage.FieldValuesQuerier](store)
+	if !ok {
+		t.Skip("storage does not implement FieldValuesQuerier")
+	}
+	ctx := context.Background()
+	schema := createSchema(t, store, "field_values",
+		&models.Field{Name: "user_id", Type: models.FieldTypeString},
+		&models.Field{Name: "status", Type: models.FieldTypeInt},
+		&models.Field{Name: "client", Type: models.FieldTypeIP},
+	)
+	var batch []*models.LogEntry
+	for i, user := range []string{"u2", "u1", "u3", "u1", "u2", "u1"} {
+		fields := map[string]interface{}{"user_id": user, "client": "10.0.0.1"}
+		if i > 0 {
+			fields["status"] = 200 + 300*(i%2)
+		}
+		batch = append(batch, entry(schema.Table, time.Duration(i)*time.Second, fields))
+	}
+	require.NoError(t, store.BatchInsertLogs(ctx, Project, schema.Table, batch))
+
+	values, err := querier.FieldValues(ctx, Project, schema.Table, "user_id", &models.Query{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "user_id", values.Field)
+	assert.Equal(t, int64(6), values.Total)
+	assert.Equal(t, int64(3), values.Distinct)
+	require.Len(t, values.Values, 2)
+	assert.Equal(t, models.FieldValue{Value: "u1", Count: 3}, values.Values[0])
+	assert.Equal(t, models.FieldValue{Value: "u2", Count: 2}, values.Values[1])
+
+	// 空值不参与统计，时间范围与 search 一致：from 包含，to 不包含
+	from, to := base.Add(time.Second), base.Add(5*time.Second)
+	values, err = querier.FieldValues(ctx, Project, schema.Table, "status", &models.Query{From: &from, To: &to})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), values.Total)
+	require.Len(t, values.Values, 2)
+	assert.Equal(t, int64(200), asInt(t, values.Values[0].Value), "equal counts are ordered by value")
+	assert.Equal(t, int64(2), values.Values[0].Count)
+	assert.Equal(t, int64(500), asInt(t, values.Values[1].Value))
+	assert.Equal(t, int64(2), values.Values[1].Count)
+
+	values, err = querier.FieldValues(ctx, Project, schema.Table, "client", &models.Query{})
+	require.NoError(t, err)
+	require.Len(t, values.Values, 1)
+	assert.Equal(t, models.FieldValue{Value: "10.0.0.1", Count: 6}, values.Values[0])
+
+	later := base.Add(time.Hour)
+	values, err = querier.FieldValues(ctx, Project, schema.Table, "user_id", &models.Query{From: &later})
+	require.NoError(t, err)
+	assert.Zero(t, values.Total)
+	assert.NotNil(t, values.Values)
+	assert.Empty(t, values.Values)
+}
+
+// asInt 将后端返回的整数值统一为 int64
+func asInt(t *testing.T, value interface{}) int64 {
+	t.Helper()
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case json.Number:
+		n, err := v.Int64()
+		require.NoError(t, err)
+		return n
+	case []byte:
+		return asInt(t, string(v))
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		require.NoError(t, err)
+		return n
+	}
+	t.Fatalf("expected an integer, got %T %v", value, value)
+	return 0
+}
+
+// asFloat 将后端返回的浮点值统一为 float64
+func asFloat(t *testing.T, value interface{}) float64 {
+	t.Helper()
+	switch v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case []byte:
+		return asFloat(t, string(v))
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		require.NoError(t, err)
+		return f
+	}
+	return float64(asInt(t, value))
+}
+
+// asBool 布尔值，以 0/1 保存布尔值的后端返回整数
+func asBool(t *testing.T, value interface{}) bool {
+	t.Helper()
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return asInt(t, value) != 0
+}
+
+// asTime 时间值，以文本保存时间的后端返回字符串
+func asTime(t *testing.T, value interface{}) time.Time {
+	t.Helper()
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999"} {
+			if ts, err := time.Parse(layout, v); err == nil {
+				return ts
+			}
+		}
+	}
+	t.Fatalf("expected a time, got %T %v", value, value)
+	return time.Time{}
+}
+
+// asJSON 解析 JSON 值，以文本保存 JSON 的后端返回字符串或字节
+func asJSON(t *testing.T, value interface{}) interface{} {
+	t.Helper()
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		var err error
+		data, err = json.Marshal(v)
+		require.NoError(t, err)
+	}
+	var decoded interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	return decoded
+}
+
+// asTags 将标签列统一为 map[string]string
+func asTags(t *testing.T, value interface{}) map[string]string {
+	t.Helper()
+	if tags, ok := value.(map[string]string); ok {
+		return tags
+	}
+	decoded, ok := asJSON(t, value).(map[string]interface{})
+	require.True(t, ok, "expected a tag map, got %T", value)
+	tags := make(map[string]string, len(decoded))
+	for key, v := range decoded {
+		tags[key] = fmt.Sprint(v)
+	}
+	return tags
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// TimeoutConfig 存储操作的超时时间，与调用方 context 的截止时间取较早者；0 表示只受调用方 context 约束
+type TimeoutConfig struct {
+	// Query 日志查询、计数与聚合读取
+	Query time.Duration `yaml:"query,omitempty"`
+	// Write 日志写入，超时或取消时整批回滚
+	Write time.Duration `yaml:"write,omitempty"`
+	// Schema schema 读写与建表、改表
+	Schema time.Duration `yaml:"schema,omitempty"`
+}
+
+// query 返回日志查询使用的 context
+func (c TimeoutConfig) query(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, c.Query)
+}
+
+// write 返回日志写入使用的 context
+func (c TimeoutConfig) write(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, c.Write)
+}
+
+// schema 返回 schema 操作使用的 context
+func (c TimeoutConfig) schema(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, c.Schema)
+}
+
+// withTimeout 为 context 附加超时，d 不大于 0 时原样返回
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
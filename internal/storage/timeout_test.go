@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestTimeoutConfig(t *testing.T) {
+	ctx, cancel := TimeoutConfig{}.query(context.Background())
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+
+	ctx, cancel = TimeoutConfig{Write: time.Minute}.write(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+
+	// 调用方的截止时间更早时保持不变
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Second)
+	defer cancelParent()
+	ctx, cancel = TimeoutConfig{Schema: time.Hour}.schema(parent)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 500*time.Millisecond)
+}
+
+func TestSQLiteBatchInsertCancelled(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLiteStorage(Config{
+		Type:   "sqlite",
+		SQLite: SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, store.Initialize(ctx))
+	defer store.Close()
+
+	schema := &models.Schema{Project: "app", Table: "requests", Fields: []*models.Field{{Name: "service", Type: models.FieldTypeString}}}
+	require.NoError(t, store.CreateSchema(ctx, schema))
+
+	logs := []*models.LogEntry{
+		{Project: "app", Table: "requests", Timestamp: time.Now(), Fields: map[string]interface{}{"service": "a"}},
+		{Project: "app", Table: "requests", Timestamp: time.Now(), Fields: map[string]interface{}{"service": "b"}},
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err := store.BatchInsertLogs(cancelled, "app", "requests", logs)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	count, err := store.CountLogs(ctx, "app", "requests", nil)
+	require.NoError(t, err)
+	assert.Zero(t, count, "cancelled batch must not be partially written")
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// defaultChunkInterval hypertable 默认 chunk 时间范围
+const defaultChunkInterval = 24 * time.Hour
+
+// enableTimescale 检查并启用 TimescaleDB 扩展，扩展不可用时记录警告并返回 false
+func (s *PostgresStorage) enableTimescale(ctx context.Context) bool {
+	if !s.config.Postgres.Timescale.Enabled {
+		return false
+	}
+	if s.config.Postgres.Timescale.ChunkInterval < 0 || s.config.Postgres.Timescale.CompressAfter < 0 {
+		s.logger.Warn("timescaledb intervals must not be negative, hypertables disabled")
+		return false
+	}
+	if _, err := s.db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS timescaledb`); err != nil {
+		s.logger.Warn("timescaledb extension is not available, log tables are created as plain tables", zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// postgresKeyColumns 返回日志表的内置列定义，timestampType 为 timestamp 列的类型。hypertable 的唯一约束必须包含分区列，
+// 因此启用 TimescaleDB 时主键为 (id, timestamp)
+func postgresKeyColumns(timescale bool, timestampType string) []string {
+	if !timescale {
+		return []string{
+			"id VARCHAR(64) PRIMARY KEY",
+			"project VARCHAR(255)",
+			"table_name VARCHAR(255)",
+			"timestamp " + timestampType,
+		}
+	}
+	return []string{
+		"id VARCHAR(64) NOT NULL",
+		"project VARCHAR(255)",
+		"table_name VARCHAR(255)",
+		"timestamp " + timestampType + " NOT NULL",
+		"PRIMARY KEY (id, timestamp)",
+	}
+}
+
+// postgresTimestampType 返回指定精度的 timestamp 列类型，6 位为 PostgreSQL 的默认精度
+func postgresTimestampType(digits int) string {
+	if digits == 6 {
+		return "TIMESTAMP WITH TIME ZONE"
+	}
+	return fmt.Sprintf("TIMESTAMP(%d) WITH TIME ZONE", digits)
+}
+
+// postgresInterval 将时长转换为 PostgreSQL interval 字面量
+func postgresInterval(d time.Duration) string {
+	return fmt.Sprintf("%d seconds", int64(d/time.Second))
+}
+
+// createHypertable 将日志表转换为 hypertable 并设置 chunk 范围与压缩策略。
+// 已存在的普通表会先将主键改为 (id, timestamp)，已有数据迁移到 chunk 中
+func (s *PostgresStorage) createHypertable(ctx context.Context, tableName string, schema *models.Schema) error {
+	config := s.config.Postgres.Timescale
+	chunkInterval := config.ChunkInterval
+	if chunkInterval == 0 {
+		chunkInterval = defaultChunkInterval
+	}
+
+	var compressed bool
+	err := s.db.QueryRowContext(ctx, `
+	SELECT compression_enabled FROM timescaledb_information.hypertables
+	WHERE hypertable_schema = $1 AND hypertable_name = $2`,
+		s.schema, postgresTableName(schema.Project, schema.Table),
+	).Scan(&compressed)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("查询 hypertable 失败: %w", err)
+	}
+
+	if err == sql.ErrNoRows {
+		queries := []string{
+			fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s", tableName, quote(postgresTableName(schema.Project, schema.Table)+"_pkey")),
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN timestamp SET NOT NULL", tableName),
+			fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (id, timestamp)", tableName),
+		}
+		for _, query := range queries {
+			if _, err := s.db.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("迁移主键失败: %w", err)
+			}
+		}
+		query := `SELECT create_hypertable($1::regclass, 'timestamp', chunk_time_interval => $2::interval, if_not_exists => TRUE, migrate_data => TRUE)`
+		if _, err := s.db.ExecContext(ctx, query, tableName, postgresInterval(chunkInterval)); err != nil {
+			return fmt.Errorf("创建 hypertable 失败: %w", err)
+		}
+	} else {
+		// 只影响之后新建的 chunk
+		query := `SELECT set_chunk_time_interval($1::regclass, $2::interval)`
+		if _, err := s.db.ExecContext(ctx, query, tableName, postgresInterval(chunkInterval)); err != nil {
+			return fmt.Errorf("设置 chunk 范围失败: %w", err)
+		}
+	}
+
+	if config.CompressAfter == 0 {
+		return nil
+	}
+	if !compressed {
+		query := fmt.Sprintf(`ALTER TABLE %s SET (timescaledb.compress, timescaledb.compress_orderby = 'timestamp DESC')`, tableName)
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("启用压缩失败: %w", err)
+		}
+	}
+	// 策略已存在时保持原有设置
+	query := `SELECT add_compression_policy($1::regclass, $2::interval, if_not_exists => TRUE)`
+	if _, err := s.db.ExecContext(ctx, query, tableName, postgresInterval(config.CompressAfter)); err != nil {
+		return fmt.Errorf("添加压缩策略失败: %w", err)
+	}
+	return nil
+}
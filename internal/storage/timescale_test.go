@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresKeyColumns(t *testing.T) {
+	assert.Contains(t, postgresKeyColumns(false, postgresTimestampType(6)), "id VARCHAR(64) PRIMARY KEY")
+	assert.Contains(t, postgresKeyColumns(false, postgresTimestampType(3)), "timestamp TIMESTAMP(3) WITH TIME ZONE")
+
+	columns := postgresKeyColumns(true, postgresTimestampType(6))
+	assert.Contains(t, columns, "timestamp TIMESTAMP WITH TIME ZONE NOT NULL")
+	assert.Contains(t, columns, "PRIMARY KEY (id, timestamp)")
+	assert.NotContains(t, columns, "id VARCHAR(64) PRIMARY KEY")
+
+	assert.Equal(t, "86400 seconds", postgresInterval(defaultChunkInterval))
+	assert.Equal(t, "604800 seconds", postgresInterval(7*24*time.Hour))
+}
+
+func TestEnableTimescaleDisabled(t *testing.T) {
+	store := NewPostgresStorage(Config{Type: "postgres"})
+	assert.False(t, store.enableTimescale(context.Background()))
+}
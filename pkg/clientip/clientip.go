@@ -0,0 +1,186 @@
+// Package clientip 从经过反向代理或负载均衡的 HTTP 请求中取得真实的客户端地址。
+// 只有直接连接的地址属于受信任的代理时才读取 Forwarded、X-Forwarded-For 等头部，
+// 并从右向左跳过链路中受信任的代理，客户端无法通过伪造头部冒充其他地址
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// 常用的客户端地址头部
+const (
+	HeaderForwarded      = "Forwarded" // RFC 7239，读取 for= 参数
+	HeaderXForwardedFor  = "X-Forwarded-For"
+	HeaderXRealIP        = "X-Real-IP"
+	HeaderCFConnectingIP = "CF-Connecting-IP" // Cloudflare，需将 Cloudflare 的地址段配置为受信任代理
+	HeaderTrueClientIP   = "True-Client-IP"
+)
+
+// DefaultHeaders 默认依次读取的头部
+var DefaultHeaders = []string{HeaderForwarded, HeaderXForwardedFor, HeaderXRealIP}
+
+// 受信任代理中可以使用的地址段别名
+var aliases = map[string][]string{
+	"loopback": {"127.0.0.0/8", "::1/128"},
+	"private":  {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+}
+
+// Config 客户端地址的解析规则
+type Config struct {
+	// TrustedProxies 受信任代理的地址或 CIDR，也可以使用 loopback 与 private 别名。
+	// 为空时不信任任何代理，总是使用连接地址
+	TrustedProxies []string
+
+	// Headers 依次读取的头部，第一个能解析出地址的头部生效，默认 DefaultHeaders。
+	// Forwarded 按 RFC 7239 解析，其他头部按逗号分隔的地址列表解析
+	Headers []string
+}
+
+// Resolver 按受信任代理配置取得客户端地址，可并发使用
+type Resolver struct {
+	trusted []netip.Prefix
+	headers []string
+}
+
+// New 创建 Resolver，受信任代理的格式错误时返回错误
+func New(config Config) (*Resolver, error) {
+	r := &Resolver{headers: config.Headers}
+	if len(r.headers) == 0 {
+		r.headers = DefaultHeaders
+	}
+	for _, proxy := range config.TrustedProxies {
+		entries, ok := aliases[strings.ToLower(proxy)]
+		if !ok {
+			entries = []string{proxy}
+		}
+		for _, entry := range entries {
+			prefix, err := parsePrefix(entry)
+			if err != nil {
+				return nil, err
+			}
+			r.trusted = append(r.trusted, prefix)
+		}
+	}
+	return r, nil
+}
+
+// parsePrefix 解析 CIDR 或单个地址
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Trusted 地址是否属于受信任的代理
+func (r *Resolver) Trusted(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 返回请求的客户端地址。连接地址不受信任或头部中没有可用的地址时返回连接地址，
+// 连接地址无法解析时原样返回 RemoteAddr 的主机部分
+func (r *Resolver) ClientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	remote, ok := parseNode(host)
+	if !ok {
+		return host
+	}
+	if !r.Trusted(remote) {
+		return remote.String()
+	}
+
+	for _, header := range r.headers {
+		values := req.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+		var nodes []string
+		if strings.EqualFold(header, HeaderForwarded) {
+			nodes = forwardedFor(values)
+		} else {
+			nodes = splitList(values)
+		}
+		if addr, ok := r.pick(nodes); ok {
+			return addr.String()
+		}
+	}
+	return remote.String()
+}
+
+// pick 从右向左跳过受信任的代理，返回第一个不受信任的地址；全部受信任时返回最左侧的地址。
+// 链路中有无法解析的地址时整个头部不可用
+func (r *Resolver) pick(nodes []string) (netip.Addr, bool) {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		addr, ok := parseNode(nodes[i])
+		if !ok {
+			return netip.Addr{}, false
+		}
+		if i == 0 || !r.Trusted(addr) {
+			return addr, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// splitList 拆分逗号分隔的地址列表，同一头部出现多次时按出现顺序连接
+func splitList(values []string) []string {
+	var nodes []string
+	for _, value := range values {
+		for _, node := range strings.Split(value, ",") {
+			nodes = append(nodes, strings.TrimSpace(node))
+		}
+	}
+	return nodes
+}
+
+// forwardedFor 返回 Forwarded 头部各元素的 for= 参数，没有 for= 的元素返回空字符串
+func forwardedFor(values []string) []string {
+	var nodes []string
+	for _, element := range splitList(values) {
+		node := ""
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "for") {
+				node = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// parseNode 解析地址，支持 IPv4、IPv6、带端口的 1.2.3.4:80 与 [::1]:80。
+// unknown 与 _hidden 等匿名标识无法解析
+func parseNode(node string) (netip.Addr, bool) {
+	node = strings.TrimSpace(node)
+	if addr, err := netip.ParseAddr(strings.Trim(node, "[]")); err == nil {
+		return addr.Unmap().WithZone(""), true
+	}
+	if addrPort, err := netip.ParseAddrPort(node); err == nil {
+		return addrPort.Addr().Unmap().WithZone(""), true
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,72 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := New(Config{TrustedProxies: []string{"10.0.0.0/8", "2001:db8::1", "loopback"}})
+	require.NoError(t, err)
+
+	request := func(remote string, headers ...string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Add(headers[i], headers[i+1])
+		}
+		return req
+	}
+
+	for name, tt := range map[string]struct {
+		req  *http.Request
+		want string
+	}{
+		"direct":                 {request("203.0.113.7:5000"), "203.0.113.7"},
+		"untrusted remote":       {request("203.0.113.7:5000", "X-Forwarded-For", "198.51.100.1"), "203.0.113.7"},
+		"x-forwarded-for":        {request("10.0.0.2:5000", "X-Forwarded-For", "198.51.100.1"), "198.51.100.1"},
+		"skips trusted hops":     {request("10.0.0.2:5000", "X-Forwarded-For", "1.1.1.1, 198.51.100.1, 10.0.0.3"), "198.51.100.1"},
+		"spoofed leftmost":       {request("10.0.0.2:5000", "X-Forwarded-For", "1.1.1.1", "X-Forwarded-For", "198.51.100.1"), "198.51.100.1"},
+		"all trusted":            {request("10.0.0.2:5000", "X-Forwarded-For", "10.0.0.9, 10.0.0.3"), "10.0.0.9"},
+		"forwarded":              {request("127.0.0.1:5000", "Forwarded", `for=192.0.2.60;proto=http;by=203.0.113.43`), "192.0.2.60"},
+		"forwarded ipv6 port":    {request("[2001:db8::1]:443", "Forwarded", `for="[2001:db8:cafe::17]:4711", for=10.1.2.3`), "2001:db8:cafe::17"},
+		"forwarded before xff":   {request("10.0.0.2:5000", "Forwarded", "for=192.0.2.60", "X-Forwarded-For", "198.51.100.1"), "192.0.2.60"},
+		"forwarded obfuscated":   {request("10.0.0.2:5000", "Forwarded", "for=_hidden", "X-Forwarded-For", "198.51.100.1"), "198.51.100.1"},
+		"invalid header":         {request("10.0.0.2:5000", "X-Forwarded-For", "garbage"), "10.0.0.2"},
+		"x-real-ip":              {request("10.0.0.2:5000", "X-Real-IP", "198.51.100.9"), "198.51.100.9"},
+		"mapped remote":          {request("[::ffff:10.0.0.2]:5000", "X-Forwarded-For", "198.51.100.1"), "198.51.100.1"},
+		"cf header not default":  {request("10.0.0.2:5000", "CF-Connecting-IP", "198.51.100.5"), "10.0.0.2"},
+		"unparseable remote":     {request("pipe"), "pipe"},
+		"forwarded with port v4": {request("10.0.0.2:5000", "Forwarded", `for="192.0.2.43:47011"`), "192.0.2.43"},
+	} {
+		assert.Equal(t, tt.want, resolver.ClientIP(tt.req), name)
+	}
+
+	cloudflare, err := New(Config{TrustedProxies: []string{"173.245.48.0/20"}, Headers: []string{HeaderCFConnectingIP}})
+	require.NoError(t, err)
+	assert.Equal(t, "198.51.100.5", cloudflare.ClientIP(request("173.245.48.1:443", "CF-Connecting-IP", "198.51.100.5")))
+	assert.Equal(t, "203.0.113.7", cloudflare.ClientIP(request("203.0.113.7:443", "CF-Connecting-IP", "198.51.100.5")))
+
+	// 未配置受信任代理时总是使用连接地址
+	none, err := New(Config{})
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", none.ClientIP(request("127.0.0.1:5000", "X-Forwarded-For", "198.51.100.1")))
+}
+
+func TestNewInvalidProxy(t *testing.T) {
+	_, err := New(Config{TrustedProxies: []string{"10.0.0.0/33"}})
+	assert.Error(t, err)
+	_, err = New(Config{TrustedProxies: []string{"proxy.local"}})
+	assert.Error(t, err)
+
+	resolver, err := New(Config{TrustedProxies: []string{"private"}})
+	require.NoError(t, err)
+	assert.True(t, resolver.Trusted(netip.MustParseAddr("192.168.1.1")))
+	assert.True(t, resolver.Trusted(netip.MustParseAddr("fd00::1")))
+	assert.False(t, resolver.Trusted(netip.MustParseAddr("8.8.8.8")))
+}
@@ -0,0 +1,191 @@
+// Package clock 提供可替换的时间源，后台任务通过它创建定时器，
+// 测试中使用 Mock 推进时间，无需 time.Sleep
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock 时间源
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker 周期定时器
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer 一次性定时器
+type Timer interface {
+	Stop() bool
+}
+
+// New 返回基于系统时间的 Clock
+func New() Clock {
+	return realClock{}
+}
+
+// OrReal 返回 c，为 nil 时返回系统时间
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return New()
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// Mock 手动推进的时间源，定时器只在 Add/Set 时触发
+type Mock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter 等待触发的 ticker 或 AfterFunc 定时器
+type waiter struct {
+	mock   *Mock
+	next   time.Time
+	period time.Duration // ticker 的周期，AfterFunc 为 0
+	c      chan time.Time
+	fn     func()
+}
+
+// NewMock 创建从 now 开始的 Mock
+func NewMock(now time.Time) *Mock {
+	m := &Mock{now: now}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// Now 返回当前模拟时间
+func (m *Mock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// NewTicker 创建模拟 ticker，与 time.Ticker 一样在接收方未及时读取时丢弃 tick
+func (m *Mock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	w := &waiter{mock: m, period: d, c: make(chan time.Time, 1)}
+	m.add(w, d)
+	return mockTicker{w}
+}
+
+// AfterFunc 创建模拟定时器，f 在推进时间的 goroutine 中同步执行
+func (m *Mock) AfterFunc(d time.Duration, f func()) Timer {
+	w := &waiter{mock: m, fn: f}
+	m.add(w, d)
+	return w
+}
+
+// add 注册定时器并唤醒 BlockUntil
+func (m *Mock) add(w *waiter, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.next = m.now.Add(d)
+	m.waiters = append(m.waiters, w)
+	m.cond.Broadcast()
+}
+
+// remove 注销定时器，返回其是否仍处于等待状态
+func (m *Mock) remove(w *waiter) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, other := range m.waiters {
+		if other == w {
+			m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+			m.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// BlockUntil 阻塞直到至少有 n 个等待中的定时器，用于确认后台 goroutine 已就绪
+func (m *Mock) BlockUntil(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(m.waiters) < n {
+		m.cond.Wait()
+	}
+}
+
+// Add 将时间推进 d，并按时间顺序触发到期的定时器
+func (m *Mock) Add(d time.Duration) {
+	m.Set(m.Now().Add(d))
+}
+
+// Set 将时间推进到 t，并按时间顺序触发到期的定时器
+func (m *Mock) Set(t time.Time) {
+	for m.fireNext(t) {
+	}
+
+	m.mu.Lock()
+	if t.After(m.now) {
+		m.now = t
+	}
+	m.mu.Unlock()
+}
+
+// fireNext 触发最早一个不晚于 t 的定时器，没有可触发的定时器时返回 false
+func (m *Mock) fireNext(t time.Time) bool {
+	m.mu.Lock()
+	sort.SliceStable(m.waiters, func(i, j int) bool { return m.waiters[i].next.Before(m.waiters[j].next) })
+	if len(m.waiters) == 0 || m.waiters[0].next.After(t) {
+		m.mu.Unlock()
+		return false
+	}
+
+	w := m.waiters[0]
+	m.now = w.next
+	if w.period > 0 {
+		w.next = w.next.Add(w.period)
+		select {
+		case w.c <- m.now:
+		default:
+		}
+		m.mu.Unlock()
+		return true
+	}
+
+	m.waiters = m.waiters[1:]
+	m.cond.Broadcast()
+	m.mu.Unlock()
+	w.fn()
+	return true
+}
+
+// Stop 停止定时器，已触发或已停止时返回 false
+func (w *waiter) Stop() bool { return w.mock.remove(w) }
+
+// mockTicker 模拟 ticker
+type mockTicker struct{ w *waiter }
+
+func (t mockTicker) C() <-chan time.Time { return t.w.c }
+func (t mockTicker) Stop()               { t.w.mock.remove(t.w) }
@@ -0,0 +1,65 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockTicker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMock(start)
+
+	ticker := m.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	m.Add(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired early")
+	default:
+	}
+
+	m.Add(500 * time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-ticker.C())
+
+	// 未读取的 tick 会被丢弃，与 time.Ticker 一致
+	m.Add(3 * time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-ticker.C())
+	assert.Equal(t, start.Add(4*time.Second), m.Now())
+}
+
+func TestMockAfterFunc(t *testing.T) {
+	m := NewMock(time.Unix(0, 0))
+
+	var fired []string
+	m.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	m.AfterFunc(time.Second, func() {
+		fired = append(fired, "a")
+		// 回调中注册的定时器在同一次推进中按时间顺序触发
+		m.AfterFunc(500*time.Millisecond, func() { fired = append(fired, "a2") })
+	})
+	stopped := m.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	assert.True(t, stopped.Stop())
+
+	m.BlockUntil(2)
+	m.Add(3 * time.Second)
+	assert.Equal(t, []string{"a", "a2", "b"}, fired)
+	assert.False(t, stopped.Stop())
+}
+
+func TestMockBlockUntil(t *testing.T) {
+	m := NewMock(time.Unix(0, 0))
+	ticks := make(chan time.Time)
+
+	go func() {
+		ticker := m.NewTicker(time.Minute)
+		defer ticker.Stop()
+		ticks <- <-ticker.C()
+	}()
+
+	m.BlockUntil(1)
+	m.Add(time.Minute)
+	assert.Equal(t, time.Unix(60, 0), <-ticks)
+}
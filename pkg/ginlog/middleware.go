@@ -0,0 +1,192 @@
+package ginlog
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/logctx"
+)
+
+// Recorder 接收访问日志，storage.Storage 以及其他实现了 InsertLog 的客户端均可使用
+type Recorder interface {
+	InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error
+}
+
+// options 中间件配置
+type options struct {
+	sampleRate      float64
+	excludePaths    []string
+	headers         []string
+	requestIDHeader string
+	errorHandler    func(error)
+	clientIP        func(r *http.Request) string
+}
+
+// Option 配置中间件
+type Option func(*options)
+
+// WithSampleRate 设置采样率，取值范围 (0, 1]，默认记录全部请求
+func WithSampleRate(rate float64) Option {
+	return func(o *options) {
+		o.sampleRate = rate
+	}
+}
+
+// WithExcludePaths 设置不记录的路径，以 * 结尾表示前缀匹配
+func WithExcludePaths(paths ...string) Option {
+	return func(o *options) {
+		o.excludePaths = append(o.excludePaths, paths...)
+	}
+}
+
+// WithHeaders 设置需要记录的请求头
+func WithHeaders(headers ...string) Option {
+	return func(o *options) {
+		o.headers = append(o.headers, headers...)
+	}
+}
+
+// WithRequestIDHeader 设置读取请求 ID 的请求头，默认 X-Request-ID
+func WithRequestIDHeader(header string) Option {
+	return func(o *options) {
+		o.requestIDHeader = header
+	}
+}
+
+// WithClientIP 设置取得客户端地址的方式，如 clientip.Resolver 的 ClientIP 方法，默认使用 gin 的 Context.ClientIP
+func WithClientIP(fn func(r *http.Request) string) Option {
+	return func(o *options) {
+		o.clientIP = fn
+	}
+}
+
+// WithErrorHandler 设置写入失败时的回调，未设置时忽略写入错误
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) {
+		o.errorHandler = fn
+	}
+}
+
+// Middleware 返回记录 HTTP 访问日志的 gin 中间件，
+// 请求头中的请求 ID 会写入 request context，下游可通过 logctx.RequestID 获取
+func Middleware(recorder Recorder, project, table string, opts ...Option) gin.HandlerFunc {
+	o := &options{
+		sampleRate:      1,
+		requestIDHeader: "X-Request-ID",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if o.excluded(path) || !o.sampled() {
+			c.Next()
+			return
+		}
+
+		if id := c.GetHeader(o.requestIDHeader); id != "" {
+			c.Request = c.Request.WithContext(logctx.WithRequestID(c.Request.Context(), id))
+		}
+
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		status := c.Writer.Status()
+		ip := c.ClientIP()
+		if o.clientIP != nil {
+			ip = o.clientIP(c.Request)
+		}
+		entry := &models.LogEntry{
+			Project:   project,
+			Table:     table,
+			Level:     levelForStatus(status),
+			Message:   fmt.Sprintf("%s %s", c.Request.Method, path),
+			Timestamp: start,
+			IP:        ip,
+			Fields: map[string]interface{}{
+				"method":       c.Request.Method,
+				"path":         path,
+				"status":       status,
+				"latency":      latency.Nanoseconds(),
+				"ip":           ip,
+				"body_size":    c.Writer.Size(),
+				"request_size": c.Request.ContentLength,
+			},
+		}
+
+		if requestID := o.requestID(c); requestID != "" {
+			entry.Fields["request_id"] = requestID
+		}
+		if len(o.headers) > 0 {
+			headers := make(map[string]interface{}, len(o.headers))
+			for _, name := range o.headers {
+				if value := c.GetHeader(name); value != "" {
+					headers[strings.ToLower(name)] = value
+				}
+			}
+			entry.Fields["headers"] = headers
+		}
+
+		logctx.Inject(c.Request.Context(), entry)
+
+		if err := recorder.InsertLog(c.Request.Context(), project, table, entry); err != nil && o.errorHandler != nil {
+			o.errorHandler(err)
+		}
+	}
+}
+
+// excluded 检查路径是否被排除
+func (o *options) excluded(path string) bool {
+	for _, p := range o.excludePaths {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// sampled 根据采样率决定是否记录
+func (o *options) sampled() bool {
+	if o.sampleRate >= 1 {
+		return true
+	}
+	if o.sampleRate <= 0 {
+		return false
+	}
+	return rand.Float64() < o.sampleRate
+}
+
+// requestID 获取请求 ID，优先使用请求头，其次使用上下文中的 request_id
+func (o *options) requestID(c *gin.Context) string {
+	if id := c.GetHeader(o.requestIDHeader); id != "" {
+		return id
+	}
+	if id := c.Writer.Header().Get(o.requestIDHeader); id != "" {
+		return id
+	}
+	return c.GetString("request_id")
+}
+
+// levelForStatus 根据状态码确定日志级别
+func levelForStatus(status int) string {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return "error"
+	case status >= http.StatusBadRequest:
+		return "warn"
+	default:
+		return "info"
+	}
+}
@@ -0,0 +1,142 @@
+package ginlog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clientip"
+	"pkg.blksails.net/logs/pkg/logctx"
+)
+
+type mockRecorder struct {
+	mu   sync.Mutex
+	logs []*models.LogEntry
+}
+
+func (m *mockRecorder) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs = append(m.logs, log)
+	return nil
+}
+
+// failingRecorder 写入总是失败的 Recorder
+type failingRecorder struct{}
+
+func (failingRecorder) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
+	return errors.New("storage unavailable")
+}
+
+func newTestRouter(recorder Recorder, opts ...Option) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(recorder, "web", "access_logs", opts...))
+	router.GET("/hello", func(c *gin.Context) {
+		c.String(http.StatusOK, "hello")
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+	router.GET("/ctx", func(c *gin.Context) {
+		c.String(http.StatusOK, logctx.RequestID(c.Request.Context()))
+	})
+	router.GET("/health", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := &mockRecorder{}
+	router := newTestRouter(recorder, WithHeaders("User-Agent"))
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("User-Agent", "test-agent")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Len(t, recorder.logs, 1)
+	log := recorder.logs[0]
+	assert.Equal(t, "web", log.Project)
+	assert.Equal(t, "access_logs", log.Table)
+	assert.Equal(t, "info", log.Level)
+	assert.Equal(t, "GET", log.Fields["method"])
+	assert.Equal(t, "/hello", log.Fields["path"])
+	assert.Equal(t, http.StatusOK, log.Fields["status"])
+	assert.Equal(t, 5, log.Fields["body_size"])
+	assert.IsType(t, int64(0), log.Fields["latency"])
+	assert.Equal(t, "req-1", log.Fields["request_id"])
+	assert.Equal(t, map[string]interface{}{"user-agent": "test-agent"}, log.Fields["headers"])
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	require.Len(t, recorder.logs, 2)
+	assert.Equal(t, "error", recorder.logs[1].Level)
+}
+
+func TestMiddlewareExcludeAndSample(t *testing.T) {
+	recorder := &mockRecorder{}
+	router := newTestRouter(recorder, WithExcludePaths("/health"))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Empty(t, recorder.logs)
+
+	recorder = &mockRecorder{}
+	router = newTestRouter(recorder, WithExcludePaths("/he*"))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Empty(t, recorder.logs)
+
+	recorder = &mockRecorder{}
+	router = newTestRouter(recorder, WithSampleRate(0))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Empty(t, recorder.logs)
+}
+
+func TestMiddlewareRequestIDContext(t *testing.T) {
+	recorder := &mockRecorder{}
+	router := newTestRouter(recorder)
+
+	req := httptest.NewRequest(http.MethodGet, "/ctx", nil)
+	req.Header.Set("X-Request-ID", "req-2")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "req-2", w.Body.String())
+	require.Len(t, recorder.logs, 1)
+	assert.Equal(t, "req-2", recorder.logs[0].Fields["request_id"])
+}
+
+func TestMiddlewareClientIP(t *testing.T) {
+	resolver, err := clientip.New(clientip.Config{TrustedProxies: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+	recorder := &mockRecorder{}
+	router := newTestRouter(recorder, WithClientIP(resolver.ClientIP))
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.RemoteAddr = "10.0.0.2:5000"
+	req.Header.Set("Forwarded", "for=198.51.100.1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Len(t, recorder.logs, 1)
+	assert.Equal(t, "198.51.100.1", recorder.logs[0].IP)
+	assert.Equal(t, "198.51.100.1", recorder.logs[0].Fields["ip"])
+}
+
+func TestMiddlewareErrorHandler(t *testing.T) {
+	// 默认忽略写入错误，不影响请求
+	w := httptest.NewRecorder()
+	newTestRouter(failingRecorder{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var errs []error
+	router := newTestRouter(failingRecorder{}, WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "storage unavailable")
+}
@@ -0,0 +1,318 @@
+package grpclog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/logctx"
+)
+
+// requestIDMetadata 携带请求 ID 的元数据键
+const requestIDMetadata = "x-request-id"
+
+// Writer 接收 RPC 日志，pkg/zap 中带缓冲的 Hook 实现了该接口
+type Writer interface {
+	WriteEntry(log *models.LogEntry) error
+}
+
+// options 拦截器配置
+type options struct {
+	metadataKeys   []string
+	excludeMethods []string
+	errorHandler   func(error)
+}
+
+// Option 配置拦截器
+type Option func(*options)
+
+// WithMetadataKeys 设置需要记录的元数据键，默认记录全部元数据
+func WithMetadataKeys(keys ...string) Option {
+	return func(o *options) {
+		for _, key := range keys {
+			o.metadataKeys = append(o.metadataKeys, strings.ToLower(key))
+		}
+	}
+}
+
+// WithExcludeMethods 设置不记录的方法，以 * 结尾表示前缀匹配
+func WithExcludeMethods(methods ...string) Option {
+	return func(o *options) {
+		o.excludeMethods = append(o.excludeMethods, methods...)
+	}
+}
+
+// WithErrorHandler 设置写入失败时的回调，未设置时忽略写入错误
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) {
+		o.errorHandler = fn
+	}
+}
+
+// newOptions 创建默认配置
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// UnaryServerInterceptor 返回记录一元调用的服务端拦截器
+func UnaryServerInterceptor(w Writer, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if o.excluded(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = withRequestID(ctx, md)
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		o.record(ctx, w, "server", "unary", info.FullMethod, peerAddr(ctx), md, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor 返回记录流式调用的服务端拦截器
+func StreamServerInterceptor(w Writer, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if o.excluded(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		ctx := withRequestID(ss.Context(), md)
+
+		start := time.Now()
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		o.record(ctx, w, "server", "stream", info.FullMethod, peerAddr(ctx), md, start, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor 返回记录一元调用的客户端拦截器
+func UnaryClientInterceptor(w Writer, opts ...Option) grpc.UnaryClientInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		if o.excluded(method) {
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+
+		var p peer.Peer
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, append(callOpts, grpc.Peer(&p))...)
+		md, _ := metadata.FromOutgoingContext(ctx)
+		o.record(ctx, w, "client", "unary", method, addrString(p.Addr, cc.Target()), md, start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor 返回记录流式调用的客户端拦截器，在流结束时写入日志：
+// 接收到 io.EOF 或错误、非服务端流式调用收到响应，或调用的 context 被取消
+func StreamClientInterceptor(w Writer, opts ...Option) grpc.StreamClientInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if o.excluded(method) {
+			return streamer(ctx, desc, cc, method, callOpts...)
+		}
+
+		p := &peer.Peer{}
+		start := time.Now()
+		md, _ := metadata.FromOutgoingContext(ctx)
+		finish := func(err error) {
+			o.record(ctx, w, "client", "stream", method, addrString(p.Addr, cc.Target()), md, start, err)
+		}
+
+		cs, err := streamer(ctx, desc, cc, method, append(callOpts, grpc.Peer(p))...)
+		if err != nil {
+			finish(err)
+			return nil, err
+		}
+		stream := &clientStream{ClientStream: cs, serverStreams: desc.ServerStreams, done: make(chan struct{}), finish: finish}
+		// 调用方放弃流时不会再调用 RecvMsg，以 context 取消作为结束
+		go func() {
+			select {
+			case <-ctx.Done():
+				stream.end(status.FromContextError(ctx.Err()).Err())
+			case <-stream.done:
+			}
+		}()
+		return stream, nil
+	}
+}
+
+// serverStream 包装 grpc.ServerStream，使处理函数获得携带请求 ID 的 context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context 返回携带请求 ID 的 context
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// clientStream 包装 grpc.ClientStream，在调用结束时记录一次日志
+type clientStream struct {
+	grpc.ClientStream
+	serverStreams bool // 服务端是否流式响应，否则收到唯一的响应即结束
+	once          sync.Once
+	done          chan struct{}
+	finish        func(error)
+}
+
+// RecvMsg 接收消息，io.EOF 视为正常结束
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	case !s.serverStreams:
+		s.end(nil)
+	}
+	return err
+}
+
+// end 结束调用并记录日志，只有第一次调用生效
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		close(s.done)
+		s.finish(err)
+	})
+}
+
+// record 构建日志条目并写入
+func (o *options) record(ctx context.Context, w Writer, side, kind, method, addr string, md metadata.MD, start time.Time, err error) {
+	latency := time.Since(start)
+	code := status.Code(err)
+
+	entry := &models.LogEntry{
+		Level:     levelForCode(code),
+		Message:   fmt.Sprintf("%s %s", method, code),
+		Timestamp: start,
+		IP:        hostOf(addr),
+		Fields: map[string]interface{}{
+			"side":    side,
+			"kind":    kind,
+			"method":  method,
+			"code":    code.String(),
+			"latency": latency.Nanoseconds(),
+			"peer":    addr,
+		},
+	}
+	if err != nil {
+		entry.Fields["error"] = status.Convert(err).Message()
+	}
+	if values := o.metadata(md); len(values) > 0 {
+		entry.Fields["metadata"] = values
+	}
+	if id := metadataValue(md, requestIDMetadata); id != "" {
+		entry.Fields[logctx.RequestIDField] = id
+	}
+	logctx.Inject(ctx, entry)
+
+	if err := w.WriteEntry(entry); err != nil && o.errorHandler != nil {
+		o.errorHandler(err)
+	}
+}
+
+// metadata 提取需要记录的元数据
+func (o *options) metadata(md metadata.MD) map[string]interface{} {
+	values := make(map[string]interface{})
+	if len(o.metadataKeys) == 0 {
+		for key, vals := range md {
+			if strings.HasPrefix(key, ":") || len(vals) == 0 {
+				continue
+			}
+			values[key] = strings.Join(vals, ",")
+		}
+		return values
+	}
+	for _, key := range o.metadataKeys {
+		if vals := md.Get(key); len(vals) > 0 {
+			values[key] = strings.Join(vals, ",")
+		}
+	}
+	return values
+}
+
+// excluded 检查方法是否被排除
+func (o *options) excluded(method string) bool {
+	for _, m := range o.excludeMethods {
+		if strings.HasSuffix(m, "*") {
+			if strings.HasPrefix(method, strings.TrimSuffix(m, "*")) {
+				return true
+			}
+		} else if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// withRequestID 将元数据中的请求 ID 写入 context
+func withRequestID(ctx context.Context, md metadata.MD) context.Context {
+	return logctx.WithRequestID(ctx, metadataValue(md, requestIDMetadata))
+}
+
+// metadataValue 获取元数据中的第一个值
+func metadataValue(md metadata.MD, key string) string {
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// peerAddr 获取服务端上下文中的对端地址
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// addrString 返回对端地址，无法获取时使用连接目标
+func addrString(addr net.Addr, target string) string {
+	if addr != nil {
+		return addr.String()
+	}
+	return target
+}
+
+// hostOf 去掉地址中的端口
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// levelForCode 根据状态码确定日志级别
+func levelForCode(code codes.Code) string {
+	switch code {
+	case codes.OK:
+		return "info"
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition,
+		codes.OutOfRange, codes.ResourceExhausted:
+		return "warn"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,194 @@
+package grpclog
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+type mockWriter struct {
+	mu   sync.Mutex
+	logs []*models.LogEntry
+}
+
+func (m *mockWriter) WriteEntry(log *models.LogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs = append(m.logs, log)
+	return nil
+}
+
+func (m *mockWriter) entries() []*models.LogEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*models.LogEntry(nil), m.logs...)
+}
+
+func newTestClient(t *testing.T, server, client *mockWriter, opts ...Option) healthpb.HealthClient {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(server, opts...)),
+		grpc.StreamInterceptor(StreamServerInterceptor(server, opts...)),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("logs", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(client, opts...)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(client, opts...)),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	server, client := &mockWriter{}, &mockWriter{}
+	hc := newTestClient(t, server, client, WithMetadataKeys("X-Request-ID"))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-1", "authorization", "secret")
+	_, err := hc.Check(ctx, &healthpb.HealthCheckRequest{Service: "logs"})
+	require.NoError(t, err)
+
+	_, err = hc.Check(ctx, &healthpb.HealthCheckRequest{Service: "missing"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	require.Len(t, server.entries(), 2)
+	log := server.entries()[0]
+	assert.Equal(t, "info", log.Level)
+	assert.Equal(t, "server", log.Fields["side"])
+	assert.Equal(t, "unary", log.Fields["kind"])
+	assert.Equal(t, "/grpc.health.v1.Health/Check", log.Fields["method"])
+	assert.Equal(t, "OK", log.Fields["code"])
+	assert.IsType(t, int64(0), log.Fields["latency"])
+	assert.Equal(t, map[string]interface{}{"x-request-id": "req-1"}, log.Fields["metadata"])
+	assert.Equal(t, "warn", server.entries()[1].Level)
+	assert.Equal(t, "NotFound", server.entries()[1].Fields["code"])
+
+	require.Len(t, client.entries(), 2)
+	assert.Equal(t, "client", client.entries()[0].Fields["side"])
+	assert.Equal(t, "OK", client.entries()[0].Fields["code"])
+	assert.NotEmpty(t, client.entries()[0].Fields["peer"])
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	server, client := &mockWriter{}, &mockWriter{}
+	hc := newTestClient(t, server, client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := hc.Watch(ctx, &healthpb.HealthCheckRequest{Service: "logs"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	cancel()
+	_, err = stream.Recv()
+	require.Equal(t, codes.Canceled, status.Code(err))
+
+	require.Eventually(t, func() bool { return len(server.entries()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "stream", server.entries()[0].Fields["kind"])
+	assert.Equal(t, "/grpc.health.v1.Health/Watch", server.entries()[0].Fields["method"])
+
+	require.Len(t, client.entries(), 1)
+	assert.Equal(t, "Canceled", client.entries()[0].Fields["code"])
+	assert.Equal(t, "warn", client.entries()[0].Level)
+}
+
+func TestExcludeMethods(t *testing.T) {
+	server, client := &mockWriter{}, &mockWriter{}
+	hc := newTestClient(t, server, client, WithExcludeMethods("/grpc.health.v1.*"))
+
+	_, err := hc.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "logs"})
+	require.NoError(t, err)
+	assert.Empty(t, server.entries())
+	assert.Empty(t, client.entries())
+}
+
+// fakeClientStream 依次返回 recv 中的结果的 grpc.ClientStream
+type fakeClientStream struct {
+	grpc.ClientStream
+	recv []error
+}
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	err := s.recv[0]
+	s.recv = s.recv[1:]
+	return err
+}
+
+func TestStreamClientInterceptorFinish(t *testing.T) {
+	cc, err := grpc.NewClient("passthrough:///bufnet", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+
+	open := func(ctx context.Context, w *mockWriter, desc *grpc.StreamDesc, recv ...error) grpc.ClientStream {
+		streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &fakeClientStream{recv: recv}, nil
+		}
+		stream, err := StreamClientInterceptor(w)(ctx, desc, cc, "/test.Service/Call", streamer)
+		require.NoError(t, err)
+		return stream
+	}
+
+	t.Run("client streaming", func(t *testing.T) {
+		// CloseAndRecv 只调用一次 RecvMsg，收到响应即结束
+		w := &mockWriter{}
+		stream := open(context.Background(), w, &grpc.StreamDesc{ClientStreams: true}, nil, io.EOF)
+		require.NoError(t, stream.RecvMsg(nil))
+		require.Len(t, w.entries(), 1)
+		assert.Equal(t, "OK", w.entries()[0].Fields["code"])
+		assert.Equal(t, "stream", w.entries()[0].Fields["kind"])
+		assert.Equal(t, io.EOF, stream.RecvMsg(nil))
+		assert.Len(t, w.entries(), 1, "logged once")
+	})
+
+	t.Run("server streaming", func(t *testing.T) {
+		w := &mockWriter{}
+		stream := open(context.Background(), w, &grpc.StreamDesc{ServerStreams: true}, nil, nil, io.EOF)
+		require.NoError(t, stream.RecvMsg(nil))
+		require.NoError(t, stream.RecvMsg(nil))
+		assert.Empty(t, w.entries(), "not finished while messages arrive")
+		assert.Equal(t, io.EOF, stream.RecvMsg(nil))
+		require.Len(t, w.entries(), 1)
+		assert.Equal(t, "OK", w.entries()[0].Fields["code"])
+	})
+
+	t.Run("server streaming error", func(t *testing.T) {
+		w := &mockWriter{}
+		stream := open(context.Background(), w, &grpc.StreamDesc{ServerStreams: true}, status.Error(codes.Unavailable, "down"))
+		assert.Error(t, stream.RecvMsg(nil))
+		require.Len(t, w.entries(), 1)
+		assert.Equal(t, "Unavailable", w.entries()[0].Fields["code"])
+		assert.Equal(t, "down", w.entries()[0].Fields["error"])
+	})
+
+	t.Run("abandoned", func(t *testing.T) {
+		w := &mockWriter{}
+		ctx, cancel := context.WithCancel(context.Background())
+		open(ctx, w, &grpc.StreamDesc{ServerStreams: true}, nil)
+		cancel()
+		require.Eventually(t, func() bool { return len(w.entries()) == 1 }, time.Second, 10*time.Millisecond)
+		assert.Equal(t, "Canceled", w.entries()[0].Fields["code"])
+	})
+}
@@ -0,0 +1,69 @@
+// Package logctx 在 context 中传递请求 ID，并从 OpenTelemetry span 中提取 trace_id/span_id，
+// 供 zap hook、slog handler 与 HTTP/gRPC 中间件自动附加到日志条目
+package logctx
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// 日志条目中使用的字段名
+const (
+	TraceIDField   = "trace_id"
+	SpanIDField    = "span_id"
+	RequestIDField = "request_id"
+)
+
+// requestIDKey context 中请求 ID 的键
+type requestIDKey struct{}
+
+// WithRequestID 返回携带请求 ID 的 context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 获取 context 中的请求 ID
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Fields 提取 context 中的 trace_id、span_id 与 request_id
+func Fields(ctx context.Context) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if ctx == nil {
+		return fields
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields[TraceIDField] = sc.TraceID().String()
+		fields[SpanIDField] = sc.SpanID().String()
+	}
+	if id := RequestID(ctx); id != "" {
+		fields[RequestIDField] = id
+	}
+	return fields
+}
+
+// Inject 将 context 中的关联字段写入日志条目，不覆盖已有字段
+func Inject(ctx context.Context, entry *models.LogEntry) {
+	fields := Fields(ctx)
+	if len(fields) == 0 {
+		return
+	}
+	if entry.Fields == nil {
+		entry.Fields = make(map[string]interface{}, len(fields))
+	}
+	for key, value := range fields {
+		if _, ok := entry.Fields[key]; !ok {
+			entry.Fields[key] = value
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package logctx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+func TestInject(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = WithRequestID(ctx, "req-1")
+
+	entry := &models.LogEntry{Fields: map[string]interface{}{"request_id": "explicit"}}
+	Inject(ctx, entry)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entry.Fields["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", entry.Fields["span_id"])
+	assert.Equal(t, "explicit", entry.Fields["request_id"])
+
+	entry = &models.LogEntry{}
+	Inject(context.Background(), entry)
+	assert.Nil(t, entry.Fields)
+
+	assert.Equal(t, map[string]interface{}{"request_id": "req-1"}, Fields(WithRequestID(context.Background(), "req-1")))
+}
@@ -0,0 +1,177 @@
+// Package logs 是在其他 Go 程序中嵌入日志服务的公共 API，
+// 对外暴露存储接口、schema 与日志模型、API 服务器和 schema 管理器。
+// internal 下的包可能随时调整，外部程序应只依赖本包与 pkg 下的其他包
+package logs
+
+import (
+	"context"
+
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+// Storage 存储后端接口
+type Storage = storage.Storage
+
+// LogQuerier 查询日志的可选能力
+type LogQuerier = storage.LogQuerier
+
+// SchemaRecordDeleter 只删除 schema 记录、保留日志表的可选能力
+type SchemaRecordDeleter = storage.SchemaRecordDeleter
+
+// SchemaArchiver 软删除 schema 的可选能力，日志表归档后可恢复
+type SchemaArchiver = storage.SchemaArchiver
+
+// SchemaRenamer 重命名 schema 及其日志表的可选能力
+type SchemaRenamer = storage.SchemaRenamer
+
+// LogMutator 按过滤条件删除或修改已写入日志的可选能力
+type LogMutator = storage.LogMutator
+
+// Roller 将过期原始日志汇总到 rollup 表后删除的可选能力
+type Roller = storage.Roller
+
+// SizeReporter 报告日志表占用存储空间的可选能力
+type SizeReporter = storage.SizeReporter
+
+// QueryExplainer 返回日志查询执行计划的可选能力
+type QueryExplainer = storage.QueryExplainer
+
+// SlowQueryStore 保存慢查询记录的可选能力
+type SlowQueryStore = storage.SlowQueryStore
+
+// FieldValuesQuerier 统计字段取值分布的可选能力
+type FieldValuesQuerier = storage.FieldValuesQuerier
+
+// BaseColumnLister 报告日志表中除 schema 字段外其他基础列的可选能力
+type BaseColumnLister = storage.BaseColumnLister
+
+// IssueStore 保存错误归并问题的可选能力
+type IssueStore = storage.IssueStore
+
+// StorageFactory 根据配置创建存储后端
+type StorageFactory = storage.Factory
+
+// 存储配置
+type (
+	StorageConfig    = storage.Config
+	PostgresConfig   = storage.PostgresConfig
+	TimescaleConfig  = storage.TimescaleConfig
+	MySQLConfig      = storage.MySQLConfig
+	SQLiteConfig     = storage.SQLiteConfig
+	ClickHouseConfig = storage.ClickHouseConfig
+	FileConfig       = storage.FileConfig
+	RetryConfig      = storage.RetryConfig
+	TimeoutConfig    = storage.TimeoutConfig
+)
+
+// 存储错误
+var (
+	// ErrBackendUnavailable 存储后端不可用
+	ErrBackendUnavailable = storage.ErrBackendUnavailable
+	// ErrCircuitOpen 熔断器打开，请求未发送到存储后端
+	ErrCircuitOpen = storage.ErrCircuitOpen
+)
+
+// RegisterStorage 注册名为 name 的存储后端，之后可通过 StorageConfig.Type 使用。
+// 名称为空、factory 为 nil 或重复注册时 panic
+func RegisterStorage(name string, factory StorageFactory) {
+	storage.Register(name, factory)
+}
+
+// RegisteredStorage 返回已注册的存储后端名称
+func RegisteredStorage() []string {
+	return storage.Registered()
+}
+
+// NewStorage 按 config.Type 创建并初始化存储后端
+func NewStorage(ctx context.Context, config StorageConfig) (Storage, error) {
+	return storage.New(ctx, config)
+}
+
+// WithSchemaCache 包装存储，schema 的读取经由 registry 缓存，经由包装器的 schema 变更同步到 registry
+func WithSchemaCache(store Storage, registry *SchemaRegistry) Storage {
+	return storage.WithSchemaCache(store, registry)
+}
+
+// As 返回 store 的可选能力 T，如 As[LogQuerier](store)
+func As[T any](store Storage) (T, bool) {
+	return storage.As[T](store)
+}
+
+// Schema 与日志模型
+type (
+	Schema    = models.Schema
+	Field     = models.Field
+	FieldType = models.FieldType
+	LogEntry  = models.LogEntry
+	Query     = models.Query
+
+	ArchivedSchema = models.ArchivedSchema
+	LogFilter      = models.LogFilter
+	Issue          = models.Issue
+	IssueFilter    = models.IssueFilter
+	IssueStatus    = models.IssueStatus
+	TableSize      = models.TableSize
+	QueryPlan      = models.QueryPlan
+	SlowQuery      = models.SlowQuery
+	FieldValues    = models.FieldValues
+	FieldValue     = models.FieldValue
+
+	SchemaRegistry  = models.SchemaRegistry
+	SchemaEvent     = models.SchemaEvent
+	SchemaEventType = models.SchemaEventType
+)
+
+// schema 变更事件类型
+const (
+	SchemaCreated = models.SchemaCreated
+	SchemaUpdated = models.SchemaUpdated
+	SchemaDeleted = models.SchemaDeleted
+)
+
+// 字段类型
+const (
+	FieldTypeString   = models.FieldTypeString
+	FieldTypeInt      = models.FieldTypeInt
+	FieldTypeFloat    = models.FieldTypeFloat
+	FieldTypeBool     = models.FieldTypeBool
+	FieldTypeDateTime = models.FieldTypeDateTime
+	FieldTypeTime     = models.FieldTypeTime
+	FieldTypeDuration = models.FieldTypeDuration
+	FieldTypeJSON     = models.FieldTypeJSON
+	FieldTypeRest     = models.FieldTypeRest
+	FieldTypeIP       = models.FieldTypeIP
+	FieldTypeObject   = models.FieldTypeObject
+	FieldTypeArray    = models.FieldTypeArray
+)
+
+// 模型错误
+var (
+	// ErrSchemaNotFound schema 不存在
+	ErrSchemaNotFound = models.ErrSchemaNotFound
+	// ErrValidation 日志或 schema 校验失败，可用 errors.Is 判断
+	ErrValidation = models.ErrValidation
+	// ErrMutationLimit 删除或修改日志匹配的条数超过上限
+	ErrMutationLimit = models.ErrMutationLimit
+)
+
+// NewSchemaRegistry 创建并发安全的 schema 注册表，可通过 Subscribe 订阅 schema 变更
+func NewSchemaRegistry() *SchemaRegistry {
+	return models.NewSchemaRegistry()
+}
+
+// NewLogEntry 创建属于 project/table 的日志条目
+func NewLogEntry(project, table string) *LogEntry {
+	return models.NewLogEntry(project, table)
+}
+
+// SchemaFromYAML 从 YAML 内容解析并校验 schema
+func SchemaFromYAML(data []byte) (*Schema, error) {
+	return models.SchemaFromYAML(data)
+}
+
+// LoadSchemaFromFile 从 YAML 文件加载 schema
+func LoadSchemaFromFile(filename string) (*Schema, error) {
+	return models.LoadSchemaFromFile(filename)
+}
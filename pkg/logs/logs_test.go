@@ -0,0 +1,70 @@
+package logs_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/pkg/logs"
+)
+
+func TestEmbed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store, err := logs.NewStorage(ctx, logs.StorageConfig{
+		Type:   "sqlite",
+		SQLite: logs.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "requests.yaml"), []byte(`project: app
+table: requests
+fields:
+  - name: path
+    type: string
+    required: true
+`), 0644))
+	manager, err := logs.NewSchemaManager(store, dir, logs.WithDeletePolicy(logs.DeletePolicyIgnore))
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	defer manager.Stop()
+
+	schema, err := store.GetSchema(ctx, "app", "requests")
+	require.NoError(t, err)
+	assert.Equal(t, logs.FieldTypeString, schema.Fields[0].Type)
+
+	server := logs.NewServer(store, &logs.ServerConfig{SchemaManager: manager, StorageType: "sqlite"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/logs/app/requests",
+		strings.NewReader(`{"level": "info", "message": "hit", "path": "/a"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	entry := logs.NewLogEntry("app", "requests")
+	entry.Level, entry.Message, entry.Timestamp = "info", "direct", time.Now()
+	entry.Fields["path"] = "/b"
+	require.NoError(t, store.InsertLog(ctx, "app", "requests", entry))
+
+	querier, ok := logs.As[logs.LogQuerier](store)
+	require.True(t, ok)
+	rows, err := querier.SearchLogs(ctx, "app", "requests", &logs.Query{Sort: []string{"path"}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "/a", rows[0]["path"])
+	assert.Equal(t, "/b", rows[1]["path"])
+
+	_, err = store.GetSchema(ctx, "app", "missing")
+	assert.ErrorIs(t, err, logs.ErrSchemaNotFound)
+	assert.Contains(t, logs.RegisteredStorage(), "sqlite")
+}
@@ -0,0 +1,19 @@
+// Package logstest 为通过 logs.RegisterStorage 注册的自定义存储后端提供与内置后端相同的一致性测试
+//
+// Deprecated: 使用 pkg.blksails.net/logs/pkg/logs/storagetest
+package logstest
+
+import (
+	"testing"
+
+	"pkg.blksails.net/logs/pkg/logs"
+	"pkg.blksails.net/logs/pkg/logs/storagetest"
+)
+
+// RunStorageConformance 对已初始化的 store 执行一致性测试，测试使用 conformance 项目下的表并在结束时删除。
+// 未实现的可选能力（如 logs.LogQuerier）对应的用例被跳过
+//
+// Deprecated: 使用 storagetest.RunConformanceTests
+func RunStorageConformance(t *testing.T, store logs.Storage) {
+	storagetest.RunConformanceTests(t, func(*testing.T) logs.Storage { return store })
+}
@@ -0,0 +1,22 @@
+package logstest_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/pkg/logs"
+	"pkg.blksails.net/logs/pkg/logs/logstest"
+)
+
+func TestRunStorageConformance(t *testing.T) {
+	store, err := logs.NewStorage(context.Background(), logs.StorageConfig{
+		Type:   "sqlite",
+		SQLite: logs.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	logstest.RunStorageConformance(t, store)
+}
@@ -0,0 +1,74 @@
+package logs
+
+import (
+	"go.uber.org/zap"
+	"pkg.blksails.net/logs/internal/api"
+	"pkg.blksails.net/logs/internal/schema"
+)
+
+// Server HTTP API 服务器，Handler 可挂载到已有的 HTTP 服务中
+type Server = api.Server
+
+// ServerConfig API 服务器配置
+type ServerConfig = api.Config
+
+// NewServer 创建使用 store 的 API 服务器
+func NewServer(store Storage, cfg *ServerConfig) *Server {
+	return api.NewServer(store, cfg)
+}
+
+// SchemaManager 从目录加载 schema YAML 文件并监听变更
+type SchemaManager = schema.Manager
+
+// SchemaManagerOption 配置 SchemaManager
+type SchemaManagerOption = schema.Option
+
+// SchemaManagerStatus schema 管理器的当前状态
+type SchemaManagerStatus = schema.Status
+
+// ConflictPolicy 多个文件声明同一 schema 时的处理策略
+type ConflictPolicy = schema.ConflictPolicy
+
+// DeletePolicy schema 文件被删除时对存储的处理策略
+type DeletePolicy = schema.DeletePolicy
+
+// 冲突与删除策略
+const (
+	ConflictPolicyError      = schema.ConflictPolicyError
+	ConflictPolicyFirstWins  = schema.ConflictPolicyFirstWins
+	ConflictPolicyNewestWins = schema.ConflictPolicyNewestWins
+
+	DeletePolicyIgnore     = schema.DeletePolicyIgnore
+	DeletePolicySoftDelete = schema.DeletePolicySoftDelete
+	DeletePolicyDropTable  = schema.DeletePolicyDropTable
+)
+
+// NewSchemaManager 创建 schema 管理器，调用 Start 后加载 dir 中的文件并开始监听
+func NewSchemaManager(store Storage, dir string, opts ...SchemaManagerOption) (*SchemaManager, error) {
+	return schema.NewManager(store, dir, opts...)
+}
+
+// WithConflictPolicy 设置 schema 文件冲突策略
+func WithConflictPolicy(policy ConflictPolicy) SchemaManagerOption {
+	return schema.WithConflictPolicy(policy)
+}
+
+// WithDeletePolicy 设置 schema 文件被删除时对存储的处理策略
+func WithDeletePolicy(policy DeletePolicy) SchemaManagerOption {
+	return schema.WithDeletePolicy(policy)
+}
+
+// WithWriteBack 开启后通过 API 修改的 schema 会写回目录中的 YAML 文件
+func WithWriteBack(enabled bool) SchemaManagerOption {
+	return schema.WithWriteBack(enabled)
+}
+
+// WithRegistry 设置 schema 管理器保存 schema 的注册表，可与 ServerConfig.Schemas 共享
+func WithRegistry(registry *SchemaRegistry) SchemaManagerOption {
+	return schema.WithRegistry(registry)
+}
+
+// WithLogger 设置 schema 管理器的日志记录器，为空时使用全局 logger
+func WithLogger(logger *zap.Logger) SchemaManagerOption {
+	return schema.WithLogger(logger)
+}
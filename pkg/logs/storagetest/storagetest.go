@@ -0,0 +1,23 @@
+// Package storagetest 为通过 logs.RegisterStorage 注册的自定义存储后端提供与内置后端相同的一致性测试
+package storagetest
+
+import (
+	"testing"
+
+	"pkg.blksails.net/logs/internal/storage"
+	"pkg.blksails.net/logs/internal/storage/storagetest"
+	"pkg.blksails.net/logs/pkg/logs"
+)
+
+// Project 一致性测试使用的项目，用例在其中创建的表在用例结束时删除
+const Project = storagetest.Project
+
+// Factory 为一个用例返回已初始化的存储，可以每次创建新的实例，也可以复用同一个实例；
+// 需要关闭的存储应通过 t.Cleanup 注册
+type Factory func(t *testing.T) logs.Storage
+
+// RunConformanceTests 执行一致性测试，覆盖 schema 的增删改查、写入、查询、校验、Rest 字段与并发写入。
+// 未实现的可选能力（如 logs.LogQuerier）对应的用例被跳过
+func RunConformanceTests(t *testing.T, factory Factory) {
+	storagetest.RunConformanceTests(t, func(t *testing.T) storage.Storage { return factory(t) })
+}
@@ -0,0 +1,23 @@
+package storagetest_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/pkg/logs"
+	"pkg.blksails.net/logs/pkg/logs/storagetest"
+)
+
+func TestRunConformanceTests(t *testing.T) {
+	storagetest.RunConformanceTests(t, func(t *testing.T) logs.Storage {
+		store, err := logs.NewStorage(context.Background(), logs.StorageConfig{
+			Type:   "sqlite",
+			SQLite: logs.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
@@ -0,0 +1,134 @@
+package slog
+
+import (
+	"context"
+	stdslog "log/slog"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/logctx"
+)
+
+// Writer 接收日志条目，pkg/zap 中带缓冲的 Hook 实现了该接口
+type Writer interface {
+	WriteEntry(log *models.LogEntry) error
+}
+
+// HandlerOptions Handler 配置
+type HandlerOptions struct {
+	// Level 最低记录级别，默认 Info
+	Level stdslog.Leveler
+}
+
+// Handler 实现 slog.Handler 接口，将日志写入存储，
+// 并自动附加 context 中的 trace_id、span_id 与 request_id
+type Handler struct {
+	w      Writer
+	level  stdslog.Leveler
+	attrs  []stdslog.Attr
+	prefix string
+}
+
+// NewHandler 创建新的 slog Handler
+func NewHandler(w Writer, opts *HandlerOptions) *Handler {
+	h := &Handler{w: w, level: stdslog.LevelInfo}
+	if opts != nil && opts.Level != nil {
+		h.level = opts.Level
+	}
+	return h
+}
+
+// Enabled 实现 slog.Handler 接口
+func (h *Handler) Enabled(_ context.Context, level stdslog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle 实现 slog.Handler 接口
+func (h *Handler) Handle(ctx context.Context, r stdslog.Record) error {
+	log := &models.LogEntry{
+		Level:     levelString(r.Level),
+		Message:   r.Message,
+		Timestamp: r.Time,
+		Fields:    make(map[string]interface{}),
+	}
+
+	for _, attr := range h.attrs {
+		addAttr(log.Fields, "", attr)
+	}
+	r.Attrs(func(attr stdslog.Attr) bool {
+		addAttr(log.Fields, h.prefix, attr)
+		return true
+	})
+	logctx.Inject(ctx, log)
+
+	return h.w.WriteEntry(log)
+}
+
+// WithAttrs 实现 slog.Handler 接口
+func (h *Handler) WithAttrs(attrs []stdslog.Attr) stdslog.Handler {
+	clone := *h
+	clone.attrs = make([]stdslog.Attr, 0, len(h.attrs)+len(attrs))
+	clone.attrs = append(clone.attrs, h.attrs...)
+	for _, attr := range attrs {
+		if h.prefix != "" {
+			attr.Key = h.prefix + attr.Key
+		}
+		clone.attrs = append(clone.attrs, attr)
+	}
+	return &clone
+}
+
+// WithGroup 实现 slog.Handler 接口，组内字段使用 "组名." 前缀
+func (h *Handler) WithGroup(name string) stdslog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// addAttr 将属性写入字段，分组属性展开为带前缀的键
+func addAttr(fields map[string]interface{}, prefix string, attr stdslog.Attr) {
+	value := attr.Value.Resolve()
+	if attr.Equal(stdslog.Attr{}) {
+		return
+	}
+
+	switch value.Kind() {
+	case stdslog.KindGroup:
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix = prefix + attr.Key + "."
+		}
+		for _, a := range value.Group() {
+			addAttr(fields, groupPrefix, a)
+		}
+	case stdslog.KindTime:
+		fields[prefix+attr.Key] = value.Time().Format(time.RFC3339Nano)
+	case stdslog.KindDuration:
+		fields[prefix+attr.Key] = value.Duration().String()
+	case stdslog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			fields[prefix+attr.Key] = err.Error()
+		} else {
+			fields[prefix+attr.Key] = value.Any()
+		}
+	default:
+		fields[prefix+attr.Key] = value.Any()
+	}
+}
+
+// levelString 转换日志级别
+func levelString(level stdslog.Level) string {
+	switch {
+	case level >= stdslog.LevelError:
+		return "error"
+	case level >= stdslog.LevelWarn:
+		return "warn"
+	case level >= stdslog.LevelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
@@ -0,0 +1,48 @@
+package slog
+
+import (
+	"context"
+	"errors"
+	stdslog "log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/logctx"
+)
+
+type mockWriter struct {
+	logs []*models.LogEntry
+}
+
+func (m *mockWriter) WriteEntry(log *models.LogEntry) error {
+	m.logs = append(m.logs, log)
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	w := &mockWriter{}
+	logger := stdslog.New(NewHandler(w, nil)).With("service", "api").WithGroup("http")
+
+	ctx := logctx.WithRequestID(context.Background(), "req-1")
+	logger.DebugContext(ctx, "ignored")
+	logger.WarnContext(ctx, "slow request",
+		"status", 200,
+		"latency", 1500*time.Millisecond,
+		"err", errors.New("timeout"),
+		stdslog.Group("client", "ip", "127.0.0.1"),
+	)
+
+	require.Len(t, w.logs, 1)
+	log := w.logs[0]
+	assert.Equal(t, "warn", log.Level)
+	assert.Equal(t, "slow request", log.Message)
+	assert.Equal(t, "api", log.Fields["service"])
+	assert.Equal(t, int64(200), log.Fields["http.status"])
+	assert.Equal(t, "1.5s", log.Fields["http.latency"])
+	assert.Equal(t, "timeout", log.Fields["http.err"])
+	assert.Equal(t, "127.0.0.1", log.Fields["http.client.ip"])
+	assert.Equal(t, "req-1", log.Fields["request_id"])
+}
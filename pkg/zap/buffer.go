@@ -0,0 +1,229 @@
+package zap
+
+import (
+	"errors"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// ErrBufferFull 缓冲区超出上限时丢弃日志使用的错误
+var ErrBufferFull = errors.New("hook buffer full")
+
+// 缓冲区超出 MaxBufferEntries 或 MaxBufferBytes 时的处理策略
+const (
+	OverflowDropOldest = "drop-oldest" // 丢弃最旧的日志
+	OverflowDropNewest = "drop-newest" // 丢弃新写入的日志
+	OverflowBlock      = "block"       // 阻塞写入直到缓冲区有空间，超过 BlockTimeout 后丢弃新日志
+)
+
+const (
+	// defaultBlockTimeout block 策略的默认等待时间
+	defaultBlockTimeout = time.Second
+	// entryOverhead 日志条目结构体与 map 的估算开销
+	entryOverhead = 256
+	// valueOverhead 单个字段值的估算开销
+	valueOverhead = 16
+)
+
+// HookStats Hook 的缓冲区与刷新统计
+type HookStats struct {
+	Project       string `json:"project"`
+	Table         string `json:"table"`
+	Buffered      int    `json:"buffered"`       // 缓冲区中的日志数
+	BufferedBytes int64  `json:"buffered_bytes"` // 缓冲区中日志的估算内存
+	Flushing      int    `json:"flushing"`       // 正在写入存储的日志数，与 Buffered 之和为尚未写入的日志数
+	Dropped       uint64 `json:"dropped"`        // 因缓冲区溢出或刷新失败被丢弃的日志数
+	Flushed       uint64 `json:"flushed"`        // 已写入存储的日志数
+	FlushFailures uint64 `json:"flush_failures"` // 重试用尽后仍失败的刷新次数
+
+	// Oldest 最早一条尚未写入存储的日志进入缓冲区的时间，全部写入时为 nil。
+	// 与当前时间的差即写入落后的时长，持续增长说明存储跟不上写入或一直失败
+	Oldest *time.Time `json:"oldest,omitempty"`
+	// OldestAge 按 Hook 的时间源计算的 Oldest 至今的时长，全部写入时为 0
+	OldestAge time.Duration `json:"-"`
+
+	LastFlush        *time.Time `json:"last_flush,omitempty"`          // 最近一次成功写入存储的时间
+	LastFlushError   string     `json:"last_flush_error,omitempty"`    // 最近一次刷新失败的错误，之后的成功刷新不会清除
+	LastFlushErrorAt *time.Time `json:"last_flush_error_at,omitempty"` // 最近一次刷新失败的时间
+}
+
+// Stats 返回 Hook 的统计信息
+func (h *Hook) Stats() HookStats {
+	now := h.clock.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stats := HookStats{
+		Project:       h.project,
+		Table:         h.table,
+		Buffered:      len(h.buffer),
+		BufferedBytes: h.bufBytes,
+		Flushing:      h.flushing,
+		Dropped:       h.dropped.Load(),
+		Flushed:       h.flushedLogs.Load(),
+		FlushFailures: h.flushFailures.Load(),
+		LastFlush:     timePtr(h.lastFlush),
+	}
+	oldest := h.oldest
+	if !h.flushingSince.IsZero() && (oldest.IsZero() || h.flushingSince.Before(oldest)) {
+		oldest = h.flushingSince
+	}
+	if !oldest.IsZero() {
+		stats.Oldest = &oldest
+		stats.OldestAge = max(now.Sub(oldest), 0)
+	}
+	if h.lastErr != nil {
+		stats.LastFlushError = h.lastErr.Error()
+		stats.LastFlushErrorAt = timePtr(h.lastErrAt)
+	}
+	return stats
+}
+
+// timePtr 返回 t 的指针，零值返回 nil
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// entrySize 估算日志条目占用的内存
+func entrySize(log *models.LogEntry) int64 {
+	size := int64(entryOverhead + len(log.ID) + len(log.Project) + len(log.Table) + len(log.Level) + len(log.Message) + len(log.IP))
+	for key, value := range log.Fields {
+		size += int64(len(key)) + valueSize(value)
+	}
+	for key, value := range log.Tags {
+		size += int64(len(key) + len(value) + 2*valueOverhead)
+	}
+	return size
+}
+
+// valueSize 估算字段值占用的内存
+func valueSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case string:
+		return int64(valueOverhead + len(v))
+	case []byte:
+		return int64(valueOverhead + len(v))
+	case map[string]interface{}:
+		size := int64(valueOverhead)
+		for key, item := range v {
+			size += int64(len(key)) + valueSize(item)
+		}
+		return size
+	case []interface{}:
+		size := int64(valueOverhead)
+		for _, item := range v {
+			size += valueSize(item)
+		}
+		return size
+	default:
+		return valueOverhead
+	}
+}
+
+// fits 判断缓冲区能否再容纳一条大小为 size 的日志，空缓冲区总能容纳一条，调用方需持有 h.mu
+func (h *Hook) fits(size int64) bool {
+	if len(h.buffer) == 0 {
+		return true
+	}
+	if len(h.buffer) >= h.maxEntries {
+		return false
+	}
+	return h.maxBytes <= 0 || h.bufBytes+size <= h.maxBytes
+}
+
+// overLimit 判断缓冲区是否超出上限，调用方需持有 h.mu
+func (h *Hook) overLimit() bool {
+	if len(h.buffer) <= 1 {
+		return false
+	}
+	return len(h.buffer) > h.maxEntries || (h.maxBytes > 0 && h.bufBytes > h.maxBytes)
+}
+
+// reserve 按溢出策略为大小为 size 的新日志腾出空间，返回被丢弃的旧日志；
+// 新日志应被丢弃时返回 ErrBufferFull。block 策略等待期间会释放 h.mu，调用方需持有 h.mu
+func (h *Hook) reserve(size int64) ([]*models.LogEntry, error) {
+	if h.fits(size) {
+		return nil, nil
+	}
+
+	switch h.overflow {
+	case OverflowDropNewest:
+		return nil, ErrBufferFull
+	case OverflowBlock:
+		expired := make(chan struct{})
+		timer := h.clock.AfterFunc(h.blockTimeout, func() { close(expired) })
+		defer timer.Stop()
+		for !h.fits(size) {
+			// 通知定期刷新立即执行，而不是等到下一个周期
+			h.requestFlush()
+			space := h.space
+			h.mu.Unlock()
+			select {
+			case <-space:
+				h.mu.Lock()
+			case <-expired:
+				h.mu.Lock()
+				if h.fits(size) {
+					return nil, nil
+				}
+				return nil, ErrBufferFull
+			case <-h.done:
+				h.mu.Lock()
+				return nil, ErrBufferFull
+			}
+		}
+		return nil, nil
+	default:
+		var dropped []*models.LogEntry
+		for !h.fits(size) {
+			dropped = append(dropped, h.buffer[0])
+			h.bufBytes -= entrySize(h.buffer[0])
+			h.buffer = h.buffer[1:]
+		}
+		if len(h.buffer) == 0 {
+			h.oldest = time.Time{}
+		}
+		return dropped, nil
+	}
+}
+
+// trim 将缓冲区裁剪到上限以内并返回被丢弃的日志。drop-oldest 策略从头部丢弃，
+// 其他策略保留先进入缓冲区的日志、从尾部丢弃。调用方需持有 h.mu
+func (h *Hook) trim() []*models.LogEntry {
+	var dropped []*models.LogEntry
+	for h.overLimit() {
+		i := len(h.buffer) - 1
+		if h.overflow == OverflowDropOldest {
+			i = 0
+		}
+		dropped = append(dropped, h.buffer[i])
+		h.bufBytes -= entrySize(h.buffer[i])
+		if i == 0 {
+			h.buffer = h.buffer[1:]
+		} else {
+			h.buffer = h.buffer[:i]
+		}
+	}
+	return dropped
+}
+
+// freed 通知阻塞的写入缓冲区已有空间，调用方需持有 h.mu
+func (h *Hook) freed() {
+	close(h.space)
+	h.space = make(chan struct{})
+}
+
+// drop 记录并回调被丢弃的日志
+func (h *Hook) drop(logs []*models.LogEntry, err error) {
+	if len(logs) == 0 {
+		return
+	}
+	h.dropped.Add(uint64(len(logs)))
+	if h.onError != nil {
+		h.onError(logs, err)
+	}
+}
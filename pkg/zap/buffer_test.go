@@ -0,0 +1,141 @@
+package zap
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+// blockingStorage 批量写入阻塞直到 release 关闭
+type blockingStorage struct {
+	mockStorage
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	b.started <- struct{}{}
+	<-b.release
+	return nil
+}
+
+func bufferedMessages(h *Hook) []interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var messages []interface{}
+	for _, log := range h.buffer {
+		messages = append(messages, log.Fields["message"])
+	}
+	return messages
+}
+
+func TestHook_OverflowDropOldest(t *testing.T) {
+	var dropped []*models.LogEntry
+	var dropErr error
+	hook, err := NewHook(&mockStorage{}, &Config{
+		Project: "p", Table: "t", BufferSize: 10, MaxBufferEntries: 2, FlushPeriod: time.Hour,
+		OnFlushError: func(logs []*models.LogEntry, err error) { dropped, dropErr = logs, err },
+	})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	writeMessages(t, hook, "a", "b", "c")
+	if assert.Len(t, dropped, 1) {
+		assert.Equal(t, "a", dropped[0].Fields["message"])
+	}
+	assert.ErrorIs(t, dropErr, ErrBufferFull)
+	assert.Equal(t, []interface{}{"b", "c"}, bufferedMessages(hook))
+	assert.Equal(t, uint64(1), hook.Stats().Dropped)
+}
+
+func TestHook_OverflowDropNewest(t *testing.T) {
+	hook, err := NewHook(&mockStorage{}, &Config{Project: "p", Table: "t", BufferSize: 10, MaxBufferEntries: 2, FlushPeriod: time.Hour, Overflow: OverflowDropNewest})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	writeMessages(t, hook, "a", "b")
+	assert.ErrorIs(t, hook.WriteEntry(&models.LogEntry{Fields: map[string]interface{}{"message": "c"}}), ErrBufferFull)
+	assert.Equal(t, []interface{}{"a", "b"}, bufferedMessages(hook))
+
+	stats := hook.Stats()
+	assert.Equal(t, 2, stats.Buffered)
+	assert.Equal(t, uint64(1), stats.Dropped)
+}
+
+func TestHook_MaxBufferBytes(t *testing.T) {
+	large := &models.LogEntry{Project: "p", Table: "t", Fields: map[string]interface{}{"message": "big", "payload": strings.Repeat("x", 1000)}}
+	hook, err := NewHook(&mockStorage{}, &Config{Project: "p", Table: "t", BufferSize: 10, MaxBufferBytes: 2 * entrySize(large), FlushPeriod: time.Hour})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	// 字节上限先于条数上限生效
+	for i := 0; i < 3; i++ {
+		require.NoError(t, hook.WriteEntry(&models.LogEntry{Fields: map[string]interface{}{"message": "big", "payload": strings.Repeat("x", 1000)}}))
+	}
+	stats := hook.Stats()
+	assert.Equal(t, 2, stats.Buffered)
+	assert.LessOrEqual(t, stats.BufferedBytes, 2*entrySize(large))
+	assert.Equal(t, uint64(1), stats.Dropped)
+
+	// 超过上限的单条日志在空缓冲区中仍能写入
+	require.NoError(t, hook.Flush())
+	require.NoError(t, hook.WriteEntry(&models.LogEntry{Fields: map[string]interface{}{"payload": strings.Repeat("x", 10000)}}))
+	assert.Equal(t, 1, hook.Stats().Buffered)
+	assert.Equal(t, uint64(2), hook.Stats().Flushed)
+}
+
+func TestHook_OverflowBlock(t *testing.T) {
+	storage := &mockStorage{batches: make(chan []*models.LogEntry, 1)}
+	hook, err := NewHook(storage, &Config{Project: "p", Table: "t", BufferSize: 10, MaxBufferEntries: 1, FlushPeriod: time.Hour, Overflow: OverflowBlock, BlockTimeout: time.Minute})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	// 阻塞的写入触发立即刷新，腾出空间后写入成功
+	writeMessages(t, hook, "a", "b")
+	batch := <-storage.batches
+	if assert.Len(t, batch, 1) {
+		assert.Equal(t, "a", batch[0].Fields["message"])
+	}
+	assert.Equal(t, []interface{}{"b"}, bufferedMessages(hook))
+	assert.Zero(t, hook.Stats().Dropped)
+}
+
+func TestHook_OverflowBlockTimeout(t *testing.T) {
+	mock := clock.NewMock(time.Now())
+	storage := &blockingStorage{started: make(chan struct{}, 1), release: make(chan struct{})}
+	hook, err := NewHook(storage, &Config{
+		Project: "p", Table: "t", BufferSize: 10, MaxBufferEntries: 1, FlushPeriod: time.Second,
+		Overflow: OverflowBlock, BlockTimeout: 500 * time.Millisecond, Clock: mock,
+	})
+	require.NoError(t, err)
+
+	// 定期刷新卡在写入存储时，缓冲区无法腾出空间
+	writeMessages(t, hook, "a")
+	mock.BlockUntil(1)
+	mock.Add(time.Second)
+	<-storage.started
+	writeMessages(t, hook, "b")
+
+	errc := make(chan error, 1)
+	go func() { errc <- hook.WriteEntry(&models.LogEntry{Fields: map[string]interface{}{"message": "c"}}) }()
+	mock.BlockUntil(2)
+	mock.Add(500 * time.Millisecond)
+	assert.ErrorIs(t, <-errc, ErrBufferFull)
+	assert.Equal(t, []interface{}{"b"}, bufferedMessages(hook))
+	assert.Equal(t, uint64(1), hook.Stats().Dropped)
+
+	close(storage.release)
+	assert.NoError(t, hook.Close())
+	assert.Equal(t, uint64(2), hook.Stats().Flushed)
+}
+
+func TestNewHook_InvalidOverflow(t *testing.T) {
+	_, err := NewHook(&mockStorage{}, &Config{Overflow: "spill"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package zap
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// encodeFields 使用 zapcore.MapObjectEncoder 编码字段并写入 log.Fields，
+// 支持对象、数组与命名空间，返回 Context 字段携带的 context
+func encodeFields(log *models.LogEntry, fields []zapcore.Field) context.Context {
+	enc := zapcore.NewMapObjectEncoder()
+	var parent context.Context
+	for _, field := range fields {
+		if field.Type == zapcore.SkipType {
+			if ctx := injectContext(field, log); ctx != nil {
+				parent = ctx
+			}
+			continue
+		}
+		field.AddTo(enc)
+	}
+	for key, value := range enc.Fields {
+		log.Fields[key] = normalizeValue(value)
+	}
+	return parent
+}
+
+// normalizeValue 将编码结果转换为存储可以直接保存的类型：
+// 整数统一为 int64（超出范围的无符号整数为 float64），浮点数为 float64，
+// 时长为 int64 纳秒（与存储中 duration 字段的格式一致），复数为字符串，时间为 RFC3339 字符串，二进制为 base64 字符串
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// 复制而不是原地修改，zap.Any 传入的 map 属于调用方
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = normalizeValue(item)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalizeValue(item)
+		}
+		return items
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return normalizeUint(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return normalizeUint(v)
+	case uintptr:
+		return normalizeUint(uint64(v))
+	case float32:
+		return float64(v)
+	case complex64, complex128:
+		return fmt.Sprint(v)
+	case time.Duration:
+		return int64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	default:
+		return value
+	}
+}
+
+// normalizeUint 超出 int64 范围的无符号整数转换为 float64
+func normalizeUint(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return float64(v)
+	}
+	return int64(v)
+}
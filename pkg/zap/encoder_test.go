@@ -0,0 +1,90 @@
+package zap
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+func (u user) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", u.Name)
+	enc.AddInt("age", u.Age)
+	return nil
+}
+
+func TestEncodeFields(t *testing.T) {
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	log := &models.LogEntry{Fields: map[string]interface{}{}}
+	encodeFields(log, []zapcore.Field{
+		zap.Float64("f64", 3.14),
+		zap.Float32("f32", 1.5),
+		zap.Uint64("u64", math.MaxUint64),
+		zap.Uint8("u8", 7),
+		zap.Duration("dur", 1500*time.Millisecond),
+		zap.Time("time", tm),
+		zap.Binary("bin", []byte("hi")),
+		zap.ByteString("bytes", []byte("text")),
+		zap.Complex128("c", complex(1, 2)),
+		zap.Error(errors.New("boom")),
+		zap.Object("user", user{Name: "alice", Age: 30}),
+		zap.Ints("ids", []int{1, 2}),
+		zap.Objects("users", []user{{Name: "bob", Age: 40}}),
+		zap.Namespace("ns"),
+		zap.String("inner", "v"),
+	})
+
+	assert.Equal(t, 3.14, log.Fields["f64"])
+	assert.Equal(t, 1.5, log.Fields["f32"])
+	assert.Equal(t, float64(math.MaxUint64), log.Fields["u64"])
+	assert.Equal(t, int64(7), log.Fields["u8"])
+	assert.Equal(t, int64(1500*time.Millisecond), log.Fields["dur"])
+	assert.Equal(t, tm.Format(time.RFC3339Nano), log.Fields["time"])
+	assert.Equal(t, "aGk=", log.Fields["bin"])
+	assert.Equal(t, "text", log.Fields["bytes"])
+	assert.Equal(t, "(1+2i)", log.Fields["c"])
+	assert.Equal(t, "boom", log.Fields["error"])
+	assert.Equal(t, map[string]interface{}{"name": "alice", "age": int64(30)}, log.Fields["user"])
+	assert.Equal(t, []interface{}{int64(1), int64(2)}, log.Fields["ids"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "bob", "age": int64(40)}}, log.Fields["users"])
+	assert.Equal(t, map[string]interface{}{"inner": "v"}, log.Fields["ns"])
+	assert.NotContains(t, log.Fields, "inner")
+}
+
+func TestEncodeFields_DoesNotMutateReflected(t *testing.T) {
+	value := map[string]interface{}{"n": 1}
+	log := &models.LogEntry{Fields: map[string]interface{}{}}
+	encodeFields(log, []zapcore.Field{zap.Any("m", value)})
+
+	assert.Equal(t, map[string]interface{}{"n": int64(1)}, log.Fields["m"])
+	assert.Equal(t, 1, value["n"])
+}
+
+func TestCore_WithFields(t *testing.T) {
+	hook, err := NewHook(&mockStorage{}, &Config{Project: "p", Table: "t", FlushPeriod: time.Hour})
+	assert.NoError(t, err)
+	defer hook.Close()
+
+	core := NewCore(hook, zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.DebugLevel)
+	logger := zap.New(core).With(zap.String("service", "api"))
+	logger.Info("hello", zap.Float64("latency", 0.25))
+
+	messages := bufferedMessages(hook)
+	if assert.Equal(t, []interface{}{"hello"}, messages) {
+		hook.mu.Lock()
+		log := hook.buffer[0]
+		hook.mu.Unlock()
+		assert.Equal(t, "api", log.Fields["service"])
+		assert.Equal(t, 0.25, log.Fields["latency"])
+	}
+}
@@ -2,16 +2,53 @@ package zap
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap/zapcore"
 	"pkg.blksails.net/logs/internal/models"
 	"pkg.blksails.net/logs/internal/storage"
+	"pkg.blksails.net/logs/pkg/clock"
+	"pkg.blksails.net/logs/pkg/logctx"
 )
 
+// contextFieldKey Context 字段使用的键
+const contextFieldKey = "context"
+
+// Context 返回携带 context 的字段，写入存储时会附加其中的 trace_id、span_id 与 request_id，
+// 其他编码器会忽略该字段
+func Context(ctx context.Context) zapcore.Field {
+	return zapcore.Field{Key: contextFieldKey, Type: zapcore.SkipType, Interface: ctx}
+}
+
+// defaultWriteTimeout 写入存储的默认超时时间
+const defaultWriteTimeout = 5 * time.Second
+
+// injectContext 将 Context 字段中的关联信息写入日志条目，返回字段携带的 context
+func injectContext(field zapcore.Field, log *models.LogEntry) context.Context {
+	if ctx, ok := field.Interface.(context.Context); ok && field.Key == contextFieldKey {
+		logctx.Inject(ctx, log)
+		return ctx
+	}
+	return nil
+}
+
+// writeContext 返回写入存储使用的 context：保留 Context 字段中的值，但不随请求取消，
+// 避免请求结束后日志丢失；写入受 timeout 约束
+func writeContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if timeout <= 0 {
+		timeout = defaultWriteTimeout
+	}
+	return context.WithTimeout(context.WithoutCancel(parent), timeout)
+}
+
 // StorageHook 实现 zap 的 Core 接口
 type StorageHook struct {
 	storage  storage.Storage
@@ -19,6 +56,7 @@ type StorageHook struct {
 	table    string
 	fields   []zapcore.Field
 	minLevel zapcore.Level
+	timeout  time.Duration
 }
 
 // StorageHookConfig 配置
@@ -27,6 +65,7 @@ type StorageHookConfig struct {
 	Project  string
 	Table    string
 	MinLevel zapcore.Level
+	Timeout  time.Duration // 单条日志写入存储的超时时间，默认 5s
 }
 
 // NewStorageHook 创建新的存储 hook
@@ -36,6 +75,7 @@ func NewStorageHook(config StorageHookConfig) *StorageHook {
 		project:  config.Project,
 		table:    config.Table,
 		minLevel: config.MinLevel,
+		timeout:  config.Timeout,
 		fields:   make([]zapcore.Field, 0),
 	}
 }
@@ -48,7 +88,8 @@ func (h *StorageHook) Enabled(level zapcore.Level) bool {
 // With 实现 zapcore.Core 接口
 func (h *StorageHook) With(fields []zapcore.Field) zapcore.Core {
 	clone := *h
-	clone.fields = append(clone.fields, fields...)
+	// 限制容量，避免兄弟 logger 共享底层数组
+	clone.fields = append(h.fields[:len(h.fields):len(h.fields)], fields...)
 	return &clone
 }
 
@@ -66,6 +107,8 @@ func (h *StorageHook) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 	log := &models.LogEntry{
 		Project:   h.project,
 		Table:     h.table,
+		Level:     ent.Level.String(),
+		Message:   ent.Message,
 		Timestamp: ent.Time,
 		Fields:    make(map[string]interface{}),
 	}
@@ -83,31 +126,12 @@ func (h *StorageHook) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 	}
 
 	// 添加自定义字段
-	allFields := append(h.fields, fields...)
-	for _, field := range allFields {
-		switch field.Type {
-		case zapcore.StringType:
-			log.Fields[field.Key] = field.String
-		case zapcore.BoolType:
-			log.Fields[field.Key] = field.Integer == 1
-		case zapcore.Int8Type, zapcore.Int16Type, zapcore.Int32Type, zapcore.Int64Type,
-			zapcore.Uint8Type, zapcore.Uint16Type, zapcore.Uint32Type, zapcore.Uint64Type:
-			log.Fields[field.Key] = field.Integer
-		case zapcore.Float32Type, zapcore.Float64Type:
-			log.Fields[field.Key] = math.Float64frombits(uint64(field.Integer))
-		case zapcore.DurationType:
-			log.Fields[field.Key] = time.Duration(field.Integer).String()
-		case zapcore.TimeType:
-			log.Fields[field.Key] = time.Unix(0, field.Integer).Format(time.RFC3339Nano)
-		case zapcore.ErrorType:
-			log.Fields[field.Key] = field.Interface.(error).Error()
-		case zapcore.ReflectType:
-			log.Fields[field.Key] = field.Interface
-		}
-	}
+	parent := encodeFields(log, append(h.fields[:len(h.fields):len(h.fields)], fields...))
 
 	// 存储日志
-	if err := h.storage.InsertLog(context.Background(), h.project, h.table, log); err != nil {
+	ctx, cancel := writeContext(parent, h.timeout)
+	defer cancel()
+	if err := h.storage.InsertLog(ctx, h.project, h.table, log); err != nil {
 		return fmt.Errorf("存储日志失败: %w", err)
 	}
 
@@ -119,16 +143,52 @@ func (h *StorageHook) Sync() error {
 	return nil
 }
 
+// 刷新失败后对整批日志的处理策略
+const (
+	FlushFailureDrop    = "drop"    // 丢弃整批日志
+	FlushFailureRequeue = "requeue" // 放回缓冲区头部，等待下次刷新
+)
+
 // Hook 实现 Zap 日志钩子
 type Hook struct {
-	storage  storage.Storage
-	project  string
-	table    string
-	buffer   []*models.LogEntry
-	bufSize  int
-	interval time.Duration
-	mu       sync.Mutex
-	done     chan struct{}
+	storage      storage.Storage
+	project      string
+	table        string
+	buffer       []*models.LogEntry
+	bufBytes     int64
+	bufSize      int
+	maxEntries   int
+	maxBytes     int64
+	overflow     string
+	blockTimeout time.Duration
+	interval     time.Duration
+	timeout      time.Duration
+	retries      int
+	backoff      time.Duration
+	onFailure    string
+	onError      func(logs []*models.LogEntry, err error)
+	errHandler   func(err error)
+	clock        clock.Clock
+	wal          *wal
+	mu           sync.Mutex
+	flushMu      sync.Mutex // 串行化刷新，Sync 返回前等待进行中的刷新完成
+	space        chan struct{}
+	flushNow     chan struct{}
+	learnN       int // 大于 0 时处于 schema 推断模式，访问需持有 flushMu
+	done         chan struct{}
+	stopped      chan struct{}
+
+	dropped       atomic.Uint64
+	flushedLogs   atomic.Uint64
+	flushFailures atomic.Uint64
+
+	// 以下字段访问需持有 h.mu
+	oldest        time.Time // 缓冲区中最早的日志进入缓冲区的时间，缓冲区为空时为零值
+	flushing      int       // 正在写入存储的日志数
+	flushingSince time.Time // 正在写入的批次中最早的日志进入缓冲区的时间
+	lastFlush     time.Time // 最近一次成功写入存储的时间
+	lastErr       error     // 最近一次刷新失败的错误
+	lastErrAt     time.Time
 }
 
 // Config Hook 配置
@@ -137,6 +197,24 @@ type Config struct {
 	Table       string
 	BufferSize  int
 	FlushPeriod time.Duration
+	Timeout     time.Duration   // 每次刷新写入存储的超时时间，默认 5s
+	Clock       clock.Clock     // 定期刷新使用的时间源，默认系统时间
+	WAL         WALConfig       // 缓冲区的预写日志，默认不启用
+	Provision   ProvisionConfig // 目标 schema 不存在时自动创建，默认不启用
+
+	FlushRetries int           // 刷新失败后的重试次数，默认不重试
+	RetryBackoff time.Duration // 首次重试前的等待时间，之后每次翻倍，默认 100ms
+	OnFailure    string        // 重试用尽后的处理策略：drop 或 requeue，默认 drop
+	// OnFlushError 日志被丢弃时调用，logs 为 drop 策略下的整批日志或缓冲区溢出丢弃的日志
+	OnFlushError func(logs []*models.LogEntry, err error)
+
+	MaxBufferEntries int           // 缓冲区最多容纳的日志数，默认 10 倍 BufferSize
+	MaxBufferBytes   int64         // 缓冲区日志的估算内存上限，默认不限制
+	Overflow         string        // 超出上限时的策略：drop-oldest、drop-newest 或 block，默认 drop-oldest
+	BlockTimeout     time.Duration // block 策略的最长等待时间，默认 1s
+
+	// ErrorHandler 处理后台定期刷新与 WAL 同步的错误，默认输出到标准错误
+	ErrorHandler func(err error)
 }
 
 // NewHook 创建新的 Zap 日志钩子
@@ -147,15 +225,74 @@ func NewHook(storage storage.Storage, cfg *Config) (*Hook, error) {
 	if cfg.FlushPeriod <= 0 {
 		cfg.FlushPeriod = 5 * time.Second
 	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBufferEntries <= 0 {
+		cfg.MaxBufferEntries = 10 * cfg.BufferSize
+	}
+	if cfg.BlockTimeout <= 0 {
+		cfg.BlockTimeout = defaultBlockTimeout
+	}
+	switch cfg.Overflow {
+	case "":
+		cfg.Overflow = OverflowDropOldest
+	case OverflowDropOldest, OverflowDropNewest, OverflowBlock:
+	default:
+		return nil, fmt.Errorf("invalid overflow strategy: %s", cfg.Overflow)
+	}
+	switch cfg.OnFailure {
+	case "":
+		cfg.OnFailure = FlushFailureDrop
+	case FlushFailureDrop, FlushFailureRequeue:
+	default:
+		return nil, fmt.Errorf("invalid flush failure policy: %s", cfg.OnFailure)
+	}
 
 	hook := &Hook{
-		storage:  storage,
-		project:  cfg.Project,
-		table:    cfg.Table,
-		buffer:   make([]*models.LogEntry, 0, cfg.BufferSize),
-		bufSize:  cfg.BufferSize,
-		interval: cfg.FlushPeriod,
-		done:     make(chan struct{}),
+		storage:      storage,
+		project:      cfg.Project,
+		table:        cfg.Table,
+		buffer:       make([]*models.LogEntry, 0, cfg.BufferSize),
+		bufSize:      cfg.BufferSize,
+		maxEntries:   cfg.MaxBufferEntries,
+		maxBytes:     cfg.MaxBufferBytes,
+		overflow:     cfg.Overflow,
+		blockTimeout: cfg.BlockTimeout,
+		interval:     cfg.FlushPeriod,
+		timeout:      cfg.Timeout,
+		retries:      cfg.FlushRetries,
+		backoff:      cfg.RetryBackoff,
+		onFailure:    cfg.OnFailure,
+		onError:      cfg.OnFlushError,
+		errHandler:   cfg.ErrorHandler,
+		clock:        clock.OrReal(cfg.Clock),
+		space:        make(chan struct{}),
+		flushNow:     make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+
+	if cfg.Provision.Enabled {
+		if err := hook.provision(cfg.Provision); err != nil {
+			return nil, fmt.Errorf("provision schema: %w", err)
+		}
+	}
+
+	// 重放上次退出时尚未写入存储的日志
+	if cfg.WAL.Dir != "" {
+		w, pending, err := openWAL(cfg.WAL, hook.clock, hook.handleError)
+		if err != nil {
+			return nil, fmt.Errorf("open wal: %w", err)
+		}
+		hook.wal = w
+		hook.buffer = append(hook.buffer, pending...)
+		for _, log := range pending {
+			hook.bufBytes += entrySize(log)
+		}
+		if len(pending) > 0 {
+			hook.oldest = hook.clock.Now()
+		}
 	}
 
 	// 启动定期刷新
@@ -170,15 +307,23 @@ func (h *Hook) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-// Sync 实现 zapcore.WriteSyncer 接口
+// Sync 实现 zapcore.WriteSyncer 接口，等待进行中的刷新完成后刷新剩余日志并返回写入错误
 func (h *Hook) Sync() error {
 	return h.Flush()
 }
 
-// Close 关闭钩子
+// Close 关闭钩子，启用 WAL 时未能写入存储的日志保留在磁盘上，下次启动时重放
 func (h *Hook) Close() error {
 	close(h.done)
-	return h.Flush()
+	// 等待进行中的定期刷新结束，保证返回时缓冲区已全部处理
+	<-h.stopped
+	err := h.Flush()
+	if h.wal != nil {
+		if walErr := h.wal.close(); err == nil {
+			err = walErr
+		}
+	}
+	return err
 }
 
 // WriteLog 写入日志
@@ -187,6 +332,8 @@ func (h *Hook) WriteLog(entry zapcore.Entry, fields []zapcore.Field) error {
 	log := &models.LogEntry{
 		Project:   h.project,
 		Table:     h.table,
+		Level:     entry.Level.String(),
+		Message:   entry.Message,
 		Timestamp: entry.Time,
 		Fields:    make(map[string]interface{}),
 	}
@@ -201,77 +348,226 @@ func (h *Hook) WriteLog(entry zapcore.Entry, fields []zapcore.Field) error {
 	}
 
 	// 添加自定义字段
-	for _, field := range fields {
-		switch field.Type {
-		case zapcore.StringType:
-			log.Fields[field.Key] = field.String
-		case zapcore.BoolType:
-			log.Fields[field.Key] = field.Integer == 1
-		case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
-			log.Fields[field.Key] = field.Integer
-		case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
-			log.Fields[field.Key] = uint64(field.Integer)
-		case zapcore.Float64Type:
-			log.Fields[field.Key] = float64(field.Integer)
-		case zapcore.Float32Type:
-			log.Fields[field.Key] = float32(field.Integer)
-		case zapcore.DurationType:
-			log.Fields[field.Key] = time.Duration(field.Integer)
-		case zapcore.TimeType:
-			log.Fields[field.Key] = time.Unix(0, field.Integer)
-		case zapcore.ErrorType:
-			if field.Interface != nil {
-				log.Fields[field.Key] = field.Interface.(error).Error()
-			}
-		case zapcore.ReflectType:
-			log.Fields[field.Key] = field.Interface
-		default:
-			log.Fields[field.Key] = fmt.Sprintf("%v", field.Interface)
-		}
-	}
+	encodeFields(log, fields)
+
+	return h.WriteEntry(log)
+}
+
+// WriteEntry 将构建好的日志条目写入缓冲区，项目和表使用钩子配置。
+// 缓冲区已满时按 Overflow 策略处理，新日志被丢弃时返回 ErrBufferFull；
+// 启用 WAL 时先追加到 WAL，追加失败的日志不进入缓冲区
+func (h *Hook) WriteEntry(log *models.LogEntry) error {
+	log.Project = h.project
+	log.Table = h.table
+	size := entrySize(log)
 
 	// 添加到缓冲区
 	h.mu.Lock()
+	dropped, err := h.reserve(size)
+	if err != nil {
+		h.mu.Unlock()
+		h.drop([]*models.LogEntry{log}, err)
+		return err
+	}
+	if h.wal != nil {
+		if err := h.wal.append(log); err != nil {
+			h.mu.Unlock()
+			h.drop(dropped, ErrBufferFull)
+			return fmt.Errorf("append wal: %w", err)
+		}
+	}
 	h.buffer = append(h.buffer, log)
-	shouldFlush := len(h.buffer) >= h.bufSize
+	h.bufBytes += size
+	if h.oldest.IsZero() {
+		h.oldest = h.clock.Now()
+	}
+	// 只在缓冲区刚好填满时触发刷新，requeue 后超出 BufferSize 的缓冲区交给定期刷新，避免存储不可用时每次写入都重试
+	shouldFlush := len(h.buffer) == h.bufSize
 	h.mu.Unlock()
 
-	// 如果缓冲区已满，立即刷新
+	h.drop(dropped, ErrBufferFull)
+
+	// 如果缓冲区已满，立即刷新；已有刷新在进行时交给后台刷新，
+	// 避免存储在写入过程中通过同一个 logger 记录日志时死锁
 	if shouldFlush {
-		return h.Flush()
+		if !h.flushMu.TryLock() {
+			h.requestFlush()
+			return nil
+		}
+		defer h.flushMu.Unlock()
+		return h.flush(context.Background(), false)
 	}
 
 	return nil
 }
 
-// Flush 刷新缓冲区
+// writeTimeout 返回单次写入存储的超时时间
+func (h *Hook) writeTimeout() time.Duration {
+	if h.timeout <= 0 {
+		return defaultWriteTimeout
+	}
+	return h.timeout
+}
+
+// requestFlush 通知定期刷新立即执行一次
+func (h *Hook) requestFlush() {
+	select {
+	case h.flushNow <- struct{}{}:
+	default:
+	}
+}
+
+// handleError 处理后台错误
+func (h *Hook) handleError(err error) {
+	if h.errHandler != nil {
+		h.errHandler(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "zap hook: %v\n", err)
+}
+
+// Flush 刷新缓冲区，写入失败时按 FlushRetries 重试，重试用尽后按 OnFailure 处理并返回最后一次的错误
 func (h *Hook) Flush() error {
+	return h.FlushContext(context.Background())
+}
+
+// FlushContext 与 Flush 相同，ctx 取消时停止写入与重试；每次写入另受 Timeout 约束
+func (h *Hook) FlushContext(ctx context.Context) error {
+	h.flushMu.Lock()
+	defer h.flushMu.Unlock()
+	return h.flush(ctx, true)
+}
+
+// flush 刷新缓冲区，调用方需持有 h.flushMu。schema 推断模式下只有 force 或缓冲区达到
+// LearnEntries 条时才会推断 schema 并写入，否则保留缓冲区
+func (h *Hook) flush(ctx context.Context, force bool) error {
+	if ready, err := h.learnSchema(ctx, force); err != nil || !ready {
+		return err
+	}
+
 	h.mu.Lock()
 	if len(h.buffer) == 0 {
 		h.mu.Unlock()
 		return nil
 	}
+	// 封存 WAL 段与取出缓冲区在同一把锁内完成，保证段中的日志恰好是本次写入的批次
+	var segments []string
+	if h.wal != nil {
+		var err error
+		if segments, err = h.wal.checkpoint(); err != nil {
+			h.mu.Unlock()
+			return fmt.Errorf("checkpoint wal: %w", err)
+		}
+	}
 	logs := make([]*models.LogEntry, len(h.buffer))
 	copy(logs, h.buffer)
 	h.buffer = h.buffer[:0]
+	h.bufBytes = 0
+	h.flushing, h.flushingSince, h.oldest = len(logs), h.oldest, time.Time{}
+	h.freed()
 	h.mu.Unlock()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	err := h.insert(ctx, logs)
+	since := h.flushed(err)
+	if err != nil {
+		h.flushFailures.Add(1)
+		if h.onFailure == FlushFailureRequeue {
+			h.drop(h.requeue(logs, segments, since), err)
+		} else {
+			h.drop(logs, err)
+		}
+		return err
+	}
+	h.flushedLogs.Add(uint64(len(logs)))
+	if h.wal != nil {
+		return h.wal.remove(segments)
+	}
+	return nil
+}
+
+// insert 写入一批日志，失败时按指数退避重试，ctx 取消时停止重试
+func (h *Hook) insert(ctx context.Context, logs []*models.LogEntry) error {
+	timeout := h.writeTimeout()
+	backoff := h.backoff
+	for attempt := 0; ; attempt++ {
+		// 缓冲区中的日志来自不同请求，只使用调用方的 ctx
+		writeCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := h.storage.BatchInsertLogs(writeCtx, h.project, h.table, logs)
+		cancel()
+		if err == nil || attempt >= h.retries {
+			return err
+		}
+
+		wait := make(chan struct{})
+		timer := h.clock.AfterFunc(backoff, func() { close(wait) })
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		}
+		backoff *= 2
+	}
+}
 
-	return h.storage.BatchInsertLogs(ctx, h.project, h.table, logs)
+// flushed 记录一次刷新的结果，返回该批次中最早的日志进入缓冲区的时间
+func (h *Hook) flushed(err error) time.Time {
+	now := h.clock.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	since := h.flushingSince
+	h.flushing, h.flushingSince = 0, time.Time{}
+	if err != nil {
+		h.lastErr, h.lastErrAt = err, now
+	} else {
+		h.lastFlush = now
+	}
+	return since
+}
+
+// requeue 将写入失败的日志放回缓冲区头部，返回按 Overflow 策略裁剪时被丢弃的日志。
+// 日志所在的 WAL 段随之归还，在下一次刷新成功后删除；since 为这批日志中最早的进入缓冲区的时间
+func (h *Hook) requeue(logs []*models.LogEntry, segments []string, since time.Time) []*models.LogEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.oldest.IsZero() || since.Before(h.oldest) {
+		h.oldest = since
+	}
+	h.buffer = append(logs, h.buffer...)
+	for _, log := range logs {
+		h.bufBytes += entrySize(log)
+	}
+	dropped := h.trim()
+	if h.wal != nil {
+		h.wal.restore(segments)
+	}
+	return dropped
+}
+
+// periodic 定期刷新，schema 推断模式下等待缓冲区达到 LearnEntries 条
+func (h *Hook) periodic() error {
+	h.flushMu.Lock()
+	defer h.flushMu.Unlock()
+	return h.flush(context.Background(), false)
 }
 
 // periodicFlush 定期刷新缓冲区
 func (h *Hook) periodicFlush() {
-	ticker := time.NewTicker(h.interval)
+	defer close(h.stopped)
+	ticker := h.clock.NewTicker(h.interval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			if err := h.Flush(); err != nil {
-				fmt.Printf("Failed to flush logs: %v\n", err)
+		case <-ticker.C():
+			if err := h.periodic(); err != nil {
+				h.handleError(fmt.Errorf("flush logs: %w", err))
+			}
+		case <-h.flushNow:
+			if err := h.periodic(); err != nil {
+				h.handleError(fmt.Errorf("flush logs: %w", err))
 			}
 		case <-h.done:
 			return
@@ -282,8 +578,9 @@ func (h *Hook) periodicFlush() {
 // Core 创建 zapcore.Core
 type Core struct {
 	zapcore.LevelEnabler
-	hook *Hook
-	enc  zapcore.Encoder
+	hook   *Hook
+	enc    zapcore.Encoder
+	fields []zapcore.Field
 }
 
 // NewCore 创建新的 Core
@@ -301,6 +598,7 @@ func (c *Core) With(fields []zapcore.Field) zapcore.Core {
 	for _, field := range fields {
 		field.AddTo(clone.enc)
 	}
+	clone.fields = append(clone.fields, fields...)
 	return clone
 }
 
@@ -314,7 +612,7 @@ func (c *Core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.Check
 
 // Write 写入日志
 func (c *Core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
-	return c.hook.WriteLog(ent, fields)
+	return c.hook.WriteLog(ent, append(c.fields[:len(c.fields):len(c.fields)], fields...))
 }
 
 // Sync 同步缓冲区
@@ -328,5 +626,6 @@ func (c *Core) clone() *Core {
 		LevelEnabler: c.LevelEnabler,
 		hook:         c.hook,
 		enc:          c.enc.Clone(),
+		fields:       c.fields[:len(c.fields):len(c.fields)],
 	}
 }
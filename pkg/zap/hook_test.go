@@ -2,26 +2,38 @@ package zap
 
 import (
 	"context"
+	"errors"
 	"math"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zapcore"
 	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+	"pkg.blksails.net/logs/pkg/logctx"
 )
 
 type mockStorage struct {
 	lastLog *models.LogEntry
 	called  bool
+	ctx     context.Context
+	batches chan []*models.LogEntry
 }
 
 func (m *mockStorage) Initialize(ctx context.Context) error { return nil }
 func (m *mockStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	if m.batches != nil {
+		m.batches <- logs
+	}
 	return nil
 }
 func (m *mockStorage) DeleteSchema(ctx context.Context, project, table string) error { return nil }
 func (m *mockStorage) InsertLog(ctx context.Context, project, table string, log *models.LogEntry) error {
+	m.called = true
+	m.lastLog = log
+	m.ctx = ctx
 	return nil
 }
 func (m *mockStorage) ListSchemas(ctx context.Context) ([]*models.Schema, error)     { return nil, nil }
@@ -73,3 +85,246 @@ func TestStorageHook_Write_FieldTypes(t *testing.T) {
 	assert.Equal(t, tm.Format(time.RFC3339), log.Fields["time"])
 	assert.Equal(t, int64(dur), log.Fields["duration"])
 }
+
+func TestHook_WriteLog_Context(t *testing.T) {
+	hook, err := NewHook(&mockStorage{}, &Config{Project: "test_project", Table: "test_table"})
+	assert.NoError(t, err)
+	defer hook.Close()
+
+	ctx := logctx.WithRequestID(context.Background(), "req-1")
+	err = hook.WriteLog(zapcore.Entry{Level: zapcore.InfoLevel, Message: "hello", Time: time.Now()},
+		[]zapcore.Field{Context(ctx), {Key: "str", Type: zapcore.StringType, String: "v"}})
+	assert.NoError(t, err)
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if assert.Len(t, hook.buffer, 1) {
+		log := hook.buffer[0]
+		assert.Equal(t, "test_project", log.Project)
+		assert.Equal(t, "req-1", log.Fields["request_id"])
+		assert.Equal(t, "v", log.Fields["str"])
+		assert.NotContains(t, log.Fields, "context")
+	}
+}
+
+func TestHook_PeriodicFlush(t *testing.T) {
+	mock := clock.NewMock(time.Now())
+	storage := &mockStorage{batches: make(chan []*models.LogEntry, 1)}
+	hook, err := NewHook(storage, &Config{Project: "test_project", Table: "test_table", FlushPeriod: time.Second, Clock: mock})
+	assert.NoError(t, err)
+
+	assert.NoError(t, hook.WriteLog(zapcore.Entry{Level: zapcore.InfoLevel, Message: "hello", Time: mock.Now()}, nil))
+
+	// 刷新周期未到时不写入
+	mock.BlockUntil(1)
+	mock.Add(500 * time.Millisecond)
+	select {
+	case <-storage.batches:
+		t.Fatal("flushed before the flush period")
+	default:
+	}
+
+	mock.Add(500 * time.Millisecond)
+	batch := <-storage.batches
+	if assert.Len(t, batch, 1) {
+		assert.Equal(t, "hello", batch[0].Fields["message"])
+	}
+
+	assert.NoError(t, hook.Close())
+}
+
+func TestStorageHook_Write_Context(t *testing.T) {
+	mock := &mockStorage{}
+	hook := NewStorageHook(StorageHookConfig{Storage: mock, Project: "p", Table: "t", Timeout: time.Minute})
+
+	// 请求已取消时日志仍然写入，且保留 context 中的值与写入超时
+	ctx, cancel := context.WithCancel(logctx.WithRequestID(context.Background(), "req-1"))
+	cancel()
+	err := hook.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "m", Time: time.Now()}, []zapcore.Field{Context(ctx)})
+	assert.NoError(t, err)
+	assert.Equal(t, "req-1", mock.lastLog.Fields["request_id"])
+	assert.Equal(t, "req-1", logctx.RequestID(mock.ctx))
+	deadline, ok := mock.ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+}
+
+// flakyStorage 前 failures 次批量写入失败
+type flakyStorage struct {
+	mockStorage
+	mu       sync.Mutex
+	failures int
+	calls    int
+	written  [][]*models.LogEntry
+	during   func() // 每次写入时调用，模拟刷新期间的并发写入
+}
+
+func (f *flakyStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.during != nil {
+		f.during()
+	}
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("storage unavailable")
+	}
+	f.written = append(f.written, logs)
+	return nil
+}
+
+func writeMessages(t *testing.T, hook *Hook, messages ...string) {
+	for _, msg := range messages {
+		assert.NoError(t, hook.WriteLog(zapcore.Entry{Level: zapcore.InfoLevel, Message: msg, Time: time.Now()}, nil))
+	}
+}
+
+func TestHook_FlushRetries(t *testing.T) {
+	storage := &flakyStorage{failures: 2}
+	hook, err := NewHook(storage, &Config{Project: "p", Table: "t", FlushPeriod: time.Hour, FlushRetries: 2, RetryBackoff: time.Millisecond})
+	assert.NoError(t, err)
+	defer hook.Close()
+
+	writeMessages(t, hook, "a")
+	assert.NoError(t, hook.Flush())
+	assert.Equal(t, 3, storage.calls)
+	assert.Len(t, storage.written, 1)
+}
+
+func TestHook_FlushDropCallsOnFlushError(t *testing.T) {
+	var dropped []*models.LogEntry
+	storage := &flakyStorage{failures: 2}
+	hook, err := NewHook(storage, &Config{
+		Project: "p", Table: "t", FlushPeriod: time.Hour, FlushRetries: 1, RetryBackoff: time.Millisecond,
+		OnFlushError: func(logs []*models.LogEntry, err error) { dropped = logs },
+	})
+	assert.NoError(t, err)
+	defer hook.Close()
+
+	writeMessages(t, hook, "a", "b")
+	assert.Error(t, hook.Flush())
+	assert.Equal(t, 2, storage.calls)
+	assert.Len(t, dropped, 2)
+
+	hook.mu.Lock()
+	assert.Empty(t, hook.buffer)
+	hook.mu.Unlock()
+}
+
+func TestHook_FlushRequeue(t *testing.T) {
+	var dropped []*models.LogEntry
+	storage := &flakyStorage{failures: 1}
+	hook, err := NewHook(storage, &Config{
+		Project: "p", Table: "t", BufferSize: 10, FlushPeriod: time.Hour, OnFailure: FlushFailureRequeue, MaxBufferEntries: 4,
+		OnFlushError: func(logs []*models.LogEntry, err error) { dropped = logs },
+	})
+	assert.NoError(t, err)
+	defer hook.Close()
+
+	writeMessages(t, hook, "a", "b")
+	assert.Error(t, hook.Flush())
+	assert.Nil(t, dropped)
+
+	// 放回的日志排在新日志之前
+	writeMessages(t, hook, "c", "d")
+	assert.NoError(t, hook.Flush())
+	if assert.Len(t, storage.written, 1) {
+		var messages []interface{}
+		for _, log := range storage.written[0] {
+			messages = append(messages, log.Fields["message"])
+		}
+		assert.Equal(t, []interface{}{"a", "b", "c", "d"}, messages)
+	}
+
+	// 刷新期间写入的日志与放回的日志超出 MaxBuffer 时丢弃最旧的
+	storage.failures = 10
+	storage.during = func() { writeMessages(t, hook, "g", "h") }
+	writeMessages(t, hook, "e", "f", "x")
+	assert.Error(t, hook.Flush())
+	if assert.Len(t, dropped, 1) {
+		assert.Equal(t, "e", dropped[0].Fields["message"])
+	}
+	hook.mu.Lock()
+	assert.Len(t, hook.buffer, 4)
+	hook.mu.Unlock()
+	storage.during = nil
+}
+
+func TestNewHook_InvalidFailurePolicy(t *testing.T) {
+	_, err := NewHook(&mockStorage{}, &Config{OnFailure: "ignore"})
+	assert.Error(t, err)
+}
+
+func TestHook_FlushContextStopsRetries(t *testing.T) {
+	storage := &flakyStorage{failures: 10}
+	hook, err := NewHook(storage, &Config{Project: "p", Table: "t", FlushPeriod: time.Hour, FlushRetries: 5, RetryBackoff: time.Hour})
+	assert.NoError(t, err)
+
+	writeMessages(t, hook, "a")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = hook.FlushContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, storage.calls)
+
+	close(hook.done)
+	<-hook.stopped
+}
+
+func TestHook_ErrorHandler(t *testing.T) {
+	mock := clock.NewMock(time.Now())
+	errs := make(chan error, 1)
+	hook, err := NewHook(&flakyStorage{failures: 1}, &Config{
+		Project: "p", Table: "t", FlushPeriod: time.Second, Clock: mock,
+		ErrorHandler: func(err error) { errs <- err },
+	})
+	assert.NoError(t, err)
+	defer hook.Close()
+
+	writeMessages(t, hook, "a")
+	mock.BlockUntil(1)
+	mock.Add(time.Second)
+	assert.ErrorContains(t, <-errs, "storage unavailable")
+}
+
+func TestHook_SyncWaitsForInFlightFlush(t *testing.T) {
+	mock := clock.NewMock(time.Now())
+	storage := &blockingStorage{started: make(chan struct{}, 2), release: make(chan struct{})}
+	hook, err := NewHook(storage, &Config{Project: "p", Table: "t", FlushPeriod: time.Second, Clock: mock})
+	assert.NoError(t, err)
+
+	writeMessages(t, hook, "a")
+	mock.BlockUntil(1)
+	mock.Add(time.Second)
+	<-storage.started
+
+	// 定期刷新尚未完成时 Sync 不能返回
+	synced := make(chan error, 1)
+	go func() { synced <- hook.Sync() }()
+	select {
+	case <-synced:
+		t.Fatal("Sync returned before the in-flight flush finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(storage.release)
+	assert.NoError(t, <-synced)
+	assert.Equal(t, uint64(1), hook.Stats().Flushed)
+	assert.NoError(t, hook.Close())
+}
+
+func TestHook_MaxBufferEntries(t *testing.T) {
+	var dropped []*models.LogEntry
+	var dropErr error
+	hook, err := NewHook(&mockStorage{}, &Config{
+		Project: "p", Table: "t", BufferSize: 10, MaxBufferEntries: 2, FlushPeriod: time.Hour,
+		OnFlushError: func(logs []*models.LogEntry, err error) { dropped, dropErr = logs, err },
+	})
+	assert.NoError(t, err)
+	defer hook.Close()
+
+	writeMessages(t, hook, "a", "b", "c")
+	if assert.Len(t, dropped, 1) {
+		assert.Equal(t, "a", dropped[0].Fields["message"])
+	}
+	assert.ErrorIs(t, dropErr, ErrBufferFull)
+}
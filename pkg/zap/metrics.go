@@ -0,0 +1,101 @@
+package zap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// labelEscaper 转义 Prometheus 标签值中的反斜杠、引号与换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// hookMetric 一个按 Hook 输出的指标
+type hookMetric struct {
+	name, kind, help string
+	value            func(stats *HookStats) float64
+}
+
+// hookMetrics WriteMetrics 输出的指标，每个 Hook 一条以 project、table 区分的序列
+var hookMetrics = []hookMetric{
+	{"logs_hook_buffered_entries", "gauge", "Logs waiting in the hook buffer.",
+		func(s *HookStats) float64 { return float64(s.Buffered) }},
+	{"logs_hook_buffered_bytes", "gauge", "Estimated memory used by logs in the hook buffer.",
+		func(s *HookStats) float64 { return float64(s.BufferedBytes) }},
+	{"logs_hook_flushing_entries", "gauge", "Logs being written to storage by an in-progress flush.",
+		func(s *HookStats) float64 { return float64(s.Flushing) }},
+	{"logs_hook_oldest_entry_age_seconds", "gauge", "Time the oldest unwritten log has been waiting; 0 when everything is written.",
+		func(s *HookStats) float64 { return s.OldestAge.Seconds() }},
+	{"logs_hook_flushed_total", "counter", "Logs written to storage.",
+		func(s *HookStats) float64 { return float64(s.Flushed) }},
+	{"logs_hook_dropped_total", "counter", "Logs dropped because the buffer overflowed or a flush failed.",
+		func(s *HookStats) float64 { return float64(s.Dropped) }},
+	{"logs_hook_flush_failures_total", "counter", "Flushes that failed after all retries.",
+		func(s *HookStats) float64 { return float64(s.FlushFailures) }},
+	{"logs_hook_last_flush_timestamp_seconds", "gauge", "Unix time of the last successful flush; 0 before the first one.",
+		func(s *HookStats) float64 { return unixSeconds(s.LastFlush) }},
+	{"logs_hook_last_flush_error_timestamp_seconds", "gauge", "Unix time of the last failed flush; 0 if no flush has failed.",
+		func(s *HookStats) float64 { return unixSeconds(s.LastFlushErrorAt) }},
+}
+
+// WriteMetrics 以 Prometheus 文本格式（0.0.4）输出 hooks 的缓冲区长度、正在写入的日志数、最早未写入日志的等待时长
+// 与最近一次刷新的结果，各 Hook 以 project、table 标签区分。logs_hook_last_flush_error_info 以 error 标签给出最近一次失败的错误
+func WriteMetrics(w io.Writer, hooks ...*Hook) (int64, error) {
+	stats := make([]HookStats, len(hooks))
+	for i, hook := range hooks {
+		stats[i] = hook.Stats()
+	}
+
+	var b strings.Builder
+	for _, metric := range hookMetrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for i := range stats {
+			fmt.Fprintf(&b, "%s{%s} %s\n", metric.name, hookLabels(&stats[i]), strconv.FormatFloat(metric.value(&stats[i]), 'g', -1, 64))
+		}
+	}
+	b.WriteString("# HELP logs_hook_last_flush_error_info The error of the last failed flush.\n# TYPE logs_hook_last_flush_error_info gauge\n")
+	for i := range stats {
+		if stats[i].LastFlushError != "" {
+			fmt.Fprintf(&b, "logs_hook_last_flush_error_info{%s,error=\"%s\"} 1\n", hookLabels(&stats[i]), labelEscaper.Replace(stats[i].LastFlushError))
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// MetricsHandler 返回输出 WriteMetrics 的 http.Handler，可挂载到应用自己的 /metrics
+func MetricsHandler(hooks ...*Hook) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w, hooks...)
+	})
+}
+
+// StatsHandler 返回以 JSON 数组输出各 Hook 的 Stats 的 http.Handler，用于排查写入是否落后
+func StatsHandler(hooks ...*Hook) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		stats := make([]HookStats, len(hooks))
+		for i, hook := range hooks {
+			stats[i] = hook.Stats()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	})
+}
+
+// hookLabels 返回 Hook 的 project、table 标签
+func hookLabels(stats *HookStats) string {
+	return fmt.Sprintf(`project="%s",table="%s"`, labelEscaper.Replace(stats.Project), labelEscaper.Replace(stats.Table))
+}
+
+// unixSeconds 返回 t 的 Unix 秒数，nil 返回 0
+func unixSeconds(t *time.Time) float64 {
+	if t == nil {
+		return 0
+	}
+	return float64(t.UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,102 @@
+package zap
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+func TestHook_FlushLag(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock := clock.NewMock(start)
+	hook, err := NewHook(&flakyStorage{failures: 1}, &Config{
+		Project: "app", Table: "events", FlushPeriod: time.Hour, Clock: mock, OnFailure: FlushFailureRequeue,
+	})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	stats := hook.Stats()
+	assert.Nil(t, stats.Oldest)
+	assert.Zero(t, stats.OldestAge)
+
+	writeMessages(t, hook, "a")
+	mock.Add(30 * time.Second)
+	writeMessages(t, hook, "b")
+	stats = hook.Stats()
+	assert.Equal(t, "app", stats.Project)
+	assert.Equal(t, 2, stats.Buffered)
+	require.NotNil(t, stats.Oldest)
+	assert.Equal(t, start, *stats.Oldest)
+	assert.Equal(t, 30*time.Second, stats.OldestAge)
+
+	// 失败的批次放回缓冲区，等待时长从最早的日志进入缓冲区时算起
+	require.Error(t, hook.Flush())
+	stats = hook.Stats()
+	assert.Equal(t, 2, stats.Buffered)
+	assert.Equal(t, start, *stats.Oldest)
+	assert.Equal(t, "storage unavailable", stats.LastFlushError)
+	assert.Equal(t, start.Add(30*time.Second), *stats.LastFlushErrorAt)
+	assert.Nil(t, stats.LastFlush)
+
+	var b strings.Builder
+	_, err = WriteMetrics(&b, hook)
+	require.NoError(t, err)
+	metrics := b.String()
+	assert.Contains(t, metrics, `logs_hook_buffered_entries{project="app",table="events"} 2`)
+	assert.Contains(t, metrics, `logs_hook_oldest_entry_age_seconds{project="app",table="events"} 30`)
+	assert.Contains(t, metrics, `logs_hook_flush_failures_total{project="app",table="events"} 1`)
+	assert.Contains(t, metrics, `logs_hook_last_flush_timestamp_seconds{project="app",table="events"} 0`)
+	assert.Contains(t, metrics, `logs_hook_last_flush_error_info{project="app",table="events",error="storage unavailable"} 1`)
+
+	mock.Add(10 * time.Second)
+	require.NoError(t, hook.Flush())
+	stats = hook.Stats()
+	assert.Zero(t, stats.Buffered)
+	assert.Nil(t, stats.Oldest)
+	assert.Zero(t, stats.OldestAge)
+	assert.Equal(t, start.Add(40*time.Second), *stats.LastFlush)
+	assert.Equal(t, "storage unavailable", stats.LastFlushError, "the last error is kept after a successful flush")
+
+	w := httptest.NewRecorder()
+	StatsHandler(hook).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var decoded []HookStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decoded))
+	require.Len(t, decoded, 1)
+	assert.Equal(t, uint64(2), decoded[0].Flushed)
+	assert.Contains(t, w.Body.String(), `"last_flush":"2024-01-02T03:04:45Z"`)
+
+	w = httptest.NewRecorder()
+	MetricsHandler(hook).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `logs_hook_last_flush_timestamp_seconds{project="app",table="events"} 1.704164685e+09`)
+}
+
+func TestHook_FlushingStats(t *testing.T) {
+	storage := &blockingStorage{started: make(chan struct{}), release: make(chan struct{})}
+	hook, err := NewHook(storage, &Config{Project: "p", Table: "t", FlushPeriod: time.Hour})
+	require.NoError(t, err)
+
+	writeMessages(t, hook, "a", "b")
+	done := make(chan error)
+	go func() { done <- hook.Flush() }()
+	<-storage.started
+
+	// 正在写入的日志计入 Flushing，仍算作尚未写入
+	stats := hook.Stats()
+	assert.Zero(t, stats.Buffered)
+	assert.Equal(t, 2, stats.Flushing)
+	assert.NotNil(t, stats.Oldest)
+
+	close(storage.release)
+	require.NoError(t, <-done)
+	stats = hook.Stats()
+	assert.Zero(t, stats.Flushing)
+	assert.Nil(t, stats.Oldest)
+	require.NoError(t, hook.Close())
+}
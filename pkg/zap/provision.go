@@ -0,0 +1,79 @@
+package zap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// ProvisionConfig 目标 schema 不存在时自动创建的配置
+type ProvisionConfig struct {
+	Enabled bool
+	// Fields 声明的字段，启动时按这些字段创建 schema；为空时从最先写入的 LearnEntries 条日志推断
+	Fields []*models.Field
+	// LearnEntries 推断 schema 使用的日志条数，默认 BufferSize，不超过 MaxBufferEntries。
+	// 推断完成前日志保留在缓冲区中，Sync、Flush 与 Close 会用已有的日志立即推断
+	LearnEntries int
+}
+
+// provision 启动时检查目标 schema，不存在时按声明的字段创建，未声明字段时进入推断模式
+func (h *Hook) provision(cfg ProvisionConfig) error {
+	ctx, cancel := writeContext(nil, h.timeout)
+	defer cancel()
+
+	schema, err := h.storage.GetSchema(ctx, h.project, h.table)
+	switch {
+	case err == nil && schema != nil:
+		return nil
+	case err != nil && !errors.Is(err, models.ErrSchemaNotFound):
+		return fmt.Errorf("get schema: %w", err)
+	}
+
+	if len(cfg.Fields) > 0 {
+		return h.createSchema(ctx, &models.Schema{Project: h.project, Table: h.table, Fields: cfg.Fields})
+	}
+	h.learnN = cfg.LearnEntries
+	if h.learnN <= 0 {
+		h.learnN = h.bufSize
+	}
+	h.learnN = min(h.learnN, h.maxEntries)
+	return nil
+}
+
+// learnSchema 推断模式下缓冲区达到 LearnEntries 条（force 时只要非空）后推断并创建 schema，
+// 返回 schema 是否已就绪。调用方需持有 h.flushMu
+func (h *Hook) learnSchema(ctx context.Context, force bool) (bool, error) {
+	if h.learnN == 0 {
+		return true, nil
+	}
+
+	h.mu.Lock()
+	if len(h.buffer) == 0 || (len(h.buffer) < h.learnN && !force) {
+		h.mu.Unlock()
+		return false, nil
+	}
+	sample := append([]*models.LogEntry(nil), h.buffer[:min(len(h.buffer), h.learnN)]...)
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, h.writeTimeout())
+	defer cancel()
+	if err := h.createSchema(ctx, models.InferSchema(h.project, h.table, sample)); err != nil {
+		return false, err
+	}
+	h.learnN = 0
+	return true, nil
+}
+
+// createSchema 创建 schema，其他进程已抢先创建时视为成功
+func (h *Hook) createSchema(ctx context.Context, schema *models.Schema) error {
+	err := h.storage.CreateSchema(ctx, schema)
+	if err == nil {
+		return nil
+	}
+	if existing, getErr := h.storage.GetSchema(ctx, h.project, h.table); getErr == nil && existing != nil {
+		return nil
+	}
+	return fmt.Errorf("create schema: %w", err)
+}
@@ -0,0 +1,91 @@
+package zap
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/internal/storage"
+)
+
+func newSQLiteStorage(t *testing.T) storage.Storage {
+	store, err := storage.New(context.Background(), storage.Config{
+		Type:   "sqlite",
+		SQLite: storage.SQLiteConfig{Path: filepath.Join(t.TempDir(), "logs.db")},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestHook_ProvisionDeclaredFields(t *testing.T) {
+	store := newSQLiteStorage(t)
+	hook, err := NewHook(store, &Config{
+		Project: "app", Table: "events", FlushPeriod: time.Hour,
+		Provision: ProvisionConfig{Enabled: true, Fields: []*models.Field{
+			{Name: "level", Type: models.FieldTypeString},
+			{Name: "message", Type: models.FieldTypeString},
+			{Name: "service", Type: models.FieldTypeString, Indexed: true},
+		}},
+	})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	schema, err := store.GetSchema(context.Background(), "app", "events")
+	require.NoError(t, err)
+	assert.Len(t, schema.Fields, 3)
+}
+
+func TestHook_ProvisionLearnsSchema(t *testing.T) {
+	store := newSQLiteStorage(t)
+	hook, err := NewHook(store, &Config{
+		Project: "app", Table: "events", BufferSize: 10, FlushPeriod: time.Hour,
+		Provision: ProvisionConfig{Enabled: true, LearnEntries: 2},
+	})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	logger := zap.New(NewCore(hook, zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.DebugLevel))
+	logger.Info("first", zap.String("service", "api"), zap.Int("status", 200))
+
+	// 样本不足时定期刷新保留缓冲区
+	require.NoError(t, hook.periodic())
+	_, err = store.GetSchema(context.Background(), "app", "events")
+	assert.ErrorIs(t, err, models.ErrSchemaNotFound)
+
+	logger.Info("second", zap.Float64("latency", 0.5))
+	require.NoError(t, hook.periodic())
+
+	schema, err := store.GetSchema(context.Background(), "app", "events")
+	require.NoError(t, err)
+	types := make(map[string]models.FieldType)
+	for _, field := range schema.Fields {
+		types[field.Name] = field.Type
+	}
+	assert.Equal(t, models.FieldTypeString, types["service"])
+	assert.Equal(t, models.FieldTypeInt, types["status"])
+	assert.Equal(t, models.FieldTypeFloat, types["latency"])
+	assert.Equal(t, models.FieldTypeRest, types["rest"])
+	assert.Equal(t, uint64(2), hook.Stats().Flushed)
+
+	count, err := store.(*storage.SQLiteStorage).CountLogs(context.Background(), "app", "events", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestHook_ProvisionExistingSchema(t *testing.T) {
+	store := newSQLiteStorage(t)
+	require.NoError(t, store.CreateSchema(context.Background(), &models.Schema{
+		Project: "app", Table: "events", Fields: []*models.Field{{Name: "service", Type: models.FieldTypeString}},
+	}))
+	hook, err := NewHook(store, &Config{Project: "app", Table: "events", FlushPeriod: time.Hour, Provision: ProvisionConfig{Enabled: true}})
+	require.NoError(t, err)
+	defer hook.Close()
+	assert.Zero(t, hook.learnN)
+}
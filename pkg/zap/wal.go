@@ -0,0 +1,298 @@
+package zap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.blksails.net/logs/internal/models"
+	"pkg.blksails.net/logs/pkg/clock"
+)
+
+// WAL 落盘策略
+const (
+	WALSyncAlways   = "always"   // 每条日志写入后 fsync
+	WALSyncInterval = "interval" // 按 SyncInterval 定期 fsync
+	WALSyncNone     = "none"     // 不主动 fsync，由操作系统决定何时落盘
+)
+
+const (
+	// defaultWALSegmentSize WAL 段文件的默认大小上限
+	defaultWALSegmentSize = 16 << 20
+	// defaultWALSyncInterval interval 策略的默认 fsync 间隔
+	defaultWALSyncInterval = time.Second
+	// walSegmentExt WAL 段文件扩展名
+	walSegmentExt = ".wal"
+)
+
+// WALConfig Hook 缓冲区的预写日志配置，Dir 为空时不启用
+type WALConfig struct {
+	Dir          string        // 段文件目录，每个 Hook 应使用独立的目录
+	SegmentSize  int64         // 单个段文件的大小上限，默认 16MB
+	SyncPolicy   string        // always、interval 或 none，默认 always
+	SyncInterval time.Duration // interval 策略的 fsync 间隔，默认 1s
+}
+
+// wal 以 JSON Lines 段文件保存尚未写入存储的日志。
+// 每次刷新都会封存当前段，批次写入成功后删除对应的段；写入失败的段在 requeue 时随日志归还，否则保留到下次启动时重放
+type wal struct {
+	dir      string
+	segSize  int64
+	policy   string
+	interval time.Duration
+	clock    clock.Clock
+	onError  func(err error)
+
+	mu     sync.Mutex
+	seq    int
+	active *os.File
+	size   int64
+	dirty  bool
+	sealed []string
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// openWAL 打开 WAL 目录并返回其中尚未写入存储的日志，这些日志所在的段在下一次刷新成功后删除。
+// onError 处理定期 fsync 的错误
+func openWAL(cfg WALConfig, c clock.Clock, onError func(err error)) (*wal, []*models.LogEntry, error) {
+	switch cfg.SyncPolicy {
+	case "":
+		cfg.SyncPolicy = WALSyncAlways
+	case WALSyncAlways, WALSyncInterval, WALSyncNone:
+	default:
+		return nil, nil, fmt.Errorf("invalid wal sync policy: %s", cfg.SyncPolicy)
+	}
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = defaultWALSegmentSize
+	}
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = defaultWALSyncInterval
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("create wal dir: %w", err)
+	}
+
+	w := &wal{
+		dir:      cfg.Dir,
+		segSize:  cfg.SegmentSize,
+		policy:   cfg.SyncPolicy,
+		interval: cfg.SyncInterval,
+		clock:    clock.OrReal(c),
+		onError:  onError,
+		done:     make(chan struct{}),
+	}
+
+	names, err := w.segments()
+	if err != nil {
+		return nil, nil, err
+	}
+	var logs []*models.LogEntry
+	for _, name := range names {
+		entries, err := readWALSegment(filepath.Join(w.dir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+		logs = append(logs, entries...)
+		w.sealed = append(w.sealed, name)
+		seq, _ := strconv.Atoi(strings.TrimSuffix(name, walSegmentExt))
+		w.seq = max(w.seq, seq)
+	}
+
+	if w.policy == WALSyncInterval {
+		w.wg.Add(1)
+		go w.periodicSync()
+	}
+	return w, logs, nil
+}
+
+// segments 按序号返回目录中的段文件名
+func (w *wal) segments() ([]string, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read wal dir: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, walSegmentExt) {
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimSuffix(name, walSegmentExt)); err == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readWALSegment 读取段文件中的日志，崩溃时写了一半的行被跳过
+func readWALSegment(path string) ([]*models.LogEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open wal segment: %w", err)
+	}
+	defer f.Close()
+
+	var logs []*models.LogEntry
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var log models.LogEntry
+			if json.Unmarshal(line, &log) == nil {
+				logs = append(logs, &log)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return logs, nil
+}
+
+// append 将日志追加到当前段，按落盘策略 fsync 后返回
+func (w *wal) append(log *models.LogEntry) error {
+	data, err := json.Marshal(log)
+	if err != nil {
+		return fmt.Errorf("encode wal entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.active != nil && w.size+int64(len(data)) > w.segSize {
+		if err := w.seal(); err != nil {
+			return err
+		}
+	}
+	if w.active == nil {
+		w.seq++
+		name := fmt.Sprintf("%08d%s", w.seq, walSegmentExt)
+		f, err := os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("create wal segment: %w", err)
+		}
+		w.active, w.size = f, 0
+	}
+	n, err := w.active.Write(data)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write wal segment: %w", err)
+	}
+	if w.policy == WALSyncAlways {
+		if err := w.active.Sync(); err != nil {
+			return fmt.Errorf("sync wal segment: %w", err)
+		}
+		return nil
+	}
+	w.dirty = true
+	return nil
+}
+
+// seal 同步并关闭当前段，调用方需持有 w.mu
+func (w *wal) seal() error {
+	if w.active == nil {
+		return nil
+	}
+	name := filepath.Base(w.active.Name())
+	if err := w.active.Sync(); err != nil {
+		return fmt.Errorf("sync wal segment: %w", err)
+	}
+	if err := w.active.Close(); err != nil {
+		return fmt.Errorf("close wal segment: %w", err)
+	}
+	w.active, w.dirty = nil, false
+	w.sealed = append(w.sealed, name)
+	return nil
+}
+
+// checkpoint 封存当前段并返回此前所有段，调用方在这些日志写入存储后调用 remove
+func (w *wal) checkpoint() ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.seal(); err != nil {
+		return nil, err
+	}
+	names := w.sealed
+	w.sealed = nil
+	return names, nil
+}
+
+// restore 归还 checkpoint 取出但未能写入存储的段，排在之后封存的段之前
+func (w *wal) restore(names []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.sealed = append(append([]string(nil), names...), w.sealed...)
+}
+
+// remove 删除已写入存储的段
+func (w *wal) remove(names []string) error {
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(w.dir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove wal segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// sync 将当前段中尚未落盘的数据 fsync
+func (w *wal) sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.active == nil || !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	if err := w.active.Sync(); err != nil {
+		return fmt.Errorf("sync wal segment: %w", err)
+	}
+	return nil
+}
+
+// periodicSync interval 策略下定期 fsync
+func (w *wal) periodicSync() {
+	defer w.wg.Done()
+	ticker := w.clock.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			if err := w.sync(); err != nil {
+				w.onError(err)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// close 停止定期 fsync 并关闭当前段，未写入存储的段保留在磁盘上
+func (w *wal) close() error {
+	close(w.done)
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.active == nil {
+		return nil
+	}
+	err := w.active.Sync()
+	if closeErr := w.active.Close(); err == nil {
+		err = closeErr
+	}
+	w.active = nil
+	return err
+}
@@ -0,0 +1,111 @@
+package zap
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"pkg.blksails.net/logs/internal/models"
+)
+
+// failingStorage 批量写入总是失败
+type failingStorage struct {
+	mockStorage
+}
+
+func (f *failingStorage) BatchInsertLogs(ctx context.Context, project, table string, logs []*models.LogEntry) error {
+	return errors.New("storage unavailable")
+}
+
+func walFiles(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+walSegmentExt))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestHook_WALReplay(t *testing.T) {
+	dir := t.TempDir()
+	hook, err := NewHook(&failingStorage{}, &Config{Project: "p", Table: "t", FlushPeriod: time.Hour, WAL: WALConfig{Dir: dir}})
+	require.NoError(t, err)
+	for _, msg := range []string{"first", "second"} {
+		require.NoError(t, hook.WriteLog(zapcore.Entry{Level: zapcore.InfoLevel, Message: msg, Time: time.Now()}, nil))
+	}
+	assert.Len(t, walFiles(t, dir), 1)
+
+	// 写入存储失败时段文件保留
+	assert.Error(t, hook.Close())
+	assert.Len(t, walFiles(t, dir), 1)
+
+	storage := &mockStorage{batches: make(chan []*models.LogEntry, 1)}
+	hook, err = NewHook(storage, &Config{Project: "p", Table: "t", FlushPeriod: time.Hour, WAL: WALConfig{Dir: dir}})
+	require.NoError(t, err)
+	require.NoError(t, hook.WriteLog(zapcore.Entry{Level: zapcore.InfoLevel, Message: "third", Time: time.Now()}, nil))
+	require.NoError(t, hook.Flush())
+
+	batch := <-storage.batches
+	if assert.Len(t, batch, 3) {
+		assert.Equal(t, "first", batch[0].Fields["message"])
+		assert.Equal(t, "second", batch[1].Fields["message"])
+		assert.Equal(t, "third", batch[2].Fields["message"])
+	}
+	assert.Empty(t, walFiles(t, dir))
+	assert.NoError(t, hook.Close())
+}
+
+func TestHook_WALSkipsTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	hook, err := NewHook(&failingStorage{}, &Config{Project: "p", Table: "t", FlushPeriod: time.Hour, WAL: WALConfig{Dir: dir, SyncPolicy: WALSyncNone}})
+	require.NoError(t, err)
+	require.NoError(t, hook.WriteLog(zapcore.Entry{Level: zapcore.InfoLevel, Message: "complete", Time: time.Now()}, nil))
+	close(hook.done)
+	require.NoError(t, hook.wal.close())
+
+	// 模拟崩溃时写了一半的行
+	files := walFiles(t, dir)
+	require.Len(t, files, 1)
+	f, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"project":"p","fields":{"message":"tor`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	hook, err = NewHook(&mockStorage{}, &Config{Project: "p", Table: "t", FlushPeriod: time.Hour, WAL: WALConfig{Dir: dir}})
+	require.NoError(t, err)
+	hook.mu.Lock()
+	if assert.Len(t, hook.buffer, 1) {
+		assert.Equal(t, "complete", hook.buffer[0].Fields["message"])
+	}
+	hook.mu.Unlock()
+	assert.NoError(t, hook.Close())
+	assert.Empty(t, walFiles(t, dir))
+}
+
+func TestWAL_SegmentRotation(t *testing.T) {
+	dir := t.TempDir()
+	w, pending, err := openWAL(WALConfig{Dir: dir, SegmentSize: 200}, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, w.append(&models.LogEntry{Project: "p", Table: "t", Fields: map[string]interface{}{"message": "rotate me"}}))
+	}
+	assert.Greater(t, len(walFiles(t, dir)), 1)
+
+	segments, err := w.checkpoint()
+	require.NoError(t, err)
+	assert.Equal(t, len(walFiles(t, dir)), len(segments))
+	require.NoError(t, w.remove(segments))
+	assert.Empty(t, walFiles(t, dir))
+	assert.NoError(t, w.close())
+}
+
+func TestWAL_InvalidSyncPolicy(t *testing.T) {
+	_, _, err := openWAL(WALConfig{Dir: t.TempDir(), SyncPolicy: "sometimes"}, nil, nil)
+	assert.Error(t, err)
+}